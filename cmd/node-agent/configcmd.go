@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"utopia-node-agent/internal/config"
+)
+
+// stringList 可重复的命令行参数
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// runConfig 处理 config 子命令
+func runConfig(layers *config.Layers, path string, args []string) error {
+	if len(args) > 0 && args[0] == "migrate" {
+		return runConfigMigrate(path, args[1:])
+	}
+	if len(args) == 0 || args[0] != "show" {
+		return fmt.Errorf("usage: node-agent [-config path] [-set key=value] config show [--resolved] | config migrate [--write]")
+	}
+
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	resolved := fs.Bool("resolved", false, "Print every effective value with its source")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if !*resolved {
+		data, err := layers.RedactedYAML()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	settings, err := layers.Settings()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, s := range settings {
+		var value strings.Builder
+		encoder := json.NewEncoder(&value)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(s.Value); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Key, strings.TrimSpace(value.String()), s.Source)
+	}
+	return w.Flush()
+}
+
+// runConfigMigrate 将配置文件升级到当前结构版本：默认输出升级后的内容，--write 时改写文件
+func runConfigMigrate(path string, args []string) error {
+	fs := flag.NewFlagSet("config migrate", flag.ContinueOnError)
+	write := fs.Bool("write", false, "Rewrite the config file in place, keeping the original as <path>.bak")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	migration, err := config.MigrateFile(data)
+	if err != nil {
+		return err
+	}
+	if !migration.Migrated() {
+		fmt.Fprintf(os.Stderr, "%s is already at config_version %d\n", path, migration.ToVersion)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Migrating %s from config_version %d to %d\n", path, migration.FromVersion, migration.ToVersion)
+	for _, change := range migration.Changes {
+		fmt.Fprintf(os.Stderr, "  %s\n", change)
+	}
+
+	if !*write {
+		out, err := migration.YAML()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	if err := config.WriteMigratedFile(path, migration); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s (original saved as %s.bak)\n", path, path)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"utopia-node-agent/internal/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// runDeregister 请求本机运行中的agent执行节点退役流程
+// 启用独立管理监听地址时使用该地址与管理令牌
+func runDeregister(cfg *config.Config) error {
+	address, token := cfg.AgentAPI.ListenAddress, cfg.AgentAPI.AuthToken
+	if cfg.AgentAPI.AdminListenAddress != "" {
+		address = cfg.AgentAPI.AdminListenAddress
+		var err error
+		if token, err = adminToken(cfg); err != nil {
+			return err
+		}
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", address, err)
+	}
+	// 监听通配地址时通过回环地址访问
+	url := fmt.Sprintf("http://%s/api/v1/admin/node", net.JoinHostPort(config.LoopbackFor(host), port))
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach agent at %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("agent returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	log.Info("Decommission started; the agent will remove all containers, deregister and exit")
+	return nil
+}
+
+// adminToken 读取管理端点的令牌：配置的令牌、令牌文件或agent自动生成的令牌
+func adminToken(cfg *config.Config) (string, error) {
+	if cfg.AgentAPI.AdminAuthToken != "" {
+		return cfg.AgentAPI.AdminAuthToken, nil
+	}
+	path := cfg.AgentAPI.AdminAuthTokenFile
+	if path == "" {
+		path = filepath.Join(cfg.DataDir, "admin_token")
+	}
+	token, err := config.ReadSecretFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read admin token: %w", err)
+	}
+	return token, nil
+}
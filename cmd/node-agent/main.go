@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -10,6 +11,9 @@ import (
 
 	"utopia-node-agent/internal/agent"
 	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/logsample"
+	"utopia-node-agent/internal/redact"
+	"utopia-node-agent/internal/simulate"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -20,10 +24,19 @@ var (
 )
 
 func main() {
+	// 模拟模式下本进程同时作为 docker、frpc 等命令的替身
+	if code, ok := simulate.RunShim(); ok {
+		os.Exit(code)
+	}
+
 	var (
 		configPath  = flag.String("config", "/etc/utopia/agent-config.yaml", "Configuration file path")
 		showVersion = flag.Bool("version", false, "Show version information")
+		asPID1      = flag.Bool("as-pid1", false, "Run as the init process of a container: forward signals to the agent and reap orphaned processes")
+		downtime    = flag.Duration("expected-downtime", 0, "Expected downtime reported to the platform when the agent shuts down (e.g. 30m); can be changed at runtime via the admin API")
+		setValues   stringList
 	)
+	flag.Var(&setValues, "set", "Override a config key (key=value, repeatable); takes precedence over file, platform overrides and environment")
 	flag.Parse()
 
 	if *showVersion {
@@ -32,24 +45,72 @@ func main() {
 	}
 
 	// 配置日志
-	log.SetFormatter(&log.JSONFormatter{})
+	// 遮盖日志中的敏感值；重复的日志在时间窗口内限流并汇总，策略由配置决定
+	log.SetFormatter(logsample.Default().Formatter(redact.Formatter(&log.JSONFormatter{})))
 	log.SetLevel(log.InfoLevel)
 
-	// 加载配置
-	cfg, err := config.LoadConfig(*configPath)
+	// 容器中作为PID 1运行时由init进程启动agent子进程
+	if *asPID1 && os.Getenv(pid1ChildEnv) == "" {
+		os.Exit(runAsPID1())
+	}
+	if os.Getpid() == 1 {
+		log.Warn("Running as PID 1 without --as-pid1: orphaned processes will not be reaped")
+	}
+
+	// 模拟节点使用替身frpc，不下载真实的frpc（命令行中的 -set 仍可覆盖）
+	if flag.Arg(0) == "simulate" {
+		setValues = append(stringList{"frp.frpc_download.enabled=false"}, setValues...)
+	}
+
+	// 加载配置：默认值 < 配置文件 < 平台覆盖 < 环境变量 < 命令行参数
+	layers, err := config.Load(*configPath, setValues)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	cfg := layers.Config()
+
+	if level, err := log.ParseLevel(cfg.LogLevel); err == nil {
+		log.SetLevel(level)
+	}
+
+	// 子命令
+	var sim *simulate.Simulator
+	switch flag.Arg(0) {
+	case "":
+	case "simulate":
+		if sim, err = setupSimulation(cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to set up simulation: %v", err)
+		}
+	case "deregister":
+		if err := runDeregister(cfg); err != nil {
+			log.Fatalf("Deregister failed: %v", err)
+		}
+		return
+	case "config":
+		if err := runConfig(layers, *configPath, flag.Args()[1:]); err != nil {
+			log.Fatalf("Config command failed: %v", err)
+		}
+		return
+	default:
+		log.Fatalf("Unknown command: %s", flag.Arg(0))
+	}
 
 	// 创建并启动代理
-	nodeAgent, err := agent.New(cfg)
+	agent.Version = version
+	nodeAgent, err := agent.New(layers)
 	if err != nil {
 		log.Fatalf("Failed to create agent: %v", err)
 	}
+	if *downtime > 0 {
+		nodeAgent.PlanDowntime(*downtime, "")
+	}
 
 	// 设置信号处理
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// SIGUSR2 触发无中断重启（需启用 handover.enabled）
+	restartChan := make(chan os.Signal, 1)
+	signal.Notify(restartChan, syscall.SIGUSR2)
 
 	// 启动代理
 	errChan := make(chan error, 1)
@@ -61,12 +122,31 @@ func main() {
 
 	log.Info("Utopia Node Agent started successfully")
 
+	simCtx, stopSim := context.WithCancel(context.Background())
+	defer stopSim()
+	if sim != nil {
+		go sim.Run(simCtx)
+	}
+
 	// 等待信号或错误
-	select {
-	case err := <-errChan:
-		log.Errorf("Agent error: %v", err)
-	case sig := <-sigChan:
-		log.Infof("Received signal: %v", sig)
+wait:
+	for {
+		select {
+		case err := <-errChan:
+			log.Errorf("Agent error: %v", err)
+			break wait
+		case sig := <-sigChan:
+			log.Infof("Received signal: %v", sig)
+			break wait
+		case <-restartChan:
+			log.Info("Received SIGUSR2, restarting agent without downtime")
+			if err := nodeAgent.Restart(); err != nil {
+				log.Errorf("Failed to restart agent: %v", err)
+			}
+		case <-nodeAgent.Done():
+			log.Info("Agent requested exit")
+			break wait
+		}
 	}
 
 	// 优雅关闭
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"utopia-node-agent/internal/handover"
+)
+
+// pid1ChildEnv 标记由PID 1模式的init进程启动的agent进程
+const pid1ChildEnv = "UTOPIA_AGENT_PID1_CHILD"
+
+// runAsPID1 作为容器的init进程运行：以相同参数启动agent子进程，
+// 将收到的信号转发给agent，回收所有被过继的孤儿进程，agent退出后以其退出码退出
+// agent自身的子进程（frpc、docker命令）仍由agent等待，init只回收孤儿，避免与exec.Cmd.Wait争抢
+// agent无中断重启时经管道告知新进程的PID，旧进程退出后改为监管新进程
+func runAsPID1() int {
+	executable, err := os.Executable()
+	if err != nil {
+		log.Errorf("Failed to locate agent executable: %v", err)
+		return 1
+	}
+
+	// 先订阅信号，避免子进程启动后、订阅前的信号丢失
+	signals := make(chan os.Signal, 32)
+	signal.Notify(signals)
+
+	handovers, handoverWriter, err := os.Pipe()
+	if err != nil {
+		log.Errorf("Failed to create handover pipe: %v", err)
+		return 1
+	}
+	defer handovers.Close()
+
+	child, err := os.StartProcess(executable, os.Args, &os.ProcAttr{
+		Env: append(os.Environ(), pid1ChildEnv+"=1",
+			fmt.Sprintf("%s=%d", handover.SupervisorFDEnv, 3)),
+		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr, handoverWriter},
+	})
+	handoverWriter.Close()
+	if err != nil {
+		log.Errorf("Failed to start agent: %v", err)
+		return 1
+	}
+	agentPID := child.Pid
+	log.Infof("Running as init process, agent PID %d", agentPID)
+
+	for sig := range signals {
+		switch sig {
+		case syscall.SIGCHLD:
+			status, exited := reapChildren(agentPID)
+			if !exited {
+				continue
+			}
+			// 旧agent在新进程就绪、写入其PID后才退出
+			if pid := handedOverPID(handovers); pid > 0 && syscall.Kill(pid, 0) == nil {
+				log.Infof("Agent handed over to PID %d", pid)
+				agentPID = pid
+				continue
+			}
+			return status
+		case syscall.SIGURG, syscall.SIGPIPE:
+			// SIGURG 由Go运行时用于抢占调度，SIGPIPE 与agent无关
+		default:
+			if err := syscall.Kill(agentPID, sig.(syscall.Signal)); err != nil {
+				log.Warnf("Failed to forward %v to agent: %v", sig, err)
+			}
+		}
+	}
+	return 1
+}
+
+// handedOverPID 读取管道中最近一次交接的新agent PID，没有时返回0
+func handedOverPID(handovers *os.File) int {
+	handovers.SetReadDeadline(time.Now())
+	data, _ := io.ReadAll(handovers)
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0
+	}
+	pid, _ := strconv.Atoi(fields[len(fields)-1])
+	return pid
+}
+
+// reapChildren 回收所有已退出的子进程，agent退出时返回其退出码
+// 被信号终止时按shell惯例返回 128+信号值
+func reapChildren(agentPID int) (status int, agentExited bool) {
+	for {
+		var ws syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &ws, syscall.WNOHANG, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || pid <= 0 {
+			return status, agentExited
+		}
+		if pid != agentPID {
+			log.Debugf("Reaped orphaned process %d", pid)
+			continue
+		}
+		agentExited = true
+		switch {
+		case ws.Exited():
+			status = ws.ExitStatus()
+		case ws.Signaled():
+			status = 128 + int(ws.Signal())
+			fmt.Fprintf(os.Stderr, "agent terminated by signal %v\n", ws.Signal())
+		default:
+			status = 1
+		}
+	}
+}
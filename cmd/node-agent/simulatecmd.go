@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/simulate"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// setupSimulation 处理 simulate 子命令的参数并准备模拟环境，之后agent照常启动
+func setupSimulation(cfg *config.Config, args []string) (*simulate.Simulator, error) {
+	defaults := simulate.DefaultOptions()
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	dir := fs.String("state-dir", filepath.Join(cfg.DataDir, "simulate"), "Directory for simulated containers, images and events")
+	gpus := fs.Int("gpus", defaults.GPUs, "Number of virtual GPUs")
+	model := fs.String("gpu-model", defaults.GPUModel, "Reported GPU model name")
+	memory := fs.Int("gpu-memory-mb", defaults.GPUMemoryMB, "Memory per virtual GPU in MB")
+	pull := fs.Duration("pull-delay", defaults.PullDelay, "Simulated image pull duration")
+	start := fs.Duration("start-delay", defaults.StartDelay, "Simulated container start duration")
+	failures := fs.Float64("failures-per-hour", defaults.FailuresPerHour, "Average OOM failures per running container per hour (0 disables)")
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("usage: node-agent [-config path] simulate [flags]: %w", err)
+	}
+
+	sim, err := simulate.Setup(simulate.Options{
+		Dir:             *dir,
+		GPUs:            *gpus,
+		GPUModel:        *model,
+		GPUMemoryMB:     *memory,
+		PullDelay:       *pull,
+		StartDelay:      *start,
+		FailuresPerHour: *failures,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Simulating %d x %s, state in %s", *gpus, *model, *dir)
+	return sim, nil
+}
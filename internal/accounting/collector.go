@@ -0,0 +1,447 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/gpu"
+	"utopia-node-agent/internal/store"
+)
+
+// 记录类型
+const (
+	EventStart = "start"
+	EventUsage = "usage"
+	EventStop  = "stop"
+)
+
+// StateHibernated 休眠周期的记录状态：容器已停止，按仅存储（及保留的GPU）计费
+const StateHibernated = "hibernated"
+
+// stateKey 未结算周期的状态键
+const stateKey = "state"
+
+// uploadBatchSize 单次上传的最大记录数
+const uploadBatchSize = 100
+
+// Record 单个claim在一个结算周期内的资源使用记录
+// ID由容器、类型与周期起点确定，重复上传时平台据此去重
+type Record struct {
+	ID          string `json:"id"`
+	NodeID      string `json:"node_id"`
+	ClaimID     string `json:"claim_id"`
+	ContainerID string `json:"container_id"`
+	Event       string `json:"event"`
+	PeriodStart int64  `json:"period_start"`
+	PeriodEnd   int64  `json:"period_end"`
+
+	GPUCount           int     `json:"gpu_count"`
+	GPUSeconds         float64 `json:"gpu_seconds"`
+	GPUMemoryMBSeconds float64 `json:"gpu_memory_mb_seconds"`
+	GPUMemoryPeakMB    int     `json:"gpu_memory_peak_mb"`
+	CPUSeconds         float64 `json:"cpu_seconds"`
+	NetworkRxBytes     uint64  `json:"network_rx_bytes"`
+	NetworkTxBytes     uint64  `json:"network_tx_bytes"`
+	DiskUsageBytes     int64   `json:"disk_usage_bytes"`
+
+	// 休眠周期为 hibernated，GPUCount为休眠期间保留的GPU数
+	State              string  `json:"state,omitempty"`
+	ReservedGPUSeconds float64 `json:"reserved_gpu_seconds,omitempty"`
+
+	// 时间片共享的claim占用的GPU份额，gpu_seconds 已按份额折算；独占GPU时为0
+	GPUFraction float64 `json:"gpu_fraction,omitempty"`
+
+	// 按claim进程统计的GPU平均与峰值利用率（百分比），周期内没有统计时为0
+	GPUUtilizationAvgPercent  float64 `json:"gpu_utilization_avg_percent,omitempty"`
+	GPUUtilizationPeakPercent float64 `json:"gpu_utilization_peak_percent,omitempty"`
+
+	// 生成记录时的节点标签
+	Labels map[string]string `json:"labels,omitempty"`
+	// claim创建时平台附加的元数据
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// UsageSource 容器资源使用采集接口
+type UsageSource interface {
+	CollectUsage(ctx context.Context) ([]container.ContainerUsage, error)
+}
+
+// GPUSource GPU状态接口
+type GPUSource interface {
+	GetGPUInfo() []gpu.GPUInfo
+}
+
+// UploadFunc 将记录上传到平台
+type UploadFunc func(ctx context.Context, records []Record) error
+
+// period 单个容器未结算的使用量
+type period struct {
+	ClaimID     string `json:"claim_id"`
+	ContainerID string `json:"container_id"`
+	GPUCount    int    `json:"gpu_count"`
+	Start       int64  `json:"start"`
+	LastSample  int64  `json:"last_sample"`
+
+	// 上次采样时的累计计数，用于计算增量
+	LastCPUNanos uint64 `json:"last_cpu_nanos"`
+	LastRxBytes  uint64 `json:"last_rx_bytes"`
+	LastTxBytes  uint64 `json:"last_tx_bytes"`
+	// 休眠周期
+	Hibernated bool `json:"hibernated,omitempty"`
+	// claim的平台元数据
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// 时间片共享时占用的GPU份额
+	GPUFraction float64 `json:"gpu_fraction,omitempty"`
+	// GPU利用率对时间的积分（百分比·秒）、有利用率统计的时长与峰值
+	GPUUtilPercentSeconds float64 `json:"gpu_util_percent_seconds,omitempty"`
+	GPUUtilSampledSeconds float64 `json:"gpu_util_sampled_seconds,omitempty"`
+	GPUUtilPeakPercent    float64 `json:"gpu_util_peak_percent,omitempty"`
+
+	GPUSeconds         float64 `json:"gpu_seconds"`
+	GPUMemoryMBSeconds float64 `json:"gpu_memory_mb_seconds"`
+	GPUMemoryPeakMB    int     `json:"gpu_memory_peak_mb"`
+	CPUSeconds         float64 `json:"cpu_seconds"`
+	RxBytes            uint64  `json:"rx_bytes"`
+	TxBytes            uint64  `json:"tx_bytes"`
+	DiskUsageBytes     int64   `json:"disk_usage_bytes"`
+	ReservedGPUSeconds float64 `json:"reserved_gpu_seconds,omitempty"`
+}
+
+// Collector 按容器采样资源使用并汇总为claim级别的计费记录
+// 未结算周期与待上传记录均持久化在本地，Agent重启后继续累计与上传
+type Collector struct {
+	mu sync.Mutex
+	// 节点以独立模式启动时在注册成功前为空
+	nodeID  func() string
+	usage   UsageSource
+	gpus    GPUSource
+	state   *store.Store
+	pending *store.Store
+	periods map[string]*period // containerID -> 未结算周期
+	labels  map[string]string
+}
+
+// NewCollector 创建计费采集器，数据保存在dir下
+func NewCollector(dir string, nodeID func() string, usage UsageSource, gpus GPUSource) (*Collector, error) {
+	state, err := store.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := store.Open(dir + "/pending")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Collector{
+		nodeID:  nodeID,
+		usage:   usage,
+		gpus:    gpus,
+		state:   state,
+		pending: pending,
+		periods: make(map[string]*period),
+	}
+	if _, err := state.Get(stateKey, &c.periods); err != nil {
+		return nil, fmt.Errorf("failed to load accounting state: %w", err)
+	}
+
+	return c, nil
+}
+
+// SetLabels 设置之后生成的记录携带的节点标签
+func (c *Collector) SetLabels(labels map[string]string) {
+	c.mu.Lock()
+	c.labels = labels
+	c.mu.Unlock()
+}
+
+// Sample 采样一次资源使用：新出现的容器生成start记录，消失或停止的容器生成stop记录
+func (c *Collector) Sample(ctx context.Context) error {
+	usages, err := c.usage.CollectUsage(ctx)
+	if err != nil {
+		return err
+	}
+
+	gpuMemory := make(map[int]int)
+	for _, info := range c.gpus.GetGPUInfo() {
+		gpuMemory[info.ID] = info.MemoryUsedMB
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now().Unix()
+	seen := make(map[string]bool)
+	var records []Record
+
+	for _, usage := range usages {
+		if !usage.Running && !usage.Hibernated {
+			continue
+		}
+		seen[usage.ID] = true
+
+		p, exists := c.periods[usage.ID]
+		// 进入或退出休眠时结算原周期，开始新的周期
+		if exists && p.Hibernated != usage.Hibernated {
+			records = append(records, c.settle(p, EventStop))
+			exists = false
+		}
+		if !exists {
+			p = &period{
+				ClaimID:      usage.ClaimID,
+				ContainerID:  usage.ID,
+				GPUCount:     len(usage.GPUIDs),
+				GPUFraction:  usage.GPUFraction,
+				Start:        now,
+				LastSample:   now,
+				LastCPUNanos: usage.CPUUsageNanos,
+				LastRxBytes:  usage.NetworkRxBytes,
+				LastTxBytes:  usage.NetworkTxBytes,
+				Hibernated:   usage.Hibernated,
+				Metadata:     usage.Metadata,
+			}
+			if usage.Hibernated {
+				p.GPUCount = usage.ReservedGPUs
+			}
+			c.periods[usage.ID] = p
+			records = append(records, c.marker(p, EventStart, now))
+		}
+
+		elapsed := float64(now - p.LastSample)
+		if p.Hibernated {
+			p.ReservedGPUSeconds += float64(p.GPUCount) * elapsed
+			p.DiskUsageBytes = usage.DiskUsageBytes
+			p.LastSample = now
+			continue
+		}
+		memoryMB := 0
+		for _, id := range usage.GPUIDs {
+			memoryMB += gpuMemory[id]
+		}
+
+		p.GPUSeconds += float64(p.GPUCount) * p.gpuWeight() * elapsed
+		p.GPUMemoryMBSeconds += float64(memoryMB) * elapsed
+		if memoryMB > p.GPUMemoryPeakMB {
+			p.GPUMemoryPeakMB = memoryMB
+		}
+		p.CPUSeconds += float64(counterDelta(p.LastCPUNanos, usage.CPUUsageNanos)) / float64(time.Second)
+		p.RxBytes += counterDelta(p.LastRxBytes, usage.NetworkRxBytes)
+		p.TxBytes += counterDelta(p.LastTxBytes, usage.NetworkTxBytes)
+		p.DiskUsageBytes = usage.DiskUsageBytes
+		if usage.GPUUtilizationPercent != nil {
+			p.recordGPUUtilization(*usage.GPUUtilizationPercent, elapsed)
+		}
+
+		p.LastSample = now
+		p.LastCPUNanos = usage.CPUUsageNanos
+		p.LastRxBytes = usage.NetworkRxBytes
+		p.LastTxBytes = usage.NetworkTxBytes
+	}
+
+	// 结算已停止的容器
+	for id, p := range c.periods {
+		if seen[id] {
+			continue
+		}
+		records = append(records, c.settle(p, EventStop))
+		delete(c.periods, id)
+	}
+
+	return c.commit(records)
+}
+
+// Flush 结算所有运行中容器的当前周期
+func (c *Collector) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var records []Record
+	for _, p := range c.periods {
+		if p.LastSample <= p.Start {
+			continue
+		}
+		records = append(records, c.settle(p, EventUsage))
+		c.resetPeriod(p)
+	}
+
+	return c.commit(records)
+}
+
+// Upload 按时间顺序上传待上传记录，上传成功后删除；失败的记录在下次重试
+func (c *Collector) Upload(ctx context.Context, upload UploadFunc) error {
+	records, err := c.pendingRecords()
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(records); start += uploadBatchSize {
+		end := start + uploadBatchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		batch := records[start:end]
+
+		if err := upload(ctx, batch); err != nil {
+			return fmt.Errorf("failed to upload %d usage record(s): %w", len(records)-start, err)
+		}
+		for _, record := range batch {
+			if err := c.pending.Delete(record.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// PendingCount 返回待上传记录数
+func (c *Collector) PendingCount() int {
+	keys, err := c.pending.Keys()
+	if err != nil {
+		return 0
+	}
+	return len(keys)
+}
+
+// pendingRecords 读取全部待上传记录，按周期结束时间排序
+func (c *Collector) pendingRecords() ([]Record, error) {
+	keys, err := c.pending.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, 0, len(keys))
+	for _, key := range keys {
+		var record Record
+		if found, err := c.pending.Get(key, &record); err != nil || !found {
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].PeriodEnd < records[j].PeriodEnd
+	})
+	return records, nil
+}
+
+// commit 先写入记录再保存状态（调用方需持有锁）
+// 两步之间崩溃时会以相同ID重新生成记录，由平台去重
+func (c *Collector) commit(records []Record) error {
+	for _, record := range records {
+		if err := c.pending.Put(record.ID, record); err != nil {
+			return fmt.Errorf("failed to persist usage record: %w", err)
+		}
+	}
+	if err := c.state.Put(stateKey, c.periods); err != nil {
+		return fmt.Errorf("failed to persist accounting state: %w", err)
+	}
+	return nil
+}
+
+// marker 生成不含用量的start记录
+func (c *Collector) marker(p *period, event string, at int64) Record {
+	return Record{
+		ID:          recordID(p.ContainerID, event, at),
+		NodeID:      c.nodeID(),
+		ClaimID:     p.ClaimID,
+		ContainerID: p.ContainerID,
+		Event:       event,
+		PeriodStart: at,
+		PeriodEnd:   at,
+		GPUCount:    p.GPUCount,
+		GPUFraction: p.GPUFraction,
+		State:       p.state(),
+		Labels:      c.labels,
+		Metadata:    p.Metadata,
+	}
+}
+
+// settle 将周期内的累计用量生成记录
+func (c *Collector) settle(p *period, event string) Record {
+	record := Record{
+		ID:                 recordID(p.ContainerID, event, p.Start),
+		NodeID:             c.nodeID(),
+		ClaimID:            p.ClaimID,
+		ContainerID:        p.ContainerID,
+		Event:              event,
+		PeriodStart:        p.Start,
+		PeriodEnd:          p.LastSample,
+		GPUCount:           p.GPUCount,
+		GPUSeconds:         p.GPUSeconds,
+		GPUMemoryMBSeconds: p.GPUMemoryMBSeconds,
+		GPUMemoryPeakMB:    p.GPUMemoryPeakMB,
+		CPUSeconds:         p.CPUSeconds,
+		NetworkRxBytes:     p.RxBytes,
+		NetworkTxBytes:     p.TxBytes,
+		DiskUsageBytes:     p.DiskUsageBytes,
+		State:              p.state(),
+		ReservedGPUSeconds: p.ReservedGPUSeconds,
+		GPUFraction:        p.GPUFraction,
+		Labels:             c.labels,
+		Metadata:           p.Metadata,
+	}
+	if p.GPUUtilSampledSeconds > 0 {
+		record.GPUUtilizationAvgPercent = p.GPUUtilPercentSeconds / p.GPUUtilSampledSeconds
+		record.GPUUtilizationPeakPercent = p.GPUUtilPeakPercent
+	}
+	return record
+}
+
+// resetPeriod 从上次采样时刻开始新的周期，保留累计计数基线
+func (c *Collector) resetPeriod(p *period) {
+	p.Start = p.LastSample
+	p.GPUSeconds = 0
+	p.GPUMemoryMBSeconds = 0
+	p.GPUMemoryPeakMB = 0
+	p.CPUSeconds = 0
+	p.RxBytes = 0
+	p.TxBytes = 0
+	p.ReservedGPUSeconds = 0
+	p.GPUUtilPercentSeconds = 0
+	p.GPUUtilSampledSeconds = 0
+	p.GPUUtilPeakPercent = 0
+}
+
+// state 返回周期记录的状态
+func (p *period) state() string {
+	if p.Hibernated {
+		return StateHibernated
+	}
+	return ""
+}
+
+// gpuWeight GPU时间的计费权重，时间片共享的claim按份额计
+func (p *period) gpuWeight() float64 {
+	if p.GPUFraction > 0 {
+		return p.GPUFraction
+	}
+	return 1
+}
+
+// recordGPUUtilization 累计一次采样间隔内的GPU利用率
+func (p *period) recordGPUUtilization(percent, elapsed float64) {
+	p.GPUUtilPercentSeconds += percent * elapsed
+	p.GPUUtilSampledSeconds += elapsed
+	if percent > p.GPUUtilPeakPercent {
+		p.GPUUtilPeakPercent = percent
+	}
+}
+
+// recordID 生成确定性的记录ID
+func recordID(containerID, event string, periodStart int64) string {
+	if len(containerID) > 12 {
+		containerID = containerID[:12]
+	}
+	return fmt.Sprintf("%s-%s-%d", containerID, event, periodStart)
+}
+
+// counterDelta 计算累计计数的增量，计数回绕（容器重启）时取当前值
+func counterDelta(last, current uint64) uint64 {
+	if current < last {
+		return current
+	}
+	return current - last
+}
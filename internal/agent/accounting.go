@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"utopia-node-agent/internal/accounting"
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/logsample"
+)
+
+// initializeAccounting 初始化计费采集器
+func (a *Agent) initializeAccounting() error {
+	collector, err := accounting.NewCollector(
+		filepath.Join(a.config.DataDir, "accounting"),
+		a.NodeID,
+		a.containerManager,
+		a.gpuMonitor,
+	)
+	if err != nil {
+		return err
+	}
+	collector.SetLabels(a.config.NodeLabels)
+	a.accounting = collector
+
+	fmt.Printf("Accounting enabled (%d pending record(s))\n", collector.PendingCount())
+	return nil
+}
+
+// accountingSampleTask 计费采样任务
+func (a *Agent) accountingSampleTask() {
+	a.runPeriodic("accounting_sample", func(c *config.Config) int { return c.Accounting.SampleIntervalSeconds }, func() error {
+		err := a.accounting.Sample(a.ctx)
+		if err != nil {
+			logsample.Printf("Failed to sample usage: %v\n", err)
+		}
+		return err
+	})
+}
+
+// accountingReportTask 计费结算与上传任务
+func (a *Agent) accountingReportTask() {
+	a.runPeriodic("accounting_report", func(c *config.Config) int { return c.Accounting.ReportIntervalSeconds }, func() error {
+		if err := a.accounting.Flush(); err != nil {
+			logsample.Printf("Failed to settle usage: %v\n", err)
+			return err
+		}
+		if err := a.accounting.Upload(a.ctx, a.uploadUsage); err != nil {
+			logsample.Printf("Failed to upload usage: %v\n", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// settleAccounting 立即采样并上传全部记录（节点退役时使用）
+func (a *Agent) settleAccounting(ctx context.Context) {
+	if a.accounting == nil {
+		return
+	}
+	if err := a.accounting.Sample(ctx); err != nil {
+		logsample.Printf("Failed to sample usage: %v\n", err)
+	}
+	if err := a.accounting.Upload(ctx, a.uploadUsage); err != nil {
+		logsample.Printf("Failed to upload usage: %v\n", err)
+	}
+}
+
+// uploadUsage 上传计费记录到平台
+func (a *Agent) uploadUsage(ctx context.Context, records []accounting.Record) error {
+	return a.regClient.ReportUsage(ctx, a.NodeID(), records)
+}
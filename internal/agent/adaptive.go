@@ -0,0 +1,172 @@
+package agent
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/gpu"
+)
+
+// 自适应采样的模式
+const (
+	samplingNormal = "normal"
+	// 节点高负载或GPU温度压力，间隔逐次加倍
+	samplingReduced = "reduced"
+	// 检测到异常，使用间隔下限
+	samplingBoosted = "boosted"
+)
+
+// maxSamplingFactor 降频倍数的上限，实际间隔另受 max_interval_seconds 限制
+const maxSamplingFactor = 64
+
+// adaptiveSampling 自适应采样状态，由GPU监控任务在每次采样后更新
+type adaptiveSampling struct {
+	mu     sync.Mutex
+	mode   string
+	reason string
+	// 降频时在配置间隔上的倍数
+	factor     int
+	boostUntil time.Time
+	// 上一次采样的结果，用于发现变化：是否失败、各GPU温度（按UUID）、GPU数量与被争用的GPU
+	failing      bool
+	temperatures map[string]int
+	gpuCount     int
+	contended    map[string]bool
+}
+
+// samplingInterval 返回按自适应采样调整后的间隔（秒），base为配置的间隔
+func (a *Agent) samplingInterval(cfg config.AdaptiveMonitorConfig, base int) int {
+	if !cfg.Enabled {
+		return base
+	}
+	s := &a.sampling
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch s.mode {
+	case samplingBoosted:
+		return min(base, cfg.MinIntervalSeconds)
+	case samplingReduced:
+		return max(base, min(base*s.factor, cfg.MaxIntervalSeconds))
+	default:
+		return base
+	}
+}
+
+// updateSampling 根据本次GPU采样结果与节点负载调整采样模式：异常优先，其次是负载与温度压力
+func (a *Agent) updateSampling(refreshErr error) {
+	cfg := a.currentConfig()
+	adaptive := cfg.Monitor.Adaptive
+	s := &a.sampling
+
+	var gpus []gpu.GPUInfo
+	if refreshErr == nil {
+		gpus = a.gpuMonitor.GetGPUInfo()
+	}
+	stress := ""
+	if adaptive.Enabled {
+		stress = a.samplingStress(adaptive, gpus)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	anomaly := s.observe(adaptive, gpus, refreshErr)
+	previous, previousFactor := s.mode, s.factor
+	switch {
+	case !adaptive.Enabled:
+		s.mode, s.reason, s.factor, s.boostUntil = samplingNormal, "", 1, time.Time{}
+	case anomaly != "":
+		s.mode, s.reason, s.factor = samplingBoosted, anomaly, 1
+		s.boostUntil = now.Add(time.Duration(adaptive.BoostSeconds) * time.Second)
+	case s.mode == samplingBoosted && now.Before(s.boostUntil):
+		// 异常后保持高频一段时间
+	case stress != "":
+		if s.mode == samplingReduced {
+			s.factor = min(s.factor*2, maxSamplingFactor)
+		} else {
+			s.factor = 2
+		}
+		s.mode, s.reason = samplingReduced, stress
+	default:
+		s.mode, s.reason, s.factor = samplingNormal, "", 1
+	}
+	mode, reason, factor := s.mode, s.reason, s.factor
+	s.mu.Unlock()
+
+	if mode == previous && factor == previousFactor {
+		return
+	}
+	interval := a.samplingInterval(adaptive, cfg.Monitor.GPUIntervalSeconds)
+	switch mode {
+	case samplingNormal:
+		fmt.Printf("Monitoring frequency restored (GPU sampling every %ds)\n", interval)
+	default:
+		fmt.Printf("Monitoring frequency %s (GPU sampling every %ds): %s\n", mode, interval, reason)
+	}
+	if a.apiServer != nil {
+		a.apiServer.SetMetricsMaxAge(a.metricsMaxAge(cfg))
+	}
+}
+
+// observe 与上一次采样比较，返回发现的异常（没有时为空字符串），并记录本次采样（调用方需持有锁）
+// 持续的故障只在开始时算作异常，避免驱动长时间异常时一直高频采样
+func (s *adaptiveSampling) observe(cfg config.AdaptiveMonitorConfig, gpus []gpu.GPUInfo, refreshErr error) string {
+	if refreshErr != nil {
+		failing := s.failing
+		s.failing = true
+		if !failing {
+			return fmt.Sprintf("GPU sampling failed: %v", refreshErr)
+		}
+		return ""
+	}
+
+	anomaly := ""
+	if s.failing {
+		anomaly = "GPU sampling recovered"
+	}
+	if s.gpuCount > 0 && len(gpus) != s.gpuCount && anomaly == "" {
+		anomaly = fmt.Sprintf("GPU count changed from %d to %d", s.gpuCount, len(gpus))
+	}
+	temperatures := make(map[string]int, len(gpus))
+	contended := make(map[string]bool)
+	for _, g := range gpus {
+		temperatures[g.UUID] = g.TemperatureC
+		if last, ok := s.temperatures[g.UUID]; ok && g.TemperatureC-last >= cfg.TemperatureJumpC && anomaly == "" {
+			anomaly = fmt.Sprintf("GPU %d temperature rose from %d°C to %d°C", g.ID, last, g.TemperatureC)
+		}
+		if g.Contended {
+			contended[g.UUID] = true
+			if !s.contended[g.UUID] && anomaly == "" {
+				anomaly = fmt.Sprintf("GPU %d is contended", g.ID)
+			}
+		}
+	}
+	s.failing, s.gpuCount, s.temperatures, s.contended = false, len(gpus), temperatures, contended
+	return anomaly
+}
+
+// samplingStress 返回节点高负载或GPU温度压力的原因，没有时为空字符串
+func (a *Agent) samplingStress(cfg config.AdaptiveMonitorConfig, gpus []gpu.GPUInfo) string {
+	for _, g := range gpus {
+		if g.TemperatureC >= cfg.TemperatureC || g.ThermalThrottled {
+			return fmt.Sprintf("GPU %d is at %d°C", g.ID, g.TemperatureC)
+		}
+	}
+	if len(gpus) > 0 {
+		total := 0.0
+		for _, g := range gpus {
+			total += g.UsagePercent
+		}
+		if average := total / float64(len(gpus)); average >= cfg.GPUUtilizationPercent {
+			return fmt.Sprintf("average GPU utilization is %.0f%%", average)
+		}
+	}
+	if sys, err := a.systemMonitor.GetSystemMetrics(); err == nil {
+		if load := sys.LoadAverage / float64(runtime.NumCPU()) * 100; load >= cfg.LoadPercent {
+			return fmt.Sprintf("load average is %.0f%% of %d CPUs", load, runtime.NumCPU())
+		}
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"fmt"
+
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/logsample"
+	"utopia-node-agent/internal/system"
+)
+
+// EventAddressChanged 节点主机名或IP地址变化
+const EventAddressChanged = "agent.address_changed"
+
+// addressMonitorTask 定期检测主机名与IP地址变化并通知平台
+func (a *Agent) addressMonitorTask() {
+	a.supervisor.Report("address_monitor", a.checkAddress())
+	a.runPeriodic("address_monitor", func(c *config.Config) int { return c.Monitor.AddressIntervalSeconds }, a.checkAddress)
+}
+
+// checkAddress 检测地址，与平台已知的地址不同时更新注册记录
+// 启动后的首次检测总会上报一次；上报失败时保留旧地址，下次检测重试
+func (a *Agent) checkAddress() error {
+	cfg := a.currentConfig()
+	addr, err := system.DetectAddress(a.ctx, cfg.CentralPlatform.APIURL, cfg.Monitor.PublicIPURL)
+	if err != nil {
+		logsample.Printf("Warning: failed to detect node address: %v\n", err)
+		return err
+	}
+
+	a.mu.RLock()
+	previous := a.nodeAddress
+	nodeID := a.nodeID
+	a.mu.RUnlock()
+	if previous != nil && *previous == addr {
+		return nil
+	}
+
+	if err := a.regClient.UpdateAddress(a.ctx, nodeID, addr); err != nil {
+		logsample.Printf("Warning: failed to report node address: %v\n", err)
+		return err
+	}
+
+	a.mu.Lock()
+	a.nodeAddress = &addr
+	a.mu.Unlock()
+
+	if previous == nil {
+		fmt.Printf("Reported node address: hostname=%s primary_ip=%s\n", addr.Hostname, addr.PrimaryIP)
+		return nil
+	}
+
+	fmt.Printf("Node address changed: hostname %s -> %s, primary_ip %s -> %s, public_ip %s -> %s\n",
+		previous.Hostname, addr.Hostname, previous.PrimaryIP, addr.PrimaryIP, previous.PublicIP, addr.PublicIP)
+	a.eventBus.Publish(events.Event{
+		Type:     EventAddressChanged,
+		Severity: events.SeverityWarning,
+		Message:  "node hostname or IP address changed",
+		Data: map[string]interface{}{
+			"previous": previous,
+			"current":  addr,
+		},
+	})
+
+	// 本机地址变化后frpc到服务端的连接已失效，立即重建隧道
+	if previous.PrimaryIP != addr.PrimaryIP {
+		a.restartFRPWithCurrentConfig()
+	}
+	return nil
+}
+
+// NodeAddress 返回最近一次上报给平台的节点地址
+func (a *Agent) NodeAddress() *system.NodeAddress {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.nodeAddress
+}
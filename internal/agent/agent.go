@@ -4,20 +4,50 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"utopia-node-agent/internal/accounting"
 	"utopia-node-agent/internal/api"
+	"utopia-node-agent/internal/breakglass"
+	"utopia-node-agent/internal/claimdns"
 	"utopia-node-agent/internal/config"
 	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/dataset"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/features"
 	"utopia-node-agent/internal/frp"
 	"utopia-node-agent/internal/gpu"
+	"utopia-node-agent/internal/handover"
+	"utopia-node-agent/internal/hooks"
+	"utopia-node-agent/internal/idempotency"
+	"utopia-node-agent/internal/identity"
+	"utopia-node-agent/internal/jobs"
+	"utopia-node-agent/internal/lifecycle"
+	"utopia-node-agent/internal/logsample"
+	"utopia-node-agent/internal/logship"
+	"utopia-node-agent/internal/metrics"
+	"utopia-node-agent/internal/mounts"
+	"utopia-node-agent/internal/quota"
+	"utopia-node-agent/internal/redact"
 	"utopia-node-agent/internal/registration"
+	"utopia-node-agent/internal/secrets"
+	"utopia-node-agent/internal/security"
+	"utopia-node-agent/internal/signing"
+	"utopia-node-agent/internal/supervisor"
 	"utopia-node-agent/internal/system"
+	"utopia-node-agent/internal/tracing"
+	"utopia-node-agent/internal/watch"
 )
 
+// Version Agent版本号，由main在启动时设置
+var Version = "dev"
+
 // Agent 节点代理
 type Agent struct {
 	config           *config.Config
@@ -27,14 +57,119 @@ type Agent struct {
 	systemMonitor    *system.Monitor
 	frpManager       *frp.Manager
 	apiServer        *api.Server
+	jobManager       *jobs.Manager
+	regClient        *registration.Client
+	identity         *identity.Identity
 	ctx              context.Context
 	cancel           context.CancelFunc
 	wg               sync.WaitGroup
 	mu               sync.RWMutex
+	done             chan struct{}
+	doneOnce         sync.Once
+	// 全部子系统已启动
+	started bool
+
+	// 节点退役状态；退役关闭的隧道在注销失败等待重试期间不再重启
+	draining        bool
+	decommissioning bool
+	tunnelsClosed   bool
+
+	// 以独立模式启动时的注册状态（启动时已有身份的节点为nil）
+	registrationStatus *registration.Status
+
+	// 链路追踪关闭函数
+	shutdownTracing func(context.Context) error
+
+	// 分层配置，平台补丁不覆盖环境变量与命令行参数设置的配置项
+	layers *config.Layers
+
+	// 平台下发配置的应用状态
+	overrides             *config.Overrides
+	rejectedConfigVersion int64
+	configError           string
+
+	// 电源管理状态
+	bootInfo     *system.BootInfo
+	powerPending bool
+
+	// 计划停机，及停止时是否已通知平台下线（之后不再发送心跳）
+	plannedDowntime *api.PlannedDowntime
+	offlineNotified bool
+
+	// 节点事件总线与已通过心跳上报的事件序号
+	eventBus    *events.Bus
+	eventCursor int64
+
+	// 节点加密密钥对（未启用密钥下发时为nil）
+	secretsKeypair *secrets.Keypair
+
+	// 计费采集器（未启用时为nil）
+	accounting *accounting.Collector
+
+	// 租户容器强制访问控制的生效情况
+	securityStatus security.Status
+
+	// 被监视的凭据文件加载状态
+	credentialFiles map[string]*watch.FileStatus
+
+	// 最近一次上报给平台的节点地址
+	nodeAddress *system.NodeAddress
+
+	// 指标输出管道（未配置输出时为nil）
+	metricsPipeline *metrics.Pipeline
+
+	// 容器日志转发器（未启用时为nil）
+	logForwarder *logship.Forwarder
+	// claim DNS名称登记（未启用时为nil）
+	claimDNS *claimdns.Registrar
+
+	// 运行时功能开关
+	featureFlags *features.Flags
+
+	// 争用检测已上报的占用者（仅由争用检测任务访问）
+	reportedIntruders map[intruderKey]gpu.Intruder
+
+	// 各GPU的温控状态（仅由温控任务及其停止后的还原访问）
+	thermalStates map[int]*thermalState
+
+	// GPU驱动维护任务是否正在执行
+	gpuMaintenance bool
+	// 节点自检是否正在执行
+	selfTesting bool
+	// 自适应采样状态
+	sampling adaptiveSampling
+
+	// 请求立即发送一次心跳（不等待下一个周期）
+	heartbeatWake chan struct{}
+
+	// 后台任务监管器
+	supervisor *supervisor.Supervisor
+
+	// 子系统的有序启动与停止
+	lifecycle *lifecycle.Manager
+	// API服务器停止接受请求后关闭
+	apiDone chan struct{}
+
+	// claim发布的端口（claimID -> 端口，未启用时为nil），tunnelMu串行化frpc配置更新
+	publishedPorts map[string][]container.PublishedPort
+	tunnelMu       sync.Mutex
+
+	// 平台验证公钥缓存（未配置平台签名公钥时为nil）
+	signingKeys *signing.KeyCache
+	// 敏感值遮盖（未启用时为nil）
+	redactor *redact.Redactor
+
+	// 旧agent进程交接的状态（不是由交接启动时为nil）及本进程是否已就绪
+	inherited     *handover.Inherited
+	handoverReady bool
+	// 无中断重启是否进行中，及是否已交给新进程
+	restarting bool
+	handedOver bool
 }
 
-// New 创建新的代理实例
-func New(cfg *config.Config) (*Agent, error) {
+// New 创建新的代理实例，layers 中已包含持久化的平台配置覆盖
+func New(layers *config.Layers) (*Agent, error) {
+	cfg := layers.Config()
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
@@ -42,128 +177,123 @@ func New(cfg *config.Config) (*Agent, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	agent := &Agent{
-		config: cfg,
-		ctx:    ctx,
-		cancel: cancel,
+		config:    cfg,
+		layers:    layers,
+		overrides: layers.Overrides(),
+		regClient: registration.NewClient(cfg.CentralPlatform.APIURL),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		eventBus:  events.NewBus(1000),
+
+		heartbeatWake:   make(chan struct{}, 1),
+		credentialFiles: make(map[string]*watch.FileStatus),
 	}
+	agent.supervisor = supervisor.New(ctx, &agent.wg)
+	agent.lifecycle = lifecycle.New()
+	agent.registerStages()
 
-	return agent, nil
-}
-
-// Start 启动代理
-func (a *Agent) Start() error {
-	// 1. 启动与注册工作流
-	if err := a.bootstrap(); err != nil {
-		return fmt.Errorf("bootstrap failed: %w", err)
+	if agent.overrides != nil {
+		fmt.Printf("Loaded config overrides version %d\n", agent.overrides.Version)
 	}
+	applyLogLevel(agent.config.LogLevel)
+	applyLogSampling(agent.config.LogSampling)
 
-	// 2. 初始化监控器
-	if err := a.initializeMonitors(); err != nil {
-		return fmt.Errorf("failed to initialize monitors: %w", err)
+	redactor, err := newRedactor(cfg.Redaction)
+	if err != nil {
+		cancel()
+		return nil, err
 	}
+	agent.redactor = redactor
 
-	// 3. 初始化容器管理器
-	if err := a.initializeContainerManager(); err != nil {
-		return fmt.Errorf("failed to initialize container manager: %w", err)
+	// 从令牌文件加载认证令牌
+	if err := agent.loadTokenFiles(); err != nil {
+		cancel()
+		return nil, err
 	}
 
-	// 4. 启动FRP管理器
-	if err := a.startFRP(); err != nil {
-		return fmt.Errorf("failed to start FRP: %w", err)
+	// 由旧agent进程无中断重启时接收其交接的状态
+	if err := agent.inheritHandover(); err != nil {
+		cancel()
+		return nil, err
 	}
 
-	// 5. 启动API服务器
-	if err := a.startAPIServer(); err != nil {
-		return fmt.Errorf("failed to start API server: %w", err)
-	}
+	return agent, nil
+}
 
-	// 6. 启动后台任务
-	a.startBackgroundTasks()
+// currentConfig 获取当前生效的配置
+func (a *Agent) currentConfig() *config.Config {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.config
+}
 
+// Start 记录启动原因后按依赖顺序启动各子系统
+func (a *Agent) Start() error {
+	a.detectBoot()
+	if err := a.lifecycle.Start(a.ctx); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.started = true
+	a.mu.Unlock()
+	a.finishHandover()
 	return nil
 }
 
-// Stop 停止代理
+// Stop 按启动的相反顺序停止各子系统，每个子系统的停止有独立的时限
 func (a *Agent) Stop() error {
 	fmt.Println("Stopping Utopia Node Agent...")
+	a.notifyOffline()
 
-	// 取消上下文
-	a.cancel()
-
-	// 等待所有goroutine完成，但设置超时
-	done := make(chan struct{})
-	go func() {
-		a.wg.Wait()
-		close(done)
-	}()
-
-	select {
-	case <-done:
-		fmt.Println("All goroutines stopped gracefully")
-	case <-time.After(15 * time.Second):
-		fmt.Println("Warning: Timeout waiting for goroutines to stop")
-	}
-
-	// 停止API服务器
-	if a.apiServer != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := a.apiServer.Stop(ctx); err != nil {
-			fmt.Printf("Error stopping API server: %v\n", err)
-		} else {
-			fmt.Println("API server stopped")
-		}
-	}
-
-	// 停止FRP
-	if a.frpManager != nil {
-		if err := a.frpManager.Stop(); err != nil {
-			fmt.Printf("Error stopping FRP: %v\n", err)
-		} else {
-			fmt.Println("FRP stopped")
-		}
-		if err := a.frpManager.CleanupConfig(); err != nil {
-			fmt.Printf("Error cleaning up FRP config: %v\n", err)
-		}
+	if err := a.lifecycle.Stop(); err != nil {
+		fmt.Printf("Warning: some subsystems did not stop cleanly: %v\n", err)
 	}
+	// 启动未完成时后台任务子系统未启动，仍需取消上下文
+	a.cancel()
 
-	// 关闭监控器
-	if a.gpuMonitor != nil {
-		if err := a.gpuMonitor.Close(); err != nil {
-			fmt.Printf("Error closing GPU monitor: %v\n", err)
-		} else {
-			fmt.Println("GPU monitor closed")
-		}
+	// 记录正常退出，用于下次启动判断启动原因（已交给新进程时agent并未退出）
+	if a.passiveStop() {
+		fmt.Println("Utopia Node Agent stopped")
+		return nil
 	}
-
-	// 关闭容器管理器
-	if a.containerManager != nil {
-		if err := a.containerManager.Close(); err != nil {
-			fmt.Printf("Error closing container manager: %v\n", err)
-		} else {
-			fmt.Println("Container manager closed")
-		}
+	if err := system.MarkCleanShutdown(a.config.DataDir); err != nil {
+		fmt.Printf("Error recording clean shutdown: %v\n", err)
 	}
 
 	fmt.Println("Utopia Node Agent stopped")
 	return nil
 }
 
-// bootstrap 启动与注册工作流
-func (a *Agent) bootstrap() error {
+// bootstrap 启动与注册工作流，ctx取消时放弃注册
+func (a *Agent) bootstrap(ctx context.Context) error {
 	// 1. 检查本地身份
-	log.Printf("Checking for existing node ID at %s...", a.config.IdentityFilePath)
-	nodeID, err := registration.LoadNodeID(a.config.IdentityFilePath)
+	log.Printf("Checking for existing node identity at %s...", a.config.IdentityFilePath)
+	id, err := a.loadIdentity()
 	if err != nil {
-		return fmt.Errorf("failed to load node ID: %w", err)
+		return fmt.Errorf("failed to load node identity: %w", err)
 	}
 
-	if nodeID != "" {
-		a.nodeID = nodeID
-		fmt.Printf("Loaded existing node ID: %s\n", nodeID)
+	if id != nil && id.NodeID != "" {
+		a.setIdentity(id)
+		fmt.Printf("Loaded existing node ID: %s (identity public key: %s)\n", id.NodeID, id.PublicKey())
 		return nil
 	}
 
+	// 平台不可达时以独立模式继续启动，注册在后台重试
+	retry, err := a.register(ctx)
+	if err == nil {
+		return nil
+	}
+	if !retry || !a.config.CentralPlatform.StandaloneOnUnreachable || ctx.Err() != nil {
+		return err
+	}
+	a.enterStandalone(err)
+	return nil
+}
+
+// register 向平台注册并保存身份，返回的retry表示失败原因是平台暂时不可达
+func (a *Agent) register(ctx context.Context) (retry bool, err error) {
 	// // 2. 获取机器ID
 	// machineID, err := registration.GetMachineID()
 	// if err != nil {
@@ -173,25 +303,68 @@ func (a *Agent) bootstrap() error {
 
 	hostName, err := registration.GetHostname()
 	if err != nil {
-		return fmt.Errorf("failed to get hostname: %w", err)
+		return false, fmt.Errorf("failed to get hostname: %w", err)
 	}
 	fmt.Printf("Hostname: %s\n", hostName)
 
-	// 3. 向平台注册
-	regClient := registration.NewClient(a.config.CentralPlatform.APIURL)
-	regResp, err := regClient.Register(a.config.CentralPlatform.BootstrapToken, hostName)
+	// 3. 获取引导令牌并向平台注册（不输出令牌内容）
+	// 令牌可能来自实例元数据服务，获取失败时同样可以重试
+	cfg := a.currentConfig()
+	token, err := registration.ResolveBootstrapToken(ctx, cfg.CentralPlatform)
+	if err != nil {
+		return true, fmt.Errorf("failed to obtain bootstrap token: %w", err)
+	}
+	fmt.Printf("Registering with %s\n", token)
+
+	// 生成身份密钥对，注册请求携带公钥并以私钥签名
+	id, err := identity.Generate()
+	if err != nil {
+		return false, err
+	}
+	a.regClient.SetIdentity(id)
+	regResp, err := a.regClient.Register(ctx, token, hostName, a.encryptionPublicKey())
 	if err != nil {
-		return fmt.Errorf("failed to register with platform: %w", err)
+		return !registration.IsRejected(err), fmt.Errorf("failed to register with platform: %s", token.Redact(err.Error()))
 	}
 
 	// 4. 持久化身份
-	if err := registration.SaveNodeID(a.config.IdentityFilePath, regResp.NodeID); err != nil {
-		return fmt.Errorf("failed to save node ID: %w", err)
+	id.NodeID = strconv.FormatInt(regResp.NodeID, 10)
+	id.Certificate = regResp.IdentityCertificate
+	id.RegisteredAt = time.Now().Unix()
+	if err := a.saveIdentity(id); err != nil {
+		return false, fmt.Errorf("failed to save node identity: %w", err)
 	}
 
-	a.nodeID = strconv.FormatInt(regResp.NodeID, 10)
+	a.setIdentity(id)
 	fmt.Printf("Successfully registered as node: %d\n", regResp.NodeID)
 
+	// 5. 作废一次性令牌，之后重启使用已保存的身份
+	if err := token.Consume(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	return false, nil
+}
+
+// initializeTracing 初始化链路追踪
+func (a *Agent) initializeTracing() error {
+	cfg := a.config.Tracing
+	shutdown, err := tracing.Init(a.ctx, tracing.Config{
+		Enabled:     cfg.Enabled,
+		Endpoint:    cfg.OTLPEndpoint,
+		Insecure:    cfg.Insecure,
+		SampleRatio: cfg.SampleRatio,
+		NodeID:      a.nodeID,
+		Version:     Version,
+	})
+	if err != nil {
+		return err
+	}
+	a.shutdownTracing = shutdown
+
+	if cfg.Enabled {
+		fmt.Printf("Tracing enabled (OTLP endpoint: %s)\n", cfg.OTLPEndpoint)
+	}
 	return nil
 }
 
@@ -200,12 +373,26 @@ func (a *Agent) initializeMonitors() error {
 	// 初始化GPU监控器
 	gpuMonitor, err := gpu.NewMonitor()
 	if err != nil {
-		return fmt.Errorf("failed to create GPU monitor: %w", err)
+		// 驱动安装中或仅CPU节点：以降级模式运行，禁用GPU功能
+		fmt.Printf("Warning: GPU support disabled, running in CPU-only mode: %v\n", err)
+		gpuMonitor = gpu.NewUnavailableMonitor(err.Error())
 	}
 	a.gpuMonitor = gpuMonitor
+	a.gpuMonitor.SetBusyPolicy(a.gpuBusyPolicy())
 
 	// 初始化系统监控器
 	a.systemMonitor = system.NewMonitor()
+	if hostCfg := a.config.Monitor.HostMetrics; hostCfg.Enabled {
+		a.systemMonitor.EnableHostMetrics(system.HostMetricsOptions{
+			Devices:    hostCfg.Devices,
+			Interfaces: hostCfg.Interfaces,
+		})
+	}
+
+	// 采集组件版本
+	versions := a.systemMonitor.GetVersions(true)
+	fmt.Printf("Versions: kernel=%s driver=%s docker=%s containerd=%s runc=%s nvidia-container-toolkit=%s\n",
+		versions.Kernel, versions.NvidiaDriver, versions.Docker, versions.Containerd, versions.Runc, versions.NvidiaContainerToolkit)
 
 	// 刷新一次GPU信息
 	if err := a.gpuMonitor.RefreshGPUInfo(); err != nil {
@@ -224,24 +411,189 @@ func (a *Agent) initializeMonitors() error {
 
 // initializeContainerManager 初始化容器管理器
 func (a *Agent) initializeContainerManager() error {
-	containerManager, err := container.NewManager(a.gpuMonitor)
+	// 加载租户容器的安全配置
+	if err := a.setupSecurity(); err != nil {
+		return err
+	}
+
+	// 运行时功能开关，管理端点设置的覆盖持久化在数据目录中
+	flags, err := features.Open(filepath.Join(a.config.DataDir, "feature_flags"))
+	if err != nil {
+		return fmt.Errorf("failed to load feature flags: %w", err)
+	}
+	flags.SetConfig(a.config.FeatureFlags)
+	a.featureFlags = flags
+
+	// 检查主机端口划分与节点自身使用的端口
+	if err := a.validatePortPlan(); err != nil {
+		return err
+	}
+
+	containerManager, err := container.NewManager(a.gpuMonitor, a.containerOptions())
 	if err != nil {
 		return fmt.Errorf("failed to create container manager: %w", err)
 	}
 	a.containerManager = containerManager
+	a.containerManager.SetEventBus(a.eventBus)
+	a.containerManager.SetRedactor(a.redactor)
+	// 涉及claim的事件附加平台在创建时传入的元数据，并遮盖其中的敏感值
+	a.eventBus.SetEnricher(func(event *events.Event) {
+		if event.ClaimMetadata == nil && (event.ClaimID != "" || event.ContainerID != "") {
+			event.ClaimMetadata = containerManager.ClaimMetadata(event.ClaimID, event.ContainerID)
+		}
+		a.redactEvent(event)
+	})
+	a.containerManager.SetFeatureFlags(flags)
+	a.gpuMonitor.SetManagedChecker(a.containerManager.IsGPUInUse)
+	a.gpuMonitor.SetDeviceChangeHandler(a.handleGPUDeviceChange)
+	if a.config.GPUCleanup.Enabled && a.gpuMonitor.Available() {
+		a.containerManager.SetGPUCleaner(a)
+	}
+	if a.secretsKeypair != nil {
+		a.containerManager.SetSecrets(a.secretsKeypair, a.config.Secrets.RuntimeDir)
+	}
+	if cfg := a.config.ClaimDNS; cfg.Enabled {
+		a.claimDNS = claimdns.NewRegistrar(cfg.Domain, a.config.ClaimDNSHostsFile(), cfg.ReloadPIDFile)
+		a.containerManager.SetClaimDNS(a.claimDNS)
+		fmt.Printf("Claim DNS registration enabled: *.%s in %s\n", cfg.Domain, a.config.ClaimDNSHostsFile())
+	}
+
+	// 启用共享数据集缓存
+	if a.config.DatasetCache.Enabled {
+		dir := a.config.DatasetCacheDir()
+		cache, err := dataset.NewCache(dir, int64(a.config.DatasetCache.BudgetGB)<<30)
+		if err != nil {
+			return fmt.Errorf("failed to open dataset cache: %w", err)
+		}
+		cache.SetRsyncHosts(a.config.DatasetCache.RsyncHosts)
+		a.containerManager.SetDatasetCache(cache)
+		fmt.Printf("Dataset cache enabled at %s (budget: %d GB)\n", dir, a.config.DatasetCache.BudgetGB)
+	}
+
+	// 启用外部存储卷
+	if cfg := a.config.ExternalVolumes; cfg.Enabled {
+		mounter, err := mounts.NewManager(a.config.ExternalVolumesDir(), mounts.Options{
+			Types:    cfg.Types,
+			S3Driver: cfg.S3Driver,
+			Timeout:  time.Duration(cfg.MountTimeoutSeconds) * time.Second,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to enable external volumes: %w", err)
+		}
+		a.containerManager.SetExternalVolumes(mounter)
+		fmt.Printf("External volumes enabled at %s (types: %s)\n", a.config.ExternalVolumesDir(), strings.Join(mounter.Types(), ", "))
+	}
+
+	// 启用MPS共享计算
+	if a.config.MPS.Enabled {
+		if !a.gpuMonitor.Available() {
+			fmt.Println("Warning: MPS shared compute disabled: GPU support is unavailable")
+		} else {
+			mps, err := gpu.NewMPSManager(a.config.MPS.Dir)
+			if err != nil {
+				return fmt.Errorf("failed to initialize MPS: %w", err)
+			}
+			a.containerManager.SetMPS(mps)
+			fmt.Printf("MPS shared compute enabled (max %d clients per GPU)\n", a.config.MPS.MaxClientsPerGPU)
+		}
+	}
+
+	// 启用镜像懒加载拉取，未生效时镜像照常完整拉取
+	if cfg := a.config.LazyPull; cfg.Enabled {
+		status, err := a.containerManager.EnableLazyPull(a.ctx, container.LazyPullOptions{
+			Snapshotter:         cfg.Snapshotter,
+			Address:             cfg.Address,
+			ContainerdConfigDir: cfg.ContainerdConfigDir,
+		})
+		switch {
+		case err != nil:
+			fmt.Printf("Warning: failed to enable lazy pulling: %v\n", err)
+		case status.Active:
+			fmt.Printf("Lazy pulling enabled via %s snapshotter\n", status.Snapshotter)
+		case status.RestartRequired:
+			fmt.Printf("Warning: lazy pulling configured in %s; restart containerd and docker to activate (%s)\n", status.ConfigFile, status.Reason)
+		default:
+			fmt.Printf("Warning: lazy pulling is not active: %s\n", status.Reason)
+		}
+	}
+
+	// 启用核心转储收集，无法按容器收集时仍限制转储大小
+	if cfg := a.config.Container.CoreDumps; cfg.Enabled {
+		status, err := a.containerManager.EnableCoreDumps(container.CoreDumpOptions{
+			Dir:           a.config.CoreDumpsDir(),
+			CorePattern:   cfg.CorePattern,
+			MaxSizeBytes:  int64(cfg.MaxSizeMB) << 20,
+			MaxTotalBytes: int64(cfg.MaxTotalMB) << 20,
+		})
+		switch {
+		case err != nil:
+			fmt.Printf("Warning: failed to enable core dump collection: %v\n", err)
+		case status.Collecting:
+			fmt.Printf("Core dump collection enabled: %s in containers, stored in %s\n", status.ContainerDir, a.config.CoreDumpsDir())
+		default:
+			fmt.Printf("Warning: core dumps limited to %d MB but not collected: %s\n", cfg.MaxSizeMB, status.Reason)
+		}
+	}
+
+	// 配置生命周期钩子
+	if len(a.config.Hooks) > 0 {
+		lifecycleHooks := make([]hooks.Hook, len(a.config.Hooks))
+		for i, h := range a.config.Hooks {
+			lifecycleHooks[i] = h.Hook()
+		}
+		a.containerManager.SetHooks(hooks.NewRunner(lifecycleHooks))
+		fmt.Printf("Container lifecycle hooks enabled (%d configured)\n", len(lifecycleHooks))
+	}
+
+	// 启用操作意图日志
+	if err := a.containerManager.EnableJournal(filepath.Join(a.config.DataDir, "journal")); err != nil {
+		return fmt.Errorf("failed to open operation journal: %w", err)
+	}
+
+	// 启用claim休眠
+	if err := a.containerManager.EnableHibernation(filepath.Join(a.config.DataDir, "hibernation")); err != nil {
+		return fmt.Errorf("failed to enable claim hibernation: %w", err)
+	}
+
+	// 启用定时启动的claim
+	if a.config.Schedule.Enabled {
+		if err := a.containerManager.EnableSchedules(filepath.Join(a.config.DataDir, "schedules")); err != nil {
+			return fmt.Errorf("failed to enable scheduled claims: %w", err)
+		}
+	}
 
 	// 刷新现有容器
 	if err := a.containerManager.RefreshContainers(a.ctx); err != nil {
 		fmt.Printf("Warning: failed to refresh existing containers: %v\n", err)
 	}
 
+	// 停止上次运行遗留且已无容器使用的MPS守护进程，卸载已无容器使用的外部存储卷
+	a.containerManager.ReleaseMPS(a.ctx)
+	a.containerManager.ReleaseOrphanedVolumes()
+
 	return nil
 }
 
 // startFRP 启动FRP管理器
 func (a *Agent) startFRP() error {
+	// 隧道端口按节点ID划分，以独立模式运行时在注册成功后启动
+	if a.nodeID == "" {
+		fmt.Println("FRP will start after the node registers with the platform")
+		return nil
+	}
+
+	// 恢复claim已发布的端口
+	if a.config.FRP.ClaimPortRangeStart > 0 {
+		a.enablePortPublishing()
+	}
+
 	// 生成FRP配置
-	frpConfig := a.generateFRPConfig()
+	return a.launchFRP(a.generateFRPConfig())
+}
+
+// launchFRP 创建FRP管理器并启动frpc，启用端口发布时注册为容器管理器的端口发布器
+func (a *Agent) launchFRP(frpConfig *frp.Config) error {
+	cfg := a.currentConfig()
 
 	// 创建FRP管理器
 	frpManager, err := frp.NewManager(frpConfig)
@@ -249,25 +601,41 @@ func (a *Agent) startFRP() error {
 		return fmt.Errorf("failed to create FRP manager: %w", err)
 	}
 	a.frpManager = frpManager
-
-	// 启动FRP
-	if err := a.frpManager.Start(a.ctx); err != nil {
+	a.frpManager.SetStartTimeout(time.Duration(cfg.FRP.StartTimeoutSeconds) * time.Second)
+	a.frpManager.SetHandover(cfg.Handover.Enabled)
+	a.frpManager.SetDownload(frp.DownloadConfig{
+		Enabled:    cfg.FRP.Download.Enabled,
+		Version:    cfg.FRP.Download.Version,
+		MirrorURL:  cfg.FRP.Download.MirrorURL,
+		InstallDir: cfg.FRP.Download.InstallDir,
+		SHA256:     cfg.FRP.Download.SHA256,
+	})
+
+	// 启动FRP，由交接启动时接管旧进程的frpc
+	if err := a.startOrAdoptFRP(); err != nil {
 		return fmt.Errorf("failed to start FRP: %w", err)
 	}
 
 	fmt.Printf("FRP started (PID: %d)\n", a.frpManager.GetPID())
 
+	if a.publishedPorts != nil {
+		a.containerManager.SetPortPublisher(a)
+		fmt.Printf("Claim port publishing enabled (%d ports per node)\n", cfg.FRP.ClaimPortsPerNode)
+	}
+
 	return nil
 }
 
-// generateFRPConfig 生成FRP配置
+// generateFRPConfig 生成FRP配置（调用方需持有锁，启动阶段除外）
 func (a *Agent) generateFRPConfig() *frp.Config {
-	// 解析Agent API端口
+	// 解析Agent API地址，frpc通过回环地址访问通配监听地址
 	apiPort := 9200
-	if portStr := getPortFromAddress(a.config.AgentAPI.ListenAddress); portStr != "" {
-		if port, err := strconv.Atoi(portStr); err == nil {
+	apiLocalIP := "127.0.0.1"
+	if host, portStr, err := net.SplitHostPort(a.config.AgentAPI.ListenAddress); err == nil {
+		if port, err := config.ParsePort(portStr); err == nil {
 			apiPort = port
 		}
+		apiLocalIP = config.LoopbackFor(host)
 	}
 
 	// 计算端口偏移
@@ -281,8 +649,9 @@ func (a *Agent) generateFRPConfig() *frp.Config {
 	// 控制隧道端口
 	controlRemotePort := basePort + 0 // service_offset = 0
 
-	// 生成GPU隧道配置
+	// 生成GPU隧道配置，本地端口按端口划分取各GPU的web与ssh端口
 	gpuCount, _ := a.gpuMonitor.GetGPUCount()
+	plan := a.portPlan()
 	var gpuTunnels []frp.GPUTunnel
 
 	for i := 0; i < gpuCount; i++ {
@@ -293,26 +662,87 @@ func (a *Agent) generateFRPConfig() *frp.Config {
 
 		gpuTunnels = append(gpuTunnels, frp.GPUTunnel{
 			ID:            i,
-			WebLocalPort:  8000 + i*10,
-			SshLocalPort:  8000 + i*10 + 1,
+			WebLocalPort:  plan.WebPort(i),
+			SshLocalPort:  plan.SSHPort(i),
 			WebRemotePort: basePort + webOffset,
 			SshRemotePort: basePort + sshOffset,
 		})
 	}
 
 	return &frp.Config{
-		ServerAddr:        a.config.FRP.ServerAddr,
+		ServerAddr:        config.NormalizeHost(a.config.FRP.ServerAddr),
 		ServerPort:        a.config.FRP.ServerPort,
+		FallbackServers:   a.frpFallbackServers(),
 		FrpToken:          a.config.FRP.Token,
 		NodeID:            a.nodeID,
+		AgentApiLocalIP:   apiLocalIP,
 		AgentApiPort:      apiPort,
 		ControlRemotePort: controlRemotePort,
 		Gpus:              gpuTunnels,
+		Claims:            a.claimTunnelsLocked(),
+		AdminPort:         a.config.FRP.AdminPort,
+		PublicHost:        config.NormalizeHost(a.config.FRP.PublicHost),
+	}
+}
+
+// frpFallbackServers 生成备用frps服务器列表，未指定端口的使用主服务器端口（调用方需持有锁，启动阶段除外）
+func (a *Agent) frpFallbackServers() []frp.Server {
+	var servers []frp.Server
+	for _, s := range a.config.FRP.FallbackServers {
+		port := s.Port
+		if port == 0 {
+			port = a.config.FRP.ServerPort
+		}
+		servers = append(servers, frp.Server{Addr: config.NormalizeHost(s.Addr), Port: port})
 	}
+	return servers
 }
 
-// startAPIServer 启动API服务器
-func (a *Agent) startAPIServer() error {
+// FRPServers 返回frps服务器的探测状态，FRP未启动时为nil
+func (a *Agent) FRPServers() []frp.ServerStatus {
+	if a.frpManager == nil {
+		return nil
+	}
+	return a.frpManager.Servers()
+}
+
+// Tunnels 返回frpc隧道的状态，FRP未启动时为nil
+func (a *Agent) Tunnels(ctx context.Context) []frp.TunnelStatus {
+	if a.frpManager == nil {
+		return nil
+	}
+	return a.frpManager.Tunnels(ctx)
+}
+
+// ClaimDNS claim DNS名称登记状态，未启用时为nil
+func (a *Agent) ClaimDNS() *claimdns.Status {
+	if a.claimDNS == nil {
+		return nil
+	}
+	status := a.claimDNS.Status()
+	return &status
+}
+
+// quotaTenants 将子租户配置转换为API使用的租户与配额
+func quotaTenants(tenants []config.TenantConfig) []quota.Tenant {
+	result := make([]quota.Tenant, 0, len(tenants))
+	for _, tenant := range tenants {
+		result = append(result, quota.Tenant{
+			Name:  tenant.Name,
+			Token: tenant.Token,
+			Limits: quota.Limits{
+				MaxContainers:       tenant.MaxContainers,
+				MaxGPUs:             tenant.MaxGPUs,
+				MaxDiskGB:           tenant.MaxDiskGB,
+				MaxCreatesPerMinute: tenant.MaxCreatesPerMinute,
+			},
+		})
+	}
+	return result
+}
+
+// configureAPIServer 创建并配置API服务器，由 serveAPI 开始接受请求
+func (a *Agent) configureAPIServer() error {
 	// 创建API服务器
 	a.apiServer = api.NewServer(
 		a.containerManager,
@@ -320,109 +750,386 @@ func (a *Agent) startAPIServer() error {
 		a.systemMonitor,
 		a.config.AgentAPI.AuthToken,
 	)
+	a.apiServer.SetNodeController(a)
+	a.apiServer.SetEventBus(a.eventBus)
+	a.apiServer.SetTaskSupervisor(a.supervisor)
+	a.apiServer.SetFeatureFlags(a.featureFlags)
+	a.apiServer.SetMetricsMaxAge(a.metricsMaxAge(a.config))
+	if tenants := a.config.AgentAPI.Tenants; len(tenants) > 0 {
+		a.apiServer.SetTenants(quotaTenants(tenants))
+		fmt.Printf("API tenant tokens enabled for %d tenant(s)\n", len(tenants))
+	}
+
+	// 异步任务管理器
+	a.jobManager = jobs.NewManager(100)
+	a.apiServer.SetJobManager(a.jobManager)
+
+	// 启用幂等键支持
+	if ttl := a.config.AgentAPI.IdempotencyTTLSeconds; ttl > 0 {
+		cache, err := idempotency.NewCache(filepath.Join(a.config.DataDir, "idempotency"), time.Duration(ttl)*time.Second)
+		if err != nil {
+			return fmt.Errorf("failed to open idempotency cache: %w", err)
+		}
+		a.apiServer.EnableIdempotency(cache)
+	}
+
+	// 启用运维应急Shell
+	if a.config.BreakGlass.Enabled {
+		if err := a.enableBreakGlass(); err != nil {
+			return fmt.Errorf("failed to enable break-glass shell: %w", err)
+		}
+	}
+
+	// 要求经隧道调用的破坏性操作携带平台签名
+	if a.config.CommandSigning.Enabled {
+		verifier := signing.NewVerifier(nil, time.Duration(a.config.CommandSigning.MaxTokenTTLSeconds)*time.Second)
+		a.signingKeys.Attach(verifier)
+		a.apiServer.EnableCommandSigning(verifier)
+		fmt.Println("Platform signatures required for destructive operations")
+	}
+
+	// 使用旧进程交接的监听套接字，并启用无中断重启
+	a.apiServer.SetInheritedListeners(a.inherited)
+	if a.config.Handover.Enabled {
+		a.apiServer.EnableRestart(a)
+	}
+
+	// 启用远程电源管理
+	if a.config.Power.Enabled {
+		a.apiServer.EnablePower(a, time.Duration(a.config.Power.MaxDelaySeconds)*time.Second)
+		fmt.Println("Remote power management enabled")
+	}
+
+	// 启用驱动重新加载后的GPU恢复
+	if a.gpuMonitor.Available() {
+		a.apiServer.EnableGPUMaintenance(a)
+	}
+
+	// 启用节点自检
+	if a.config.SelfTest.Enabled {
+		a.apiServer.EnableSelfTest(a)
+	}
+
+	// 启用claim迁移导出/导入
+	if a.config.Migration.Enabled {
+		if err := a.apiServer.EnableMigration(a.config.MigrationSpoolDir()); err != nil {
+			return fmt.Errorf("failed to enable migration: %w", err)
+		}
+		fmt.Printf("Claim migration enabled (spool: %s)\n", a.config.MigrationSpoolDir())
+	}
 
-	// 在后台启动服务器
-	a.wg.Add(1)
+	// 管理端点使用独立的监听地址与令牌
+	if address := a.config.AgentAPI.AdminListenAddress; address != "" {
+		token, err := a.adminToken()
+		if err != nil {
+			return fmt.Errorf("failed to load admin API token: %w", err)
+		}
+		a.apiServer.EnableAdminListener(address, token)
+		fmt.Printf("Admin endpoints served on %s\n", address)
+	} else {
+		fmt.Println("Warning: agent_api.admin_listen_address is empty, admin endpoints are served on the main API")
+	}
+
+	// 启用本地Unix套接字
+	if path := a.config.AgentAPI.UnixSocket; path != "" {
+		a.apiServer.EnableUnixSocket(path, a.config.AgentAPI.UnixSocketAllowedUIDs, a.config.AgentAPI.UnixSocketAllowedGIDs)
+		fmt.Printf("API also served on unix socket %s\n", path)
+	}
+
+	return nil
+}
+
+// serveAPI 在后台开始接受API请求，监听失败时返回错误
+func (a *Agent) serveAPI(context.Context) error {
+	done := make(chan struct{})
+	a.apiDone = done
+	errCh := make(chan error, 1)
 	go func() {
-		defer a.wg.Done()
-		if err := a.apiServer.Start(a.config.AgentAPI.ListenAddress); err != nil {
+		defer close(done)
+		addresses := append([]string{a.config.AgentAPI.ListenAddress}, a.config.AgentAPI.AdditionalListenAddresses...)
+		err := a.apiServer.Start(addresses...)
+		if err != nil {
 			fmt.Printf("API server error: %v\n", err)
 		}
+		errCh <- err
 	}()
 
-	// 等待一下确保服务器启动
-	time.Sleep(1 * time.Second)
+	// 监听失败会立即返回，等待一下确保服务器启动
+	select {
+	case err := <-errCh:
+		if err == nil {
+			err = fmt.Errorf("API server exited during startup")
+		}
+		return err
+	case <-time.After(time.Second):
+	}
 
 	fmt.Printf("API server started on %s\n", a.config.AgentAPI.ListenAddress)
+	return nil
+}
+
+// drainAPI 停止接受新请求并等待处理中的请求完成
+func (a *Agent) drainAPI(ctx context.Context) error {
+	if a.apiServer == nil || a.apiDone == nil {
+		return nil
+	}
+	if err := a.apiServer.Stop(ctx); err != nil {
+		return err
+	}
+	select {
+	case <-a.apiDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enableBreakGlass 初始化应急Shell并注册到API服务器
+func (a *Agent) enableBreakGlass() error {
+	cfg := a.config.BreakGlass
+	manager, err := breakglass.NewManager(breakglass.Config{
+		Shell:          cfg.Shell,
+		AuditDir:       cfg.AuditDir,
+		MaxSessionTime: time.Duration(cfg.MaxSessionMinutes) * time.Minute,
+	})
+	if err != nil {
+		return err
+	}
+
+	verifier := signing.NewVerifier(nil, time.Duration(cfg.MaxTokenTTLSeconds)*time.Second)
+	a.signingKeys.Attach(verifier)
+	a.apiServer.EnableBreakGlass(manager, verifier)
 
+	fmt.Printf("Break-glass shell enabled (audit dir: %s)\n", cfg.AuditDir)
 	return nil
 }
 
-// startBackgroundTasks 启动后台任务
+// startBackgroundTasks 启动后台任务，任务由监管器运行，panic后按退避重启
 func (a *Agent) startBackgroundTasks() {
+	// 定期输出被限流日志的汇总
+	a.supervisor.Go("log_sampling", logsample.Default().Run)
+
 	// 启动GPU监控任务
-	a.wg.Add(1)
-	go func() {
-		defer a.wg.Done()
-		a.gpuMonitorTask()
-	}()
+	a.supervisor.Go("gpu_monitor", func(context.Context) { a.gpuMonitorTask() })
 
 	// 启动容器监控任务
-	a.wg.Add(1)
-	go func() {
-		defer a.wg.Done()
-		a.containerMonitorTask()
-	}()
+	a.supervisor.Go("container_monitor", func(context.Context) { a.containerMonitorTask() })
+
+	// 启动claim GPU利用率统计任务（是否统计由当前配置决定）
+	if a.gpuMonitor.Available() {
+		a.supervisor.Go("claim_gpu_utilization", func(context.Context) { a.claimGPUUtilizationTask() })
+	}
+
+	// 订阅Docker容器事件
+	a.supervisor.Go("docker_events", a.containerManager.WatchDockerEvents)
 
+	// 按计划启动claim
+	a.supervisor.Go("schedules", a.containerManager.RunSchedules)
+
+	// 启动GPU争用检测任务
+	if a.config.GPUContention.Enabled && a.gpuMonitor.Available() {
+		a.supervisor.Go("gpu_contention", func(context.Context) { a.gpuContentionTask() })
+	}
+
+	// 启动GPU温控任务（是否启用由当前配置决定）
+	if a.gpuMonitor.Available() {
+		a.supervisor.Go("thermal_policy", func(context.Context) { a.thermalPolicyTask() })
+	}
+
+	// 启动claim空闲检测任务（是否启用由当前配置决定，平台可在运行时修改）
+	a.supervisor.Go("claim_idle", func(context.Context) { a.claimIdleTask() })
+
+	// 启动claim运行时长上限任务，到期处理不依赖平台
+	a.supervisor.Go("runtime_limit", func(context.Context) { a.runtimeLimitTask() })
+
+	// 启动核心转储检查任务
+	if status := a.containerManager.CoreDumpStatus(); status != nil && status.Collecting {
+		a.supervisor.Go("core_dumps", func(context.Context) { a.coreDumpTask() })
+	}
+
+	// 启动指标采集与推送任务
+	if a.metricsPipeline != nil {
+		a.supervisor.Go("metrics_pipeline", a.metricsPipeline.Run)
+		a.supervisor.Go("metrics", func(context.Context) { a.metricsTask() })
+	}
+
+	// 启动容器日志转发任务
+	if a.logForwarder != nil {
+		a.supervisor.Go("log_forwarder", a.logForwarder.Run)
+		a.supervisor.Go("log_shipping", func(context.Context) { a.logShippingTask() })
+	}
+
+	// 启动计费采样任务
+	if a.accounting != nil {
+		a.supervisor.Go("accounting_sample", func(context.Context) { a.accountingSampleTask() })
+	}
+
+	// 与平台通信的任务，以独立模式运行时在注册成功后启动
+	if a.NodeID() != "" {
+		a.startPlatformTasks()
+	} else {
+		a.supervisor.Go("registration", a.registrationTask)
+	}
+}
+
+// startPlatformTasks 启动依赖节点ID、与平台通信的后台任务
+func (a *Agent) startPlatformTasks() {
 	// 启动FRP监控任务
-	a.wg.Add(1)
-	go func() {
-		defer a.wg.Done()
-		a.frpMonitorTask()
-	}()
+	a.supervisor.Go("frp_monitor", func(context.Context) { a.frpMonitorTask() })
+
+	// 启动地址变化检测任务
+	a.supervisor.Go("address_monitor", func(context.Context) { a.addressMonitorTask() })
+
+	// 启动心跳任务
+	a.supervisor.Go("heartbeat", func(context.Context) { a.heartbeatTask() })
+
+	// 定期拉取平台签名密钥包
+	if a.signingKeys != nil && a.currentConfig().CentralPlatform.SigningKeysRefreshSeconds > 0 {
+		a.supervisor.Go("signing_keys", func(context.Context) { a.signingKeysTask() })
+	}
+
+	// 启动计费结算与上传任务
+	if a.accounting != nil {
+		a.supervisor.Go("accounting_report", func(context.Context) { a.accountingReportTask() })
+	}
 }
 
-// gpuMonitorTask GPU监控任务
-func (a *Agent) gpuMonitorTask() {
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
+// runPeriodic 按当前配置的间隔周期执行任务，间隔变更在下一周期生效
+// 每次执行的结果记录到监管器中名为name的任务
+func (a *Agent) runPeriodic(name string, intervalSeconds func(*config.Config) int, task func() error) {
+	a.runPeriodicOrWake(name, intervalSeconds, nil, task)
+}
 
+// runPeriodicOrWake 与runPeriodic相同，wake收到信号时立即执行一次并重新开始计时
+func (a *Agent) runPeriodicOrWake(name string, intervalSeconds func(*config.Config) int, wake <-chan struct{}, task func() error) {
 	for {
+		interval := time.Duration(intervalSeconds(a.currentConfig())) * time.Second
+		a.supervisor.SetInterval(name, interval)
+		timer := time.NewTimer(interval)
+
 		select {
 		case <-a.ctx.Done():
+			timer.Stop()
 			return
-		case <-ticker.C:
-			if err := a.gpuMonitor.RefreshGPUInfo(); err != nil {
-				fmt.Printf("Failed to refresh GPU info: %v\n", err)
-			}
+		case <-timer.C:
+			a.supervisor.Report(name, task())
+		case <-wake:
+			timer.Stop()
+			a.supervisor.Report(name, task())
 		}
 	}
 }
 
-// containerMonitorTask 容器监控任务
-func (a *Agent) containerMonitorTask() {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+// metricsMaxAge 缓存GPU指标的最长有效期：后台刷新周期（按自适应采样调整后）的两倍
+func (a *Agent) metricsMaxAge(cfg *config.Config) time.Duration {
+	interval := a.samplingInterval(cfg.Monitor.Adaptive, cfg.Monitor.GPUIntervalSeconds)
+	return 2 * time.Duration(interval) * time.Second
+}
 
-	for {
-		select {
-		case <-a.ctx.Done():
-			return
-		case <-ticker.C:
-			if err := a.containerManager.RefreshContainers(a.ctx); err != nil {
-				fmt.Printf("Failed to refresh containers: %v\n", err)
-			}
+// gpuMonitorTask GPU监控任务
+func (a *Agent) gpuMonitorTask() {
+	interval := func(c *config.Config) int {
+		return a.samplingInterval(c.Monitor.Adaptive, c.Monitor.GPUIntervalSeconds)
+	}
+	a.runPeriodic("gpu_monitor", interval, func() error {
+		err := a.gpuMonitor.RefreshGPUInfo()
+		if err != nil {
+			logsample.Printf("Failed to refresh GPU info: %v\n", err)
 		}
+		a.updateSampling(err)
+		return err
+	})
+}
+
+// claimGPUUtilizationTask 按进程统计各claim的GPU利用率，间隔为0时不统计
+func (a *Agent) claimGPUUtilizationTask() {
+	interval := func(c *config.Config) int {
+		if c.Monitor.ClaimGPUIntervalSeconds > 0 {
+			return c.Monitor.ClaimGPUIntervalSeconds
+		}
+		return c.Monitor.ContainerIntervalSeconds
 	}
+	a.runPeriodic("claim_gpu_utilization", interval, func() error {
+		// GPU维护期间NVML已关闭
+		if a.currentConfig().Monitor.ClaimGPUIntervalSeconds == 0 || a.gpuMonitor.InMaintenance() {
+			return nil
+		}
+		err := a.containerManager.SampleGPUUtilization()
+		if err != nil {
+			logsample.Printf("Failed to sample claim GPU utilization: %v\n", err)
+		}
+		return err
+	})
+}
+
+// eventStreamRefreshFactor Docker事件流连接期间全量刷新容器的间隔（容器监控周期的倍数）
+const eventStreamRefreshFactor = 10
+
+// containerMonitorTask 容器监控任务
+// Docker事件流连接期间每 eventStreamRefreshFactor 个周期全量刷新一次；事件流中断或重新连接后的首个周期立即全量刷新
+func (a *Agent) containerMonitorTask() {
+	var lastFull time.Time
+	a.runPeriodic("container_monitor", func(c *config.Config) int { return c.Monitor.ContainerIntervalSeconds }, func() error {
+		interval := time.Duration(a.currentConfig().Monitor.ContainerIntervalSeconds) * time.Second
+		since := a.containerManager.EventStreamSince()
+		if !since.IsZero() && since.Before(lastFull) && time.Since(lastFull) < eventStreamRefreshFactor*interval {
+			return nil
+		}
+		err := a.containerManager.RefreshContainers(a.ctx)
+		if err != nil {
+			logsample.Printf("Failed to refresh containers: %v\n", err)
+			return err
+		}
+		lastFull = time.Now()
+		return nil
+	})
 }
 
 // frpMonitorTask FRP监控任务
 func (a *Agent) frpMonitorTask() {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-a.ctx.Done():
-			return
-		case <-ticker.C:
-			if !a.frpManager.IsRunning() {
-				fmt.Println("FRP process died, restarting...")
-				if err := a.frpManager.Restart(a.ctx); err != nil {
-					fmt.Printf("Failed to restart FRP: %v\n", err)
-				} else {
-					fmt.Println("FRP restarted successfully")
-				}
+	a.runPeriodic("frp_monitor", func(c *config.Config) int { return c.Monitor.FRPIntervalSeconds }, func() error {
+		a.mu.RLock()
+		closed := a.tunnelsClosed
+		a.mu.RUnlock()
+		if closed {
+			return nil
+		}
+		// 以独立模式启动的节点注册后创建FRP管理器失败时重试
+		if a.frpManager == nil {
+			return a.startRegisteredFRP()
+		}
+		if a.frpManager.IsRunning() {
+			// 当前frps不可达时切换到其他服务器
+			if err := a.frpManager.CheckServer(a.ctx); err != nil {
+				return fmt.Errorf("FRP server %s: %w", a.frpManager.ActiveServer(), err)
 			}
+			return nil
 		}
-	}
+		fmt.Println("FRP process died, restarting...")
+		if err := a.frpManager.Restart(a.ctx); err != nil {
+			logsample.Printf("Failed to restart FRP: %v\n", err)
+			return fmt.Errorf("failed to restart FRP: %w", err)
+		}
+		fmt.Println("FRP restarted successfully")
+		return nil
+	})
 }
 
-// getPortFromAddress 从地址中提取端口
-func getPortFromAddress(address string) string {
-	parts := strings.Split(address, ":")
-	if len(parts) == 2 {
-		return parts[1]
+// heartbeatTask 心跳任务
+func (a *Agent) heartbeatTask() {
+	a.runPeriodicOrWake("heartbeat", func(c *config.Config) int { return c.CentralPlatform.HeartbeatIntervalSeconds }, a.heartbeatWake, func() error {
+		err := a.sendHeartbeat()
+		if err != nil {
+			logsample.Printf("Failed to send heartbeat: %v\n", err)
+		}
+		return err
+	})
+}
+
+// removeFileIfExists 删除文件，文件不存在时不报错
+func removeFileIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
 	}
-	return ""
+	return nil
 }
@@ -0,0 +1,231 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/gpu"
+)
+
+// GPU争用事件类型
+const (
+	EventGPUContentionDetected = "gpu.contention_detected"
+	EventGPUContentionResolved = "gpu.contention_resolved"
+	EventGPUIntruderStopped    = "gpu.intruder_stopped"
+)
+
+// intruderKey 已上报的占用者（按GPU区分）
+type intruderKey struct {
+	gpuID int
+	key   string
+}
+
+// mpsServerProcess MPS守护进程代表共享计算的客户端运行在GPU上
+const mpsServerProcess = "nvidia-cuda-mps-server"
+
+// gpuContentionTask 定期检查已预留的GPU是否被非预留方使用
+func (a *Agent) gpuContentionTask() {
+	a.runPeriodic("gpu_contention", func(c *config.Config) int { return c.GPUContention.IntervalSeconds }, a.checkGPUContention)
+}
+
+// checkGPUContention 查找已预留GPU的占用者，标记争用、发布事件并按策略停止非受管容器
+func (a *Agent) checkGPUContention() error {
+	cfg := a.currentConfig().GPUContention
+	if !cfg.Enabled || a.gpuMonitor.InMaintenance() {
+		return nil
+	}
+
+	reservations := a.containerManager.GPUReservations()
+	intruders, err := a.findIntruders(reservations, cfg.IgnoreContainers)
+	if err != nil {
+		// 查询失败时保留上一次的结果
+		return err
+	}
+	a.gpuMonitor.SetContention(intruders)
+	a.reportContention(reservations, intruders)
+
+	if cfg.Action == config.ContentionActionStop {
+		a.stopIntruders(reservations, intruders)
+	}
+	return nil
+}
+
+// findIntruders 查找每个已预留GPU上不属于预留claim的计算进程与配置了该GPU的非受管容器
+func (a *Agent) findIntruders(reservations map[int][]string, ignore []string) (map[int][]gpu.Intruder, error) {
+	intruders := make(map[int][]gpu.Intruder)
+	if len(reservations) == 0 {
+		return intruders, nil
+	}
+
+	uuids := make(map[string]int)
+	for _, g := range a.gpuMonitor.GetGPUInfo() {
+		uuids[g.UUID] = g.ID
+	}
+	foreign, err := a.containerManager.ForeignGPUContainers(a.ctx, uuids)
+	if err != nil {
+		return nil, err
+	}
+	foreignByID := make(map[string]container.ForeignContainer, len(foreign))
+	for _, f := range foreign {
+		foreignByID[f.ID] = f
+	}
+
+	for gpuID, claims := range reservations {
+		seen := make(map[string]bool)
+		add := func(intruder gpu.Intruder) {
+			if intruder.ContainerName != "" && ignoredContainer(intruder.ContainerName, ignore) {
+				return
+			}
+			if key := intruder.Key(); !seen[key] {
+				seen[key] = true
+				intruders[gpuID] = append(intruders[gpuID], intruder)
+			}
+		}
+
+		processes, err := a.gpuMonitor.Processes(gpuID)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range processes {
+			intruder := gpu.Intruder{PID: p.PID, ProcessName: p.Name, UsedMemoryMB: p.UsedMemoryMB}
+			if info, managed := a.containerManager.ContainerForPID(int(p.PID)); managed {
+				if containsString(claims, info.ClaimID) {
+					continue
+				}
+				intruder.Kind, intruder.ContainerID, intruder.ClaimID = gpu.IntruderClaim, info.ID, info.ClaimID
+			} else if containerID := container.DockerContainerIDForPID(int(p.PID)); containerID != "" {
+				f := foreignByID[containerID]
+				intruder.Kind, intruder.ContainerID, intruder.ContainerName, intruder.Image = gpu.IntruderContainer, containerID, f.Name, f.Image
+			} else {
+				if p.Name == mpsServerProcess {
+					continue
+				}
+				intruder.Kind = gpu.IntruderProcess
+			}
+			add(intruder)
+		}
+
+		// 配置了GPU访问但尚未运行进程的容器同样视为占用者
+		for _, f := range foreign {
+			if f.UsesGPU(gpuID) {
+				add(gpu.Intruder{Kind: gpu.IntruderContainer, ContainerID: f.ID, ContainerName: f.Name, Image: f.Image})
+			}
+		}
+	}
+	return intruders, nil
+}
+
+// reportContention 对新发现的占用者发布争用事件，对已消失的占用者发布解除事件
+func (a *Agent) reportContention(reservations map[int][]string, intruders map[int][]gpu.Intruder) {
+	current := make(map[intruderKey]gpu.Intruder)
+	for gpuID, list := range intruders {
+		for _, intruder := range list {
+			key := intruderKey{gpuID: gpuID, key: intruder.Key()}
+			current[key] = intruder
+			if _, reported := a.reportedIntruders[key]; reported {
+				continue
+			}
+			fmt.Printf("Warning: GPU %d reserved by %v is used by %s\n", gpuID, reservations[gpuID], describeIntruder(intruder))
+			a.eventBus.Publish(events.Event{
+				Type:        EventGPUContentionDetected,
+				Severity:    events.SeverityWarning,
+				ContainerID: intruder.ContainerID,
+				Message:     fmt.Sprintf("GPU %d reserved by claim(s) %v is used by %s", gpuID, reservations[gpuID], describeIntruder(intruder)),
+				Data: map[string]interface{}{
+					"gpu_id":    gpuID,
+					"claim_ids": reservations[gpuID],
+					"intruder":  intruder,
+				},
+			})
+		}
+	}
+	for key, intruder := range a.reportedIntruders {
+		if _, exists := current[key]; exists {
+			continue
+		}
+		a.eventBus.Publish(events.Event{
+			Type:        EventGPUContentionResolved,
+			Severity:    events.SeverityInfo,
+			ContainerID: intruder.ContainerID,
+			Message:     fmt.Sprintf("GPU %d is no longer used by %s", key.gpuID, describeIntruder(intruder)),
+			Data: map[string]interface{}{
+				"gpu_id":   key.gpuID,
+				"intruder": intruder,
+			},
+		})
+	}
+	a.reportedIntruders = current
+}
+
+// stopIntruders 停止占用已预留GPU的非受管容器
+func (a *Agent) stopIntruders(reservations map[int][]string, intruders map[int][]gpu.Intruder) {
+	stopped := make(map[string]bool)
+	for gpuID, list := range intruders {
+		for _, intruder := range list {
+			if intruder.Kind != gpu.IntruderContainer || stopped[intruder.ContainerID] {
+				continue
+			}
+			stopped[intruder.ContainerID] = true
+
+			ctx, cancel := context.WithTimeout(a.ctx, time.Minute)
+			err := a.containerManager.StopForeignContainer(ctx, intruder.ContainerID)
+			cancel()
+			if err != nil {
+				fmt.Printf("Warning: failed to stop container %s using reserved GPU %d: %v\n", intruder.ContainerID, gpuID, err)
+				continue
+			}
+			fmt.Printf("Stopped container %s using GPU %d reserved by %v\n", describeIntruder(intruder), gpuID, reservations[gpuID])
+			a.eventBus.Publish(events.Event{
+				Type:        EventGPUIntruderStopped,
+				Severity:    events.SeverityWarning,
+				ContainerID: intruder.ContainerID,
+				Message:     fmt.Sprintf("stopped %s using GPU %d reserved by claim(s) %v", describeIntruder(intruder), gpuID, reservations[gpuID]),
+				Data: map[string]interface{}{
+					"gpu_id":    gpuID,
+					"claim_ids": reservations[gpuID],
+					"intruder":  intruder,
+				},
+			})
+		}
+	}
+}
+
+// describeIntruder 占用者的简短描述
+func describeIntruder(intruder gpu.Intruder) string {
+	switch intruder.Kind {
+	case gpu.IntruderClaim:
+		return fmt.Sprintf("container %s of claim %s", shortID(intruder.ContainerID), intruder.ClaimID)
+	case gpu.IntruderContainer:
+		if intruder.ContainerName != "" {
+			return fmt.Sprintf("unmanaged container %s (%s)", intruder.ContainerName, shortID(intruder.ContainerID))
+		}
+		return fmt.Sprintf("unmanaged container %s", shortID(intruder.ContainerID))
+	default:
+		return fmt.Sprintf("host process %d (%s)", intruder.PID, intruder.ProcessName)
+	}
+}
+
+// ignoredContainer 容器名称是否匹配忽略的模式
+func ignoredContainer(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString 列表中是否包含s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
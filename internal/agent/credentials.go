@@ -0,0 +1,356 @@
+package agent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/frp"
+	"utopia-node-agent/internal/identity"
+	"utopia-node-agent/internal/watch"
+)
+
+// 凭据文件名称
+const (
+	credentialIdentity  = "identity"
+	credentialAuthToken = "auth_token"
+	credentialFRPToken  = "frp_token"
+)
+
+// 凭据文件事件
+const (
+	EventCredentialReloaded     = "agent.credential_reloaded"
+	EventCredentialReloadFailed = "agent.credential_reload_failed"
+)
+
+// adminTokenFile 未配置管理令牌时自动生成的令牌文件（位于数据目录下）
+const adminTokenFile = "admin_token"
+
+// credentialDebounce 合并配置管理工具写文件时产生的连续事件
+const credentialDebounce = 500 * time.Millisecond
+
+// CredentialFiles 返回被监视的凭据文件及其最近一次加载结果
+func (a *Agent) CredentialFiles() []watch.FileStatus {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var result []watch.FileStatus
+	for _, name := range []string{credentialIdentity, credentialAuthToken, credentialFRPToken} {
+		if status, exists := a.credentialFiles[name]; exists {
+			result = append(result, *status)
+		}
+	}
+	return result
+}
+
+// loadTokenFiles 启动时从令牌文件加载认证令牌与FRP令牌
+func (a *Agent) loadTokenFiles() error {
+	cfg := *a.config
+	if path := cfg.AgentAPI.AuthTokenFile; path != "" {
+		token, err := config.ReadSecretFile(path)
+		if err != nil {
+			return fmt.Errorf("agent_api.auth_token_file: %w", err)
+		}
+		cfg.AgentAPI.AuthToken = token
+		a.recordCredential(credentialAuthToken, path, nil)
+	}
+	if path := cfg.FRP.TokenFile; path != "" {
+		token, err := config.ReadSecretFile(path)
+		if err != nil {
+			return fmt.Errorf("frp.token_file: %w", err)
+		}
+		cfg.FRP.Token = token
+		a.recordCredential(credentialFRPToken, path, nil)
+	}
+	if path := cfg.AgentAPI.AdminAuthTokenFile; path != "" {
+		token, err := config.ReadSecretFile(path)
+		if err != nil {
+			return fmt.Errorf("agent_api.admin_auth_token_file: %w", err)
+		}
+		cfg.AgentAPI.AdminAuthToken = token
+	}
+	if path := cfg.Registry.PasswordFile; path != "" {
+		password, err := config.ReadPrivateSecretFile(path)
+		if err != nil {
+			return fmt.Errorf("registry.password_file: %w", err)
+		}
+		cfg.Registry.Password = password
+	}
+	cfg.Registry.Credentials = append([]config.RegistryCredential(nil), cfg.Registry.Credentials...)
+	for i, credential := range cfg.Registry.Credentials {
+		if credential.PasswordFile != "" {
+			password, err := config.ReadPrivateSecretFile(credential.PasswordFile)
+			if err != nil {
+				return fmt.Errorf("registry.credentials[%s].password_file: %w", credential.Server, err)
+			}
+			cfg.Registry.Credentials[i].Password = password
+		}
+	}
+	if cfg.AgentAPI.AdminAuthToken != "" && cfg.AgentAPI.AdminAuthToken == cfg.AgentAPI.AuthToken {
+		return fmt.Errorf("agent_api: admin token must differ from the API auth token")
+	}
+	cfg.AgentAPI.Tenants = append([]config.TenantConfig(nil), cfg.AgentAPI.Tenants...)
+	for i, tenant := range cfg.AgentAPI.Tenants {
+		if tenant.TokenFile != "" {
+			token, err := config.ReadSecretFile(tenant.TokenFile)
+			if err != nil {
+				return fmt.Errorf("agent_api.tenants[%s].token_file: %w", tenant.Name, err)
+			}
+			cfg.AgentAPI.Tenants[i].Token = token
+		}
+		if cfg.AgentAPI.Tenants[i].Token == cfg.AgentAPI.AuthToken {
+			return fmt.Errorf("agent_api.tenants[%s]: token must differ from the API auth token", tenant.Name)
+		}
+	}
+	a.config = &cfg
+	return nil
+}
+
+// adminToken 返回管理端点的令牌：配置的令牌，或数据目录下自动生成的令牌（首次启动时生成）
+func (a *Agent) adminToken() (string, error) {
+	if token := a.config.AgentAPI.AdminAuthToken; token != "" {
+		return token, nil
+	}
+
+	path := filepath.Join(a.config.DataDir, adminTokenFile)
+	token, err := config.ReadSecretFile(path)
+	if err == nil {
+		return token, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate admin token: %w", err)
+	}
+	token = hex.EncodeToString(secret)
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write admin token: %w", err)
+	}
+	fmt.Printf("Generated admin API token in %s\n", path)
+	return token, nil
+}
+
+// startCredentialWatcher 监视身份文件与令牌文件，变化时在线重新加载
+func (a *Agent) startCredentialWatcher() error {
+	watcher, err := watch.NewWatcher(credentialDebounce)
+	if err != nil {
+		return err
+	}
+
+	a.recordCredential(credentialIdentity, a.config.IdentityFilePath, nil)
+	files := map[string]func(){
+		a.config.IdentityFilePath: a.reloadIdentity,
+	}
+	if path := a.config.AgentAPI.AuthTokenFile; path != "" {
+		files[path] = a.reloadAuthToken
+	}
+	if path := a.config.FRP.TokenFile; path != "" {
+		files[path] = a.reloadFRPToken
+	}
+	for path, reload := range files {
+		if err := watcher.Add(path, reload); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer watcher.Close()
+		watcher.Run(a.ctx)
+	}()
+	return nil
+}
+
+// reloadIdentity 重新加载节点身份，节点ID变化时以新ID重建FRP隧道
+// 文件被删除或内容无效时保留当前身份；写入只有节点ID的旧版文件时沿用当前密钥对并升级文件
+func (a *Agent) reloadIdentity() {
+	path := a.currentConfig().IdentityFilePath
+	key, err := a.identityKey()
+	var id *identity.Identity
+	if err == nil {
+		id, err = identity.Load(path, key)
+	}
+	if err == nil && (id == nil || id.NodeID == "") {
+		if a.isDecommissioning() {
+			return
+		}
+		err = fmt.Errorf("identity file %s was removed", path)
+	}
+	if err == nil {
+		if _, parseErr := strconv.Atoi(id.NodeID); parseErr != nil {
+			err = fmt.Errorf("invalid node ID %q", id.NodeID)
+		}
+	}
+	if err != nil {
+		a.credentialReloadFailed(credentialIdentity, path, err)
+		return
+	}
+
+	a.mu.Lock()
+	previous, current := a.nodeID, a.identity
+	if a.decommissioning {
+		a.mu.Unlock()
+		return
+	}
+	upgrade := !id.HasKey()
+	if upgrade {
+		id.AdoptKey(current)
+	}
+	unchanged := id.NodeID == previous && id.PublicKey() == current.PublicKey()
+	a.mu.Unlock()
+
+	// 升级后的文件再次触发重新加载时内容不变
+	if upgrade {
+		if err := id.Save(path, key); err != nil {
+			fmt.Printf("Warning: failed to upgrade identity file %s: %v\n", path, err)
+		}
+	}
+	if unchanged {
+		return
+	}
+	a.setIdentity(id)
+	if id.NodeID == previous {
+		a.credentialReloaded(credentialIdentity, path, map[string]interface{}{
+			"node_id":             id.NodeID,
+			"identity_public_key": id.PublicKey(),
+		})
+		return
+	}
+
+	a.restartFRPWithCurrentConfig()
+	a.mu.RLock()
+	if a.containerManager != nil {
+		a.containerManager.UpdateOptions(a.containerOptions())
+	}
+	a.mu.RUnlock()
+
+	a.credentialReloaded(credentialIdentity, path, map[string]interface{}{
+		"previous_node_id":    previous,
+		"node_id":             id.NodeID,
+		"identity_public_key": id.PublicKey(),
+	})
+}
+
+// reloadAuthToken 重新加载API认证令牌
+func (a *Agent) reloadAuthToken() {
+	path := a.currentConfig().AgentAPI.AuthTokenFile
+	token, err := config.ReadSecretFile(path)
+	if err != nil {
+		a.credentialReloadFailed(credentialAuthToken, path, err)
+		return
+	}
+
+	a.mu.Lock()
+	if token == a.config.AgentAPI.AuthToken {
+		a.mu.Unlock()
+		return
+	}
+	cfg := *a.config
+	cfg.AgentAPI.AuthToken = token
+	a.config = &cfg
+	a.mu.Unlock()
+
+	if a.apiServer != nil {
+		a.apiServer.SetAuthToken(token)
+	}
+	a.credentialReloaded(credentialAuthToken, path, nil)
+}
+
+// reloadFRPToken 重新加载FRP令牌并重启frpc
+func (a *Agent) reloadFRPToken() {
+	path := a.currentConfig().FRP.TokenFile
+	token, err := config.ReadSecretFile(path)
+	if err != nil {
+		a.credentialReloadFailed(credentialFRPToken, path, err)
+		return
+	}
+
+	a.mu.Lock()
+	if token == a.config.FRP.Token {
+		a.mu.Unlock()
+		return
+	}
+	cfg := *a.config
+	cfg.FRP.Token = token
+	a.config = &cfg
+	a.mu.Unlock()
+
+	a.restartFRPWithCurrentConfig()
+	a.credentialReloaded(credentialFRPToken, path, nil)
+}
+
+// restartFRPWithCurrentConfig 按当前节点ID与配置重新生成FRP配置并重启frpc
+func (a *Agent) restartFRPWithCurrentConfig() {
+	if a.frpManager == nil {
+		return
+	}
+	a.tunnelMu.Lock()
+	defer a.tunnelMu.Unlock()
+
+	a.mu.RLock()
+	frpConfig := a.generateFRPConfig()
+	a.mu.RUnlock()
+
+	if err := a.frpManager.UpdateConfig(a.ctx, frpConfig); errors.Is(err, frp.ErrTokenRejected) {
+		fmt.Printf("Warning: frps rejected the reloaded FRP token: %v\n", err)
+	} else if err != nil {
+		fmt.Printf("Failed to restart FRP with current config: %v\n", err)
+	}
+}
+
+// isDecommissioning 节点是否正在退役（退役流程会删除身份文件）
+func (a *Agent) isDecommissioning() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.decommissioning
+}
+
+// recordCredential 记录凭据文件的加载结果
+func (a *Agent) recordCredential(name, path string, err error) {
+	status := &watch.FileStatus{Name: name, Path: path, LoadedAt: time.Now().Unix()}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		// 加载失败时保留上次成功加载的时间
+		if previous, exists := a.credentialFiles[name]; exists {
+			status.LoadedAt = previous.LoadedAt
+		}
+		status.Error = err.Error()
+	}
+	a.credentialFiles[name] = status
+}
+
+// credentialReloaded 记录并发布凭据重新加载事件
+func (a *Agent) credentialReloaded(name, path string, data map[string]interface{}) {
+	a.recordCredential(name, path, nil)
+	fmt.Printf("Reloaded %s from %s\n", name, path)
+	a.eventBus.Publish(events.Event{
+		Type:     EventCredentialReloaded,
+		Severity: events.SeverityInfo,
+		Message:  fmt.Sprintf("%s reloaded from %s", name, path),
+		Data:     data,
+	})
+}
+
+// credentialReloadFailed 记录并发布凭据重新加载失败事件，继续使用当前值
+func (a *Agent) credentialReloadFailed(name, path string, err error) {
+	a.recordCredential(name, path, err)
+	fmt.Printf("Warning: failed to reload %s: %v\n", name, err)
+	a.eventBus.Publish(events.Event{
+		Type:     EventCredentialReloadFailed,
+		Severity: events.SeverityWarning,
+		Message:  fmt.Sprintf("failed to reload %s, keeping current value: %v", name, err),
+	})
+}
@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/identity"
+)
+
+// Done 返回在agent请求退出（例如节点退役完成）时关闭的通道
+func (a *Agent) Done() <-chan struct{} {
+	return a.done
+}
+
+// NodeID 返回节点ID
+func (a *Agent) NodeID() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.nodeID
+}
+
+// AgentVersion 返回Agent版本号
+func (a *Agent) AgentVersion() string {
+	return Version
+}
+
+// IsDraining 节点是否正在排空
+func (a *Agent) IsDraining() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.draining
+}
+
+// Decommission 启动节点退役流程，立即返回，流程在后台执行
+func (a *Agent) Decommission() error {
+	a.mu.Lock()
+	if a.decommissioning {
+		a.mu.Unlock()
+		return fmt.Errorf("decommission already in progress")
+	}
+	if a.restarting {
+		a.mu.Unlock()
+		return fmt.Errorf("agent restart in progress")
+	}
+	a.decommissioning = true
+	a.draining = true
+	a.mu.Unlock()
+
+	go a.runDecommission()
+	return nil
+}
+
+// runDecommission 执行退役流程：删除容器与卷 -> 关闭隧道 -> 平台注销 -> 删除本地身份 -> 退出
+// 注销失败时保留身份并以排空状态继续运行，可再次调用退役端点重试
+func (a *Agent) runDecommission() {
+	// 给API响应留出通过隧道返回的时间
+	time.Sleep(1 * time.Second)
+
+	fmt.Println("Decommissioning node: removing managed containers...")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if err := a.containerManager.RefreshContainers(ctx); err != nil {
+		fmt.Printf("Warning: failed to refresh containers before decommission: %v\n", err)
+	}
+	// 先记录容器挂载的命名卷，包括非agent创建的卷
+	volumes := make(map[string]bool)
+	for _, info := range a.containerManager.ListContainers() {
+		mounts, err := a.containerManager.ContainerMounts(ctx, info.ID)
+		if err != nil {
+			fmt.Printf("Warning: failed to list mounts of container %s: %v\n", info.ID, err)
+		}
+		for _, m := range mounts {
+			if m.Type == "volume" && m.Name != "" {
+				volumes[m.Name] = true
+			}
+		}
+	}
+	for _, info := range a.containerManager.ListContainers() {
+		if err := a.containerManager.RemoveContainer(ctx, info.ID); err != nil {
+			fmt.Printf("Error removing container %s: %v\n", info.ID, err)
+		}
+	}
+
+	fmt.Println("Decommissioning node: removing managed volumes...")
+	managed, err := container.ManagedVolumes(ctx)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	for _, name := range managed {
+		volumes[name] = true
+	}
+	for name := range volumes {
+		if err := container.RemoveVolume(ctx, name); err != nil {
+			fmt.Printf("Error removing volume: %v\n", err)
+		}
+	}
+
+	// 上报最后的计费记录
+	a.settleAccounting(ctx)
+
+	// 以独立模式运行、尚未注册的节点没有启动frpc
+	if a.frpManager != nil {
+		fmt.Println("Decommissioning node: tearing down tunnels...")
+		a.mu.Lock()
+		a.tunnelsClosed = true
+		a.mu.Unlock()
+		if err := a.frpManager.Stop(); err != nil {
+			fmt.Printf("Error stopping FRP: %v\n", err)
+		}
+		if err := a.frpManager.CleanupConfig(); err != nil {
+			fmt.Printf("Error cleaning up FRP config: %v\n", err)
+		}
+	}
+
+	fmt.Println("Decommissioning node: notifying platform...")
+	if err := a.regClient.Deregister(ctx, a.nodeID); err != nil {
+		// 保留本地身份并继续以排空状态运行，运维可通过管理端点重试注销
+		fmt.Printf("Error deregistering node %s, keeping identity file and staying in draining state until decommission is retried: %v\n", a.nodeID, err)
+		a.mu.Lock()
+		a.decommissioning = false
+		a.mu.Unlock()
+		return
+	}
+
+	if err := identity.Remove(a.config.IdentityFilePath); err != nil {
+		fmt.Printf("Error removing identity file: %v\n", err)
+	}
+	if err := removeFileIfExists(a.config.OverridesPath()); err != nil {
+		fmt.Printf("Error removing config overrides: %v\n", err)
+	}
+	if err := removeFileIfExists(a.config.SecretsKeyFile()); err != nil {
+		fmt.Printf("Error removing encryption key: %v\n", err)
+	}
+
+	fmt.Printf("Node %s decommissioned\n", a.nodeID)
+	a.closeDone()
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"utopia-node-agent/internal/api"
+	"utopia-node-agent/internal/registration"
+)
+
+// offlineNoticeTimeout 停止时通知平台下线的时限，平台不可达时不拖慢退出
+const offlineNoticeTimeout = 5 * time.Second
+
+// PlannedDowntime 返回计划停机，未设置时返回nil
+func (a *Agent) PlannedDowntime() *api.PlannedDowntime {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.plannedDowntime == nil {
+		return nil
+	}
+	plan := *a.plannedDowntime
+	return &plan
+}
+
+// PlanDowntime 设置agent停止时随下线通知上报的预计停机时长，覆盖之前的设置
+func (a *Agent) PlanDowntime(expected time.Duration, message string) *api.PlannedDowntime {
+	plan := &api.PlannedDowntime{
+		ExpectedDurationSeconds: int64(expected / time.Second),
+		Message:                 message,
+		PlannedAt:               time.Now().Unix(),
+	}
+	a.mu.Lock()
+	a.plannedDowntime = plan
+	a.mu.Unlock()
+
+	copied := *plan
+	return &copied
+}
+
+// ClearPlannedDowntime 取消计划停机
+func (a *Agent) ClearPlannedDowntime() {
+	a.mu.Lock()
+	a.plannedDowntime = nil
+	a.mu.Unlock()
+}
+
+// notifyOffline 停止前通知平台节点下线并进入排空状态，使平台立即停止向节点调度claim
+// 未注册、已退役（已注销）或交给新进程时不通知
+func (a *Agent) notifyOffline() {
+	if a.passiveStop() {
+		return
+	}
+
+	a.mu.Lock()
+	if a.nodeID == "" || a.decommissioning || a.restarting || a.offlineNotified {
+		a.mu.Unlock()
+		return
+	}
+	a.draining = true
+	notice := &registration.OfflineNotice{
+		NodeID:    a.nodeID,
+		Timestamp: time.Now().Unix(),
+		Reason:    registration.OfflineReasonShutdown,
+	}
+	if plan := a.plannedDowntime; plan != nil {
+		notice.ExpectedDurationSeconds = plan.ExpectedDurationSeconds
+		notice.Message = plan.Message
+	}
+	a.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), offlineNoticeTimeout)
+	defer cancel()
+	if err := a.regClient.NotifyOffline(ctx, notice); err != nil {
+		fmt.Printf("Warning: failed to notify platform of shutdown, it will detect missed heartbeats instead: %v\n", err)
+		return
+	}
+
+	a.mu.Lock()
+	a.offlineNotified = true
+	a.mu.Unlock()
+	if notice.ExpectedDurationSeconds > 0 {
+		fmt.Printf("Notified platform of shutdown (expected downtime %s)\n", time.Duration(notice.ExpectedDurationSeconds)*time.Second)
+	} else {
+		fmt.Println("Notified platform of shutdown")
+	}
+}
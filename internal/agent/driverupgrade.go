@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"utopia-node-agent/internal/api"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/gpu"
+	"utopia-node-agent/internal/jobs"
+	"utopia-node-agent/internal/tracing"
+)
+
+// GPU驱动升级事件
+const (
+	EventGPUDriverUpgraded      = "gpu.driver_upgraded"
+	EventGPUDriverUpgradeFailed = "gpu.driver_upgrade_failed"
+)
+
+// DriverUpgradeEnabled 是否配置了驱动升级命令
+func (a *Agent) DriverUpgradeEnabled() bool {
+	return len(a.currentConfig().GPUDriverUpgrade.Command) > 0
+}
+
+// UpgradeDriver 提交驱动升级任务：排空GPU容器，执行升级命令，校验NVML后恢复容器
+func (a *Agent) UpgradeDriver(req api.GPUDriverUpgradeRequest) (jobs.Job, error) {
+	cfg := a.currentConfig().GPUDriverUpgrade
+	if len(cfg.Command) == 0 {
+		return jobs.Job{}, fmt.Errorf("gpu_driver_upgrade.command is not configured")
+	}
+
+	a.mu.Lock()
+	if a.gpuMaintenance {
+		a.mu.Unlock()
+		return jobs.Job{}, api.ErrGPUMaintenanceRunning
+	}
+	a.gpuMaintenance = true
+	a.mu.Unlock()
+
+	timeout := defaultGPUReattachTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	commandTimeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+
+	return a.jobManager.Submit("gpu.driver_upgrade", func(ctx context.Context, h *jobs.Handle) (map[string]interface{}, error) {
+		defer func() {
+			a.mu.Lock()
+			a.gpuMaintenance = false
+			a.mu.Unlock()
+		}()
+		// 命令同步完成驱动的卸载与加载，结束后直接等待NVML
+		result, err := a.reattachGPUs(ctx, h, driverPlan{
+			timeout: timeout,
+			upgrade: func(ctx context.Context) error {
+				ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+				defer cancel()
+				return a.runDriverUpgrade(ctx, h, cfg.Command, req.Version)
+			},
+			targetVersion: req.Version,
+		})
+		result["target_version"] = req.Version
+		a.publishDriverUpgradeEvent(result, err)
+		return result, err
+	}), nil
+}
+
+// runDriverUpgrade 执行驱动升级命令，输出逐行写入任务日志
+func (a *Agent) runDriverUpgrade(ctx context.Context, h *jobs.Handle, command []string, version string) error {
+	h.Log("running " + strings.Join(command, " "))
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(tracing.CommandEnv(ctx),
+		"UTOPIA_NODE_ID="+a.NodeID(),
+		"UTOPIA_DRIVER_VERSION="+version,
+		"UTOPIA_DRIVER_CURRENT="+gpu.DriverVersion(),
+	)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", command[0], err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			h.Log(line)
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s timed out: %w", command[0], ctx.Err())
+		}
+		return fmt.Errorf("%s: %w", command[0], err)
+	}
+	return nil
+}
+
+// publishDriverUpgradeEvent 发布驱动升级结果事件
+func (a *Agent) publishDriverUpgradeEvent(result map[string]interface{}, err error) {
+	if err != nil {
+		a.eventBus.Publish(events.Event{
+			Type:     EventGPUDriverUpgradeFailed,
+			Severity: events.SeverityError,
+			Message:  fmt.Sprintf("GPU driver upgrade failed: %v", err),
+			Data:     result,
+		})
+		return
+	}
+	a.eventBus.Publish(events.Event{
+		Type:     EventGPUDriverUpgraded,
+		Severity: events.SeverityInfo,
+		Message:  fmt.Sprintf("GPU driver upgraded to %v", result["driver_after"]),
+		Data:     result,
+	})
+}
@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/gpu"
+)
+
+// GPU清理事件类型
+const (
+	EventGPUCleanupRemediated = "gpu.cleanup_remediated"
+	EventGPUCleanupFailed     = "gpu.cleanup_failed"
+)
+
+// CleanupGPUs 将claim释放的GPU标记为待清理，并在后台逐个确认
+func (a *Agent) CleanupGPUs(claimID string, gpuIDs []int) {
+	a.gpuMonitor.MarkPendingCleanup(gpuIDs)
+	for _, id := range gpuIDs {
+		a.wg.Add(1)
+		go func(gpuID int) {
+			defer a.wg.Done()
+			a.cleanupGPU(claimID, gpuID)
+		}(id)
+	}
+}
+
+// cleanupGPU 确认单个GPU已清理，执行补救时发布事件
+func (a *Agent) cleanupGPU(claimID string, gpuID int) {
+	a.mu.RLock()
+	cfg := a.config.GPUCleanup
+	a.mu.RUnlock()
+	policy := gpu.CleanupPolicy{
+		MaxMemoryMB:   cfg.MaxMemoryMB,
+		Timeout:       time.Duration(cfg.TimeoutSeconds) * time.Second,
+		KillProcesses: cfg.KillProcesses,
+		ResetGPU:      cfg.ResetGPU,
+	}
+
+	result := a.gpuMonitor.CleanupGPU(a.ctx, gpuID, policy)
+	if a.ctx.Err() != nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"gpu_id":         gpuID,
+		"memory_used_mb": result.MemoryUsedMB,
+	}
+	if len(result.Processes) > 0 {
+		data["processes"] = result.Processes
+	}
+	if len(result.KilledProcesses) > 0 {
+		data["killed_processes"] = result.KilledProcesses
+	}
+	if result.Reset {
+		data["reset"] = true
+	}
+	if result.Error != "" {
+		data["error"] = result.Error
+	}
+
+	switch {
+	case !result.Clean:
+		fmt.Printf("Warning: GPU %d is not clean after claim %s (memory used: %d MB, processes: %v), keeping it unavailable\n",
+			gpuID, claimID, result.MemoryUsedMB, result.Processes)
+		a.eventBus.Publish(events.Event{
+			Type:     EventGPUCleanupFailed,
+			Severity: events.SeverityError,
+			ClaimID:  claimID,
+			Message:  fmt.Sprintf("GPU %d still holds memory or processes of a previous claim and stays unavailable", gpuID),
+			Data:     data,
+		})
+	case result.Remediated():
+		a.eventBus.Publish(events.Event{
+			Type:     EventGPUCleanupRemediated,
+			Severity: events.SeverityWarning,
+			ClaimID:  claimID,
+			Message:  fmt.Sprintf("GPU %d was cleaned up after claim removal", gpuID),
+			Data:     data,
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"utopia-node-agent/internal/api"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/gpu"
+	"utopia-node-agent/internal/jobs"
+)
+
+// GPU驱动维护事件
+const (
+	EventGPUReattached     = "gpu.reattached"
+	EventGPUReattachFailed = "gpu.reattach_failed"
+)
+
+// defaultGPUReattachTimeout 等待驱动恢复的默认时限
+const defaultGPUReattachTimeout = 10 * time.Minute
+
+// ReattachGPUs 提交驱动重新加载的恢复任务
+func (a *Agent) ReattachGPUs(req api.GPUReattachRequest) (jobs.Job, error) {
+	a.mu.Lock()
+	if a.gpuMaintenance {
+		a.mu.Unlock()
+		return jobs.Job{}, api.ErrGPUMaintenanceRunning
+	}
+	a.gpuMaintenance = true
+	a.mu.Unlock()
+
+	timeout := defaultGPUReattachTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	expectReload := req.ExpectReload == nil || *req.ExpectReload
+
+	return a.jobManager.Submit("gpu.reattach", func(ctx context.Context, h *jobs.Handle) (map[string]interface{}, error) {
+		defer func() {
+			a.mu.Lock()
+			a.gpuMaintenance = false
+			a.mu.Unlock()
+		}()
+		result, err := a.reattachGPUs(ctx, h, driverPlan{expectReload: expectReload, timeout: timeout})
+		a.publishReattachEvent(result, err)
+		return result, err
+	}), nil
+}
+
+// driverPlan 驱动维护期间（容器已停止、NVML已关闭）执行的操作
+type driverPlan struct {
+	// 是否等待驱动被卸载或版本变化后再继续
+	expectReload bool
+	// 等待驱动恢复的时限
+	timeout time.Duration
+	// 由agent执行的驱动升级或重新加载操作，为nil时由外部完成
+	upgrade func(ctx context.Context) error
+	// 恢复后要求的驱动版本，为空时不校验
+	targetVersion string
+}
+
+// reattachGPUs 驱动重新加载的恢复流程：
+// 停止使用GPU的容器与MPS守护进程，关闭NVML（并执行升级操作）后等待驱动恢复，
+// 按UUID校验设备索引与次设备号未变化，再重启受影响的容器
+func (a *Agent) reattachGPUs(ctx context.Context, h *jobs.Handle, plan driverPlan) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+
+	// 1. 记录重新加载前的设备身份
+	h.SetProgress(0, "recording GPU identities")
+	before, err := a.gpuMonitor.Devices()
+	if err != nil {
+		// 驱动可能已经异常，退回到最近一次刷新的信息，此时无法校验次设备号
+		h.Log(fmt.Sprintf("NVML query failed, using last known GPU list: %v", err))
+		before = nil
+		for _, info := range a.gpuMonitor.GetGPUInfo() {
+			before = append(before, gpu.DeviceIdentity{Index: info.ID, UUID: info.UUID, Minor: -1})
+		}
+	}
+	previousVersion := gpu.DriverVersion()
+	result["devices_before"] = before
+	result["driver_before"] = previousVersion
+
+	// 2. 停止使用GPU的容器
+	h.SetProgress(10, "stopping GPU containers")
+	var stopped []string
+	for _, info := range a.containerManager.RunningGPUContainers() {
+		if err := a.containerManager.StopContainer(ctx, info.ID); err != nil {
+			h.Log(fmt.Sprintf("failed to stop %s: %v", shortID(info.ID), err))
+			result["stopped"] = stopped
+			return result, fmt.Errorf("failed to quiesce container %s: %w", shortID(info.ID), err)
+		}
+		h.Log(fmt.Sprintf("stopped %s (claim %s, GPUs %v)", shortID(info.ID), info.ClaimID, info.GPUIDs))
+		stopped = append(stopped, info.ID)
+	}
+	result["stopped"] = stopped
+	a.containerManager.StopAllMPS(ctx)
+
+	// 3. 关闭NVML并等待驱动恢复
+	if err := a.gpuMonitor.BeginMaintenance(); err != nil {
+		return result, err
+	}
+	// 升级失败时仍等待驱动恢复并重启容器，尽量恢复服务
+	var upgradeErr error
+	if plan.upgrade != nil {
+		h.SetProgress(20, "upgrading the driver")
+		if upgradeErr = plan.upgrade(ctx); upgradeErr != nil {
+			h.Log(fmt.Sprintf("driver upgrade failed: %v", upgradeErr))
+			result["upgrade_error"] = upgradeErr.Error()
+		}
+	}
+	if plan.expectReload {
+		h.SetProgress(30, "waiting for the driver to be reloaded")
+	} else {
+		h.SetProgress(30, "waiting for NVML")
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, plan.timeout)
+	err = a.gpuMonitor.WaitForDriver(waitCtx, previousVersion, plan.expectReload, len(before))
+	cancel()
+	a.gpuMonitor.EndMaintenance(err == nil)
+	if err != nil {
+		if upgradeErr != nil {
+			err = fmt.Errorf("%v (after driver upgrade failed: %v)", err, upgradeErr)
+		}
+		return result, fmt.Errorf("GPUs did not come back, %d container(s) left stopped: %w", len(stopped), err)
+	}
+	currentVersion := gpu.DriverVersion()
+	result["driver_after"] = currentVersion
+	h.Log("driver is back: " + currentVersion)
+
+	// 4. 校验设备身份：容器按索引绑定GPU，索引或次设备号变化时不能直接重启
+	h.SetProgress(70, "verifying GPU devices")
+	after, err := a.gpuMonitor.Devices()
+	if err != nil {
+		return result, fmt.Errorf("failed to query GPUs after reload: %w", err)
+	}
+	result["devices_after"] = after
+	changed := changedGPUs(before, after)
+	if len(changed) > 0 {
+		result["changed_gpus"] = changed
+		h.Log(fmt.Sprintf("GPU identity changed for GPU(s) %v", changed))
+	}
+
+	// 5. 重启设备未变化的容器
+	h.SetProgress(80, "restarting containers")
+	var restarted []string
+	failed := make(map[string]string)
+	for i, containerID := range stopped {
+		info, exists := a.containerManager.GetContainer(containerID)
+		switch {
+		case !exists:
+			failed[containerID] = "container no longer exists"
+		case usesAny(info.GPUIDs, changed):
+			failed[containerID] = "assigned GPU changed identity, the claim must be recreated"
+		default:
+			if err := a.containerManager.StartContainer(ctx, containerID); err != nil {
+				failed[containerID] = err.Error()
+			} else {
+				restarted = append(restarted, containerID)
+				h.Log(fmt.Sprintf("restarted %s", shortID(containerID)))
+			}
+		}
+		h.SetProgress(80+20*float64(i+1)/float64(len(stopped)), fmt.Sprintf("restarted %d/%d containers", len(restarted), len(stopped)))
+	}
+	result["restarted"] = restarted
+	if len(failed) > 0 {
+		result["failed"] = failed
+		return result, fmt.Errorf("%d container(s) could not be restarted", len(failed))
+	}
+	if upgradeErr != nil {
+		return result, fmt.Errorf("driver upgrade failed, service restored on driver %q: %w", currentVersion, upgradeErr)
+	}
+	if plan.targetVersion != "" && !strings.Contains(currentVersion, plan.targetVersion) {
+		return result, fmt.Errorf("driver %q does not match the target version %s", currentVersion, plan.targetVersion)
+	}
+	return result, nil
+}
+
+// changedGPUs 返回UUID对应的索引或次设备号发生变化（或消失）的原GPU索引
+func changedGPUs(before, after []gpu.DeviceIdentity) []int {
+	byUUID := make(map[string]gpu.DeviceIdentity, len(after))
+	for _, d := range after {
+		byUUID[d.UUID] = d
+	}
+
+	var changed []int
+	for _, d := range before {
+		current, exists := byUUID[d.UUID]
+		switch {
+		case !exists, current.Index != d.Index:
+			changed = append(changed, d.Index)
+		case d.Minor >= 0 && current.Minor != d.Minor:
+			changed = append(changed, d.Index)
+		default:
+			if _, err := os.Stat(fmt.Sprintf("/dev/nvidia%d", current.Minor)); err != nil {
+				changed = append(changed, d.Index)
+			}
+		}
+	}
+	return changed
+}
+
+// usesAny 判断容器是否使用了列表中的任一GPU
+func usesAny(gpuIDs, list []int) bool {
+	for _, id := range gpuIDs {
+		for _, other := range list {
+			if id == other {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// publishReattachEvent 发布GPU重新挂载结果事件
+func (a *Agent) publishReattachEvent(result map[string]interface{}, err error) {
+	if err != nil {
+		a.eventBus.Publish(events.Event{
+			Type:     EventGPUReattachFailed,
+			Severity: events.SeverityError,
+			Message:  fmt.Sprintf("GPU re-attach failed: %v", err),
+			Data:     result,
+		})
+		return
+	}
+	a.eventBus.Publish(events.Event{
+		Type:     EventGPUReattached,
+		Severity: events.SeverityInfo,
+		Message:  "GPUs re-attached after driver reload",
+		Data:     result,
+	})
+}
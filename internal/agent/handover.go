@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"utopia-node-agent/internal/api"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/frp"
+	"utopia-node-agent/internal/handover"
+)
+
+// 无中断重启事件类型
+const (
+	EventAgentRestarted     = "agent.restarted"
+	EventAgentRestartFailed = "agent.restart_failed"
+)
+
+// inheritHandover 读取旧agent进程交接的状态，不是由交接启动时为nil
+func (a *Agent) inheritHandover() error {
+	inherited, err := handover.Inherit()
+	if err != nil {
+		return err
+	}
+	if inherited != nil {
+		fmt.Printf("Taking over from agent process %d\n", inherited.PreviousPID())
+	}
+	a.inherited = inherited
+	return nil
+}
+
+// startOrAdoptFRP 接管旧agent进程交出的frpc，没有或已退出时启动新的frpc
+func (a *Agent) startOrAdoptFRP() error {
+	state, output := a.inherited.FRP()
+	if state == nil {
+		return a.frpManager.Start(a.ctx)
+	}
+	err := a.frpManager.Adopt(a.ctx, state, output)
+	if errors.Is(err, frp.ErrNotAdoptable) {
+		fmt.Printf("Warning: %v, starting a new frpc\n", err)
+		return a.frpManager.Start(a.ctx)
+	}
+	return err
+}
+
+// finishHandover 全部子系统启动后通知旧agent进程退出
+func (a *Agent) finishHandover() {
+	if a.inherited == nil {
+		return
+	}
+	a.mu.Lock()
+	a.handoverReady = true
+	a.mu.Unlock()
+
+	if err := a.inherited.Ready(); err != nil {
+		// 旧进程已退出，本进程照常运行
+		fmt.Printf("Warning: failed to notify previous agent process: %v\n", err)
+		return
+	}
+	fmt.Printf("Took over from agent process %d\n", a.inherited.PreviousPID())
+	a.eventBus.Publish(events.Event{
+		Type:     EventAgentRestarted,
+		Severity: events.SeverityInfo,
+		Message:  fmt.Sprintf("agent restarted without downtime (PID %d -> %d)", a.inherited.PreviousPID(), os.Getpid()),
+		Data: map[string]interface{}{
+			"previous_pid": a.inherited.PreviousPID(),
+			"pid":          os.Getpid(),
+			"version":      Version,
+		},
+	})
+}
+
+// Restart 启动新的agent进程并交接API监听套接字与frpc，立即返回，交接在后台执行
+// 新进程就绪后当前进程退出；新进程启动失败时当前进程继续运行
+func (a *Agent) Restart() error {
+	if !a.currentConfig().Handover.Enabled {
+		return api.ErrRestartUnsupported
+	}
+	a.mu.Lock()
+	if !a.started {
+		a.mu.Unlock()
+		return fmt.Errorf("agent is still starting")
+	}
+	if a.restarting || a.decommissioning || a.handedOver {
+		a.mu.Unlock()
+		return api.ErrRestartInProgress
+	}
+	a.restarting = true
+	a.mu.Unlock()
+
+	go a.runHandover()
+	return nil
+}
+
+// runHandover 执行交接，成功后请求当前进程退出
+func (a *Agent) runHandover() {
+	pid, err := a.handover()
+	if err != nil {
+		fmt.Printf("Warning: zero-downtime restart failed, keeping the current process: %v\n", err)
+		a.mu.Lock()
+		a.restarting = false
+		a.mu.Unlock()
+		a.eventBus.Publish(events.Event{
+			Type:     EventAgentRestartFailed,
+			Severity: events.SeverityError,
+			Message:  fmt.Sprintf("zero-downtime agent restart failed: %v", err),
+			Data:     map[string]interface{}{"error": err.Error()},
+		})
+		return
+	}
+
+	// systemd与PID 1模式的init进程改为监管新进程，当前进程退出不会结束服务
+	if err := handover.NotifySupervisor(pid); err != nil {
+		fmt.Printf("Warning: failed to register agent process %d with the service manager: %v\n", pid, err)
+	}
+	fmt.Printf("Handed over to agent process %d, exiting\n", pid)
+
+	a.mu.Lock()
+	a.handedOver = true
+	a.mu.Unlock()
+	a.closeDone()
+}
+
+// handover 登记监听套接字与frpc并启动新进程，返回就绪的新进程PID
+func (a *Agent) handover() (int, error) {
+	cfg := a.currentConfig()
+	builder := handover.NewBuilder()
+	if err := a.apiServer.HandoverListeners(builder); err != nil {
+		builder.Close()
+		return 0, err
+	}
+	if a.frpManager != nil {
+		state, output, err := a.frpManager.Detach()
+		if err != nil {
+			builder.Close()
+			return 0, err
+		}
+		if output != nil {
+			state.OutputFD = builder.Add(output)
+		}
+		builder.State.FRP = state
+	}
+
+	timeout := time.Duration(cfg.Handover.ReadyTimeoutSeconds) * time.Second
+	pid, err := builder.Spawn(a.ctx, cfg.DataDir, timeout)
+	if err != nil && a.frpManager != nil {
+		a.frpManager.Reattach()
+	}
+	return pid, err
+}
+
+// passiveStop 停止时是否把frpc、计费周期与温控设置留给另一个agent进程：
+// 已交给新进程，或由交接启动但尚未就绪（旧进程仍在运行）
+func (a *Agent) passiveStop() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.handedOver || (a.inherited != nil && !a.handoverReady)
+}
+
+// closeDone 请求agent退出，可重复调用
+func (a *Agent) closeDone() {
+	a.doneOnce.Do(func() { close(a.done) })
+}
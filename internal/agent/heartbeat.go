@@ -0,0 +1,345 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/gpu"
+	"utopia-node-agent/internal/logsample"
+	"utopia-node-agent/internal/registration"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxHeartbeatEvents 单次心跳携带的最大事件数
+const maxHeartbeatEvents = 100
+
+// sendHeartbeat 上报心跳，并应用响应中携带的配置补丁
+func (a *Agent) sendHeartbeat() error {
+	// 已通知平台下线，停止过程中的心跳会让平台误以为节点恢复
+	a.mu.RLock()
+	offline := a.offlineNotified
+	a.mu.RUnlock()
+	if offline {
+		return nil
+	}
+
+	systemMetrics, err := a.systemMonitor.GetSystemMetrics()
+	if err != nil {
+		systemMetrics = nil
+	}
+
+	// 关闭平台指标上报时只保留调度所需的GPU状态
+	gpus := a.gpuMonitor.GetGPUInfo()
+	if !a.currentConfig().Metrics.Platform {
+		systemMetrics = nil
+		for i := range gpus {
+			gpus[i].History = nil
+		}
+	}
+
+	a.mu.RLock()
+	req := &registration.HeartbeatRequest{
+		NodeID:                a.nodeID,
+		Timestamp:             time.Now().Unix(),
+		GPUs:                  gpus,
+		GPUUnavailableReason:  a.gpuMonitor.UnavailableReason(),
+		Runtimes:              a.containerManager.AvailableRuntimes(),
+		ExternalVolumeTypes:   a.containerManager.ExternalVolumeTypes(),
+		TimeSlicing:           a.containerManager.TimeSlicingStatus(),
+		EncryptionPublicKey:   a.encryptionPublicKey(),
+		IdentityPublicKey:     a.identity.PublicKey(),
+		System:                systemMetrics,
+		ContainerCount:        len(a.containerManager.ListContainers()),
+		Versions:              a.systemMonitor.GetVersions(false),
+		ConfigVersion:         a.appliedConfigVersion(),
+		RejectedConfigVersion: a.rejectedConfigVersion,
+		ConfigError:           a.configError,
+		Events:                a.eventBus.Since(a.eventCursor, maxHeartbeatEvents),
+		Labels:                a.config.NodeLabels,
+		SystemReserved:        a.systemReservation(),
+	}
+	a.mu.RUnlock()
+
+	resp, err := a.regClient.Heartbeat(a.ctx, req)
+	if err != nil {
+		return err
+	}
+
+	// 平台已收到的事件不再重复上报
+	if n := len(req.Events); n > 0 {
+		a.mu.Lock()
+		a.eventCursor = req.Events[n-1].Seq
+		a.mu.Unlock()
+	}
+
+	if resp.SigningKeys != "" {
+		a.applySigningKeys(resp.SigningKeys)
+	}
+	if resp.ConfigPatch != nil {
+		a.handleConfigPatch(resp.ConfigPatch)
+	}
+
+	return nil
+}
+
+// appliedConfigVersion 返回已应用的配置补丁版本（调用方需持有锁）
+func (a *Agent) appliedConfigVersion() int64 {
+	if a.overrides == nil {
+		return 0
+	}
+	return a.overrides.Version
+}
+
+// handleConfigPatch 验证、应用并持久化平台下发的配置补丁
+// 结果通过下一次心跳确认
+func (a *Agent) handleConfigPatch(patch *config.ConfigPatch) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// 已应用或已拒绝的版本不再处理
+	if patch.Version <= a.appliedConfigVersion() || patch.Version == a.rejectedConfigVersion {
+		return
+	}
+
+	// 环境变量与命令行参数优先于平台补丁，被覆盖的配置项只持久化而不生效
+	set, shadowed, err := a.layers.EffectivePatch(patch.Set)
+	if err != nil {
+		a.rejectPatch(patch.Version, err)
+		return
+	}
+	for key, source := range shadowed {
+		fmt.Printf("Config patch key %s is shadowed by %s and will not take effect\n", key, source)
+	}
+
+	newConfig, err := a.config.ApplyPatch(set)
+	if err != nil {
+		a.rejectPatch(patch.Version, err)
+		return
+	}
+
+	overrides := a.overrides.Merge(patch)
+	if err := config.SaveOverrides(a.config.OverridesPath(), overrides); err != nil {
+		a.rejectPatch(patch.Version, fmt.Errorf("failed to persist overrides: %w", err))
+		return
+	}
+
+	a.config = newConfig
+	a.overrides = overrides
+	a.rejectedConfigVersion = 0
+	a.configError = ""
+	a.applyRuntimeConfig()
+
+	fmt.Printf("Applied config patch version %d (%d keys)\n", patch.Version, len(patch.Set))
+}
+
+// rejectPatch 记录被拒绝的配置补丁（调用方需持有锁）
+func (a *Agent) rejectPatch(version int64, err error) {
+	a.rejectedConfigVersion = version
+	a.configError = err.Error()
+	fmt.Printf("Rejected config patch version %d: %v\n", version, err)
+}
+
+// applyRuntimeConfig 将当前配置中可热更新的部分应用到各子系统（调用方需持有锁）
+// 监控间隔由 runPeriodic 在下一周期读取，无需在此处理
+func (a *Agent) applyRuntimeConfig() {
+	applyLogLevel(a.config.LogLevel)
+	applyLogSampling(a.config.LogSampling)
+
+	if a.gpuMonitor != nil {
+		a.gpuMonitor.SetBusyPolicy(a.gpuBusyPolicy())
+	}
+	if a.containerManager != nil {
+		a.containerManager.UpdateOptions(a.containerOptions())
+	}
+	if a.accounting != nil {
+		a.accounting.SetLabels(a.config.NodeLabels)
+	}
+	if a.featureFlags != nil {
+		a.featureFlags.SetConfig(a.config.FeatureFlags)
+	}
+	if a.apiServer != nil {
+		a.apiServer.SetMetricsMaxAge(a.metricsMaxAge(a.config))
+	}
+}
+
+// containerNetwork 容器默认DNS选项，启用claim DNS时将节点本地解析器作为首选DNS服务器并添加搜索域
+func (a *Agent) containerNetwork() container.NetworkOptions {
+	network := container.NetworkOptions{
+		DNSServers: a.config.Container.DNSServers,
+		DNSSearch:  a.config.Container.DNSSearch,
+		ExtraHosts: a.config.Container.ExtraHosts,
+	}
+	if cfg := a.config.ClaimDNS; cfg.Enabled && cfg.ResolverAddress != "" {
+		network.DNSServers = append([]string{cfg.ResolverAddress}, network.DNSServers...)
+		network.DNSSearch = append(append([]string{}, network.DNSSearch...), cfg.Domain)
+	}
+	return network
+}
+
+// registryCredentials 返回registry.credentials中的节点仓库凭据
+func (a *Agent) registryCredentials() []container.RegistryAuth {
+	credentials := make([]container.RegistryAuth, 0, len(a.config.Registry.Credentials))
+	for _, credential := range a.config.Registry.Credentials {
+		credentials = append(credentials, container.RegistryAuth{
+			Server:   credential.Server,
+			Username: credential.Username,
+			Password: credential.Password,
+		})
+	}
+	return credentials
+}
+
+// containerOptions 根据当前配置生成容器管理器选项
+func (a *Agent) containerOptions() container.Options {
+	return container.Options{
+		DefaultStorageSizeGB: a.config.Container.DefaultStorageSizeGB,
+		Registry: container.RegistryAuth{
+			Server:   a.config.Registry.Server,
+			Username: a.config.Registry.Username,
+			Password: a.config.Registry.Password,
+		},
+		RepositoryPrefix:    a.config.Registry.RepositoryPrefix,
+		RegistryCredentials: a.registryCredentials(),
+		CrashLoop: container.CrashLoopPolicy{
+			MaxRestarts: a.config.Container.CrashLoopMaxRestarts,
+			Window:      time.Duration(a.config.Container.CrashLoopWindowMinutes) * time.Minute,
+		},
+		Network:            a.containerNetwork(),
+		RefreshConcurrency: a.config.Container.RefreshConcurrency,
+		CreateTimeout:      time.Duration(a.config.Container.CreateTimeoutSeconds) * time.Second,
+		HostnamePattern:    a.config.Container.HostnamePattern,
+		NodeID:             a.nodeID,
+		Security: container.SecurityPolicy{
+			Status:             a.securityStatus,
+			AllowedRelaxations: a.config.Security.AllowedRelaxations,
+		},
+		Command:             a.commandPolicy(),
+		MPSMaxClientsPerGPU: a.config.MPS.MaxClientsPerGPU,
+		UtilizationInterval: time.Duration(a.config.Monitor.ClaimGPUIntervalSeconds) * time.Second,
+		TimeSlicing:         a.timeSlicingPolicy(),
+		Overcommit: container.OvercommitPolicy{
+			CPURatio:           a.config.Overcommit.CPURatio,
+			MemoryRatio:        a.config.Overcommit.MemoryRatio,
+			GPUMemoryRatio:     a.config.Overcommit.GPUMemoryRatio,
+			DefaultCPUs:        a.config.Overcommit.DefaultCPUs,
+			DefaultMemoryMB:    a.config.Overcommit.DefaultMemoryMB,
+			DefaultGPUMemoryMB: a.config.Overcommit.DefaultGPUMemoryMB,
+		},
+		SystemReserved:     a.systemReservation(),
+		AllowedVolumeRoots: a.config.Container.AllowedVolumeRoots,
+		HibernateKeepGPUs:  a.config.Container.HibernateKeepGPUs,
+		Ulimits:            a.containerUlimits(),
+		IOLimits:           a.containerIOLimits(),
+		IODevice:           a.config.Container.IOLimits.Device,
+		Ports:              a.portPlan(),
+		Labels:             a.config.NodeLabels,
+		Build: container.BuildPolicy{
+			Enabled:         a.config.ImageBuild.Enabled,
+			CPUs:            a.config.ImageBuild.CPUs,
+			MemoryMB:        a.config.ImageBuild.MemoryMB,
+			Timeout:         time.Duration(a.config.ImageBuild.TimeoutSeconds) * time.Second,
+			MaxContextBytes: int64(a.config.ImageBuild.MaxContextMB) << 20,
+			AllowedGitHosts: a.config.ImageBuild.AllowedGitHosts,
+		},
+		Schedule: container.SchedulePolicy{
+			MaxAdvance:       time.Duration(a.config.Schedule.MaxAdvanceDays) * 24 * time.Hour,
+			MinFreeDiskBytes: uint64(a.config.Schedule.MinFreeDiskGB) << 30,
+			ReserveLead:      time.Duration(a.config.Schedule.ReserveLeadSeconds) * time.Second,
+		},
+	}
+}
+
+// containerUlimits 容器默认ulimit
+func (a *Agent) containerUlimits() map[string]container.Ulimit {
+	if len(a.config.Container.Ulimits) == 0 {
+		return nil
+	}
+	ulimits := make(map[string]container.Ulimit, len(a.config.Container.Ulimits))
+	for name, limit := range a.config.Container.Ulimits {
+		ulimits[name] = container.Ulimit{Soft: limit.Soft, Hard: limit.Hard}
+	}
+	return ulimits
+}
+
+// timeSlicingPolicy GPU时间片共享策略，未启用时份数为0
+func (a *Agent) timeSlicingPolicy() container.TimeSlicingPolicy {
+	if !a.config.TimeSlicing.Enabled {
+		return container.TimeSlicingPolicy{}
+	}
+	return container.TimeSlicingPolicy{
+		ReplicasPerGPU: a.config.TimeSlicing.ReplicasPerGPU,
+		MaxGPUs:        a.config.TimeSlicing.MaxGPUs,
+	}
+}
+
+// containerIOLimits 容器对工作区设备的默认读写限速
+func (a *Agent) containerIOLimits() container.IOLimits {
+	cfg := a.config.Container.IOLimits
+	return container.IOLimits{
+		ReadBPS:   cfg.ReadBPS,
+		WriteBPS:  cfg.WriteBPS,
+		ReadIOPS:  cfg.ReadIOPS,
+		WriteIOPS: cfg.WriteIOPS,
+	}
+}
+
+// portPlan 主机端口划分，容器端口分配与FRP GPU隧道共用
+func (a *Agent) portPlan() container.PortPlan {
+	cfg := a.config.Container.PortPlan
+	return container.PortPlan{
+		GPUBase:      cfg.GPUBase,
+		GPUStride:    cfg.GPUStride,
+		DynamicStart: cfg.DynamicStart,
+		DynamicEnd:   cfg.DynamicEnd,
+	}
+}
+
+// commandPolicy 根据当前配置生成容器命令策略，未启用时为空策略
+func (a *Agent) commandPolicy() container.CommandPolicy {
+	cfg := a.config.CommandPolicy
+	if !cfg.Enabled {
+		return container.CommandPolicy{}
+	}
+	policy, err := container.NewCommandPolicy(cfg.DenyPatterns, cfg.AllowPatterns)
+	if err != nil {
+		fmt.Printf("Warning: command policy disabled: %v\n", err)
+	}
+	return policy
+}
+
+// systemReservation 为宿主机系统与Agent保留的资源
+func (a *Agent) systemReservation() container.SystemReservation {
+	return container.SystemReservation{
+		CPUs:      a.config.SystemReserved.CPUs,
+		MemoryMB:  a.config.SystemReserved.MemoryMB,
+		DiskBytes: uint64(a.config.SystemReserved.DiskGB) << 30,
+	}
+}
+
+// gpuBusyPolicy 根据当前配置生成GPU忙碌判定策略
+func (a *Agent) gpuBusyPolicy() gpu.BusyPolicy {
+	return gpu.BusyPolicy{
+		MemoryPercent:      a.config.GPU.BusyMemoryPercent,
+		UtilizationPercent: a.config.GPU.BusyUtilizationPercent,
+		Window:             time.Duration(a.config.GPU.BusyWindowSeconds) * time.Second,
+	}
+}
+
+// applyLogLevel 设置全局日志级别
+func applyLogLevel(level string) {
+	if parsed, err := logrus.ParseLevel(level); err == nil {
+		logrus.SetLevel(parsed)
+	}
+}
+
+// applyLogSampling 设置全局日志限流策略
+func applyLogSampling(cfg config.LogSamplingConfig) {
+	logsample.Default().SetPolicy(logsample.Policy{
+		Enabled: cfg.Enabled,
+		Window:  time.Duration(cfg.WindowSeconds) * time.Second,
+		Burst:   cfg.Burst,
+	})
+}
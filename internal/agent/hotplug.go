@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"fmt"
+
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/gpu"
+)
+
+// EventGPUDevicesChanged GPU被热插拔，数量或索引发生变化
+const EventGPUDevicesChanged = "gpu.devices_changed"
+
+// handleGPUDeviceChange GPU增减后按新索引刷新容器的GPU占用、通知平台，并重建隧道与上报心跳
+// 由GPU刷新同步调用：返回后监控器按刷新后的容器信息重新判定GPU占用
+func (a *Agent) handleGPUDeviceChange(change gpu.DeviceChange) {
+	fmt.Printf("Warning: GPU devices changed: %d -> %d (added %d, removed %d, renumbered %d)\n",
+		change.PreviousCount, change.Count, len(change.Added), len(change.Removed), len(change.Moved))
+
+	if err := a.containerManager.RefreshContainers(a.ctx); err != nil {
+		fmt.Printf("Warning: failed to refresh containers after GPU change: %v\n", err)
+	}
+
+	// 不自动停止受影响的容器：运行中的容器仍持有原来的设备，由平台决定是否重新创建claim
+	var affected []map[string]interface{}
+	for _, info := range a.containerManager.ContainersOnChangedGPUs(change) {
+		affected = append(affected, map[string]interface{}{
+			"claim_id":         info.ClaimID,
+			"container_id":     info.ID,
+			"status":           info.Status,
+			"recorded_gpu_ids": info.Labels["utopia.gpu_ids"],
+			"gpu_ids":          info.GPUIDs,
+		})
+	}
+
+	severity := events.SeverityInfo
+	if len(change.Removed) > 0 || len(affected) > 0 {
+		severity = events.SeverityWarning
+	}
+	a.eventBus.Publish(events.Event{
+		Type:     EventGPUDevicesChanged,
+		Severity: severity,
+		Message:  fmt.Sprintf("GPU count changed from %d to %d", change.PreviousCount, change.Count),
+		Data: map[string]interface{}{
+			"previous_count":  change.PreviousCount,
+			"count":           change.Count,
+			"added":           change.Added,
+			"removed":         change.Removed,
+			"moved":           change.Moved,
+			"affected_claims": affected,
+		},
+	})
+
+	// 隧道按GPU数量生成；重启frpc较慢，不阻塞GPU刷新
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		if change.Count != change.PreviousCount {
+			a.restartFRPWithCurrentConfig()
+		}
+		a.requestHeartbeat()
+	}()
+}
+
+// requestHeartbeat 请求心跳任务立即上报一次，已有未处理的请求时忽略
+func (a *Agent) requestHeartbeat() {
+	select {
+	case a.heartbeatWake <- struct{}{}:
+	default:
+	}
+}
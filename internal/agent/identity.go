@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"fmt"
+
+	"utopia-node-agent/internal/identity"
+)
+
+// loadIdentity 加载身份文件，文件不存在时返回nil
+// 只有节点ID的旧版文件升级为带密钥对的身份，私钥的加密状态与配置不一致时按配置重新保存
+func (a *Agent) loadIdentity() (*identity.Identity, error) {
+	key, err := a.identityKey()
+	if err != nil {
+		return nil, err
+	}
+	path := a.config.IdentityFilePath
+	id, err := identity.Load(path, key)
+	if err != nil || id == nil || id.NodeID == "" {
+		return id, err
+	}
+
+	switch {
+	case !id.HasKey():
+		if err := id.GenerateKey(); err != nil {
+			return nil, err
+		}
+		fmt.Printf("Upgrading identity file %s to a keypair bundle; the public key is registered with the next heartbeat\n", path)
+	case id.Encrypted() == (key != nil):
+		return id, nil
+	}
+	if err := id.Save(path, key); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// saveIdentity 按当前配置保存身份文件
+func (a *Agent) saveIdentity(id *identity.Identity) error {
+	key, err := a.identityKey()
+	if err != nil {
+		return err
+	}
+	return id.Save(a.currentConfig().IdentityFilePath, key)
+}
+
+// identityKey 返回身份文件私钥的加密密钥，未启用加密时为nil
+func (a *Agent) identityKey() ([]byte, error) {
+	cfg := a.currentConfig().Identity
+	if !cfg.EncryptPrivateKey {
+		return nil, nil
+	}
+	return identity.EncryptionKey(cfg.KeyFile)
+}
+
+// setIdentity 切换节点身份，之后发往平台的请求以新密钥签名
+func (a *Agent) setIdentity(id *identity.Identity) {
+	a.mu.Lock()
+	a.identity = id
+	a.nodeID = id.NodeID
+	a.mu.Unlock()
+	a.regClient.SetIdentity(id)
+}
+
+// IdentityPublicKey 返回节点身份公钥
+func (a *Agent) IdentityPublicKey() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.identity.PublicKey()
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"time"
+
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/container"
+)
+
+// claimIdleTask 定期检查claim的活动信号，按平台策略警告或停止空闲的claim
+func (a *Agent) claimIdleTask() {
+	a.runPeriodic("claim_idle", func(c *config.Config) int { return c.IdleShutdown.IntervalSeconds }, a.checkClaimIdle)
+}
+
+// coreDumpTask 定期检查容器的核心转储，发布新转储事件并按总量上限清理
+func (a *Agent) coreDumpTask() {
+	a.runPeriodic("core_dumps", func(c *config.Config) int { return c.Monitor.ContainerIntervalSeconds }, a.containerManager.CollectCoreDumps)
+}
+
+// runtimeLimitTask 定期检查claim的运行时长上限，到期前警告，到期后停止并删除容器
+func (a *Agent) runtimeLimitTask() {
+	a.runPeriodic("runtime_limit", func(c *config.Config) int { return c.RuntimeLimit.IntervalSeconds }, a.checkRuntimeLimits)
+}
+
+// checkRuntimeLimits 按当前配置检查一次claim运行时长上限
+func (a *Agent) checkRuntimeLimits() error {
+	cfg := a.currentConfig().RuntimeLimit
+	policy := container.RuntimeLimitPolicy{
+		GracePeriod: time.Duration(cfg.GracePeriodSeconds) * time.Second,
+	}
+	for _, minutes := range cfg.WarningMinutes {
+		policy.Warnings = append(policy.Warnings, time.Duration(minutes)*time.Minute)
+	}
+	return a.containerManager.CheckRuntimeLimits(a.ctx, policy)
+}
+
+// checkClaimIdle 按当前配置检查一次claim空闲状态
+func (a *Agent) checkClaimIdle() error {
+	cfg := a.currentConfig().IdleShutdown
+	// GPU维护期间没有可信的利用率，跳过检查
+	if !cfg.Enabled || a.gpuMonitor.InMaintenance() {
+		return nil
+	}
+
+	policy := container.IdlePolicy{
+		IdleAfter:               time.Duration(cfg.IdleMinutes) * time.Minute,
+		GPUUtilizationPercent:   cfg.GPUUtilizationPercent,
+		NetworkRxBytesPerMinute: uint64(cfg.NetworkRxKBPerMinute) * 1024,
+	}
+	if cfg.Action == config.IdleActionStop {
+		policy.StopAfter = policy.IdleAfter + time.Duration(cfg.StopGraceMinutes)*time.Minute
+	}
+	return a.containerManager.CheckIdle(a.ctx, policy, func(gpuID int) (float64, bool) {
+		info, ok := a.gpuMonitor.GetGPUByID(gpuID)
+		return info.UsagePercent, ok
+	})
+}
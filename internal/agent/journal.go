@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/events"
+)
+
+// 中断操作恢复事件类型
+const (
+	EventOperationRecovered      = "agent.operation_recovered"
+	EventOperationRecoveryFailed = "agent.operation_recovery_failed"
+)
+
+// recoverInterruptedOperations 完成或回滚上次运行中断的容器操作并发布结果
+// 恢复失败的操作保留在日志中，下次启动时重试
+func (a *Agent) recoverInterruptedOperations() {
+	results, err := a.containerManager.RecoverIntents(a.ctx)
+	if err != nil {
+		fmt.Printf("Warning: failed to recover interrupted operations: %v\n", err)
+		return
+	}
+
+	for _, result := range results {
+		claims := strings.Join(result.ClaimIDs, ",")
+		data := map[string]interface{}{
+			"op":         result.Op,
+			"claim_ids":  result.ClaimIDs,
+			"outcome":    result.Outcome,
+			"started_at": result.StartedAt,
+		}
+		if result.ContainerID != "" {
+			data["container_id"] = result.ContainerID
+		}
+		if len(result.Containers) > 0 {
+			data["containers"] = result.Containers
+		}
+
+		event := events.Event{
+			Type:     EventOperationRecovered,
+			Severity: events.SeverityWarning,
+			Message:  fmt.Sprintf("Interrupted %s operation for claim %s: %s", result.Op, claims, result.Outcome),
+			Data:     data,
+		}
+		if len(result.ClaimIDs) == 1 {
+			event.ClaimID = result.ClaimIDs[0]
+		}
+		if result.Outcome == container.RecoveryNoop {
+			event.Severity = events.SeverityInfo
+		}
+		if result.Outcome == container.RecoveryFailed {
+			data["error"] = result.Error
+			event.Type = EventOperationRecoveryFailed
+			event.Severity = events.SeverityError
+			fmt.Printf("Warning: failed to recover interrupted %s operation for claim %s: %s\n", result.Op, claims, result.Error)
+		} else {
+			fmt.Printf("Recovered interrupted %s operation for claim %s: %s %v\n", result.Op, claims, result.Outcome, result.Containers)
+		}
+		a.eventBus.Publish(event)
+	}
+}
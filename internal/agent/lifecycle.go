@@ -0,0 +1,194 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"utopia-node-agent/internal/lifecycle"
+)
+
+// 各子系统停止的时限
+const (
+	apiDrainTimeout       = 10 * time.Second
+	backgroundStopTimeout = 15 * time.Second
+	jobsStopTimeout       = 10 * time.Second
+	subsystemStopTimeout  = 5 * time.Second
+	// frpc在SIGTERM后10秒仍未退出才被SIGKILL
+	frpStopTimeout = 15 * time.Second
+)
+
+// registerStages 注册Agent的子系统及其依赖
+// 停止顺序与启动相反：先停止接受请求并等待处理中的请求，再停止后台任务与异步任务，
+// 最后关闭这些任务使用的FRP、容器管理器、监控器与链路追踪
+func (a *Agent) registerStages() {
+	a.lifecycle.Add(lifecycle.Stage{
+		Name: "secrets",
+		Start: func(context.Context) error {
+			if !a.config.Secrets.Enabled {
+				return nil
+			}
+			return a.loadSecretsKeypair()
+		},
+	})
+	a.lifecycle.Add(lifecycle.Stage{
+		Name:      "bootstrap",
+		DependsOn: []string{"secrets"},
+		Start:     a.bootstrap,
+	})
+	a.lifecycle.Add(lifecycle.Stage{
+		Name:      "signing_keys",
+		DependsOn: []string{"bootstrap"},
+		Start: func(context.Context) error {
+			if a.config.CentralPlatform.SigningPublicKey == "" {
+				return nil
+			}
+			return a.initializeSigningKeys()
+		},
+	})
+	a.lifecycle.Add(lifecycle.Stage{
+		Name:      "tracing",
+		DependsOn: []string{"bootstrap"},
+		Start:     func(context.Context) error { return a.initializeTracing() },
+		Stop: func(ctx context.Context) error {
+			if a.shutdownTracing == nil {
+				return nil
+			}
+			return a.shutdownTracing(ctx)
+		},
+		StopTimeout: subsystemStopTimeout,
+	})
+	a.lifecycle.Add(lifecycle.Stage{
+		Name:      "monitors",
+		DependsOn: []string{"tracing"},
+		Start:     func(context.Context) error { return a.initializeMonitors() },
+		Stop: func(context.Context) error {
+			if a.gpuMonitor == nil {
+				return nil
+			}
+			if !a.passiveStop() {
+				a.restoreThermalControls()
+			}
+			return a.gpuMonitor.Close()
+		},
+		StopTimeout: subsystemStopTimeout,
+	})
+	a.lifecycle.Add(lifecycle.Stage{
+		Name:      "containers",
+		DependsOn: []string{"monitors"},
+		Start:     func(context.Context) error { return a.initializeContainerManager() },
+		Stop: func(context.Context) error {
+			if a.containerManager == nil {
+				return nil
+			}
+			return a.containerManager.Close()
+		},
+		StopTimeout: subsystemStopTimeout,
+	})
+	a.lifecycle.Add(lifecycle.Stage{
+		Name:      "accounting",
+		DependsOn: []string{"containers"},
+		Start: func(context.Context) error {
+			if !a.config.Accounting.Enabled {
+				return nil
+			}
+			return a.initializeAccounting()
+		},
+		// 结算当前计费周期，记录在下次启动后上传；已交给新进程时由新进程继续当前周期
+		Stop: func(context.Context) error {
+			if a.accounting == nil || a.passiveStop() {
+				return nil
+			}
+			return a.accounting.Flush()
+		},
+		StopTimeout: subsystemStopTimeout,
+	})
+	a.lifecycle.Add(lifecycle.Stage{
+		Name:      "metrics",
+		DependsOn: []string{"monitors", "containers"},
+		Start:     func(context.Context) error { return a.initializeMetrics() },
+	})
+	a.lifecycle.Add(lifecycle.Stage{
+		Name:      "log_shipping",
+		DependsOn: []string{"containers"},
+		Start: func(context.Context) error {
+			if !a.config.LogShipping.Enabled {
+				return nil
+			}
+			return a.initializeLogShipping()
+		},
+	})
+	a.lifecycle.Add(lifecycle.Stage{
+		Name:      "frp",
+		DependsOn: []string{"containers"},
+		Start:     func(context.Context) error { return a.startFRP() },
+		Stop: func(context.Context) error {
+			if a.frpManager == nil || a.passiveStop() {
+				return nil
+			}
+			err := a.frpManager.Stop()
+			if cleanupErr := a.frpManager.CleanupConfig(); err == nil {
+				err = cleanupErr
+			}
+			return err
+		},
+		StopTimeout: frpStopTimeout,
+	})
+	// 恢复上次运行中断的容器操作（需在端口发布与钩子就绪后、接受请求前执行）
+	a.lifecycle.Add(lifecycle.Stage{
+		Name:      "recovery",
+		DependsOn: []string{"containers", "frp"},
+		Start: func(context.Context) error {
+			a.recoverInterruptedOperations()
+			return nil
+		},
+	})
+	// API服务器与其使用的异步任务，停止时取消运行中的任务并等待其返回
+	a.lifecycle.Add(lifecycle.Stage{
+		Name:      "api",
+		DependsOn: []string{"containers", "monitors", "frp", "signing_keys"},
+		Start:     func(context.Context) error { return a.configureAPIServer() },
+		Stop: func(ctx context.Context) error {
+			if a.jobManager == nil {
+				return nil
+			}
+			return a.jobManager.Shutdown(ctx)
+		},
+		StopTimeout: jobsStopTimeout,
+	})
+	// 后台任务与凭据文件监视，停止时取消Agent上下文并等待全部goroutine返回
+	a.lifecycle.Add(lifecycle.Stage{
+		Name:      "background_tasks",
+		DependsOn: []string{"api", "metrics", "log_shipping", "accounting"},
+		Start: func(context.Context) error {
+			a.startBackgroundTasks()
+			if err := a.startCredentialWatcher(); err != nil {
+				fmt.Printf("Warning: credential files will not be reloaded automatically: %v\n", err)
+			}
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			a.cancel()
+			done := make(chan struct{})
+			go func() {
+				a.wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return fmt.Errorf("background tasks did not stop: %w", ctx.Err())
+			}
+		},
+		StopTimeout: backgroundStopTimeout,
+	})
+	// 最后开始接受请求、最先停止：关闭监听并等待处理中的请求完成
+	a.lifecycle.Add(lifecycle.Stage{
+		Name:        "api_listener",
+		DependsOn:   []string{"api", "recovery", "background_tasks"},
+		Start:       a.serveAPI,
+		Stop:        a.drainAPI,
+		StopTimeout: apiDrainTimeout,
+	})
+}
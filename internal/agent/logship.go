@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/logship"
+)
+
+// initializeLogShipping 创建容器日志转发器
+func (a *Agent) initializeLogShipping() error {
+	cfg := a.config.LogShipping
+
+	var names []string
+	var sinks []logship.Sink
+	if cfg.Platform {
+		names = append(names, "platform")
+		sinks = append(sinks, platformLogSink{a})
+	}
+	for _, sinkCfg := range cfg.Sinks {
+		sink, err := newLogSink(sinkCfg)
+		if err != nil {
+			return fmt.Errorf("failed to create log sink %s: %w", sinkCfg.Type, err)
+		}
+		name := sinkCfg.Name
+		if name == "" {
+			name = sinkCfg.Type
+		}
+		names = append(names, name)
+		sinks = append(sinks, sink)
+	}
+
+	forwarder, err := logship.NewForwarder(a.NodeID, names, sinks, logship.Options{
+		MaxLineBytes:            cfg.MaxLineBytes,
+		RateLimitLinesPerSecond: cfg.RateLimitLinesPerSecond,
+		BatchSize:               cfg.BatchSize,
+		QueueSize:               cfg.QueueSize,
+		FlushInterval:           time.Duration(cfg.FlushIntervalSeconds) * time.Second,
+	}, filepath.Join(a.config.DataDir, "logship"))
+	if err != nil {
+		return err
+	}
+	a.logForwarder = forwarder
+	fmt.Printf("Log shipping enabled: %s\n", strings.Join(names, ", "))
+	return nil
+}
+
+// newLogSink 创建单个日志输出
+func newLogSink(cfg config.LogSinkConfig) (logship.Sink, error) {
+	switch cfg.Type {
+	case "loki":
+		return logship.NewLokiSink(cfg.URL, cfg.TenantID, cfg.Headers), nil
+	case "cloudwatch":
+		return logship.NewCloudWatchSink(cfg.Region, cfg.LogGroup, cfg.Endpoint, logship.CloudWatchCredentials{
+			AccessKeyID:     cfg.AccessKeyID,
+			SecretAccessKey: cfg.SecretAccessKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown log sink type %q", cfg.Type)
+	}
+}
+
+// logShippingTask 定期为新启动的容器开始转发日志
+func (a *Agent) logShippingTask() {
+	a.runPeriodic("log_shipping", func(c *config.Config) int { return c.LogShipping.SyncIntervalSeconds }, func() error {
+		containers := a.containerManager.ListContainers()
+		targets := make([]logship.Target, 0, len(containers))
+		for _, c := range containers {
+			targets = append(targets, logship.Target{
+				ContainerID: c.ID,
+				ClaimID:     c.ClaimID,
+				Running:     c.Status == "running",
+				Created:     c.Created,
+			})
+		}
+		a.logForwarder.Sync(a.ctx, targets)
+		return nil
+	})
+}
+
+// LogShipping 返回日志转发状态，未启用时为nil
+func (a *Agent) LogShipping() *logship.Status {
+	if a.logForwarder == nil {
+		return nil
+	}
+	status := a.logForwarder.Status()
+	return &status
+}
+
+// platformLogSink 将日志上报到平台
+type platformLogSink struct {
+	a *Agent
+}
+
+// Type 输出类型
+func (s platformLogSink) Type() string { return "platform" }
+
+// Write 上报一批日志
+func (s platformLogSink) Write(ctx context.Context, nodeID string, entries []logship.Entry) error {
+	return s.a.regClient.ShipLogs(ctx, nodeID, entries)
+}
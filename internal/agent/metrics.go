@@ -0,0 +1,253 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/metrics"
+	"utopia-node-agent/internal/system"
+)
+
+// initializeMetrics 根据配置创建指标输出管道，未配置输出时不创建
+func (a *Agent) initializeMetrics() error {
+	cfg := a.config.Metrics
+	if len(cfg.Sinks) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(cfg.Sinks))
+	sinks := make([]metrics.Sink, 0, len(cfg.Sinks))
+	for _, sinkCfg := range cfg.Sinks {
+		sink, err := newMetricsSink(sinkCfg)
+		if err != nil {
+			return fmt.Errorf("failed to create metrics sink %s: %w", sinkCfg.Type, err)
+		}
+		name := sinkCfg.Name
+		if name == "" {
+			name = sinkCfg.Type
+		}
+		names = append(names, name)
+		sinks = append(sinks, sink)
+	}
+
+	a.metricsPipeline = metrics.NewPipeline(names, sinks, metrics.Options{
+		BatchSize:     cfg.BatchSize,
+		QueueSize:     cfg.QueueSize,
+		FlushInterval: time.Duration(cfg.IntervalSeconds) * time.Second,
+	})
+	fmt.Printf("Metrics sinks enabled: %s\n", strings.Join(names, ", "))
+	return nil
+}
+
+// newMetricsSink 创建单个指标输出
+func newMetricsSink(cfg config.MetricsSinkConfig) (metrics.Sink, error) {
+	switch cfg.Type {
+	case "influxdb":
+		return metrics.NewInfluxDBSink(cfg.URL, cfg.Org, cfg.Bucket, cfg.Token)
+	case "otlp":
+		return metrics.NewOTLPSink(cfg.Endpoint, cfg.Headers, "utopia-node-agent"), nil
+	case "statsd":
+		return metrics.NewStatsdSink(cfg.Address, cfg.Prefix), nil
+	default:
+		return nil, fmt.Errorf("unknown metrics sink type %q", cfg.Type)
+	}
+}
+
+// metricsTask 定期采集节点、GPU与容器指标并推送到各输出
+func (a *Agent) metricsTask() {
+	interval := func(c *config.Config) int {
+		return a.samplingInterval(c.Monitor.Adaptive, c.Metrics.IntervalSeconds)
+	}
+	a.runPeriodic("metrics", interval, func() error {
+		a.metricsPipeline.Publish(a.collectMetrics())
+		return nil
+	})
+}
+
+// collectMetrics 采集一次指标
+func (a *Agent) collectMetrics() []metrics.Point {
+	now := time.Now()
+	nodeID := a.NodeID()
+	containers := a.containerManager.ListContainers()
+
+	var points []metrics.Point
+
+	nodeFields := map[string]float64{"containers": float64(len(containers))}
+	var host *system.HostMetrics
+	if sys, err := a.systemMonitor.GetSystemMetrics(); err == nil {
+		nodeFields["cpu_usage_percent"] = sys.CPUUsagePercent
+		nodeFields["memory_usage_percent"] = sys.MemoryUsagePercent
+		nodeFields["memory_total_mb"] = float64(sys.MemoryTotalMB)
+		nodeFields["memory_used_mb"] = float64(sys.MemoryUsedMB)
+		nodeFields["disk_usage_percent"] = sys.DiskUsagePercent
+		nodeFields["load_average"] = sys.LoadAverage
+		host = sys.Host
+	}
+	if host != nil {
+		addHostNodeFields(nodeFields, host)
+	}
+	points = append(points, metrics.Point{
+		Name:   "utopia_node",
+		Tags:   map[string]string{"node_id": nodeID},
+		Fields: nodeFields,
+		Time:   now,
+	})
+	if host != nil {
+		points = append(points, hostPoints(nodeID, host, now)...)
+	}
+
+	for _, g := range a.gpuMonitor.GetGPUInfo() {
+		busy := 0.0
+		if g.Busy {
+			busy = 1
+		}
+		throttled := 0.0
+		if g.ThermalThrottled {
+			throttled = 1
+		}
+		fields := map[string]float64{
+			"utilization_percent": g.UsagePercent,
+			"memory_used_mb":      float64(g.MemoryUsedMB),
+			"memory_total_mb":     float64(g.MemoryTotalMB),
+			"temperature_c":       float64(g.TemperatureC),
+			"power_draw_w":        float64(g.PowerDrawW),
+			"power_limit_w":       float64(g.PowerLimitW),
+			"thermal_throttled":   throttled,
+			"busy":                busy,
+		}
+		for fan, speed := range g.FanSpeedsPercent {
+			fields["fan"+strconv.Itoa(fan)+"_speed_percent"] = float64(speed)
+		}
+		points = append(points, metrics.Point{
+			Name: "utopia_gpu",
+			Tags: map[string]string{
+				"node_id": nodeID,
+				"gpu_id":  strconv.Itoa(g.ID),
+				"uuid":    g.UUID,
+				"name":    g.Name,
+			},
+			Fields: fields,
+			Time:   now,
+		})
+	}
+
+	for _, c := range containers {
+		running := 0.0
+		if c.Status == "running" {
+			running = 1
+		}
+		points = append(points, metrics.Point{
+			Name: "utopia_container",
+			Tags: map[string]string{
+				"node_id":      nodeID,
+				"claim_id":     c.ClaimID,
+				"container_id": shortID(c.ID),
+			},
+			Fields: map[string]float64{
+				"running":       running,
+				"gpu_count":     float64(len(c.GPUIDs)),
+				"restart_count": float64(c.RestartCount),
+			},
+			Time: now,
+		})
+	}
+
+	for _, img := range a.containerManager.ImageLatencies() {
+		lazy := 0.0
+		if img.LastPullLazy {
+			lazy = 1
+		}
+		points = append(points, metrics.Point{
+			Name: "utopia_image",
+			Tags: map[string]string{"node_id": nodeID, "image": img.Image},
+			Fields: map[string]float64{
+				"pulls":              float64(img.Pulls),
+				"last_pull_seconds":  img.LastPullSeconds,
+				"avg_pull_seconds":   img.AvgPullSeconds,
+				"last_pull_lazy":     lazy,
+				"starts":             float64(img.Starts),
+				"last_start_seconds": img.LastStartSeconds,
+				"avg_start_seconds":  img.AvgStartSeconds,
+			},
+			Time: now,
+		})
+	}
+
+	return points
+}
+
+// addHostNodeFields 将PSI与大页内存加入节点指标
+func addHostNodeFields(fields map[string]float64, host *system.HostMetrics) {
+	if p := host.Pressure; p != nil {
+		for name, stats := range map[string]*system.PressureStats{"cpu": p.CPU, "memory": p.Memory, "io": p.IO} {
+			if stats == nil {
+				continue
+			}
+			fields["pressure_"+name+"_some_avg10"] = stats.SomeAvg10
+			fields["pressure_"+name+"_some_avg60"] = stats.SomeAvg60
+			fields["pressure_"+name+"_full_avg10"] = stats.FullAvg10
+			fields["pressure_"+name+"_full_avg60"] = stats.FullAvg60
+		}
+	}
+	if h := host.HugePages; h != nil && h.Total > 0 {
+		fields["hugepages_total"] = float64(h.Total)
+		fields["hugepages_free"] = float64(h.Free)
+		fields["hugepages_reserved"] = float64(h.Reserved)
+	}
+}
+
+// hostPoints 生成块设备IO与网卡指标
+func hostPoints(nodeID string, host *system.HostMetrics, now time.Time) []metrics.Point {
+	var points []metrics.Point
+	for _, d := range host.Disks {
+		points = append(points, metrics.Point{
+			Name: "utopia_host_disk",
+			Tags: map[string]string{"node_id": nodeID, "device": d.Device},
+			Fields: map[string]float64{
+				"read_iops":           d.ReadIOPS,
+				"write_iops":          d.WriteIOPS,
+				"read_bytes_per_sec":  d.ReadBytesPerSec,
+				"write_bytes_per_sec": d.WriteBytesPerSec,
+				"utilization_percent": d.UtilizationPercent,
+				"await_ms":            d.AwaitMs,
+				"in_flight":           float64(d.InFlight),
+			},
+			Time: now,
+		})
+	}
+	for _, n := range host.Network {
+		points = append(points, metrics.Point{
+			Name: "utopia_host_net",
+			Tags: map[string]string{"node_id": nodeID, "interface": n.Interface},
+			Fields: map[string]float64{
+				"rx_bytes_per_sec": n.RxBytesPerSec,
+				"tx_bytes_per_sec": n.TxBytesPerSec,
+				"rx_errors":        float64(n.RxErrors),
+				"tx_errors":        float64(n.TxErrors),
+				"rx_dropped":       float64(n.RxDropped),
+				"tx_dropped":       float64(n.TxDropped),
+			},
+			Time: now,
+		})
+	}
+	return points
+}
+
+// MetricsSinks 返回指标输出的运行状态，未启用时为nil
+func (a *Agent) MetricsSinks() []metrics.SinkStatus {
+	if a.metricsPipeline == nil {
+		return nil
+	}
+	return a.metricsPipeline.Status()
+}
+
+// shortID 返回容器ID的前12位
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"utopia-node-agent/internal/api"
+	"utopia-node-agent/internal/system"
+)
+
+// BootInfo 返回本次系统启动的时间与原因
+func (a *Agent) BootInfo() *system.BootInfo {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.bootInfo
+}
+
+// detectBoot 判断并记录本次启动原因
+func (a *Agent) detectBoot() {
+	info, err := system.DetectBoot(a.config.DataDir)
+	if err != nil {
+		fmt.Printf("Warning: failed to record boot info: %v\n", err)
+	}
+	if info == nil {
+		return
+	}
+
+	a.mu.Lock()
+	a.bootInfo = info
+	a.mu.Unlock()
+
+	fmt.Printf("Last boot reason: %s (boot time: %s)\n", info.Reason, time.Unix(info.BootTime, 0).Format(time.RFC3339))
+}
+
+// PowerAction 在delay后执行电源操作
+// 重启与关机在等待期间排空节点，并预先记录意图以便下次启动时上报原因
+func (a *Agent) PowerAction(action string, force bool, delay time.Duration, reason string) error {
+	command := a.powerCommand(action)
+	if len(command) == 0 {
+		return fmt.Errorf("%w: %s", api.ErrPowerActionUnsupported, action)
+	}
+
+	if !force {
+		if running := a.runningContainerCount(); running > 0 {
+			return fmt.Errorf("%w: %d running container(s)", api.ErrActiveClaims, running)
+		}
+	}
+
+	a.mu.Lock()
+	if a.powerPending {
+		a.mu.Unlock()
+		return api.ErrPowerActionPending
+	}
+	a.powerPending = true
+	restart := action != api.PowerActionSuspend
+	if restart {
+		a.draining = true
+	}
+	a.mu.Unlock()
+
+	if restart {
+		intent := system.PowerIntent{Action: action, Reason: reason, RequestedAt: time.Now().Unix()}
+		if err := system.RecordPowerIntent(a.config.DataDir, intent); err != nil {
+			fmt.Printf("Warning: failed to record power intent: %v\n", err)
+		}
+	}
+
+	go a.runPowerAction(action, command, delay)
+	return nil
+}
+
+// runPowerAction 等待延迟后执行电源命令，失败时恢复节点状态
+func (a *Agent) runPowerAction(action string, command []string, delay time.Duration) {
+	// 给API响应留出通过隧道返回的时间
+	if delay < time.Second {
+		delay = time.Second
+	}
+
+	select {
+	case <-a.ctx.Done():
+		a.cancelPowerAction(action)
+		return
+	case <-time.After(delay):
+	}
+
+	fmt.Printf("Executing power action %s: %s\n", action, strings.Join(command, " "))
+	output, err := exec.Command(command[0], command[1:]...).CombinedOutput()
+	if err != nil {
+		fmt.Printf("Power action %s failed: %v: %s\n", action, err, strings.TrimSpace(string(output)))
+		a.cancelPowerAction(action)
+		return
+	}
+
+	if action == api.PowerActionSuspend {
+		a.mu.Lock()
+		a.powerPending = false
+		a.mu.Unlock()
+	}
+}
+
+// cancelPowerAction 撤销未执行的电源操作
+func (a *Agent) cancelPowerAction(action string) {
+	a.mu.Lock()
+	a.powerPending = false
+	if action != api.PowerActionSuspend && !a.decommissioning {
+		a.draining = false
+	}
+	a.mu.Unlock()
+
+	if action != api.PowerActionSuspend {
+		if err := system.ClearPowerIntent(a.config.DataDir); err != nil {
+			fmt.Printf("Warning: failed to clear power intent: %v\n", err)
+		}
+	}
+}
+
+// powerCommand 返回电源操作对应的命令
+func (a *Agent) powerCommand(action string) []string {
+	cfg := a.currentConfig().Power
+	switch action {
+	case api.PowerActionReboot:
+		return cfg.RebootCommand
+	case api.PowerActionShutdown:
+		return cfg.ShutdownCommand
+	case api.PowerActionSuspend:
+		return cfg.SuspendCommand
+	}
+	return nil
+}
+
+// runningContainerCount 统计运行中的受管容器数量
+func (a *Agent) runningContainerCount() int {
+	ctx, cancel := context.WithTimeout(a.ctx, 30*time.Second)
+	defer cancel()
+	if err := a.containerManager.RefreshContainers(ctx); err != nil {
+		fmt.Printf("Warning: failed to refresh containers: %v\n", err)
+	}
+
+	count := 0
+	for _, info := range a.containerManager.ListContainers() {
+		if info.Status == "running" {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,172 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/frp"
+)
+
+// enablePortPublishing 从现有容器恢复已发布的端口，frpc启动时一并建立隧道
+func (a *Agent) enablePortPublishing() {
+	a.publishedPorts = make(map[string][]container.PublishedPort)
+	for _, info := range a.containerManager.ListContainers() {
+		if len(info.PublishedPorts) > 0 {
+			a.publishedPorts[info.ClaimID] = info.PublishedPorts
+		}
+	}
+}
+
+// PublishPorts 为claim的端口分配本节点的远端端口，并用新的隧道配置重启frpc
+func (a *Agent) PublishPorts(ctx context.Context, claimID string, ports []container.PortMapping) ([]container.PublishedPort, error) {
+	a.tunnelMu.Lock()
+	defer a.tunnelMu.Unlock()
+
+	a.mu.Lock()
+	published, err := a.allocatePublishedPortsLocked(claimID, ports)
+	if err != nil {
+		a.mu.Unlock()
+		return nil, err
+	}
+	previous, existed := a.publishedPorts[claimID]
+	a.publishedPorts[claimID] = published
+	frpConfig := a.generateFRPConfig()
+	a.mu.Unlock()
+
+	// frpc进程的生命周期不能绑定到单个请求
+	if err := a.frpManager.UpdateConfig(a.ctx, frpConfig); err != nil {
+		a.mu.Lock()
+		if existed {
+			a.publishedPorts[claimID] = previous
+		} else {
+			delete(a.publishedPorts, claimID)
+		}
+		a.mu.Unlock()
+		return nil, fmt.Errorf("failed to update FRP tunnels: %w", err)
+	}
+	return published, nil
+}
+
+// UnpublishPorts 撤销claim的发布端口并重启frpc
+func (a *Agent) UnpublishPorts(ctx context.Context, claimID string) error {
+	a.tunnelMu.Lock()
+	defer a.tunnelMu.Unlock()
+
+	a.mu.Lock()
+	if _, exists := a.publishedPorts[claimID]; !exists {
+		a.mu.Unlock()
+		return nil
+	}
+	delete(a.publishedPorts, claimID)
+	frpConfig := a.generateFRPConfig()
+	a.mu.Unlock()
+
+	return a.frpManager.UpdateConfig(a.ctx, frpConfig)
+}
+
+// allocatePublishedPortsLocked 在本节点的远端端口段中为claim分配端口（调用方需持有锁）
+// 远端端口段为 claim_port_range_start + (节点ID-1)*claim_ports_per_node 起的 claim_ports_per_node 个端口
+func (a *Agent) allocatePublishedPortsLocked(claimID string, ports []container.PortMapping) ([]container.PublishedPort, error) {
+	nodeIDInt, err := strconv.Atoi(a.nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node ID %q: %w", a.nodeID, err)
+	}
+	perNode := a.config.FRP.ClaimPortsPerNode
+	base := a.config.FRP.ClaimPortRangeStart + (nodeIDInt-1)*perNode
+	if base+perNode-1 > 65535 {
+		return nil, fmt.Errorf("%w: node port range starting at %d exceeds 65535", container.ErrNoPublishPorts, base)
+	}
+
+	used := make(map[int]bool)
+	for owner, list := range a.publishedPorts {
+		if owner == claimID {
+			continue
+		}
+		for _, p := range list {
+			used[p.RemotePort] = true
+		}
+	}
+
+	host := a.config.FRP.PublicHost
+	if host == "" {
+		host = a.config.FRP.ServerAddr
+	}
+	host = config.NormalizeHost(host)
+
+	published := make([]container.PublishedPort, 0, len(ports))
+	next := base
+	for _, pm := range ports {
+		for next < base+perNode && used[next] {
+			next++
+		}
+		if next >= base+perNode {
+			return nil, fmt.Errorf("%w: all %d ports of this node are in use", container.ErrNoPublishPorts, perNode)
+		}
+		used[next] = true
+
+		p := container.PublishedPort{
+			Name:          pm.Name,
+			ContainerPort: pm.ContainerPort,
+			HostPort:      pm.HostPort,
+			RemotePort:    next,
+			Address:       net.JoinHostPort(host, strconv.Itoa(next)),
+		}
+		if pm.Publish == container.PublishHTTP {
+			p.URL = "http://" + p.Address
+		}
+		published = append(published, p)
+	}
+	return published, nil
+}
+
+// claimTunnelsLocked 生成claim发布端口的隧道配置，按claim与端口名称排序（调用方需持有锁）
+func (a *Agent) claimTunnelsLocked() []frp.ClaimTunnel {
+	var tunnels []frp.ClaimTunnel
+	for claimID, list := range a.publishedPorts {
+		for _, p := range list {
+			tunnels = append(tunnels, frp.ClaimTunnel{
+				ClaimID:    claimID,
+				PortName:   p.Name,
+				LocalPort:  p.HostPort,
+				RemotePort: p.RemotePort,
+			})
+		}
+	}
+	sort.Slice(tunnels, func(i, j int) bool {
+		if tunnels[i].ClaimID != tunnels[j].ClaimID {
+			return tunnels[i].ClaimID < tunnels[j].ClaimID
+		}
+		return tunnels[i].PortName < tunnels[j].PortName
+	})
+	return tunnels
+}
+
+// validatePortPlan 按GPU数量检查主机端口划分，各端口段不能重叠，也不能包含Agent API与frpc管理接口的端口
+func (a *Agent) validatePortPlan() error {
+	reserved := make(map[int]string)
+	addresses := append([]string{a.config.AgentAPI.ListenAddress}, a.config.AgentAPI.AdditionalListenAddresses...)
+	if a.config.AgentAPI.AdminListenAddress != "" {
+		addresses = append(addresses, a.config.AgentAPI.AdminListenAddress)
+	}
+	for _, address := range addresses {
+		if _, portStr, err := net.SplitHostPort(address); err == nil {
+			if port, err := config.ParsePort(portStr); err == nil {
+				reserved[port] = "agent API " + address
+			}
+		}
+	}
+	if a.config.FRP.AdminPort > 0 {
+		reserved[a.config.FRP.AdminPort] = "frp.admin_port"
+	}
+
+	gpuCount, _ := a.gpuMonitor.GetGPUCount()
+	if err := a.portPlan().Validate(gpuCount, reserved); err != nil {
+		return fmt.Errorf("invalid container.port_plan: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package agent
+
+import (
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/redact"
+)
+
+// newRedactor 按配置创建敏感值遮盖器并用于全局日志，未启用时返回nil
+func newRedactor(cfg config.RedactionConfig) (*redact.Redactor, error) {
+	if !cfg.Enabled {
+		redact.SetDefault(nil)
+		return nil, nil
+	}
+	r, err := redact.New(cfg.Patterns)
+	if err != nil {
+		return nil, err
+	}
+	redact.SetDefault(r)
+	return r, nil
+}
+
+// redactEvent 遮盖事件消息、数据与claim元数据中的敏感值
+func (a *Agent) redactEvent(event *events.Event) {
+	if a.redactor == nil {
+		return
+	}
+	event.Message = a.redactor.Text(event.Message)
+	event.Data = a.redactor.Map(event.Data)
+	event.ClaimMetadata = a.redactor.Map(event.ClaimMetadata)
+}
@@ -0,0 +1,31 @@
+package agent
+
+import (
+	"fmt"
+
+	"utopia-node-agent/internal/secrets"
+)
+
+// loadSecretsKeypair 加载或生成节点加密密钥对，并检查密钥目录位于tmpfs
+func (a *Agent) loadSecretsKeypair() error {
+	if err := secrets.EnsureMemoryBacked(a.config.Secrets.RuntimeDir); err != nil {
+		return err
+	}
+
+	keypair, err := secrets.LoadOrCreateKeypair(a.config.SecretsKeyFile())
+	if err != nil {
+		return err
+	}
+	a.secretsKeypair = keypair
+
+	fmt.Printf("Secrets delivery enabled (encryption public key: %s)\n", keypair.PublicKey())
+	return nil
+}
+
+// encryptionPublicKey 返回节点加密公钥，未启用密钥下发时为空
+func (a *Agent) encryptionPublicKey() string {
+	if a.secretsKeypair == nil {
+		return ""
+	}
+	return a.secretsKeypair.PublicKey()
+}
@@ -0,0 +1,29 @@
+package agent
+
+import (
+	"fmt"
+
+	"utopia-node-agent/internal/security"
+)
+
+// setupSecurity 加载租户容器的加固配置，配置要求强制但无法生效时返回错误
+func (a *Agent) setupSecurity() error {
+	if !a.config.Security.Enabled {
+		a.securityStatus = security.Status{Mechanism: security.MechanismNone, Reason: "disabled by configuration"}
+		return nil
+	}
+
+	status := security.Setup(a.ctx, a.config.SecurityProfileDir(), a.config.Security.SELinuxType)
+	a.securityStatus = status
+
+	if !status.Enforced {
+		if a.config.Security.Required {
+			return fmt.Errorf("container security profile cannot be enforced: %s", status.Reason)
+		}
+		fmt.Printf("Warning: container security profile not enforced: %s\n", status.Reason)
+		return nil
+	}
+
+	fmt.Printf("Container security profile enforced (%s: %s)\n", status.Mechanism, status.Profile)
+	return nil
+}
@@ -0,0 +1,335 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"utopia-node-agent/internal/api"
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/frp"
+)
+
+// 节点自检事件
+const (
+	EventNodeSelfTestPassed = "node.self_test_passed"
+	EventNodeSelfTestFailed = "node.self_test_failed"
+)
+
+// 自检的辅助时限
+const (
+	// 删除测试容器的时限，自检已超时时仍会删除
+	selfTestRemoveTimeout = 2 * time.Minute
+	// 经隧道访问agent健康检查的时限
+	selfTestTunnelTimeout = 10 * time.Second
+)
+
+// selfTestRun 一次自检的执行状态
+type selfTestRun struct {
+	report api.SelfTestReport
+	// 之前的步骤已失败，之后依赖测试容器的步骤跳过
+	failed bool
+}
+
+// step 执行一个步骤并记录结果，fn返回跳过原因时记为skipped
+func (r *selfTestRun) step(name string, fn func(data map[string]interface{}) (skipped string, err error)) {
+	start := time.Now()
+	data := make(map[string]interface{})
+	skipped, err := fn(data)
+	step := api.SelfTestStep{Name: name, Status: api.SelfTestPassed, DurationMs: time.Since(start).Milliseconds()}
+	switch {
+	case err != nil:
+		step.Status = api.SelfTestFailed
+		step.Message = err.Error()
+		r.failed = true
+	case skipped != "":
+		step.Status = api.SelfTestSkipped
+		step.Message = skipped
+	}
+	if len(data) > 0 {
+		step.Data = data
+	}
+	r.report.Steps = append(r.report.Steps, step)
+}
+
+// skip 记录因之前的步骤失败而跳过的步骤
+func (r *selfTestRun) skip(name string) {
+	r.report.Steps = append(r.report.Steps, api.SelfTestStep{Name: name, Status: api.SelfTestSkipped, Message: "a previous step failed"})
+}
+
+// SelfTest 经完整的创建流程在空闲GPU上运行测试容器，检查容器内GPU可见性与隧道可达性，再删除容器
+// 测试容器带有 metadata.self_test=true，并以自检时限作为运行时长上限，agent中途退出时也会被清理
+func (a *Agent) SelfTest(ctx context.Context, req api.SelfTestRequest) (api.SelfTestReport, error) {
+	cfg := a.currentConfig().SelfTest
+	a.mu.Lock()
+	if a.selfTesting {
+		a.mu.Unlock()
+		return api.SelfTestReport{}, api.ErrSelfTestRunning
+	}
+	a.selfTesting = true
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		a.selfTesting = false
+		a.mu.Unlock()
+	}()
+
+	image := req.Image
+	if image == "" {
+		image = cfg.Image
+	}
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	run := &selfTestRun{report: api.SelfTestReport{
+		NodeID:    a.NodeID(),
+		Image:     image,
+		ClaimID:   fmt.Sprintf("selftest-%d", start.UnixNano()),
+		StartedAt: start.Unix(),
+	}}
+	fmt.Printf("Running node self-test (claim %s, image %s)\n", run.report.ClaimID, image)
+
+	run.step("gpu", a.selfTestFreeGPU)
+	if run.failed {
+		run.skip("create")
+		run.skip("gpu_visibility")
+		run.skip("tunnel")
+		run.skip("remove")
+	} else {
+		run.step("create", func(data map[string]interface{}) (string, error) {
+			return "", a.selfTestCreate(ctx, run, timeout, data)
+		})
+		if run.failed {
+			run.skip("gpu_visibility")
+			run.skip("tunnel")
+		} else {
+			run.step("gpu_visibility", func(data map[string]interface{}) (string, error) {
+				return "", a.selfTestGPUVisibility(ctx, run.report.ContainerID, data)
+			})
+			run.step("tunnel", func(data map[string]interface{}) (string, error) {
+				return a.selfTestTunnel(ctx, run.report.GPUIDs, data)
+			})
+		}
+		run.step("remove", func(data map[string]interface{}) (string, error) {
+			return a.selfTestRemove(run, data)
+		})
+	}
+
+	run.report.Passed = !run.failed
+	run.report.DurationMs = time.Since(start).Milliseconds()
+	a.publishSelfTestEvent(run.report)
+	return run.report, nil
+}
+
+// selfTestFreeGPU 检查是否有未被受管容器或其他进程占用的GPU
+func (a *Agent) selfTestFreeGPU(data map[string]interface{}) (string, error) {
+	if !a.gpuMonitor.Available() {
+		return "", fmt.Errorf("GPU monitoring is unavailable")
+	}
+	var free []int
+	for _, g := range a.gpuMonitor.GetGPUInfo() {
+		if !g.Busy && !a.containerManager.IsGPUInUse(g.ID) {
+			free = append(free, g.ID)
+		}
+	}
+	data["free_gpu_ids"] = free
+	if len(free) == 0 {
+		return "", fmt.Errorf("no free GPU")
+	}
+	return "", nil
+}
+
+// selfTestCreate 经创建容器的完整流程（校验、准入、GPU选择、钩子）创建测试容器
+func (a *Agent) selfTestCreate(ctx context.Context, run *selfTestRun, timeout time.Duration, data map[string]interface{}) error {
+	req := &container.CreateRequest{
+		ClaimID:           run.report.ClaimID,
+		Image:             run.report.Image,
+		GPUCount:          1,
+		Command:           []string{"sleep", "infinity"},
+		RestartPolicy:     &container.RestartPolicy{Name: "no"},
+		Metadata:          map[string]interface{}{"self_test": true},
+		MaxRuntimeSeconds: int(timeout.Seconds()) + int(selfTestRemoveTimeout.Seconds()),
+	}
+	containerID, err := a.containerManager.CreateContainer(ctx, req)
+	if containerID == "" {
+		// 创建中途失败时容器可能已存在，删除步骤按claim查找
+		containerID = a.selfTestContainer(run.report.ClaimID)
+	}
+	run.report.ContainerID = containerID
+	if err != nil {
+		return err
+	}
+
+	info, ok := a.containerManager.GetContainer(containerID)
+	if !ok {
+		return fmt.Errorf("container %.12s is not in the container cache", containerID)
+	}
+	run.report.GPUIDs = info.GPUIDs
+	data["container_id"] = containerID
+	data["gpu_ids"] = info.GPUIDs
+	if len(info.GPUIDs) != 1 {
+		return fmt.Errorf("expected 1 allocated GPU, got %d", len(info.GPUIDs))
+	}
+	if info.Status != "running" {
+		return fmt.Errorf("container is %s", info.Status)
+	}
+	return nil
+}
+
+// selfTestContainer 按claim查找测试容器，不存在时返回空字符串
+func (a *Agent) selfTestContainer(claimID string) string {
+	for _, info := range a.containerManager.ListContainers() {
+		if info.ClaimID == claimID {
+			return info.ID
+		}
+	}
+	return ""
+}
+
+// selfTestGPUVisibility 检查容器内 nvidia-smi 看到的GPU与分配的GPU一致
+func (a *Agent) selfTestGPUVisibility(ctx context.Context, containerID string, data map[string]interface{}) error {
+	info, _ := a.containerManager.GetContainer(containerID)
+	var expected []string
+	for _, g := range a.gpuMonitor.GetGPUInfo() {
+		if slices.Contains(info.GPUIDs, g.ID) {
+			expected = append(expected, g.UUID)
+		}
+	}
+	data["expected_uuids"] = expected
+
+	output, err := a.containerManager.ExecContainer(ctx, containerID, "nvidia-smi", "--query-gpu=uuid,name", "--format=csv,noheader")
+	if err != nil {
+		return fmt.Errorf("nvidia-smi failed in container: %w", err)
+	}
+	var visible, names []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		uuid, name, _ := strings.Cut(line, ",")
+		if uuid = strings.TrimSpace(uuid); uuid != "" {
+			visible = append(visible, uuid)
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+	data["visible_uuids"] = visible
+	data["names"] = names
+
+	slices.Sort(expected)
+	slices.Sort(visible)
+	if !slices.Equal(expected, visible) {
+		return fmt.Errorf("container sees GPUs %v, expected %v", visible, expected)
+	}
+	return nil
+}
+
+// selfTestTunnel 检查控制隧道与测试容器所在GPU的数据隧道已建立，并经控制隧道的外部地址访问agent健康检查
+func (a *Agent) selfTestTunnel(ctx context.Context, gpuIDs []int, data map[string]interface{}) (string, error) {
+	if a.frpManager == nil {
+		return "FRP is not configured", nil
+	}
+	if !a.frpManager.IsRunning() {
+		return "", fmt.Errorf("frpc is not running")
+	}
+
+	var control *frp.TunnelStatus
+	var problems []string
+	states := make(map[string]string)
+	for _, tunnel := range a.frpManager.Tunnels(ctx) {
+		switch {
+		case tunnel.Kind == frp.TunnelKindControl:
+			t := tunnel
+			control = &t
+		case tunnel.Kind == frp.TunnelKindData && tunnel.GPUID != nil && slices.Contains(gpuIDs, *tunnel.GPUID):
+		default:
+			continue
+		}
+		states[tunnel.Name] = tunnel.State
+		if tunnel.State != frp.TunnelRunning {
+			problem := fmt.Sprintf("%s is %s", tunnel.Name, tunnel.State)
+			if tunnel.Error != "" {
+				problem += ": " + tunnel.Error
+			}
+			problems = append(problems, problem)
+		}
+	}
+	data["tunnels"] = states
+	if len(problems) > 0 {
+		return "", fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	if control == nil || control.Address == "" {
+		return "", fmt.Errorf("control tunnel has no remote address")
+	}
+
+	// 任何HTTP响应都说明外部经frps可以访问到本agent
+	data["address"] = control.Address
+	ctx, cancel := context.WithTimeout(ctx, selfTestTunnelTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+control.Address+"/health", nil)
+	if err != nil {
+		return "", err
+	}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("control tunnel %s is unreachable: %w", control.Address, err)
+	}
+	resp.Body.Close()
+	data["status_code"] = resp.StatusCode
+	data["latency_ms"] = time.Since(start).Milliseconds()
+	return "", nil
+}
+
+// selfTestRemove 经删除容器的完整流程删除测试容器，并检查GPU已释放
+func (a *Agent) selfTestRemove(run *selfTestRun, data map[string]interface{}) (string, error) {
+	if run.report.ContainerID == "" {
+		return "no container was created", nil
+	}
+	// 自检超时或请求方断开时仍需删除
+	ctx, cancel := context.WithTimeout(a.ctx, selfTestRemoveTimeout)
+	defer cancel()
+	if err := a.containerManager.RemoveContainer(ctx, run.report.ContainerID); err != nil {
+		return "", err
+	}
+	for _, id := range run.report.GPUIDs {
+		if a.containerManager.IsGPUInUse(id) {
+			return "", fmt.Errorf("GPU %d is still in use after removal", id)
+		}
+	}
+	data["released_gpu_ids"] = run.report.GPUIDs
+	return "", nil
+}
+
+// publishSelfTestEvent 发布自检结果事件
+func (a *Agent) publishSelfTestEvent(report api.SelfTestReport) {
+	event := events.Event{
+		Type:     EventNodeSelfTestPassed,
+		Severity: events.SeverityInfo,
+		Message:  fmt.Sprintf("node self-test passed in %dms", report.DurationMs),
+		Data: map[string]interface{}{
+			"claim_id":    report.ClaimID,
+			"image":       report.Image,
+			"duration_ms": report.DurationMs,
+		},
+	}
+	if !report.Passed {
+		var failed []string
+		for _, step := range report.Steps {
+			if step.Status == api.SelfTestFailed {
+				failed = append(failed, fmt.Sprintf("%s: %s", step.Name, step.Message))
+			}
+		}
+		event.Type = EventNodeSelfTestFailed
+		event.Severity = events.SeverityWarning
+		event.Message = "node self-test failed: " + strings.Join(failed, "; ")
+		event.Data["failed_steps"] = failed
+	}
+	fmt.Println(event.Message)
+	a.eventBus.Publish(event)
+}
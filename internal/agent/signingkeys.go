@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/logsample"
+	"utopia-node-agent/internal/signing"
+)
+
+// initializeSigningKeys 加载平台验证公钥的本地缓存
+// 配置的公钥作为根公钥始终受信任，平台轮换的公钥通过由受信任公钥签名的密钥包下发
+func (a *Agent) initializeSigningKeys() error {
+	rootKey, err := signing.ParsePublicKey(a.config.CentralPlatform.SigningPublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid platform signing key: %w", err)
+	}
+
+	var fetch signing.FetchFunc
+	if a.config.CentralPlatform.SigningKeysRefreshSeconds > 0 {
+		fetch = func(ctx context.Context) (string, error) {
+			return a.regClient.SigningKeys(ctx, a.NodeID())
+		}
+	}
+
+	cache, err := signing.NewKeyCache(filepath.Join(a.config.DataDir, "signing-keys.json"), []ed25519.PublicKey{rootKey}, fetch)
+	if err != nil {
+		return err
+	}
+	a.signingKeys = cache
+
+	status := cache.Status()
+	fmt.Printf("Signing keys loaded (bundle version %d, %d key(s))\n", status.Version, status.KeyCount)
+	return nil
+}
+
+// signingKeysTask 定期从平台拉取签名密钥包，平台不可达时继续使用本地缓存
+func (a *Agent) signingKeysTask() {
+	a.runPeriodic("signing_keys", func(c *config.Config) int { return c.CentralPlatform.SigningKeysRefreshSeconds }, func() error {
+		err := a.signingKeys.Refresh(a.ctx)
+		if err != nil {
+			logsample.Printf("Warning: failed to refresh signing keys, using cached keys: %v\n", err)
+		}
+		return err
+	})
+}
+
+// applySigningKeys 应用心跳推送的签名密钥包
+func (a *Agent) applySigningKeys(bundle string) {
+	if a.signingKeys == nil {
+		return
+	}
+	if err := a.signingKeys.Update(bundle); err != nil && !errors.Is(err, signing.ErrStaleBundle) {
+		fmt.Printf("Warning: rejected signing key bundle from platform: %v\n", err)
+	}
+}
+
+// SigningKeys 返回签名公钥缓存的状态，未配置平台签名公钥时为nil
+func (a *Agent) SigningKeys() *signing.KeyCacheStatus {
+	if a.signingKeys == nil {
+		return nil
+	}
+	status := a.signingKeys.Status()
+	return &status
+}
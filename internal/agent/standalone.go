@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/registration"
+)
+
+// EventRegistered 以独立模式启动的节点在后台注册成功
+const EventRegistered = "agent.registered"
+
+// registrationRetryMin 后台重试注册的初始间隔
+const registrationRetryMin = 5 * time.Second
+
+// enterStandalone 首次注册时平台不可达：本地子系统照常启动，
+// 隧道与平台通信的后台任务在注册成功后启动，期间的事件在首次心跳中上报
+func (a *Agent) enterStandalone(err error) {
+	now := time.Now().Unix()
+	a.mu.Lock()
+	a.registrationStatus = &registration.Status{
+		State:         registration.StatePending,
+		Attempts:      1,
+		LastError:     err.Error(),
+		LastAttemptAt: now,
+	}
+	a.mu.Unlock()
+	fmt.Printf("Warning: %v; starting in standalone mode and retrying registration in the background\n", err)
+}
+
+// registrationTask 以指数退避重试注册，成功后启动隧道与平台任务
+func (a *Agent) registrationTask(ctx context.Context) {
+	delay := registrationRetryMin
+	for {
+		maxDelay := time.Duration(a.currentConfig().CentralPlatform.RegistrationRetryMaxSeconds) * time.Second
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+		a.mu.Lock()
+		a.registrationStatus.NextAttemptAt = time.Now().Add(delay).Unix()
+		a.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		retry, err := a.register(ctx)
+		if err == nil {
+			a.completeRegistration()
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		a.mu.Lock()
+		a.registrationStatus.Attempts++
+		a.registrationStatus.LastError = err.Error()
+		a.registrationStatus.LastAttemptAt = time.Now().Unix()
+		a.mu.Unlock()
+		// 平台明确拒绝时（如令牌无效）按最长间隔重试，等待运维更换令牌
+		if !retry {
+			fmt.Printf("Error: registration rejected, retrying in %s: %v\n", maxDelay, err)
+			delay = maxDelay
+			continue
+		}
+		fmt.Printf("Warning: registration failed, retrying: %v\n", err)
+		delay *= 2
+	}
+}
+
+// completeRegistration 注册成功后启动frpc与平台任务，并立即发送首次心跳
+func (a *Agent) completeRegistration() {
+	nodeID := a.NodeID()
+	a.mu.Lock()
+	a.registrationStatus.State = registration.StateRegistered
+	a.registrationStatus.LastError = ""
+	a.registrationStatus.NextAttemptAt = 0
+	a.registrationStatus.RegisteredAt = time.Now().Unix()
+	standaloneSince := a.registrationStatus.LastAttemptAt
+	attempts := a.registrationStatus.Attempts + 1
+	a.mu.Unlock()
+
+	fmt.Printf("Registered as node %s after %d attempt(s), leaving standalone mode\n", nodeID, attempts)
+
+	// 节点ID会写入容器标签等选项
+	a.mu.RLock()
+	a.containerManager.UpdateOptions(a.containerOptions())
+	a.mu.RUnlock()
+
+	if err := a.startRegisteredFRP(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	a.eventBus.Publish(events.Event{
+		Type:     EventRegistered,
+		Severity: events.SeverityInfo,
+		Message:  fmt.Sprintf("node registered after starting in standalone mode (%d attempt(s))", attempts),
+		Data: map[string]interface{}{
+			"node_id":       nodeID,
+			"attempts":      attempts,
+			"last_attempt":  standaloneSince,
+			"registered_at": time.Now().Unix(),
+		},
+	})
+
+	a.startPlatformTasks()
+	a.requestHeartbeat()
+}
+
+// startRegisteredFRP 在运行期间启动frpc（以独立模式启动的节点注册成功后）
+func (a *Agent) startRegisteredFRP() error {
+	a.tunnelMu.Lock()
+	defer a.tunnelMu.Unlock()
+	if a.frpManager != nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	if a.config.FRP.ClaimPortRangeStart > 0 {
+		a.enablePortPublishing()
+	}
+	frpConfig := a.generateFRPConfig()
+	a.mu.Unlock()
+
+	if err := a.launchFRP(frpConfig); err != nil {
+		return fmt.Errorf("failed to start FRP after registration: %w", err)
+	}
+	return nil
+}
+
+// Registration 返回节点注册状态
+func (a *Agent) Registration() *registration.Status {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.registrationStatus == nil {
+		return &registration.Status{State: registration.StateRegistered}
+	}
+	status := *a.registrationStatus
+	return &status
+}
@@ -0,0 +1,234 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/gpu"
+	"utopia-node-agent/internal/logsample"
+)
+
+// GPU温控事件类型
+const (
+	EventGPUThermalLimitExceeded = "gpu.thermal_limit_exceeded"
+	EventGPUThermalRecovered     = "gpu.thermal_recovered"
+)
+
+// thermalState 单个GPU的温控状态
+type thermalState struct {
+	// 状态所属GPU的UUID，GPU热插拔导致索引变化时据此迁移状态
+	uuid string
+	// 温度持续超过上限，尚未持续回落到恢复温度
+	hot bool
+	// 与当前状态相反的温度条件开始成立的时间，零值表示没有
+	pendingSince time.Time
+	// 降低功率上限前的设置（瓦），0表示未调整
+	originalLimitW int
+	// 风扇已固定转速
+	fansPinned bool
+}
+
+// thermalPolicyTask 定期检查GPU温度，持续超温时发布事件并按配置降低功率上限或提高风扇转速
+func (a *Agent) thermalPolicyTask() {
+	a.runPeriodic("thermal_policy", func(c *config.Config) int { return c.ThermalPolicy.IntervalSeconds }, a.checkThermalPolicy)
+}
+
+// checkThermalPolicy 按最近一次采集的温度更新各GPU的温控状态
+// 状态切换需要温度持续超过上限或低于恢复温度，两个阈值之间的温度不改变状态
+func (a *Agent) checkThermalPolicy() error {
+	cfg := a.currentConfig().ThermalPolicy
+	// 驱动维护期间没有可信的温度，NVML也无法调整设备
+	if a.gpuMonitor.InMaintenance() {
+		return nil
+	}
+	if !cfg.Enabled {
+		a.restoreThermalControls()
+		return nil
+	}
+	if a.thermalStates == nil {
+		a.thermalStates = make(map[int]*thermalState)
+	}
+
+	now := time.Now()
+	sustain := time.Duration(cfg.SustainSeconds) * time.Second
+	gpus := a.gpuMonitor.GetGPUInfo()
+	a.rekeyThermalStates(gpus)
+	var lastErr error
+	for _, g := range gpus {
+		state, exists := a.thermalStates[g.ID]
+		if !exists {
+			state = &thermalState{uuid: g.UUID}
+			a.thermalStates[g.ID] = state
+		}
+
+		if state.hot {
+			// 配置在运行时关闭了某项调整时立即还原
+			if err := a.relaxThermalControls(g.ID, state, cfg); err != nil {
+				lastErr = err
+			}
+		}
+
+		flip := g.TemperatureC >= cfg.MaxTemperatureC
+		if state.hot {
+			flip = g.TemperatureC <= cfg.ResumeTemperatureC
+		}
+		if !flip {
+			state.pendingSince = time.Time{}
+			continue
+		}
+		if state.pendingSince.IsZero() {
+			state.pendingSince = now
+		}
+		if now.Sub(state.pendingSince) < sustain {
+			continue
+		}
+		state.pendingSince = time.Time{}
+
+		var err error
+		if state.hot {
+			err = a.leaveThermalLimit(g, state)
+		} else {
+			err = a.enterThermalLimit(g, state, cfg)
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	a.updateThermalThrottled()
+	return lastErr
+}
+
+// enterThermalLimit GPU持续超温：降低功率上限、固定风扇转速并通知平台
+func (a *Agent) enterThermalLimit(g gpu.GPUInfo, state *thermalState, cfg config.ThermalPolicyConfig) error {
+	state.hot = true
+	data := map[string]interface{}{
+		"gpu_id":            g.ID,
+		"uuid":              g.UUID,
+		"temperature_c":     g.TemperatureC,
+		"max_temperature_c": cfg.MaxTemperatureC,
+		"fan_speeds":        g.FanSpeedsPercent,
+	}
+
+	var lastErr error
+	if cfg.PowerLimitPercent > 0 {
+		limits, err := a.gpuMonitor.PowerLimits(g.ID)
+		if err == nil {
+			watts := limits.DefaultW * cfg.PowerLimitPercent / 100
+			if watts < limits.MinW {
+				watts = limits.MinW
+			}
+			// 当前上限已不高于目标值（如管理员手动调低）时保持不变
+			if watts < limits.CurrentW {
+				if err = a.gpuMonitor.SetPowerLimit(g.ID, watts); err == nil {
+					state.originalLimitW = limits.CurrentW
+					data["power_limit_w"] = watts
+					data["original_power_limit_w"] = limits.CurrentW
+				}
+			}
+		}
+		if err != nil {
+			logsample.Printf("Warning: failed to lower power limit of overheating GPU %d: %v\n", g.ID, err)
+			lastErr = err
+		}
+	}
+	if cfg.FanSpeedPercent > 0 && len(g.FanSpeedsPercent) > 0 {
+		if err := a.gpuMonitor.SetFanSpeed(g.ID, cfg.FanSpeedPercent); err != nil {
+			logsample.Printf("Warning: failed to raise fan speed of overheating GPU %d: %v\n", g.ID, err)
+			lastErr = err
+		} else {
+			state.fansPinned = true
+			data["fan_speed_percent"] = cfg.FanSpeedPercent
+		}
+	}
+
+	fmt.Printf("Warning: GPU %d has been at %d°C (limit %d°C) for at least %ds\n", g.ID, g.TemperatureC, cfg.MaxTemperatureC, cfg.SustainSeconds)
+	a.eventBus.Publish(events.Event{
+		Type:     EventGPUThermalLimitExceeded,
+		Severity: events.SeverityWarning,
+		Message:  fmt.Sprintf("GPU %d temperature %d°C exceeded the %d°C limit", g.ID, g.TemperatureC, cfg.MaxTemperatureC),
+		Data:     data,
+	})
+	return lastErr
+}
+
+// leaveThermalLimit GPU温度已持续回落：还原功率上限与风扇控制并通知平台
+func (a *Agent) leaveThermalLimit(g gpu.GPUInfo, state *thermalState) error {
+	state.hot = false
+	err := a.relaxThermalControls(g.ID, state, config.ThermalPolicyConfig{})
+
+	fmt.Printf("GPU %d temperature recovered to %d°C\n", g.ID, g.TemperatureC)
+	a.eventBus.Publish(events.Event{
+		Type:     EventGPUThermalRecovered,
+		Severity: events.SeverityInfo,
+		Message:  fmt.Sprintf("GPU %d temperature recovered to %d°C", g.ID, g.TemperatureC),
+		Data: map[string]interface{}{
+			"gpu_id":        g.ID,
+			"uuid":          g.UUID,
+			"temperature_c": g.TemperatureC,
+		},
+	})
+	return err
+}
+
+// relaxThermalControls 还原cfg中未启用的调整（传入零值配置时还原全部调整）
+func (a *Agent) relaxThermalControls(gpuID int, state *thermalState, cfg config.ThermalPolicyConfig) error {
+	var lastErr error
+	if state.originalLimitW > 0 && cfg.PowerLimitPercent == 0 {
+		if err := a.gpuMonitor.SetPowerLimit(gpuID, state.originalLimitW); err != nil {
+			fmt.Printf("Warning: failed to restore power limit of GPU %d: %v\n", gpuID, err)
+			lastErr = err
+		} else {
+			state.originalLimitW = 0
+		}
+	}
+	if state.fansPinned && cfg.FanSpeedPercent == 0 {
+		if err := a.gpuMonitor.ResetFanSpeed(gpuID); err != nil {
+			fmt.Printf("Warning: failed to restore automatic fan control of GPU %d: %v\n", gpuID, err)
+			lastErr = err
+		} else {
+			state.fansPinned = false
+		}
+	}
+	return lastErr
+}
+
+// restoreThermalControls 还原全部GPU的温控调整并清除状态（策略关闭或Agent停止时）
+func (a *Agent) restoreThermalControls() {
+	if len(a.thermalStates) == 0 || a.gpuMonitor.InMaintenance() {
+		return
+	}
+	for gpuID, state := range a.thermalStates {
+		if a.relaxThermalControls(gpuID, state, config.ThermalPolicyConfig{}) == nil {
+			delete(a.thermalStates, gpuID)
+		}
+	}
+	a.updateThermalThrottled()
+}
+
+// rekeyThermalStates 把温控状态迁移到GPU的当前索引，已被移除的GPU的状态直接丢弃
+func (a *Agent) rekeyThermalStates(gpus []gpu.GPUInfo) {
+	index := make(map[string]int, len(gpus))
+	for _, g := range gpus {
+		index[g.UUID] = g.ID
+	}
+	rekeyed := make(map[int]*thermalState, len(a.thermalStates))
+	for _, state := range a.thermalStates {
+		if id, ok := index[state.uuid]; ok {
+			rekeyed[id] = state
+		}
+	}
+	a.thermalStates = rekeyed
+}
+
+// updateThermalThrottled 在GPU信息中标记功率上限被降低的GPU
+func (a *Agent) updateThermalThrottled() {
+	throttled := make(map[int]bool)
+	for gpuID, state := range a.thermalStates {
+		if state.originalLimitW > 0 {
+			throttled[gpuID] = true
+		}
+	}
+	a.gpuMonitor.SetThermalThrottled(throttled)
+}
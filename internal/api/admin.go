@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"utopia-node-agent/internal/tracing"
+)
+
+// EnableAdminListener 在独立的监听地址上提供管理端点，使用单独的令牌认证
+// 启用后经隧道暴露的主API不再提供这些端点（应急Shell除外，其由平台签发的一次性令牌授权）
+func (s *Server) EnableAdminListener(address, token string) {
+	s.authMu.Lock()
+	s.adminAddress = address
+	s.adminToken = token
+	s.authMu.Unlock()
+}
+
+// adminListenerEnabled 是否启用了独立的管理监听地址
+func (s *Server) adminListenerEnabled() bool {
+	s.authMu.RLock()
+	defer s.authMu.RUnlock()
+	return s.adminAddress != ""
+}
+
+// registerAdminRoutes 注册退役、重启、电源、GPU恢复、自检、后台任务、功能开关与性能剖析等管理端点
+func (s *Server) registerAdminRoutes(admin *gin.RouterGroup) {
+	admin.DELETE("/node", s.decommissionNode)
+	admin.POST("/restart", s.restartAgent)
+	admin.POST("/power", s.powerAction)
+	admin.GET("/planned-downtime", s.getPlannedDowntime)
+	admin.PUT("/planned-downtime", s.setPlannedDowntime)
+	admin.DELETE("/planned-downtime", s.clearPlannedDowntime)
+	admin.POST("/gpu/reattach", s.reattachGPUs)
+	admin.POST("/gpu/driver-upgrade", s.upgradeDriver)
+	admin.POST("/selftest", s.runSelfTest)
+	admin.GET("/tasks", s.listTasks)
+	admin.GET("/feature-flags", s.listFeatureFlags)
+	admin.PUT("/feature-flags/:name", s.setFeatureFlag)
+	admin.DELETE("/feature-flags/:name", s.resetFeatureFlag)
+	admin.GET("/debug/pprof/:profile", s.getProfile)
+}
+
+// setupAdminRoutes 创建管理监听地址使用的路由，路径与主API相同
+func (s *Server) setupAdminRoutes() {
+	engine := gin.New()
+	engine.Use(gin.Recovery())
+	engine.Use(tracing.Middleware())
+	engine.Use(gzipMiddleware())
+	engine.Use(requestIDMiddleware())
+
+	for _, version := range apiVersions {
+		group := engine.Group("/api/" + version.Version + "/admin")
+		group.Use(versionMiddleware(version.Version), s.adminAuthMiddleware())
+		s.registerAdminRoutes(group)
+	}
+	engine.NoRoute(func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Not found",
+			Code:  404,
+		})
+	})
+
+	s.adminEngine = engine
+}
+
+// adminAuthMiddleware 管理监听地址的认证中间件，只接受管理令牌
+func (s *Server) adminAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		s.authMu.RLock()
+		valid := ok && token != "" && token == s.adminToken
+		s.authMu.RUnlock()
+		if !valid {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{
+				Error: "Invalid admin token",
+				Code:  401,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// adminOnMainMiddleware 启用独立管理监听地址后，主API上的管理端点返回404
+func (s *Server) adminOnMainMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.adminListenerEnabled() {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Not found",
+				Code:    404,
+				Details: "admin endpoints are served on the admin listener",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
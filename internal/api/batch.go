@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/tracing"
+)
+
+// BatchCreateRequest 批量创建容器请求
+type BatchCreateRequest struct {
+	Containers []*container.CreateRequest `json:"containers" binding:"required,dive,required"`
+}
+
+// BatchCreateResponse 批量创建容器响应，顺序与请求一致
+type BatchCreateResponse struct {
+	Containers []BatchCreatedContainer `json:"containers"`
+}
+
+// BatchCreatedContainer 批量中创建成功的容器
+type BatchCreatedContainer struct {
+	ClaimID string `json:"claim_id"`
+	CreateContainerResponse
+}
+
+// batchCreateContainers 批量创建容器，全部成功或全部回滚
+func (s *Server) batchCreateContainers(c *gin.Context) {
+	if s.node != nil && s.node.IsDraining() {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Node is draining",
+			Code:  503,
+		})
+		return
+	}
+
+	var req BatchCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if !s.negotiateCreateRequests(c, req.Containers, true) {
+		return
+	}
+	if err := s.containerManager.ValidateBatch(req.Containers); err != nil {
+		s.respondInvalidRequest(c, err)
+		return
+	}
+
+	gpuCount := 0
+	for _, r := range req.Containers {
+		gpuCount += r.GPUCount
+	}
+	if gpuCount > 0 && !s.gpuMonitor.Available() {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "GPU support is unavailable on this node",
+			Code:    409,
+			Details: s.gpuMonitor.UnavailableReason(),
+		})
+		return
+	}
+
+	// 子租户的请求检查配额，创建完成前计入预留
+	release, ok := s.reserveQuota(c, req.Containers)
+	if !ok {
+		return
+	}
+
+	// 创建容器（不随请求取消，但保留链路上下文），失败时已创建的容器被回滚
+	ctx := tracing.Detach(c.Request.Context())
+	containerIDs, err := s.containerManager.CreateContainers(ctx, req.Containers)
+	release()
+	if errors.Is(err, container.ErrInsufficientGPUs) {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Not enough available GPUs",
+			Code:    409,
+			Details: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		s.respondCreateError(c, err)
+		return
+	}
+
+	resp := BatchCreateResponse{Containers: make([]BatchCreatedContainer, len(containerIDs))}
+	for i, containerID := range containerIDs {
+		created := BatchCreatedContainer{
+			ClaimID:                 req.Containers[i].ClaimID,
+			CreateContainerResponse: CreateContainerResponse{ContainerID: containerID},
+		}
+		if info, exists := s.containerManager.GetContainer(containerID); exists {
+			created.PublishedPorts = info.PublishedPorts
+			created.GPUSelection = info.GPUSelection
+		}
+		resp.Containers[i] = created
+	}
+	c.JSON(http.StatusCreated, resp)
+}
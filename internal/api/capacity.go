@@ -0,0 +1,42 @@
+package api
+
+import (
+	"time"
+
+	"utopia-node-agent/internal/container"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CapacityResponse 节点可调度容量汇总，供调度器一次获取GPU、CPU、内存、磁盘余量与节点状态
+type CapacityResponse struct {
+	NodeID string `json:"node_id"`
+	// 节点是否接受新容器：未排空且不在GPU驱动维护中
+	Schedulable bool `json:"schedulable"`
+	Draining    bool `json:"draining"`
+	// 正在进行GPU驱动维护（重新加载或升级），期间不分配GPU
+	Maintenance bool `json:"maintenance"`
+	// GPU功能不可用（降级模式）的原因
+	GPUUnavailableReason string `json:"gpu_unavailable_reason,omitempty"`
+	container.Capacity
+	// GPU状态的采集时间
+	CollectedAt time.Time `json:"collected_at"`
+}
+
+// getCapacity 返回节点可调度容量汇总
+func (s *Server) getCapacity(c *gin.Context) {
+	gpus, collectedAt := s.gpuMonitor.Snapshot()
+	response := CapacityResponse{
+		NodeID:               s.nodeID(),
+		Maintenance:          s.gpuMonitor.InMaintenance(),
+		GPUUnavailableReason: s.gpuMonitor.UnavailableReason(),
+		Capacity:             s.containerManager.Capacity(c.Request.Context(), gpus),
+		CollectedAt:          collectedAt,
+	}
+	if s.node != nil {
+		response.Draining = s.node.IsDraining()
+	}
+	response.Schedulable = !response.Draining && !response.Maintenance
+
+	respondJSONWithETag(c, response)
+}
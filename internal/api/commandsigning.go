@@ -0,0 +1,116 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"utopia-node-agent/internal/signing"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// commandSignatureHeader 破坏性操作携带平台签名令牌的请求头
+const commandSignatureHeader = "X-Command-Signature"
+
+// CommandTokenPurpose 命令签名令牌的用途
+const CommandTokenPurpose = "node-command"
+
+// signedRoutes 经主API调用时需要平台签名的破坏性操作（方法 + 路由模板）
+var signedRoutes = map[string]bool{
+	"DELETE /containers/:id":          true,
+	"DELETE /gpus/:id/processes/:pid": true,
+	"POST /claims/:id/hibernate":      true,
+	"DELETE /schedules/:id":           true,
+	"DELETE /datasets/:key":           true,
+	"DELETE /admin/node":              true,
+	"POST /admin/power":               true,
+	"POST /admin/gpu/reattach":        true,
+	"POST /admin/gpu/driver-upgrade":  true,
+}
+
+// errMissingCommandSignature 请求没有携带命令签名
+var errMissingCommandSignature = errors.New("missing " + commandSignatureHeader + " header")
+
+// EnableCommandSigning 要求经主API（FRP控制隧道）调用的破坏性操作携带平台签名，
+// 仅泄露Bearer令牌不足以删除容器或退役节点
+func (s *Server) EnableCommandSigning(verifier *signing.Verifier) {
+	s.commandVerifier = verifier
+}
+
+// commandSigningMiddleware 校验破坏性操作的平台签名
+// 本机Unix套接字上按对端凭据认证的请求，以及只能操作自己容器的子租户令牌不要求签名
+func (s *Server) commandSigningMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.commandVerifier == nil || !signedRoutes[c.Request.Method+" "+unversionedPath(c.FullPath())] {
+			c.Next()
+			return
+		}
+		if cred, ok := peerCredentials(c); ok && s.peerAllowed(cred) {
+			c.Next()
+			return
+		}
+		if requestTenant(c) != "" {
+			c.Next()
+			return
+		}
+
+		claims, err := s.verifyCommand(c)
+		if err != nil {
+			log.Warnf("Rejected %s %s from %s: invalid command signature: %v", c.Request.Method, c.Request.URL.Path, c.ClientIP(), err)
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{
+				Error:   "Invalid command signature",
+				Code:    403,
+				Details: err.Error(),
+			})
+			return
+		}
+		log.Infof("Authorized signed command %s (token %s, subject %q)", claims.Target, claims.ID, claims.Subject)
+		c.Next()
+	}
+}
+
+// verifyCommand 校验并消费请求携带的命令签名令牌
+// 令牌须绑定本节点，并与请求的方法、路径（不含版本前缀）、查询参数及请求体一致；声明不匹配时不消费令牌
+func (s *Server) verifyCommand(c *gin.Context) (*signing.Claims, error) {
+	token := c.GetHeader(commandSignatureHeader)
+	if token == "" {
+		return nil, errMissingCommandSignature
+	}
+	nodeID := s.nodeID()
+	if nodeID == "" {
+		return nil, fmt.Errorf("node is not registered")
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	bodyHash := ""
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		bodyHash = hex.EncodeToString(sum[:])
+	}
+	target := c.Request.Method + " " + unversionedPath(c.Request.URL.Path)
+	if c.Request.URL.RawQuery != "" {
+		target += "?" + c.Request.URL.RawQuery
+	}
+
+	claims, err := s.commandVerifier.Verify(token, CommandTokenPurpose, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case claims.Target != target:
+		return nil, fmt.Errorf("%w: token was issued for %q", signing.ErrInvalidToken, claims.Target)
+	case claims.BodySHA256 != bodyHash:
+		return nil, fmt.Errorf("%w: request body does not match the token", signing.ErrInvalidToken)
+	}
+	return s.commandVerifier.Consume(token, CommandTokenPurpose, nodeID)
+}
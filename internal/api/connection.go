@@ -0,0 +1,286 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/frp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 按容器端口或发布端口名称识别的服务
+const (
+	sshContainerPort     = 22
+	jupyterContainerPort = 8888
+)
+
+// jupyterPortNames 视为Jupyter的发布端口名称
+var jupyterPortNames = map[string]bool{"jupyter": true, "notebook": true, "lab": true}
+
+// 连接方式
+const (
+	// 经frp隧道访问
+	connectionViaTunnel = "tunnel"
+	// 直接访问节点地址上的主机端口
+	connectionViaDirect = "direct"
+)
+
+// ConnectionEndpoint 服务的外部访问地址
+type ConnectionEndpoint struct {
+	ContainerPort int `json:"container_port"`
+	HostPort      int `json:"host_port"`
+	// tunnel 或 direct，无法从外部访问时为空
+	Via  string `json:"via,omitempty"`
+	Host string `json:"host,omitempty"`
+	Port int    `json:"port,omitempty"`
+	// 经隧道访问时的隧道名称与状态
+	Tunnel      string `json:"tunnel,omitempty"`
+	TunnelState string `json:"tunnel_state,omitempty"`
+}
+
+// reachable 是否有外部访问地址
+func (e ConnectionEndpoint) reachable() bool {
+	return e.Host != "" && e.Port > 0
+}
+
+// SSHConnection SSH连接信息
+type SSHConnection struct {
+	ConnectionEndpoint
+	User string `json:"user"`
+	// 如 ssh -p 20012 root@frp.example.com，无外部访问地址时为空
+	Command string `json:"command,omitempty"`
+}
+
+// JupyterConnection Jupyter连接信息
+type JupyterConnection struct {
+	ConnectionEndpoint
+	// 带令牌的访问URL，无外部访问地址时为空
+	URL string `json:"url,omitempty"`
+	// 是否从容器环境变量或启动参数中找到了令牌
+	TokenFound bool `json:"token_found"`
+}
+
+// VSCodeConnection VS Code Remote-SSH 连接信息
+type VSCodeConnection struct {
+	// Remote-SSH 的远程标识，用于 code --remote
+	Remote    string `json:"remote"`
+	Workspace string `json:"workspace"`
+	// 在浏览器或系统中打开的链接
+	URI     string `json:"uri"`
+	Command string `json:"command"`
+}
+
+// ClaimConnectionResponse claim的连接信息，供平台界面展示可直接使用的命令
+type ClaimConnectionResponse struct {
+	ClaimID     string             `json:"claim_id"`
+	ContainerID string             `json:"container_id"`
+	Status      string             `json:"status"`
+	SSH         *SSHConnection     `json:"ssh,omitempty"`
+	Jupyter     *JupyterConnection `json:"jupyter,omitempty"`
+	VSCode      *VSCodeConnection  `json:"vscode,omitempty"`
+}
+
+// getClaimConnection 根据容器端口、隧道状态与注入的凭据组装claim的连接信息
+func (s *Server) getClaimConnection(c *gin.Context) {
+	claimID := c.Param("id")
+	info, found := s.claimContainer(c, claimID)
+	if !found {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Claim not found",
+			Code:  404,
+		})
+		return
+	}
+
+	access, err := s.containerManager.ContainerAccess(c.Request.Context(), info.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to inspect container",
+			Code:    500,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var tunnels []frp.TunnelStatus
+	nodeHost := ""
+	if s.node != nil {
+		tunnels = s.node.Tunnels(c.Request.Context())
+		if addr := s.node.NodeAddress(); addr != nil {
+			nodeHost = addr.PublicIP
+			if nodeHost == "" {
+				nodeHost = addr.PrimaryIP
+			}
+		}
+	}
+
+	response := ClaimConnectionResponse{
+		ClaimID:     claimID,
+		ContainerID: info.ID,
+		Status:      info.Status,
+	}
+	for _, port := range containerPorts(info) {
+		published := publishedPortFor(info, port.containerPort)
+		name := ""
+		if published != nil {
+			name = published.Name
+		}
+		switch {
+		case response.SSH == nil && (name == "ssh" || (name == "" && port.containerPort == sshContainerPort)):
+			endpoint := resolveEndpoint(info, port, published, tunnels, nodeHost)
+			response.SSH = &SSHConnection{ConnectionEndpoint: endpoint, User: access.SSHUser}
+			if endpoint.reachable() {
+				response.SSH.Command = "ssh -p " + strconv.Itoa(endpoint.Port) + " " + access.SSHUser + "@" + endpoint.Host
+				response.VSCode = vscodeConnection(endpoint, access)
+			}
+		case response.Jupyter == nil && (jupyterPortNames[name] || (name == "" && port.containerPort == jupyterContainerPort)):
+			endpoint := resolveEndpoint(info, port, published, tunnels, nodeHost)
+			response.Jupyter = &JupyterConnection{ConnectionEndpoint: endpoint, TokenFound: access.JupyterToken != ""}
+			if endpoint.reachable() {
+				response.Jupyter.URL = jupyterURL(endpoint, published, access.JupyterToken)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// claimContainer 查找请求方可见的claim容器，有多个时优先返回运行中的容器
+func (s *Server) claimContainer(c *gin.Context, claimID string) (container.ContainerInfo, bool) {
+	var result container.ContainerInfo
+	found := false
+	tenant := requestTenant(c)
+	for _, info := range s.containerManager.ListContainers() {
+		if info.ClaimID != claimID || (tenant != "" && info.Tenant() != tenant) {
+			continue
+		}
+		if !found || (info.Status == "running" && result.Status != "running") {
+			result, found = info, true
+		}
+	}
+	return result, found
+}
+
+// containerPort 容器端口与主机端口绑定
+type containerPort struct {
+	containerPort int
+	hostIP        string
+	hostPort      int
+}
+
+// containerPorts 解析容器信息中的TCP端口绑定（"22/tcp" -> "0.0.0.0:8001"），按容器端口排序
+func containerPorts(info container.ContainerInfo) []containerPort {
+	var ports []containerPort
+	for key, binding := range info.Ports {
+		portText, protocol, _ := strings.Cut(key, "/")
+		if protocol != "" && protocol != "tcp" {
+			continue
+		}
+		// 主机地址可能是不带方括号的IPv6地址（如 "::"），按最后一个冒号分割
+		i := strings.LastIndex(binding, ":")
+		if i < 0 {
+			continue
+		}
+		hostIP, hostPortText := binding[:i], binding[i+1:]
+		port, err1 := strconv.Atoi(portText)
+		hostPort, err2 := strconv.Atoi(hostPortText)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		ports = append(ports, containerPort{containerPort: port, hostIP: hostIP, hostPort: hostPort})
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].containerPort < ports[j].containerPort })
+	return ports
+}
+
+// publishedPortFor 返回容器端口对应的发布端口
+func publishedPortFor(info container.ContainerInfo, port int) *container.PublishedPort {
+	for i := range info.PublishedPorts {
+		if info.PublishedPorts[i].ContainerPort == port {
+			return &info.PublishedPorts[i]
+		}
+	}
+	return nil
+}
+
+// resolveEndpoint 确定端口的外部访问地址：优先使用claim发布端口的隧道，其次是本地端口相同的GPU数据隧道，
+// 最后在主机端口未绑定回环地址时直接使用节点地址
+func resolveEndpoint(info container.ContainerInfo, port containerPort, published *container.PublishedPort, tunnels []frp.TunnelStatus, nodeHost string) ConnectionEndpoint {
+	endpoint := ConnectionEndpoint{ContainerPort: port.containerPort, HostPort: port.hostPort}
+	address := ""
+	switch {
+	case published != nil:
+		endpoint.Via = connectionViaTunnel
+		address = published.Address
+		for _, t := range tunnels {
+			if t.Kind == frp.TunnelKindClaim && t.ClaimID == info.ClaimID && t.PortName == published.Name {
+				endpoint.Tunnel, endpoint.TunnelState = t.Name, t.State
+				if t.Address != "" {
+					address = t.Address
+				}
+				break
+			}
+		}
+	default:
+		for _, t := range tunnels {
+			if t.Kind == frp.TunnelKindData && t.LocalPort == port.hostPort {
+				endpoint.Via = connectionViaTunnel
+				endpoint.Tunnel, endpoint.TunnelState = t.Name, t.State
+				address = t.Address
+				break
+			}
+		}
+		if endpoint.Via == "" && nodeHost != "" && !net.ParseIP(port.hostIP).IsLoopback() {
+			endpoint.Via = connectionViaDirect
+			address = net.JoinHostPort(nodeHost, strconv.Itoa(port.hostPort))
+		}
+	}
+
+	if host, portText, err := net.SplitHostPort(address); err == nil {
+		endpoint.Host = host
+		endpoint.Port, _ = strconv.Atoi(portText)
+	}
+	return endpoint
+}
+
+// jupyterURL 生成带令牌的Jupyter访问URL
+func jupyterURL(endpoint ConnectionEndpoint, published *container.PublishedPort, token string) string {
+	base := "http://" + net.JoinHostPort(endpoint.Host, strconv.Itoa(endpoint.Port))
+	if published != nil && published.URL != "" {
+		base = published.URL
+	}
+	base = strings.TrimSuffix(base, "/") + "/"
+	if token == "" {
+		return base
+	}
+	return base + "?token=" + url.QueryEscape(token)
+}
+
+// vscodeConnection 生成 VS Code Remote-SSH 的连接信息
+// 远程标识使用十六进制编码的JSON主机描述，可以携带端口与用户而无需修改本地 ~/.ssh/config
+func vscodeConnection(endpoint ConnectionEndpoint, access container.ContainerAccess) *VSCodeConnection {
+	host, _ := json.Marshal(map[string]interface{}{
+		"hostName": endpoint.Host,
+		"port":     endpoint.Port,
+		"user":     access.SSHUser,
+	})
+	remote := "ssh-remote+" + hex.EncodeToString(host)
+	workspace := access.Workspace
+	if !strings.HasPrefix(workspace, "/") {
+		workspace = "/" + workspace
+	}
+	return &VSCodeConnection{
+		Remote:    remote,
+		Workspace: workspace,
+		URI:       "vscode://vscode-remote/" + remote + workspace,
+		Command:   "code --folder-uri vscode-remote://" + remote + workspace,
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+
+	"utopia-node-agent/internal/container"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CoreDumpsResponse claim收集到的核心转储
+type CoreDumpsResponse struct {
+	ClaimID string `json:"claim_id"`
+	// 转储在容器内的写入目录
+	ContainerDir string               `json:"container_dir"`
+	Dumps        []container.CoreDump `json:"dumps"`
+}
+
+// coreDumpsEnabled 节点是否按容器收集核心转储，未收集时返回404
+func (s *Server) coreDumpsEnabled(c *gin.Context) (*container.CoreDumpStatus, bool) {
+	status := s.containerManager.CoreDumpStatus()
+	if status == nil || !status.Collecting {
+		details := "core dump collection is disabled"
+		if status != nil {
+			details = status.Reason
+		}
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Core dump collection is not available",
+			Code:    404,
+			Details: details,
+		})
+		return nil, false
+	}
+	return status, true
+}
+
+// listCoreDumps 列出claim容器收集到的核心转储
+func (s *Server) listCoreDumps(c *gin.Context) {
+	status, ok := s.coreDumpsEnabled(c)
+	if !ok {
+		return
+	}
+	claimID := c.Param("id")
+	if _, found := s.claimContainer(c, claimID); !found {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Claim not found",
+			Code:  404,
+		})
+		return
+	}
+
+	dumps, err := s.containerManager.ListCoreDumps(claimID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to list core dumps",
+			Code:    500,
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, CoreDumpsResponse{ClaimID: claimID, ContainerDir: status.ContainerDir, Dumps: dumps})
+}
+
+// downloadCoreDump 下载claim容器的核心转储
+func (s *Server) downloadCoreDump(c *gin.Context) {
+	if _, ok := s.coreDumpsEnabled(c); !ok {
+		return
+	}
+	claimID := c.Param("id")
+	if _, found := s.claimContainer(c, claimID); !found {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Claim not found",
+			Code:  404,
+		})
+		return
+	}
+
+	name := c.Param("name")
+	file, err := s.containerManager.CoreDumpFile(claimID, name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Core dump not found",
+			Code:  404,
+		})
+		return
+	}
+	c.FileAttachment(file, name)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"utopia-node-agent/internal/container"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listDatasets 列出节点缓存的共享数据集
+func (s *Server) listDatasets(c *gin.Context) {
+	datasets, err := s.containerManager.ListDatasets()
+	if errors.Is(err, container.ErrDatasetCacheDisabled) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Dataset cache is disabled",
+			Code:  404,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, datasets)
+}
+
+// removeDataset 删除缓存的数据集
+func (s *Server) removeDataset(c *gin.Context) {
+	err := s.containerManager.RemoveDataset(c.Param("key"))
+	switch {
+	case errors.Is(err, container.ErrDatasetCacheDisabled):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Dataset cache is disabled",
+			Code:  404,
+		})
+		return
+	case errors.Is(err, container.ErrDatasetInUse):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Dataset is in use",
+			Code:    409,
+			Details: err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Dataset not found",
+			Code:    404,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
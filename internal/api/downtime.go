@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxPlannedDowntime 计划停机时长的上限
+const maxPlannedDowntime = 30 * 24 * time.Hour
+
+// PlannedDowntime 计划停机：agent停止时随下线通知上报平台的预计停机时长
+type PlannedDowntime struct {
+	ExpectedDurationSeconds int64 `json:"expected_duration_seconds"`
+	// 运维说明，随下线通知上报
+	Message string `json:"message,omitempty"`
+	// 设置时间（Unix 时间戳）
+	PlannedAt int64 `json:"planned_at"`
+}
+
+// PlannedDowntimeRequest 设置计划停机请求
+type PlannedDowntimeRequest struct {
+	ExpectedDurationSeconds int64  `json:"expected_duration_seconds" binding:"required"`
+	Message                 string `json:"message,omitempty"`
+}
+
+// getPlannedDowntime 查看计划停机
+func (s *Server) getPlannedDowntime(c *gin.Context) {
+	if s.node == nil {
+		c.JSON(http.StatusNotImplemented, ErrorResponse{
+			Error: "Node controller not available",
+			Code:  501,
+		})
+		return
+	}
+
+	plan := s.node.PlannedDowntime()
+	if plan == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "No planned downtime",
+			Code:  404,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+// setPlannedDowntime 设置agent下次停止时上报的预计停机时长
+func (s *Server) setPlannedDowntime(c *gin.Context) {
+	if s.node == nil {
+		c.JSON(http.StatusNotImplemented, ErrorResponse{
+			Error: "Node controller not available",
+			Code:  501,
+		})
+		return
+	}
+
+	var req PlannedDowntimeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+	expected := time.Duration(req.ExpectedDurationSeconds) * time.Second
+	if req.ExpectedDurationSeconds < 0 || expected > maxPlannedDowntime {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Expected duration out of range",
+			Code:  400,
+		})
+		return
+	}
+
+	plan := s.node.PlanDowntime(expected, req.Message)
+	log.Infof("Planned downtime of %s set by %s (message=%q)", expected, c.ClientIP(), req.Message)
+	c.JSON(http.StatusOK, plan)
+}
+
+// clearPlannedDowntime 取消计划停机，之后停止时上报的预计停机时长为未知
+func (s *Server) clearPlannedDowntime(c *gin.Context) {
+	if s.node == nil {
+		c.JSON(http.StatusNotImplemented, ErrorResponse{
+			Error: "Node controller not available",
+			Code:  501,
+		})
+		return
+	}
+
+	s.node.ClearPlannedDowntime()
+	c.Status(http.StatusNoContent)
+}
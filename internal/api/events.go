@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/features"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SSE 事件推送的轮询与保活间隔
+const (
+	eventStreamPollInterval = time.Second
+	eventStreamKeepalive    = 15 * time.Second
+)
+
+// SetEventBus 设置节点事件总线
+func (s *Server) SetEventBus(bus *events.Bus) {
+	s.events = bus
+}
+
+// listEvents 列出最近的节点事件，支持 since（序号）与 limit 参数
+func (s *Server) listEvents(c *gin.Context) {
+	if s.events == nil {
+		c.JSON(http.StatusOK, []events.Event{})
+		return
+	}
+
+	since, err := strconv.ParseInt(c.DefaultQuery("since", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid since parameter",
+			Code:  400,
+		})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid limit parameter",
+			Code:  400,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.events.Since(since, limit))
+}
+
+// streamEvents 以 Server-Sent Events 推送节点事件（需开启 event_stream 功能开关）
+// 断线重连时从 Last-Event-ID 或 since 参数之后继续推送；开关关闭后结束推送
+func (s *Server) streamEvents(c *gin.Context) {
+	if s.events == nil || !s.featureEnabled(features.EventStream) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Event stream is not enabled",
+			Code:    404,
+			Details: "enable the event_stream feature flag to use this endpoint",
+		})
+		return
+	}
+
+	cursor := c.GetHeader("Last-Event-ID")
+	if cursor == "" {
+		cursor = c.DefaultQuery("since", "0")
+	}
+	since, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid since parameter",
+			Code:  400,
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(eventStreamPollInterval)
+	defer ticker.Stop()
+	lastWrite := time.Now()
+	for {
+		for _, event := range s.events.Since(since, 0) {
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data); err != nil {
+				return
+			}
+			since = event.Seq
+			lastWrite = time.Now()
+		}
+		if time.Since(lastWrite) >= eventStreamKeepalive {
+			if _, err := fmt.Fprint(c.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			lastWrite = time.Now()
+		}
+		c.Writer.Flush()
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+		}
+		if !s.featureEnabled(features.EventStream) {
+			return
+		}
+	}
+}
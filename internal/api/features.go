@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/features"
+	"utopia-node-agent/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EventFeatureFlagChanged 功能开关通过管理端点修改
+const EventFeatureFlagChanged = "agent.feature_flag_changed"
+
+// SetFeatureFlagRequest 设置功能开关请求
+type SetFeatureFlagRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// SetFeatureFlags 设置运行时功能开关
+func (s *Server) SetFeatureFlags(flags *features.Flags) {
+	s.features = flags
+}
+
+// featureEnabled 返回功能开关是否开启，未设置开关时使用默认值
+func (s *Server) featureEnabled(name string) bool {
+	return s.features.Enabled(name)
+}
+
+// listFeatureFlags 列出全部功能开关的当前状态
+func (s *Server) listFeatureFlags(c *gin.Context) {
+	if s.features == nil {
+		c.JSON(http.StatusOK, []features.Flag{})
+		return
+	}
+	c.JSON(http.StatusOK, s.features.List())
+}
+
+// setFeatureFlag 覆盖功能开关值，立即生效并持久化
+func (s *Server) setFeatureFlag(c *gin.Context) {
+	var req SetFeatureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+	s.updateFeatureFlag(c, func(name string) (features.Flag, error) {
+		return s.features.Set(name, *req.Enabled)
+	})
+}
+
+// resetFeatureFlag 删除功能开关的覆盖，恢复为配置值或默认值
+func (s *Server) resetFeatureFlag(c *gin.Context) {
+	s.updateFeatureFlag(c, s.features.Reset)
+}
+
+// updateFeatureFlag 修改路径参数指定的功能开关并发布事件
+func (s *Server) updateFeatureFlag(c *gin.Context, update func(name string) (features.Flag, error)) {
+	if s.features == nil {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Feature flags are not available",
+			Code:  503,
+		})
+		return
+	}
+
+	name := c.Param("name")
+	previous, err := s.features.Get(name)
+	if err == nil {
+		var flag features.Flag
+		if flag, err = update(name); err == nil {
+			tracing.Logger(c.Request.Context()).Infof("Feature flag %s set to %t (source: %s)", name, flag.Enabled, flag.Source)
+			s.publishFeatureFlagEvent(previous, flag)
+			c.JSON(http.StatusOK, flag)
+			return
+		}
+	}
+
+	if errors.Is(err, features.ErrUnknownFlag) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Feature flag not found",
+			Code:    404,
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "Failed to update feature flag",
+		Code:    500,
+		Details: err.Error(),
+	})
+}
+
+// publishFeatureFlagEvent 发布功能开关变更事件
+func (s *Server) publishFeatureFlagEvent(previous, flag features.Flag) {
+	if s.events == nil {
+		return
+	}
+	s.events.Publish(events.Event{
+		Type:     EventFeatureFlagChanged,
+		Severity: events.SeverityInfo,
+		Message:  fmt.Sprintf("feature flag %s is now %t (source: %s)", flag.Name, flag.Enabled, flag.Source),
+		Data: map[string]interface{}{
+			"flag":     flag.Name,
+			"enabled":  flag.Enabled,
+			"previous": previous.Enabled,
+			"source":   flag.Source,
+		},
+	})
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"utopia-node-agent/internal/jobs"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrGPUMaintenanceRunning 已有GPU驱动维护任务在执行
+var ErrGPUMaintenanceRunning = errors.New("a GPU maintenance job is already running")
+
+// GPUReattachRequest 驱动重新加载后重新挂载GPU的请求
+type GPUReattachRequest struct {
+	// 是否等待驱动被卸载或版本变化后再继续，默认true
+	ExpectReload *bool `json:"expect_reload,omitempty"`
+	// 等待驱动恢复的时限（秒），0表示使用默认的600秒
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// GPUDriverUpgradeRequest 驱动升级请求
+type GPUDriverUpgradeRequest struct {
+	// 目标驱动版本，通过 UTOPIA_DRIVER_VERSION 传给升级命令，并在升级后校验
+	Version string `json:"version,omitempty"`
+	// 升级命令结束后等待NVML恢复的时限（秒），0表示使用默认的600秒
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// maxGPUReattachTimeoutSeconds 等待驱动恢复的最长时限
+const maxGPUReattachTimeoutSeconds = 3600
+
+// driverVersionPattern 驱动版本号，如 550.127.05
+var driverVersionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+// GPUMaintenance GPU驱动维护接口（由agent实现）
+type GPUMaintenance interface {
+	// ReattachGPUs 提交驱动重新加载的恢复任务，已有任务执行时返回 ErrGPUMaintenanceRunning
+	ReattachGPUs(req GPUReattachRequest) (jobs.Job, error)
+	// DriverUpgradeEnabled 是否配置了驱动升级命令
+	DriverUpgradeEnabled() bool
+	// UpgradeDriver 提交驱动升级任务，已有任务执行时返回 ErrGPUMaintenanceRunning
+	UpgradeDriver(req GPUDriverUpgradeRequest) (jobs.Job, error)
+}
+
+// EnableGPUMaintenance 启用GPU驱动维护端点
+func (s *Server) EnableGPUMaintenance(maintenance GPUMaintenance) {
+	s.gpuMaintenance = maintenance
+}
+
+// reattachGPUs 停止使用GPU的容器，等待驱动重新加载后校验设备并重启容器（异步任务）
+func (s *Server) reattachGPUs(c *gin.Context) {
+	if s.gpuMaintenance == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "GPU maintenance is unavailable",
+			Code:  404,
+		})
+		return
+	}
+
+	var req GPUReattachRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request body",
+				Code:    400,
+				Details: err.Error(),
+			})
+			return
+		}
+	}
+	if req.TimeoutSeconds < 0 || req.TimeoutSeconds > maxGPUReattachTimeoutSeconds {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Timeout out of range",
+			Code:  400,
+		})
+		return
+	}
+
+	job, err := s.gpuMaintenance.ReattachGPUs(req)
+	switch {
+	case errors.Is(err, ErrGPUMaintenanceRunning):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: "A GPU maintenance job is already running",
+			Code:  409,
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to start GPU maintenance",
+			Code:    500,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	log.Warnf("GPU re-attach job %s started by %s", job.ID, c.ClientIP())
+	c.JSON(http.StatusAccepted, JobResponse{JobID: job.ID})
+}
+
+// upgradeDriver 排空GPU容器后执行配置的驱动升级命令，校验NVML与驱动版本后恢复容器（异步任务）
+func (s *Server) upgradeDriver(c *gin.Context) {
+	if s.gpuMaintenance == nil || !s.gpuMaintenance.DriverUpgradeEnabled() {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "GPU driver upgrade is unavailable",
+			Code:  404,
+		})
+		return
+	}
+
+	var req GPUDriverUpgradeRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request body",
+				Code:    400,
+				Details: err.Error(),
+			})
+			return
+		}
+	}
+	if req.Version != "" && !driverVersionPattern.MatchString(req.Version) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid driver version",
+			Code:    400,
+			Details: req.Version,
+		})
+		return
+	}
+	if req.TimeoutSeconds < 0 || req.TimeoutSeconds > maxGPUReattachTimeoutSeconds {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Timeout out of range",
+			Code:  400,
+		})
+		return
+	}
+
+	job, err := s.gpuMaintenance.UpgradeDriver(req)
+	switch {
+	case errors.Is(err, ErrGPUMaintenanceRunning):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: "A GPU maintenance job is already running",
+			Code:  409,
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to start GPU driver upgrade",
+			Code:    500,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	log.Warnf("GPU driver upgrade job %s (version %q) started by %s", job.ID, req.Version, c.ClientIP())
+	c.JSON(http.StatusAccepted, JobResponse{JobID: job.ID})
+}
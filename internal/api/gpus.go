@@ -0,0 +1,123 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"utopia-node-agent/internal/gpu"
+)
+
+// GPUProcess GPU上的计算进程，属于受管容器时包含容器与claim
+type GPUProcess struct {
+	gpu.ProcessInfo
+	ContainerID string `json:"container_id,omitempty"`
+	ClaimID     string `json:"claim_id,omitempty"`
+}
+
+// GPUProcessesResponse GPU计算进程列表
+type GPUProcessesResponse struct {
+	GPUID     int          `json:"gpu_id"`
+	Processes []GPUProcess `json:"processes"`
+}
+
+// listGPUProcesses 列出GPU上的计算进程（等同于 nvidia-smi 的进程列表）
+func (s *Server) listGPUProcesses(c *gin.Context) {
+	gpuID, ok := s.gpuParam(c)
+	if !ok {
+		return
+	}
+
+	processes, err := s.gpuMonitor.Processes(gpuID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to query GPU processes",
+			Code:    500,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	resp := GPUProcessesResponse{GPUID: gpuID, Processes: make([]GPUProcess, len(processes))}
+	for i, p := range processes {
+		resp.Processes[i] = GPUProcess{ProcessInfo: p}
+		if info, exists := s.containerManager.ContainerForPID(int(p.PID)); exists {
+			resp.Processes[i].ContainerID = info.ID
+			resp.Processes[i].ClaimID = info.ClaimID
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// killGPUProcess 结束GPU上的残留进程
+// 只能结束该GPU上的计算进程；属于受管容器的进程需设置 force=true
+func (s *Server) killGPUProcess(c *gin.Context) {
+	gpuID, ok := s.gpuParam(c)
+	if !ok {
+		return
+	}
+	pid, err := strconv.ParseUint(c.Param("pid"), 10, 32)
+	if err != nil || pid <= 1 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid process ID",
+			Code:  400,
+		})
+		return
+	}
+
+	force := c.Query("force") == "true"
+	if info, exists := s.containerManager.ContainerForPID(int(pid)); exists && !force {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Process belongs to a managed container, set force=true to kill it",
+			Code:    409,
+			Details: "claim " + info.ClaimID,
+		})
+		return
+	}
+
+	if err := s.gpuMonitor.KillProcess(gpuID, uint32(pid)); err != nil {
+		if errors.Is(err, gpu.ErrProcessNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Process not found on GPU",
+				Code:    404,
+				Details: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to kill GPU process",
+			Code:    500,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	log.Warnf("Killed process %d on GPU %d by %s (force=%t)", pid, gpuID, c.ClientIP(), force)
+	c.Status(http.StatusNoContent)
+}
+
+// gpuParam 解析路径中的GPU序号，GPU不可用或不存在时返回404
+func (s *Server) gpuParam(c *gin.Context) (int, bool) {
+	if !s.gpuMonitor.Available() {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "GPU support is unavailable on this node",
+			Code:    404,
+			Details: s.gpuMonitor.UnavailableReason(),
+		})
+		return 0, false
+	}
+	gpuID, err := strconv.Atoi(c.Param("id"))
+	if err == nil {
+		if _, exists := s.gpuMonitor.GetGPUByID(gpuID); exists {
+			return gpuID, true
+		}
+	}
+	c.JSON(http.StatusNotFound, ErrorResponse{
+		Error: "GPU not found",
+		Code:  404,
+	})
+	return 0, false
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"utopia-node-agent/internal/handover"
+)
+
+var (
+	// ErrRestartUnsupported 未启用无中断重启
+	ErrRestartUnsupported = errors.New("zero-downtime restart is not enabled")
+	// ErrRestartInProgress 已有进行中的重启或退役
+	ErrRestartInProgress = errors.New("a restart is already in progress")
+)
+
+// RestartController 无中断重启接口（由agent实现）
+type RestartController interface {
+	// Restart 异步启动新的agent进程并交接监听套接字与frpc，新进程就绪后当前进程退出
+	Restart() error
+}
+
+// servedListener 服务器使用的监听套接字及其配置的地址
+type servedListener struct {
+	network  string
+	address  string
+	listener net.Listener
+}
+
+// EnableRestart 启用管理端点触发的无中断重启
+func (s *Server) EnableRestart(controller RestartController) {
+	s.restart = controller
+}
+
+// SetInheritedListeners 使用旧agent进程交接的监听套接字，没有对应地址时新建
+func (s *Server) SetInheritedListeners(inherited *handover.Inherited) {
+	s.inherited = inherited
+}
+
+// listen 创建或接管监听套接字，并记录以便交给下一个agent进程
+func (s *Server) listen(network, address string) (net.Listener, error) {
+	listener, ok, err := s.inherited.Listener(network, address)
+	if err != nil {
+		log.Warnf("Failed to adopt inherited listener on %s, listening again: %v", address, err)
+	}
+	if ok && err == nil {
+		// 套接字文件由最初创建它的进程所有，本进程关闭时不删除（交接失败时旧进程仍在使用）
+		if unixListener, isUnix := listener.(*net.UnixListener); isUnix {
+			unixListener.SetUnlinkOnClose(false)
+		}
+	} else {
+		if network == "unix" {
+			listener, err = listenUnix(address)
+		} else {
+			listener, err = net.Listen(network, address)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.listenerMu.Lock()
+	s.listeners = append(s.listeners, servedListener{network: network, address: address, listener: listener})
+	s.listenerMu.Unlock()
+	return listener, nil
+}
+
+// HandoverListeners 把全部监听套接字登记到交接状态
+// Unix套接字关闭时不再删除套接字文件，新进程继续在其上提供服务
+func (s *Server) HandoverListeners(builder *handover.Builder) error {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+	for _, served := range s.listeners {
+		if err := builder.AddListener(served.network, served.address, served.listener); err != nil {
+			return fmt.Errorf("failed to hand over %s: %w", served.address, err)
+		}
+		if unixListener, ok := served.listener.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false)
+		}
+	}
+	return nil
+}
+
+// restartAgent 以无中断方式重启agent进程
+func (s *Server) restartAgent(c *gin.Context) {
+	if s.restart == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Zero-downtime restart is disabled",
+			Code:  404,
+		})
+		return
+	}
+
+	err := s.restart.Restart()
+	switch {
+	case errors.Is(err, ErrRestartInProgress):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: "A restart is already in progress",
+			Code:  409,
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to start restart",
+			Code:    500,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	log.Warnf("Zero-downtime agent restart requested by %s", c.ClientIP())
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": "restarting",
+	})
+}
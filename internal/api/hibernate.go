@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/tracing"
+)
+
+// HibernateRequest claim休眠请求
+type HibernateRequest struct {
+	// 休眠期间是否保留GPU，未设置时使用节点默认值
+	KeepGPUs *bool `json:"keep_gpus,omitempty"`
+}
+
+// hibernateClaim 停止claim的容器，保留可写层、卷与（可选）GPU
+func (s *Server) hibernateClaim(c *gin.Context) {
+	var req HibernateRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request body",
+				Code:    400,
+				Details: err.Error(),
+			})
+			return
+		}
+	}
+
+	ctx := tracing.Detach(c.Request.Context())
+	record, err := s.containerManager.HibernateClaim(ctx, c.Param("id"), req.KeepGPUs)
+	if err != nil {
+		s.respondHibernationError(c, err, "Failed to hibernate claim")
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// resumeClaim 重新启动休眠claim的容器
+func (s *Server) resumeClaim(c *gin.Context) {
+	if s.node != nil && s.node.IsDraining() {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Node is draining",
+			Code:  503,
+		})
+		return
+	}
+
+	ctx := tracing.Detach(c.Request.Context())
+	if err := s.containerManager.ResumeClaim(ctx, c.Param("id")); err != nil {
+		s.respondHibernationError(c, err, "Failed to resume claim")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// getHibernation 获取claim的休眠状态
+func (s *Server) getHibernation(c *gin.Context) {
+	record, hibernated := s.containerManager.GetHibernation(c.Param("id"))
+	if !hibernated {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Claim is not hibernated",
+			Code:  404,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// respondHibernationError 返回休眠与恢复的错误响应
+func (s *Server) respondHibernationError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, container.ErrHibernationDisabled):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Claim hibernation is disabled",
+			Code:  404,
+		})
+	case errors.Is(err, container.ErrClaimNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Claim has no running container",
+			Code:    404,
+			Details: err.Error(),
+		})
+	case errors.Is(err, container.ErrClaimHibernated), errors.Is(err, container.ErrClaimNotHibernated):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   message,
+			Code:    409,
+			Details: err.Error(),
+		})
+	case errors.Is(err, container.ErrHibernatedGPUsTaken), errors.Is(err, container.ErrGPUsReindexed):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "GPUs of the claim are no longer available",
+			Code:    409,
+			Details: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   message,
+			Code:    500,
+			Details: err.Error(),
+		})
+	}
+}
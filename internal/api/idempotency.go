@@ -0,0 +1,149 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"utopia-node-agent/internal/idempotency"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// idempotencyKeyHeader 幂等键请求头
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength 幂等键的最大长度
+const maxIdempotencyKeyLength = 255
+
+// recordingWriter 在写出响应的同时记录响应体
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write 写出并记录响应体
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString 写出并记录字符串响应体
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// EnableIdempotency 启用POST/DELETE请求的幂等键支持
+func (s *Server) EnableIdempotency(cache *idempotency.Cache) {
+	s.idempotency = cache
+}
+
+// requestPrincipal 返回请求的认证主体，与authMiddleware的认证方式对应
+func (s *Server) requestPrincipal(c *gin.Context) string {
+	if cred, ok := peerCredentials(c); ok && s.peerAllowed(cred) {
+		return fmt.Sprintf("uid:%d", cred.UID)
+	}
+	if tenant := requestTenant(c); tenant != "" {
+		return "tenant:" + tenant
+	}
+	return "operator"
+}
+
+// idempotencyMiddleware 对携带 Idempotency-Key 的POST/DELETE请求去重
+// 重试时直接返回首次请求的响应；5xx响应不保存，以便平台重试
+func (s *Server) idempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		method := c.Request.Method
+		if s.idempotency == nil || key == "" || (method != http.MethodPost && method != http.MethodDelete) {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{
+				Error: "Idempotency key is too long",
+				Code:  400,
+			})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Failed to read request body",
+				Code:    400,
+				Details: err.Error(),
+			})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// 幂等键按调用方隔离，不同调用方使用相同的键互不影响
+		key = s.requestPrincipal(c) + "/" + key
+		fingerprint := idempotency.Fingerprint(method, c.Request.URL.Path, body)
+		record, err := s.idempotency.Begin(key, fingerprint)
+		switch {
+		case errors.Is(err, idempotency.ErrInProgress):
+			c.AbortWithStatusJSON(http.StatusConflict, ErrorResponse{
+				Error: "A request with this idempotency key is in progress",
+				Code:  409,
+			})
+			return
+		case errors.Is(err, idempotency.ErrMismatch):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorResponse{
+				Error: "Idempotency key was already used for a different request",
+				Code:  422,
+			})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to look up idempotency key",
+				Code:    500,
+				Details: err.Error(),
+			})
+			return
+		}
+
+		if record != nil {
+			c.Header("Idempotent-Replayed", "true")
+			if record.ContentType == "" {
+				c.Status(record.Status)
+			} else {
+				c.Data(record.Status, record.ContentType, record.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		// 处理函数panic时释放幂等键
+		completed := false
+		defer func() {
+			if !completed {
+				s.idempotency.Abort(key)
+			}
+		}()
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		completed = true
+		if err := s.idempotency.Complete(key, idempotency.Record{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}); err != nil {
+			log.Warnf("Failed to store idempotency key for %s %s: %v", method, c.Request.URL.Path, err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"utopia-node-agent/internal/container"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// buildMultipartMemory 解析multipart表单时保留在内存中的大小，超出部分写入临时文件
+const buildMultipartMemory = 32 << 20
+
+// BuildImageRequest 以JSON提交的镜像构建请求（构建上下文为git仓库）
+// 上传构建上下文时使用 multipart/form-data，字段同名，context 为tar文件，build_args 为多个 NAME=VALUE
+type BuildImageRequest struct {
+	ClaimID        string            `json:"claim_id"`
+	Tag            string            `json:"tag,omitempty"`
+	GitURL         string            `json:"git_url,omitempty"`
+	Dockerfile     string            `json:"dockerfile,omitempty"`
+	DockerfilePath string            `json:"dockerfile_path,omitempty"`
+	BuildArgs      map[string]string `json:"build_args,omitempty"`
+}
+
+// buildImage 按用户Dockerfile构建镜像并加载到本地，以 Server-Sent Events 推送构建日志与结果
+// 客户端断开时取消构建
+func (s *Server) buildImage(c *gin.Context) {
+	policy := s.containerManager.BuildPolicy()
+	if !policy.Enabled {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Image build is not enabled",
+			Code:    404,
+			Details: "set image_build.enabled to use this endpoint",
+		})
+		return
+	}
+
+	// 上传的构建上下文不超过解压后的上限（另留1MB给表单字段与tar头）
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, policy.MaxContextBytes+1<<20)
+	req, err := parseBuildRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+	if closer, ok := req.Context.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	// 验证通过、开始输出构建日志时才切换为事件流，之前的错误以普通JSON响应返回
+	streaming := false
+	startStream := func() {
+		streaming = true
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+	}
+	result, err := s.containerManager.BuildImage(c.Request.Context(), req, func(line string) {
+		if !streaming {
+			startStream()
+		}
+		fmt.Fprintf(c.Writer, "event: log\ndata: %s\n\n", line)
+		c.Writer.Flush()
+	})
+
+	if !streaming {
+		var validationErr *container.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			s.respondInvalidRequest(c, err)
+			return
+		case errors.Is(err, container.ErrBuildBusy):
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error: "An image build is already running",
+				Code:  409,
+			})
+			return
+		case errors.Is(err, container.ErrBuildDisabled):
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error: "Image build is not enabled",
+				Code:  404,
+			})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to build image",
+				Code:    500,
+				Details: err.Error(),
+			})
+			return
+		}
+		startStream()
+	}
+
+	if err != nil {
+		log.Warnf("Image build for claim %s failed: %v", req.ClaimID, err)
+		data, _ := json.Marshal(ErrorResponse{Error: "Failed to build image", Code: 500, Details: err.Error()})
+		fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", data)
+	} else {
+		data, _ := json.Marshal(result)
+		fmt.Fprintf(c.Writer, "event: result\ndata: %s\n\n", data)
+	}
+	c.Writer.Flush()
+}
+
+// parseBuildRequest 解析JSON或multipart/form-data格式的构建请求
+func parseBuildRequest(c *gin.Context) (container.BuildRequest, error) {
+	if c.ContentType() != "multipart/form-data" {
+		var body BuildImageRequest
+		if err := c.ShouldBindJSON(&body); err != nil {
+			return container.BuildRequest{}, err
+		}
+		return container.BuildRequest{
+			ClaimID:        body.ClaimID,
+			Tag:            body.Tag,
+			GitURL:         body.GitURL,
+			Dockerfile:     body.Dockerfile,
+			DockerfilePath: body.DockerfilePath,
+			BuildArgs:      body.BuildArgs,
+		}, nil
+	}
+
+	if err := c.Request.ParseMultipartForm(buildMultipartMemory); err != nil {
+		return container.BuildRequest{}, err
+	}
+	form := c.Request.MultipartForm
+	value := func(name string) string {
+		if values := form.Value[name]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	req := container.BuildRequest{
+		ClaimID:        value("claim_id"),
+		Tag:            value("tag"),
+		GitURL:         value("git_url"),
+		Dockerfile:     value("dockerfile"),
+		DockerfilePath: value("dockerfile_path"),
+	}
+	for _, arg := range form.Value["build_args"] {
+		name, val, ok := strings.Cut(arg, "=")
+		if !ok {
+			return req, fmt.Errorf("build_args must be NAME=VALUE, got %q", arg)
+		}
+		if req.BuildArgs == nil {
+			req.BuildArgs = make(map[string]string)
+		}
+		req.BuildArgs[name] = val
+	}
+	if files := form.File["context"]; len(files) > 0 {
+		file, err := files[0].Open()
+		if err != nil {
+			return req, err
+		}
+		req.Context = file
+	}
+	return req, nil
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"utopia-node-agent/internal/container"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImageStatsResponse 镜像拉取与启动耗时统计
+type ImageStatsResponse struct {
+	// 懒加载拉取的生效情况，未启用时为空
+	LazyPull *container.LazyPullStatus `json:"lazy_pull,omitempty"`
+	Images   []container.ImageLatency  `json:"images"`
+}
+
+// getImageStats 返回各镜像的拉取与启动耗时，用于比较懒加载拉取的冷启动效果
+func (s *Server) getImageStats(c *gin.Context) {
+	response := ImageStatsResponse{Images: s.containerManager.ImageLatencies()}
+	if status := s.containerManager.LazyPullStatus(c.Request.Context()); status.Snapshotter != "" {
+		response.LazyPull = &status
+	}
+	respondJSONWithETag(c, response)
+}
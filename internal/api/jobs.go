@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/jobs"
+	"utopia-node-agent/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CommitContainerRequest 容器快照请求
+type CommitContainerRequest struct {
+	// 完整镜像仓库名，为空时使用节点默认仓库
+	Repository string `json:"repository,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+	Push       bool   `json:"push"`
+	Message    string `json:"message,omitempty"`
+	Author     string `json:"author,omitempty"`
+	// 提交期间是否暂停容器，默认true
+	Pause *bool `json:"pause,omitempty"`
+	// 推送使用的短期仓库凭据，只保存在内存中；为空时使用节点为该仓库配置的凭据
+	RegistryAuth *container.RegistryAuth `json:"registry_auth,omitempty"`
+}
+
+// JobResponse 异步任务提交响应
+type JobResponse struct {
+	JobID string `json:"job_id"`
+	Image string `json:"image,omitempty"`
+}
+
+// SetJobManager 设置异步任务管理器
+func (s *Server) SetJobManager(jobManager *jobs.Manager) {
+	s.jobs = jobManager
+}
+
+// commitContainer 将容器提交为镜像并可选推送到仓库（异步任务）
+func (s *Server) commitContainer(c *gin.Context) {
+	containerID := c.Param("id")
+	info, exists := s.containerManager.GetContainer(containerID)
+	if !exists {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Container not found",
+			Code:  404,
+		})
+		return
+	}
+
+	var req CommitContainerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err := s.containerManager.ValidatePushTarget(req.Repository, req.Tag, req.Push, req.RegistryAuth != nil); err != nil {
+		s.respondInvalidRequest(c, err)
+		return
+	}
+
+	tag := req.Tag
+	if tag == "" {
+		tag = time.Now().UTC().Format("20060102-150405")
+	}
+	image := s.containerManager.DefaultImageReference(info.ClaimID, tag)
+	if req.Repository != "" {
+		image = req.Repository + ":" + tag
+	}
+	if req.RegistryAuth != nil {
+		if err := req.RegistryAuth.Validate(image); err != nil {
+			s.respondInvalidRequest(c, &container.ValidationError{Fields: []container.FieldError{
+				{Field: "registry_auth", Message: err.Error()},
+			}})
+			return
+		}
+	}
+
+	opts := container.CommitOptions{
+		Message: req.Message,
+		Author:  req.Author,
+		Pause:   req.Pause == nil || *req.Pause,
+	}
+
+	job := s.jobs.Submit("container.commit", func(ctx context.Context, h *jobs.Handle) (map[string]interface{}, error) {
+		result := map[string]interface{}{
+			"container_id": containerID,
+			"image":        image,
+			"pushed":       false,
+		}
+
+		h.SetProgress(0, "committing container")
+		if err := s.containerManager.CommitContainer(ctx, containerID, image, opts); err != nil {
+			return result, err
+		}
+		h.Log(fmt.Sprintf("committed %s as %s", containerID, image))
+
+		if !req.Push {
+			return result, nil
+		}
+
+		h.SetProgress(10, "pushing image")
+		digest, err := s.containerManager.PushImage(ctx, image, req.RegistryAuth, func(done, total int, line string) {
+			h.Log(line)
+			if total > 0 {
+				h.SetProgress(10+90*float64(done)/float64(total), fmt.Sprintf("pushed %d/%d layers", done, total))
+			}
+		})
+		if err != nil {
+			return result, err
+		}
+
+		result["pushed"] = true
+		result["digest"] = digest
+		return result, nil
+	})
+
+	c.JSON(http.StatusAccepted, JobResponse{
+		JobID: job.ID,
+		Image: image,
+	})
+}
+
+// createContainerAsync 以异步任务创建已通过验证的容器，任务结果包含容器ID、发布端口与GPU选择依据
+// release 在创建完成后释放子租户的配额预留
+func (s *Server) createContainerAsync(c *gin.Context, req *container.CreateRequest, release func()) {
+	// 与同步创建一样不随请求取消，但保留链路上下文
+	ctx := tracing.Detach(c.Request.Context())
+	job := s.jobs.SubmitForTenant(req.Tenant, "container.create", func(_ context.Context, h *jobs.Handle) (map[string]interface{}, error) {
+		result := map[string]interface{}{"claim_id": req.ClaimID}
+
+		h.SetProgress(0, "creating container")
+		containerID, err := s.containerManager.CreateContainer(ctx, req)
+		release()
+		if err != nil {
+			return result, err
+		}
+		result["container_id"] = containerID
+		if info, exists := s.containerManager.GetContainer(containerID); exists {
+			if len(info.PublishedPorts) > 0 {
+				result["published_ports"] = info.PublishedPorts
+			}
+			if info.GPUSelection != nil {
+				result["gpu_selection"] = info.GPUSelection
+			}
+		}
+		h.Log(fmt.Sprintf("created container %s for claim %s", containerID, req.ClaimID))
+		return result, nil
+	})
+
+	c.JSON(http.StatusAccepted, JobResponse{JobID: job.ID})
+}
+
+// listJobs 列出异步任务
+func (s *Server) listJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, s.jobs.List())
+}
+
+// getJob 获取异步任务状态
+func (s *Server) getJob(c *gin.Context) {
+	job, exists := s.jobs.Get(c.Param("id"))
+	// 子租户只能查看自己提交的任务
+	if tenant := requestTenant(c); exists && tenant != "" && job.Tenant != tenant {
+		exists = false
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Job not found",
+			Code:  404,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
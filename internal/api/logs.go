@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"utopia-node-agent/internal/container"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultLogTail 未指定tail时返回的日志行数
+const defaultLogTail = 100
+
+// getContainerLogs 以纯文本返回容器的stdout与stderr
+// 支持 tail（行数或all）、since（Unix秒）、timestamps 与 follow 参数；follow=true 时持续推送直到容器停止或客户端断开
+func (s *Server) getContainerLogs(c *gin.Context) {
+	opts := container.LogOptions{
+		Tail:       defaultLogTail,
+		Follow:     c.Query("follow") == "true",
+		Timestamps: c.Query("timestamps") == "true",
+	}
+
+	var err error
+	if value := c.Query("tail"); value == "all" {
+		opts.Tail = 0
+	} else if value != "" {
+		opts.Tail, err = strconv.Atoi(value)
+		if err == nil && opts.Tail < 0 {
+			err = errors.New("tail must be non-negative")
+		}
+	}
+	if value := c.Query("since"); value != "" && err == nil {
+		var since int64
+		if since, err = strconv.ParseInt(value, 10, 64); err == nil {
+			opts.Since = time.Unix(since, 0)
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid query parameters",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if _, exists := s.visibleContainer(c, c.Param("id")); !exists {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Container not found",
+			Code:  404,
+		})
+		return
+	}
+
+	logs, err := s.containerManager.ContainerLogs(c.Request.Context(), c.Param("id"), opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to read container logs",
+			Code:    500,
+			Details: err.Error(),
+		})
+		return
+	}
+	defer logs.Close()
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if !opts.Follow {
+		io.Copy(c.Writer, logs)
+		return
+	}
+
+	// 持续输出时每次读取后立即刷新
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := logs.Read(buf)
+		if n > 0 {
+			if _, writeErr := c.Writer.Write(buf[:n]); writeErr != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
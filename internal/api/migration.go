@@ -0,0 +1,356 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/jobs"
+	"utopia-node-agent/internal/migration"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportRequest claim迁移导出请求（由平台协调，目标端点指向目标节点Agent或对象存储）
+type ExportRequest struct {
+	MigrationID string             `json:"migration_id" binding:"required"`
+	Image       migration.Endpoint `json:"image" binding:"required"`
+	Volumes     []VolumeExport     `json:"volumes,omitempty"`
+	// 导出前停止容器，保证卷数据一致（容器保持停止状态）
+	Stop bool `json:"stop,omitempty"`
+	// 提交期间是否暂停容器，默认true
+	Pause *bool `json:"pause,omitempty"`
+}
+
+// VolumeExport 要导出的容器挂载
+type VolumeExport struct {
+	// 容器内挂载路径
+	MountPath string `json:"mount_path" binding:"required"`
+	// 目标端卷名，导入时使用
+	Name   string             `json:"name" binding:"required"`
+	Target migration.Endpoint `json:"target" binding:"required"`
+}
+
+// ImportRequest 从对象存储导入迁移数据的请求
+type ImportRequest struct {
+	Image *migration.Endpoint `json:"image,omitempty"`
+	// 卷名 -> 数据源
+	Volumes map[string]migration.Endpoint `json:"volumes,omitempty"`
+}
+
+// EnableMigration 启用claim迁移导出/导入，数据暂存在spoolDir
+func (s *Server) EnableMigration(spoolDir string) error {
+	transfer, err := migration.NewTransfer(spoolDir)
+	if err != nil {
+		return err
+	}
+	s.migration = transfer
+	return nil
+}
+
+// exportContainer 提交容器并将镜像与工作区卷上传到目标端点（异步任务）
+func (s *Server) exportContainer(c *gin.Context) {
+	if !s.migrationEnabled(c) {
+		return
+	}
+
+	containerID := c.Param("id")
+	info, exists := s.containerManager.GetContainer(containerID)
+	if !exists {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Container not found",
+			Code:  404,
+		})
+		return
+	}
+
+	var req ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := validateExport(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid export request",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	image := s.containerManager.DefaultImageReference(info.ClaimID, "migration-"+req.MigrationID)
+	opts := container.CommitOptions{
+		Message: "migration " + req.MigrationID,
+		Pause:   req.Pause == nil || *req.Pause,
+	}
+
+	job := s.jobs.Submit("container.export", func(ctx context.Context, h *jobs.Handle) (map[string]interface{}, error) {
+		result := map[string]interface{}{
+			"container_id": containerID,
+			"migration_id": req.MigrationID,
+			"image":        image,
+		}
+
+		mounts, err := s.containerManager.ContainerMounts(ctx, containerID)
+		if err != nil {
+			return result, err
+		}
+		sources := make([]container.Mount, len(req.Volumes))
+		for i, volume := range req.Volumes {
+			mount, found := findMount(mounts, volume.MountPath)
+			if !found {
+				return result, fmt.Errorf("container has no mount at %s", volume.MountPath)
+			}
+			sources[i] = mount
+		}
+
+		if req.Stop {
+			h.SetProgress(0, "stopping container")
+			if err := s.containerManager.StopContainer(ctx, containerID); err != nil {
+				return result, err
+			}
+		}
+
+		h.SetProgress(5, "committing container")
+		if err := s.containerManager.CommitContainer(ctx, containerID, image, opts); err != nil {
+			return result, err
+		}
+		h.Log(fmt.Sprintf("committed %s as %s", containerID, image))
+
+		steps := float64(1 + len(req.Volumes))
+		var artifacts []migration.Artifact
+
+		h.SetProgress(10, "uploading image")
+		artifact, err := s.migration.Upload(ctx, "image", req.Image, func(w io.Writer) error {
+			return s.containerManager.SaveImage(ctx, image, w)
+		})
+		if err != nil {
+			return result, err
+		}
+		artifacts = append(artifacts, artifact)
+		h.Log(fmt.Sprintf("uploaded image (%d bytes, sha256 %s)", artifact.SizeBytes, artifact.SHA256))
+
+		for i, volume := range req.Volumes {
+			h.SetProgress(10+90*float64(i+1)/steps, "uploading volume "+volume.Name)
+			mount := sources[i]
+			artifact, err := s.migration.Upload(ctx, volume.Name, volume.Target, func(w io.Writer) error {
+				return container.ArchiveMount(ctx, mount, w)
+			})
+			if err != nil {
+				return result, err
+			}
+			artifacts = append(artifacts, artifact)
+			h.Log(fmt.Sprintf("uploaded volume %s from %s (%d bytes, sha256 %s)", volume.Name, volume.MountPath, artifact.SizeBytes, artifact.SHA256))
+		}
+
+		result["artifacts"] = artifacts
+		return result, nil
+	})
+
+	c.JSON(http.StatusAccepted, JobResponse{
+		JobID: job.ID,
+		Image: image,
+	})
+}
+
+// receiveImage 接收源节点直接上传的镜像归档并加载
+func (s *Server) receiveImage(c *gin.Context) {
+	if !s.migrationEnabled(c) || !s.validMigrationID(c) {
+		return
+	}
+
+	var image string
+	artifact, err := s.migration.Receive("image", c.Request.Body, c.GetHeader(migration.ChecksumHeader), func(r io.Reader) error {
+		var err error
+		image, err = s.containerManager.LoadImage(c.Request.Context(), r)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to import image",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"image":    image,
+		"artifact": artifact,
+	})
+}
+
+// receiveVolume 接收源节点直接上传的卷归档并恢复为docker卷
+func (s *Server) receiveVolume(c *gin.Context) {
+	if !s.migrationEnabled(c) || !s.validMigrationID(c) {
+		return
+	}
+	name := c.Param("name")
+	if err := migration.ValidateName(name); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid volume name",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var volume string
+	artifact, err := s.migration.Receive(name, c.Request.Body, c.GetHeader(migration.ChecksumHeader), func(r io.Reader) error {
+		var err error
+		volume, err = s.containerManager.RestoreVolume(c.Request.Context(), c.Param("id"), name, r)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to import volume",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"volume":   volume,
+		"artifact": artifact,
+	})
+}
+
+// importMigration 从对象存储下载迁移数据并导入（异步任务）
+func (s *Server) importMigration(c *gin.Context) {
+	if !s.migrationEnabled(c) || !s.validMigrationID(c) {
+		return
+	}
+	migrationID := c.Param("id")
+
+	var req ImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+	names := make([]string, 0, len(req.Volumes))
+	for name := range req.Volumes {
+		if err := migration.ValidateName(name); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid volume name",
+				Code:    400,
+				Details: err.Error(),
+			})
+			return
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	job := s.jobs.Submit("migration.import", func(ctx context.Context, h *jobs.Handle) (map[string]interface{}, error) {
+		result := map[string]interface{}{
+			"migration_id": migrationID,
+		}
+		steps := float64(len(names))
+
+		if req.Image != nil {
+			steps++
+			h.SetProgress(0, "importing image")
+			var image string
+			artifact, err := s.migration.Download(ctx, "image", *req.Image, func(r io.Reader) error {
+				var err error
+				image, err = s.containerManager.LoadImage(ctx, r)
+				return err
+			})
+			if err != nil {
+				return result, err
+			}
+			result["image"] = image
+			h.Log(fmt.Sprintf("loaded image %s (%d bytes)", image, artifact.SizeBytes))
+		}
+
+		volumes := make(map[string]string)
+		for i, name := range names {
+			h.SetProgress(100*float64(i)/steps, "importing volume "+name)
+			var volume string
+			artifact, err := s.migration.Download(ctx, name, req.Volumes[name], func(r io.Reader) error {
+				var err error
+				volume, err = s.containerManager.RestoreVolume(ctx, migrationID, name, r)
+				return err
+			})
+			if err != nil {
+				return result, err
+			}
+			volumes[name] = volume
+			h.Log(fmt.Sprintf("restored volume %s (%d bytes)", volume, artifact.SizeBytes))
+		}
+		result["volumes"] = volumes
+
+		return result, nil
+	})
+
+	c.JSON(http.StatusAccepted, JobResponse{JobID: job.ID})
+}
+
+// migrationEnabled 检查迁移功能是否启用，未启用时写入404
+func (s *Server) migrationEnabled(c *gin.Context) bool {
+	if s.migration == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Migration is disabled",
+			Code:  404,
+		})
+		return false
+	}
+	return true
+}
+
+// validMigrationID 检查路径中的迁移ID，无效时写入400
+func (s *Server) validMigrationID(c *gin.Context) bool {
+	if err := migration.ValidateName(c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid migration id",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// validateExport 验证导出请求
+func validateExport(req ExportRequest) error {
+	if err := migration.ValidateName(req.MigrationID); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, volume := range req.Volumes {
+		if err := migration.ValidateName(volume.Name); err != nil {
+			return err
+		}
+		if seen[volume.Name] {
+			return fmt.Errorf("duplicate volume name %q", volume.Name)
+		}
+		seen[volume.Name] = true
+		if !path.IsAbs(volume.MountPath) {
+			return fmt.Errorf("mount path %q must be absolute", volume.MountPath)
+		}
+	}
+	return nil
+}
+
+// findMount 按容器内路径查找挂载
+func findMount(mounts []container.Mount, mountPath string) (container.Mount, bool) {
+	for _, mount := range mounts {
+		if path.Clean(mount.Destination) == path.Clean(mountPath) {
+			return mount, true
+		}
+	}
+	return container.Mount{}, false
+}
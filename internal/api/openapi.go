@@ -0,0 +1,530 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/dataset"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/features"
+	"utopia-node-agent/internal/gpu"
+	"utopia-node-agent/internal/jobs"
+)
+
+// openAPIVersion 生成的文档遵循的OpenAPI规范版本
+const openAPIVersion = "3.0.3"
+
+// OpenAPIDocument OpenAPI文档
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Servers    []OpenAPIServer                         `json:"servers"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+	Security   []map[string][]string                   `json:"security"`
+}
+
+// OpenAPIInfo 文档描述的API
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIServer API的基础路径
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIComponents 可复用的结构与认证方式
+type OpenAPIComponents struct {
+	Schemas         map[string]*Schema                `json:"schemas"`
+	SecuritySchemes map[string]map[string]interface{} `json:"securitySchemes"`
+}
+
+// OpenAPIOperation 一个端点
+type OpenAPIOperation struct {
+	Summary     string                      `json:"summary,omitempty"`
+	OperationID string                      `json:"operationId"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter 路径或查询参数
+type OpenAPIParameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// OpenAPIRequestBody 请求体
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse 响应
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType 请求或响应体的结构
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema 由Go类型生成的JSON Schema（OpenAPI 3.0子集），同时用于验证请求体
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Deprecated           bool               `json:"deprecated,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// queryParam 端点接受的查询参数
+type queryParam struct {
+	Name string
+	Type string
+}
+
+// oneOf 响应体为其中一种类型
+type oneOf []interface{}
+
+// apiOperation 端点的请求与响应类型，路径相对于 /api/{version}，与gin路由写法相同
+type apiOperation struct {
+	Method  string
+	Path    string
+	Summary string
+	Query   []queryParam
+	// JSON请求体类型的零值，nil表示没有JSON请求体；RequestOptional表示可以不带请求体
+	Request         interface{}
+	RequestOptional bool
+	// 成功状态码到响应体类型的零值，值为nil表示没有响应体
+	Responses map[int]interface{}
+	// 非JSON的成功响应的媒体类型
+	Produces string
+}
+
+// apiOperations 已描述请求与响应类型的端点
+// 未列出的路由仍出现在文档中，只是没有请求与响应结构
+var apiOperations = []apiOperation{
+	{Method: "POST", Path: "/containers", Summary: "创建容器", Query: []queryParam{{"async", "boolean"}},
+		Request:   container.CreateRequest{},
+		Responses: map[int]interface{}{201: CreateContainerResponse{}, 202: oneOf{JobResponse{}, container.Schedule{}}}},
+	{Method: "POST", Path: "/containers/batch", Summary: "批量创建容器，全部成功或全部回滚",
+		Request: BatchCreateRequest{}, Responses: map[int]interface{}{201: BatchCreateResponse{}}},
+	{Method: "DELETE", Path: "/containers/:id", Summary: "删除容器", Responses: map[int]interface{}{204: nil}},
+	{Method: "GET", Path: "/containers", Summary: "列出容器",
+		Query: []queryParam{{"status", "string"}, {"claim_id", "string"}, {"label", "string"}, {"sort", "string"},
+			{"gpu_id", "integer"}, {"created_since", "integer"}, {"limit", "integer"}, {"offset", "integer"}},
+		Responses: map[int]interface{}{200: []container.ContainerInfo{}}},
+	{Method: "GET", Path: "/containers/:id", Summary: "获取容器信息", Responses: map[int]interface{}{200: container.ContainerInfo{}}},
+	{Method: "GET", Path: "/containers/:id/logs", Summary: "获取容器日志",
+		Query:     []queryParam{{"tail", "string"}, {"since", "integer"}, {"follow", "boolean"}, {"timestamps", "boolean"}},
+		Responses: map[int]interface{}{200: nil}, Produces: "text/plain"},
+	{Method: "POST", Path: "/containers/:id/commit", Summary: "将容器提交为镜像并推送",
+		Request: CommitContainerRequest{}, Responses: map[int]interface{}{202: JobResponse{}}},
+	{Method: "POST", Path: "/containers/:id/export", Summary: "导出容器用于迁移",
+		Request: ExportRequest{}, Responses: map[int]interface{}{202: JobResponse{}}},
+	{Method: "POST", Path: "/images/build", Summary: "构建镜像，以Server-Sent Events推送日志",
+		Request: BuildImageRequest{}, Responses: map[int]interface{}{200: nil}, Produces: "text/event-stream"},
+	{Method: "GET", Path: "/images/stats", Summary: "获取镜像拉取与启动耗时统计", Responses: map[int]interface{}{200: ImageStatsResponse{}}},
+	{Method: "POST", Path: "/claims/:id/hibernate", Summary: "休眠claim",
+		Request: HibernateRequest{}, RequestOptional: true, Responses: map[int]interface{}{200: container.Hibernation{}}},
+	{Method: "POST", Path: "/claims/:id/resume", Summary: "恢复休眠的claim", Responses: map[int]interface{}{204: nil}},
+	{Method: "GET", Path: "/claims/:id/hibernation", Summary: "获取claim的休眠记录", Responses: map[int]interface{}{200: container.Hibernation{}}},
+	{Method: "GET", Path: "/claims/:id/gpu-utilization", Summary: "获取claim最近的GPU利用率采样", Query: []queryParam{{"since", "integer"}},
+		Responses: map[int]interface{}{200: ClaimGPUUtilizationResponse{}}},
+	{Method: "GET", Path: "/claims/:id/gpu-utilization/stream", Summary: "以Server-Sent Events推送claim的GPU利用率", Query: []queryParam{{"since", "integer"}},
+		Responses: map[int]interface{}{200: nil}, Produces: "text/event-stream"},
+	{Method: "GET", Path: "/claims/:id/connection", Summary: "获取claim的SSH、Jupyter与VS Code连接信息", Responses: map[int]interface{}{200: ClaimConnectionResponse{}}},
+	{Method: "GET", Path: "/claims/:id/core-dumps", Summary: "列出claim收集到的核心转储", Responses: map[int]interface{}{200: CoreDumpsResponse{}}},
+	{Method: "GET", Path: "/claims/:id/core-dumps/:name", Summary: "下载claim的核心转储",
+		Responses: map[int]interface{}{200: nil}, Produces: "application/octet-stream"},
+	{Method: "GET", Path: "/datasets", Summary: "列出缓存的数据集", Responses: map[int]interface{}{200: []dataset.Entry{}}},
+	{Method: "DELETE", Path: "/datasets/:key", Summary: "删除缓存的数据集", Responses: map[int]interface{}{204: nil}},
+	{Method: "GET", Path: "/schedules", Summary: "列出定时启动的claim", Responses: map[int]interface{}{200: []container.Schedule{}}},
+	{Method: "GET", Path: "/schedules/:id", Summary: "获取定时启动计划", Responses: map[int]interface{}{200: container.Schedule{}}},
+	{Method: "DELETE", Path: "/schedules/:id", Summary: "取消定时启动计划", Responses: map[int]interface{}{200: container.Schedule{}}},
+	{Method: "GET", Path: "/events", Summary: "列出节点事件", Query: []queryParam{{"since", "integer"}, {"limit", "integer"}},
+		Responses: map[int]interface{}{200: []events.Event{}}},
+	{Method: "GET", Path: "/events/stream", Summary: "以Server-Sent Events推送节点事件", Query: []queryParam{{"since", "integer"}},
+		Responses: map[int]interface{}{200: nil}, Produces: "text/event-stream"},
+	{Method: "POST", Path: "/migrations/:id/import", Summary: "导入迁移数据",
+		Request: ImportRequest{}, Responses: map[int]interface{}{202: JobResponse{}}},
+	{Method: "GET", Path: "/jobs", Summary: "列出异步任务", Responses: map[int]interface{}{200: []jobs.Job{}}},
+	{Method: "GET", Path: "/jobs/:id", Summary: "获取异步任务", Responses: map[int]interface{}{200: jobs.Job{}}},
+	{Method: "GET", Path: "/metrics", Summary: "获取GPU与系统指标", Query: []queryParam{{"refresh", "boolean"}},
+		Responses: map[int]interface{}{200: MetricsResponse{}}},
+	{Method: "GET", Path: "/capacity", Summary: "获取节点可调度容量汇总", Responses: map[int]interface{}{200: CapacityResponse{}}},
+	{Method: "GET", Path: "/quota", Summary: "获取子租户配额与用量", Responses: map[int]interface{}{200: []TenantQuota{}}},
+	{Method: "GET", Path: "/info", Summary: "获取节点信息", Query: []queryParam{{"refresh", "boolean"}},
+		Responses: map[int]interface{}{200: InfoResponse{}}},
+	{Method: "GET", Path: "/topology", Summary: "获取GPU与NUMA拓扑", Responses: map[int]interface{}{200: gpu.Topology{}}},
+	{Method: "GET", Path: "/tunnels", Summary: "列出FRP隧道状态",
+		Query:     []queryParam{{"claim_id", "string"}, {"kind", "string"}, {"state", "string"}},
+		Responses: map[int]interface{}{200: TunnelsResponse{}}},
+	{Method: "GET", Path: "/gpus/:id/processes", Summary: "列出GPU上的计算进程", Responses: map[int]interface{}{200: GPUProcessesResponse{}}},
+	{Method: "DELETE", Path: "/gpus/:id/processes/:pid", Summary: "结束GPU上的计算进程", Query: []queryParam{{"force", "boolean"}},
+		Responses: map[int]interface{}{204: nil}},
+	{Method: "POST", Path: "/admin/restart", Summary: "无中断重启agent进程", Responses: map[int]interface{}{202: nil}},
+	{Method: "POST", Path: "/admin/power", Summary: "计划重启或关机",
+		Request: PowerRequest{}, Responses: map[int]interface{}{202: nil}},
+	{Method: "GET", Path: "/admin/planned-downtime", Summary: "查看计划停机", Responses: map[int]interface{}{200: PlannedDowntime{}}},
+	{Method: "PUT", Path: "/admin/planned-downtime", Summary: "设置停止时上报平台的预计停机时长",
+		Request: PlannedDowntimeRequest{}, Responses: map[int]interface{}{200: PlannedDowntime{}}},
+	{Method: "DELETE", Path: "/admin/planned-downtime", Summary: "取消计划停机", Responses: map[int]interface{}{204: nil}},
+	{Method: "POST", Path: "/admin/gpu/reattach", Summary: "重新加载GPU驱动",
+		Request: GPUReattachRequest{}, RequestOptional: true, Responses: map[int]interface{}{202: JobResponse{}}},
+	{Method: "POST", Path: "/admin/gpu/driver-upgrade", Summary: "升级GPU驱动",
+		Request: GPUDriverUpgradeRequest{}, RequestOptional: true, Responses: map[int]interface{}{202: JobResponse{}}},
+	{Method: "POST", Path: "/admin/selftest", Summary: "运行节点自检",
+		Request: SelfTestRequest{}, RequestOptional: true, Responses: map[int]interface{}{200: SelfTestReport{}}},
+	{Method: "GET", Path: "/admin/tasks", Summary: "列出后台任务状态", Responses: map[int]interface{}{200: TasksResponse{}}},
+	{Method: "GET", Path: "/admin/feature-flags", Summary: "列出功能开关", Responses: map[int]interface{}{200: []features.Flag{}}},
+	{Method: "PUT", Path: "/admin/feature-flags/:name", Summary: "设置功能开关",
+		Request: SetFeatureFlagRequest{}, Responses: map[int]interface{}{200: features.Flag{}}},
+	{Method: "DELETE", Path: "/admin/feature-flags/:name", Summary: "清除功能开关的覆盖", Responses: map[int]interface{}{200: features.Flag{}}},
+	{Method: "GET", Path: "/admin/debug/pprof/:profile", Summary: "采集agent进程的性能剖析数据或执行追踪",
+		Query:     []queryParam{{"seconds", "integer"}, {"debug", "integer"}, {"gc", "boolean"}},
+		Responses: map[int]interface{}{200: nil}, Produces: "application/octet-stream"},
+}
+
+// findOperation 返回方法与路由对应的端点描述
+func findOperation(method, path string) (apiOperation, bool) {
+	for _, op := range apiOperations {
+		if op.Method == method && op.Path == path {
+			return op, true
+		}
+	}
+	return apiOperation{}, false
+}
+
+// schemaGenerator 由Go类型生成Schema，结构体放入components并以$ref引用
+type schemaGenerator struct {
+	version string
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// newSchemaGenerator 创建指定API版本的生成器，版本决定弃用字段的标注与移除
+func newSchemaGenerator(version string) *schemaGenerator {
+	return &schemaGenerator{
+		version: version,
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	createRequestType = reflect.TypeOf(container.CreateRequest{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaFor 返回类型的Schema
+func (g *schemaGenerator) schemaFor(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64"}
+	case rawMessageType:
+		return &Schema{}
+	}
+	// 自定义JSON编码的类型无法从结构推断，文本编码的类型（如net.IP）为字符串
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return &Schema{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := g.schemaFor(t.Elem())
+		if schema.Ref != "" {
+			// OpenAPI 3.0中$ref的同级属性被忽略，可空引用需要包装
+			return &Schema{Nullable: true, AllOf: []*Schema{schema}}
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer", Format: intFormat(t)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Format: intFormat(t), Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte", Nullable: true}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem()), Nullable: true}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		return &Schema{Ref: "#/components/schemas/" + g.structName(t)}
+	default:
+		// interface{} 等任意值
+		return &Schema{}
+	}
+}
+
+// intFormat 返回整数类型的格式
+func intFormat(t reflect.Type) string {
+	if t.Bits() <= 32 {
+		return "int32"
+	}
+	return "int64"
+}
+
+// structName 返回结构体在components中的名称，首次遇到时生成其Schema
+// 不同包中的同名类型以包名为前缀区分
+func (g *schemaGenerator) structName(t reflect.Type) string {
+	if name, exists := g.names[t]; exists {
+		return name
+	}
+	name := t.Name()
+	if name == "" {
+		name = "Object"
+	}
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	g.names[t] = name
+	// 先占位以支持递归类型
+	schema := &Schema{Type: "object"}
+	g.schemas[name] = schema
+	g.fillStruct(schema, t)
+	return name
+}
+
+// fillStruct 按json标签生成结构体的属性，匿名嵌入的结构体字段提升到外层
+func (g *schemaGenerator) fillStruct(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.fillStruct(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schemaFor(field.Type)
+		if t == createRequestType {
+			if removed, deprecated := g.deprecation(name); removed {
+				continue
+			} else if deprecated {
+				copied := *property
+				copied.Deprecated = true
+				property = &copied
+			}
+		}
+		if schema.Properties == nil {
+			schema.Properties = make(map[string]*Schema)
+		}
+		schema.Properties[name] = property
+		if binding := field.Tag.Get("binding"); binding == "required" || strings.HasPrefix(binding, "required,") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// deprecation 返回创建请求字段在当前版本是否已移除或已弃用
+func (g *schemaGenerator) deprecation(field string) (removed, deprecated bool) {
+	current := versionIndex(g.version)
+	for _, d := range deprecations {
+		if d.Field != field || current < versionIndex(d.DeprecatedIn) {
+			continue
+		}
+		if d.RemovedIn != "" && current >= versionIndex(d.RemovedIn) {
+			return true, false
+		}
+		return false, true
+	}
+	return false, false
+}
+
+// resolve 返回$ref指向的Schema
+func (g *schemaGenerator) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = g.schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// openAPISpec 按已注册的路由生成指定API版本的OpenAPI文档与请求体Schema
+type openAPISpec struct {
+	document  *OpenAPIDocument
+	generator *schemaGenerator
+	// 按 "方法 路由" 索引的请求体Schema
+	requests map[string]*Schema
+}
+
+// buildOpenAPISpec 为指定API版本生成文档，路径来自实际注册的路由
+func buildOpenAPISpec(version string, routes gin.RoutesInfo) *openAPISpec {
+	g := newSchemaGenerator(version)
+	spec := &openAPISpec{generator: g, requests: make(map[string]*Schema)}
+	doc := &OpenAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    OpenAPIInfo{Title: "Utopia Node Agent API", Version: version},
+		Servers: []OpenAPIServer{{URL: "/api/" + version}},
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+		Components: OpenAPIComponents{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]map[string]interface{}{
+				"bearerAuth": {"type": "http", "scheme": "bearer"},
+			},
+		},
+		Security: []map[string][]string{{"bearerAuth": {}}},
+	}
+	errorSchema := g.schemaFor(reflect.TypeOf(ErrorResponse{}))
+
+	prefix := "/api/" + version
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	for _, route := range routes {
+		path, ok := strings.CutPrefix(route.Path, prefix)
+		if !ok || path == "/openapi.json" {
+			continue
+		}
+		op, _ := findOperation(route.Method, path)
+		operation := &OpenAPIOperation{
+			Summary:     op.Summary,
+			OperationID: operationID(route.Method, path),
+			Responses: map[string]*OpenAPIResponse{
+				"default": {Description: "Error", Content: jsonContent(errorSchema)},
+			},
+		}
+
+		openAPIPath, params := openAPIPathParams(path)
+		for _, name := range params {
+			operation.Parameters = append(operation.Parameters, OpenAPIParameter{
+				Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"},
+			})
+		}
+		for _, q := range op.Query {
+			operation.Parameters = append(operation.Parameters, OpenAPIParameter{
+				Name: q.Name, In: "query", Schema: &Schema{Type: q.Type},
+			})
+		}
+		if op.Request != nil {
+			schema := g.schemaFor(reflect.TypeOf(op.Request))
+			operation.RequestBody = &OpenAPIRequestBody{Required: !op.RequestOptional, Content: jsonContent(schema)}
+			spec.requests[route.Method+" "+route.Path] = schema
+		}
+		if len(op.Responses) == 0 {
+			operation.Responses["200"] = &OpenAPIResponse{Description: "OK"}
+		}
+		for status, body := range op.Responses {
+			response := &OpenAPIResponse{Description: http.StatusText(status)}
+			switch {
+			case op.Produces != "":
+				response.Content = map[string]OpenAPIMediaType{op.Produces: {Schema: &Schema{Type: "string"}}}
+			case body != nil:
+				response.Content = jsonContent(g.bodySchema(body))
+			}
+			operation.Responses[strconv.Itoa(status)] = response
+		}
+
+		if doc.Paths[openAPIPath] == nil {
+			doc.Paths[openAPIPath] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[openAPIPath][strings.ToLower(route.Method)] = operation
+	}
+	spec.document = doc
+	return spec
+}
+
+// bodySchema 返回响应体的Schema
+func (g *schemaGenerator) bodySchema(body interface{}) *Schema {
+	alternatives, ok := body.(oneOf)
+	if !ok {
+		return g.schemaFor(reflect.TypeOf(body))
+	}
+	schema := &Schema{}
+	for _, alternative := range alternatives {
+		schema.OneOf = append(schema.OneOf, g.schemaFor(reflect.TypeOf(alternative)))
+	}
+	return schema
+}
+
+// jsonContent 返回JSON媒体类型的内容描述
+func jsonContent(schema *Schema) map[string]OpenAPIMediaType {
+	return map[string]OpenAPIMediaType{"application/json": {Schema: schema}}
+}
+
+// openAPIPathParams 将gin路由中的 :name 参数转换为 {name}，返回转换后的路径与参数名
+func openAPIPathParams(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID 由方法与路径生成唯一的操作ID，如 get_containers_id_logs
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == ':' || r == '-' || r == '*' || r == '.' }) {
+		id += "_" + part
+	}
+	return id
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// 电源操作
+const (
+	PowerActionReboot   = "reboot"
+	PowerActionShutdown = "shutdown"
+	PowerActionSuspend  = "suspend"
+)
+
+var (
+	// ErrActiveClaims 节点上仍有运行中的claim
+	ErrActiveClaims = errors.New("node has active claims")
+	// ErrPowerActionUnsupported 节点未配置该电源操作
+	ErrPowerActionUnsupported = errors.New("power action not supported on this node")
+	// ErrPowerActionPending 已有待执行的电源操作
+	ErrPowerActionPending = errors.New("a power action is already scheduled")
+)
+
+// PowerController 电源管理接口（由agent实现）
+type PowerController interface {
+	// PowerAction 在delay后执行电源操作；force为false时若有运行中的claim返回 ErrActiveClaims
+	PowerAction(action string, force bool, delay time.Duration, reason string) error
+}
+
+// PowerRequest 电源操作请求
+type PowerRequest struct {
+	Action       string `json:"action" binding:"required"`
+	Force        bool   `json:"force,omitempty"`
+	DelaySeconds int    `json:"delay_seconds,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// EnablePower 启用远程电源管理，maxDelay为允许的最长延迟
+func (s *Server) EnablePower(controller PowerController, maxDelay time.Duration) {
+	s.power = controller
+	s.maxPowerDelay = maxDelay
+}
+
+// powerAction 请求节点重启、关机或休眠
+func (s *Server) powerAction(c *gin.Context) {
+	if s.power == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Power management is disabled",
+			Code:  404,
+		})
+		return
+	}
+
+	var req PowerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	switch req.Action {
+	case PowerActionReboot, PowerActionShutdown, PowerActionSuspend:
+	default:
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Action must be one of reboot, shutdown, suspend",
+			Code:  400,
+		})
+		return
+	}
+
+	delay := time.Duration(req.DelaySeconds) * time.Second
+	if req.DelaySeconds < 0 || delay > s.maxPowerDelay {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Delay out of range",
+			Code:  400,
+		})
+		return
+	}
+
+	err := s.power.PowerAction(req.Action, req.Force, delay, req.Reason)
+	switch {
+	case errors.Is(err, ErrActiveClaims):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Node has active claims, set force to override",
+			Code:    409,
+			Details: err.Error(),
+		})
+		return
+	case errors.Is(err, ErrPowerActionPending):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: "A power action is already scheduled",
+			Code:  409,
+		})
+		return
+	case errors.Is(err, ErrPowerActionUnsupported):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Power action not supported",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to schedule power action",
+			Code:    500,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	log.Warnf("Power action %q scheduled in %s by %s (force=%t, reason=%q)", req.Action, delay, c.ClientIP(), req.Force, req.Reason)
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":        "scheduled",
+		"action":        req.Action,
+		"delay_seconds": req.DelaySeconds,
+	})
+}
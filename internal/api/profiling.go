@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// 按时长采集的剖析数据的默认与最长时长（秒）
+const (
+	defaultCPUProfileSeconds = 30
+	defaultTraceSeconds      = 5
+	maxProfileSeconds        = 300
+)
+
+// 采集期间启用的阻塞与锁竞争采样率，平时关闭以免影响性能
+const (
+	blockProfileRate     = 10000
+	mutexProfileFraction = 5
+)
+
+// errProfileRunning 已有同类剖析数据在采集
+var errProfileRunning = errors.New("a profile of this kind is already being captured")
+
+// snapshotProfiles 即时导出的运行时剖析数据
+var snapshotProfiles = map[string]bool{"heap": true, "allocs": true, "goroutine": true, "threadcreate": true}
+
+// sampledProfiles 需在采集期间开启采样的剖析数据
+var sampledProfiles = map[string]bool{"block": true, "mutex": true}
+
+// getProfile 采集agent进程的剖析数据并以附件下载
+// profile（CPU）、trace 与 block/mutex 按 seconds 参数采集，其他剖析数据即时导出
+func (s *Server) getProfile(c *gin.Context) {
+	name := c.Param("profile")
+	if name != "profile" && name != "trace" && !snapshotProfiles[name] && !sampledProfiles[name] {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Unknown profile",
+			Code:    404,
+			Details: "supported profiles: profile, trace, heap, allocs, goroutine, threadcreate, block, mutex",
+		})
+		return
+	}
+	debug, err := strconv.Atoi(c.DefaultQuery("debug", "0"))
+	if err != nil || debug < 0 || debug > 2 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid debug parameter",
+			Code:  400,
+		})
+		return
+	}
+	defaultSeconds := defaultCPUProfileSeconds
+	if name == "trace" {
+		defaultSeconds = defaultTraceSeconds
+	}
+	seconds, err := strconv.Atoi(c.DefaultQuery("seconds", strconv.Itoa(defaultSeconds)))
+	if err != nil || seconds <= 0 || seconds > maxProfileSeconds {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid seconds parameter",
+			Code:    400,
+			Details: fmt.Sprintf("seconds must be between 1 and %d", maxProfileSeconds),
+		})
+		return
+	}
+	if snapshotProfiles[name] {
+		seconds = 0
+	}
+
+	var buf bytes.Buffer
+	switch {
+	case name == "profile":
+		err = captureCPUProfile(c, &buf, seconds)
+	case name == "trace":
+		err = captureTrace(c, &buf, seconds)
+	case sampledProfiles[name]:
+		err = s.captureSampledProfile(c, &buf, name, seconds, debug)
+	default:
+		if name == "heap" && c.Query("gc") == "true" {
+			runtime.GC()
+		}
+		err = pprof.Lookup(name).WriteTo(&buf, debug)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errProfileRunning) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, ErrorResponse{
+			Error:   "Failed to capture profile",
+			Code:    status,
+			Details: err.Error(),
+		})
+		return
+	}
+	if c.Request.Context().Err() != nil {
+		return
+	}
+
+	log.Infof("Captured %s profile (%ds) for %s", name, seconds, c.ClientIP())
+	contentType, extension := "application/octet-stream", "pprof"
+	switch {
+	case name == "trace":
+		extension = "trace"
+	case debug > 0 && name != "profile":
+		contentType, extension = "text/plain; charset=utf-8", "txt"
+	}
+	prefix := "utopia-agent"
+	if nodeID := s.nodeID(); nodeID != "" {
+		prefix += "-" + nodeID
+	}
+	filename := fmt.Sprintf("%s-%s-%s.%s", prefix, name, time.Now().UTC().Format("20060102T150405Z"), extension)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// captureCPUProfile 采集指定时长的CPU剖析数据，客户端断开时提前结束
+func captureCPUProfile(c *gin.Context, buf *bytes.Buffer, seconds int) error {
+	if err := pprof.StartCPUProfile(buf); err != nil {
+		return errProfileRunning
+	}
+	waitProfile(c, seconds)
+	pprof.StopCPUProfile()
+	return nil
+}
+
+// captureTrace 采集指定时长的运行时执行追踪
+func captureTrace(c *gin.Context, buf *bytes.Buffer, seconds int) error {
+	if err := trace.Start(buf); err != nil {
+		return errProfileRunning
+	}
+	waitProfile(c, seconds)
+	trace.Stop()
+	return nil
+}
+
+// captureSampledProfile 在采集期间开启阻塞或锁竞争采样，结束后恢复为关闭
+func (s *Server) captureSampledProfile(c *gin.Context, buf *bytes.Buffer, name string, seconds, debug int) error {
+	if !s.profileMu.TryLock() {
+		return errProfileRunning
+	}
+	defer s.profileMu.Unlock()
+
+	if name == "block" {
+		runtime.SetBlockProfileRate(blockProfileRate)
+		defer runtime.SetBlockProfileRate(0)
+	} else {
+		runtime.SetMutexProfileFraction(mutexProfileFraction)
+		defer runtime.SetMutexProfileFraction(0)
+	}
+	waitProfile(c, seconds)
+	return pprof.Lookup(name).WriteTo(buf, debug)
+}
+
+// waitProfile 等待采集时长结束或客户端断开
+func waitProfile(c *gin.Context, seconds int) {
+	timer := time.NewTimer(time.Duration(seconds) * time.Second)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-c.Request.Context().Done():
+	}
+}
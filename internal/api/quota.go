@@ -0,0 +1,240 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/quota"
+)
+
+// tenantContextKey 请求所属子租户在gin上下文中的键
+const tenantContextKey = "utopia.tenant"
+
+// tenantRoutes 子租户令牌可以访问的端点（方法 + 路由模板），其余端点返回403
+var tenantRoutes = map[string]bool{
+	"POST /containers":                 true,
+	"POST /containers/batch":           true,
+	"GET /containers":                  true,
+	"GET /containers/:id":              true,
+	"DELETE /containers/:id":           true,
+	"GET /containers/:id/logs":         true,
+	"GET /claims/:id/connection":       true,
+	"GET /claims/:id/core-dumps":       true,
+	"GET /claims/:id/core-dumps/:name": true,
+	"GET /jobs/:id":                    true,
+	"GET /metrics":                     true,
+	"GET /quota":                       true,
+}
+
+// QuotaExceededResponse 子租户配额或创建速率超限的响应
+type QuotaExceededResponse struct {
+	ErrorResponse
+	Tenant    string       `json:"tenant"`
+	Resource  string       `json:"resource"`
+	Limit     int          `json:"limit"`
+	Used      int          `json:"used"`
+	Requested int          `json:"requested"`
+	Usage     quota.Usage  `json:"usage"`
+	Limits    quota.Limits `json:"limits"`
+}
+
+// TenantQuota 子租户的配额与当前占用
+type TenantQuota struct {
+	Tenant string       `json:"tenant"`
+	Limits quota.Limits `json:"limits"`
+	Usage  quota.Usage  `json:"usage"`
+}
+
+// SetTenants 设置子租户令牌与配额，替换之前的设置
+func (s *Server) SetTenants(tenants []quota.Tenant) {
+	s.authMu.Lock()
+	defer s.authMu.Unlock()
+	s.tenants = tenants
+	if s.quota == nil {
+		s.quota = quota.NewTracker()
+	}
+}
+
+// tenantForToken 按令牌查找子租户
+func (s *Server) tenantForToken(token string) (quota.Tenant, bool) {
+	s.authMu.RLock()
+	defer s.authMu.RUnlock()
+	for _, tenant := range s.tenants {
+		if token == tenant.Token {
+			return tenant, true
+		}
+	}
+	return quota.Tenant{}, false
+}
+
+// tenantByName 按名称查找子租户
+func (s *Server) tenantByName(name string) (quota.Tenant, bool) {
+	s.authMu.RLock()
+	defer s.authMu.RUnlock()
+	for _, tenant := range s.tenants {
+		if tenant.Name == name {
+			return tenant, true
+		}
+	}
+	return quota.Tenant{}, false
+}
+
+// requestTenant 返回请求所属的子租户，平台令牌与本地套接字请求返回空字符串
+func requestTenant(c *gin.Context) string {
+	return c.GetString(tenantContextKey)
+}
+
+// tenantScopeMiddleware 限制子租户令牌只能访问容器相关端点
+func tenantScopeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if requestTenant(c) == "" {
+			c.Next()
+			return
+		}
+		// 路由模板形如 /api/v1/containers/:id，去掉版本前缀后匹配
+		if !tenantRoutes[c.Request.Method+" "+unversionedPath(c.FullPath())] {
+			c.JSON(http.StatusForbidden, ErrorResponse{
+				Error: "Endpoint is not available to tenant tokens",
+				Code:  403,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// visibleContainer 查找请求方可见的容器，子租户只能看到自己创建的容器
+func (s *Server) visibleContainer(c *gin.Context, containerID string) (container.ContainerInfo, bool) {
+	info, exists := s.containerManager.GetContainer(containerID)
+	if !exists {
+		return container.ContainerInfo{}, false
+	}
+	if tenant := requestTenant(c); tenant != "" && info.Tenant() != tenant {
+		return container.ContainerInfo{}, false
+	}
+	return info, true
+}
+
+// tenantUsage 统计子租户已创建容器的占用
+func (s *Server) tenantUsage(tenant string) quota.Usage {
+	var usage quota.Usage
+	for _, info := range s.containerManager.ListContainers() {
+		if info.Tenant() != tenant {
+			continue
+		}
+		usage.Containers++
+		usage.GPUs += len(info.GPUIDs)
+		usage.DiskGB += info.StorageSizeGB()
+	}
+	return usage
+}
+
+// reserveQuota 为子租户的创建请求标记租户并预留配额，超限时写入错误响应
+// 平台令牌的请求不受配额限制；返回的释放函数需在创建完成后调用
+func (s *Server) reserveQuota(c *gin.Context, reqs []*container.CreateRequest) (release func(), ok bool) {
+	name := requestTenant(c)
+	if name == "" {
+		return func() {}, true
+	}
+	tenant, exists := s.tenantByName(name)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error: "Invalid token",
+			Code:  401,
+		})
+		return nil, false
+	}
+
+	var requested quota.Usage
+	for _, req := range reqs {
+		if req.StartAt > time.Now().Unix() {
+			c.JSON(http.StatusForbidden, ErrorResponse{
+				Error: "Scheduled creation is not available to tenant tokens",
+				Code:  403,
+			})
+			return nil, false
+		}
+		size := s.containerManager.StorageSizeGB(req)
+		if tenant.Limits.MaxDiskGB > 0 && size == 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "storage_size_gb is required",
+				Code:    400,
+				Details: "the tenant has a disk quota and the node has no default storage size",
+			})
+			return nil, false
+		}
+		req.Tenant = tenant.Name
+		requested.Containers++
+		requested.GPUs += req.GPUCount
+		requested.DiskGB += size
+	}
+
+	release, err := s.quota.Reserve(tenant, s.tenantUsage(tenant.Name), requested, len(reqs))
+	if err != nil {
+		respondQuotaExceeded(c, err)
+		return nil, false
+	}
+	return release, true
+}
+
+// respondQuotaExceeded 返回配额超限响应：创建速率超限为429，资源配额超限为403
+func respondQuotaExceeded(c *gin.Context, err error) {
+	var exceeded *quota.ExceededError
+	if !errors.As(err, &exceeded) {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check quota",
+			Code:    500,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	status := http.StatusForbidden
+	if exceeded.Resource == quota.ResourceRate {
+		status = http.StatusTooManyRequests
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(exceeded.RetryAfter.Seconds()))))
+	}
+	c.JSON(status, QuotaExceededResponse{
+		ErrorResponse: ErrorResponse{
+			Error:   "Tenant quota exceeded",
+			Code:    status,
+			Details: fmt.Sprintf("%s: used %d, requested %d, limit %d", exceeded.Resource, exceeded.Used, exceeded.Requested, exceeded.Limit),
+		},
+		Tenant:    exceeded.Tenant,
+		Resource:  exceeded.Resource,
+		Limit:     exceeded.Limit,
+		Used:      exceeded.Used,
+		Requested: exceeded.Requested,
+		Usage:     exceeded.Usage,
+		Limits:    exceeded.Limits,
+	})
+}
+
+// getQuota 返回子租户的配额与占用，子租户只能看到自己
+func (s *Server) getQuota(c *gin.Context) {
+	s.authMu.RLock()
+	tenants := append([]quota.Tenant(nil), s.tenants...)
+	s.authMu.RUnlock()
+
+	name := requestTenant(c)
+	result := make([]TenantQuota, 0, len(tenants))
+	for _, tenant := range tenants {
+		if name != "" && tenant.Name != name {
+			continue
+		}
+		result = append(result, TenantQuota{
+			Tenant: tenant.Name,
+			Limits: tenant.Limits,
+			Usage:  s.quota.Usage(tenant.Name, s.tenantUsage(tenant.Name)),
+		})
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"utopia-node-agent/internal/tracing"
+)
+
+// requestIDKey gin上下文中保存请求ID的键
+const requestIDKey = "request_id"
+
+// requestIDWriter 在错误响应的JSON对象中加入请求ID
+type requestIDWriter struct {
+	gin.ResponseWriter
+	requestID string
+	written   bool
+}
+
+// Write 写出响应体，首次写出的错误JSON响应加入 request_id 字段
+func (w *requestIDWriter) Write(data []byte) (int, error) {
+	if w.written || w.Status() < http.StatusBadRequest ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") || !bytes.HasPrefix(data, []byte("{")) {
+		w.written = true
+		return w.ResponseWriter.Write(data)
+	}
+	w.written = true
+
+	field := `"request_id":` + strconv.Quote(w.requestID)
+	if !bytes.HasPrefix(data, []byte("{}")) {
+		field += ","
+	}
+	if _, err := w.ResponseWriter.Write(append([]byte("{"+field), data[1:]...)); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// WriteString 写出字符串响应体
+func (w *requestIDWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// requestIDMiddleware 沿用平台传入的 X-Request-ID 或生成新的请求ID，
+// 写入响应头、请求上下文与错误响应，并记录请求日志，便于关联隧道两端的日志
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 未带版本的请求转发时再次经过中间件，沿用已分配的请求ID且不重复记录日志
+		if id := tracing.RequestID(c.Request.Context()); id != "" {
+			c.Set(requestIDKey, id)
+			c.Writer = &requestIDWriter{ResponseWriter: c.Writer, requestID: id}
+			c.Next()
+			return
+		}
+
+		id := c.GetHeader(tracing.RequestIDHeader)
+		if !tracing.ValidRequestID(id) {
+			id = tracing.NewRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(tracing.RequestIDHeader, id)
+
+		ctx := tracing.WithRequestID(c.Request.Context(), id)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("utopia.request_id", id))
+		c.Request = c.Request.WithContext(ctx)
+		c.Writer = &requestIDWriter{ResponseWriter: c.Writer, requestID: id}
+
+		start := time.Now()
+		c.Next()
+
+		entry := tracing.Logger(ctx).WithFields(map[string]interface{}{
+			"method":      c.Request.Method,
+			"path":        c.Request.URL.Path,
+			"status":      c.Writer.Status(),
+			"duration_ms": time.Since(start).Milliseconds(),
+			"client":      c.ClientIP(),
+		})
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			entry.Warn("API request failed")
+		case status >= http.StatusBadRequest:
+			entry.Info("API request rejected")
+		default:
+			entry.Debug("API request")
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"utopia-node-agent/internal/container"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scheduleContainer 登记计划启动的claim，返回202与定时启动状态
+func (s *Server) scheduleContainer(c *gin.Context, req *container.CreateRequest) {
+	// 启动时的可用GPU无法预知，只检查节点GPU总数
+	if total := len(s.gpuMonitor.GetGPUInfo()); !req.SharesGPU() && req.GPUCount > total {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: fmt.Sprintf("Not enough GPUs on this node: requested %d, total %d", req.GPUCount, total),
+			Code:  409,
+		})
+		return
+	}
+
+	schedule, err := s.containerManager.ScheduleContainer(req)
+	switch {
+	case errors.Is(err, container.ErrSchedulesDisabled):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Scheduled claims are disabled on this node",
+			Code:  400,
+		})
+		return
+	case errors.Is(err, container.ErrScheduleTooFar):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Start time is too far in the future",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	case errors.Is(err, container.ErrScheduleExists):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Claim is already scheduled",
+			Code:    409,
+			Details: err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to schedule container",
+			Code:    500,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, schedule)
+}
+
+// listSchedules 列出定时启动的claim
+func (s *Server) listSchedules(c *gin.Context) {
+	schedules, err := s.containerManager.ListSchedules()
+	if errors.Is(err, container.ErrSchedulesDisabled) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Scheduled claims are disabled",
+			Code:  404,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+// getSchedule 获取claim的定时启动状态
+func (s *Server) getSchedule(c *gin.Context) {
+	schedule, err := s.containerManager.GetSchedule(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Schedule not found",
+			Code:    404,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// cancelSchedule 取消尚未开始的定时启动
+func (s *Server) cancelSchedule(c *gin.Context) {
+	schedule, err := s.containerManager.CancelSchedule(c.Param("id"))
+	switch {
+	case errors.Is(err, container.ErrScheduleFinished):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Schedule has already started or finished",
+			Code:    409,
+			Details: err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Schedule not found",
+			Code:    404,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
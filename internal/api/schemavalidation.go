@@ -0,0 +1,210 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"utopia-node-agent/internal/container"
+)
+
+// maxValidatedBodyBytes 按Schema验证的JSON请求体上限，超过时交由处理函数按原方式解析
+const maxValidatedBodyBytes = 8 << 20
+
+// getOpenAPISpec 返回API版本的OpenAPI文档
+func (s *Server) getOpenAPISpec(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		spec := s.openAPISpecs[version]
+		if spec == nil {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error: "Not found",
+				Code:  404,
+			})
+			return
+		}
+		c.JSON(http.StatusOK, spec.document)
+	}
+}
+
+// buildOpenAPISpecs 按已注册的路由生成各API版本的文档，需在全部路由注册后调用
+func (s *Server) buildOpenAPISpecs() {
+	routes := s.engine.Routes()
+	s.openAPISpecs = make(map[string]*openAPISpec, len(apiVersions))
+	for _, version := range apiVersions {
+		s.openAPISpecs[version.Version] = buildOpenAPISpec(version.Version, routes)
+	}
+}
+
+// schemaValidationMiddleware 按OpenAPI文档中的Schema验证JSON请求体，一次返回全部字段错误
+// 未知字段不视为错误，以兼容携带新字段的平台请求；非JSON请求体（如multipart上传）不验证
+func (s *Server) schemaValidationMiddleware(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		spec := s.openAPISpecs[version]
+		if spec == nil || c.Request.Body == nil || c.ContentType() == "multipart/form-data" {
+			c.Next()
+			return
+		}
+		schema := spec.requests[c.Request.Method+" "+c.FullPath()]
+		if schema == nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxValidatedBodyBytes+1))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request body",
+				Code:    400,
+				Details: err.Error(),
+			})
+			return
+		}
+		c.Request.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+		if len(bytes.TrimSpace(body)) == 0 || len(body) > maxValidatedBodyBytes {
+			c.Next()
+			return
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request body",
+				Code:    400,
+				Details: err.Error(),
+			})
+			return
+		}
+
+		var fields []container.FieldError
+		spec.generator.validate(schema, value, "", &fields)
+		if len(fields) > 0 {
+			s.respondInvalidRequest(c, &container.ValidationError{Fields: fields})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// validate 按Schema验证解码后的JSON值，字段错误追加到fields
+func (g *schemaGenerator) validate(schema *Schema, value interface{}, path string, fields *[]container.FieldError) {
+	fail := func(message string) {
+		field := path
+		if field == "" {
+			field = "body"
+		}
+		*fields = append(*fields, container.FieldError{Field: field, Message: message})
+	}
+
+	schema = g.resolve(schema)
+	if schema == nil {
+		return
+	}
+	if value == nil {
+		if !schema.Nullable && schema.Type != "" {
+			fail("must not be null")
+		}
+		return
+	}
+	for _, part := range schema.AllOf {
+		g.validate(part, value, path, fields)
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range schema.Required {
+			if _, exists := object[name]; !exists {
+				*fields = append(*fields, container.FieldError{Field: joinFieldPath(path, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			item := object[name]
+			if property, exists := schema.Properties[name]; exists {
+				g.validate(property, item, joinFieldPath(path, name), fields)
+			} else if schema.AdditionalProperties != nil {
+				g.validate(schema.AdditionalProperties, item, joinFieldPath(path, name), fields)
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range array {
+			g.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i), fields)
+		}
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			fail("must be an integer")
+			return
+		}
+		n, err := number.Int64()
+		if err != nil {
+			fail("must be an integer")
+			return
+		}
+		if schema.Minimum != nil && float64(n) < *schema.Minimum {
+			fail(fmt.Sprintf("must be at least %g", *schema.Minimum))
+		}
+		if schema.Format == "int32" && (n < -1<<31 || n > 1<<31-1) {
+			fail("is out of range")
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			fail("must be a number")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		switch schema.Format {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+				fail("must be an RFC 3339 timestamp")
+			}
+		case "byte":
+			if _, err := base64.StdEncoding.DecodeString(text); err != nil {
+				fail("must be base64 encoded")
+			}
+		}
+	}
+}
+
+// joinFieldPath 拼接字段路径，如 containers[0].gpu_count
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return strings.Join([]string{path, name}, ".")
+}
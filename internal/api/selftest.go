@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrSelfTestRunning 已有自检在执行
+var ErrSelfTestRunning = errors.New("a self-test is already running")
+
+// 自检步骤的结果
+const (
+	SelfTestPassed  = "passed"
+	SelfTestFailed  = "failed"
+	SelfTestSkipped = "skipped"
+)
+
+// maxSelfTestTimeoutSeconds 请求可指定的最长自检时限
+const maxSelfTestTimeoutSeconds = 3600
+
+// SelfTestRequest 节点自检请求
+type SelfTestRequest struct {
+	// 测试容器镜像，为空时使用节点配置 self_test.image
+	Image string `json:"image,omitempty"`
+	// 自检时限（秒），0表示使用节点配置 self_test.timeout_seconds
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// SelfTestStep 自检步骤的结果
+type SelfTestStep struct {
+	Name string `json:"name"`
+	// passed、failed 或 skipped（前置步骤失败或不适用）
+	Status     string                 `json:"status"`
+	DurationMs int64                  `json:"duration_ms"`
+	Message    string                 `json:"message,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
+
+// SelfTestReport 节点自检报告
+type SelfTestReport struct {
+	// 全部步骤通过（或不适用）
+	Passed  bool   `json:"passed"`
+	NodeID  string `json:"node_id"`
+	Image   string `json:"image"`
+	ClaimID string `json:"claim_id"`
+	// 测试容器及其分配的GPU
+	ContainerID string         `json:"container_id,omitempty"`
+	GPUIDs      []int          `json:"gpu_ids,omitempty"`
+	StartedAt   int64          `json:"started_at"`
+	DurationMs  int64          `json:"duration_ms"`
+	Steps       []SelfTestStep `json:"steps"`
+}
+
+// SelfTester 节点自检接口（由agent实现）
+type SelfTester interface {
+	// SelfTest 运行测试容器并返回报告，已有自检执行时返回 ErrSelfTestRunning
+	SelfTest(ctx context.Context, req SelfTestRequest) (SelfTestReport, error)
+}
+
+// EnableSelfTest 启用节点自检端点
+func (s *Server) EnableSelfTest(tester SelfTester) {
+	s.selfTest = tester
+}
+
+// runSelfTest 经完整的创建与删除流程运行测试容器，同步返回自检报告
+// 自检未通过时同样返回200，由报告的 passed 与各步骤说明原因
+func (s *Server) runSelfTest(c *gin.Context) {
+	if s.selfTest == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Self-test is disabled",
+			Code:  404,
+		})
+		return
+	}
+
+	var req SelfTestRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request body",
+				Code:    400,
+				Details: err.Error(),
+			})
+			return
+		}
+	}
+	if req.TimeoutSeconds < 0 || req.TimeoutSeconds > maxSelfTestTimeoutSeconds {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Timeout out of range",
+			Code:  400,
+		})
+		return
+	}
+
+	log.Infof("Node self-test requested by %s", c.ClientIP())
+	report, err := s.selfTest.SelfTest(c.Request.Context(), req)
+	switch {
+	case errors.Is(err, ErrSelfTestRunning):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error: "A self-test is already running",
+			Code:  409,
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to run self-test",
+			Code:    500,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
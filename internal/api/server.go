@@ -2,39 +2,202 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"utopia-node-agent/internal/breakglass"
+	"utopia-node-agent/internal/claimdns"
 	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/features"
+	"utopia-node-agent/internal/frp"
 	"utopia-node-agent/internal/gpu"
+	"utopia-node-agent/internal/handover"
+	"utopia-node-agent/internal/hooks"
+	"utopia-node-agent/internal/idempotency"
+	"utopia-node-agent/internal/jobs"
+	"utopia-node-agent/internal/logship"
+	"utopia-node-agent/internal/metrics"
+	"utopia-node-agent/internal/migration"
+	"utopia-node-agent/internal/mounts"
+	"utopia-node-agent/internal/quota"
+	"utopia-node-agent/internal/registration"
+	"utopia-node-agent/internal/signing"
+	"utopia-node-agent/internal/supervisor"
 	"utopia-node-agent/internal/system"
+	"utopia-node-agent/internal/tracing"
+	"utopia-node-agent/internal/watch"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
 )
 
 // Server API服务器
 type Server struct {
-	engine           *gin.Engine
-	server           *http.Server
+	engine *gin.Engine
+	server *http.Server
+	// 管理端点的独立监听（未启用时adminAddress为空）
+	adminEngine      *gin.Engine
+	adminServer      *http.Server
+	adminAddress     string
+	adminToken       string
 	containerManager *container.Manager
 	gpuMonitor       *gpu.Monitor
 	systemMonitor    *system.Monitor
+	authMu           sync.RWMutex
 	authToken        string
+	tenants          []quota.Tenant
+	quota            *quota.Tracker
+	breakGlass       *breakglass.Manager
+	tokenVerifier    *signing.Verifier
+	commandVerifier  *signing.Verifier
+	node             NodeController
+	jobs             *jobs.Manager
+	events           *events.Bus
+	features         *features.Flags
+	power            PowerController
+	maxPowerDelay    time.Duration
+	migration        *migration.Transfer
+	idempotency      *idempotency.Cache
+	gpuMaintenance   GPUMaintenance
+	tasks            *supervisor.Supervisor
+	unixSocket       string
+	restart          RestartController
+	selfTest         SelfTester
+	// 阻塞与锁竞争采样同时只能有一个采集
+	profileMu sync.Mutex
+	// 旧agent进程交接的监听套接字，及本进程使用的监听套接字
+	inherited  *handover.Inherited
+	listenerMu sync.Mutex
+	listeners  []servedListener
+	// 各API版本的OpenAPI文档与请求体Schema
+	openAPISpecs map[string]*openAPISpec
+	socketUIDs   map[uint32]bool
+	socketGIDs   map[uint32]bool
+	// 缓存的GPU指标超过该时长视为过期（纳秒），0表示不判定
+	metricsMaxAge atomic.Int64
+}
+
+// InfoResponse 节点信息响应
+type InfoResponse struct {
+	NodeID       string                        `json:"node_id"`
+	AgentVersion string                        `json:"agent_version"`
+	Versions     *system.VersionInfo           `json:"versions"`
+	StorageQuota container.StorageQuotaSupport `json:"storage_quota"`
+	IOLimits     container.IOLimitSupport      `json:"io_limits"`
+	Security     container.SecurityStatus      `json:"security"`
+	// 可用的容器运行时类别
+	Runtimes []string `json:"runtimes"`
+	// 可挂载的外部存储类型，未启用外部存储卷时为空
+	ExternalVolumeTypes []string `json:"external_volume_types,omitempty"`
+	// GPU时间片共享的份额与余量，未启用时为空
+	TimeSlicing *container.TimeSlicingStatus `json:"time_slicing,omitempty"`
+	// 正在运行MPS守护进程的GPU
+	MPSGPUs  []int            `json:"mps_gpus,omitempty"`
+	Draining bool             `json:"draining"`
+	Boot     *system.BootInfo `json:"boot,omitempty"`
+	// 被监视的凭据文件及其最近一次加载结果
+	CredentialFiles []watch.FileStatus `json:"credential_files,omitempty"`
+	// 最近一次上报给平台的主机名与IP地址
+	Address *system.NodeAddress `json:"address,omitempty"`
+	// 指标输出的运行状态
+	MetricsSinks []metrics.SinkStatus `json:"metrics_sinks,omitempty"`
+	// 容器日志转发状态
+	LogShipping *logship.Status `json:"log_shipping,omitempty"`
+	// 平台验证公钥缓存状态
+	SigningKeys *signing.KeyCacheStatus `json:"signing_keys,omitempty"`
+	// frps服务器（主服务器与备用服务器）的探测状态
+	FRPServers []frp.ServerStatus `json:"frp_servers,omitempty"`
+	// claim DNS名称登记状态
+	ClaimDNS *claimdns.Status `json:"claim_dns,omitempty"`
+	// 运行时功能开关的当前状态
+	FeatureFlags []features.Flag `json:"feature_flags,omitempty"`
+	// 节点身份公钥（Ed25519，base64），对发往平台的请求签名
+	IdentityPublicKey string `json:"identity_public_key,omitempty"`
+	// 节点注册状态，平台不可达时以独立模式启动并在后台重试注册
+	Registration *registration.Status `json:"registration,omitempty"`
+	// 核心转储收集的生效情况
+	CoreDumps *container.CoreDumpStatus `json:"core_dumps,omitempty"`
+	// 主机端口划分：各GPU的端口段与动态端口段
+	PortPlan container.PortPlan `json:"port_plan"`
+}
+
+// NodeController 节点级操作接口（由agent实现）
+type NodeController interface {
+	// NodeID 节点ID
+	NodeID() string
+	// AgentVersion Agent版本号
+	AgentVersion() string
+	// IsDraining 节点是否正在排空（不再接受新容器）
+	IsDraining() bool
+	// Decommission 异步执行节点退役流程，完成后agent退出
+	Decommission() error
+	// BootInfo 本次系统启动的时间与原因
+	BootInfo() *system.BootInfo
+	// CredentialFiles 被监视的凭据文件状态
+	CredentialFiles() []watch.FileStatus
+	// NodeAddress 最近一次上报给平台的节点地址
+	NodeAddress() *system.NodeAddress
+	// MetricsSinks 指标输出状态
+	MetricsSinks() []metrics.SinkStatus
+	// LogShipping 容器日志转发状态
+	LogShipping() *logship.Status
+	// SigningKeys 平台验证公钥缓存状态
+	SigningKeys() *signing.KeyCacheStatus
+	// FRPServers frps服务器的探测状态
+	FRPServers() []frp.ServerStatus
+	// ClaimDNS claim DNS名称登记状态
+	ClaimDNS() *claimdns.Status
+	// Tunnels frpc隧道的状态
+	Tunnels(ctx context.Context) []frp.TunnelStatus
+	// IdentityPublicKey 节点身份公钥
+	IdentityPublicKey() string
+	// Registration 节点注册状态
+	Registration() *registration.Status
+	// PlannedDowntime 计划停机，未设置时返回nil
+	PlannedDowntime() *PlannedDowntime
+	// PlanDowntime 设置agent停止时随下线通知上报的预计停机时长
+	PlanDowntime(expected time.Duration, message string) *PlannedDowntime
+	// ClearPlannedDowntime 取消计划停机
+	ClearPlannedDowntime()
+}
+
+// wsUpgrader WebSocket升级器（请求已经过Bearer认证）
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
 // MetricsResponse 指标响应
 type MetricsResponse struct {
-	NodeID             string                `json:"node_id"`
-	CPUUsagePercent    float64               `json:"cpu_usage_percent"`
-	MemoryUsagePercent float64               `json:"memory_usage_percent"`
-	GPUs               []gpu.GPUInfo         `json:"gpus"`
-	System             *system.SystemMetrics `json:"system,omitempty"`
+	NodeID             string        `json:"node_id"`
+	CPUUsagePercent    float64       `json:"cpu_usage_percent"`
+	MemoryUsagePercent float64       `json:"memory_usage_percent"`
+	GPUs               []gpu.GPUInfo `json:"gpus"`
+	// GPU指标的采集时间，未超过后台刷新周期的两倍时stale为false
+	CollectedAt time.Time `json:"collected_at"`
+	Stale       bool      `json:"stale"`
+	// GPU功能不可用（降级模式）的原因
+	GPUUnavailableReason string                `json:"gpu_unavailable_reason,omitempty"`
+	System               *system.SystemMetrics `json:"system,omitempty"`
+	// 超分策略下已承诺资源与物理容量
+	Overcommit *container.OvercommitStatus `json:"overcommit,omitempty"`
 }
 
 // CreateContainerResponse 创建容器响应
 type CreateContainerResponse struct {
 	ContainerID string `json:"container_id"`
+	// 通过隧道对外发布的端口及访问地址
+	PublishedPorts []container.PublishedPort `json:"published_ports,omitempty"`
+	// GPU选择依据（按数量分配GPU时）
+	GPUSelection *gpu.Selection `json:"gpu_selection,omitempty"`
 }
 
 // ErrorResponse 错误响应
@@ -42,6 +205,8 @@ type ErrorResponse struct {
 	Error   string `json:"error"`
 	Code    int    `json:"code,omitempty"`
 	Details string `json:"details,omitempty"`
+	// 请求验证失败时的逐字段错误
+	Fields []container.FieldError `json:"fields,omitempty"`
 }
 
 // NewServer 创建新的API服务器
@@ -56,7 +221,10 @@ func NewServer(
 
 	// 添加中间件
 	engine.Use(gin.Recovery())
+	engine.Use(tracing.Middleware())
 	engine.Use(corsMiddleware())
+	engine.Use(gzipMiddleware())
+	engine.Use(requestIDMiddleware())
 
 	server := &Server{
 		engine:           engine,
@@ -68,6 +236,7 @@ func NewServer(
 
 	// 设置路由
 	server.setupRoutes()
+	server.setupAdminRoutes()
 
 	return server
 }
@@ -77,26 +246,140 @@ func (s *Server) setupRoutes() {
 	// 认证中间件
 	authMiddleware := s.authMiddleware()
 
-	// API v1 路由组
-	v1 := s.engine.Group("/api/v1")
-	v1.Use(authMiddleware)
+	// 各版本的API路由组，v2在定稿前与v1提供相同的端点
+	for _, version := range apiVersions {
+		group := s.engine.Group("/api/" + version.Version)
+		group.Use(versionMiddleware(version.Version), authMiddleware, tenantScopeMiddleware(), s.schemaValidationMiddleware(version.Version), s.idempotencyMiddleware(), s.commandSigningMiddleware())
+		s.registerRoutes(group)
 
-	// 容器管理
-	v1.POST("/containers", s.createContainer)
-	v1.DELETE("/containers/:id", s.removeContainer)
-	v1.GET("/containers", s.listContainers)
-	v1.GET("/containers/:id", s.getContainer)
+		// OpenAPI文档（不需要认证）
+		s.engine.GET("/api/"+version.Version+"/openapi.json", s.getOpenAPISpec(version.Version))
+	}
 
-	// 系统指标
-	v1.GET("/metrics", s.getMetrics)
+	// 未带版本的 /api/... 请求按Accept头协商版本
+	s.engine.NoRoute(s.routeUnversioned)
+
+	// API版本与能力发现（不需要认证）
+	s.engine.GET("/api/versions", s.getVersions)
 
 	// 健康检查（不需要认证）
 	s.engine.GET("/health", s.healthCheck)
+
+	s.buildOpenAPISpecs()
+}
+
+// registerRoutes 在API路由组中注册端点
+func (s *Server) registerRoutes(group *gin.RouterGroup) {
+	// 容器管理
+	group.POST("/containers", s.createContainer)
+	group.POST("/containers/batch", s.batchCreateContainers)
+	group.DELETE("/containers/:id", s.removeContainer)
+	group.GET("/containers", s.listContainers)
+	group.GET("/containers/:id", s.getContainer)
+	group.GET("/containers/:id/logs", s.getContainerLogs)
+	group.POST("/containers/:id/commit", s.commitContainer)
+	group.POST("/containers/:id/export", s.exportContainer)
+
+	// 用户Dockerfile镜像构建
+	group.POST("/images/build", s.buildImage)
+	group.GET("/images/stats", s.getImageStats)
+
+	// claim休眠
+	group.POST("/claims/:id/hibernate", s.hibernateClaim)
+	group.POST("/claims/:id/resume", s.resumeClaim)
+	group.GET("/claims/:id/hibernation", s.getHibernation)
+
+	// claim GPU利用率
+	group.GET("/claims/:id/gpu-utilization", s.getClaimGPUUtilization)
+	group.GET("/claims/:id/gpu-utilization/stream", s.streamClaimGPUUtilization)
+
+	// claim连接信息
+	group.GET("/claims/:id/connection", s.getClaimConnection)
+	group.GET("/claims/:id/core-dumps", s.listCoreDumps)
+	group.GET("/claims/:id/core-dumps/:name", s.downloadCoreDump)
+
+	// 共享数据集缓存
+	group.GET("/datasets", s.listDatasets)
+	group.DELETE("/datasets/:key", s.removeDataset)
+
+	// 定时启动的claim
+	group.GET("/schedules", s.listSchedules)
+	group.GET("/schedules/:id", s.getSchedule)
+	group.DELETE("/schedules/:id", s.cancelSchedule)
+
+	// 节点事件
+	group.GET("/events", s.listEvents)
+	group.GET("/events/stream", s.streamEvents)
+
+	// claim迁移导入
+	group.PUT("/migrations/:id/image", s.receiveImage)
+	group.PUT("/migrations/:id/volumes/:name", s.receiveVolume)
+	group.POST("/migrations/:id/import", s.importMigration)
+
+	// 异步任务
+	group.GET("/jobs", s.listJobs)
+	group.GET("/jobs/:id", s.getJob)
+
+	// 系统指标
+	group.GET("/metrics", s.getMetrics)
+
+	// 可调度容量汇总
+	group.GET("/capacity", s.getCapacity)
+
+	// 子租户配额
+	group.GET("/quota", s.getQuota)
+
+	// 节点信息
+	group.GET("/info", s.getInfo)
+
+	// GPU与NUMA拓扑
+	group.GET("/topology", s.getTopology)
+
+	// FRP隧道状态
+	group.GET("/tunnels", s.listTunnels)
+
+	// GPU计算进程
+	group.GET("/gpus/:id/processes", s.listGPUProcesses)
+	group.DELETE("/gpus/:id/processes/:pid", s.killGPUProcess)
+
+	// 管理端点，启用独立管理监听地址后只在该地址上提供
+	admin := group.Group("/admin")
+	admin.GET("/shell", s.openBreakGlassShell)
+	s.registerAdminRoutes(admin.Group("", s.adminOnMainMiddleware()))
+}
+
+// SetNodeController 设置节点级操作接口
+func (s *Server) SetNodeController(node NodeController) {
+	s.node = node
+}
+
+// EnableBreakGlass 启用运维应急Shell
+func (s *Server) EnableBreakGlass(manager *breakglass.Manager, verifier *signing.Verifier) {
+	s.breakGlass = manager
+	s.tokenVerifier = verifier
+}
+
+// SetAuthToken 替换API认证令牌，之后的请求使用新令牌验证
+func (s *Server) SetAuthToken(token string) {
+	s.authMu.Lock()
+	s.authToken = token
+	s.authMu.Unlock()
+}
+
+// SetMetricsMaxAge 设置缓存GPU指标的最长有效期，超过后响应标记为stale
+func (s *Server) SetMetricsMaxAge(maxAge time.Duration) {
+	s.metricsMaxAge.Store(int64(maxAge))
 }
 
 // authMiddleware 认证中间件
 func (s *Server) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Unix套接字上的root与允许的本地进程按对端凭据认证
+		if cred, ok := peerCredentials(c); ok && s.peerAllowed(cred) {
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{
@@ -117,7 +400,17 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 		}
 
 		token := strings.TrimPrefix(authHeader, "Bearer ")
-		if token != s.authToken {
+		s.authMu.RLock()
+		valid := token == s.authToken
+		s.authMu.RUnlock()
+		if !valid {
+			// 子租户令牌只能访问自己的容器，并受配额限制
+			if tenant, ok := s.tenantForToken(token); ok {
+				c.Set(tenantContextKey, tenant.Name)
+				valid = true
+			}
+		}
+		if !valid {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{
 				Error: "Invalid token",
 				Code:  401,
@@ -135,7 +428,8 @@ func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, DELETE, PUT, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, If-None-Match, Idempotency-Key, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Total-Count, ETag, API-Version, Deprecation, Warning, X-Request-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -148,6 +442,25 @@ func corsMiddleware() gin.HandlerFunc {
 
 // createContainer 创建容器
 func (s *Server) createContainer(c *gin.Context) {
+	if s.node != nil && s.node.IsDraining() {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Node is draining",
+			Code:  503,
+		})
+		return
+	}
+
+	// 异步创建为实验功能，需开启 async_create 功能开关
+	async := c.Query("async") == "true"
+	if async && (!s.featureEnabled(features.AsyncCreate) || s.jobs == nil) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Async creation is not enabled",
+			Code:    400,
+			Details: "enable the async_create feature flag to use ?async=true",
+		})
+		return
+	}
+
 	var req container.CreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
@@ -158,42 +471,251 @@ func (s *Server) createContainer(c *gin.Context) {
 		return
 	}
 
-	// 验证GPU数量是否合理
-	if req.GPUCount < 0 {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error: "GPU count must be non-negative",
-			Code:  400,
+	if !s.negotiateCreateRequests(c, []*container.CreateRequest{&req}, false) {
+		return
+	}
+
+	// 验证并拒绝不安全的请求字段，一次返回全部字段错误
+	if err := s.containerManager.ValidateCreateRequest(&req); err != nil {
+		s.respondInvalidRequest(c, err)
+		return
+	}
+
+	if req.GPUCount > 0 && !s.gpuMonitor.Available() {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "GPU support is unavailable on this node",
+			Code:    409,
+			Details: s.gpuMonitor.UnavailableReason(),
 		})
 		return
 	}
 
-	// 检查是否有足够的可用GPU
-	availableGPUs := s.gpuMonitor.GetAvailableGPUs()
-	if req.GPUCount > len(availableGPUs) {
+	// 子租户的请求检查配额，创建完成前计入预留
+	release, ok := s.reserveQuota(c, []*container.CreateRequest{&req})
+	if !ok {
+		return
+	}
+
+	// 计划在将来启动的claim只登记，到启动时间再创建容器
+	if req.StartAt > time.Now().Unix() {
+		release()
+		s.scheduleContainer(c, &req)
+		return
+	}
+
+	// 检查是否有足够的可用GPU（共享计算可复用已运行MPS的GPU，由容器管理器分配）
+	// 为其他claim定时启动预留的GPU不计入可用数量
+	available := len(s.gpuMonitor.GetAvailableGPUs()) - s.containerManager.ReservedGPUs(req.ClaimID)
+	if !req.SharesGPU() && req.GPUCount > available {
+		release()
 		c.JSON(http.StatusConflict, ErrorResponse{
-			Error: fmt.Sprintf("Not enough available GPUs: requested %d, available %d", req.GPUCount, len(availableGPUs)),
+			Error: fmt.Sprintf("Not enough available GPUs: requested %d, available %d", req.GPUCount, max(available, 0)),
 			Code:  409,
 		})
 		return
 	}
 
-	// 创建容器
-	ctx := context.Background()
+	if async {
+		s.createContainerAsync(c, &req, release)
+		return
+	}
+
+	// 创建容器（不随请求取消，但保留链路上下文）
+	ctx := tracing.Detach(c.Request.Context())
 	containerID, err := s.containerManager.CreateContainer(ctx, &req)
+	release()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Failed to create container",
-			Code:    500,
+		s.respondCreateError(c, err)
+		return
+	}
+
+	resp := CreateContainerResponse{ContainerID: containerID}
+	if info, exists := s.containerManager.GetContainer(containerID); exists {
+		resp.PublishedPorts = info.PublishedPorts
+		resp.GPUSelection = info.GPUSelection
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// respondCreateError 将容器创建错误映射为HTTP响应
+func (s *Server) respondCreateError(c *gin.Context, err error) {
+	if errors.Is(err, container.ErrInvalidRequest) {
+		s.respondInvalidRequest(c, err)
+		return
+	}
+	if errors.Is(err, container.ErrStorageQuotaUnsupported) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Storage size limit not supported on this node",
+			Code:    400,
 			Details: err.Error(),
 		})
 		return
 	}
-
-	c.JSON(http.StatusCreated, CreateContainerResponse{
-		ContainerID: containerID,
+	if errors.Is(err, container.ErrIOLimitsUnsupported) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Disk IO limits not supported on this node",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrRelaxationNotAllowed) {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Security relaxation not allowed on this node",
+			Code:    403,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrCommandNotAllowed) {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Container command not allowed on this node",
+			Code:    403,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrCreateTimeout) {
+		c.JSON(http.StatusGatewayTimeout, ErrorResponse{
+			Error:   "Container creation timed out",
+			Code:    504,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, hooks.ErrHookFailed) {
+		c.JSON(http.StatusPreconditionFailed, ErrorResponse{
+			Error:   "Container creation rejected by lifecycle hook",
+			Code:    412,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrRuntimeUnavailable) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Container runtime not available on this node",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrMPSDisabled) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Shared compute is disabled on this node",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrNoSharedGPU) {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "No GPU available for shared compute",
+			Code:    409,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrTimeSlicingDisabled) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "GPU time-slicing is disabled on this node",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrNoFractionalGPU) {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "No GPU available for a fractional claim",
+			Code:    409,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrOvercommit) {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Insufficient node resources under the overcommit policy",
+			Code:    409,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrSecretsDisabled) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Secrets delivery is disabled on this node",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrDatasetCacheDisabled) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Dataset cache is disabled on this node",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrExternalVolumesDisabled) || errors.Is(err, mounts.ErrTypeUnavailable) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "External volume is not available on this node",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrPortPublishingDisabled) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Port publishing is disabled on this node",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrPortConflict) {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Host port already in use",
+			Code:    409,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrNoHostPorts) {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "No host ports left for allocation",
+			Code:    409,
+			Details: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, container.ErrNoPublishPorts) {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "No remote ports left for publishing",
+			Code:    409,
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "Failed to create container",
+		Code:    500,
+		Details: err.Error(),
 	})
 }
 
+// respondInvalidRequest 返回创建请求的逐字段验证错误
+func (s *Server) respondInvalidRequest(c *gin.Context, err error) {
+	resp := ErrorResponse{
+		Error:   "Invalid request",
+		Code:    400,
+		Details: err.Error(),
+	}
+	var validationErr *container.ValidationError
+	if errors.As(err, &validationErr) {
+		resp.Fields = validationErr.Fields
+	}
+	c.JSON(http.StatusBadRequest, resp)
+}
+
 // removeContainer 删除容器
 func (s *Server) removeContainer(c *gin.Context) {
 	containerID := c.Param("id")
@@ -205,8 +727,29 @@ func (s *Server) removeContainer(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	info, visible := s.visibleContainer(c, containerID)
+	if !visible && requestTenant(c) != "" {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Container not found",
+			Code:  404,
+		})
+		return
+	}
+	// 按解析出的完整ID删除，避免名称或ID前缀在docker中指向其他容器
+	if visible {
+		containerID = info.ID
+	}
+
+	ctx := tracing.Detach(c.Request.Context())
 	if err := s.containerManager.RemoveContainer(ctx, containerID); err != nil {
+		if errors.Is(err, hooks.ErrHookFailed) {
+			c.JSON(http.StatusPreconditionFailed, ErrorResponse{
+				Error:   "Container removal rejected by lifecycle hook",
+				Code:    412,
+				Details: err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to remove container",
 			Code:    500,
@@ -218,10 +761,51 @@ func (s *Server) removeContainer(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-// listContainers 列出容器
+// listContainers 列出容器，支持过滤、排序与分页；过滤后的总数通过 X-Total-Count 头返回
+// 响应携带ETag，客户端可通过 If-None-Match 避免重复传输未变化的列表
 func (s *Server) listContainers(c *gin.Context) {
-	containers := s.containerManager.ListContainers()
-	c.JSON(http.StatusOK, containers)
+	query := container.ListQuery{
+		Status:         c.Query("status"),
+		ClaimID:        c.Query("claim_id"),
+		LabelSelectors: c.QueryArray("label"),
+		Sort:           c.Query("sort"),
+	}
+	// 子租户只能看到自己创建的容器
+	if tenant := requestTenant(c); tenant != "" {
+		query.LabelSelectors = append(query.LabelSelectors, container.TenantLabel+"="+tenant)
+	}
+
+	var err error
+	if value := c.Query("gpu_id"); value != "" {
+		var gpuID int
+		if gpuID, err = strconv.Atoi(value); err == nil {
+			query.GPUID = &gpuID
+		}
+	}
+	if value := c.Query("created_since"); value != "" && err == nil {
+		query.CreatedSince, err = strconv.ParseInt(value, 10, 64)
+	}
+	if value := c.Query("limit"); value != "" && err == nil {
+		query.Limit, err = strconv.Atoi(value)
+	}
+	if value := c.Query("offset"); value != "" && err == nil {
+		query.Offset, err = strconv.Atoi(value)
+	}
+	if err == nil {
+		err = query.Validate()
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid query parameters",
+			Code:    400,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	containers, total := s.containerManager.QueryContainers(query)
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	respondJSONWithETag(c, containers)
 }
 
 // getContainer 获取容器信息
@@ -235,7 +819,7 @@ func (s *Server) getContainer(c *gin.Context) {
 		return
 	}
 
-	container, exists := s.containerManager.GetContainer(containerID)
+	container, exists := s.visibleContainer(c, containerID)
 	if !exists {
 		c.JSON(http.StatusNotFound, ErrorResponse{
 			Error: "Container not found",
@@ -248,19 +832,22 @@ func (s *Server) getContainer(c *gin.Context) {
 }
 
 // getMetrics 获取系统指标
+// GPU指标默认返回后台刷新的缓存，refresh=true 时先刷新（与进行中的刷新合并）
 func (s *Server) getMetrics(c *gin.Context) {
-	// 刷新GPU信息
-	if err := s.gpuMonitor.RefreshGPUInfo(); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Failed to refresh GPU info",
-			Code:    500,
-			Details: err.Error(),
-		})
-		return
+	gpus, collectedAt := s.gpuMonitor.Snapshot()
+	if c.Query("refresh") == "true" || collectedAt.IsZero() {
+		if err := s.gpuMonitor.RefreshGPUInfo(); err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to refresh GPU info",
+				Code:    500,
+				Details: err.Error(),
+			})
+			return
+		}
+		gpus, collectedAt = s.gpuMonitor.Snapshot()
 	}
-
-	// 获取GPU信息
-	gpus := s.gpuMonitor.GetGPUInfo()
+	maxAge := time.Duration(s.metricsMaxAge.Load())
+	stale := collectedAt.IsZero() || (maxAge > 0 && time.Since(collectedAt) > maxAge)
 
 	// 获取系统指标
 	systemMetrics, err := s.systemMonitor.GetSystemMetrics()
@@ -276,16 +863,125 @@ func (s *Server) getMetrics(c *gin.Context) {
 	}
 
 	response := MetricsResponse{
-		NodeID:             nodeID,
-		CPUUsagePercent:    systemMetrics.CPUUsagePercent,
-		MemoryUsagePercent: systemMetrics.MemoryUsagePercent,
-		GPUs:               gpus,
-		System:             systemMetrics,
+		NodeID:               nodeID,
+		CPUUsagePercent:      systemMetrics.CPUUsagePercent,
+		MemoryUsagePercent:   systemMetrics.MemoryUsagePercent,
+		GPUs:                 gpus,
+		CollectedAt:          collectedAt,
+		Stale:                stale,
+		GPUUnavailableReason: s.gpuMonitor.UnavailableReason(),
+		System:               systemMetrics,
+	}
+	if s.containerManager != nil {
+		overcommit := s.containerManager.OvercommitStatus(c.Request.Context())
+		response.Overcommit = &overcommit
+	}
+
+	respondJSONWithETag(c, response)
+}
+
+// decommissionNode 退役节点：排空并删除所有容器、关闭隧道、向平台注销并删除本地身份
+func (s *Server) decommissionNode(c *gin.Context) {
+	if s.node == nil {
+		c.JSON(http.StatusNotImplemented, ErrorResponse{
+			Error: "Node controller not available",
+			Code:  501,
+		})
+		return
+	}
+
+	if err := s.node.Decommission(); err != nil {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Failed to start decommission",
+			Code:    409,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": "decommissioning",
+	})
+}
+
+// openBreakGlassShell 通过WebSocket打开主机应急Shell
+func (s *Server) openBreakGlassShell(c *gin.Context) {
+	if s.breakGlass == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Break-glass shell is disabled",
+			Code:  404,
+		})
+		return
+	}
+
+	// 令牌只从请求头读取，不放在URL中以免写入访问日志与链路追踪
+	token := c.GetHeader("X-Break-Glass-Token")
+	claims, err := s.tokenVerifier.Consume(token, breakglass.TokenPurpose, s.nodeID())
+	if err != nil {
+		log.Warnf("Rejected break-glass shell request from %s: %v", c.ClientIP(), err)
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Invalid break-glass token",
+			Code:    403,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade失败时已向客户端写入错误响应
+		return
+	}
+
+	if err := s.breakGlass.Serve(conn, claims); err != nil {
+		log.Errorf("Break-glass shell session %s failed: %v", claims.ID, err)
+	}
+}
+
+// getInfo 获取节点信息（组件版本等）
+func (s *Server) getInfo(c *gin.Context) {
+	response := InfoResponse{
+		NodeID:              s.nodeID(),
+		Versions:            s.systemMonitor.GetVersions(c.Query("refresh") == "true"),
+		StorageQuota:        s.containerManager.GetStorageQuotaSupport(),
+		IOLimits:            s.containerManager.GetIOLimitSupport(),
+		Security:            s.containerManager.GetSecurityStatus(),
+		Runtimes:            s.containerManager.AvailableRuntimes(),
+		MPSGPUs:             s.containerManager.MPSGPUs(),
+		ExternalVolumeTypes: s.containerManager.ExternalVolumeTypes(),
+		TimeSlicing:         s.containerManager.TimeSlicingStatus(),
+		CoreDumps:           s.containerManager.CoreDumpStatus(),
+		PortPlan:            s.containerManager.PortPlan(),
+	}
+	if s.features != nil {
+		response.FeatureFlags = s.features.List()
+	}
+	if s.node != nil {
+		response.AgentVersion = s.node.AgentVersion()
+		response.IdentityPublicKey = s.node.IdentityPublicKey()
+		response.Draining = s.node.IsDraining()
+		response.Boot = s.node.BootInfo()
+		response.CredentialFiles = s.node.CredentialFiles()
+		response.Address = s.node.NodeAddress()
+		response.MetricsSinks = s.node.MetricsSinks()
+		response.LogShipping = s.node.LogShipping()
+		response.SigningKeys = s.node.SigningKeys()
+		response.FRPServers = s.node.FRPServers()
+		response.ClaimDNS = s.node.ClaimDNS()
+		response.Registration = s.node.Registration()
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// nodeID 获取节点ID，未设置节点控制器时返回空字符串
+func (s *Server) nodeID() string {
+	if s.node == nil {
+		return ""
+	}
+	return s.node.NodeID()
+}
+
 // healthCheck 健康检查
 func (s *Server) healthCheck(c *gin.Context) {
 	// 检查GPU监控器
@@ -298,31 +994,136 @@ func (s *Server) healthCheck(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	// 仅CPU降级模式或有后台任务不健康时仍可提供服务
+	unhealthy := s.unhealthyTasks()
+	frpServer := s.activeFRPServer()
+	if !s.gpuMonitor.Available() || len(unhealthy) > 0 {
+		body := gin.H{
+			"status":    "degraded",
+			"timestamp": c.GetHeader("X-Request-Time"),
+		}
+		if frpServer != "" {
+			body["frp_server"] = frpServer
+		}
+		if !s.gpuMonitor.Available() {
+			body["gpu_unavailable_reason"] = s.gpuMonitor.UnavailableReason()
+		}
+		if len(unhealthy) > 0 {
+			body["unhealthy_tasks"] = unhealthy
+		}
+		c.JSON(http.StatusOK, body)
+		return
+	}
+
+	body := gin.H{
 		"status":    "healthy",
 		"timestamp": c.GetHeader("X-Request-Time"),
-	})
+	}
+	if frpServer != "" {
+		body["frp_server"] = frpServer
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// activeFRPServer 返回frpc当前连接的frps服务器地址
+func (s *Server) activeFRPServer() string {
+	if s.node == nil {
+		return ""
+	}
+	for _, server := range s.node.FRPServers() {
+		if server.Active {
+			return server.String()
+		}
+	}
+	return ""
 }
 
-// Start 启动服务器
-func (s *Server) Start(address string) error {
+// Start 在一个或多个地址上启动服务器（支持IPv4、IPv6与双栈）
+func (s *Server) Start(addresses ...string) error {
 	s.server = &http.Server{
-		Addr:    address,
-		Handler: s.engine,
+		Handler:     s.engine,
+		ConnContext: withPeerCredentials,
+	}
+
+	// 先创建全部监听器，任一失败则整体失败
+	var listeners []net.Listener
+	for _, address := range addresses {
+		listener, err := s.listen("tcp", address)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("failed to listen on %s: %w", address, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	if s.unixSocket != "" {
+		listener, err := s.listen("unix", s.unixSocket)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("failed to listen on %s: %w", s.unixSocket, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	var adminListener net.Listener
+	if s.adminListenerEnabled() {
+		listener, err := s.listen("tcp", s.adminAddress)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("failed to listen on admin address %s: %w", s.adminAddress, err)
+		}
+		adminListener = listener
+		s.adminServer = &http.Server{Handler: s.adminEngine}
 	}
 
-	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		return fmt.Errorf("failed to start server: %w", err)
+	errChan := make(chan error, len(listeners)+1)
+	for _, listener := range listeners {
+		go func(l net.Listener) {
+			errChan <- s.server.Serve(l)
+		}(listener)
+	}
+	served := len(listeners)
+	if adminListener != nil {
+		go func() {
+			errChan <- s.adminServer.Serve(adminListener)
+		}()
+		served++
+	}
+
+	var firstErr error
+	for i := 0; i < served; i++ {
+		if err := <-errChan; err != nil && err != http.ErrServerClosed && firstErr == nil {
+			firstErr = fmt.Errorf("failed to start server: %w", err)
+		}
 	}
 
-	return nil
+	return firstErr
 }
 
-// Stop 停止服务器
+// Stop 停止接受新连接并等待处理中的请求完成
+// ctx到期时强制关闭剩余的连接（如事件流与跟随的日志）
 func (s *Server) Stop(ctx context.Context) error {
 	if s.server == nil {
 		return nil
 	}
 
-	return s.server.Shutdown(ctx)
+	shutdown := func(server *http.Server) error {
+		err := server.Shutdown(ctx)
+		if err != nil {
+			server.Close()
+		}
+		return err
+	}
+	var adminErr error
+	if s.adminServer != nil {
+		adminErr = shutdown(s.adminServer)
+	}
+	if err := shutdown(s.server); err != nil {
+		return err
+	}
+	return adminErr
 }
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+
+	"utopia-node-agent/internal/supervisor"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TasksResponse 后台任务状态响应
+type TasksResponse struct {
+	Tasks []supervisor.TaskStatus `json:"tasks"`
+}
+
+// SetTaskSupervisor 设置后台任务监管器，用于健康检查与任务状态查询
+func (s *Server) SetTaskSupervisor(tasks *supervisor.Supervisor) {
+	s.tasks = tasks
+}
+
+// unhealthyTasks 返回不健康的后台任务名称
+func (s *Server) unhealthyTasks() []string {
+	if s.tasks == nil {
+		return nil
+	}
+	return s.tasks.Unhealthy()
+}
+
+// listTasks 列出后台任务的运行状态
+func (s *Server) listTasks(c *gin.Context) {
+	if s.tasks == nil {
+		c.JSON(http.StatusOK, TasksResponse{Tasks: []supervisor.TaskStatus{}})
+		return
+	}
+	c.JSON(http.StatusOK, TasksResponse{Tasks: s.tasks.Status()})
+}
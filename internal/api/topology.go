@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getTopology 获取GPU互联、NUMA节点与网卡拓扑，用于多GPU放置决策
+func (s *Server) getTopology(c *gin.Context) {
+	topology, err := s.gpuMonitor.Topology()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to query topology",
+			Code:    500,
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, topology)
+}
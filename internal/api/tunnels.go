@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"utopia-node-agent/internal/frp"
+)
+
+// TunnelsResponse 隧道列表响应
+type TunnelsResponse struct {
+	Tunnels []frp.TunnelStatus `json:"tunnels"`
+}
+
+// listTunnels 列出agent请求的frpc隧道、远端端口与连接状态，支持按 claim_id、kind 与 state 过滤
+func (s *Server) listTunnels(c *gin.Context) {
+	if s.node == nil {
+		c.JSON(http.StatusNotImplemented, ErrorResponse{
+			Error: "Node controller not available",
+			Code:  501,
+		})
+		return
+	}
+
+	claimID, kind, state := c.Query("claim_id"), c.Query("kind"), c.Query("state")
+	tunnels := make([]frp.TunnelStatus, 0)
+	for _, tunnel := range s.node.Tunnels(c.Request.Context()) {
+		if (claimID != "" && tunnel.ClaimID != claimID) ||
+			(kind != "" && tunnel.Kind != kind) ||
+			(state != "" && tunnel.State != state) {
+			continue
+		}
+		tunnels = append(tunnels, tunnel)
+	}
+	c.JSON(http.StatusOK, TunnelsResponse{Tunnels: tunnels})
+}
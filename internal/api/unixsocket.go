@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sys/unix"
+)
+
+// PeerCredentials Unix套接字对端进程的凭据
+type PeerCredentials struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+// peerCredentialsKey 连接上下文中保存对端凭据的键
+type peerCredentialsKey struct{}
+
+// EnableUnixSocket 在本地Unix套接字上提供API
+// root与允许的uid/gid的对端进程无需令牌即可访问，其他进程仍需提供令牌
+func (s *Server) EnableUnixSocket(path string, allowedUIDs, allowedGIDs []int) {
+	s.unixSocket = path
+	s.socketUIDs = make(map[uint32]bool)
+	s.socketGIDs = make(map[uint32]bool)
+	for _, uid := range allowedUIDs {
+		s.socketUIDs[uint32(uid)] = true
+	}
+	for _, gid := range allowedGIDs {
+		s.socketGIDs[uint32(gid)] = true
+	}
+}
+
+// listenUnix 创建Unix套接字监听器，替换残留的套接字文件
+// 套接字对所有本地用户可连接，访问控制由对端凭据与令牌完成
+func listenUnix(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0666); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	return listener, nil
+}
+
+// withPeerCredentials 为Unix套接字连接在上下文中记录对端凭据
+func withPeerCredentials(ctx context.Context, conn net.Conn) context.Context {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return ctx
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return ctx
+	}
+
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		credErr = err
+	}
+	if credErr != nil {
+		fmt.Printf("Warning: failed to read peer credentials of unix socket connection: %v\n", credErr)
+		return ctx
+	}
+	return context.WithValue(ctx, peerCredentialsKey{}, PeerCredentials{PID: cred.Pid, UID: cred.Uid, GID: cred.Gid})
+}
+
+// peerCredentials 返回请求所在Unix套接字连接的对端凭据
+func peerCredentials(c *gin.Context) (PeerCredentials, bool) {
+	cred, ok := c.Request.Context().Value(peerCredentialsKey{}).(PeerCredentials)
+	return cred, ok
+}
+
+// peerAllowed 判断对端进程是否可以免令牌访问
+func (s *Server) peerAllowed(cred PeerCredentials) bool {
+	return cred.UID == 0 || s.socketUIDs[cred.UID] || s.socketGIDs[cred.GID]
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"utopia-node-agent/internal/container"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClaimGPUUtilizationResponse claim最近的GPU利用率采样
+type ClaimGPUUtilizationResponse struct {
+	ClaimID         string                     `json:"claim_id"`
+	IntervalSeconds int                        `json:"interval_seconds"`
+	Samples         []container.ClaimGPUSample `json:"samples"`
+}
+
+// claimUtilizationParams 校验GPU利用率端点的claim与since参数，失败时已写入响应
+func (s *Server) claimUtilizationParams(c *gin.Context, cursor string) (claimID string, since int64, ok bool) {
+	if s.containerManager == nil || s.containerManager.UtilizationInterval() <= 0 {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Claim GPU utilization is not enabled",
+			Code:    404,
+			Details: "set monitor.claim_gpu_interval_seconds to enable it",
+		})
+		return "", 0, false
+	}
+
+	claimID = c.Param("id")
+	if _, found := s.claimContainer(c, claimID); !found {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Claim not found",
+			Code:  404,
+		})
+		return "", 0, false
+	}
+
+	since, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid since parameter",
+			Code:  400,
+		})
+		return "", 0, false
+	}
+	return claimID, since, true
+}
+
+// getClaimGPUUtilization 返回claim最近的GPU利用率采样，支持 since（序号）参数
+func (s *Server) getClaimGPUUtilization(c *gin.Context) {
+	claimID, since, ok := s.claimUtilizationParams(c, c.DefaultQuery("since", "0"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, ClaimGPUUtilizationResponse{
+		ClaimID:         claimID,
+		IntervalSeconds: int(s.containerManager.UtilizationInterval() / time.Second),
+		Samples:         s.containerManager.GPUUtilization(claimID, since),
+	})
+}
+
+// streamClaimGPUUtilization 以 Server-Sent Events 推送claim的GPU利用率采样
+// 断线重连时从 Last-Event-ID 或 since 参数之后继续推送；claim的容器被删除后结束推送
+func (s *Server) streamClaimGPUUtilization(c *gin.Context) {
+	cursor := c.GetHeader("Last-Event-ID")
+	if cursor == "" {
+		cursor = c.DefaultQuery("since", "0")
+	}
+	claimID, since, ok := s.claimUtilizationParams(c, cursor)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(eventStreamPollInterval)
+	defer ticker.Stop()
+	lastWrite := time.Now()
+	for {
+		for _, sample := range s.containerManager.GPUUtilization(claimID, since) {
+			data, err := json.Marshal(sample)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: gpu_utilization\ndata: %s\n\n", sample.Seq, data); err != nil {
+				return
+			}
+			since = sample.Seq
+			lastWrite = time.Now()
+		}
+		if time.Since(lastWrite) >= eventStreamKeepalive {
+			if _, err := fmt.Fprint(c.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			lastWrite = time.Now()
+		}
+		c.Writer.Flush()
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+		}
+		if _, found := s.claimContainer(c, claimID); !found {
+			return
+		}
+	}
+}
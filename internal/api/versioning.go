@@ -0,0 +1,272 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/features"
+)
+
+// API版本
+const (
+	APIVersionV1 = "v1"
+	APIVersionV2 = "v2"
+)
+
+// defaultAPIVersion 请求未指定版本时使用的版本
+const defaultAPIVersion = APIVersionV1
+
+// apiVersionHeader 响应中实际使用的API版本
+const apiVersionHeader = "API-Version"
+
+// apiVersionKey 请求上下文中保存API版本的键
+const apiVersionKey = "api_version"
+
+// APIVersion 支持的API版本及其状态
+type APIVersion struct {
+	Version string `json:"version"`
+	// stable：稳定；preview：预览，可能变化
+	Status string `json:"status"`
+}
+
+// apiVersions 支持的API版本，按从旧到新排列
+var apiVersions = []APIVersion{
+	{Version: APIVersionV1, Status: "stable"},
+	{Version: APIVersionV2, Status: "preview"},
+}
+
+// Deprecation 已弃用的请求字段
+// 在DeprecatedIn及之后的版本中使用时响应带有弃用警告，在RemovedIn及之后的版本中被拒绝
+type Deprecation struct {
+	Field        string `json:"field"`
+	Replacement  string `json:"replacement,omitempty"`
+	DeprecatedIn string `json:"deprecated_in"`
+	RemovedIn    string `json:"removed_in,omitempty"`
+}
+
+// deprecations 已弃用的请求字段
+var deprecations = []Deprecation{
+	{Field: "gpu_count", Replacement: "gpus", DeprecatedIn: APIVersionV1, RemovedIn: APIVersionV2},
+}
+
+// VersionsResponse API版本与能力发现文档
+type VersionsResponse struct {
+	Default      string        `json:"default"`
+	Versions     []APIVersion  `json:"versions"`
+	Capabilities []string      `json:"capabilities"`
+	Deprecations []Deprecation `json:"deprecations"`
+}
+
+// acceptVersionPattern Accept头中的版本化媒体类型，如 application/vnd.utopia.v2+json
+var acceptVersionPattern = regexp.MustCompile(`application/vnd\.utopia\.(v[0-9]+)\+json`)
+
+// versionIndex 返回版本在apiVersions中的位置，不支持的版本返回-1
+func versionIndex(version string) int {
+	for i, v := range apiVersions {
+		if v.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// acceptedVersion 返回Accept头中请求的API版本，未指定时为空
+func acceptedVersion(c *gin.Context) string {
+	if match := acceptVersionPattern.FindStringSubmatch(c.GetHeader("Accept")); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// requestVersion 返回请求使用的API版本
+func requestVersion(c *gin.Context) string {
+	if version := c.GetString(apiVersionKey); version != "" {
+		return version
+	}
+	return defaultAPIVersion
+}
+
+// versionMiddleware 记录路径中的API版本，Accept头请求了其他版本时返回406
+func versionMiddleware(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if accepted := acceptedVersion(c); accepted != "" && accepted != version {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, ErrorResponse{
+				Error:   "API version mismatch",
+				Code:    406,
+				Details: fmt.Sprintf("path requests %s but Accept requests %s", version, accepted),
+			})
+			return
+		}
+		c.Set(apiVersionKey, version)
+		c.Header(apiVersionHeader, version)
+		c.Next()
+	}
+}
+
+// routeUnversioned 将未带版本的 /api/... 请求按Accept头协商的版本（默认v1）转发
+func (s *Server) routeUnversioned(c *gin.Context) {
+	rest, ok := strings.CutPrefix(c.Request.URL.Path, "/api/")
+	if !ok || versionIndex(strings.SplitN(rest, "/", 2)[0]) >= 0 {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Not found",
+			Code:  404,
+		})
+		return
+	}
+
+	version := acceptedVersion(c)
+	if version == "" {
+		version = defaultAPIVersion
+	}
+	if versionIndex(version) < 0 {
+		c.JSON(http.StatusNotAcceptable, ErrorResponse{
+			Error:   "Unsupported API version",
+			Code:    406,
+			Details: version,
+		})
+		return
+	}
+
+	// 转发后处理链已替换为目标路由的处理链，需中止以免外层继续执行
+	c.Request.URL.Path = "/api/" + version + "/" + rest
+	s.engine.HandleContext(c)
+	c.Abort()
+}
+
+// unversionedPath 去掉路径或路由模板中的 /api/v1 版本前缀
+func unversionedPath(path string) string {
+	if i := strings.Index(path, "/api/"); i >= 0 {
+		if j := strings.Index(path[i+len("/api/"):], "/"); j >= 0 {
+			return path[i+len("/api/")+j:]
+		}
+	}
+	return path
+}
+
+// getVersions 返回支持的API版本、节点启用的能力与弃用的字段
+func (s *Server) getVersions(c *gin.Context) {
+	c.JSON(http.StatusOK, VersionsResponse{
+		Default:      defaultAPIVersion,
+		Versions:     apiVersions,
+		Capabilities: s.capabilities(),
+		Deprecations: deprecations,
+	})
+}
+
+// capabilities 返回节点API启用的能力
+func (s *Server) capabilities() []string {
+	capabilities := []string{"containers.batch", "claims.hibernate", "claims.metadata", "events", "jobs", "topology", "tunnels", "gpus.processes", "idempotency", "openapi", "registry_auth", "capacity", "images.stats", "claims.connection", "admin.pprof"}
+	if s.gpuMaintenance != nil {
+		capabilities = append(capabilities, "gpu.reattach")
+		if s.gpuMaintenance.DriverUpgradeEnabled() {
+			capabilities = append(capabilities, "gpu.driver_upgrade")
+		}
+	}
+	if s.breakGlass != nil {
+		capabilities = append(capabilities, "admin.shell")
+	}
+	if s.commandVerifier != nil {
+		capabilities = append(capabilities, "command_signing")
+	}
+	if s.restart != nil {
+		capabilities = append(capabilities, "admin.restart")
+	}
+	if s.node != nil {
+		capabilities = append(capabilities, "admin.planned_downtime")
+	}
+	if s.selfTest != nil {
+		capabilities = append(capabilities, "admin.selftest")
+	}
+	if s.unixSocket != "" {
+		capabilities = append(capabilities, "unix_socket")
+	}
+	if s.features != nil {
+		capabilities = append(capabilities, "admin.feature_flags")
+	}
+	if s.quota != nil {
+		capabilities = append(capabilities, "tenants")
+	}
+	if s.featureEnabled(features.AsyncCreate) && s.jobs != nil {
+		capabilities = append(capabilities, "containers.async_create")
+	}
+	if s.containerManager != nil && s.containerManager.BuildPolicy().Enabled {
+		capabilities = append(capabilities, "images.build")
+	}
+	if s.containerManager != nil {
+		if status := s.containerManager.CoreDumpStatus(); status != nil && status.Collecting {
+			capabilities = append(capabilities, "claims.core_dumps")
+		}
+		if s.containerManager.UtilizationInterval() > 0 {
+			capabilities = append(capabilities, "claims.gpu_utilization")
+		}
+	}
+	if s.containerManager != nil && len(s.containerManager.ExternalVolumeTypes()) > 0 {
+		capabilities = append(capabilities, "containers.external_volumes")
+	}
+	if s.containerManager != nil && s.containerManager.TimeSlicingStatus() != nil {
+		capabilities = append(capabilities, "containers.gpu_fraction")
+	}
+	if s.featureEnabled(features.EventStream) && s.events != nil {
+		capabilities = append(capabilities, "events.stream")
+	}
+	return capabilities
+}
+
+// checkDeprecatedFields 检查请求使用的弃用字段
+// 字段在当前版本已移除时返回字段错误，仍可使用时在响应中添加 Deprecation 与 Warning 头
+func checkDeprecatedFields(c *gin.Context, prefix string, used []string) []container.FieldError {
+	current := versionIndex(requestVersion(c))
+	var fields []container.FieldError
+	for _, field := range used {
+		for _, d := range deprecations {
+			if d.Field != field || current < versionIndex(d.DeprecatedIn) {
+				continue
+			}
+			if d.RemovedIn != "" && current >= versionIndex(d.RemovedIn) {
+				fields = append(fields, container.FieldError{
+					Field:   prefix + field,
+					Message: fmt.Sprintf("was removed in %s, use %s instead", d.RemovedIn, d.Replacement),
+				})
+				continue
+			}
+			c.Header("Deprecation", "true")
+			c.Writer.Header().Add("Warning", fmt.Sprintf(`299 - "%s%s is deprecated, use %s instead"`, prefix, field, d.Replacement))
+		}
+	}
+	return fields
+}
+
+// negotiateCreateRequests 按请求的API版本处理创建请求中的弃用字段，并将 gpus 合并到 gpu_count
+// 返回false时已写出错误响应
+func (s *Server) negotiateCreateRequests(c *gin.Context, reqs []*container.CreateRequest, batch bool) bool {
+	var fields []container.FieldError
+	for i, req := range reqs {
+		prefix := ""
+		if batch {
+			prefix = fmt.Sprintf("containers[%d].", i)
+		}
+		if req.GPUCount != 0 {
+			fields = append(fields, checkDeprecatedFields(c, prefix, []string{"gpu_count"})...)
+		}
+		if req.GPUs != nil && req.GPUCount != 0 && *req.GPUs != req.GPUCount {
+			fields = append(fields, container.FieldError{
+				Field:   prefix + "gpus",
+				Message: fmt.Sprintf("conflicts with gpu_count %d, set only one of them", req.GPUCount),
+			})
+			continue
+		}
+		if req.GPUs != nil && *req.GPUs >= 0 && req.GPUCount == 0 {
+			req.GPUCount = *req.GPUs
+		}
+	}
+	if len(fields) > 0 {
+		s.respondInvalidRequest(c, &container.ValidationError{Fields: fields})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,214 @@
+package breakglass
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"utopia-node-agent/internal/signing"
+
+	"github.com/creack/pty"
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+// TokenPurpose 应急Shell令牌的用途标识
+const TokenPurpose = "break-glass-shell"
+
+// Config 应急Shell配置
+type Config struct {
+	Shell          string
+	AuditDir       string
+	MaxSessionTime time.Duration
+}
+
+// Manager 应急Shell会话管理器
+type Manager struct {
+	config Config
+	mu     sync.Mutex
+	active map[string]time.Time // 会话ID -> 开始时间
+}
+
+// controlMessage 客户端控制消息（文本帧）
+type controlMessage struct {
+	Type string `json:"type"`
+	Cols uint16 `json:"cols"`
+	Rows uint16 `json:"rows"`
+}
+
+// transcriptEntry 会话记录条目
+type transcriptEntry struct {
+	Time      string `json:"t"`
+	Direction string `json:"dir"` // in, out, event
+	Data      string `json:"data"`
+}
+
+// NewManager 创建新的应急Shell管理器
+func NewManager(config Config) (*Manager, error) {
+	if config.Shell == "" {
+		config.Shell = "/bin/bash"
+	}
+	if err := os.MkdirAll(config.AuditDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	return &Manager{
+		config: config,
+		active: make(map[string]time.Time),
+	}, nil
+}
+
+// ActiveSessions 返回当前活跃会话数量
+func (m *Manager) ActiveSessions() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.active)
+}
+
+// Serve 在WebSocket连接上运行一个主机Shell会话，并完整记录会话内容
+//
+// 二进制帧作为终端输入，文本帧为JSON控制消息（目前仅支持resize）。
+func (m *Manager) Serve(conn *websocket.Conn, claims *signing.Claims) error {
+	defer conn.Close()
+
+	transcript, err := m.openTranscript(claims)
+	if err != nil {
+		return err
+	}
+	defer transcript.close()
+
+	m.mu.Lock()
+	m.active[claims.ID] = time.Now()
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.active, claims.ID)
+		m.mu.Unlock()
+	}()
+
+	log.WithFields(log.Fields{
+		"session":  claims.ID,
+		"operator": claims.Subject,
+	}).Warn("Break-glass shell session opened")
+
+	cmd := exec.Command(m.config.Shell, "-l")
+	cmd.Env = append(os.Environ(), "UTOPIA_BREAK_GLASS_SESSION="+claims.ID)
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		transcript.write("event", fmt.Sprintf("failed to start shell: %v", err))
+		return fmt.Errorf("failed to start shell: %w", err)
+	}
+	defer ptmx.Close()
+
+	// 超过最长会话时间后强制结束
+	if m.config.MaxSessionTime > 0 {
+		timer := time.AfterFunc(m.config.MaxSessionTime, func() {
+			transcript.write("event", "session time limit exceeded")
+			cmd.Process.Kill()
+		})
+		defer timer.Stop()
+	}
+
+	// 终端输出 -> WebSocket
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		buf := make([]byte, 4096)
+		for {
+			n, err := ptmx.Read(buf)
+			if n > 0 {
+				transcript.write("out", string(buf[:n]))
+				if werr := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// WebSocket -> 终端输入
+	go func() {
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				cmd.Process.Kill()
+				return
+			}
+			switch msgType {
+			case websocket.BinaryMessage:
+				transcript.write("in", string(data))
+				if _, err := ptmx.Write(data); err != nil {
+					return
+				}
+			case websocket.TextMessage:
+				var msg controlMessage
+				if err := json.Unmarshal(data, &msg); err == nil && msg.Type == "resize" {
+					pty.Setsize(ptmx, &pty.Winsize{Cols: msg.Cols, Rows: msg.Rows})
+				}
+			}
+		}
+	}()
+
+	waitErr := cmd.Wait()
+	<-outputDone
+
+	transcript.write("event", fmt.Sprintf("shell exited: %v", waitErr))
+	conn.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, "session ended"))
+
+	log.WithFields(log.Fields{
+		"session":  claims.ID,
+		"operator": claims.Subject,
+	}).Warn("Break-glass shell session closed")
+
+	return nil
+}
+
+// transcript 会话记录文件
+type transcript struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// openTranscript 创建会话记录文件，首行记录令牌声明
+func (m *Manager) openTranscript(claims *signing.Claims) (*transcript, error) {
+	name := fmt.Sprintf("%s-%s.jsonl", time.Now().UTC().Format("20060102T150405Z"), filepath.Base(claims.ID))
+	file, err := os.OpenFile(filepath.Join(m.config.AuditDir, name), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcript: %w", err)
+	}
+
+	t := &transcript{file: file, enc: json.NewEncoder(file)}
+	header, _ := json.Marshal(claims)
+	t.write("event", "session opened: "+string(header))
+	return t, nil
+}
+
+// write 追加一条会话记录
+func (t *transcript) write(direction, data string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.enc.Encode(transcriptEntry{
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+		Direction: direction,
+		Data:      data,
+	}); err != nil {
+		log.Errorf("Failed to write break-glass transcript: %v", err)
+	}
+}
+
+// close 刷新并关闭记录文件
+func (t *transcript) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.file.Sync()
+	t.file.Close()
+}
@@ -0,0 +1,164 @@
+package claimdns
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// labelInvalidChars DNS标签中不允许的字符
+var labelInvalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Status 登记状态
+type Status struct {
+	Domain    string `json:"domain"`
+	HostsFile string `json:"hosts_file"`
+	// 当前登记的记录数
+	Records   int       `json:"records"`
+	LastSync  time.Time `json:"last_sync"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Registrar 将claim容器的地址写入节点本地解析器读取的hosts文件
+// dnsmasq 通过 addn-hosts 读取该文件，更新后向pidFile中的进程发送SIGHUP使其重新加载；
+// CoreDNS 的 hosts 插件会自动检测文件变化，无需设置pidFile
+type Registrar struct {
+	mu      sync.Mutex
+	domain  string
+	path    string
+	pidFile string
+	// 上次写入的文件内容，内容未变化时不重写文件
+	written  string
+	records  int
+	lastSync time.Time
+	lastErr  error
+}
+
+// NewRegistrar 创建登记器，domain为claim名称的后缀（如 claims.local）
+func NewRegistrar(domain, hostsFile, pidFile string) *Registrar {
+	return &Registrar{
+		domain:  strings.Trim(strings.ToLower(domain), "."),
+		path:    hostsFile,
+		pidFile: pidFile,
+	}
+}
+
+// Name 返回claim的DNS名称，如 claim-123.claims.local
+func (r *Registrar) Name(claimID string) string {
+	return label(claimID) + "." + r.domain
+}
+
+// SyncClaims 以完整的 claim ID -> 容器IP 集合替换已登记的记录
+func (r *Registrar) SyncClaims(addresses map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	claimIDs := make([]string, 0, len(addresses))
+	for claimID := range addresses {
+		claimIDs = append(claimIDs, claimID)
+	}
+	sort.Strings(claimIDs)
+
+	var b strings.Builder
+	b.WriteString("# Managed by utopia-node-agent, do not edit\n")
+	names := make(map[string]string, len(claimIDs))
+	var conflicts []string
+	for _, claimID := range claimIDs {
+		name := r.Name(claimID)
+		// 不同claim ID规范化后可能得到相同名称，只登记第一个
+		if other, exists := names[name]; exists {
+			conflicts = append(conflicts, fmt.Sprintf("claim %s maps to DNS name %s already used by claim %s", claimID, name, other))
+			continue
+		}
+		names[name] = claimID
+		fmt.Fprintf(&b, "%s %s\n", addresses[claimID], name)
+	}
+	content := b.String()
+
+	r.lastSync = time.Now()
+	// 内容未变化且上次已成功生效时无需重写；失败后每次同步都重试
+	if content == r.written && r.lastErr == nil {
+		return nil
+	}
+	for _, conflict := range conflicts {
+		fmt.Printf("Warning: %s, skipping\n", conflict)
+	}
+	if err := r.write(content); err != nil {
+		r.lastErr = err
+		return err
+	}
+	r.written = content
+	r.records = len(names)
+	r.lastErr = r.reload()
+	return r.lastErr
+}
+
+// Status 返回登记状态
+func (r *Registrar) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := Status{
+		Domain:    r.domain,
+		HostsFile: r.path,
+		Records:   r.records,
+		LastSync:  r.lastSync,
+	}
+	if r.lastErr != nil {
+		status.LastError = r.lastErr.Error()
+	}
+	return status
+}
+
+// write 原子地替换hosts文件
+func (r *Registrar) write(content string) error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create hosts file directory: %w", err)
+	}
+	tmpPath := r.path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write hosts file: %w", err)
+	}
+	if err := os.Rename(tmpPath, r.path); err != nil {
+		return fmt.Errorf("failed to replace hosts file: %w", err)
+	}
+	return nil
+}
+
+// reload 通知dnsmasq重新读取hosts文件
+func (r *Registrar) reload() error {
+	if r.pidFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(r.pidFile)
+	if err != nil {
+		return fmt.Errorf("failed to read resolver pid file: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return fmt.Errorf("invalid pid in %s", r.pidFile)
+	}
+	if err := syscall.Kill(pid, syscall.SIGHUP); err != nil {
+		return fmt.Errorf("failed to signal resolver (pid %d): %w", pid, err)
+	}
+	return nil
+}
+
+// label 将claim ID转换为合法的DNS标签
+func label(claimID string) string {
+	value := labelInvalidChars.ReplaceAllString(strings.ToLower(claimID), "-")
+	value = strings.Trim(value, "-")
+	if len(value) > 63 {
+		value = strings.TrimRight(value[:63], "-")
+	}
+	if value == "" {
+		value = "x"
+	}
+	return value
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hostnamePattern RFC 1123 主机名
+var hostnamePattern = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// NormalizeHost 去掉IPv6地址两侧的方括号，例如 "[::1]" -> "::1"
+func NormalizeHost(host string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(host), "["), "]")
+}
+
+// ValidateHost 验证主机是IPv4/IPv6地址或合法主机名
+func ValidateHost(host string) error {
+	host = NormalizeHost(host)
+	if host == "" {
+		return fmt.Errorf("host is empty")
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if len(host) > 253 || !hostnamePattern.MatchString(host) {
+		return fmt.Errorf("%q is neither an IP address nor a valid hostname", host)
+	}
+	return nil
+}
+
+// ValidateListenAddress 验证 host:port 形式的监听地址，IPv6需写作 [::]:9200
+// host 为空表示监听所有地址
+func ValidateListenAddress(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", address, err)
+	}
+	if host != "" && ValidateHost(host) != nil {
+		return fmt.Errorf("invalid listen host %q", host)
+	}
+	if _, err := ParsePort(port); err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", address, err)
+	}
+	return nil
+}
+
+// ParsePort 解析端口号
+func ParsePort(port string) (int, error) {
+	value, err := strconv.Atoi(port)
+	if err != nil || value <= 0 || value > 65535 {
+		return 0, fmt.Errorf("invalid port %q", port)
+	}
+	return value, nil
+}
+
+// LoopbackFor 返回可访问指定监听主机的本地地址：
+// 通配地址映射到对应协议族的回环地址，其他地址原样返回
+func LoopbackFor(host string) string {
+	host = NormalizeHost(host)
+	if host == "" {
+		return "127.0.0.1"
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsUnspecified() {
+		return host
+	}
+	if ip.To4() == nil {
+		return "::1"
+	}
+	return "127.0.0.1"
+}
@@ -2,16 +2,51 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
-	"gopkg.in/yaml.v3"
+	"utopia-node-agent/internal/hooks"
+	"utopia-node-agent/internal/security"
+)
+
+var (
+	// frpVersionPattern frp发布版本号
+	frpVersionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+$`)
+	// sha256Pattern 十六进制SHA-256
+	sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
 )
 
 // Config 节点代理配置
 type Config struct {
-	// 节点ID持久化路径
+	// 配置文件结构版本，低于当前版本的配置文件在加载时自动迁移
+	ConfigVersion int `yaml:"config_version"`
+
+	// 节点身份（节点ID与Ed25519密钥对）持久化路径
 	IdentityFilePath string `yaml:"identity_file_path"`
 
+	// 身份文件私钥的静态加密
+	Identity IdentityConfig `yaml:"identity"`
+
+	// 本地持久化数据目录
+	DataDir string `yaml:"data_dir"`
+
+	// 平台下发配置的覆盖文件路径，默认位于数据目录下
+	OverridesFilePath string `yaml:"overrides_file_path,omitempty"`
+
+	// 日志级别: debug, info, warn, error
+	LogLevel string `yaml:"log_level"`
+
+	// 重复日志的限流
+	LogSampling LogSamplingConfig `yaml:"log_sampling"`
+
+	// 环境变量、标签与元数据中敏感值的遮盖
+	Redaction RedactionConfig `yaml:"redaction"`
+
 	// 中央平台信息
 	CentralPlatform CentralPlatformConfig `yaml:"central_platform"`
 
@@ -20,85 +55,1621 @@ type Config struct {
 
 	// Agent自身API服务配置
 	AgentAPI AgentAPIConfig `yaml:"agent_api"`
+
+	// 容器相关配置
+	Container ContainerConfig `yaml:"container"`
+
+	// GPU忙碌判定配置
+	GPU GPUConfig `yaml:"gpu"`
+
+	// 运维应急Shell配置
+	BreakGlass BreakGlassConfig `yaml:"break_glass"`
+
+	// 破坏性操作的平台命令签名
+	CommandSigning CommandSigningConfig `yaml:"command_signing"`
+
+	// 后台监控任务配置
+	Monitor MonitorConfig `yaml:"monitor"`
+
+	// 功能开关
+	FeatureFlags map[string]bool `yaml:"feature_flags,omitempty"`
+
+	// 节点标签（如成本中心、机架、区域），添加到创建的每个容器并随心跳与计费记录上报
+	NodeLabels map[string]string `yaml:"node_labels,omitempty"`
+
+	// 链路追踪配置
+	Tracing TracingConfig `yaml:"tracing"`
+
+	// 镜像仓库配置
+	Registry RegistryConfig `yaml:"registry"`
+
+	// 用户Dockerfile镜像构建配置
+	ImageBuild ImageBuildConfig `yaml:"image_build"`
+
+	// 镜像懒加载拉取配置
+	LazyPull LazyPullConfig `yaml:"lazy_pull"`
+
+	// 远程电源管理配置
+	Power PowerConfig `yaml:"power"`
+
+	// 计费采集配置
+	Accounting AccountingConfig `yaml:"accounting"`
+
+	// 共享数据集缓存配置
+	DatasetCache DatasetCacheConfig `yaml:"dataset_cache"`
+
+	// 外部存储卷配置
+	ExternalVolumes ExternalVolumesConfig `yaml:"external_volumes"`
+
+	// 加密密钥下发配置
+	Secrets SecretsConfig `yaml:"secrets"`
+
+	// claim迁移配置
+	Migration MigrationConfig `yaml:"migration"`
+
+	// 租户容器强制访问控制配置
+	Security SecurityConfig `yaml:"security"`
+
+	// 容器入口点与命令的拒绝/允许规则
+	CommandPolicy CommandPolicyConfig `yaml:"command_policy"`
+
+	// NVIDIA MPS共享计算配置
+	MPS MPSConfig `yaml:"mps"`
+
+	// GPU时间片共享配置
+	TimeSlicing TimeSlicingConfig `yaml:"time_slicing"`
+
+	// CPU、内存与共享GPU显存的超分策略
+	Overcommit OvercommitConfig `yaml:"overcommit"`
+
+	// 为宿主机系统与Agent保留的资源
+	SystemReserved SystemReservedConfig `yaml:"system_reserved"`
+
+	// 容器生命周期钩子
+	Hooks []HookConfig `yaml:"hooks,omitempty"`
+
+	// 指标输出配置
+	Metrics MetricsConfig `yaml:"metrics"`
+
+	// 容器日志转发配置
+	LogShipping LogShippingConfig `yaml:"log_shipping"`
+
+	// 定时启动的claim配置
+	Schedule ScheduleConfig `yaml:"schedule"`
+
+	// claim之间的GPU清理校验配置
+	GPUCleanup GPUCleanupConfig `yaml:"gpu_cleanup"`
+
+	// 已预留GPU的争用检测配置
+	GPUContention GPUContentionConfig `yaml:"gpu_contention"`
+
+	// 宿主机GPU驱动升级编排配置
+	GPUDriverUpgrade GPUDriverUpgradeConfig `yaml:"gpu_driver_upgrade"`
+
+	// GPU温控策略配置
+	ThermalPolicy ThermalPolicyConfig `yaml:"thermal_policy"`
+
+	// claim容器在节点本地解析器中的DNS名称登记
+	ClaimDNS ClaimDNSConfig `yaml:"claim_dns"`
+
+	// claim空闲检测与自动停止配置
+	IdleShutdown IdleShutdownConfig `yaml:"idle_shutdown"`
+
+	// claim运行时长上限的执行配置
+	RuntimeLimit RuntimeLimitConfig `yaml:"runtime_limit"`
+
+	// Agent无中断重启配置
+	Handover HandoverConfig `yaml:"handover"`
+
+	// 节点自检配置
+	SelfTest SelfTestConfig `yaml:"self_test"`
+}
+
+// LogSamplingConfig 重复日志的限流配置：相同的消息在窗口内只输出前burst次，其余汇总为一行
+type LogSamplingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 统计重复消息的时间窗口（秒）
+	WindowSeconds int `yaml:"window_seconds"`
+	// 每个窗口内原样输出的次数
+	Burst int `yaml:"burst"`
+}
+
+// RedactionConfig 敏感值遮盖配置：名称匹配模式的环境变量、标签与元数据的值在API响应、事件与日志中显示为 [REDACTED]
+type RedactionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 不区分大小写的通配符模式，如 *TOKEN*
+	Patterns []string `yaml:"patterns"`
 }
 
 // CentralPlatformConfig 中央平台配置
 type CentralPlatformConfig struct {
 	APIURL         string `yaml:"api_url"`
 	BootstrapToken string `yaml:"bootstrap_token,omitempty"`
+	// 一次性引导令牌文件（如cloud-init写入），注册成功后删除；优先于bootstrap_token
+	BootstrapTokenFile string `yaml:"bootstrap_token_file,omitempty"`
+	// 从实例元数据服务获取引导令牌的地址及请求头，在令牌文件不存在时使用
+	BootstrapTokenURL     string            `yaml:"bootstrap_token_url,omitempty"`
+	BootstrapTokenHeaders map[string]string `yaml:"bootstrap_token_headers,omitempty"`
+	// 平台签名公钥（base64编码的Ed25519公钥），用于校验平台签发的令牌
+	SigningPublicKey string `yaml:"signing_public_key,omitempty"`
+	// 从平台拉取签名密钥包的间隔（秒），0表示只使用本地缓存与心跳推送的密钥包
+	SigningKeysRefreshSeconds int `yaml:"signing_keys_refresh_seconds"`
+	// 心跳上报间隔（秒）
+	HeartbeatIntervalSeconds int `yaml:"heartbeat_interval_seconds"`
+	// 首次注册时平台不可达（网络错误或5xx）时以独立模式启动本地子系统并在后台重试注册；关闭时注册失败即退出
+	StandaloneOnUnreachable bool `yaml:"standalone_on_unreachable"`
+	// 后台重试注册的最长间隔（秒），从5秒开始翻倍
+	RegistrationRetryMaxSeconds int `yaml:"registration_retry_max_seconds"`
 }
 
 // FRPConfig FRP配置
 type FRPConfig struct {
-	ServerAddr     string `yaml:"server_addr"`
-	ServerPort     int    `yaml:"server_port"`
-	Token          string `yaml:"token"`
+	ServerAddr string `yaml:"server_addr"`
+	ServerPort int    `yaml:"server_port"`
+	// 备用frps服务器，主服务器不可达时按顺序切换，并保持使用最近一次可用的服务器
+	FallbackServers []FRPServerConfig `yaml:"fallback_servers,omitempty"`
+	Token           string            `yaml:"token"`
+	// 令牌文件，设置时覆盖token，文件变化时自动重新加载
+	TokenFile      string `yaml:"token_file,omitempty"`
 	PortRangeStart int    `yaml:"port_range_start"`
+	// claim端口发布：从 claim_port_range_start 起每个节点按节点ID划分 claim_ports_per_node 个远端端口，0表示不启用
+	ClaimPortRangeStart int `yaml:"claim_port_range_start,omitempty"`
+	ClaimPortsPerNode   int `yaml:"claim_ports_per_node"`
+	// 发布端口访问地址使用的主机名，默认为 server_addr
+	PublicHost string `yaml:"public_host,omitempty"`
+	// 启动frpc后等待其登录frps的时间（秒）
+	StartTimeoutSeconds int `yaml:"start_timeout_seconds"`
+	// frpc管理接口在127.0.0.1上的端口，用于查询隧道状态；0表示不启用，隧道状态只从frpc日志判断
+	AdminPort int `yaml:"admin_port"`
+	// PATH中没有frpc时自动下载固定版本
+	Download FRPCDownloadConfig `yaml:"frpc_download"`
+}
+
+// FRPCDownloadConfig frpc自动下载配置
+type FRPCDownloadConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 固定的frp版本
+	Version string `yaml:"version"`
+	// 发布文件镜像，从 <mirror_url>/v<version>/frp_<version>_linux_<arch>.tar.gz 下载
+	MirrorURL string `yaml:"mirror_url"`
+	// 安装目录
+	InstallDir string `yaml:"install_dir"`
+	// 各架构（amd64、arm64等）压缩包的SHA-256；未配置当前架构时使用Agent内置的默认版本校验和
+	SHA256 map[string]string `yaml:"sha256,omitempty"`
+}
+
+// FRPServerConfig 备用frps服务器
+type FRPServerConfig struct {
+	Addr string `yaml:"addr"`
+	// 为0时使用 server_port
+	Port int `yaml:"port,omitempty"`
 }
 
 // AgentAPIConfig Agent API配置
 type AgentAPIConfig struct {
+	// 监听地址，IPv6写作 "[::]:9200"
 	ListenAddress string `yaml:"listen_address"`
-	AuthToken     string `yaml:"auth_token"`
+	// 额外监听地址，用于在 bindv6only 主机上同时监听 IPv4 与 IPv6
+	AdditionalListenAddresses []string `yaml:"additional_listen_addresses,omitempty"`
+	AuthToken                 string   `yaml:"auth_token"`
+	// 认证令牌文件，设置时覆盖auth_token，文件变化时自动重新加载
+	AuthTokenFile string `yaml:"auth_token_file,omitempty"`
+	// 幂等键保留时间（秒），0表示不支持 Idempotency-Key
+	IdempotencyTTLSeconds int `yaml:"idempotency_ttl_seconds"`
+	// 本地Unix套接字路径，为空时不启用；root与允许的uid/gid的进程按对端凭据免令牌访问
+	UnixSocket            string `yaml:"unix_socket,omitempty"`
+	UnixSocketAllowedUIDs []int  `yaml:"unix_socket_allowed_uids,omitempty"`
+	UnixSocketAllowedGIDs []int  `yaml:"unix_socket_allowed_gids,omitempty"`
+	// 子租户令牌，只能管理自己创建的容器并受配额限制
+	Tenants []TenantConfig `yaml:"tenants,omitempty"`
+	// 管理端点（退役、电源、GPU恢复、后台任务、功能开关）的独立监听地址，默认仅本机可访问；
+	// 为空时管理端点仍由主API提供
+	AdminListenAddress string `yaml:"admin_listen_address"`
+	// 管理端点的认证令牌，或从文件读取（启动时读取）；均未设置时使用数据目录下自动生成的 admin_token
+	AdminAuthToken     string `yaml:"admin_auth_token,omitempty"`
+	AdminAuthTokenFile string `yaml:"admin_auth_token_file,omitempty"`
+}
+
+// TenantConfig 子租户令牌与配额，配额为0表示不限制
+type TenantConfig struct {
+	Name string `yaml:"name"`
+	// 令牌，或从token_file读取（启动时读取）
+	Token     string `yaml:"token,omitempty"`
+	TokenFile string `yaml:"token_file,omitempty"`
+	// 同时存在的容器数、占用的GPU数与可写层大小总和（GB）上限
+	MaxContainers int `yaml:"max_containers,omitempty"`
+	MaxGPUs       int `yaml:"max_gpus,omitempty"`
+	MaxDiskGB     int `yaml:"max_disk_gb,omitempty"`
+	// 每分钟最多创建的容器数
+	MaxCreatesPerMinute int `yaml:"max_creates_per_minute,omitempty"`
+}
+
+// ContainerConfig 容器配置
+type ContainerConfig struct {
+	// 容器可写层默认大小限制（GB），0表示不限制
+	DefaultStorageSizeGB int `yaml:"default_storage_size_gb"`
+	// 崩溃循环判定：crash_loop_window_minutes 内重启超过 crash_loop_max_restarts 次
+	CrashLoopMaxRestarts   int `yaml:"crash_loop_max_restarts"`
+	CrashLoopWindowMinutes int `yaml:"crash_loop_window_minutes"`
+	// 默认DNS服务器、搜索域与额外hosts条目（"host:ip"），请求可覆盖
+	DNSServers []string `yaml:"dns_servers,omitempty"`
+	DNSSearch  []string `yaml:"dns_search,omitempty"`
+	ExtraHosts []string `yaml:"extra_hosts,omitempty"`
+	// 容器主机名模式，支持 {{claim_id}} 与 {{node_id}}，为空时使用docker默认主机名
+	HostnamePattern string `yaml:"hostname_pattern"`
+	// 刷新容器列表时的最大并发查询数
+	RefreshConcurrency int `yaml:"refresh_concurrency"`
+	// 创建容器（含镜像拉取与启动）的时限（秒），超时后清理未完成的容器，0表示不限制
+	CreateTimeoutSeconds int `yaml:"create_timeout_seconds"`
+	// 允许通过创建请求挂载到容器的主机目录，为空时只允许命名卷
+	AllowedVolumeRoots []string `yaml:"allowed_volume_roots"`
+	// claim休眠时默认是否保留GPU，请求可覆盖
+	HibernateKeepGPUs bool `yaml:"hibernate_keep_gpus"`
+	// 容器默认ulimit（nofile、nproc、core、memlock、stack），未设置的使用docker守护进程的默认值，请求可覆盖
+	Ulimits map[string]UlimitConfig `yaml:"ulimits,omitempty"`
+	// 容器对工作区设备的默认读写限速，请求可覆盖
+	IOLimits IOLimitConfig `yaml:"io_limits"`
+	// 收集容器进程的核心转储
+	CoreDumps CoreDumpConfig `yaml:"core_dumps"`
+	// 主机端口划分，容器端口分配与FRP GPU隧道共用
+	PortPlan PortPlanConfig `yaml:"port_plan"`
+}
+
+// PortPlanConfig 主机端口划分配置
+type PortPlanConfig struct {
+	// GPU i 的端口段从 gpu_base + i*gpu_stride 起，共 gpu_stride 个端口，前两个为 web 与 ssh 端口
+	GPUBase   int `yaml:"gpu_base"`
+	GPUStride int `yaml:"gpu_stride"`
+	// 为未指定主机端口的映射分配端口的动态端口段，dynamic_start 为0表示不启用
+	DynamicStart int `yaml:"dynamic_start"`
+	DynamicEnd   int `yaml:"dynamic_end"`
+}
+
+// UlimitConfig 软硬限制，-1表示不限制
+type UlimitConfig struct {
+	Soft int64 `yaml:"soft"`
+	Hard int64 `yaml:"hard"`
+}
+
+// IOLimitConfig 磁盘IO限速配置，0表示不限制
+type IOLimitConfig struct {
+	// 被限速的工作区设备（如 /dev/nvme1n1），为空时使用Docker数据目录所在的块设备，修改后需重启Agent
+	Device string `yaml:"device,omitempty"`
+	// 每秒读写字节数
+	ReadBPS  int64 `yaml:"read_bps"`
+	WriteBPS int64 `yaml:"write_bps"`
+	// 每秒读写次数
+	ReadIOPS  int64 `yaml:"read_iops"`
+	WriteIOPS int64 `yaml:"write_iops"`
+}
+
+// CoreDumpConfig 核心转储收集配置
+type CoreDumpConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 启动时写入 /proc/sys/kernel/core_pattern 的模式，为空时沿用主机设置
+	// 绝对路径（如 /workspace/core-dumps/core.%e.%p.%t）时Agent把按claim隔离的目录挂载到容器内该路径所在目录
+	CorePattern string `yaml:"core_pattern,omitempty"`
+	// 按容器存放转储的目录，默认 data_dir/core-dumps
+	Dir string `yaml:"dir,omitempty"`
+	// 单个转储的大小上限（MB），作为容器的 core ulimit
+	MaxSizeMB int `yaml:"max_size_mb"`
+	// 每个claim保留的转储总量上限（MB），超出时删除最旧的转储，0表示不限制
+	MaxTotalMB int `yaml:"max_total_mb"`
+}
+
+// GPUConfig GPU忙碌判定配置
+type GPUConfig struct {
+	// 显存使用率或GPU利用率超过阈值（百分比）视为有负载
+	BusyMemoryPercent      float64 `yaml:"busy_memory_percent"`
+	BusyUtilizationPercent float64 `yaml:"busy_utilization_percent"`
+	// 负载状态需持续的时间（秒）才切换忙碌判定
+	BusyWindowSeconds int `yaml:"busy_window_seconds"`
+}
+
+// BreakGlassConfig 运维应急Shell配置
+type BreakGlassConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	Shell              string `yaml:"shell"`
+	AuditDir           string `yaml:"audit_dir"`
+	MaxSessionMinutes  int    `yaml:"max_session_minutes"`
+	MaxTokenTTLSeconds int    `yaml:"max_token_ttl_seconds"`
+}
+
+// CommandSigningConfig 破坏性操作的平台命令签名配置
+// 启用后经主API调用的删除容器、退役节点、电源操作与GPU重置须携带平台签发的一次性令牌
+type CommandSigningConfig struct {
+	Enabled            bool `yaml:"enabled"`
+	MaxTokenTTLSeconds int  `yaml:"max_token_ttl_seconds"`
+}
+
+// MonitorConfig 后台监控任务间隔配置（秒）
+type MonitorConfig struct {
+	GPUIntervalSeconds       int `yaml:"gpu_interval_seconds"`
+	ContainerIntervalSeconds int `yaml:"container_interval_seconds"`
+	FRPIntervalSeconds       int `yaml:"frp_interval_seconds"`
+	// 主机名与IP地址变化检测间隔
+	AddressIntervalSeconds int `yaml:"address_interval_seconds"`
+	// 查询公网IP的地址（返回纯文本IP），为空时不检测公网IP
+	PublicIPURL string `yaml:"public_ip_url,omitempty"`
+	// 按节点负载与异常自动调整GPU与系统指标的采样间隔
+	Adaptive AdaptiveMonitorConfig `yaml:"adaptive"`
+	// 在系统指标中附带磁盘IO、网卡错误、大页内存与PSI
+	HostMetrics HostMetricsConfig `yaml:"host_metrics"`
+	// 按进程统计各claim GPU利用率的间隔，0表示不统计
+	ClaimGPUIntervalSeconds int `yaml:"claim_gpu_interval_seconds"`
+}
+
+// HostMetricsConfig 主机指标采集配置，GPU任务常因IO或内存压力失败，这些指标用于定位原因
+type HostMetricsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 采集的块设备与网卡名称（支持通配符如 nvme*），为空时采集全部物理块设备与除lo、veth外的网卡
+	Devices    []string `yaml:"devices,omitempty"`
+	Interfaces []string `yaml:"interfaces,omitempty"`
+}
+
+// AdaptiveMonitorConfig 自适应采样频率：节点高负载或GPU温度压力下逐步降低GPU与系统指标的采样频率，
+// 检测到异常时提高到上限频率，调整后的间隔限制在上下限之间
+type AdaptiveMonitorConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 调整后采样间隔的下限与上限（秒）
+	MinIntervalSeconds int `yaml:"min_interval_seconds"`
+	MaxIntervalSeconds int `yaml:"max_interval_seconds"`
+	// 每个CPU的1分钟平均负载（百分比）达到该值视为高负载
+	LoadPercent float64 `yaml:"load_percent"`
+	// 全部GPU的平均利用率（百分比）达到该值视为高负载
+	GPUUtilizationPercent float64 `yaml:"gpu_utilization_percent"`
+	// 任一GPU温度达到该值（摄氏度）视为温度压力
+	TemperatureC int `yaml:"temperature_c"`
+	// 两次采样之间GPU温度上升达到该值（摄氏度）视为异常
+	TemperatureJumpC int `yaml:"temperature_jump_c"`
+	// 检测到异常后保持最高采样频率的时间（秒）
+	BoostSeconds int `yaml:"boost_seconds"`
+}
+
+// TracingConfig OpenTelemetry链路追踪配置
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// OTLP/HTTP 收集器地址（host:port）
+	OTLPEndpoint string  `yaml:"otlp_endpoint"`
+	Insecure     bool    `yaml:"insecure"`
+	SampleRatio  float64 `yaml:"sample_ratio"`
+}
+
+// RegistryConfig 镜像仓库配置（推送容器快照，并用于拉取该仓库的私有镜像）
+type RegistryConfig struct {
+	Server   string `yaml:"server"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// 密码文件，设置时覆盖password（启动时读取），只允许属主访问
+	PasswordFile     string `yaml:"password_file,omitempty"`
+	RepositoryPrefix string `yaml:"repository_prefix"`
+	// 拉取私有镜像时使用的其他仓库凭据，按镜像所在仓库选择
+	Credentials []RegistryCredential `yaml:"credentials,omitempty"`
+}
+
+// RegistryCredential 节点级镜像仓库凭据
+type RegistryCredential struct {
+	// 仓库地址，如 registry.example.com:5000，Docker Hub 写作 docker.io
+	Server       string `yaml:"server"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+}
+
+// ImageBuildConfig 用户Dockerfile镜像构建配置
+// 构建在带资源限制的buildkit构建器（docker-container驱动）中执行，结果只加载到本地
+type ImageBuildConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 构建器的CPU核数与内存（MB）上限，0表示不限制
+	CPUs     float64 `yaml:"cpus"`
+	MemoryMB int     `yaml:"memory_mb"`
+	// 单次构建的时限（秒）
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+	// 上传的构建上下文解压后的大小上限（MB）
+	MaxContextMB int `yaml:"max_context_mb"`
+	// 允许的git仓库主机，为空时允许任意https地址
+	AllowedGitHosts []string `yaml:"allowed_git_hosts,omitempty"`
+}
+
+// LazyPullConfig 镜像懒加载拉取配置，需要docker使用containerd镜像存储并以stargz或nydus快照器为存储驱动
+// Agent只写入containerd代理插件配置并检测是否生效，不重启containerd或docker
+type LazyPullConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// stargz 或 nydus
+	Snapshotter string `yaml:"snapshotter"`
+	// 快照器gRPC套接字，为空时使用该快照器的默认路径
+	Address string `yaml:"address,omitempty"`
+	// 写入containerd代理插件配置的目录（需在containerd配置的imports中包含），为空时不写入
+	ContainerdConfigDir string `yaml:"containerd_config_dir,omitempty"`
+}
+
+// PowerConfig 远程电源管理配置，命令为空表示不支持该操作
+type PowerConfig struct {
+	Enabled         bool     `yaml:"enabled"`
+	RebootCommand   []string `yaml:"reboot_command"`
+	ShutdownCommand []string `yaml:"shutdown_command"`
+	SuspendCommand  []string `yaml:"suspend_command"`
+	// 延迟执行的最长时间（秒）
+	MaxDelaySeconds int `yaml:"max_delay_seconds"`
+}
+
+// AccountingConfig 计费采集配置
+type AccountingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 采样间隔（秒）
+	SampleIntervalSeconds int `yaml:"sample_interval_seconds"`
+	// 结算与上传间隔（秒）
+	ReportIntervalSeconds int `yaml:"report_interval_seconds"`
+}
+
+// DatasetCacheConfig 共享数据集缓存配置
+type DatasetCacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 缓存目录，默认位于数据目录下
+	Dir string `yaml:"dir,omitempty"`
+	// 磁盘预算（GB），超出时按最近使用时间淘汰，0表示不限制
+	BudgetGB int `yaml:"budget_gb"`
+	// 允许的rsync://数据源主机，为空时不限制
+	RsyncHosts []string `yaml:"rsync_hosts,omitempty"`
+}
+
+// ExternalVolumesConfig 外部存储卷配置：Agent在主机上挂载claim请求的NFS导出、S3存储桶或SMB共享，再绑定挂载到容器
+type ExternalVolumesConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 主机上的挂载点目录，默认位于数据目录下
+	Dir string `yaml:"dir,omitempty"`
+	// 允许的存储类型：nfs、s3、smb
+	Types []string `yaml:"types"`
+	// S3挂载工具：s3fs 或 rclone
+	S3Driver string `yaml:"s3_driver"`
+	// 单个卷的挂载时限（秒）
+	MountTimeoutSeconds int `yaml:"mount_timeout_seconds"`
+}
+
+// SecretsConfig 加密密钥下发配置
+type SecretsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 节点加密私钥文件，默认位于数据目录下
+	KeyFile string `yaml:"key_file,omitempty"`
+	// 解密后的文件密钥存放目录，必须位于tmpfs
+	RuntimeDir string `yaml:"runtime_dir"`
+}
+
+// MigrationConfig claim迁移导出/导入配置
+type MigrationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 传输数据的暂存目录，默认位于数据目录下，需能容纳最大的镜像与卷归档
+	SpoolDir string `yaml:"spool_dir,omitempty"`
+}
+
+// SecurityConfig 租户容器AppArmor/SELinux配置
+type SecurityConfig struct {
+	// 加载并默认应用加固配置
+	Enabled bool `yaml:"enabled"`
+	// 无法强制时拒绝启动
+	Required bool `yaml:"required"`
+	// AppArmor配置文件写入目录，默认位于数据目录下
+	ProfileDir string `yaml:"profile_dir,omitempty"`
+	// SELinux主机上容器进程的类型，为空时使用docker默认的 container_t
+	SELinuxType string `yaml:"selinux_type,omitempty"`
+	// 允许创建请求放宽的限制：unconfined、seccomp-unconfined
+	AllowedRelaxations []string `yaml:"allowed_relaxations,omitempty"`
+}
+
+// IdentityConfig 身份文件私钥的加密配置
+type IdentityConfig struct {
+	// 以AES-256-GCM加密身份文件中的私钥
+	EncryptPrivateKey bool `yaml:"encrypt_private_key"`
+	// 加密密钥文件（取内容的SHA-256作为密钥），为空时由机器ID派生，复制到其他机器的身份文件无法使用
+	KeyFile string `yaml:"key_file,omitempty"`
+}
+
+// CommandPolicyConfig 容器命令策略，用正则表达式匹配镜像入口点与命令组成的命令行
+type CommandPolicyConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 匹配任一模式的命令被拒绝（如挖矿程序、fork炸弹）
+	DenyPatterns []string `yaml:"deny_patterns"`
+	// 不为空时只允许匹配其中之一的命令，用于锁定的站点
+	AllowPatterns []string `yaml:"allow_patterns,omitempty"`
+}
+
+// MPSConfig NVIDIA MPS共享计算配置
+type MPSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 各GPU守护进程的管道与日志目录
+	Dir string `yaml:"dir"`
+	// 每个GPU上共享计算容器的上限，0表示不限制
+	MaxClientsPerGPU int `yaml:"max_clients_per_gpu"`
+}
+
+// TimeSlicingConfig GPU时间片共享配置
+// 多个容器以时间片轮转共享同一块GPU，不隔离显存与算力，用于推理等低负载的份额GPU
+type TimeSlicingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 每块GPU划分的份数，最小份额为 1/replicas_per_gpu
+	ReplicasPerGPU int `yaml:"replicas_per_gpu"`
+	// 最多用于时间片共享的GPU数，0表示不限制
+	MaxGPUs int `yaml:"max_gpus"`
+}
+
+// OvercommitConfig 创建容器时的资源准入策略
+// 所有受管容器承诺的资源之和不得超过物理容量乘以超分比例，比例为0表示不限制
+type OvercommitConfig struct {
+	CPURatio    float64 `yaml:"cpu_ratio"`
+	MemoryRatio float64 `yaml:"memory_ratio"`
+	// 共享计算容器在单个GPU上承诺的显存之和相对于该GPU显存总量的比例
+	GPUMemoryRatio float64 `yaml:"gpu_memory_ratio"`
+	// 请求未指定时计入承诺量的CPU核数、内存（MB）与共享计算显存（MB），不作为容器的资源限制
+	DefaultCPUs        float64 `yaml:"default_cpus"`
+	DefaultMemoryMB    int     `yaml:"default_memory_mb"`
+	DefaultGPUMemoryMB int     `yaml:"default_gpu_memory_mb"`
+}
+
+// SystemReservedConfig 为宿主机系统进程（dockerd、frpc等）与Agent自身保留的资源
+// 准入时从可调度容量中扣除，并随心跳上报平台
+type SystemReservedConfig struct {
+	CPUs     float64 `yaml:"cpus"`
+	MemoryMB int     `yaml:"memory_mb"`
+	// 创建容器后Docker数据目录至少保留的空闲空间（GB）
+	DiskGB int `yaml:"disk_gb"`
+}
+
+// ScheduleConfig 定时启动claim的配置
+type ScheduleConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 最多可提前多少天预约
+	MaxAdvanceDays int `yaml:"max_advance_days"`
+	// 预拉取镜像时要求Docker数据目录至少保留的空闲空间（GB）
+	MinFreeDiskGB int `yaml:"min_free_disk_gb"`
+	// 在启动时间前多少秒开始为其预留GPU，不再分配给立即创建的容器
+	ReserveLeadSeconds int `yaml:"reserve_lead_seconds"`
+}
+
+// GPUCleanupConfig claim之间的GPU清理校验配置
+// 容器删除后其GPU先标记为待清理，显存与计算进程确认释放后才重新分配
+type GPUCleanupConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 显存占用不超过该值（MB）且没有计算进程时视为已清理
+	MaxMemoryMB int `yaml:"max_memory_mb"`
+	// 等待显存自然释放的时间（秒），超时后执行补救
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+	// 补救时结束GPU上残留的计算进程
+	KillProcesses bool `yaml:"kill_processes"`
+	// 仍未清理且GPU上没有进程时执行 nvidia-smi --gpu-reset
+	ResetGPU bool `yaml:"reset_gpu"`
+}
+
+// GPUContentionConfig 已预留GPU的争用检测配置
+// 定期检查分配给claim的GPU是否被非受管容器、宿主机进程或其他claim使用
+type GPUContentionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 检查间隔（秒）
+	IntervalSeconds int `yaml:"interval_seconds"`
+	// 发现非受管容器时的处理：report（仅上报）或 stop（停止该容器）；宿主机进程与其他claim只上报
+	Action string `yaml:"action"`
+	// 不视为占用者的非受管容器名称（path.Match 模式），如使用全部GPU的监控容器
+	IgnoreContainers []string `yaml:"ignore_containers,omitempty"`
+}
+
+// GPUDriverUpgradeConfig 宿主机GPU驱动升级编排配置
+// 升级任务排空GPU容器并关闭NVML后执行命令，等待NVML恢复并校验设备后重启容器
+type GPUDriverUpgradeConfig struct {
+	// 卸载并重新加载驱动或升级驱动包的命令，为空时不启用升级端点
+	// 目标版本通过 UTOPIA_DRIVER_VERSION 环境变量传入
+	Command []string `yaml:"command,omitempty"`
+	// 命令执行时限（秒）
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+}
+
+// ThermalPolicyConfig GPU温控策略配置
+// 温度持续超过上限时发布事件并按配置降低功率上限或提高风扇转速，持续低于恢复温度后还原
+type ThermalPolicyConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 检查间隔（秒）
+	IntervalSeconds int `yaml:"interval_seconds"`
+	// 温度上限与恢复温度（摄氏度），两者之间的差值避免状态来回切换
+	MaxTemperatureC    int `yaml:"max_temperature_c"`
+	ResumeTemperatureC int `yaml:"resume_temperature_c"`
+	// 温度需持续超过上限或低于恢复温度多少秒才切换状态
+	SustainSeconds int `yaml:"sustain_seconds"`
+	// 超温时将功率上限降至默认值的百分比，0表示不调整
+	PowerLimitPercent int `yaml:"power_limit_percent"`
+	// 超温时将风扇固定在该转速（百分比），0表示保持自动控制
+	FanSpeedPercent int `yaml:"fan_speed_percent"`
+}
+
+// IdleShutdownConfig claim空闲检测配置
+// GPU利用率、暴露端口上的入站连接（如通过隧道的SSH会话）与入站流量（如对web端口的HTTP请求）均低于阈值时视为无活动
+type IdleShutdownConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 检查间隔（秒）
+	IntervalSeconds int `yaml:"interval_seconds"`
+	// 持续无活动多少分钟视为空闲并发出警告
+	IdleMinutes int `yaml:"idle_minutes"`
+	// 空闲时的处理：report（仅警告）或 stop（警告后再经过 stop_grace_minutes 分钟停止容器）
+	Action           string `yaml:"action"`
+	StopGraceMinutes int    `yaml:"stop_grace_minutes"`
+	// 活动阈值：GPU利用率（百分比）与每分钟入站流量（KB）
+	GPUUtilizationPercent float64 `yaml:"gpu_utilization_percent"`
+	NetworkRxKBPerMinute  int     `yaml:"network_rx_kb_per_minute"`
+}
+
+// RuntimeLimitConfig claim运行时长上限（创建请求的 max_runtime_seconds）的执行配置
+type RuntimeLimitConfig struct {
+	// 检查间隔（秒）
+	IntervalSeconds int `yaml:"interval_seconds"`
+	// 到期前多少分钟发布警告事件
+	WarningMinutes []int `yaml:"warning_minutes"`
+	// 到期时停止容器的宽限时间（秒）：先发送SIGTERM，超时后SIGKILL，随后删除容器
+	GracePeriodSeconds int `yaml:"grace_period_seconds"`
+}
+
+// HandoverConfig Agent无中断重启（SIGUSR2或管理端点触发）配置
+// 启用后frpc不随Agent进程退出，由新进程接管
+type HandoverConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 等待新进程就绪的时间（秒），超时后结束新进程，当前进程继续运行
+	ReadyTimeoutSeconds int `yaml:"ready_timeout_seconds"`
+}
+
+// SelfTestConfig 节点自检（POST /admin/selftest）配置：在空闲GPU上运行测试容器，检查GPU可见性与隧道后删除
+type SelfTestConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 测试容器镜像，需包含 nvidia-smi 与 sleep
+	Image string `yaml:"image"`
+	// 整个自检（含拉取镜像）的时限（秒）
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+}
+
+// 空闲claim的处理方式
+const (
+	IdleActionReport = "report"
+	IdleActionStop   = "stop"
+)
+
+// ClaimDNSConfig claim容器DNS名称登记配置
+// 将运行中的claim容器登记为 <claim_id>.<domain>，写入节点本地dnsmasq或CoreDNS读取的hosts文件
+type ClaimDNSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 名称后缀
+	Domain string `yaml:"domain"`
+	// 解析器读取的hosts文件（dnsmasq 的 addn-hosts 或 CoreDNS 的 hosts 插件），默认位于数据目录下
+	HostsFile string `yaml:"hosts_file,omitempty"`
+	// dnsmasq 的pid文件，更新hosts文件后发送SIGHUP；CoreDNS 自动重新加载，无需设置
+	ReloadPIDFile string `yaml:"reload_pid_file,omitempty"`
+	// 作为DNS服务器提供给容器的解析器地址（如docker0网桥地址），为空时不修改容器的DNS设置
+	ResolverAddress string `yaml:"resolver_address,omitempty"`
+}
+
+// 争用处理方式
+const (
+	ContentionActionReport = "report"
+	ContentionActionStop   = "stop"
+)
+
+// MetricsConfig 指标输出配置
+type MetricsConfig struct {
+	// 采集并推送到各输出的间隔（秒）
+	IntervalSeconds int `yaml:"interval_seconds"`
+	// 每次写出的最大采样数与每个输出的最大缓存采样数
+	BatchSize int `yaml:"batch_size"`
+	QueueSize int `yaml:"queue_size"`
+	// 是否在心跳中上报系统指标与GPU历史负载，关闭后只通过输出推送
+	Platform bool `yaml:"platform"`
+	// 指标输出
+	Sinks []MetricsSinkConfig `yaml:"sinks,omitempty"`
+}
+
+// MetricsSinkConfig 指标输出配置
+type MetricsSinkConfig struct {
+	// 输出类型：influxdb、otlp、statsd
+	Type string `yaml:"type"`
+	// 名称，用于日志与状态查询，默认为类型
+	Name string `yaml:"name,omitempty"`
+	// influxdb：服务地址、组织、存储桶与令牌
+	URL    string `yaml:"url,omitempty"`
+	Org    string `yaml:"org,omitempty"`
+	Bucket string `yaml:"bucket,omitempty"`
+	Token  string `yaml:"token,omitempty"`
+	// otlp：收集器地址（OTLP/HTTP）与附加请求头
+	Endpoint string            `yaml:"endpoint,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	// statsd：UDP地址与指标名前缀
+	Address string `yaml:"address,omitempty"`
+	Prefix  string `yaml:"prefix,omitempty"`
+}
+
+// Validate 验证指标输出配置
+func (s *MetricsSinkConfig) Validate() error {
+	switch s.Type {
+	case "influxdb":
+		if s.URL == "" || s.Bucket == "" {
+			return fmt.Errorf("url and bucket are required for influxdb")
+		}
+	case "otlp":
+		if s.Endpoint == "" {
+			return fmt.Errorf("endpoint is required for otlp")
+		}
+	case "statsd":
+		if s.Address == "" {
+			return fmt.Errorf("address is required for statsd")
+		}
+	default:
+		return fmt.Errorf("type must be one of influxdb, otlp, statsd")
+	}
+	return nil
+}
+
+// LogShippingConfig 容器stdout/stderr日志转发配置
+type LogShippingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// 单行最大字节数，超出部分截断
+	MaxLineBytes int `yaml:"max_line_bytes"`
+	// 每个容器每秒最多转发的行数，超出的行丢弃，0表示不限制
+	RateLimitLinesPerSecond int `yaml:"rate_limit_lines_per_second"`
+	// 每次写出的最大行数与每个输出的最大缓存行数
+	BatchSize int `yaml:"batch_size"`
+	QueueSize int `yaml:"queue_size"`
+	// 定期写出的间隔（秒）
+	FlushIntervalSeconds int `yaml:"flush_interval_seconds"`
+	// 检查新启动容器的间隔（秒）
+	SyncIntervalSeconds int `yaml:"sync_interval_seconds"`
+	// 是否转发到平台
+	Platform bool `yaml:"platform"`
+	// 其他日志输出
+	Sinks []LogSinkConfig `yaml:"sinks,omitempty"`
+}
+
+// LogSinkConfig 日志输出配置
+type LogSinkConfig struct {
+	// 输出类型：loki、cloudwatch
+	Type string `yaml:"type"`
+	// 名称，用于日志与状态查询，默认为类型
+	Name string `yaml:"name,omitempty"`
+	// loki：服务地址、租户ID（X-Scope-OrgID）与附加请求头
+	URL      string            `yaml:"url,omitempty"`
+	TenantID string            `yaml:"tenant_id,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	// cloudwatch：区域、日志组与可选的服务地址；未设置访问密钥时读取 AWS_* 环境变量
+	Region          string `yaml:"region,omitempty"`
+	LogGroup        string `yaml:"log_group,omitempty"`
+	Endpoint        string `yaml:"endpoint,omitempty"`
+	AccessKeyID     string `yaml:"access_key_id,omitempty"`
+	SecretAccessKey string `yaml:"secret_access_key,omitempty"`
+}
+
+// Validate 验证日志输出配置
+func (s *LogSinkConfig) Validate() error {
+	switch s.Type {
+	case "loki":
+		if s.URL == "" {
+			return fmt.Errorf("url is required for loki")
+		}
+	case "cloudwatch":
+		if s.Region == "" || s.LogGroup == "" {
+			return fmt.Errorf("region and log_group are required for cloudwatch")
+		}
+	default:
+		return fmt.Errorf("type must be one of loki, cloudwatch")
+	}
+	return nil
+}
+
+// HookConfig 容器生命周期钩子配置，command与url二选一
+type HookConfig struct {
+	// 触发阶段：pre_create、post_start、pre_remove、post_remove
+	Stage   string   `yaml:"stage"`
+	Command []string `yaml:"command,omitempty"`
+	URL     string   `yaml:"url,omitempty"`
+	// 执行超时（秒），0表示使用默认的30秒
+	TimeoutSeconds int `yaml:"timeout_seconds,omitempty"`
+	// 失败时中止容器创建或删除（仅对pre_*阶段有效）
+	FailOnError bool `yaml:"fail_on_error,omitempty"`
+}
+
+// Hook 转换为钩子定义
+func (h HookConfig) Hook() hooks.Hook {
+	return hooks.Hook{
+		Stage:       h.Stage,
+		Command:     h.Command,
+		URL:         h.URL,
+		Timeout:     time.Duration(h.TimeoutSeconds) * time.Second,
+		FailOnError: h.FailOnError,
+	}
 }
 
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
+		ConfigVersion:    CurrentConfigVersion,
 		IdentityFilePath: "/etc/utopia/node_id",
+		DataDir:          "/var/lib/utopia",
+		LogLevel:         "info",
+		LogSampling: LogSamplingConfig{
+			Enabled:       true,
+			WindowSeconds: 300,
+			Burst:         3,
+		},
+		Redaction: RedactionConfig{
+			Enabled:  true,
+			Patterns: []string{"*TOKEN*", "*SECRET*", "*KEY*", "*PASSWORD*"},
+		},
 		CentralPlatform: CentralPlatformConfig{
-			APIURL: "http://api.server.com",
+			APIURL:                   "http://api.server.com",
+			HeartbeatIntervalSeconds: 30,
+
+			SigningKeysRefreshSeconds:   3600,
+			StandaloneOnUnreachable:     true,
+			RegistrationRetryMaxSeconds: 300,
 		},
 		FRP: FRPConfig{
 			ServerAddr: "api.server.com",
 			ServerPort: 7000,
 			Token:      "frp_connection_token",
+
+			ClaimPortsPerNode:   32,
+			StartTimeoutSeconds: 30,
+			AdminPort:           7400,
+			Download: FRPCDownloadConfig{
+				Enabled:    true,
+				Version:    "0.61.1",
+				MirrorURL:  "https://github.com/fatedier/frp/releases/download",
+				InstallDir: "/var/lib/utopia/bin",
+			},
 		},
 		AgentAPI: AgentAPIConfig{
-			ListenAddress: "127.0.0.1:9200",
-			AuthToken:     "a_very_secret_agent_api_token",
+			ListenAddress:         "127.0.0.1:9200",
+			AdminListenAddress:    "127.0.0.1:9201",
+			AuthToken:             "a_very_secret_agent_api_token",
+			IdempotencyTTLSeconds: 86400,
+		},
+		Container: ContainerConfig{
+			CrashLoopMaxRestarts:   5,
+			CrashLoopWindowMinutes: 10,
+			HostnamePattern:        "{{claim_id}}.node{{node_id}}.utopia",
+			RefreshConcurrency:     8,
+			CreateTimeoutSeconds:   900,
+			AllowedVolumeRoots:     []string{"/data"},
+			HibernateKeepGPUs:      true,
+			CoreDumps: CoreDumpConfig{
+				MaxSizeMB:  4096,
+				MaxTotalMB: 16384,
+			},
+			PortPlan: PortPlanConfig{
+				GPUBase:      8000,
+				GPUStride:    10,
+				DynamicStart: 20000,
+				DynamicEnd:   29999,
+			},
+		},
+		GPU: GPUConfig{
+			BusyMemoryPercent:      10,
+			BusyUtilizationPercent: 10,
+			BusyWindowSeconds:      30,
+		},
+		BreakGlass: BreakGlassConfig{
+			Shell:              "/bin/bash",
+			AuditDir:           "/var/log/utopia/break-glass",
+			MaxSessionMinutes:  60,
+			MaxTokenTTLSeconds: 300,
+		},
+		CommandSigning: CommandSigningConfig{
+			MaxTokenTTLSeconds: 300,
+		},
+		Tracing: TracingConfig{
+			OTLPEndpoint: "localhost:4318",
+			Insecure:     true,
+			SampleRatio:  1.0,
+		},
+		ImageBuild: ImageBuildConfig{
+			CPUs:           2,
+			MemoryMB:       4096,
+			TimeoutSeconds: 1800,
+			MaxContextMB:   512,
+		},
+		LazyPull: LazyPullConfig{
+			Snapshotter: "stargz",
+		},
+		Power: PowerConfig{
+			RebootCommand:   []string{"systemctl", "reboot"},
+			ShutdownCommand: []string{"systemctl", "poweroff"},
+			SuspendCommand:  []string{"systemctl", "suspend"},
+			MaxDelaySeconds: 3600,
+		},
+		Secrets: SecretsConfig{
+			RuntimeDir: "/run/utopia/secrets",
+		},
+		Security: SecurityConfig{
+			Enabled: true,
+		},
+		CommandPolicy: CommandPolicyConfig{
+			DenyPatterns: []string{
+				`(?i)\b(xmrig|minerd|cpuminer|cgminer|bfgminer|ethminer|nbminer|t-rex|phoenixminer|lolminer|gminer|nanominer|teamredminer)\b`,
+				`(?i)stratum\+(tcp|ssl|tls)://`,
+				`:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`,
+			},
+		},
+		MPS: MPSConfig{
+			Dir:              "/run/utopia/mps",
+			MaxClientsPerGPU: 4,
+		},
+		TimeSlicing: TimeSlicingConfig{
+			ReplicasPerGPU: 2,
+		},
+		Overcommit: OvercommitConfig{
+			CPURatio:        4,
+			MemoryRatio:     1,
+			GPUMemoryRatio:  1,
+			DefaultCPUs:     1,
+			DefaultMemoryMB: 1024,
+		},
+		SystemReserved: SystemReservedConfig{
+			CPUs:     1,
+			MemoryMB: 2048,
+			DiskGB:   10,
+		},
+		DatasetCache: DatasetCacheConfig{
+			BudgetGB: 500,
+		},
+		ExternalVolumes: ExternalVolumesConfig{
+			Types:               []string{"nfs", "s3", "smb"},
+			S3Driver:            "s3fs",
+			MountTimeoutSeconds: 60,
 		},
+		Accounting: AccountingConfig{
+			SampleIntervalSeconds: 15,
+			ReportIntervalSeconds: 300,
+		},
+		Monitor: MonitorConfig{
+			GPUIntervalSeconds:       10,
+			ContainerIntervalSeconds: 30,
+			FRPIntervalSeconds:       30,
+			AddressIntervalSeconds:   60,
+			Adaptive: AdaptiveMonitorConfig{
+				Enabled:               true,
+				MinIntervalSeconds:    2,
+				MaxIntervalSeconds:    60,
+				LoadPercent:           90,
+				GPUUtilizationPercent: 95,
+				TemperatureC:          85,
+				TemperatureJumpC:      10,
+				BoostSeconds:          300,
+			},
+			HostMetrics:             HostMetricsConfig{Enabled: true},
+			ClaimGPUIntervalSeconds: 5,
+		},
+		Metrics: MetricsConfig{
+			IntervalSeconds: 15,
+			BatchSize:       1000,
+			QueueSize:       10000,
+			Platform:        true,
+		},
+		LogShipping: LogShippingConfig{
+			MaxLineBytes:            16 * 1024,
+			RateLimitLinesPerSecond: 200,
+			BatchSize:               1000,
+			QueueSize:               50000,
+			FlushIntervalSeconds:    5,
+			SyncIntervalSeconds:     10,
+			Platform:                true,
+		},
+		Schedule: ScheduleConfig{
+			Enabled:            true,
+			MaxAdvanceDays:     30,
+			MinFreeDiskGB:      10,
+			ReserveLeadSeconds: 300,
+		},
+		GPUCleanup: GPUCleanupConfig{
+			Enabled:        true,
+			MaxMemoryMB:    256,
+			TimeoutSeconds: 30,
+			KillProcesses:  true,
+		},
+		GPUContention: GPUContentionConfig{
+			Enabled:         true,
+			IntervalSeconds: 60,
+			Action:          ContentionActionReport,
+		},
+		GPUDriverUpgrade: GPUDriverUpgradeConfig{
+			TimeoutSeconds: 1800,
+		},
+		ThermalPolicy: ThermalPolicyConfig{
+			IntervalSeconds:    30,
+			MaxTemperatureC:    85,
+			ResumeTemperatureC: 78,
+			SustainSeconds:     120,
+		},
+		ClaimDNS: ClaimDNSConfig{
+			Domain: "claims.local",
+		},
+		IdleShutdown: IdleShutdownConfig{
+			Enabled:               true,
+			IntervalSeconds:       60,
+			IdleMinutes:           120,
+			Action:                IdleActionReport,
+			StopGraceMinutes:      30,
+			GPUUtilizationPercent: 5,
+			NetworkRxKBPerMinute:  64,
+		},
+		RuntimeLimit: RuntimeLimitConfig{
+			IntervalSeconds:    15,
+			WarningMinutes:     []int{30, 5},
+			GracePeriodSeconds: 30,
+		},
+		Handover: HandoverConfig{
+			Enabled:             false,
+			ReadyTimeoutSeconds: 120,
+		},
+		SelfTest: SelfTestConfig{
+			Enabled:        true,
+			Image:          "nvidia/cuda:12.4.1-base-ubuntu22.04",
+			TimeoutSeconds: 600,
+		},
+	}
+}
+
+// expandEnv 展开路径与凭据中的环境变量引用
+func (c *Config) expandEnv() {
+	c.IdentityFilePath = os.ExpandEnv(c.IdentityFilePath)
+	c.DataDir = os.ExpandEnv(c.DataDir)
+	c.OverridesFilePath = os.ExpandEnv(c.OverridesFilePath)
+	c.DatasetCache.Dir = os.ExpandEnv(c.DatasetCache.Dir)
+	c.ExternalVolumes.Dir = os.ExpandEnv(c.ExternalVolumes.Dir)
+	c.Container.CoreDumps.Dir = os.ExpandEnv(c.Container.CoreDumps.Dir)
+	c.Secrets.KeyFile = os.ExpandEnv(c.Secrets.KeyFile)
+	c.Secrets.RuntimeDir = os.ExpandEnv(c.Secrets.RuntimeDir)
+	c.Migration.SpoolDir = os.ExpandEnv(c.Migration.SpoolDir)
+	c.Security.ProfileDir = os.ExpandEnv(c.Security.ProfileDir)
+	c.AgentAPI.AuthTokenFile = os.ExpandEnv(c.AgentAPI.AuthTokenFile)
+	c.AgentAPI.UnixSocket = os.ExpandEnv(c.AgentAPI.UnixSocket)
+	c.AgentAPI.AdminAuthTokenFile = os.ExpandEnv(c.AgentAPI.AdminAuthTokenFile)
+	for i := range c.AgentAPI.Tenants {
+		c.AgentAPI.Tenants[i].TokenFile = os.ExpandEnv(c.AgentAPI.Tenants[i].TokenFile)
+	}
+	c.FRP.TokenFile = os.ExpandEnv(c.FRP.TokenFile)
+	c.Registry.PasswordFile = os.ExpandEnv(c.Registry.PasswordFile)
+	for i := range c.Registry.Credentials {
+		c.Registry.Credentials[i].PasswordFile = os.ExpandEnv(c.Registry.Credentials[i].PasswordFile)
+	}
+	c.FRP.Download.InstallDir = os.ExpandEnv(c.FRP.Download.InstallDir)
+	c.CentralPlatform.BootstrapTokenFile = os.ExpandEnv(c.CentralPlatform.BootstrapTokenFile)
+	c.MPS.Dir = os.ExpandEnv(c.MPS.Dir)
+	c.ClaimDNS.HostsFile = os.ExpandEnv(c.ClaimDNS.HostsFile)
+	c.ClaimDNS.ReloadPIDFile = os.ExpandEnv(c.ClaimDNS.ReloadPIDFile)
+	for i := range c.Metrics.Sinks {
+		c.Metrics.Sinks[i].Token = os.ExpandEnv(c.Metrics.Sinks[i].Token)
+	}
+	for i := range c.LogShipping.Sinks {
+		sink := &c.LogShipping.Sinks[i]
+		sink.AccessKeyID = os.ExpandEnv(sink.AccessKeyID)
+		sink.SecretAccessKey = os.ExpandEnv(sink.SecretAccessKey)
+		for name, value := range sink.Headers {
+			sink.Headers[name] = os.ExpandEnv(value)
+		}
 	}
+	for i := range c.Hooks {
+		c.Hooks[i].URL = os.ExpandEnv(c.Hooks[i].URL)
+	}
+}
+
+// OverridesPath 返回配置覆盖文件的实际路径
+func (c *Config) OverridesPath() string {
+	if c.OverridesFilePath != "" {
+		return c.OverridesFilePath
+	}
+	return filepath.Join(c.DataDir, "overrides.yaml")
+}
+
+// DatasetCacheDir 返回数据集缓存目录的实际路径
+func (c *Config) DatasetCacheDir() string {
+	if c.DatasetCache.Dir != "" {
+		return c.DatasetCache.Dir
+	}
+	return filepath.Join(c.DataDir, "datasets")
+}
+
+// CoreDumpsDir 返回核心转储目录的实际路径
+func (c *Config) CoreDumpsDir() string {
+	if c.Container.CoreDumps.Dir != "" {
+		return c.Container.CoreDumps.Dir
+	}
+	return filepath.Join(c.DataDir, "core-dumps")
 }
 
-// LoadConfig 从文件加载配置
-func LoadConfig(path string) (*Config, error) {
-	cfg := DefaultConfig()
+// ExternalVolumesDir 返回外部存储卷挂载点目录的实际路径
+func (c *Config) ExternalVolumesDir() string {
+	if c.ExternalVolumes.Dir != "" {
+		return c.ExternalVolumes.Dir
+	}
+	return filepath.Join(c.DataDir, "mounts")
+}
+
+// ClaimDNSHostsFile 返回claim DNS的hosts文件路径
+func (c *Config) ClaimDNSHostsFile() string {
+	if c.ClaimDNS.HostsFile != "" {
+		return c.ClaimDNS.HostsFile
+	}
+	return filepath.Join(c.DataDir, "claims.hosts")
+}
+
+// SecretsKeyFile 返回节点加密私钥文件的实际路径
+func (c *Config) SecretsKeyFile() string {
+	if c.Secrets.KeyFile != "" {
+		return c.Secrets.KeyFile
+	}
+	return filepath.Join(c.DataDir, "encryption_key")
+}
+
+// MigrationSpoolDir 返回迁移暂存目录的实际路径
+func (c *Config) MigrationSpoolDir() string {
+	if c.Migration.SpoolDir != "" {
+		return c.Migration.SpoolDir
+	}
+	return filepath.Join(c.DataDir, "migrations")
+}
 
-	// 如果配置文件不存在，返回默认配置
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return cfg, nil
+// SecurityProfileDir 返回AppArmor配置文件目录的实际路径
+func (c *Config) SecurityProfileDir() string {
+	if c.Security.ProfileDir != "" {
+		return c.Security.ProfileDir
 	}
+	return filepath.Join(c.DataDir, "apparmor")
+}
 
+// ReadSecretFile 读取令牌文件，去除首尾空白，文件为空时返回错误
+func ReadSecretFile(path string) (string, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
 	}
-
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("%s is empty", path)
 	}
+	return secret, nil
+}
 
-	cfg.IdentityFilePath = os.ExpandEnv(cfg.IdentityFilePath)
-	return cfg, nil
+// ReadPrivateSecretFile 读取只允许属主访问的凭据文件，组或其他用户可访问时返回错误
+func ReadPrivateSecretFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("%s must not be accessible by group or others (mode %04o)", path, info.Mode().Perm())
+	}
+	return ReadSecretFile(path)
 }
 
 // Validate 验证配置
 func (c *Config) Validate() error {
+	if c.ConfigVersion != CurrentConfigVersion {
+		return fmt.Errorf("config_version must be %d", CurrentConfigVersion)
+	}
 	if c.CentralPlatform.APIURL == "" {
 		return fmt.Errorf("central_platform.api_url is required")
 	}
+	if u := c.CentralPlatform.BootstrapTokenURL; u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return fmt.Errorf("central_platform.bootstrap_token_url must be http(s)")
+	}
+	if c.CentralPlatform.HeartbeatIntervalSeconds <= 0 {
+		return fmt.Errorf("central_platform.heartbeat_interval_seconds must be positive")
+	}
+	if c.CentralPlatform.StandaloneOnUnreachable && c.CentralPlatform.RegistrationRetryMaxSeconds <= 0 {
+		return fmt.Errorf("central_platform.registration_retry_max_seconds must be positive")
+	}
+	if c.DataDir == "" {
+		return fmt.Errorf("data_dir is required")
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("log_level must be one of debug, info, warn, error")
+	}
+	if c.LogSampling.Enabled && (c.LogSampling.WindowSeconds <= 0 || c.LogSampling.Burst <= 0) {
+		return fmt.Errorf("log_sampling.window_seconds and log_sampling.burst must be positive")
+	}
+	for _, pattern := range c.Redaction.Patterns {
+		if _, err := path.Match(strings.ToUpper(pattern), ""); err != nil || pattern == "" {
+			return fmt.Errorf("redaction.patterns: invalid pattern %q", pattern)
+		}
+	}
+	if c.Monitor.GPUIntervalSeconds <= 0 || c.Monitor.ContainerIntervalSeconds <= 0 || c.Monitor.FRPIntervalSeconds <= 0 ||
+		c.Monitor.AddressIntervalSeconds <= 0 {
+		return fmt.Errorf("monitor intervals must be positive")
+	}
+	if c.Monitor.ClaimGPUIntervalSeconds < 0 {
+		return fmt.Errorf("monitor.claim_gpu_interval_seconds must be non-negative")
+	}
+	if adaptive := c.Monitor.Adaptive; adaptive.Enabled {
+		if adaptive.MinIntervalSeconds <= 0 || adaptive.MaxIntervalSeconds < adaptive.MinIntervalSeconds {
+			return fmt.Errorf("monitor.adaptive.min_interval_seconds must be positive and not greater than max_interval_seconds")
+		}
+		if adaptive.LoadPercent <= 0 || adaptive.GPUUtilizationPercent <= 0 || adaptive.TemperatureC <= 0 ||
+			adaptive.TemperatureJumpC <= 0 || adaptive.BoostSeconds <= 0 {
+			return fmt.Errorf("monitor.adaptive thresholds must be positive")
+		}
+	}
+	for _, pattern := range append(append([]string{}, c.Monitor.HostMetrics.Devices...), c.Monitor.HostMetrics.Interfaces...) {
+		if _, err := filepath.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("monitor.host_metrics: invalid pattern %q", pattern)
+		}
+	}
 	if c.FRP.ServerAddr == "" {
 		return fmt.Errorf("frp.server_addr is required")
 	}
+	if err := ValidateHost(c.FRP.ServerAddr); err != nil {
+		return fmt.Errorf("frp.server_addr: %w", err)
+	}
 	if c.FRP.ServerPort <= 0 {
 		return fmt.Errorf("frp.server_port must be positive")
 	}
+	for i, server := range c.FRP.FallbackServers {
+		if err := ValidateHost(server.Addr); err != nil {
+			return fmt.Errorf("frp.fallback_servers[%d].addr: %w", i, err)
+		}
+		if server.Port < 0 || server.Port > 65535 {
+			return fmt.Errorf("frp.fallback_servers[%d].port must be between 0 and 65535", i)
+		}
+	}
+	if c.FRP.ClaimPortRangeStart < 0 || c.FRP.ClaimPortRangeStart > 65535 {
+		return fmt.Errorf("frp.claim_port_range_start must be between 0 and 65535")
+	}
+	if c.FRP.ClaimPortRangeStart > 0 && c.FRP.ClaimPortsPerNode <= 0 {
+		return fmt.Errorf("frp.claim_ports_per_node must be positive when claim port publishing is enabled")
+	}
+	if c.FRP.PublicHost != "" {
+		if err := ValidateHost(c.FRP.PublicHost); err != nil {
+			return fmt.Errorf("frp.public_host: %w", err)
+		}
+	}
+	if c.FRP.StartTimeoutSeconds <= 0 {
+		return fmt.Errorf("frp.start_timeout_seconds must be positive")
+	}
+	if c.FRP.Download.Enabled {
+		d := c.FRP.Download
+		if !frpVersionPattern.MatchString(d.Version) {
+			return fmt.Errorf("frp.frpc_download.version must be a release version such as 0.61.1, got %q", d.Version)
+		}
+		if !strings.HasPrefix(d.MirrorURL, "https://") {
+			return fmt.Errorf("frp.frpc_download.mirror_url must be an https URL, got %q", d.MirrorURL)
+		}
+		if !filepath.IsAbs(d.InstallDir) {
+			return fmt.Errorf("frp.frpc_download.install_dir must be an absolute path")
+		}
+		for arch, sum := range d.SHA256 {
+			if !sha256Pattern.MatchString(sum) {
+				return fmt.Errorf("frp.frpc_download.sha256[%s] must be 64 hex characters", arch)
+			}
+		}
+	}
+	if c.FRP.AdminPort < 0 || c.FRP.AdminPort > 65535 {
+		return fmt.Errorf("frp.admin_port must be between 0 and 65535")
+	}
 	if c.AgentAPI.ListenAddress == "" {
 		return fmt.Errorf("agent_api.listen_address is required")
 	}
-	if c.AgentAPI.AuthToken == "" {
-		return fmt.Errorf("agent_api.auth_token is required")
+	if err := ValidateListenAddress(c.AgentAPI.ListenAddress); err != nil {
+		return fmt.Errorf("agent_api.listen_address: %w", err)
+	}
+	for _, address := range c.AgentAPI.AdditionalListenAddresses {
+		if err := ValidateListenAddress(address); err != nil {
+			return fmt.Errorf("agent_api.additional_listen_addresses: %w", err)
+		}
+	}
+	if c.AgentAPI.AuthToken == "" && c.AgentAPI.AuthTokenFile == "" {
+		return fmt.Errorf("agent_api.auth_token or agent_api.auth_token_file is required")
+	}
+	if address := c.AgentAPI.AdminListenAddress; address != "" {
+		if err := ValidateListenAddress(address); err != nil {
+			return fmt.Errorf("agent_api.admin_listen_address: %w", err)
+		}
+		for _, main := range append([]string{c.AgentAPI.ListenAddress}, c.AgentAPI.AdditionalListenAddresses...) {
+			if address == main {
+				return fmt.Errorf("agent_api.admin_listen_address must differ from the API listen addresses")
+			}
+		}
+	}
+	if c.AgentAPI.AdminAuthToken != "" && c.AgentAPI.AdminAuthToken == c.AgentAPI.AuthToken {
+		return fmt.Errorf("agent_api.admin_auth_token must differ from agent_api.auth_token")
+	}
+	if c.AgentAPI.IdempotencyTTLSeconds < 0 {
+		return fmt.Errorf("agent_api.idempotency_ttl_seconds must be non-negative")
+	}
+	if c.AgentAPI.UnixSocket != "" && !filepath.IsAbs(c.AgentAPI.UnixSocket) {
+		return fmt.Errorf("agent_api.unix_socket must be an absolute path")
+	}
+	for _, id := range append(append([]int{}, c.AgentAPI.UnixSocketAllowedUIDs...), c.AgentAPI.UnixSocketAllowedGIDs...) {
+		if id < 0 {
+			return fmt.Errorf("agent_api.unix_socket_allowed_uids and unix_socket_allowed_gids must be non-negative")
+		}
+	}
+	tenantNames := make(map[string]bool)
+	for _, tenant := range c.AgentAPI.Tenants {
+		if !nodeLabelKeyPattern.MatchString(tenant.Name) {
+			return fmt.Errorf("agent_api.tenants: invalid tenant name %q", tenant.Name)
+		}
+		if tenantNames[tenant.Name] {
+			return fmt.Errorf("agent_api.tenants: duplicate tenant %q", tenant.Name)
+		}
+		tenantNames[tenant.Name] = true
+		if tenant.Token == "" && tenant.TokenFile == "" {
+			return fmt.Errorf("agent_api.tenants[%s]: token or token_file is required", tenant.Name)
+		}
+		if tenant.Token != "" && tenant.Token == c.AgentAPI.AuthToken {
+			return fmt.Errorf("agent_api.tenants[%s]: token must differ from agent_api.auth_token", tenant.Name)
+		}
+		if tenant.MaxContainers < 0 || tenant.MaxGPUs < 0 || tenant.MaxDiskGB < 0 || tenant.MaxCreatesPerMinute < 0 {
+			return fmt.Errorf("agent_api.tenants[%s]: quotas must be non-negative", tenant.Name)
+		}
+	}
+	if c.Container.DefaultStorageSizeGB < 0 {
+		return fmt.Errorf("container.default_storage_size_gb must be non-negative")
+	}
+	for _, server := range c.Container.DNSServers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("container.dns_servers: invalid address %q", server)
+		}
+	}
+	if c.Container.CreateTimeoutSeconds < 0 {
+		return fmt.Errorf("container.create_timeout_seconds must be non-negative")
+	}
+	if c.Container.RefreshConcurrency <= 0 {
+		return fmt.Errorf("container.refresh_concurrency must be positive")
+	}
+	if c.Container.CrashLoopMaxRestarts < 0 || c.Container.CrashLoopWindowMinutes <= 0 {
+		return fmt.Errorf("container crash loop thresholds must be positive")
+	}
+	for name, limit := range c.Container.Ulimits {
+		switch name {
+		case "nofile", "nproc", "core", "memlock", "stack":
+		default:
+			return fmt.Errorf("container.ulimits: unsupported ulimit %q", name)
+		}
+		if limit.Soft < -1 || limit.Hard < -1 || (limit.Hard != -1 && (limit.Soft == -1 || limit.Soft > limit.Hard)) {
+			return fmt.Errorf("container.ulimits.%s: soft and hard must be non-negative or -1, with soft not above hard", name)
+		}
+	}
+	if cfg := c.Container.IOLimits; cfg.ReadBPS < 0 || cfg.WriteBPS < 0 || cfg.ReadIOPS < 0 || cfg.WriteIOPS < 0 {
+		return fmt.Errorf("container.io_limits: limits must be non-negative")
+	} else if cfg.Device != "" && !strings.HasPrefix(cfg.Device, "/dev/") {
+		return fmt.Errorf("container.io_limits.device must be a path under /dev")
+	}
+	if cfg := c.Container.CoreDumps; cfg.Enabled {
+		if cfg.MaxSizeMB <= 0 || cfg.MaxTotalMB < 0 {
+			return fmt.Errorf("container.core_dumps: max_size_mb must be positive and max_total_mb non-negative")
+		}
+		if strings.ContainsAny(cfg.CorePattern, "\n") || (strings.HasPrefix(cfg.CorePattern, "/") && filepath.Dir(cfg.CorePattern) == "/") {
+			return fmt.Errorf("container.core_dumps.core_pattern: invalid pattern %q", cfg.CorePattern)
+		}
+	}
+	if plan := c.Container.PortPlan; plan.GPUBase < 1 || plan.GPUBase > 65535 || plan.GPUStride < 2 {
+		return fmt.Errorf("container.port_plan: gpu_base must be between 1 and 65535 and gpu_stride at least 2")
+	}
+	if plan := c.Container.PortPlan; plan.DynamicStart != 0 && (plan.DynamicStart < 1 || plan.DynamicEnd < plan.DynamicStart || plan.DynamicEnd > 65535) {
+		return fmt.Errorf("container.port_plan: dynamic range %d-%d must be within 1-65535", plan.DynamicStart, plan.DynamicEnd)
+	}
+	if c.GPU.BusyMemoryPercent < 0 || c.GPU.BusyMemoryPercent > 100 || c.GPU.BusyUtilizationPercent < 0 || c.GPU.BusyUtilizationPercent > 100 {
+		return fmt.Errorf("gpu busy thresholds must be between 0 and 100")
+	}
+	if c.GPU.BusyWindowSeconds < 0 {
+		return fmt.Errorf("gpu.busy_window_seconds must be non-negative")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample_ratio must be between 0 and 1")
+	}
+	if c.Tracing.Enabled && c.Tracing.OTLPEndpoint == "" {
+		return fmt.Errorf("tracing.otlp_endpoint is required when tracing is enabled")
+	}
+	if c.Accounting.Enabled && (c.Accounting.SampleIntervalSeconds <= 0 || c.Accounting.ReportIntervalSeconds <= 0) {
+		return fmt.Errorf("accounting intervals must be positive")
+	}
+	if c.Secrets.Enabled && c.Secrets.RuntimeDir == "" {
+		return fmt.Errorf("secrets.runtime_dir is required when secrets are enabled")
+	}
+	for _, name := range c.Security.AllowedRelaxations {
+		if err := security.ValidateRelaxation(name); err != nil {
+			return fmt.Errorf("security.allowed_relaxations: %w", err)
+		}
+	}
+	for _, pattern := range append(append([]string{}, c.CommandPolicy.DenyPatterns...), c.CommandPolicy.AllowPatterns...) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("command_policy: invalid pattern %q: %v", pattern, err)
+		}
+	}
+	if c.Security.Required && !c.Security.Enabled {
+		return fmt.Errorf("security.enabled is required when security.required is set")
+	}
+	if c.MPS.Enabled && c.MPS.Dir == "" {
+		return fmt.Errorf("mps.dir is required when mps is enabled")
+	}
+	if c.MPS.MaxClientsPerGPU < 0 {
+		return fmt.Errorf("mps.max_clients_per_gpu must be non-negative")
+	}
+	if c.TimeSlicing.Enabled && (c.TimeSlicing.ReplicasPerGPU < 2 || c.TimeSlicing.ReplicasPerGPU > 16) {
+		return fmt.Errorf("time_slicing.replicas_per_gpu must be between 2 and 16")
+	}
+	if c.TimeSlicing.MaxGPUs < 0 {
+		return fmt.Errorf("time_slicing.max_gpus must be non-negative")
+	}
+	oc := c.Overcommit
+	if oc.CPURatio < 0 || oc.MemoryRatio < 0 || oc.GPUMemoryRatio < 0 {
+		return fmt.Errorf("overcommit ratios must be non-negative")
+	}
+	if oc.DefaultCPUs < 0 || oc.DefaultMemoryMB < 0 || oc.DefaultGPUMemoryMB < 0 {
+		return fmt.Errorf("overcommit defaults must be non-negative")
+	}
+	if c.SystemReserved.CPUs < 0 || c.SystemReserved.MemoryMB < 0 || c.SystemReserved.DiskGB < 0 {
+		return fmt.Errorf("system_reserved values must be non-negative")
+	}
+	if c.Metrics.IntervalSeconds <= 0 || c.Metrics.BatchSize <= 0 || c.Metrics.QueueSize < c.Metrics.BatchSize {
+		return fmt.Errorf("metrics.interval_seconds and metrics.batch_size must be positive, and metrics.queue_size at least batch_size")
+	}
+	for i := range c.Metrics.Sinks {
+		if err := c.Metrics.Sinks[i].Validate(); err != nil {
+			return fmt.Errorf("metrics.sinks[%d]: %w", i, err)
+		}
+	}
+	if c.LogShipping.Enabled {
+		ls := c.LogShipping
+		if ls.MaxLineBytes <= 0 || ls.BatchSize <= 0 || ls.QueueSize < ls.BatchSize {
+			return fmt.Errorf("log_shipping.max_line_bytes and log_shipping.batch_size must be positive, and log_shipping.queue_size at least batch_size")
+		}
+		if ls.FlushIntervalSeconds <= 0 || ls.SyncIntervalSeconds <= 0 {
+			return fmt.Errorf("log_shipping intervals must be positive")
+		}
+		if ls.RateLimitLinesPerSecond < 0 {
+			return fmt.Errorf("log_shipping.rate_limit_lines_per_second must be non-negative")
+		}
+		if !ls.Platform && len(ls.Sinks) == 0 {
+			return fmt.Errorf("log_shipping requires platform or at least one sink")
+		}
+	}
+	for i := range c.LogShipping.Sinks {
+		if err := c.LogShipping.Sinks[i].Validate(); err != nil {
+			return fmt.Errorf("log_shipping.sinks[%d]: %w", i, err)
+		}
+	}
+	for i, credential := range c.Registry.Credentials {
+		if credential.Server == "" || credential.Username == "" {
+			return fmt.Errorf("registry.credentials[%d]: server and username are required", i)
+		}
+		if credential.Password == "" && credential.PasswordFile == "" {
+			return fmt.Errorf("registry.credentials[%d]: password or password_file is required", i)
+		}
+	}
+	for i, h := range c.Hooks {
+		hook := h.Hook()
+		if err := hook.Validate(); err != nil {
+			return fmt.Errorf("hooks[%d]: %w", i, err)
+		}
+		if h.TimeoutSeconds < 0 {
+			return fmt.Errorf("hooks[%d]: timeout_seconds must be non-negative", i)
+		}
+	}
+	for _, root := range c.Container.AllowedVolumeRoots {
+		if !filepath.IsAbs(root) || filepath.Clean(root) == "/" {
+			return fmt.Errorf("container.allowed_volume_roots entries must be absolute paths other than /, got %q", root)
+		}
+	}
+	if c.GPUCleanup.MaxMemoryMB < 0 || c.GPUCleanup.TimeoutSeconds < 0 {
+		return fmt.Errorf("gpu_cleanup.max_memory_mb and gpu_cleanup.timeout_seconds must be non-negative")
+	}
+	if c.GPUContention.Enabled && c.GPUContention.IntervalSeconds <= 0 {
+		return fmt.Errorf("gpu_contention.interval_seconds must be positive")
+	}
+	if c.GPUContention.Action != ContentionActionReport && c.GPUContention.Action != ContentionActionStop {
+		return fmt.Errorf("gpu_contention.action must be %q or %q", ContentionActionReport, ContentionActionStop)
+	}
+	for _, pattern := range c.GPUContention.IgnoreContainers {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("gpu_contention.ignore_containers: invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if len(c.GPUDriverUpgrade.Command) > 0 && c.GPUDriverUpgrade.TimeoutSeconds <= 0 {
+		return fmt.Errorf("gpu_driver_upgrade.timeout_seconds must be positive")
+	}
+	if c.ThermalPolicy.IntervalSeconds <= 0 || c.ThermalPolicy.SustainSeconds < 0 {
+		return fmt.Errorf("thermal_policy.interval_seconds must be positive and thermal_policy.sustain_seconds non-negative")
+	}
+	if c.ThermalPolicy.ResumeTemperatureC >= c.ThermalPolicy.MaxTemperatureC {
+		return fmt.Errorf("thermal_policy.resume_temperature_c must be below thermal_policy.max_temperature_c")
+	}
+	if c.ThermalPolicy.PowerLimitPercent < 0 || c.ThermalPolicy.PowerLimitPercent > 100 {
+		return fmt.Errorf("thermal_policy.power_limit_percent must be between 0 and 100")
+	}
+	if c.ThermalPolicy.FanSpeedPercent < 0 || c.ThermalPolicy.FanSpeedPercent > 100 {
+		return fmt.Errorf("thermal_policy.fan_speed_percent must be between 0 and 100")
+	}
+	if c.ClaimDNS.Enabled {
+		if err := ValidateHost(c.ClaimDNS.Domain); err != nil || net.ParseIP(c.ClaimDNS.Domain) != nil {
+			return fmt.Errorf("claim_dns.domain must be a valid domain name, got %q", c.ClaimDNS.Domain)
+		}
+		if c.ClaimDNS.ResolverAddress != "" && net.ParseIP(c.ClaimDNS.ResolverAddress) == nil {
+			return fmt.Errorf("claim_dns.resolver_address must be an IP address, got %q", c.ClaimDNS.ResolverAddress)
+		}
+	}
+	if c.IdleShutdown.IntervalSeconds <= 0 || c.IdleShutdown.IdleMinutes <= 0 {
+		return fmt.Errorf("idle_shutdown.interval_seconds and idle_shutdown.idle_minutes must be positive")
+	}
+	if c.IdleShutdown.Action != IdleActionReport && c.IdleShutdown.Action != IdleActionStop {
+		return fmt.Errorf("idle_shutdown.action must be %q or %q", IdleActionReport, IdleActionStop)
+	}
+	if c.IdleShutdown.StopGraceMinutes < 0 || c.IdleShutdown.GPUUtilizationPercent < 0 || c.IdleShutdown.NetworkRxKBPerMinute < 0 {
+		return fmt.Errorf("idle_shutdown.stop_grace_minutes and activity thresholds must be non-negative")
+	}
+	if c.RuntimeLimit.IntervalSeconds <= 0 {
+		return fmt.Errorf("runtime_limit.interval_seconds must be positive")
+	}
+	if c.RuntimeLimit.GracePeriodSeconds < 0 {
+		return fmt.Errorf("runtime_limit.grace_period_seconds must be non-negative")
+	}
+	for _, minutes := range c.RuntimeLimit.WarningMinutes {
+		if minutes <= 0 {
+			return fmt.Errorf("runtime_limit.warning_minutes must be positive")
+		}
+	}
+	if c.Handover.Enabled && c.Handover.ReadyTimeoutSeconds <= 0 {
+		return fmt.Errorf("handover.ready_timeout_seconds must be positive")
+	}
+	if c.SelfTest.Enabled && (c.SelfTest.Image == "" || c.SelfTest.TimeoutSeconds <= 0) {
+		return fmt.Errorf("self_test.image is required and self_test.timeout_seconds must be positive")
+	}
+	if c.Schedule.MaxAdvanceDays <= 0 {
+		return fmt.Errorf("schedule.max_advance_days must be positive")
+	}
+	if c.Schedule.MinFreeDiskGB < 0 || c.Schedule.ReserveLeadSeconds < 0 {
+		return fmt.Errorf("schedule.min_free_disk_gb and schedule.reserve_lead_seconds must be non-negative")
+	}
+	if c.DatasetCache.BudgetGB < 0 {
+		return fmt.Errorf("dataset_cache.budget_gb must be non-negative")
+	}
+	for _, host := range c.DatasetCache.RsyncHosts {
+		if host == "" || strings.HasPrefix(host, "-") || strings.ContainsAny(host, ":/ ") {
+			return fmt.Errorf("dataset_cache.rsync_hosts: invalid host %q", host)
+		}
+	}
+	if c.ExternalVolumes.Enabled {
+		for _, t := range c.ExternalVolumes.Types {
+			if t != "nfs" && t != "s3" && t != "smb" {
+				return fmt.Errorf("external_volumes.types: unknown type %q, must be nfs, s3 or smb", t)
+			}
+		}
+		if c.ExternalVolumes.S3Driver != "s3fs" && c.ExternalVolumes.S3Driver != "rclone" {
+			return fmt.Errorf("external_volumes.s3_driver must be s3fs or rclone")
+		}
+		if c.ExternalVolumes.MountTimeoutSeconds <= 0 {
+			return fmt.Errorf("external_volumes.mount_timeout_seconds must be positive")
+		}
+	}
+	if c.ImageBuild.CPUs < 0 || c.ImageBuild.MemoryMB < 0 {
+		return fmt.Errorf("image_build.cpus and image_build.memory_mb must be non-negative")
+	}
+	if c.ImageBuild.Enabled && (c.ImageBuild.TimeoutSeconds <= 0 || c.ImageBuild.MaxContextMB <= 0) {
+		return fmt.Errorf("image_build.timeout_seconds and image_build.max_context_mb must be positive")
+	}
+	if c.LazyPull.Enabled && c.LazyPull.Snapshotter != "stargz" && c.LazyPull.Snapshotter != "nydus" {
+		return fmt.Errorf("lazy_pull.snapshotter must be stargz or nydus")
+	}
+	if c.Power.MaxDelaySeconds < 0 {
+		return fmt.Errorf("power.max_delay_seconds must be non-negative")
+	}
+	if c.CentralPlatform.SigningKeysRefreshSeconds < 0 {
+		return fmt.Errorf("central_platform.signing_keys_refresh_seconds must be non-negative")
+	}
+	if c.BreakGlass.Enabled && c.CentralPlatform.SigningPublicKey == "" {
+		return fmt.Errorf("central_platform.signing_public_key is required when break_glass is enabled")
+	}
+	if c.CommandSigning.Enabled {
+		if c.CentralPlatform.SigningPublicKey == "" {
+			return fmt.Errorf("central_platform.signing_public_key is required when command_signing is enabled")
+		}
+		if c.CommandSigning.MaxTokenTTLSeconds <= 0 {
+			return fmt.Errorf("command_signing.max_token_ttl_seconds must be positive")
+		}
+	}
+	if err := ValidateNodeLabels(c.NodeLabels); err != nil {
+		return fmt.Errorf("node_labels: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// maxNodeLabels 节点标签的最大数量
+const maxNodeLabels = 64
+
+// maxNodeLabelValueLength 节点标签值的最大长度
+const maxNodeLabelValueLength = 256
+
+// nodeLabelKeyPattern 节点标签名：小写字母、数字、"."、"_" 与 "-"，以字母或数字开头
+var nodeLabelKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,62}$`)
+
+// reservedLabelPrefix Agent自身使用的容器标签前缀
+const reservedLabelPrefix = "utopia."
+
+// ValidateNodeLabels 验证节点标签
+func ValidateNodeLabels(labels map[string]string) error {
+	if len(labels) > maxNodeLabels {
+		return fmt.Errorf("at most %d labels are allowed", maxNodeLabels)
+	}
+	for key, value := range labels {
+		if !nodeLabelKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid label name %q", key)
+		}
+		if strings.HasPrefix(key, reservedLabelPrefix) {
+			return fmt.Errorf("label name %q uses the reserved prefix %q", key, reservedLabelPrefix)
+		}
+		if len(value) > maxNodeLabelValueLength {
+			return fmt.Errorf("value of label %q exceeds %d characters", key, maxNodeLabelValueLength)
+		}
+		if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			return fmt.Errorf("value of label %q contains control characters", key)
+		}
+	}
+	return nil
+}
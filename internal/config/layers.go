@@ -0,0 +1,427 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 配置值来源，按优先级从低到高排列
+const (
+	SourceDefault  = "default"
+	SourceFile     = "file"
+	SourceOverride = "override"
+	SourceEnv      = "env"
+	SourceFlag     = "flag"
+)
+
+// EnvPrefix 通用环境变量前缀，PHOENIX_CONFIG_MONITOR__GPU_INTERVAL_SECONDS 对应 monitor.gpu_interval_seconds
+const EnvPrefix = "PHOENIX_CONFIG_"
+
+// envAliases 兼容的环境变量名 -> 配置项
+var envAliases = map[string]string{
+	"PHOENIX_API_URL":    "central_platform.api_url",
+	"PHOENIX_FRP_SERVER": "frp.server_addr",
+	"PHOENIX_FRP_TOKEN":  "frp.token",
+	"PHOENIX_AUTH_TOKEN": "agent_api.auth_token",
+}
+
+// secretKeys 展示配置时隐藏取值的配置项（匹配路径中的任意一段）
+var secretKeys = map[string]bool{
+	"token":                   true,
+	"auth_token":              true,
+	"admin_auth_token":        true,
+	"bootstrap_token":         true,
+	"bootstrap_token_headers": true,
+	"password":                true,
+	"secret_access_key":       true,
+}
+
+// layer 一层配置，键为点分路径
+type layer struct {
+	source string
+	set    map[string]interface{}
+}
+
+// Layers 分层配置：默认值 < 配置文件 < 持久化的平台覆盖 < 环境变量 < 命令行参数
+type Layers struct {
+	defaults  map[string]interface{}
+	file      layer
+	overrides *Overrides
+	env       layer
+	flags     layer
+
+	config *Config
+}
+
+// Setting 生效的配置项及其来源
+type Setting struct {
+	Key    string
+	Value  interface{}
+	Source string
+}
+
+// Load 按优先级合并各层配置
+// flagValues 为命令行 -set 参数（key=value），环境变量从进程环境读取；
+// 持久化的平台覆盖无效时忽略覆盖，与平台重新下发补丁前的行为一致
+func Load(path string, flagValues []string) (*Layers, error) {
+	tree, err := toTree(DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	l := &Layers{
+		defaults: make(map[string]interface{}),
+		file:     layer{source: SourceFile, set: map[string]interface{}{}},
+		env:      layer{source: SourceEnv, set: map[string]interface{}{}},
+		flags:    layer{source: SourceFlag, set: map[string]interface{}{}},
+	}
+	flatten("", tree, l.defaults)
+
+	// 配置文件不存在时使用默认配置
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err == nil {
+		// 旧版结构的配置文件在内存中升级，文件本身由 config migrate 改写
+		migration, err := MigrateFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+		if len(migration.Changes) > 0 {
+			fmt.Printf("Warning: config file %s uses schema version %d, migrated to version %d in memory (%s); run 'node-agent config migrate --write' to upgrade the file\n",
+				path, migration.FromVersion, migration.ToVersion, strings.Join(migration.Changes, "; "))
+		}
+		tree, err := migration.tree()
+		if err != nil {
+			return nil, err
+		}
+		if unknown := unknownKeys(tree); len(unknown) > 0 {
+			return nil, fmt.Errorf("config file %s: unknown config keys: %s", path, strings.Join(unknown, ", "))
+		}
+		flatten("", tree, l.file.set)
+	}
+
+	for _, kv := range os.Environ() {
+		name, raw, _ := strings.Cut(kv, "=")
+		key, ok := envAliases[name]
+		if !ok {
+			if !strings.HasPrefix(name, EnvPrefix) || len(name) == len(EnvPrefix) {
+				continue
+			}
+			key = strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, EnvPrefix), "__", "."))
+		}
+		if err := l.setValue(&l.env, key, raw); err != nil {
+			return nil, fmt.Errorf("environment variable %s: %w", name, err)
+		}
+	}
+
+	for _, kv := range flagValues {
+		key, raw, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid -set %q: expected key=value", kv)
+		}
+		if err := l.setValue(&l.flags, key, raw); err != nil {
+			return nil, fmt.Errorf("-set %s: %w", key, err)
+		}
+	}
+
+	base, err := l.resolve(nil)
+	if err != nil {
+		return nil, err
+	}
+	l.config = base
+
+	overrides, err := LoadOverrides(base.OverridesPath())
+	if err != nil {
+		fmt.Printf("Warning: ignoring config overrides: %v\n", err)
+		return l, nil
+	}
+	if overrides == nil {
+		return l, nil
+	}
+	// 不含覆盖的配置本身无效时由调用方报告，不归咎于覆盖
+	withOverrides, err := l.resolve(overrides)
+	if err == nil && base.Validate() == nil {
+		err = withOverrides.Validate()
+	}
+	if err != nil {
+		fmt.Printf("Warning: ignoring invalid config overrides (version %d): %v\n", overrides.Version, err)
+		return l, nil
+	}
+	l.overrides = overrides
+	l.config = withOverrides
+	return l, nil
+}
+
+// LoadConfig 加载配置文件与环境变量合并后的配置（不含命令行参数）
+func LoadConfig(path string) (*Config, error) {
+	layers, err := Load(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return layers.Config(), nil
+}
+
+// Config 返回合并后的配置
+func (l *Layers) Config() *Config {
+	return l.config
+}
+
+// Overrides 返回启动时加载的平台配置覆盖，无覆盖或覆盖无效时为nil
+func (l *Layers) Overrides() *Overrides {
+	return l.overrides
+}
+
+// EffectivePatch 返回补丁中实际生效的配置项与被更高优先级来源覆盖的配置项（配置项 -> env 或 flag）
+// 补丁包含不允许运行时修改的配置项时返回错误
+func (l *Layers) EffectivePatch(set map[string]interface{}) (effective map[string]interface{}, shadowed map[string]string, err error) {
+	effective = make(map[string]interface{}, len(set))
+	shadowed = make(map[string]string)
+	for key, value := range set {
+		if !isPatchable(key) {
+			return nil, nil, fmt.Errorf("config key %q cannot be changed at runtime", key)
+		}
+		if source := l.shadowedBy(key); source != "" {
+			shadowed[key] = source
+			continue
+		}
+		effective[key] = value
+	}
+	return effective, shadowed, nil
+}
+
+// shadowedBy 返回设置了key或其上级路径的最高优先级来源（环境变量或命令行参数）
+func (l *Layers) shadowedBy(key string) string {
+	for _, lyr := range []layer{l.flags, l.env} {
+		if _, exists := lookupPrefix(lyr.set, key); exists {
+			return lyr.source
+		}
+	}
+	return ""
+}
+
+// Settings 返回全部生效的配置项及其来源，按键排序，敏感值已隐藏
+func (l *Layers) Settings() ([]Setting, error) {
+	tree, err := toTree(l.config)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{})
+	flatten("", tree, values)
+
+	sources := make(map[string]string)
+	for key := range l.defaults {
+		sources[key] = SourceDefault
+	}
+	for _, lyr := range l.stack(l.overrides) {
+		for key := range lyr.set {
+			for existing := range sources {
+				if strings.HasPrefix(existing, key+".") {
+					delete(sources, existing)
+				}
+			}
+			sources[key] = lyr.source
+		}
+	}
+
+	settings := make([]Setting, 0, len(values))
+	for key, value := range values {
+		source, exists := lookupPrefix(sources, key)
+		if !exists {
+			source = SourceDefault
+		}
+		settings = append(settings, Setting{Key: key, Value: redact(key, value), Source: source})
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings, nil
+}
+
+// RedactedYAML 返回合并后配置的YAML，敏感值已隐藏
+func (l *Layers) RedactedYAML() ([]byte, error) {
+	tree, err := toTree(l.config)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(redact("", tree))
+}
+
+// stack 返回按优先级从低到高排列的非默认配置层
+func (l *Layers) stack(overrides *Overrides) []layer {
+	layers := []layer{l.file}
+	if overrides != nil {
+		layers = append(layers, layer{source: SourceOverride, set: overrides.Set})
+	}
+	return append(layers, l.env, l.flags)
+}
+
+// resolve 在默认值上依次应用各层，返回展开环境变量后的配置
+func (l *Layers) resolve(overrides *Overrides) (*Config, error) {
+	if overrides != nil {
+		for key := range overrides.Set {
+			if !isPatchable(key) {
+				return nil, fmt.Errorf("config key %q cannot be changed at runtime", key)
+			}
+		}
+	}
+
+	tree := make(map[string]interface{})
+	for key, value := range l.defaults {
+		if err := setPath(tree, strings.Split(key, "."), copyValue(value)); err != nil {
+			return nil, err
+		}
+	}
+	for _, lyr := range l.stack(overrides) {
+		// 按键排序以保证应用顺序确定，整段设置先于其中的子项
+		keys := make([]string, 0, len(lyr.set))
+		for key := range lyr.set {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := setPath(tree, strings.Split(key, "."), copyValue(lyr.set[key])); err != nil {
+				return nil, fmt.Errorf("failed to apply %s value %q: %w", lyr.source, key, err)
+			}
+		}
+	}
+
+	data, err := yaml.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	cfg.expandEnv()
+	return cfg, nil
+}
+
+// setValue 解析环境变量或命令行参数中的值并检查配置项是否存在
+// 值按YAML解析（如 5、true、[a, b]），默认值为字符串的配置项保留原文
+func (l *Layers) setValue(lyr *layer, key, raw string) error {
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(raw), &value); err != nil || value == nil {
+		value = raw
+	}
+	if _, isString := l.defaults[key].(string); isString {
+		value = raw
+	}
+
+	// 只含该配置项的严格解析，拒绝不存在的配置项与类型错误的值
+	tree := make(map[string]interface{})
+	if err := setPath(tree, strings.Split(key, "."), value); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(DefaultConfig()); err != nil {
+		return fmt.Errorf("invalid config key or value: %w", err)
+	}
+
+	lyr.set[key] = value
+	return nil
+}
+
+// toTree 将配置转换为嵌套map
+func toTree(cfg *Config) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	tree := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	return tree, nil
+}
+
+// flatten 将嵌套map展开为点分路径 -> 叶子值，列表与空map作为叶子
+func flatten(prefix string, node interface{}, out map[string]interface{}) {
+	m, ok := node.(map[string]interface{})
+	if !ok || (len(m) == 0 && prefix != "") {
+		out[prefix] = node
+		return
+	}
+	for key, value := range m {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		flatten(key, value, out)
+	}
+}
+
+// lookupPrefix 查找key本身或其最长的上级路径
+func lookupPrefix[V any](set map[string]V, key string) (V, bool) {
+	for {
+		if value, exists := set[key]; exists {
+			return value, true
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			var zero V
+			return zero, false
+		}
+		key = key[:i]
+	}
+}
+
+// copyValue 深拷贝map与列表，避免修改各层共享的值
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = copyValue(item)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = copyValue(item)
+		}
+		return list
+	default:
+		return value
+	}
+}
+
+// redact 返回隐藏了敏感取值的副本，递归处理map与列表（如指标输出的 token）
+func redact(key string, value interface{}) interface{} {
+	for _, part := range strings.Split(key, ".") {
+		if secretKeys[part] {
+			if value == nil || value == "" {
+				return value
+			}
+			return "<redacted>"
+		}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for name, item := range v {
+			child := name
+			if key != "" {
+				child = key + "." + name
+			}
+			m[name] = redact(child, item)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = redact(key, item)
+		}
+		return list
+	default:
+		return value
+	}
+}
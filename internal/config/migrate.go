@@ -0,0 +1,303 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentConfigVersion 配置文件的当前结构版本
+// 修改配置结构（重命名或移动配置项）时在 migrations 中追加一步并递增此版本
+const CurrentConfigVersion = 1
+
+// configVersionKey 配置文件中记录结构版本的配置项，未设置表示版本 0
+const configVersionKey = "config_version"
+
+// migration 将配置文件从 from 版本升级到 from+1 版本，返回所做修改的说明
+type migration struct {
+	from  int
+	apply func(root *yaml.Node) ([]string, error)
+}
+
+// migrations 按版本排列的迁移步骤
+var migrations = []migration{
+	{from: 0, apply: moveLegacyKeys},
+}
+
+// legacyKeys 版本 0 配置文件中的顶层配置项 -> 现在的位置，与 envAliases 中兼容的环境变量对应
+var legacyKeys = [][2]string{
+	{"api_url", "central_platform.api_url"},
+	{"frp_server", "frp.server_addr"},
+	{"frp_token", "frp.token"},
+	{"auth_token", "agent_api.auth_token"},
+}
+
+// Migration 配置文件的结构迁移结果
+type Migration struct {
+	FromVersion int
+	ToVersion   int
+	// 迁移对配置项所做修改的说明，按执行顺序排列，不含版本号本身
+	Changes []string
+
+	doc *yaml.Node
+}
+
+// Migrated 配置文件是否低于当前版本，需要改写
+func (m *Migration) Migrated() bool {
+	return m.FromVersion != m.ToVersion
+}
+
+// YAML 返回升级后的配置文件内容，保留原有注释
+func (m *Migration) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(m.doc); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// tree 返回升级后的配置文件内容（嵌套map）
+func (m *Migration) tree() (map[string]interface{}, error) {
+	tree := make(map[string]interface{})
+	if err := m.doc.Decode(&tree); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return tree, nil
+}
+
+// MigrateFile 将配置文件内容升级到当前结构版本
+// 高于当前版本的配置文件（由更新的agent写入）返回错误
+func MigrateFile(data []byte) (*Migration, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	// 空文件视为空配置
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file must be a YAML mapping")
+	}
+
+	version := 0
+	if node, _ := mappingValue(root, configVersionKey); node != nil {
+		if err := node.Decode(&version); err != nil || version < 0 {
+			return nil, fmt.Errorf("line %d: %s must be a non-negative integer", node.Line, configVersionKey)
+		}
+	}
+	if version > CurrentConfigVersion {
+		return nil, fmt.Errorf("%s %d is newer than the latest version %d supported by this agent", configVersionKey, version, CurrentConfigVersion)
+	}
+
+	m := &Migration{FromVersion: version, ToVersion: CurrentConfigVersion, Changes: []string{}, doc: &doc}
+	for _, step := range migrations {
+		if step.from < version {
+			continue
+		}
+		changes, err := step.apply(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate config from version %d: %w", step.from, err)
+		}
+		m.Changes = append(m.Changes, changes...)
+	}
+	if m.Migrated() {
+		setConfigVersion(root, CurrentConfigVersion)
+	}
+	return m, nil
+}
+
+// moveLegacyKeys 将版本 0 的顶层配置项移到所属的配置段
+func moveLegacyKeys(root *yaml.Node) ([]string, error) {
+	var changes []string
+	for _, move := range legacyKeys {
+		moved, err := moveKey(root, move[0], move[1])
+		if err != nil {
+			return nil, err
+		}
+		if moved {
+			changes = append(changes, fmt.Sprintf("moved %s to %s", move[0], move[1]))
+		}
+	}
+	return changes, nil
+}
+
+// WriteMigratedFile 将升级后的配置写回文件，原文件保存为 path.bak
+func WriteMigratedFile(path string, m *Migration) error {
+	data, err := m.YAML()
+	if err != nil {
+		return err
+	}
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat config file: %w", err)
+	}
+	if err := os.WriteFile(path+".bak", original, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to move temp file: %w", err)
+	}
+	return nil
+}
+
+// mappingValue 返回映射节点中key对应的值节点及其键在Content中的下标，不存在时返回nil与-1
+func mappingValue(node *yaml.Node, key string) (*yaml.Node, int) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1], i
+		}
+	}
+	return nil, -1
+}
+
+// moveKey 将点分路径from处的配置项移到to，from不存在时不做修改；to已存在时返回错误
+func moveKey(root *yaml.Node, from, to string) (bool, error) {
+	parent := root
+	fromPath := strings.Split(from, ".")
+	for _, part := range fromPath[:len(fromPath)-1] {
+		node, _ := mappingValue(parent, part)
+		if node == nil || node.Kind != yaml.MappingNode {
+			return false, nil
+		}
+		parent = node
+	}
+	value, index := mappingValue(parent, fromPath[len(fromPath)-1])
+	if value == nil {
+		return false, nil
+	}
+	key := parent.Content[index]
+
+	target := root
+	toPath := strings.Split(to, ".")
+	for _, part := range toPath[:len(toPath)-1] {
+		node, _ := mappingValue(target, part)
+		switch {
+		case node == nil:
+			node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			target.Content = append(target.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: part}, node)
+		case node.Kind == yaml.ScalarNode && node.Tag == "!!null":
+			*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		case node.Kind != yaml.MappingNode:
+			return false, fmt.Errorf("cannot move %s to %s: line %d is not a mapping", from, to, node.Line)
+		}
+		target = node
+	}
+	if existing, _ := mappingValue(target, toPath[len(toPath)-1]); existing != nil {
+		return false, fmt.Errorf("both %s (line %d) and %s (line %d) are set", from, key.Line, to, existing.Line)
+	}
+
+	parent.Content = append(parent.Content[:index], parent.Content[index+2:]...)
+	key.Value = toPath[len(toPath)-1]
+	target.Content = append(target.Content, key, value)
+	return true, nil
+}
+
+// setConfigVersion 设置配置文件的结构版本，未设置时添加到文件开头
+func setConfigVersion(root *yaml.Node, version int) {
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)}
+	if node, _ := mappingValue(root, configVersionKey); node != nil {
+		*node = *value
+		return
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: configVersionKey, HeadComment: "# 配置文件结构版本，由 node-agent config migrate 升级"}
+	root.Content = append([]*yaml.Node{key, value}, root.Content...)
+}
+
+// unknownKeys 返回配置中不存在于配置结构的配置项（点分路径），按路径排序
+func unknownKeys(tree map[string]interface{}) []string {
+	unknown := []string{}
+	collectUnknown("", tree, reflect.TypeOf(Config{}), &unknown)
+	sort.Strings(unknown)
+	return unknown
+}
+
+// collectUnknown 对照类型t检查node中的配置项，类型不符的值留给解析时报告
+func collectUnknown(prefix string, node interface{}, t reflect.Type, unknown *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := yamlFields(t)
+		for key, value := range m {
+			path := joinKey(prefix, key)
+			field, known := fields[key]
+			if !known {
+				*unknown = append(*unknown, path)
+				continue
+			}
+			collectUnknown(path, value, field, unknown)
+		}
+	case reflect.Map:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, value := range m {
+			collectUnknown(joinKey(prefix, key), value, t.Elem(), unknown)
+		}
+	case reflect.Slice, reflect.Array:
+		list, ok := node.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range list {
+			collectUnknown(fmt.Sprintf("%s[%d]", prefix, i), item, t.Elem(), unknown)
+		}
+	}
+}
+
+// yamlFields 返回结构体各字段的YAML键 -> 字段类型
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// joinKey 拼接点分路径
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
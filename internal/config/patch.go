@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// patchableKeys 允许平台在运行时下发修改的配置项
+// 以 "." 结尾的条目表示前缀匹配
+var patchableKeys = []string{
+	"log_level",
+	"log_sampling.",
+	"central_platform.heartbeat_interval_seconds",
+	"monitor.gpu_interval_seconds",
+	"monitor.container_interval_seconds",
+	"monitor.frp_interval_seconds",
+	"monitor.address_interval_seconds",
+	"monitor.claim_gpu_interval_seconds",
+	"monitor.adaptive.",
+	"container.default_storage_size_gb",
+	"container.crash_loop_max_restarts",
+	"container.crash_loop_window_minutes",
+	"container.create_timeout_seconds",
+	"container.allowed_volume_roots",
+	"container.hibernate_keep_gpus",
+	"gpu.busy_memory_percent",
+	"gpu.busy_utilization_percent",
+	"gpu.busy_window_seconds",
+	"security.allowed_relaxations",
+	"command_policy.",
+	"mps.max_clients_per_gpu",
+	"time_slicing.",
+	"schedule.max_advance_days",
+	"schedule.min_free_disk_gb",
+	"schedule.reserve_lead_seconds",
+	"gpu_cleanup.max_memory_mb",
+	"gpu_cleanup.timeout_seconds",
+	"gpu_cleanup.kill_processes",
+	"gpu_cleanup.reset_gpu",
+	"idle_shutdown.",
+	"runtime_limit.",
+	"thermal_policy.",
+	"feature_flags.",
+	"node_labels.",
+}
+
+// ConfigPatch 平台下发的声明式配置补丁
+type ConfigPatch struct {
+	// 单调递增的补丁版本
+	Version int64 `json:"version" yaml:"version"`
+	// 点分路径 -> 新值，例如 "monitor.gpu_interval_seconds": 5
+	Set map[string]interface{} `json:"set" yaml:"set"`
+}
+
+// Overrides 持久化的平台配置覆盖
+type Overrides struct {
+	Version int64                  `yaml:"version"`
+	Set     map[string]interface{} `yaml:"set"`
+}
+
+// isPatchable 检查配置项是否允许运行时修改
+func isPatchable(key string) bool {
+	for _, allowed := range patchableKeys {
+		if strings.HasSuffix(allowed, ".") {
+			if strings.HasPrefix(key, allowed) && len(key) > len(allowed) {
+				return true
+			}
+		} else if key == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyPatch 在当前配置的副本上应用补丁并验证，返回新配置
+func (c *Config) ApplyPatch(set map[string]interface{}) (*Config, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	// 按键排序以保证应用顺序确定
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !isPatchable(key) {
+			return nil, fmt.Errorf("config key %q cannot be changed at runtime", key)
+		}
+		if err := setPath(tree, strings.Split(key, "."), set[key]); err != nil {
+			return nil, fmt.Errorf("failed to set %q: %w", key, err)
+		}
+	}
+
+	patched, err := yaml.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal patched config: %w", err)
+	}
+
+	result := &Config{}
+	if err := yaml.Unmarshal(patched, result); err != nil {
+		return nil, fmt.Errorf("invalid value in patch: %w", err)
+	}
+
+	if err := result.Validate(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// setPath 在嵌套map中按路径设置值
+func setPath(tree map[string]interface{}, path []string, value interface{}) error {
+	node := tree
+	for _, part := range path[:len(path)-1] {
+		child, ok := node[part]
+		if !ok || child == nil {
+			child = make(map[string]interface{})
+			node[part] = child
+		}
+		childMap, ok := child.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%q is not a section", part)
+		}
+		node = childMap
+	}
+	node[path[len(path)-1]] = value
+	return nil
+}
+
+// LoadOverrides 从文件加载持久化的配置覆盖，文件不存在时返回nil
+func LoadOverrides(path string) (*Overrides, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read overrides file: %w", err)
+	}
+
+	var overrides Overrides
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse overrides file: %w", err)
+	}
+	return &overrides, nil
+}
+
+// SaveOverrides 原子写入配置覆盖文件
+func SaveOverrides(path string, overrides *Overrides) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(overrides)
+	if err != nil {
+		return fmt.Errorf("failed to marshal overrides: %w", err)
+	}
+
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to move temp file: %w", err)
+	}
+	return nil
+}
+
+// Merge 将补丁合并到已有覆盖中，返回新的覆盖对象
+func (o *Overrides) Merge(patch *ConfigPatch) *Overrides {
+	merged := &Overrides{
+		Version: patch.Version,
+		Set:     make(map[string]interface{}),
+	}
+	if o != nil {
+		for key, value := range o.Set {
+			merged.Set[key] = value
+		}
+	}
+	for key, value := range patch.Set {
+		merged.Set[key] = value
+	}
+	return merged
+}
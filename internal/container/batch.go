@@ -0,0 +1,144 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxBatchSize 单次批量创建的最大容器数
+const MaxBatchSize = 16
+
+// ErrInsufficientGPUs 批量创建所需的GPU总数超过节点可用数量
+var ErrInsufficientGPUs = errors.New("insufficient available GPUs")
+
+// BatchError 批量创建中某个容器创建失败，已创建的容器均已回滚
+type BatchError struct {
+	// 失败请求在批量中的下标
+	Index   int
+	ClaimID string
+	Err     error
+}
+
+// Error 实现error接口
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("containers[%d] (claim %s): %v", e.Index, e.ClaimID, e.Err)
+}
+
+// Unwrap 返回单个容器创建的错误
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateBatch 验证批量创建的全部请求，字段名带有请求下标，返回 *ValidationError
+func (m *Manager) ValidateBatch(reqs []*CreateRequest) error {
+	var fields []FieldError
+	if len(reqs) == 0 {
+		fields = append(fields, FieldError{Field: "containers", Message: "at least one container is required"})
+	}
+	if len(reqs) > MaxBatchSize {
+		fields = append(fields, FieldError{Field: "containers", Message: fmt.Sprintf("at most %d containers per batch", MaxBatchSize)})
+	}
+
+	claims := make(map[string]int)
+	ports := make(map[string]int)
+	now := time.Now().Unix()
+	for i, req := range reqs {
+		prefix := fmt.Sprintf("containers[%d].", i)
+		if err := m.ValidateCreateRequest(req); err != nil {
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				return err
+			}
+			for _, f := range validationErr.Fields {
+				fields = append(fields, FieldError{Field: prefix + f.Field, Message: f.Message})
+			}
+		}
+		for j, pm := range req.PortMappings {
+			if pm.HostPort == 0 {
+				continue
+			}
+			key := hostPortKey(pm)
+			if first, exists := ports[key]; exists {
+				fields = append(fields, FieldError{Field: fmt.Sprintf("%sport_mappings[%d].host_port", prefix, j), Message: fmt.Sprintf("host port %s duplicates containers[%d]", key, first)})
+			} else {
+				ports[key] = i
+			}
+		}
+		if first, exists := claims[req.ClaimID]; exists {
+			fields = append(fields, FieldError{Field: prefix + "claim_id", Message: fmt.Sprintf("duplicates containers[%d]", first)})
+		} else {
+			claims[req.ClaimID] = i
+		}
+		if req.StartAt > now {
+			fields = append(fields, FieldError{Field: prefix + "start_at", Message: "scheduled start is not supported in batch requests"})
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// CreateContainers 按顺序创建一组容器，任一容器创建失败时删除已创建的容器
+// 失败时返回 *BatchError，可用 errors.Is 判断单个容器创建的错误
+func (m *Manager) CreateContainers(ctx context.Context, reqs []*CreateRequest) ([]string, error) {
+	if err := m.ValidateBatch(reqs); err != nil {
+		return nil, err
+	}
+
+	// 独占GPU的总数在创建前整体检查，避免创建到一半才发现GPU不足
+	claims := make(map[string]bool, len(reqs))
+	needed := 0
+	for _, req := range reqs {
+		claims[req.ClaimID] = true
+		if !req.SharesGPU() {
+			needed += req.GPUCount
+		}
+	}
+	if needed > 0 {
+		free := len(m.withoutSharedGPUs(m.gpuMonitor.GetAvailableGPUs(), m.timeSlicedGPUs())) - m.reservedGPUsExcept(claims)
+		if free < needed {
+			return nil, fmt.Errorf("%w: need %d, only %d available", ErrInsufficientGPUs, needed, max(free, 0))
+		}
+	}
+
+	// 主机端口冲突与命令策略在创建前整体检查
+	for i, req := range reqs {
+		if err := m.checkPortConflicts(req); err != nil {
+			return nil, &BatchError{Index: i, ClaimID: req.ClaimID, Err: err}
+		}
+		if err := m.checkCommandPolicy(ctx, req); err != nil {
+			return nil, &BatchError{Index: i, ClaimID: req.ClaimID, Err: err}
+		}
+	}
+
+	// 批量中已创建的容器在崩溃后整体回滚
+	claimIDs := make([]string, len(reqs))
+	for i, req := range reqs {
+		claimIDs[i] = req.ClaimID
+	}
+	defer m.beginIntent(OpCreateBatch, claimIDs, "")()
+
+	containerIDs := make([]string, 0, len(reqs))
+	for i, req := range reqs {
+		containerID, err := m.CreateContainer(ctx, req)
+		if err != nil {
+			m.rollbackBatch(containerIDs)
+			return nil, &BatchError{Index: i, ClaimID: req.ClaimID, Err: err}
+		}
+		containerIDs = append(containerIDs, containerID)
+	}
+	return containerIDs, nil
+}
+
+// rollbackBatch 按创建的逆序删除批量中已创建的容器
+func (m *Manager) rollbackBatch(containerIDs []string) {
+	for i := len(containerIDs) - 1; i >= 0; i-- {
+		if err := m.RemoveContainer(context.Background(), containerIDs[i]); err != nil {
+			fmt.Printf("Warning: failed to roll back container %s of batch: %v\n", containerIDs[i], err)
+		}
+	}
+}
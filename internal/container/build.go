@@ -0,0 +1,451 @@
+package container
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"utopia-node-agent/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrBuildDisabled 节点未启用镜像构建
+	ErrBuildDisabled = errors.New("image build is not enabled")
+	// ErrBuildBusy 已有镜像构建在执行
+	ErrBuildBusy = errors.New("an image build is already running")
+)
+
+// builderName 带资源限制的buildx构建器（docker-container驱动）
+const builderName = "utopia-build"
+
+// buildClaimLabel 记录构建镜像所属claim的标签
+// 不使用 utopia.claim_id，以免由该镜像启动的容器被误认为受管容器
+const buildClaimLabel = "utopia.build.claim_id"
+
+// 构建请求的上限
+const (
+	maxBuildArgs       = 64
+	maxDockerfileBytes = 1 << 20
+)
+
+// imageTagPattern 镜像标签
+var imageTagPattern = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+
+// BuildPolicy 镜像构建的开关与资源限制
+type BuildPolicy struct {
+	Enabled bool
+	// 构建器容器的CPU核数与内存（MB）上限，0表示不限制
+	CPUs     float64
+	MemoryMB int
+	// 单次构建的时限
+	Timeout time.Duration
+	// 构建上下文解压后的大小上限
+	MaxContextBytes int64
+	// 允许的git仓库主机，为空时允许任意https地址
+	AllowedGitHosts []string
+}
+
+// BuildRequest 镜像构建请求，构建上下文为GitURL或Context之一
+type BuildRequest struct {
+	ClaimID string
+	// 镜像标签，为空时使用当前时间
+	Tag string
+	// https git仓库地址，可带 #<ref>:<子目录>
+	GitURL string
+	// 构建上下文tar（可gzip压缩）
+	Context io.Reader
+	// Dockerfile内容，为空时使用上下文中的DockerfilePath
+	Dockerfile string
+	// 上下文中Dockerfile的相对路径，默认 Dockerfile
+	DockerfilePath string
+	BuildArgs      map[string]string
+}
+
+// BuildResult 镜像构建结果
+type BuildResult struct {
+	Image   string `json:"image"`
+	ImageID string `json:"image_id"`
+}
+
+// BuildPolicy 返回镜像构建的开关与资源限制
+func (m *Manager) BuildPolicy() BuildPolicy {
+	return m.getOptions().Build
+}
+
+// validate 验证构建请求，返回 *ValidationError
+func (r *BuildRequest) validate(policy BuildPolicy) error {
+	var fields []FieldError
+	add := func(field, format string, args ...interface{}) {
+		fields = append(fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if !claimIDPattern.MatchString(r.ClaimID) {
+		add("claim_id", "must be 1-128 characters of letters, digits, '_', '.' or '-', starting with a letter or digit")
+	}
+	if r.Tag != "" && !imageTagPattern.MatchString(r.Tag) {
+		add("tag", "must be a valid image tag")
+	}
+
+	switch {
+	case (r.GitURL == "") == (r.Context == nil):
+		add("context", "exactly one of a context tarball and git_url is required")
+	case r.GitURL != "":
+		if err := validateGitURL(r.GitURL, policy.AllowedGitHosts); err != nil {
+			add("git_url", "%v", err)
+		}
+	}
+
+	if len(r.Dockerfile) > maxDockerfileBytes {
+		add("dockerfile", "must be at most %d bytes", maxDockerfileBytes)
+	}
+	if p := r.DockerfilePath; p != "" {
+		if r.Dockerfile != "" {
+			add("dockerfile_path", "cannot be combined with an inline dockerfile")
+		} else if len(p) > maxPathLength || path.IsAbs(p) || path.Clean(p) != p || p == ".." || strings.HasPrefix(p, "../") {
+			add("dockerfile_path", "must be a clean relative path inside the build context")
+		}
+	}
+
+	if len(r.BuildArgs) > maxBuildArgs {
+		add("build_args", "at most %d build args are allowed", maxBuildArgs)
+	}
+	for name := range r.BuildArgs {
+		if !envNamePattern.MatchString(name) {
+			add("build_args", "invalid build arg name %q", name)
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// validateGitURL 只允许https仓库地址，并按配置限制主机
+func validateGitURL(raw string, allowedHosts []string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("must be an https URL")
+	}
+	if u.User != nil {
+		return fmt.Errorf("must not contain credentials")
+	}
+	if len(allowedHosts) == 0 {
+		return nil
+	}
+	for _, host := range allowedHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %s is not allowed", u.Hostname())
+}
+
+// BuildImage 使用带资源限制的buildkit构建器构建镜像，并以claim的默认镜像引用加载到本地
+// 构建输出逐行传给output，同一时间只执行一个构建
+func (m *Manager) BuildImage(ctx context.Context, req BuildRequest, output func(line string)) (result BuildResult, err error) {
+	policy := m.getOptions().Build
+	if !policy.Enabled {
+		return result, ErrBuildDisabled
+	}
+	if err := req.validate(policy); err != nil {
+		return result, err
+	}
+
+	m.buildMu.Lock()
+	if m.building {
+		m.buildMu.Unlock()
+		return result, ErrBuildBusy
+	}
+	m.building = true
+	m.buildMu.Unlock()
+	defer func() {
+		m.buildMu.Lock()
+		m.building = false
+		m.buildMu.Unlock()
+	}()
+
+	tag := req.Tag
+	if tag == "" {
+		tag = time.Now().UTC().Format("20060102-150405")
+	}
+	result.Image = m.DefaultImageReference(req.ClaimID, tag)
+
+	ctx, span := tracing.Start(ctx, "container.BuildImage",
+		attribute.String("utopia.claim_id", req.ClaimID),
+		attribute.String("container.image", result.Image),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		defer cancel()
+	}
+
+	buildContext := req.GitURL
+	if req.Context != nil {
+		dir, err := os.MkdirTemp("", "utopia-build-")
+		if err != nil {
+			return result, fmt.Errorf("failed to create build context dir: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		if err := extractBuildContext(req.Context, dir, policy.MaxContextBytes); err != nil {
+			return result, &ValidationError{Fields: []FieldError{{Field: "context", Message: err.Error()}}}
+		}
+		// buildx在主机上读取Dockerfile，不能经符号链接读取上下文之外的文件
+		if req.Dockerfile == "" {
+			dockerfile := req.DockerfilePath
+			if dockerfile == "" {
+				dockerfile = "Dockerfile"
+			}
+			if err := checkContextFile(dir, dockerfile); err != nil {
+				return result, &ValidationError{Fields: []FieldError{{Field: "dockerfile_path", Message: err.Error()}}}
+			}
+		}
+		buildContext = dir
+	}
+
+	if err := m.ensureBuilder(ctx, policy); err != nil {
+		return result, err
+	}
+
+	args := []string{"buildx", "build",
+		"--builder", builderName,
+		"--progress", "plain",
+		"--load",
+		"--tag", result.Image,
+		"--label", fmt.Sprintf("%s=%s", buildClaimLabel, req.ClaimID),
+	}
+	names := make([]string, 0, len(req.BuildArgs))
+	for name := range req.BuildArgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "--build-arg", name+"="+req.BuildArgs[name])
+	}
+	switch {
+	case req.Dockerfile != "":
+		args = append(args, "--file", "-")
+	case req.DockerfilePath != "" && req.Context != nil:
+		args = append(args, "--file", filepath.Join(buildContext, req.DockerfilePath))
+	case req.DockerfilePath != "":
+		args = append(args, "--file", req.DockerfilePath)
+	}
+	args = append(args, buildContext)
+
+	cmd := dockerCommand(ctx, args...)
+	if req.Dockerfile != "" {
+		cmd.Stdin = strings.NewReader(req.Dockerfile)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return result, fmt.Errorf("failed to capture build output: %w", err)
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return result, fmt.Errorf("failed to start build: %w", err)
+	}
+
+	var last string
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		last = scanner.Text()
+		if output != nil {
+			output(last)
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return result, fmt.Errorf("build timed out after %s", policy.Timeout)
+		}
+		return result, fmt.Errorf("build failed: %w: %s", err, strings.TrimSpace(last))
+	}
+
+	inspect, err := dockerCommand(ctx, "image", "inspect", "--format", "{{.Id}}", result.Image).Output()
+	if err != nil {
+		return result, fmt.Errorf("failed to inspect built image: %w", err)
+	}
+	result.ImageID = strings.TrimSpace(string(inspect))
+	tracing.Logger(ctx).Infof("Built image %s for claim %s", result.Image, req.ClaimID)
+	return result, nil
+}
+
+// ensureBuilder 创建带资源限制的buildx构建器
+// 本进程首次构建或资源限制变化时重建，保留构建缓存
+func (m *Manager) ensureBuilder(ctx context.Context, policy BuildPolicy) error {
+	key := fmt.Sprintf("cpus=%g,memory=%d", policy.CPUs, policy.MemoryMB)
+	if m.builderKey == key {
+		return nil
+	}
+
+	// 构建器可能不存在
+	dockerCommand(ctx, "buildx", "rm", "--keep-state", builderName).Run()
+
+	args := []string{"buildx", "create", "--name", builderName, "--driver", "docker-container"}
+	if policy.MemoryMB > 0 {
+		args = append(args, "--driver-opt", fmt.Sprintf("memory=%dm", policy.MemoryMB))
+	}
+	if policy.CPUs > 0 {
+		args = append(args,
+			"--driver-opt", "cpu-period=100000",
+			"--driver-opt", fmt.Sprintf("cpu-quota=%d", int64(policy.CPUs*100000)),
+		)
+	}
+	if output, err := dockerCommand(ctx, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create buildx builder: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	m.builderKey = key
+	return nil
+}
+
+// extractBuildContext 将构建上下文tar（可gzip压缩）解压到dir
+// 拒绝越出dir的路径与硬链接，符号链接最后创建，避免写入文件时经过符号链接
+func extractBuildContext(r io.Reader, dir string, maxBytes int64) error {
+	br := bufio.NewReader(r)
+	var source io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("invalid gzip archive: %w", err)
+		}
+		defer gz.Close()
+		source = gz
+	}
+
+	type symlink struct{ name, target string }
+	var links []symlink
+	var total int64
+	tr := tar.NewReader(source)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "/"))
+		if name == "." {
+			continue
+		}
+		if name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("archive entry %q escapes the build context", header.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			total += header.Size
+			if maxBytes > 0 && total > maxBytes {
+				return fmt.Errorf("build context exceeds %d MB", maxBytes>>20)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(header.Mode)&0755|0600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, tr)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return fmt.Errorf("failed to extract %s: %w", name, err)
+			}
+		case tar.TypeSymlink:
+			links = append(links, symlink{name: name, target: header.Linkname})
+		case tar.TypeLink:
+			return fmt.Errorf("archive entry %q: hard links are not supported", header.Name)
+		default:
+			// 设备文件等在构建上下文中没有意义
+		}
+	}
+
+	// 符号链接在普通文件之后创建，链接目标只能是上下文内的相对路径，
+	// 且链接所在目录不能经过已创建的符号链接，否则后续条目会写到上下文之外
+	for _, link := range links {
+		if path.IsAbs(link.target) || path.Clean(link.target) != link.target {
+			return fmt.Errorf("archive entry %q: symlink target %q must be a clean relative path", link.name, link.target)
+		}
+		if resolved := path.Join(path.Dir(link.name), link.target); resolved == ".." || strings.HasPrefix(resolved, "../") {
+			return fmt.Errorf("archive entry %q: symlink target %q escapes the build context", link.name, link.target)
+		}
+		if err := mkdirNoSymlinks(dir, path.Dir(link.name)); err != nil {
+			return fmt.Errorf("archive entry %q: %w", link.name, err)
+		}
+		if err := os.Symlink(link.target, filepath.Join(dir, filepath.FromSlash(link.name))); err != nil {
+			return fmt.Errorf("failed to create symlink %s: %w", link.name, err)
+		}
+	}
+	return nil
+}
+
+// mkdirNoSymlinks 在dir下逐级创建相对路径rel的目录，任一级为符号链接或非目录时返回错误
+func mkdirNoSymlinks(dir, rel string) error {
+	current := dir
+	if rel == "." {
+		return nil
+	}
+	for _, part := range strings.Split(rel, "/") {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		switch {
+		case os.IsNotExist(err):
+			if err := os.Mkdir(current, 0755); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case info.Mode()&os.ModeSymlink != 0:
+			return fmt.Errorf("path %s passes through a symlink", rel)
+		case !info.IsDir():
+			return fmt.Errorf("path %s passes through a file", rel)
+		}
+	}
+	return nil
+}
+
+// checkContextFile 检查上下文中的文件rel是普通文件，且路径不经过符号链接
+func checkContextFile(dir, rel string) error {
+	current := dir
+	for _, part := range strings.Split(rel, "/") {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("%s not found in the build context", rel)
+			}
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s must not be or pass through a symlink", rel)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"utopia-node-agent/internal/gpu"
+
+	"golang.org/x/sys/unix"
+)
+
+// 容量汇总中磁盘路径的用途
+const (
+	DiskPurposeDockerRoot = "docker_root"
+	DiskPurposeVolumeRoot = "volume_root"
+)
+
+// GPUCapacity GPU的调度容量
+type GPUCapacity struct {
+	Total int `json:"total"`
+	// 可分配给新的独占容器的GPU数：空闲GPU扣除为定时启动预留的数量
+	Free int `json:"free"`
+	// 空闲的GPU（未被占用、未运行MPS），其中Reserved个为定时启动预留
+	IdleIDs []int `json:"idle_ids"`
+	// 为即将定时启动的claim预留的GPU数
+	Reserved int `json:"reserved"`
+	// 被受管容器占用的GPU数（含保留GPU休眠的claim）
+	Allocated int `json:"allocated"`
+	// 运行MPS守护进程、只接受共享计算请求的GPU
+	SharedIDs []int `json:"shared_ids,omitempty"`
+	// 被其他进程占用或等待清理校验、暂不可分配的GPU数
+	Unavailable int `json:"unavailable"`
+	// 时间片共享的份额与余量，未启用时为空
+	TimeSlicing *TimeSlicingStatus `json:"time_slicing,omitempty"`
+}
+
+// ResourceCapacity 一类资源的承诺状态及还可承诺给新容器的余量
+type ResourceCapacity struct {
+	Commitment
+	// 按超分上限计，不限制时按物理容量扣除保留量计
+	Available float64 `json:"available"`
+}
+
+// DiskCapacity 一个路径所在文件系统的空间（字节）
+type DiskCapacity struct {
+	Path string `json:"path"`
+	// docker_root（容器可写层与镜像）或 volume_root（可挂载到容器的主机目录）
+	Purpose string `json:"purpose"`
+	Total   uint64 `json:"total"`
+	Free    uint64 `json:"free"`
+	// 为系统保留的空间（仅Docker数据目录）及扣除后的余量
+	Reserved  uint64 `json:"reserved,omitempty"`
+	Available uint64 `json:"available"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Capacity 节点资源的调度容量汇总
+type Capacity struct {
+	GPUs   GPUCapacity      `json:"gpus"`
+	CPU    ResourceCapacity `json:"cpu"`
+	Memory ResourceCapacity `json:"memory"`
+	Disks  []DiskCapacity   `json:"disks"`
+	// 运行中与全部受管容器数
+	RunningContainers int `json:"running_containers"`
+	Containers        int `json:"containers"`
+}
+
+// Capacity 汇总GPU、CPU、内存与磁盘的调度容量，gpus为当前GPU状态
+func (m *Manager) Capacity(ctx context.Context, gpus []gpu.GPUInfo) Capacity {
+	status := m.OvercommitStatus(ctx)
+	capacity := Capacity{
+		GPUs:   m.gpuCapacity(gpus),
+		CPU:    newResourceCapacity(status.CPU),
+		Memory: newResourceCapacity(status.Memory),
+	}
+
+	options := m.getOptions()
+	if root, err := m.dockerRootDir(ctx); err != nil {
+		capacity.Disks = append(capacity.Disks, DiskCapacity{Purpose: DiskPurposeDockerRoot, Error: err.Error()})
+	} else {
+		capacity.Disks = append(capacity.Disks, diskCapacity(root, DiskPurposeDockerRoot, options.SystemReserved.DiskBytes))
+	}
+	for _, root := range options.AllowedVolumeRoots {
+		capacity.Disks = append(capacity.Disks, diskCapacity(root, DiskPurposeVolumeRoot, 0))
+	}
+
+	for _, info := range m.ListContainers() {
+		capacity.Containers++
+		if strings.EqualFold(info.Status, "running") {
+			capacity.RunningContainers++
+		}
+	}
+	return capacity
+}
+
+// gpuCapacity 按GPU状态、MPS与定时启动预留统计GPU容量
+func (m *Manager) gpuCapacity(gpus []gpu.GPUInfo) GPUCapacity {
+	capacity := GPUCapacity{Total: len(gpus), IdleIDs: []int{}, SharedIDs: m.MPSGPUs()}
+	// 驱动维护期间没有可分配的GPU
+	available := m.gpuMonitor.GetAvailableGPUs()
+	for _, g := range gpus {
+		switch {
+		case slices.Contains(capacity.SharedIDs, g.ID):
+		case g.BusyBy == gpu.BusyByManagedContainer || m.IsGPUInUse(g.ID):
+			capacity.Allocated++
+		case g.Busy || !slices.Contains(available, g.ID):
+			capacity.Unavailable++
+		default:
+			capacity.IdleIDs = append(capacity.IdleIDs, g.ID)
+		}
+	}
+	capacity.Reserved = m.reservedGPUsExcept(nil)
+	capacity.Free = max(len(capacity.IdleIDs)-capacity.Reserved, 0)
+	capacity.TimeSlicing = m.TimeSlicingStatus()
+	return capacity
+}
+
+// newResourceCapacity 根据承诺状态计算余量
+func newResourceCapacity(c Commitment) ResourceCapacity {
+	available := c.Physical - c.Reserved - c.Committed
+	if c.limited() {
+		available = c.Limit - c.Committed
+	}
+	return ResourceCapacity{Commitment: c, Available: max(available, 0)}
+}
+
+// diskCapacity 查询路径所在文件系统的空间
+func diskCapacity(path, purpose string, reserved uint64) DiskCapacity {
+	disk := DiskCapacity{Path: path, Purpose: purpose, Reserved: reserved}
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		disk.Error = fmt.Sprintf("failed to stat %s: %v", path, err)
+		return disk
+	}
+	disk.Total = stat.Blocks * uint64(stat.Bsize)
+	disk.Free = stat.Bavail * uint64(stat.Bsize)
+	if disk.Free > reserved {
+		disk.Available = disk.Free - reserved
+	}
+	return disk
+}
@@ -0,0 +1,47 @@
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"utopia-node-agent/internal/features"
+)
+
+// cdiGPUKind NVIDIA Container Toolkit 生成的CDI设备类别
+const cdiGPUKind = "nvidia.com/gpu"
+
+// SetFeatureFlags 设置运行时功能开关
+func (m *Manager) SetFeatureFlags(flags *features.Flags) {
+	m.mu.Lock()
+	m.features = flags
+	m.mu.Unlock()
+}
+
+// featureEnabled 返回功能开关是否开启，未设置开关时使用默认值
+func (m *Manager) featureEnabled(name string) bool {
+	m.mu.RLock()
+	flags := m.features
+	m.mu.RUnlock()
+	return flags.Enabled(name)
+}
+
+// gpuDeviceArgs 返回为容器分配GPU的docker参数
+// 开启cdi_mode时以CDI设备名分配（需要docker 25+启用CDI并已生成 nvidia.com/gpu 规格），否则使用 --gpus
+func (m *Manager) gpuDeviceArgs(gpuIDs []int) []string {
+	if len(gpuIDs) == 0 {
+		return nil
+	}
+	if m.featureEnabled(features.CDIMode) {
+		args := make([]string, 0, 2*len(gpuIDs))
+		for _, id := range gpuIDs {
+			args = append(args, "--device", fmt.Sprintf("%s=%d", cdiGPUKind, id))
+		}
+		return args
+	}
+	gpuList := make([]string, len(gpuIDs))
+	for i, id := range gpuIDs {
+		gpuList[i] = strconv.Itoa(id)
+	}
+	return []string{"--gpus", fmt.Sprintf("\"device=%s\"", strings.Join(gpuList, ","))}
+}
@@ -0,0 +1,146 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/tracing"
+)
+
+// ErrCommandNotAllowed 容器命令不符合节点的命令策略
+var ErrCommandNotAllowed = errors.New("container command is not allowed by node policy")
+
+// EventCommandDenied 创建请求因命令策略被拒绝，供平台处理滥用
+const EventCommandDenied = "container.command_denied"
+
+// maxReportedCommandLength 事件中记录的命令最大长度
+const maxReportedCommandLength = 1024
+
+// CommandPolicy 容器命令策略：命令行匹配任一拒绝模式时拒绝；
+// 设置了允许模式时只接受匹配其中之一的命令
+type CommandPolicy struct {
+	Deny  []*regexp.Regexp
+	Allow []*regexp.Regexp
+}
+
+// NewCommandPolicy 编译命令策略的正则表达式
+func NewCommandPolicy(deny, allow []string) (CommandPolicy, error) {
+	var policy CommandPolicy
+	for _, pattern := range deny {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return CommandPolicy{}, fmt.Errorf("invalid deny pattern %q: %w", pattern, err)
+		}
+		policy.Deny = append(policy.Deny, re)
+	}
+	for _, pattern := range allow {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return CommandPolicy{}, fmt.Errorf("invalid allow pattern %q: %w", pattern, err)
+		}
+		policy.Allow = append(policy.Allow, re)
+	}
+	return policy, nil
+}
+
+// enabled 是否配置了命令策略
+func (p CommandPolicy) enabled() bool {
+	return len(p.Deny) > 0 || len(p.Allow) > 0
+}
+
+// check 检查命令行，返回违反的规则与原因，符合策略时原因为空
+// known为false表示镜像不在本地且请求未指定命令，无法确定实际执行的命令
+func (p CommandPolicy) check(command string, known bool) (rule, reason string) {
+	for _, re := range p.Deny {
+		if re.MatchString(command) {
+			return re.String(), fmt.Sprintf("command matches deny pattern %q", re.String())
+		}
+	}
+	if len(p.Allow) == 0 {
+		return "", ""
+	}
+	if !known {
+		return "", "command must be specified when the image is not present on the node"
+	}
+	for _, re := range p.Allow {
+		if re.MatchString(command) {
+			return "", ""
+		}
+	}
+	return "", "command does not match any allow pattern"
+}
+
+// checkCommandPolicy 按节点命令策略检查容器的入口点与命令，违反时记录日志并发布事件
+func (m *Manager) checkCommandPolicy(ctx context.Context, req *CreateRequest) error {
+	policy := m.getOptions().Command
+	if !policy.enabled() {
+		return nil
+	}
+
+	command, known := m.effectiveCommand(ctx, req)
+	rule, reason := policy.check(command, known)
+	if reason == "" {
+		return nil
+	}
+
+	reported := m.getRedactor().Text(command)
+	if len(reported) > maxReportedCommandLength {
+		reported = reported[:maxReportedCommandLength]
+	}
+	tracing.Logger(ctx).Warnf("Rejected claim %s: %s (image %s, command %q)", req.ClaimID, reason, req.Image, reported)
+
+	m.mu.RLock()
+	bus := m.events
+	m.mu.RUnlock()
+	if bus != nil {
+		data := map[string]interface{}{
+			"image":   req.Image,
+			"command": reported,
+			"reason":  reason,
+		}
+		if rule != "" {
+			data["pattern"] = rule
+		}
+		if req.Tenant != "" {
+			data["tenant"] = req.Tenant
+		}
+		bus.Publish(events.Event{
+			Type:     EventCommandDenied,
+			Severity: events.SeverityWarning,
+			ClaimID:  req.ClaimID,
+			Message:  fmt.Sprintf("container command rejected by node policy: %s", reason),
+			Data:     data,
+		})
+	}
+	return fmt.Errorf("%w: %s", ErrCommandNotAllowed, reason)
+}
+
+// imageCommandConfig 镜像配置中的入口点与默认命令
+type imageCommandConfig struct {
+	Entrypoint []string `json:"Entrypoint"`
+	Cmd        []string `json:"Cmd"`
+}
+
+// effectiveCommand 返回容器实际执行的命令行：镜像入口点加上请求的命令（未指定时为镜像默认命令）
+// 镜像不在本地时无法获取入口点，只检查请求的命令
+func (m *Manager) effectiveCommand(ctx context.Context, req *CreateRequest) (string, bool) {
+	var config imageCommandConfig
+	known := false
+	if output, err := dockerCommand(ctx, "image", "inspect", "--format", "{{json .Config}}", req.Image).Output(); err == nil {
+		known = json.Unmarshal(output, &config) == nil
+	}
+
+	args := append([]string{}, config.Entrypoint...)
+	if len(req.Command) > 0 {
+		args = append(args, req.Command...)
+		known = true
+	} else {
+		args = append(args, config.Cmd...)
+	}
+	return strings.Join(args, " "), known
+}
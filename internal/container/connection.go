@@ -0,0 +1,109 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 镜像或容器可通过标签声明连接信息
+const (
+	// SSHUserLabel SSH登录用户，未设置时依次使用环境变量 SSH_USER、镜像的 USER，默认为 root
+	SSHUserLabel = "utopia.ssh_user"
+	// WorkspaceLabel 远程开发打开的目录，未设置时使用容器的工作目录
+	WorkspaceLabel = "utopia.workspace"
+)
+
+// jupyterTokenArgPattern 命令行中的Jupyter令牌参数，如 --ServerApp.token=abc 或 --NotebookApp.token abc
+var jupyterTokenArgPattern = regexp.MustCompile(`^--(?:ServerApp|NotebookApp|IdentityProvider)\.token(?:=(.*))?$`)
+
+// ContainerAccess 从容器配置与注入的凭据中得到的登录信息
+type ContainerAccess struct {
+	SSHUser string
+	// Jupyter访问令牌，来自环境变量 JUPYTER_TOKEN（含以环境变量注入的密钥）或启动参数
+	JupyterToken string
+	Workspace    string
+}
+
+// containerAccessConfig docker inspect 中与登录相关的容器配置
+type containerAccessConfig struct {
+	User       string            `json:"User"`
+	Env        []string          `json:"Env"`
+	Entrypoint []string          `json:"Entrypoint"`
+	Cmd        []string          `json:"Cmd"`
+	WorkingDir string            `json:"WorkingDir"`
+	Labels     map[string]string `json:"Labels"`
+}
+
+// ContainerAccess 读取容器的SSH用户、Jupyter令牌与工作目录
+func (m *Manager) ContainerAccess(ctx context.Context, containerID string) (ContainerAccess, error) {
+	output, err := dockerCommand(ctx, "inspect", "--format", "{{json .Config}}", containerID).Output()
+	if err != nil {
+		return ContainerAccess{}, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	var config containerAccessConfig
+	if err := json.Unmarshal(output, &config); err != nil {
+		return ContainerAccess{}, fmt.Errorf("failed to parse container config: %w", err)
+	}
+
+	env := make(map[string]string, len(config.Env))
+	for _, entry := range config.Env {
+		if name, value, ok := strings.Cut(entry, "="); ok {
+			env[name] = value
+		}
+	}
+
+	access := ContainerAccess{
+		SSHUser:      config.Labels[SSHUserLabel],
+		JupyterToken: env["JUPYTER_TOKEN"],
+		Workspace:    config.Labels[WorkspaceLabel],
+	}
+	if access.SSHUser == "" {
+		access.SSHUser = env["SSH_USER"]
+	}
+	if access.SSHUser == "" {
+		// USER 可以是 name、name:group 或数字UID，数字UID无法作为登录名
+		name, _, _ := strings.Cut(config.User, ":")
+		if name != "" && strings.Trim(name, "0123456789") != "" {
+			access.SSHUser = name
+		} else {
+			access.SSHUser = "root"
+		}
+	}
+	if token := jupyterTokenArg(append(append([]string{}, config.Entrypoint...), config.Cmd...)); token != "" {
+		access.JupyterToken = token
+	}
+	if access.Workspace == "" {
+		access.Workspace = config.WorkingDir
+	}
+	if access.Workspace == "" {
+		access.Workspace = "/"
+	}
+	return access, nil
+}
+
+// jupyterTokenArg 查找启动参数中的Jupyter令牌
+func jupyterTokenArg(args []string) string {
+	for i, arg := range args {
+		// 以shell启动时令牌参数可能与其他参数写在同一个字符串中
+		for j, field := range strings.Fields(arg) {
+			match := jupyterTokenArgPattern.FindStringSubmatch(field)
+			if match == nil {
+				continue
+			}
+			if strings.Contains(field, "=") {
+				return strings.Trim(match[1], `'"`)
+			}
+			fields := strings.Fields(arg)
+			if j+1 < len(fields) {
+				return strings.Trim(fields[j+1], `'"`)
+			}
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,248 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ForeignContainer 可访问GPU的非受管容器
+type ForeignContainer struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	// 容器可访问的GPU，AllGPUs为true时可访问全部GPU
+	GPUIDs  []int `json:"gpu_ids,omitempty"`
+	AllGPUs bool  `json:"all_gpus,omitempty"`
+}
+
+// UsesGPU 容器是否可访问指定GPU
+func (f ForeignContainer) UsesGPU(gpuID int) bool {
+	if f.AllGPUs {
+		return true
+	}
+	for _, id := range f.GPUIDs {
+		if id == gpuID {
+			return true
+		}
+	}
+	return false
+}
+
+// foreignInspect docker inspect 中与GPU访问有关的字段
+type foreignInspect struct {
+	ID     string `json:"Id"`
+	Name   string `json:"Name"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+		Env    []string          `json:"Env"`
+	} `json:"Config"`
+	HostConfig struct {
+		Runtime        string `json:"Runtime"`
+		DeviceRequests []struct {
+			Driver       string     `json:"Driver"`
+			Count        int        `json:"Count"`
+			DeviceIDs    []string   `json:"DeviceIDs"`
+			Capabilities [][]string `json:"Capabilities"`
+		} `json:"DeviceRequests"`
+		Devices []struct {
+			PathOnHost string `json:"PathOnHost"`
+		} `json:"Devices"`
+	} `json:"HostConfig"`
+}
+
+// GPUReservations 返回每个GPU的预留claim：运行中的受管容器与保留GPU的休眠claim
+// 共享计算的GPU可能被多个claim预留
+func (m *Manager) GPUReservations() map[int][]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reservations := make(map[int][]string)
+	add := func(gpuID int, claimID string) {
+		for _, existing := range reservations[gpuID] {
+			if existing == claimID {
+				return
+			}
+		}
+		reservations[gpuID] = append(reservations[gpuID], claimID)
+	}
+	for _, info := range m.containers {
+		if info.Status != "running" {
+			continue
+		}
+		for _, id := range info.GPUIDs {
+			add(id, info.ClaimID)
+		}
+	}
+	if m.hibernations != nil {
+		for _, record := range m.hibernations.records {
+			if !record.KeepGPUs {
+				continue
+			}
+			for _, id := range record.GPUIDs {
+				add(id, record.ClaimID)
+			}
+		}
+	}
+	for _, claims := range reservations {
+		sort.Strings(claims)
+	}
+	return reservations
+}
+
+// ForeignGPUContainers 列出运行中且配置了GPU访问的非受管容器
+// gpuUUIDs 为GPU UUID到序号的映射，用于解析按UUID指定的设备
+func (m *Manager) ForeignGPUContainers(ctx context.Context, gpuUUIDs map[string]int) ([]ForeignContainer, error) {
+	output, err := dockerCommand(ctx, "ps", "--no-trunc", "--format", "{{.ID}}").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	ids := strings.Fields(string(output))
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	output, err = dockerCommand(ctx, append([]string{"inspect"}, ids...)...).Output()
+	if err != nil && len(output) == 0 {
+		// 列出后退出的容器会导致inspect失败，但仍输出其余容器的信息
+		return nil, fmt.Errorf("failed to inspect containers: %w", err)
+	}
+	var containers []foreignInspect
+	if err := json.Unmarshal(output, &containers); err != nil {
+		return nil, fmt.Errorf("failed to parse container info: %w", err)
+	}
+
+	var result []ForeignContainer
+	for _, c := range containers {
+		if c.Config.Labels["utopia.managed"] == "true" {
+			continue
+		}
+		gpuIDs, all := c.gpuAccess(gpuUUIDs)
+		if !all && len(gpuIDs) == 0 {
+			continue
+		}
+		result = append(result, ForeignContainer{
+			ID:      c.ID,
+			Name:    strings.TrimPrefix(c.Name, "/"),
+			Image:   c.Config.Image,
+			GPUIDs:  gpuIDs,
+			AllGPUs: all,
+		})
+	}
+	return result, nil
+}
+
+// gpuAccess 解析容器可访问的GPU：--gpus、CDI设备、/dev/nvidiaN 设备与nvidia运行时的 NVIDIA_VISIBLE_DEVICES
+func (c foreignInspect) gpuAccess(gpuUUIDs map[string]int) (gpuIDs []int, all bool) {
+	seen := make(map[int]bool)
+	addDevice := func(device string) {
+		if device == "all" {
+			all = true
+			return
+		}
+		id, err := strconv.Atoi(device)
+		if err != nil {
+			var known bool
+			if id, known = gpuUUIDs[device]; !known {
+				return
+			}
+		}
+		if !seen[id] {
+			seen[id] = true
+			gpuIDs = append(gpuIDs, id)
+		}
+	}
+
+	for _, req := range c.HostConfig.DeviceRequests {
+		if req.Driver == "cdi" {
+			for _, device := range req.DeviceIDs {
+				if kind, name, ok := strings.Cut(device, "="); ok && kind == cdiGPUKind {
+					addDevice(name)
+				}
+			}
+			continue
+		}
+		if !hasGPUCapability(req.Capabilities) && req.Driver != "nvidia" {
+			continue
+		}
+		switch {
+		case len(req.DeviceIDs) > 0:
+			for _, device := range req.DeviceIDs {
+				addDevice(device)
+			}
+		case req.Count < 0:
+			all = true
+		default:
+			// 只指定数量时nvidia运行时分配序号最小的GPU
+			for id := 0; id < req.Count; id++ {
+				addDevice(strconv.Itoa(id))
+			}
+		}
+	}
+
+	for _, device := range c.HostConfig.Devices {
+		if index := strings.TrimPrefix(device.PathOnHost, "/dev/nvidia"); index != device.PathOnHost {
+			addDevice(index)
+		}
+	}
+
+	if c.HostConfig.Runtime == "nvidia" {
+		for _, env := range c.Config.Env {
+			value, found := strings.CutPrefix(env, "NVIDIA_VISIBLE_DEVICES=")
+			if !found || value == "none" || value == "void" {
+				continue
+			}
+			for _, device := range strings.Split(value, ",") {
+				addDevice(strings.TrimSpace(device))
+			}
+		}
+	}
+
+	sort.Ints(gpuIDs)
+	return gpuIDs, all
+}
+
+// hasGPUCapability 设备请求是否包含gpu能力
+func hasGPUCapability(capabilities [][]string) bool {
+	for _, set := range capabilities {
+		for _, capability := range set {
+			if capability == "gpu" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DockerContainerIDForPID 根据进程所在的cgroup返回其所属docker容器的ID（包括非受管容器），不在容器中时为空
+func DockerContainerIDForPID(pid int) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return ""
+	}
+	match := dockerCgroupPattern.FindSubmatch(data)
+	if match == nil {
+		return ""
+	}
+	return string(match[1])
+}
+
+// StopForeignContainer 停止非受管容器，拒绝停止受管容器
+func (m *Manager) StopForeignContainer(ctx context.Context, containerID string) error {
+	output, err := dockerCommand(ctx, "inspect", "--format", `{{index .Config.Labels "utopia.managed"}}`, containerID).Output()
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if strings.TrimSpace(string(output)) == "true" {
+		return fmt.Errorf("container %s is managed by the agent", containerID)
+	}
+	if output, err := dockerCommand(ctx, "stop", "-t", "30", containerID).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to stop container: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
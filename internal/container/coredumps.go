@@ -0,0 +1,319 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"utopia-node-agent/internal/events"
+)
+
+// EventCoreDumped 容器进程崩溃并产生了核心转储
+const EventCoreDumped = "container.core_dumped"
+
+// corePatternPath 内核核心转储文件名模式
+const corePatternPath = "/proc/sys/kernel/core_pattern"
+
+// 核心转储的收集方式，由主机的 core_pattern 决定
+const (
+	// 绝对路径：转储写入容器内该目录，Agent将按claim隔离的主机目录挂载到该位置
+	CoreDumpModeDirectory = "directory"
+	// 相对路径：转储写入崩溃进程的工作目录（通常为工作区）
+	CoreDumpModeWorkingDir = "working_dir"
+	// 管道：转储交给主机上的处理程序（如 systemd-coredump），无法按容器收集
+	CoreDumpModePipe = "pipe"
+)
+
+// ErrCoreDumpNotFound 核心转储不存在
+var ErrCoreDumpNotFound = errors.New("core dump not found")
+
+// CoreDumpOptions 核心转储收集选项
+type CoreDumpOptions struct {
+	// 按容器存放转储的主机目录
+	Dir string
+	// 写入 core_pattern 的模式，为空时沿用主机设置
+	CorePattern string
+	// 单个转储的大小上限，作为容器的 core ulimit，超出部分被内核截断
+	MaxSizeBytes int64
+	// 每个容器保留的转储总量上限，超出时删除最旧的转储，0表示不限制
+	MaxTotalBytes int64
+}
+
+// CoreDumpStatus 核心转储收集的生效情况
+type CoreDumpStatus struct {
+	CorePattern string `json:"core_pattern"`
+	Mode        string `json:"mode"`
+	// 转储在容器内的写入目录（directory 方式）
+	ContainerDir string `json:"container_dir,omitempty"`
+	// Agent是否收集转储并可通过API下载
+	Collecting    bool   `json:"collecting"`
+	MaxSizeBytes  int64  `json:"max_size_bytes"`
+	MaxTotalBytes int64  `json:"max_total_bytes"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// CoreDump 收集到的核心转储文件
+type CoreDump struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	CreatedAt int64  `json:"created_at"`
+	// 大小达到上限，转储可能不完整
+	Truncated bool `json:"truncated"`
+}
+
+// coreDumps 核心转储收集状态
+type coreDumps struct {
+	options CoreDumpOptions
+	status  CoreDumpStatus
+	// 已发布事件的转储（主机路径），首次检查前为nil
+	seen map[string]bool
+}
+
+// EnableCoreDumps 启用核心转储收集：按需设置 core_pattern，并根据其形式确定收集方式
+// 容器的 core ulimit 限制为单个转储的大小上限；无法收集时仍限制转储大小
+func (m *Manager) EnableCoreDumps(options CoreDumpOptions) (CoreDumpStatus, error) {
+	if options.CorePattern != "" {
+		if err := os.WriteFile(corePatternPath, []byte(options.CorePattern+"\n"), 0644); err != nil {
+			return CoreDumpStatus{}, fmt.Errorf("failed to set core_pattern: %w", err)
+		}
+	}
+	data, err := os.ReadFile(corePatternPath)
+	if err != nil {
+		return CoreDumpStatus{}, fmt.Errorf("failed to read core_pattern: %w", err)
+	}
+
+	status := CoreDumpStatus{
+		CorePattern:   strings.TrimSpace(string(data)),
+		MaxSizeBytes:  options.MaxSizeBytes,
+		MaxTotalBytes: options.MaxTotalBytes,
+	}
+	pattern := status.CorePattern
+	switch {
+	case strings.HasPrefix(pattern, "|"):
+		status.Mode = CoreDumpModePipe
+		status.Reason = "core_pattern pipes dumps to a host handler"
+		if helper := strings.Fields(pattern[1:]); len(helper) > 0 {
+			status.Reason += " (" + helper[0] + ")"
+		}
+	case !path.IsAbs(pattern):
+		status.Mode = CoreDumpModeWorkingDir
+		status.Reason = "core_pattern is relative; dumps are written to the working directory of the crashing process"
+	default:
+		status.Mode = CoreDumpModeDirectory
+		dir := path.Dir(pattern)
+		if dir == "/" || strings.Contains(dir, "%") {
+			status.Reason = fmt.Sprintf("core_pattern directory %q cannot be mounted into containers", dir)
+			break
+		}
+		if err := os.MkdirAll(options.Dir, 0700); err != nil {
+			return CoreDumpStatus{}, fmt.Errorf("failed to create core dump directory: %w", err)
+		}
+		status.ContainerDir = dir
+		status.Collecting = true
+	}
+
+	m.mu.Lock()
+	m.coreDumps = &coreDumps{options: options, status: status}
+	m.mu.Unlock()
+	return status, nil
+}
+
+// CoreDumpStatus 返回核心转储收集的生效情况，未启用时返回nil
+func (m *Manager) CoreDumpStatus() *CoreDumpStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.coreDumps == nil {
+		return nil
+	}
+	status := m.coreDumps.status
+	return &status
+}
+
+// maxCoreBytes 容器 core ulimit 的上限，未启用时为0
+func (m *Manager) maxCoreBytes() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.coreDumps == nil {
+		return 0
+	}
+	return m.coreDumps.options.MaxSizeBytes
+}
+
+// coreDumpDir 返回容器的转储主机目录，不收集时返回空字符串
+func (m *Manager) coreDumpDir(containerName string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.coreDumps == nil || !m.coreDumps.status.Collecting {
+		return ""
+	}
+	return filepath.Join(m.coreDumps.options.Dir, containerName)
+}
+
+// prepareCoreDumps 创建容器的转储目录并挂载到 core_pattern 所在的容器目录
+func (m *Manager) prepareCoreDumps(containerName string) ([]string, error) {
+	dir := m.coreDumpDir(containerName)
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create core dump directory: %w", err)
+	}
+	// 转储以崩溃进程的用户写入，容器可能以非root用户运行
+	if err := os.Chmod(dir, 0777|os.ModeSticky); err != nil {
+		return nil, fmt.Errorf("failed to create core dump directory: %w", err)
+	}
+	m.mu.RLock()
+	containerDir := m.coreDumps.status.ContainerDir
+	m.mu.RUnlock()
+	return []string{"-v", fmt.Sprintf("%s:%s", dir, containerDir)}, nil
+}
+
+// cleanupCoreDumps 删除容器的转储目录
+func (m *Manager) cleanupCoreDumps(containerName string) {
+	dir := m.coreDumpDir(containerName)
+	if dir == "" {
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		fmt.Printf("Warning: failed to remove core dumps for %s: %v\n", containerName, err)
+	}
+}
+
+// ListCoreDumps 列出claim容器收集到的转储，按时间倒序
+func (m *Manager) ListCoreDumps(claimID string) ([]CoreDump, error) {
+	dir := m.coreDumpDir(containerNameFor(claimID))
+	if dir == "" {
+		return []CoreDump{}, nil
+	}
+	return m.listCoreDumpDir(dir)
+}
+
+// CoreDumpFile 返回claim容器的转储文件路径
+func (m *Manager) CoreDumpFile(claimID, name string) (string, error) {
+	dir := m.coreDumpDir(containerNameFor(claimID))
+	if dir == "" || name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", ErrCoreDumpNotFound
+	}
+	file := filepath.Join(dir, name)
+	if info, err := os.Lstat(file); err != nil || !info.Mode().IsRegular() {
+		return "", ErrCoreDumpNotFound
+	}
+	return file, nil
+}
+
+// listCoreDumpDir 列出目录中的转储文件（忽略符号链接等非普通文件）
+func (m *Manager) listCoreDumpDir(dir string) ([]CoreDump, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []CoreDump{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list core dumps: %w", err)
+	}
+	maxSize := m.maxCoreBytes()
+	dumps := make([]CoreDump, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		dumps = append(dumps, CoreDump{
+			Name:      entry.Name(),
+			SizeBytes: info.Size(),
+			CreatedAt: info.ModTime().Unix(),
+			Truncated: maxSize > 0 && info.Size() >= maxSize,
+		})
+	}
+	sort.Slice(dumps, func(i, j int) bool {
+		if dumps[i].CreatedAt != dumps[j].CreatedAt {
+			return dumps[i].CreatedAt > dumps[j].CreatedAt
+		}
+		return dumps[i].Name < dumps[j].Name
+	})
+	return dumps, nil
+}
+
+// CollectCoreDumps 检查各容器的转储目录：为新的转储发布事件，并按总量上限删除最旧的转储
+// 首次检查时已有的转储只记录不发布事件
+func (m *Manager) CollectCoreDumps() error {
+	m.mu.RLock()
+	state := m.coreDumps
+	m.mu.RUnlock()
+	if state == nil || !state.status.Collecting {
+		return nil
+	}
+
+	m.mu.Lock()
+	initial := state.seen == nil
+	if initial {
+		state.seen = make(map[string]bool)
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for _, info := range m.ListContainers() {
+		if info.ClaimID == "" {
+			continue
+		}
+		dir := filepath.Join(state.options.Dir, containerNameFor(info.ClaimID))
+		dumps, err := m.listCoreDumpDir(dir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// 从最新的转储开始累计，超出总量上限的较旧转储被删除
+		var total int64
+		for _, dump := range dumps {
+			file := filepath.Join(dir, dump.Name)
+			total += dump.SizeBytes
+			if state.options.MaxTotalBytes > 0 && total > state.options.MaxTotalBytes {
+				if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+					errs = append(errs, err)
+				}
+				m.mu.Lock()
+				delete(state.seen, file)
+				m.mu.Unlock()
+				continue
+			}
+
+			m.mu.Lock()
+			seen := state.seen[file]
+			state.seen[file] = true
+			m.mu.Unlock()
+			if !seen && !initial {
+				m.publishCoreDumpEvent(info, dump)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// publishCoreDumpEvent 发布核心转储事件
+func (m *Manager) publishCoreDumpEvent(info ContainerInfo, dump CoreDump) {
+	m.mu.RLock()
+	bus := m.events
+	m.mu.RUnlock()
+	if bus == nil {
+		return
+	}
+	bus.Publish(events.Event{
+		Type:        EventCoreDumped,
+		Severity:    events.SeverityWarning,
+		ContainerID: info.ID,
+		ClaimID:     info.ClaimID,
+		Message:     fmt.Sprintf("claim %s produced core dump %s", info.ClaimID, dump.Name),
+		Data: map[string]interface{}{
+			"name":       dump.Name,
+			"size_bytes": dump.SizeBytes,
+			"truncated":  dump.Truncated,
+		},
+	})
+}
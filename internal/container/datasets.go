@@ -0,0 +1,94 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"utopia-node-agent/internal/dataset"
+)
+
+var (
+	// ErrDatasetCacheDisabled 节点未启用数据集缓存
+	ErrDatasetCacheDisabled = errors.New("dataset cache is disabled on this node")
+	// ErrDatasetInUse 数据集正在被容器使用
+	ErrDatasetInUse = errors.New("dataset is in use")
+)
+
+// datasetsLabel 记录容器挂载的数据集缓存键
+const datasetsLabel = "utopia.datasets"
+
+// SetDatasetCache 启用共享数据集缓存
+func (m *Manager) SetDatasetCache(cache *dataset.Cache) {
+	m.mu.Lock()
+	m.datasets = cache
+	m.mu.Unlock()
+}
+
+// ListDatasets 列出缓存的数据集
+func (m *Manager) ListDatasets() ([]dataset.Entry, error) {
+	cache := m.datasetCache()
+	if cache == nil {
+		return nil, ErrDatasetCacheDisabled
+	}
+	return cache.List(), nil
+}
+
+// RemoveDataset 删除未被容器使用的缓存数据集
+func (m *Manager) RemoveDataset(key string) error {
+	cache := m.datasetCache()
+	if cache == nil {
+		return ErrDatasetCacheDisabled
+	}
+	if m.pinnedDatasets()[key] {
+		return fmt.Errorf("%w: %s", ErrDatasetInUse, key)
+	}
+	return cache.Remove(key)
+}
+
+// prepareDatasets 确保请求的数据集已缓存，返回只读挂载参数与缓存键
+func (m *Manager) prepareDatasets(ctx context.Context, refs []dataset.Ref) ([]string, []string, error) {
+	if len(refs) == 0 {
+		return nil, nil, nil
+	}
+	cache := m.datasetCache()
+	if cache == nil {
+		return nil, nil, ErrDatasetCacheDisabled
+	}
+
+	// 同一请求中已准备好的数据集也不能被淘汰
+	pinned := m.pinnedDatasets()
+	var args, keys []string
+	for _, ref := range refs {
+		dir, err := cache.Ensure(ctx, ref, pinned)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to prepare dataset %s: %w", ref.URL, err)
+		}
+		key := dataset.Key(ref)
+		pinned[key] = true
+		keys = append(keys, key)
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", dir, ref.MountPath))
+	}
+	return args, keys, nil
+}
+
+// pinnedDatasets 返回受管容器正在使用的数据集缓存键
+func (m *Manager) pinnedDatasets() map[string]bool {
+	pinned := make(map[string]bool)
+	for _, info := range m.ListContainers() {
+		for _, key := range strings.Split(info.Labels[datasetsLabel], ",") {
+			if key != "" {
+				pinned[key] = true
+			}
+		}
+	}
+	return pinned
+}
+
+// datasetCache 获取数据集缓存，未启用时返回nil
+func (m *Manager) datasetCache() *dataset.Cache {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.datasets
+}
@@ -0,0 +1,77 @@
+package container
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ClaimDNS 将运行中claim容器的地址登记到节点本地解析器（由agent实现）
+type ClaimDNS interface {
+	// Name 返回claim的DNS名称
+	Name(claimID string) string
+	// SyncClaims 以完整的 claim ID -> 容器IP 集合替换已登记的记录
+	SyncClaims(addresses map[string]string) error
+}
+
+// SetClaimDNS 启用claim容器的DNS名称登记
+func (m *Manager) SetClaimDNS(dns ClaimDNS) {
+	m.mu.Lock()
+	m.dns = dns
+	m.mu.Unlock()
+	m.syncClaimDNS()
+}
+
+// syncClaimDNS 在容器缓存变化后更新登记的记录
+// 持有dnsMu完成快照与写入，避免并发同步时较旧的快照覆盖较新的结果
+func (m *Manager) syncClaimDNS() {
+	m.dnsMu.Lock()
+	defer m.dnsMu.Unlock()
+
+	m.mu.RLock()
+	dns := m.dns
+	addresses := make(map[string]string)
+	if dns != nil {
+		for _, info := range m.containers {
+			if info.Status == "running" && info.IPAddress != "" && info.ClaimID != "" {
+				addresses[info.ClaimID] = info.IPAddress
+			}
+		}
+	}
+	m.mu.RUnlock()
+	if dns == nil {
+		return
+	}
+
+	if err := dns.SyncClaims(addresses); err != nil {
+		fmt.Printf("Warning: failed to update claim DNS records: %v\n", err)
+	}
+}
+
+// dnsName 返回claim的DNS名称，未启用登记时为空
+func (m *Manager) dnsName(claimID string) string {
+	m.mu.RLock()
+	dns := m.dns
+	m.mu.RUnlock()
+	if dns == nil || claimID == "" {
+		return ""
+	}
+	return dns.Name(claimID)
+}
+
+// containerIPAddress 返回容器在默认bridge网络或第一个自定义网络中的IP
+func containerIPAddress(container DockerContainer) string {
+	if container.NetworkSettings.IPAddress != "" {
+		return container.NetworkSettings.IPAddress
+	}
+	names := make([]string, 0, len(container.NetworkSettings.Networks))
+	for name := range container.NetworkSettings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ip := container.NetworkSettings.Networks[name].IPAddress; ip != "" {
+			return ip
+		}
+	}
+	return ""
+}
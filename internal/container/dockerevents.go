@@ -0,0 +1,199 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"utopia-node-agent/internal/events"
+)
+
+// Docker事件转换的节点事件类型
+const (
+	EventDied         = "container.died"
+	EventOOMKilled    = "container.oom_killed"
+	EventKilled       = "container.killed"
+	EventDestroyed    = "container.destroyed"
+	EventHealthStatus = "container.health_status"
+)
+
+// dockerEventsRetryDelay docker events 连接断开后的重连间隔
+const dockerEventsRetryDelay = 5 * time.Second
+
+// dockerEvent docker events --format '{{json .}}' 的输出
+type dockerEvent struct {
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	TimeNano int64 `json:"timeNano"`
+}
+
+// WatchDockerEvents 订阅受管容器的Docker事件，转换为节点事件并实时更新容器缓存
+// 连接断开后从最后收到的事件时间继续订阅，直到ctx取消
+func (m *Manager) WatchDockerEvents(ctx context.Context) {
+	since := time.Now()
+	for {
+		last, err := m.streamDockerEvents(ctx, since)
+		if !last.IsZero() {
+			since = last.Add(time.Nanosecond)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Printf("Warning: docker events stream ended: %v, reconnecting in %s\n", err, dockerEventsRetryDelay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(dockerEventsRetryDelay):
+		}
+	}
+}
+
+// streamDockerEvents 读取docker events输出直到进程退出，返回最后一个事件的时间
+func (m *Manager) streamDockerEvents(ctx context.Context, since time.Time) (time.Time, error) {
+	cmd := dockerCommand(ctx, "events",
+		"--format", "{{json .}}",
+		"--since", fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()),
+		"--filter", "type=container",
+		"--filter", "label=utopia.managed=true",
+		"--filter", "event=start",
+		"--filter", "event=die",
+		"--filter", "event=oom",
+		"--filter", "event=kill",
+		"--filter", "event=destroy",
+		"--filter", "event=health_status",
+	)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return time.Time{}, err
+	}
+	m.setEventStreamSince(time.Now())
+	defer m.setEventStreamSince(time.Time{})
+
+	var last time.Time
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		var event dockerEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		last = time.Unix(0, event.TimeNano)
+		m.handleDockerEvent(ctx, event)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return last, err
+	}
+	return last, scanner.Err()
+}
+
+// setEventStreamSince 记录事件流的连接状态
+func (m *Manager) setEventStreamSince(since time.Time) {
+	m.mu.Lock()
+	m.eventStreamSince = since
+	m.mu.Unlock()
+}
+
+// EventStreamSince 返回Docker事件流本次连接的建立时间，未连接时返回零值
+// 连接期间容器缓存由事件实时更新，全量刷新只需作为兜底
+func (m *Manager) EventStreamSince() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.eventStreamSince
+}
+
+// handleDockerEvent 更新容器缓存并发布对应的节点事件
+func (m *Manager) handleDockerEvent(ctx context.Context, event dockerEvent) {
+	containerID := event.Actor.ID
+	attrs := event.Actor.Attributes
+	claimID := attrs["utopia.claim_id"]
+
+	var published events.Event
+	switch {
+	case event.Action == "start":
+		m.refreshFromEvent(ctx, containerID)
+		return
+
+	case event.Action == "die":
+		m.refreshFromEvent(ctx, containerID)
+		exitCode, _ := strconv.Atoi(attrs["exitCode"])
+		published = events.Event{
+			Type:     EventDied,
+			Severity: events.SeverityInfo,
+			Message:  fmt.Sprintf("container exited with code %d", exitCode),
+			Data:     map[string]interface{}{"exit_code": exitCode},
+		}
+		if exitCode != 0 {
+			published.Severity = events.SeverityWarning
+		}
+
+	case event.Action == "oom":
+		published = events.Event{
+			Type:     EventOOMKilled,
+			Severity: events.SeverityError,
+			Message:  "container ran out of memory",
+		}
+
+	case event.Action == "kill":
+		published = events.Event{
+			Type:     EventKilled,
+			Severity: events.SeverityInfo,
+			Message:  fmt.Sprintf("container received signal %s", attrs["signal"]),
+			Data:     map[string]interface{}{"signal": attrs["signal"]},
+		}
+
+	case event.Action == "destroy":
+		m.mu.Lock()
+		m.deleteContainerLocked(containerID)
+		m.mu.Unlock()
+		m.syncClaimDNS()
+		published = events.Event{
+			Type:     EventDestroyed,
+			Severity: events.SeverityInfo,
+			Message:  "container was removed",
+		}
+
+	case strings.HasPrefix(event.Action, "health_status"):
+		status := strings.TrimSpace(strings.TrimPrefix(event.Action, "health_status:"))
+		published = events.Event{
+			Type:     EventHealthStatus,
+			Severity: events.SeverityInfo,
+			Message:  "container health status is " + status,
+			Data:     map[string]interface{}{"status": status},
+		}
+		if status == "unhealthy" {
+			published.Severity = events.SeverityWarning
+		}
+
+	default:
+		return
+	}
+
+	m.mu.RLock()
+	bus := m.events
+	m.mu.RUnlock()
+	if bus == nil {
+		return
+	}
+	published.ContainerID = containerID
+	published.ClaimID = claimID
+	published.Time = event.TimeNano / int64(time.Second)
+	bus.Publish(published)
+}
+
+// refreshFromEvent 根据事件刷新单个容器的缓存
+func (m *Manager) refreshFromEvent(ctx context.Context, containerID string) {
+	if err := m.RefreshContainer(ctx, containerID); err != nil {
+		fmt.Printf("Warning: failed to refresh container %s after docker event: %v\n", containerID, err)
+	}
+}
@@ -0,0 +1,108 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"utopia-node-agent/internal/mounts"
+)
+
+// ErrExternalVolumesDisabled 节点未启用外部存储卷
+var ErrExternalVolumesDisabled = errors.New("external volumes are disabled on this node")
+
+// SetExternalVolumes 启用外部存储卷
+func (m *Manager) SetExternalVolumes(mounter *mounts.Manager) {
+	m.mu.Lock()
+	m.mounter = mounter
+	m.mu.Unlock()
+}
+
+// ExternalVolumeTypes 返回节点可挂载的外部存储类型，未启用时为nil
+func (m *Manager) ExternalVolumeTypes() []string {
+	if mounter := m.getMounter(); mounter != nil {
+		return mounter.Types()
+	}
+	return nil
+}
+
+// prepareExternalVolumes 解密凭据并在主机上挂载外部存储，返回绑定挂载参数
+// 任一卷挂载失败时卸载已挂载的卷
+func (m *Manager) prepareExternalVolumes(ctx context.Context, containerName string, volumes []mounts.Volume) ([]string, error) {
+	if len(volumes) == 0 {
+		return nil, nil
+	}
+	mounter := m.getMounter()
+	if mounter == nil {
+		return nil, ErrExternalVolumesDisabled
+	}
+
+	m.mu.RLock()
+	keypair, credDir := m.secretsKeypair, m.secretsDir
+	m.mu.RUnlock()
+
+	var args []string
+	for i, v := range volumes {
+		var credentials []byte
+		if v.Credentials != "" {
+			if keypair == nil {
+				m.releaseExternalVolumes(containerName)
+				return nil, ErrSecretsDisabled
+			}
+			plaintext, err := keypair.Open(v.Credentials)
+			if err != nil {
+				m.releaseExternalVolumes(containerName)
+				return nil, fmt.Errorf("external volume %s credentials: %w", v.Source, err)
+			}
+			credentials = plaintext
+		}
+
+		hostPath, err := mounter.Mount(ctx, containerName, i, v, credentials, credDir)
+		if err != nil {
+			m.releaseExternalVolumes(containerName)
+			return nil, fmt.Errorf("failed to mount %s volume %s: %w", v.Type, v.Source, err)
+		}
+		mode := ""
+		if v.ReadOnly {
+			mode = ":ro"
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s%s", hostPath, v.MountPath, mode))
+	}
+	return args, nil
+}
+
+// releaseExternalVolumes 卸载容器的外部存储卷
+func (m *Manager) releaseExternalVolumes(containerName string) {
+	mounter := m.getMounter()
+	if mounter == nil {
+		return
+	}
+	if err := mounter.Release(containerName); err != nil {
+		fmt.Printf("Warning: failed to release external volumes of %s: %v\n", containerName, err)
+	}
+}
+
+// ReleaseOrphanedVolumes 卸载已没有容器的外部存储卷（如上次运行在创建或删除容器的过程中退出）
+func (m *Manager) ReleaseOrphanedVolumes() {
+	mounter := m.getMounter()
+	if mounter == nil {
+		return
+	}
+	existing := make(map[string]bool)
+	for _, info := range m.ListContainers() {
+		existing[containerNameFor(info.ClaimID)] = true
+	}
+	for _, owner := range mounter.Owners() {
+		if !existing[owner] {
+			fmt.Printf("Releasing orphaned external volumes of %s\n", owner)
+			m.releaseExternalVolumes(owner)
+		}
+	}
+}
+
+// getMounter 获取外部存储挂载管理器，未启用时返回nil
+func (m *Manager) getMounter() *mounts.Manager {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mounter
+}
@@ -0,0 +1,31 @@
+package container
+
+// GPUCleaner 在claim的容器删除后确认其GPU已清理（由agent实现）
+type GPUCleaner interface {
+	// CleanupGPUs 将GPU标记为待清理并在后台确认显存与计算进程已释放
+	CleanupGPUs(claimID string, gpuIDs []int)
+}
+
+// SetGPUCleaner 启用claim之间的GPU清理校验
+func (m *Manager) SetGPUCleaner(cleaner GPUCleaner) {
+	m.mu.Lock()
+	m.cleaner = cleaner
+	m.mu.Unlock()
+}
+
+// cleanupGPUs 对已删除容器独占的GPU发起清理校验
+// 共享计算的GPU上仍运行MPS守护进程或其他客户端，时间片共享的GPU上仍有其他容器时同样不做校验
+func (m *Manager) cleanupGPUs(info ContainerInfo) {
+	if len(info.GPUIDs) == 0 || info.Labels[mpsLabel] == "true" {
+		return
+	}
+	if info.GPUFraction > 0 && m.timeSlicedGPUs()[info.GPUIDs[0]] != nil {
+		return
+	}
+	m.mu.RLock()
+	cleaner := m.cleaner
+	m.mu.RUnlock()
+	if cleaner != nil {
+		cleaner.CleanupGPUs(info.ClaimID, info.GPUIDs)
+	}
+}
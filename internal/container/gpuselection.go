@@ -0,0 +1,38 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"utopia-node-agent/internal/gpu"
+)
+
+// gpuSelectionLabel 记录创建时的GPU选择依据（JSON）
+const gpuSelectionLabel = "utopia.gpu_selection"
+
+// gpuSelectionArgs 返回记录GPU选择依据的容器标签参数，未选择GPU时为空
+func gpuSelectionArgs(selection gpu.Selection) []string {
+	if len(selection.GPUIDs) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(selection)
+	if err != nil {
+		fmt.Printf("Warning: failed to encode GPU selection: %v\n", err)
+		return nil
+	}
+	return []string{"--label", fmt.Sprintf("%s=%s", gpuSelectionLabel, data)}
+}
+
+// parseGPUSelection 解析容器标签中的GPU选择依据
+func parseGPUSelection(labels map[string]string) *gpu.Selection {
+	value := labels[gpuSelectionLabel]
+	if value == "" {
+		return nil
+	}
+	var selection gpu.Selection
+	if err := json.Unmarshal([]byte(value), &selection); err != nil {
+		fmt.Printf("Warning: invalid %s label: %v\n", gpuSelectionLabel, err)
+		return nil
+	}
+	return &selection
+}
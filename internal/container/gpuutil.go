@@ -0,0 +1,195 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"utopia-node-agent/internal/gpu"
+)
+
+// claimUtilizationHistory 每个claim保留的GPU利用率采样数
+const claimUtilizationHistory = 720
+
+// claimUtilizationMaxWindow 单次统计向前追溯的最长时间，驱动也只保留最近的进程采样
+const claimUtilizationMaxWindow = time.Minute
+
+// GPU利用率的来源
+const (
+	// 按claim容器内进程的采样统计
+	UtilizationSourceProcess = "process"
+	// 驱动不支持按进程统计时，以claim独占的整块GPU的利用率代替
+	UtilizationSourceDevice = "device"
+)
+
+// ClaimGPUUtilization claim在单块GPU上的SM利用率（百分比）
+type ClaimGPUUtilization struct {
+	GPUID              int     `json:"gpu_id"`
+	UtilizationPercent float64 `json:"utilization_percent"`
+	Source             string  `json:"source"`
+}
+
+// ClaimGPUSample claim的一次GPU利用率采样
+type ClaimGPUSample struct {
+	// 节点内递增的采样序号，断线重连时据此续传
+	Seq     int64  `json:"seq"`
+	Time    int64  `json:"time"`
+	ClaimID string `json:"claim_id"`
+	// claim各GPU利用率的平均值
+	UtilizationPercent float64               `json:"utilization_percent"`
+	GPUs               []ClaimGPUUtilization `json:"gpus"`
+}
+
+// claimUtilization 各claim最近的GPU利用率采样
+type claimUtilization struct {
+	mu        sync.Mutex
+	seq       int64
+	sampledAt time.Time
+	samples   map[string][]ClaimGPUSample // claimID -> 按时间排序的采样
+}
+
+// claimGPU claim与其使用的一块GPU
+type claimGPU struct {
+	claimID string
+	gpuID   int
+}
+
+// SampleGPUUtilization 按进程统计运行中claim在各自GPU上的SM利用率
+// 进程按所在cgroup归属到容器；驱动不支持按进程统计时，claim独占的GPU以整块GPU的利用率代替，共享的GPU不统计
+func (m *Manager) SampleGPUUtilization() error {
+	now := time.Now()
+	known := make(map[string]bool)
+	claimGPUs := make(map[string][]int)
+	gpuClaims := make(map[int]map[string]bool)
+	for _, info := range m.ListContainers() {
+		known[info.ClaimID] = true
+		if info.Status != "running" || info.ClaimID == "" {
+			continue
+		}
+		for _, id := range info.GPUIDs {
+			if gpuClaims[id] == nil {
+				gpuClaims[id] = make(map[string]bool)
+			}
+			if !gpuClaims[id][info.ClaimID] {
+				claimGPUs[info.ClaimID] = append(claimGPUs[info.ClaimID], id)
+			}
+			gpuClaims[id][info.ClaimID] = true
+		}
+	}
+
+	m.gpuUtil.mu.Lock()
+	since := m.gpuUtil.sampledAt
+	m.gpuUtil.mu.Unlock()
+	if now.Sub(since) > claimUtilizationMaxWindow {
+		since = now.Add(-claimUtilizationMaxWindow)
+	}
+
+	utilization := make(map[claimGPU]ClaimGPUUtilization)
+	var firstErr error
+	for gpuID, claims := range gpuClaims {
+		processes, err := m.gpuMonitor.ProcessUtilization(gpuID, since)
+		if errors.Is(err, gpu.ErrProcessUtilizationUnsupported) {
+			info, ok := m.gpuMonitor.GetGPUByID(gpuID)
+			if !ok || len(claims) != 1 {
+				continue
+			}
+			for claimID := range claims {
+				utilization[claimGPU{claimID, gpuID}] = ClaimGPUUtilization{GPUID: gpuID, UtilizationPercent: info.UsagePercent, Source: UtilizationSourceDevice}
+			}
+			continue
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("GPU %d: %w", gpuID, err)
+			}
+			continue
+		}
+
+		// 区间内没有进程采样的claim视为空闲
+		for claimID := range claims {
+			utilization[claimGPU{claimID, gpuID}] = ClaimGPUUtilization{GPUID: gpuID, Source: UtilizationSourceProcess}
+		}
+		for _, p := range processes {
+			info, ok := m.ContainerForPID(int(p.PID))
+			if !ok || !claims[info.ClaimID] {
+				continue
+			}
+			key := claimGPU{info.ClaimID, gpuID}
+			u := utilization[key]
+			u.UtilizationPercent = min(u.UtilizationPercent+p.UtilizationPercent, 100)
+			utilization[key] = u
+		}
+	}
+
+	m.gpuUtil.mu.Lock()
+	defer m.gpuUtil.mu.Unlock()
+	if m.gpuUtil.samples == nil {
+		m.gpuUtil.samples = make(map[string][]ClaimGPUSample)
+	}
+	for claimID, gpuIDs := range claimGPUs {
+		sort.Ints(gpuIDs)
+		sample := ClaimGPUSample{Time: now.Unix(), ClaimID: claimID, GPUs: []ClaimGPUUtilization{}}
+		for _, id := range gpuIDs {
+			if u, ok := utilization[claimGPU{claimID, id}]; ok {
+				sample.GPUs = append(sample.GPUs, u)
+				sample.UtilizationPercent += u.UtilizationPercent
+			}
+		}
+		if len(sample.GPUs) == 0 {
+			continue
+		}
+		sample.UtilizationPercent /= float64(len(sample.GPUs))
+		m.gpuUtil.seq++
+		sample.Seq = m.gpuUtil.seq
+		samples := append(m.gpuUtil.samples[claimID], sample)
+		if len(samples) > claimUtilizationHistory {
+			samples = samples[len(samples)-claimUtilizationHistory:]
+		}
+		m.gpuUtil.samples[claimID] = samples
+	}
+	// 容器已删除的claim不再保留采样
+	for claimID := range m.gpuUtil.samples {
+		if !known[claimID] {
+			delete(m.gpuUtil.samples, claimID)
+		}
+	}
+	m.gpuUtil.sampledAt = now
+	return firstErr
+}
+
+// UtilizationInterval 返回按进程统计claim GPU利用率的间隔，0表示不统计
+func (m *Manager) UtilizationInterval() time.Duration {
+	return m.getOptions().UtilizationInterval
+}
+
+// GPUUtilization 返回claim序号大于since的GPU利用率采样，按时间排序
+func (m *Manager) GPUUtilization(claimID string, since int64) []ClaimGPUSample {
+	m.gpuUtil.mu.Lock()
+	defer m.gpuUtil.mu.Unlock()
+
+	samples := m.gpuUtil.samples[claimID]
+	start := sort.Search(len(samples), func(i int) bool { return samples[i].Seq > since })
+	return append([]ClaimGPUSample{}, samples[start:]...)
+}
+
+// latestGPUUtilization 返回claim最近一次GPU利用率采样的平均值，超过三个统计周期没有采样时返回false
+func (m *Manager) latestGPUUtilization(claimID string) (float64, bool) {
+	interval := m.UtilizationInterval()
+	if interval <= 0 {
+		return 0, false
+	}
+
+	m.gpuUtil.mu.Lock()
+	defer m.gpuUtil.mu.Unlock()
+	samples := m.gpuUtil.samples[claimID]
+	if len(samples) == 0 {
+		return 0, false
+	}
+	latest := samples[len(samples)-1]
+	if time.Since(time.Unix(latest.Time, 0)) > 3*interval {
+		return 0, false
+	}
+	return latest.UtilizationPercent, true
+}
@@ -0,0 +1,284 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/store"
+	"utopia-node-agent/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrHibernationDisabled 节点未启用claim休眠
+	ErrHibernationDisabled = errors.New("claim hibernation is disabled on this node")
+	// ErrClaimNotFound claim没有受管容器
+	ErrClaimNotFound = errors.New("claim has no container on this node")
+	// ErrClaimHibernated claim已处于休眠状态
+	ErrClaimHibernated = errors.New("claim is already hibernated")
+	// ErrClaimNotHibernated claim未处于休眠状态
+	ErrClaimNotHibernated = errors.New("claim is not hibernated")
+	// ErrHibernatedGPUsTaken 休眠时释放的GPU已分配给其他claim，无法恢复
+	ErrHibernatedGPUsTaken = errors.New("GPUs of the hibernated claim are no longer available")
+)
+
+// claim休眠事件
+const (
+	EventClaimHibernated = "claim.hibernated"
+	EventClaimResumed    = "claim.resumed"
+)
+
+// Hibernation 休眠中的claim：容器已停止，可写层与卷保留
+type Hibernation struct {
+	ClaimID     string `json:"claim_id"`
+	ContainerID string `json:"container_id"`
+	GPUIDs      []int  `json:"gpu_ids"`
+	// 休眠期间是否为claim保留GPU
+	KeepGPUs     bool  `json:"keep_gpus"`
+	HibernatedAt int64 `json:"hibernated_at"`
+}
+
+// hibernations 休眠记录
+type hibernations struct {
+	store   *store.Store
+	records map[string]Hibernation // claimID -> 休眠记录
+}
+
+// EnableHibernation 启用claim休眠，休眠记录持久化在dir中
+func (m *Manager) EnableHibernation(dir string) error {
+	st, err := store.Open(dir)
+	if err != nil {
+		return err
+	}
+	keys, err := st.Keys()
+	if err != nil {
+		return err
+	}
+
+	h := &hibernations{store: st, records: make(map[string]Hibernation)}
+	for _, key := range keys {
+		var record Hibernation
+		if ok, err := st.Get(key, &record); err != nil || !ok {
+			fmt.Printf("Warning: failed to load hibernation %s: %v\n", key, err)
+			continue
+		}
+		h.records[record.ClaimID] = record
+	}
+
+	m.mu.Lock()
+	m.hibernations = h
+	m.mu.Unlock()
+	return nil
+}
+
+// HibernateClaim 停止claim的容器但保留可写层、卷与（按keepGPUs）GPU，恢复前按仅存储计费
+// keepGPUs为nil时使用节点默认值；共享计算（MPS）的GPU不保留
+func (m *Manager) HibernateClaim(ctx context.Context, claimID string, keepGPUs *bool) (record Hibernation, err error) {
+	ctx, span := tracing.Start(ctx, "container.Hibernate", attribute.String("utopia.claim_id", claimID))
+	defer func() { tracing.End(span, err) }()
+
+	m.mu.RLock()
+	h := m.hibernations
+	_, hibernated := h.lookup(claimID)
+	m.mu.RUnlock()
+	if h == nil {
+		return Hibernation{}, ErrHibernationDisabled
+	}
+	if hibernated {
+		return Hibernation{}, ErrClaimHibernated
+	}
+	info, found := m.claimContainer(claimID)
+	if !found {
+		return Hibernation{}, ErrClaimNotFound
+	}
+
+	keep := m.getOptions().HibernateKeepGPUs
+	if keepGPUs != nil {
+		keep = *keepGPUs
+	}
+	record = Hibernation{
+		ClaimID:      claimID,
+		ContainerID:  info.ID,
+		GPUIDs:       info.GPUIDs,
+		KeepGPUs:     keep && len(info.GPUIDs) > 0 && info.Labels[mpsLabel] != "true" && info.GPUFraction == 0,
+		HibernatedAt: time.Now().Unix(),
+	}
+	// 先记录再停止，停止期间GPU不会被分配给其他claim
+	if err := m.putHibernation(record); err != nil {
+		return Hibernation{}, err
+	}
+
+	if output, err := dockerCommand(ctx, "stop", "-t", "30", info.ID).CombinedOutput(); err != nil {
+		m.deleteHibernation(claimID)
+		return Hibernation{}, fmt.Errorf("failed to stop container: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	if err := m.RefreshContainer(ctx, info.ID); err != nil {
+		tracing.Logger(ctx).Warnf("Failed to refresh hibernated container %s: %v", info.ID, err)
+	}
+
+	if info.Labels[mpsLabel] == "true" {
+		m.ReleaseMPS(ctx)
+	}
+	if !record.KeepGPUs {
+		m.cleanupGPUs(info)
+	}
+
+	m.publishHibernationEvent(EventClaimHibernated, record, fmt.Sprintf("claim hibernated (keep_gpus=%t)", record.KeepGPUs))
+	return record, nil
+}
+
+// ResumeClaim 重新启动休眠claim的容器
+// 休眠时释放的GPU已被其他claim占用时返回 ErrHibernatedGPUsTaken，claim保持休眠
+func (m *Manager) ResumeClaim(ctx context.Context, claimID string) (err error) {
+	ctx, span := tracing.Start(ctx, "container.Resume", attribute.String("utopia.claim_id", claimID))
+	defer func() { tracing.End(span, err) }()
+
+	m.mu.RLock()
+	h := m.hibernations
+	record, hibernated := h.lookup(claimID)
+	m.mu.RUnlock()
+	if h == nil {
+		return ErrHibernationDisabled
+	}
+	if !hibernated {
+		return ErrClaimNotHibernated
+	}
+
+	if fraction := m.containerGPUFraction(record.ContainerID); fraction > 0 && len(record.GPUIDs) == 1 {
+		// 时间片共享的claim只需GPU上还有足够的份额
+		if !m.timeSliceFits(record.GPUIDs[0], fraction) {
+			return fmt.Errorf("%w: GPU %d has no time-slice capacity left", ErrHibernatedGPUsTaken, record.GPUIDs[0])
+		}
+	} else if !record.KeepGPUs && len(record.GPUIDs) > 0 {
+		available := make(map[int]bool)
+		for _, id := range m.gpuMonitor.GetAvailableGPUs() {
+			available[id] = true
+		}
+		for _, id := range record.GPUIDs {
+			if !available[id] {
+				return fmt.Errorf("%w: GPU %d is in use", ErrHibernatedGPUsTaken, id)
+			}
+		}
+	}
+
+	if err := m.StartContainer(ctx, record.ContainerID); err != nil {
+		return err
+	}
+	if err := m.deleteHibernation(claimID); err != nil {
+		tracing.Logger(ctx).Warnf("Failed to clear hibernation of claim %s: %v", claimID, err)
+	}
+	if err := m.RefreshContainer(ctx, record.ContainerID); err != nil {
+		tracing.Logger(ctx).Warnf("Failed to refresh resumed container %s: %v", record.ContainerID, err)
+	}
+
+	m.publishHibernationEvent(EventClaimResumed, record, "claim resumed from hibernation")
+	return nil
+}
+
+// containerGPUFraction 返回容器以时间片共享时占用的GPU份额
+func (m *Manager) containerGPUFraction(containerID string) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.containers[containerID].GPUFraction
+}
+
+// GetHibernation 返回claim的休眠记录
+func (m *Manager) GetHibernation(claimID string) (Hibernation, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.hibernations.lookup(claimID)
+}
+
+// hibernatedGPUInUse 判断GPU是否被休眠中且保留GPU的claim占用（调用方需持有锁）
+func (m *Manager) hibernatedGPUInUse(gpuID int) bool {
+	if m.hibernations == nil {
+		return false
+	}
+	for _, record := range m.hibernations.records {
+		if !record.KeepGPUs {
+			continue
+		}
+		for _, id := range record.GPUIDs {
+			if id == gpuID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hibernationFor 返回容器的休眠记录，容器已被替换时不算休眠（调用方需持有锁）
+func (m *Manager) hibernationFor(claimID, containerID string) *Hibernation {
+	record, exists := m.hibernations.lookup(claimID)
+	if !exists || record.ContainerID != containerID {
+		return nil
+	}
+	return &record
+}
+
+// claimContainer 返回claim运行中的容器
+func (m *Manager) claimContainer(claimID string) (ContainerInfo, bool) {
+	for _, info := range m.ListContainers() {
+		if info.ClaimID == claimID && info.Status == "running" {
+			return info, true
+		}
+	}
+	return ContainerInfo{}, false
+}
+
+// putHibernation 保存休眠记录
+func (m *Manager) putHibernation(record Hibernation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.hibernations.store.Put(record.ClaimID, &record); err != nil {
+		return fmt.Errorf("failed to persist hibernation: %w", err)
+	}
+	m.hibernations.records[record.ClaimID] = record
+	return nil
+}
+
+// deleteHibernation 删除休眠记录
+func (m *Manager) deleteHibernation(claimID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hibernations == nil {
+		return nil
+	}
+	delete(m.hibernations.records, claimID)
+	return m.hibernations.store.Delete(claimID)
+}
+
+// publishHibernationEvent 发布休眠或恢复事件
+func (m *Manager) publishHibernationEvent(eventType string, record Hibernation, message string) {
+	m.mu.RLock()
+	bus := m.events
+	m.mu.RUnlock()
+	if bus == nil {
+		return
+	}
+	bus.Publish(events.Event{
+		Type:        eventType,
+		Severity:    events.SeverityInfo,
+		ContainerID: record.ContainerID,
+		ClaimID:     record.ClaimID,
+		Message:     message,
+		Data: map[string]interface{}{
+			"gpu_ids":   record.GPUIDs,
+			"keep_gpus": record.KeepGPUs,
+		},
+	})
+}
+
+// lookup 查找claim的休眠记录，未启用时返回false
+func (h *hibernations) lookup(claimID string) (Hibernation, bool) {
+	if h == nil {
+		return Hibernation{}, false
+	}
+	record, exists := h.records[claimID]
+	return record, exists
+}
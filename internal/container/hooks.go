@@ -0,0 +1,59 @@
+package container
+
+import (
+	"context"
+	"fmt"
+
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/hooks"
+	"utopia-node-agent/internal/tracing"
+)
+
+// EventHookFailed 未设置fail_on_error的钩子执行失败
+const EventHookFailed = "container.hook_failed"
+
+// SetHooks 设置容器生命周期钩子
+func (m *Manager) SetHooks(runner *hooks.Runner) {
+	m.mu.Lock()
+	m.hooks = runner
+	m.mu.Unlock()
+}
+
+// runHooks 执行阶段的钩子，非致命失败以事件上报
+func (m *Manager) runHooks(ctx context.Context, hookCtx hooks.Context) error {
+	m.mu.RLock()
+	runner := m.hooks
+	bus := m.events
+	hookCtx.NodeID = m.options.NodeID
+	m.mu.RUnlock()
+
+	if runner == nil {
+		return nil
+	}
+
+	return runner.Run(ctx, hookCtx, func(hook hooks.Hook, err error) {
+		tracing.Logger(ctx).Warnf("%s hook failed for claim %s: %v", hook.Stage, hookCtx.ClaimID, err)
+		if bus != nil {
+			bus.Publish(events.Event{
+				Type:        EventHookFailed,
+				Severity:    events.SeverityWarning,
+				ContainerID: hookCtx.ContainerID,
+				ClaimID:     hookCtx.ClaimID,
+				Message:     fmt.Sprintf("%s hook failed: %v", hook.Stage, err),
+				Data:        map[string]interface{}{"stage": hook.Stage},
+			})
+		}
+	})
+}
+
+// hookContextFor 根据容器信息构建钩子上下文
+func hookContextFor(stage string, info ContainerInfo) hooks.Context {
+	return hooks.Context{
+		Stage:       stage,
+		ClaimID:     info.ClaimID,
+		ContainerID: info.ID,
+		Image:       info.Image,
+		GPUIDs:      info.GPUIDs,
+		Labels:      info.Labels,
+	}
+}
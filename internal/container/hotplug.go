@@ -0,0 +1,82 @@
+package container
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"utopia-node-agent/internal/gpu"
+)
+
+// gpuUUIDsLabel 记录分配的GPU的UUID的标签，GPU热插拔导致索引变化后据此找回容器实际使用的GPU
+const gpuUUIDsLabel = "utopia.gpu_uuids"
+
+// ErrGPUsReindexed 容器创建时分配的GPU已被移除或索引已变化，重新启动会挂载错误的GPU
+var ErrGPUsReindexed = errors.New("GPUs assigned to the container were removed or renumbered")
+
+// gpuUUIDs 返回分配的GPU的UUID，以逗号分隔；任一GPU的UUID未知时返回空字符串
+func (m *Manager) gpuUUIDs(gpuIDs []int) string {
+	uuids := make([]string, 0, len(gpuIDs))
+	for _, id := range gpuIDs {
+		info, ok := m.gpuMonitor.GetGPUByID(id)
+		if !ok || info.UUID == "" || info.UUID == "Unknown" {
+			return ""
+		}
+		uuids = append(uuids, info.UUID)
+	}
+	return strings.Join(uuids, ",")
+}
+
+// containerGPUIDs 返回容器所用GPU的当前索引
+// GPU热插拔后索引可能变化，有UUID标签时按UUID换算（已移除的GPU不再计入），否则使用创建时记录的索引
+// GPU功能不可用（降级模式）时无法换算，同样使用记录的索引
+func (m *Manager) containerGPUIDs(labels map[string]string) []int {
+	value := labels[gpuUUIDsLabel]
+	if value == "" || !m.gpuMonitor.Available() {
+		return parseGPUIDs(labels["utopia.gpu_ids"])
+	}
+	var gpuIDs []int
+	for _, uuid := range strings.Split(value, ",") {
+		if id, ok := m.gpuMonitor.IndexOfUUID(uuid); ok {
+			gpuIDs = append(gpuIDs, id)
+		}
+	}
+	return gpuIDs
+}
+
+// gpusReindexed 容器的GPU设备参数（按创建时的索引）是否已不再对应分配的GPU
+func (m *Manager) gpusReindexed(info ContainerInfo) bool {
+	if info.Labels[gpuUUIDsLabel] == "" {
+		return false
+	}
+	return !slices.Equal(parseGPUIDs(info.Labels["utopia.gpu_ids"]), info.GPUIDs)
+}
+
+// ContainersOnChangedGPUs 返回GPU增减后设备参数不再对应所分配GPU的容器
+// 早于UUID标签创建的容器无法换算，按被移除或索引变化的GPU原索引判断
+func (m *Manager) ContainersOnChangedGPUs(change gpu.DeviceChange) []ContainerInfo {
+	stale := make(map[int]bool)
+	for _, device := range change.Removed {
+		stale[device.Index] = true
+	}
+	for _, move := range change.Moved {
+		stale[move.From] = true
+	}
+
+	var affected []ContainerInfo
+	for _, info := range m.ListContainers() {
+		if info.Labels[gpuUUIDsLabel] != "" {
+			if m.gpusReindexed(info) {
+				affected = append(affected, info)
+			}
+			continue
+		}
+		for _, id := range info.GPUIDs {
+			if stale[id] {
+				affected = append(affected, info)
+				break
+			}
+		}
+	}
+	return affected
+}
@@ -0,0 +1,315 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"utopia-node-agent/internal/events"
+)
+
+// claim空闲检测事件类型
+const (
+	EventClaimIdle        = "claim.idle"
+	EventClaimActive      = "claim.active"
+	EventClaimIdleStopped = "claim.idle_stopped"
+)
+
+// 最近一次活动的来源
+const (
+	ActivityGPU        = "gpu"
+	ActivityConnection = "connection"
+	ActivityNetwork    = "network"
+	// 开始跟踪（容器启动或agent重启）时视为活动
+	ActivityObserved = "observed"
+)
+
+// tcpEstablishedState /proc/net/tcp 中已建立连接的状态
+const tcpEstablishedState = "01"
+
+// IdlePolicy claim空闲判定与处理策略
+type IdlePolicy struct {
+	// 持续无活动超过IdleAfter视为空闲并发出警告
+	IdleAfter time.Duration
+	// 大于0时空闲（自最近一次活动起）超过StopAfter后停止容器
+	StopAfter time.Duration
+	// GPU利用率与每分钟入站流量达到阈值视为活动，暴露端口上有已建立的入站连接也视为活动
+	GPUUtilizationPercent   float64
+	NetworkRxBytesPerMinute uint64
+}
+
+// Activity claim容器最近一次检查到的活动信号与空闲状态
+type Activity struct {
+	GPUUtilizationPercent float64 `json:"gpu_utilization_percent"`
+	// 暴露端口上已建立的入站TCP连接数（如通过隧道的SSH会话）
+	Connections             int     `json:"connections"`
+	NetworkRxBytesPerMinute float64 `json:"network_rx_bytes_per_minute"`
+	LastActiveAt            int64   `json:"last_active_at"`
+	LastActiveSignal        string  `json:"last_active_signal"`
+	IdleSeconds             int64   `json:"idle_seconds"`
+	Idle                    bool    `json:"idle"`
+	// 按策略将被停止的时间，只警告时为0
+	StopAt    int64 `json:"stop_at,omitempty"`
+	CheckedAt int64 `json:"checked_at"`
+}
+
+// activityTracker 单个容器的活动跟踪状态
+type activityTracker struct {
+	activity  Activity
+	rxBytes   uint64
+	sampledAt time.Time
+	warned    bool
+}
+
+// activityInspect docker inspect 中采集活动信号所需的字段
+type activityInspect struct {
+	ID    string `json:"Id"`
+	State struct {
+		Running bool `json:"Running"`
+		Pid     int  `json:"Pid"`
+	} `json:"State"`
+	HostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+}
+
+// activityFor 返回容器的活动状态，未跟踪时为nil（调用方需持有锁）
+func (m *Manager) activityFor(containerID string) *Activity {
+	tracker, ok := m.activity[containerID]
+	if !ok {
+		return nil
+	}
+	activity := tracker.activity
+	return &activity
+}
+
+// CheckIdle 采集运行中claim容器的活动信号，对空闲超过策略时长的claim发布警告，并按策略停止容器
+// gpuUsage 返回GPU当前的利用率
+func (m *Manager) CheckIdle(ctx context.Context, policy IdlePolicy, gpuUsage func(gpuID int) (float64, bool)) error {
+	var running []ContainerInfo
+	for _, info := range m.ListContainers() {
+		if info.Status == "running" {
+			running = append(running, info)
+		}
+	}
+
+	inspected := make(map[string]activityInspect)
+	if len(running) > 0 {
+		args := []string{"inspect"}
+		for _, info := range running {
+			args = append(args, info.ID)
+		}
+		output, err := dockerCommand(ctx, args...).Output()
+		if err != nil && len(output) == 0 {
+			return fmt.Errorf("failed to inspect containers: %w", err)
+		}
+		var items []activityInspect
+		if err := json.Unmarshal(output, &items); err != nil {
+			return fmt.Errorf("failed to parse container info: %w", err)
+		}
+		for _, item := range items {
+			inspected[item.ID] = item
+		}
+	}
+
+	now := time.Now()
+	var toStop []ContainerInfo
+	var pending []events.Event
+	m.mu.Lock()
+	if m.activity == nil {
+		m.activity = make(map[string]*activityTracker)
+	}
+	current := make(map[string]bool)
+	for _, info := range running {
+		item, ok := inspected[info.ID]
+		if !ok || !item.State.Running {
+			continue
+		}
+		current[info.ID] = true
+
+		tracker, tracked := m.activity[info.ID]
+		if !tracked {
+			tracker = &activityTracker{activity: Activity{LastActiveAt: now.Unix(), LastActiveSignal: ActivityObserved}}
+			m.activity[info.ID] = tracker
+		}
+		a := &tracker.activity
+
+		a.GPUUtilizationPercent = 0
+		for _, gpuID := range info.GPUIDs {
+			if usage, ok := gpuUsage(gpuID); ok && usage > a.GPUUtilizationPercent {
+				a.GPUUtilizationPercent = usage
+			}
+		}
+		a.Connections = inboundConnections(item.State.Pid, info.Ports)
+		a.NetworkRxBytesPerMinute = 0
+		// host网络模式下无法区分容器流量
+		if item.HostConfig.NetworkMode != "host" {
+			if rx, _, err := readNetDevBytes(item.State.Pid); err == nil {
+				if tracked && rx >= tracker.rxBytes {
+					if minutes := now.Sub(tracker.sampledAt).Minutes(); minutes > 0 {
+						a.NetworkRxBytesPerMinute = float64(rx-tracker.rxBytes) / minutes
+					}
+				}
+				tracker.rxBytes, tracker.sampledAt = rx, now
+			}
+		}
+
+		switch {
+		case policy.GPUUtilizationPercent > 0 && a.GPUUtilizationPercent >= policy.GPUUtilizationPercent:
+			a.LastActiveAt, a.LastActiveSignal = now.Unix(), ActivityGPU
+		case a.Connections > 0:
+			a.LastActiveAt, a.LastActiveSignal = now.Unix(), ActivityConnection
+		case policy.NetworkRxBytesPerMinute > 0 && a.NetworkRxBytesPerMinute >= float64(policy.NetworkRxBytesPerMinute):
+			a.LastActiveAt, a.LastActiveSignal = now.Unix(), ActivityNetwork
+		}
+
+		idleFor := now.Sub(time.Unix(a.LastActiveAt, 0))
+		a.IdleSeconds = int64(idleFor.Seconds())
+		a.Idle = policy.IdleAfter > 0 && idleFor >= policy.IdleAfter
+		a.StopAt = 0
+		if policy.StopAfter > 0 {
+			a.StopAt = a.LastActiveAt + int64(policy.StopAfter.Seconds())
+		}
+		a.CheckedAt = now.Unix()
+
+		if stored, ok := m.containers[info.ID]; ok {
+			stored.Activity = m.activityFor(info.ID)
+			m.containers[info.ID] = stored
+		}
+
+		switch {
+		case a.Idle && policy.StopAfter > 0 && idleFor >= policy.StopAfter:
+			toStop = append(toStop, info)
+		case a.Idle && !tracker.warned:
+			tracker.warned = true
+			pending = append(pending, idleEvent(info, *a))
+		case !a.Idle && tracker.warned:
+			tracker.warned = false
+			pending = append(pending, events.Event{
+				Type:        EventClaimActive,
+				Severity:    events.SeverityInfo,
+				ContainerID: info.ID,
+				ClaimID:     info.ClaimID,
+				Message:     fmt.Sprintf("claim %s is active again (%s)", info.ClaimID, a.LastActiveSignal),
+				Data:        map[string]interface{}{"signal": a.LastActiveSignal},
+			})
+		}
+	}
+	for id := range m.activity {
+		if !current[id] {
+			delete(m.activity, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, event := range pending {
+		m.publishIdleEvent(event)
+	}
+	for _, info := range toStop {
+		m.stopIdle(ctx, info, policy)
+	}
+	return nil
+}
+
+// idleEvent 生成claim空闲警告事件
+func idleEvent(info ContainerInfo, a Activity) events.Event {
+	message := fmt.Sprintf("claim %s has been idle for %s", info.ClaimID, time.Duration(a.IdleSeconds)*time.Second)
+	if a.StopAt > 0 {
+		message += fmt.Sprintf(" and will be stopped at %s", time.Unix(a.StopAt, 0).UTC().Format(time.RFC3339))
+	}
+	fmt.Printf("Warning: %s\n", message)
+	return events.Event{
+		Type:        EventClaimIdle,
+		Severity:    events.SeverityWarning,
+		ContainerID: info.ID,
+		ClaimID:     info.ClaimID,
+		Message:     message,
+		Data: map[string]interface{}{
+			"idle_seconds":   a.IdleSeconds,
+			"last_active_at": a.LastActiveAt,
+			"stop_at":        a.StopAt,
+			"gpu_ids":        info.GPUIDs,
+		},
+	}
+}
+
+// stopIdle 停止空闲超过策略时长的claim容器
+func (m *Manager) stopIdle(ctx context.Context, info ContainerInfo, policy IdlePolicy) {
+	if err := m.StopContainer(ctx, info.ID); err != nil {
+		fmt.Printf("Warning: failed to stop idle claim %s: %v\n", info.ClaimID, err)
+		return
+	}
+	fmt.Printf("Stopped idle claim %s (container %.12s)\n", info.ClaimID, info.ID)
+
+	m.mu.Lock()
+	delete(m.activity, info.ID)
+	m.mu.Unlock()
+
+	m.publishIdleEvent(events.Event{
+		Type:        EventClaimIdleStopped,
+		Severity:    events.SeverityWarning,
+		ContainerID: info.ID,
+		ClaimID:     info.ClaimID,
+		Message:     fmt.Sprintf("stopped claim %s after %s without activity", info.ClaimID, policy.StopAfter),
+		Data: map[string]interface{}{
+			"idle_seconds": int64(policy.StopAfter.Seconds()),
+			"gpu_ids":      info.GPUIDs,
+		},
+	})
+}
+
+// publishIdleEvent 发布空闲检测事件
+func (m *Manager) publishIdleEvent(event events.Event) {
+	m.mu.RLock()
+	bus := m.events
+	m.mu.RUnlock()
+	if bus != nil {
+		bus.Publish(event)
+	}
+}
+
+// inboundConnections 统计容器网络命名空间中暴露端口上已建立的TCP连接数
+// ports 的键为 "容器端口/协议"
+func inboundConnections(pid int, ports map[string]string) int {
+	exposed := make(map[uint64]bool)
+	for key := range ports {
+		port, protocol, _ := strings.Cut(key, "/")
+		if protocol != "tcp" {
+			continue
+		}
+		if n, err := strconv.ParseUint(port, 10, 16); err == nil {
+			exposed[n] = true
+		}
+	}
+	if len(exposed) == 0 {
+		return 0
+	}
+
+	count := 0
+	for _, name := range []string{"tcp", "tcp6"} {
+		f, err := os.Open(fmt.Sprintf("/proc/%d/net/%s", pid, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // 表头
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 4 || fields[3] != tcpEstablishedState {
+				continue
+			}
+			local := fields[1]
+			port, err := strconv.ParseUint(local[strings.LastIndex(local, ":")+1:], 16, 16)
+			if err == nil && exposed[port] {
+				count++
+			}
+		}
+		f.Close()
+	}
+	return count
+}
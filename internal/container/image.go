@@ -0,0 +1,161 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"utopia-node-agent/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// CommitOptions 容器快照选项
+type CommitOptions struct {
+	Message string
+	Author  string
+	// 提交期间暂停容器，保证文件系统一致
+	Pause bool
+}
+
+// PushProgress 推送进度回调：已完成层数、总层数、原始输出行
+type PushProgress func(done, total int, line string)
+
+var (
+	// 形如 "5f70bf18a086: Pushing [==>   ]" 的层状态行
+	pushLayerPattern = regexp.MustCompile(`^([0-9a-f]{12}): (.+)$`)
+	// 形如 "latest: digest: sha256:... size: 1234" 的完成行
+	pushDigestPattern = regexp.MustCompile(`digest: (sha256:[0-9a-f]{64})`)
+)
+
+// CommitContainer 将受管容器提交为镜像
+func (m *Manager) CommitContainer(ctx context.Context, containerID, image string, opts CommitOptions) (err error) {
+	ctx, span := tracing.Start(ctx, "container.Commit",
+		attribute.String("container.id", containerID),
+		attribute.String("container.image", image),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if _, exists := m.GetContainer(containerID); !exists {
+		return fmt.Errorf("container %s is not managed by this agent", containerID)
+	}
+
+	args := []string{"commit", fmt.Sprintf("--pause=%t", opts.Pause)}
+	if opts.Message != "" {
+		args = append(args, "--message", opts.Message)
+	}
+	if opts.Author != "" {
+		args = append(args, "--author", opts.Author)
+	}
+	args = append(args, containerID, image)
+
+	if output, err := dockerCommand(ctx, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to commit container: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// DefaultImageReference 返回claim快照的默认镜像引用
+// 配置了节点仓库时推送到 <server>/<prefix>/claim-<claim_id>
+func (m *Manager) DefaultImageReference(claimID, tag string) string {
+	options := m.getOptions()
+
+	name := "claim-" + strings.ToLower(claimID)
+	if options.RepositoryPrefix != "" {
+		name = strings.Trim(options.RepositoryPrefix, "/") + "/" + name
+	}
+	if options.Registry.Server != "" {
+		name = strings.TrimSuffix(options.Registry.Server, "/") + "/" + name
+	} else if options.RepositoryPrefix == "" {
+		name = "utopia/" + name
+	}
+	return name + ":" + tag
+}
+
+// ValidatePushTarget 验证容器快照的仓库名与标签
+// 推送时未携带凭据的快照只能推送到节点配置的仓库，避免节点凭据被用于其他仓库
+func (m *Manager) ValidatePushTarget(repository, tag string, push, hasAuth bool) error {
+	var fields []FieldError
+	if tag != "" && !imageTagPattern.MatchString(tag) {
+		fields = append(fields, FieldError{Field: "tag", Message: "must be a valid image tag"})
+	}
+	if repository != "" {
+		switch {
+		case len(repository) > maxImageLength:
+			fields = append(fields, FieldError{Field: "repository", Message: fmt.Sprintf("must be at most %d characters", maxImageLength)})
+		case !repositoryPattern.MatchString(repository):
+			fields = append(fields, FieldError{Field: "repository", Message: fmt.Sprintf("invalid repository name %q", repository)})
+		case push && !hasAuth:
+			server := normalizeRegistryServer(m.getOptions().Registry.Server)
+			if registryHost(repository) != server {
+				fields = append(fields, FieldError{
+					Field:   "repository",
+					Message: fmt.Sprintf("must be in the configured registry %s unless registry_auth is provided", server),
+				})
+			}
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// PushImage 推送镜像到仓库，返回镜像摘要；auth为nil时使用节点为该仓库配置的凭据
+// 凭据写入临时的 DOCKER_CONFIG 目录，推送完成后删除，不影响主机docker配置
+func (m *Manager) PushImage(ctx context.Context, image string, auth *RegistryAuth, progress PushProgress) (digest string, err error) {
+	ctx, span := tracing.Start(ctx, "container.PushImage", attribute.String("container.image", image))
+	defer func() { tracing.End(span, err) }()
+
+	env, cleanup, err := registryEnv(m.registryAuthFor(ctx, image, auth))
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	push := dockerCommand(ctx, "push", image)
+	push.Env = append(push.Env, env...)
+	stdout, err := push.StdoutPipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to capture push output: %w", err)
+	}
+	push.Stderr = push.Stdout
+
+	if err := push.Start(); err != nil {
+		return "", fmt.Errorf("failed to start push: %w", err)
+	}
+
+	// 根据各层状态估算进度
+	layers := make(map[string]bool) // layer -> 是否已完成
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if match := pushLayerPattern.FindStringSubmatch(line); match != nil {
+			status := match[2]
+			layers[match[1]] = strings.HasPrefix(status, "Pushed") ||
+				strings.HasPrefix(status, "Layer already exists") ||
+				strings.HasPrefix(status, "Mounted from")
+		}
+		if match := pushDigestPattern.FindStringSubmatch(line); match != nil {
+			digest = match[1]
+		}
+		if progress != nil {
+			done := 0
+			for _, finished := range layers {
+				if finished {
+					done++
+				}
+			}
+			progress(done, len(layers), line)
+		}
+	}
+
+	if err := push.Wait(); err != nil {
+		return "", fmt.Errorf("failed to push image: %w", err)
+	}
+
+	return digest, nil
+}
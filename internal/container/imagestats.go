@@ -0,0 +1,89 @@
+package container
+
+import (
+	"sort"
+	"time"
+)
+
+// maxImageStats 保留耗时统计的镜像数，超出时淘汰最久未使用的镜像
+const maxImageStats = 256
+
+// ImageLatency 一个镜像的拉取与启动耗时统计（自Agent启动起）
+type ImageLatency struct {
+	Image string `json:"image"`
+	// 创建容器时本地没有镜像、由Agent拉取的次数与耗时（秒）
+	Pulls           int     `json:"pulls"`
+	LastPullSeconds float64 `json:"last_pull_seconds"`
+	AvgPullSeconds  float64 `json:"avg_pull_seconds"`
+	// 最近一次拉取时懒加载拉取是否生效
+	LastPullLazy bool `json:"last_pull_lazy"`
+	// docker run（创建并启动容器）的次数与耗时（秒）
+	Starts           int     `json:"starts"`
+	LastStartSeconds float64 `json:"last_start_seconds"`
+	AvgStartSeconds  float64 `json:"avg_start_seconds"`
+	LastUsedAt       int64   `json:"last_used_at"`
+}
+
+// recordImagePull 记录一次镜像拉取耗时
+func (m *Manager) recordImagePull(image string, elapsed time.Duration, lazy bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.imageStatsLocked(image)
+	seconds := elapsed.Seconds()
+	stats.AvgPullSeconds = (stats.AvgPullSeconds*float64(stats.Pulls) + seconds) / float64(stats.Pulls+1)
+	stats.Pulls++
+	stats.LastPullSeconds = seconds
+	stats.LastPullLazy = lazy
+}
+
+// recordImageStart 记录一次容器启动耗时
+func (m *Manager) recordImageStart(image string, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.imageStatsLocked(image)
+	seconds := elapsed.Seconds()
+	stats.AvgStartSeconds = (stats.AvgStartSeconds*float64(stats.Starts) + seconds) / float64(stats.Starts+1)
+	stats.Starts++
+	stats.LastStartSeconds = seconds
+}
+
+// imageStatsLocked 返回镜像的统计记录，不存在时创建（调用方需持有写锁）
+func (m *Manager) imageStatsLocked(image string) *ImageLatency {
+	if m.imageStats == nil {
+		m.imageStats = make(map[string]*ImageLatency)
+	}
+	stats, ok := m.imageStats[image]
+	if !ok {
+		if len(m.imageStats) >= maxImageStats {
+			var oldest *ImageLatency
+			for _, s := range m.imageStats {
+				if oldest == nil || s.LastUsedAt < oldest.LastUsedAt {
+					oldest = s
+				}
+			}
+			delete(m.imageStats, oldest.Image)
+		}
+		stats = &ImageLatency{Image: image}
+		m.imageStats[image] = stats
+	}
+	stats.LastUsedAt = time.Now().Unix()
+	return stats
+}
+
+// ImageLatencies 返回各镜像的拉取与启动耗时统计，按最近使用时间倒序
+func (m *Manager) ImageLatencies() []ImageLatency {
+	m.mu.RLock()
+	result := make([]ImageLatency, 0, len(m.imageStats))
+	for _, stats := range m.imageStats {
+		result = append(result, *stats)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LastUsedAt != result[j].LastUsedAt {
+			return result[i].LastUsedAt > result[j].LastUsedAt
+		}
+		return result[i].Image < result[j].Image
+	})
+	return result
+}
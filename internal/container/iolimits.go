@@ -0,0 +1,162 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrIOLimitsUnsupported 无法确定工作区设备，不能限制磁盘IO
+var ErrIOLimitsUnsupported = errors.New("disk IO limits are not supported on this node")
+
+// IOLimits 容器对工作区设备（Docker数据目录所在的块设备）的读写限速，0表示不限制
+type IOLimits struct {
+	// 每秒读写字节数
+	ReadBPS  int64 `json:"read_bps,omitempty"`
+	WriteBPS int64 `json:"write_bps,omitempty"`
+	// 每秒读写次数
+	ReadIOPS  int64 `json:"read_iops,omitempty"`
+	WriteIOPS int64 `json:"write_iops,omitempty"`
+}
+
+// IsZero 是否未设置任何限速
+func (l IOLimits) IsZero() bool {
+	return l == IOLimits{}
+}
+
+// Validate 验证限速值
+func (l IOLimits) Validate() error {
+	if l.ReadBPS < 0 || l.WriteBPS < 0 || l.ReadIOPS < 0 || l.WriteIOPS < 0 {
+		return fmt.Errorf("IO limits must be non-negative")
+	}
+	return nil
+}
+
+// merge 以other中非0的项覆盖当前限速
+func (l IOLimits) merge(other IOLimits) IOLimits {
+	if other.ReadBPS > 0 {
+		l.ReadBPS = other.ReadBPS
+	}
+	if other.WriteBPS > 0 {
+		l.WriteBPS = other.WriteBPS
+	}
+	if other.ReadIOPS > 0 {
+		l.ReadIOPS = other.ReadIOPS
+	}
+	if other.WriteIOPS > 0 {
+		l.WriteIOPS = other.WriteIOPS
+	}
+	return l
+}
+
+// IOLimitSupport 磁盘IO限速支持情况
+type IOLimitSupport struct {
+	Supported bool `json:"supported"`
+	// 被限速的工作区设备
+	Device string `json:"device,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// 节点默认限速
+	Defaults IOLimits `json:"defaults"`
+}
+
+// detectIODevice 确定工作区设备：配置指定时直接使用，否则取Docker数据目录所在的块设备
+// 分区解析为所在的整块磁盘，cgroup的IO限速只接受整块设备
+func detectIODevice(ctx context.Context, device string) IOLimitSupport {
+	if device != "" {
+		var stat unix.Stat_t
+		if err := unix.Stat(device, &stat); err != nil {
+			return IOLimitSupport{Reason: fmt.Sprintf("failed to stat %s: %v", device, err)}
+		}
+		if stat.Mode&unix.S_IFMT != unix.S_IFBLK {
+			return IOLimitSupport{Reason: fmt.Sprintf("%s is not a block device", device)}
+		}
+		return IOLimitSupport{Supported: true, Device: device}
+	}
+
+	output, err := dockerCommand(ctx, "info", "--format", "{{.DockerRootDir}}").Output()
+	if err != nil {
+		return IOLimitSupport{Reason: fmt.Sprintf("failed to query docker root dir: %v", err)}
+	}
+	root := strings.TrimSpace(string(output))
+	var stat unix.Stat_t
+	if err := unix.Stat(root, &stat); err != nil {
+		return IOLimitSupport{Reason: fmt.Sprintf("failed to stat docker root dir: %v", err)}
+	}
+	major, minor := unix.Major(stat.Dev), unix.Minor(stat.Dev)
+	if major == 0 {
+		// btrfs、zfs、overlay等没有对应块设备的文件系统
+		return IOLimitSupport{Reason: fmt.Sprintf("docker root dir %s is not backed by a block device, set container.io_limits.device", root)}
+	}
+
+	sysPath, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/dev/block/%d:%d", major, minor))
+	if err != nil {
+		return IOLimitSupport{Reason: fmt.Sprintf("failed to resolve block device %d:%d: %v", major, minor, err)}
+	}
+	if _, err := os.Stat(filepath.Join(sysPath, "partition")); err == nil {
+		sysPath = filepath.Dir(sysPath)
+	}
+	name, err := blockDeviceName(sysPath)
+	if err != nil {
+		return IOLimitSupport{Reason: err.Error()}
+	}
+	return IOLimitSupport{Supported: true, Device: "/dev/" + name}
+}
+
+// blockDeviceName 读取sysfs中块设备的设备节点名（如 nvme0n1、dm-0）
+func blockDeviceName(sysPath string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(sysPath, "uevent"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read block device info: %v", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if name, ok := strings.CutPrefix(line, "DEVNAME="); ok {
+			return name, nil
+		}
+	}
+	return filepath.Base(sysPath), nil
+}
+
+// IOLimits 返回请求实际使用的磁盘IO限速：请求中非0的项覆盖节点默认值
+// 不支持限速时节点默认值不生效
+func (m *Manager) IOLimits(req *CreateRequest) IOLimits {
+	var limits IOLimits
+	if m.ioLimits.Supported {
+		limits = m.getOptions().IOLimits
+	}
+	if req.IOLimits != nil {
+		limits = limits.merge(*req.IOLimits)
+	}
+	return limits
+}
+
+// GetIOLimitSupport 获取磁盘IO限速支持情况
+func (m *Manager) GetIOLimitSupport() IOLimitSupport {
+	support := m.ioLimits
+	support.Defaults = m.getOptions().IOLimits
+	return support
+}
+
+// ioLimitArgs 生成限制工作区设备读写速率的 docker run 参数
+func ioLimitArgs(limits IOLimits, device string) []string {
+	var args []string
+	for _, limit := range []struct {
+		flag  string
+		value int64
+	}{
+		{"--device-read-bps", limits.ReadBPS},
+		{"--device-write-bps", limits.WriteBPS},
+		{"--device-read-iops", limits.ReadIOPS},
+		{"--device-write-iops", limits.WriteIOPS},
+	} {
+		if limit.value > 0 {
+			args = append(args, limit.flag, device+":"+strconv.FormatInt(limit.value, 10))
+		}
+	}
+	return args
+}
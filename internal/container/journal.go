@@ -0,0 +1,187 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"utopia-node-agent/internal/store"
+)
+
+// 日志记录的操作类型
+const (
+	OpCreate      = "create"
+	OpCreateBatch = "create_batch"
+	OpRemove      = "remove"
+)
+
+// 中断操作的恢复结果
+const (
+	RecoveryRolledBack = "rolled_back"
+	RecoveryCompleted  = "completed"
+	RecoveryNoop       = "nothing_to_do"
+	RecoveryFailed     = "failed"
+)
+
+// Intent 执行前写入日志的操作意图，操作结束后删除
+// Agent在操作过程中崩溃时意图保留，下次启动时据此完成或回滚
+type Intent struct {
+	ID       string   `json:"id"`
+	Op       string   `json:"op"`
+	ClaimIDs []string `json:"claim_ids"`
+	// 删除操作的容器
+	ContainerID string `json:"container_id,omitempty"`
+	// 创建开始时各claim已存在的容器，回滚时保留
+	Existing  map[string]string `json:"existing,omitempty"`
+	StartedAt int64             `json:"started_at"`
+}
+
+// RecoveryResult 中断操作的恢复结果
+type RecoveryResult struct {
+	Intent
+	Outcome string `json:"outcome"`
+	// 回滚删除或补全删除的容器
+	Containers []string `json:"containers,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// journal 操作意图日志
+type journal struct {
+	store *store.Store
+	seq   atomic.Uint64
+}
+
+// EnableJournal 启用操作意图日志，记录持久化在dir中
+func (m *Manager) EnableJournal(dir string) error {
+	st, err := store.Open(dir)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.journal = &journal{store: st}
+	m.mu.Unlock()
+	return nil
+}
+
+// beginIntent 在执行操作前写入意图，返回结束操作时调用的函数
+// 日志未启用或写入失败时不阻止操作
+func (m *Manager) beginIntent(op string, claimIDs []string, containerID string) func() {
+	m.mu.RLock()
+	j := m.journal
+	intent := Intent{Op: op, ClaimIDs: claimIDs, ContainerID: containerID, StartedAt: time.Now().Unix()}
+	if op != OpRemove {
+		for _, info := range m.containers {
+			for _, claimID := range claimIDs {
+				if info.ClaimID == claimID {
+					if intent.Existing == nil {
+						intent.Existing = make(map[string]string)
+					}
+					intent.Existing[claimID] = info.ID
+				}
+			}
+		}
+	}
+	m.mu.RUnlock()
+	if j == nil {
+		return func() {}
+	}
+
+	intent.ID = fmt.Sprintf("%d-%d", time.Now().UnixNano(), j.seq.Add(1))
+	if err := j.store.Put(intent.ID, &intent); err != nil {
+		fmt.Printf("Warning: failed to journal %s operation: %v\n", op, err)
+		return func() {}
+	}
+	return func() {
+		if err := j.store.Delete(intent.ID); err != nil {
+			fmt.Printf("Warning: failed to clear journaled %s operation: %v\n", op, err)
+		}
+	}
+}
+
+// RecoverIntents 完成或回滚上次运行中断的操作
+// 中断的创建会删除已创建的容器（创建结果未返回给调用方），中断的删除会补全删除
+// 无法列出容器时保留全部意图，下次启动时再恢复
+func (m *Manager) RecoverIntents(ctx context.Context) ([]RecoveryResult, error) {
+	m.mu.RLock()
+	j := m.journal
+	m.mu.RUnlock()
+	if j == nil {
+		return nil, nil
+	}
+
+	keys, err := j.store.Keys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if err := m.RefreshContainers(ctx); err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	var results []RecoveryResult
+	for _, key := range keys {
+		var intent Intent
+		if ok, err := j.store.Get(key, &intent); err != nil || !ok {
+			fmt.Printf("Warning: dropping unreadable journal entry %s: %v\n", key, err)
+			j.store.Delete(key)
+			continue
+		}
+
+		result := m.recoverIntent(ctx, intent)
+		if result.Outcome != RecoveryFailed {
+			j.store.Delete(key)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// recoverIntent 恢复单个中断的操作
+func (m *Manager) recoverIntent(ctx context.Context, intent Intent) RecoveryResult {
+	result := RecoveryResult{Intent: intent, Outcome: RecoveryNoop}
+
+	var targets []string
+	switch intent.Op {
+	case OpRemove:
+		if info, exists := m.GetContainer(intent.ContainerID); exists {
+			targets = append(targets, info.ID)
+		}
+		for _, claimID := range intent.ClaimIDs {
+			m.cleanupSecrets(containerNameFor(claimID))
+			m.releaseExternalVolumes(containerNameFor(claimID))
+		}
+	default:
+		for _, claimID := range intent.ClaimIDs {
+			for _, info := range m.ListContainers() {
+				if info.ClaimID == claimID && info.ID != intent.Existing[claimID] {
+					targets = append(targets, info.ID)
+				}
+			}
+			if intent.Existing[claimID] == "" {
+				m.cleanupSecrets(containerNameFor(claimID))
+				m.releaseExternalVolumes(containerNameFor(claimID))
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return result
+	}
+
+	for _, id := range targets {
+		if err := m.RemoveContainer(ctx, id); err != nil {
+			result.Outcome = RecoveryFailed
+			result.Error = err.Error()
+			return result
+		}
+		result.Containers = append(result.Containers, id)
+	}
+	if intent.Op == OpRemove {
+		result.Outcome = RecoveryCompleted
+	} else {
+		result.Outcome = RecoveryRolledBack
+	}
+	return result
+}
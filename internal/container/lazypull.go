@@ -0,0 +1,179 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// 懒加载快照器
+const (
+	SnapshotterStargz = "stargz"
+	SnapshotterNydus  = "nydus"
+)
+
+// containerdSnapshotterType docker使用containerd镜像存储时 DriverStatus 中的驱动类型
+const containerdSnapshotterType = "io.containerd.snapshotter.v1"
+
+// defaultSnapshotterAddresses 快照器gRPC套接字的默认路径
+var defaultSnapshotterAddresses = map[string]string{
+	SnapshotterStargz: "/run/containerd-stargz-grpc/containerd-stargz-grpc.sock",
+	SnapshotterNydus:  "/run/containerd-nydus/containerd-nydus-grpc.sock",
+}
+
+// LazyPullOptions 懒加载拉取选项
+type LazyPullOptions struct {
+	// stargz 或 nydus
+	Snapshotter string
+	// 快照器gRPC套接字，为空时使用默认路径
+	Address string
+	// 写入containerd代理插件配置的目录，为空时不管理containerd配置
+	ContainerdConfigDir string
+}
+
+// LazyPullStatus 懒加载拉取的生效情况
+type LazyPullStatus struct {
+	Snapshotter string `json:"snapshotter"`
+	Address     string `json:"address"`
+	// docker使用containerd镜像存储且存储驱动为该快照器，eStargz/nydus镜像按需加载
+	Active bool `json:"active"`
+	// 快照器套接字是否存在
+	SnapshotterRunning bool `json:"snapshotter_running"`
+	// Agent管理的containerd代理插件配置文件
+	ConfigFile string `json:"config_file,omitempty"`
+	// 本次启动写入或更新了containerd配置，需重启containerd与docker后生效
+	RestartRequired bool   `json:"restart_required,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+}
+
+// lazyPuller 懒加载拉取状态
+type lazyPuller struct {
+	options         LazyPullOptions
+	configFile      string
+	restartRequired bool
+	// 最近一次检测是否生效，用于标记拉取记录
+	active bool
+}
+
+// EnableLazyPull 启用懒加载拉取：按需写入containerd代理插件配置并检测docker是否已使用该快照器
+// 未生效时容器仍正常创建，镜像完整拉取
+func (m *Manager) EnableLazyPull(ctx context.Context, options LazyPullOptions) (LazyPullStatus, error) {
+	if options.Address == "" {
+		options.Address = defaultSnapshotterAddresses[options.Snapshotter]
+	}
+	lazy := &lazyPuller{options: options}
+	if options.ContainerdConfigDir != "" {
+		lazy.configFile = filepath.Join(options.ContainerdConfigDir, "utopia-"+options.Snapshotter+".toml")
+		changed, err := writeProxyPluginConfig(lazy.configFile, options)
+		if err != nil {
+			return LazyPullStatus{}, err
+		}
+		lazy.restartRequired = changed
+	}
+
+	m.mu.Lock()
+	m.lazyPull = lazy
+	m.mu.Unlock()
+	return m.LazyPullStatus(ctx), nil
+}
+
+// LazyPullStatus 检测懒加载拉取的生效情况，未启用时返回零值
+func (m *Manager) LazyPullStatus(ctx context.Context) LazyPullStatus {
+	m.mu.RLock()
+	lazy := m.lazyPull
+	m.mu.RUnlock()
+	if lazy == nil {
+		return LazyPullStatus{}
+	}
+
+	status := LazyPullStatus{
+		Snapshotter:     lazy.options.Snapshotter,
+		Address:         lazy.options.Address,
+		ConfigFile:      lazy.configFile,
+		RestartRequired: lazy.restartRequired,
+	}
+	if _, err := os.Stat(status.Address); err == nil {
+		status.SnapshotterRunning = true
+	}
+
+	info, err := queryDockerInfo(ctx)
+	switch {
+	case err != nil:
+		status.Reason = err.Error()
+	case !info.usesContainerdSnapshotter():
+		status.Reason = `docker is not using the containerd image store; set "features": {"containerd-snapshotter": true} in daemon.json`
+	case info.Driver != status.Snapshotter:
+		status.Reason = fmt.Sprintf(`docker storage driver is %q; set "storage-driver": %q in daemon.json`, info.Driver, status.Snapshotter)
+	case !status.SnapshotterRunning:
+		status.Reason = fmt.Sprintf("snapshotter socket %s not found", status.Address)
+	default:
+		status.Active = true
+		status.RestartRequired = false
+	}
+
+	m.mu.Lock()
+	lazy.active = status.Active
+	m.mu.Unlock()
+	return status
+}
+
+// lazyPullActive 最近一次检测时懒加载拉取是否生效
+func (m *Manager) lazyPullActive() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lazyPull != nil && m.lazyPull.active
+}
+
+// queryDockerInfo 查询docker的存储配置
+func queryDockerInfo(ctx context.Context) (dockerInfo, error) {
+	var info dockerInfo
+	output, err := dockerCommand(ctx, "info", "--format", "{{json .}}").Output()
+	if err != nil {
+		return info, fmt.Errorf("failed to query docker info: %v", err)
+	}
+	if err := json.Unmarshal(output, &info); err != nil {
+		return info, fmt.Errorf("failed to parse docker info: %v", err)
+	}
+	return info, nil
+}
+
+// usesContainerdSnapshotter docker是否使用containerd镜像存储
+func (info dockerInfo) usesContainerdSnapshotter() bool {
+	for _, status := range info.DriverStatus {
+		if status[0] == "driver-type" && status[1] == containerdSnapshotterType {
+			return true
+		}
+	}
+	return false
+}
+
+// writeProxyPluginConfig 写入containerd代理插件配置，内容变化时返回true
+// containerd主配置需通过 imports 包含该文件所在目录
+func writeProxyPluginConfig(path string, options LazyPullOptions) (bool, error) {
+	content := []byte(fmt.Sprintf(`# Generated by utopia-node-agent: lazy pulling through the %[1]s snapshotter.
+# Include this directory from /etc/containerd/config.toml, e.g. imports = ["%[2]s/*.toml"].
+[proxy_plugins]
+  [proxy_plugins.%[1]s]
+    type = "snapshot"
+    address = %[3]q
+`, options.Snapshotter, filepath.Dir(path), options.Address))
+
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, content) {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("failed to create containerd config directory: %w", err)
+	}
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, content, 0644); err != nil {
+		return false, fmt.Errorf("failed to write snapshotter config: %w", err)
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		os.Remove(tmpFile)
+		return false, fmt.Errorf("failed to write snapshotter config: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,69 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// LogOptions 容器日志查询选项
+type LogOptions struct {
+	// 只返回最后Tail行，0表示全部
+	Tail int
+	// 只返回该时间之后的日志，零值表示不限制
+	Since time.Time
+	// 持续输出新日志直到容器停止或读取方关闭
+	Follow bool
+	// 每行前加RFC3339Nano时间戳
+	Timestamps bool
+}
+
+// logStream docker logs 的输出，关闭时结束命令
+type logStream struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+// Close 结束docker logs
+func (s *logStream) Close() error {
+	s.cancel()
+	return s.PipeReader.Close()
+}
+
+// ContainerLogs 返回受管容器的stdout与stderr（合并为一个流），调用方需关闭返回的流
+func (m *Manager) ContainerLogs(ctx context.Context, containerID string, opts LogOptions) (io.ReadCloser, error) {
+	info, exists := m.GetContainer(containerID)
+	if !exists {
+		return nil, fmt.Errorf("container %s not found", containerID)
+	}
+
+	args := []string{"logs"}
+	if opts.Tail > 0 {
+		args = append(args, "--tail", strconv.Itoa(opts.Tail))
+	}
+	if !opts.Since.IsZero() {
+		args = append(args, "--since", opts.Since.UTC().Format(time.RFC3339Nano))
+	}
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	if opts.Timestamps {
+		args = append(args, "--timestamps")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := dockerCommand(ctx, append(args, info.ID)...)
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to read container logs: %w", err)
+	}
+	go func() {
+		writer.CloseWithError(cmd.Wait())
+	}()
+	return &logStream{PipeReader: reader, cancel: cancel}, nil
+}
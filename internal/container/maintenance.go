@@ -0,0 +1,59 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"utopia-node-agent/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RunningGPUContainers 返回正在运行且分配了GPU的受管容器
+func (m *Manager) RunningGPUContainers() []ContainerInfo {
+	var result []ContainerInfo
+	for _, info := range m.ListContainers() {
+		if info.Status == "running" && len(info.GPUIDs) > 0 {
+			result = append(result, info)
+		}
+	}
+	return result
+}
+
+// StartContainer 启动已停止的受管容器，MPS共享容器先启动其GPU上的守护进程
+func (m *Manager) StartContainer(ctx context.Context, containerID string) (err error) {
+	ctx, span := tracing.Start(ctx, "container.Start", attribute.String("container.id", containerID))
+	defer func() { tracing.End(span, err) }()
+
+	info, exists := m.GetContainer(containerID)
+	if !exists {
+		return fmt.Errorf("container %s is not managed by this agent", containerID)
+	}
+	// docker按创建时的索引挂载GPU，GPU热插拔后需重新创建claim
+	if m.gpusReindexed(info) {
+		return fmt.Errorf("%w: recorded GPUs %s, now %v", ErrGPUsReindexed, info.Labels["utopia.gpu_ids"], info.GPUIDs)
+	}
+
+	if info.Labels[mpsLabel] == "true" && len(info.GPUIDs) > 0 {
+		mps := m.mpsManager()
+		if mps == nil {
+			return ErrMPSDisabled
+		}
+		if err := mps.Start(ctx, info.GPUIDs[0]); err != nil {
+			return err
+		}
+	}
+
+	if output, err := dockerCommand(ctx, "start", containerID).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to start container: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return m.RefreshContainer(ctx, containerID)
+}
+
+// StopAllMPS 停止所有MPS守护进程，用于驱动维护前释放GPU
+func (m *Manager) StopAllMPS(ctx context.Context) {
+	if mps := m.mpsManager(); mps != nil {
+		mps.StopAll(ctx)
+	}
+}
@@ -3,31 +3,97 @@ package container
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"utopia-node-agent/internal/dataset"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/features"
+	"utopia-node-agent/internal/gpu"
+	"utopia-node-agent/internal/hooks"
+	"utopia-node-agent/internal/mounts"
+	"utopia-node-agent/internal/redact"
+	"utopia-node-agent/internal/secrets"
+	"utopia-node-agent/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // CreateRequest 容器创建请求
 type CreateRequest struct {
-	ClaimID      string            `json:"claim_id" binding:"required"`
-	Image        string            `json:"image" binding:"required"`
-	GPUCount     int               `json:"gpu_count" binding:"required"` // 只需要指定GPU数量
+	ClaimID  string `json:"claim_id" binding:"required"`
+	Image    string `json:"image" binding:"required"`
+	GPUCount int    `json:"gpu_count"` // 只需要指定GPU数量，0表示仅CPU
+	// GPU数量，取代已弃用的gpu_count，API层合并到GPUCount
+	GPUs         *int              `json:"gpus,omitempty"`
 	PortMappings []PortMapping     `json:"port_mappings"`
 	EnvVars      []string          `json:"env_vars"`
 	Command      []string          `json:"command,omitempty"`
 	WorkingDir   string            `json:"working_dir,omitempty"`
 	Volumes      map[string]string `json:"volumes,omitempty"`
+	// 可写层大小限制（GB），0表示使用节点默认值
+	StorageSizeGB int `json:"storage_size_gb,omitempty"`
+	// CPU核数与内存（MB）限制，按节点超分策略准入，0表示不限制（按默认值计入承诺量）
+	CPUs     float64 `json:"cpus,omitempty"`
+	MemoryMB int     `json:"memory_mb,omitempty"`
+	// 只读挂载的共享数据集
+	Datasets []dataset.Ref `json:"datasets,omitempty"`
+	// 由Agent在主机上挂载后绑定挂载到容器的外部存储（NFS、S3、SMB）
+	ExternalVolumes []mounts.Volume `json:"external_volumes,omitempty"`
+	// 重启策略，默认 unless-stopped
+	RestartPolicy *RestartPolicy `json:"restart_policy,omitempty"`
+	// DNS与主机名选项
+	NetworkOptions
+	// 加密下发的密钥，以环境变量或只读文件注入
+	Secrets []secrets.Secret `json:"secrets,omitempty"`
+	// 申请放宽的安全限制，需节点策略允许
+	SecurityRelaxations []string `json:"security_relaxations,omitempty"`
+	// 通过NVIDIA MPS与其他容器共享GPU
+	SharedCompute *SharedComputeOptions `json:"shared_compute,omitempty"`
+	// 计划启动时间（Unix秒），晚于当前时间时按计划启动，之前预拉取镜像并预留GPU
+	StartAt int64 `json:"start_at,omitempty"`
+	// 容器运行时：runc（默认）、gvisor 或 kata
+	RuntimeClass string `json:"runtime_class,omitempty"`
+	// 多GPU时优先选择通过NVLink互联的GPU（已默认按互联拓扑选择，保留以兼容旧请求）
+	PreferNVLink bool `json:"prefer_nvlink,omitempty"`
+	// 平台附加的任意元数据（如用户ID、套餐、显示名称），随容器信息、事件与计费记录返回
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// 拉取私有镜像的短期凭据，只保存在内存中；为空时使用节点为镜像所在仓库配置的凭据
+	RegistryAuth *RegistryAuth `json:"registry_auth,omitempty"`
+	// 运行时长上限（秒），自容器创建起计时，到期后Agent停止并删除容器；0表示不限制
+	MaxRuntimeSeconds int `json:"max_runtime_seconds,omitempty"`
+	// 覆盖节点默认值的ulimit（nofile、nproc、core、memlock、stack）
+	Ulimits map[string]Ulimit `json:"ulimits,omitempty"`
+	// 以时间片与其他容器共享一块GPU时占用的份额（如0.5），需要gpu_count为1
+	GPUFraction float64 `json:"gpu_fraction,omitempty"`
+	// 对工作区设备的读写限速，非0的项覆盖节点默认值
+	IOLimits *IOLimits `json:"io_limits,omitempty"`
+	// 发起创建的子租户，由API层根据令牌设置，记录在 utopia.tenant 标签中
+	Tenant string `json:"-"`
 }
 
+// TenantLabel 记录容器所属子租户的标签
+const TenantLabel = "utopia.tenant"
+
+// storageSizeLabel 记录可写层大小限制（GB）的标签
+const storageSizeLabel = "utopia.storage_size_gb"
+
 // PortMapping 端口映射
 type PortMapping struct {
-	HostPort      int    `json:"host_port" binding:"required"`
+	// 为0时按节点端口划分分配
+	HostPort      int    `json:"host_port"`
 	ContainerPort int    `json:"container_port" binding:"required"`
 	Protocol      string `json:"protocol,omitempty"` // tcp, udp
+	// 端口名称（如 jupyter、ssh），发布端口时必填
+	Name string `json:"name,omitempty"`
+	// 通过FRP隧道对外发布：http 或 tcp，为空表示不发布
+	Publish string `json:"publish,omitempty"`
 }
 
 // ContainerInfo 容器信息
@@ -41,13 +107,36 @@ type ContainerInfo struct {
 	Created int64             `json:"created"`
 	Started int64             `json:"started"`
 	Labels  map[string]string `json:"labels"`
+	// 容器运行时类别
+	RuntimeClass string `json:"runtime_class"`
+	// 通过隧道对外发布的端口
+	PublishedPorts []PublishedPort `json:"published_ports,omitempty"`
+	// 容器IP与在节点本地解析器中登记的名称（启用claim DNS时）
+	IPAddress string `json:"ip_address,omitempty"`
+	DNSName   string `json:"dns_name,omitempty"`
+	// 创建时的GPU选择依据，共享计算及早于评分选择创建的容器没有
+	GPUSelection *gpu.Selection `json:"gpu_selection,omitempty"`
+	// 创建请求中平台附加的元数据
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+
+	RestartPolicy RestartPolicy `json:"restart_policy"`
+	RestartCount  int           `json:"restart_count"`
+	// claim休眠中时的休眠记录
+	Hibernation *Hibernation `json:"hibernation,omitempty"`
+	// 运行中claim的活动信号与空闲状态（启用空闲检测时）
+	Activity *Activity `json:"activity,omitempty"`
+	// 按运行时长上限到期的时间（Unix秒），不限制时为0
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	// 时间片共享时占用的GPU份额，独占GPU时为0
+	GPUFraction float64 `json:"gpu_fraction,omitempty"`
 }
 
 // DockerContainer Docker容器信息结构（用于解析docker inspect输出）
 type DockerContainer struct {
-	ID      string `json:"Id"`
-	Created string `json:"Created"`
-	State   struct {
+	ID           string `json:"Id"`
+	Created      string `json:"Created"`
+	RestartCount int    `json:"RestartCount"`
+	State        struct {
 		Status     string `json:"Status"`
 		StartedAt  string `json:"StartedAt"`
 		FinishedAt string `json:"FinishedAt"`
@@ -57,67 +146,422 @@ type DockerContainer struct {
 		Labels map[string]string `json:"Labels"`
 		Cmd    []string          `json:"Cmd"`
 	} `json:"Config"`
+	HostConfig struct {
+		RestartPolicy struct {
+			Name              string `json:"Name"`
+			MaximumRetryCount int    `json:"MaximumRetryCount"`
+		} `json:"RestartPolicy"`
+	} `json:"HostConfig"`
 	NetworkSettings struct {
 		Ports map[string][]struct {
 			HostIP   string `json:"HostIp"`
 			HostPort string `json:"HostPort"`
 		} `json:"Ports"`
+		IPAddress string `json:"IPAddress"`
+		Networks  map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
 	} `json:"NetworkSettings"`
 }
 
 // Manager 容器管理器
 type Manager struct {
-	mu         sync.RWMutex
-	containers map[string]ContainerInfo // containerID -> ContainerInfo
-	gpuMonitor GPUMonitor               // GPU监控器接口
+	mu           sync.RWMutex
+	containers   map[string]ContainerInfo // containerID -> ContainerInfo
+	gpuMonitor   GPUMonitor               // GPU监控器接口
+	options      Options
+	storageQuota StorageQuotaSupport
+	// 工作区设备与磁盘IO限速支持情况
+	ioLimits IOLimitSupport
+	// 可用的运行时类别 -> docker运行时名称
+	runtimes map[string]string
+	datasets *dataset.Cache
+	events   *events.Bus
+	// 外部存储卷挂载（未启用时为nil）
+	mounter *mounts.Manager
+	// 密钥注入（未启用时keypair为nil）
+	secretsKeypair *secrets.Keypair
+	secretsDir     string
+	restarts       map[string]*restartTracker // containerID -> 重启历史
+	// MPS共享计算（未启用时为nil）
+	mps *gpu.MPSManager
+	// 生命周期钩子（未配置时为nil）
+	hooks *hooks.Runner
+	// 定时启动（未启用时为nil）
+	schedules *scheduler
+	// claim端口发布（未启用时为nil）
+	publisher PortPublisher
+	// claim之间的GPU清理校验（未启用时为nil）
+	cleaner GPUCleaner
+	// 操作意图日志（未启用时为nil）
+	journal *journal
+	// 休眠中的claim（未启用时为nil）
+	hibernations *hibernations
+	// claim DNS名称登记（未启用时为nil）
+	dns   ClaimDNS
+	dnsMu sync.Mutex
+	// 运行时功能开关（未设置时使用默认值）
+	features *features.Flags
+	// 敏感值遮盖（未设置时不遮盖）
+	redactor *redact.Redactor
+	// 运行中容器的活动跟踪（containerID -> 跟踪状态）
+	activity map[string]*activityTracker
+	// 有运行时长上限的容器已发布的到期警告（containerID -> 状态）
+	runtimeLimits map[string]*runtimeLimitState
+	// 创建中的请求计入的资源承诺量
+	admitMu   sync.Mutex
+	admitting map[*CreateRequest]resourceDemand
+	// 镜像构建状态与当前构建器的资源限制
+	buildMu    sync.Mutex
+	building   bool
+	builderKey string
+	// claim元数据索引
+	metadata claimMetadata
+	// Docker数据目录（首次查询后缓存）
+	dockerRoot string
+	// 懒加载拉取（未启用时为nil）
+	lazyPull *lazyPuller
+	// 镜像拉取与启动耗时统计
+	imageStats map[string]*ImageLatency
+	// 核心转储收集（未启用时为nil）
+	coreDumps *coreDumps
+	// 创建中的容器已分配的主机端口（"端口/协议" -> claim ID）
+	portReservations map[string]string
+	// 各claim按进程统计的GPU利用率
+	gpuUtil claimUtilization
+	// 全量刷新互斥；刷新期间单个容器缓存的修改（containerID -> 修改代数）在刷新结束时合并
+	refreshMu    sync.Mutex
+	cacheGen     uint64
+	cacheChanges map[string]uint64
+	// Docker事件流本次连接的建立时间，未连接时为零值
+	eventStreamSince time.Time
+}
+
+// Options 容器管理器选项
+type Options struct {
+	// 容器可写层默认大小限制（GB），0表示不限制
+	DefaultStorageSizeGB int
+	// 节点镜像仓库凭据与快照镜像的仓库前缀
+	Registry         RegistryAuth
+	RepositoryPrefix string
+	// 其他仓库的节点凭据，拉取与推送时按镜像所在仓库选择
+	RegistryCredentials []RegistryAuth
+	// 崩溃循环判定阈值
+	CrashLoop CrashLoopPolicy
+	// 刷新容器列表时的最大并发查询数
+	RefreshConcurrency int
+	// 创建容器的时限，0表示不限制
+	CreateTimeout time.Duration
+	// 默认DNS选项与主机名模式
+	Network         NetworkOptions
+	HostnamePattern string
+	NodeID          string
+	// 强制访问控制策略
+	Security SecurityPolicy
+	// 容器入口点与命令的拒绝/允许规则
+	Command CommandPolicy
+	// 每个GPU上MPS客户端容器的上限，0表示不限制
+	MPSMaxClientsPerGPU int
+	// CPU、内存与共享GPU显存的超分策略
+	Overcommit OvercommitPolicy
+	// 为宿主机系统与Agent保留、不参与调度的资源
+	SystemReserved SystemReservation
+	// 定时启动策略
+	Schedule SchedulePolicy
+	// 镜像构建的开关与资源限制
+	Build BuildPolicy
+	// 允许挂载到容器的主机目录
+	AllowedVolumeRoots []string
+	// 添加到每个容器的节点标签
+	Labels map[string]string
+	// claim休眠时默认是否保留GPU
+	HibernateKeepGPUs bool
+	// 容器默认ulimit，请求可覆盖
+	Ulimits map[string]Ulimit
+	// 容器对工作区设备的默认读写限速，请求可覆盖
+	IOLimits IOLimits
+	// 指定被限速的工作区设备，为空时使用Docker数据目录所在的块设备；仅在创建管理器时生效
+	IODevice string
+	// 主机端口划分
+	Ports PortPlan
+	// GPU时间片共享策略
+	TimeSlicing TimeSlicingPolicy
+	// 按进程统计claim GPU利用率的间隔，0表示不统计
+	UtilizationInterval time.Duration
 }
 
+// defaultRefreshConcurrency 默认的容器刷新并发数
+const defaultRefreshConcurrency = 8
+
 // GPUMonitor GPU监控器接口
 type GPUMonitor interface {
 	GetAvailableGPUs() []int
 	IsGPUInUse(gpuID int) bool
+	GetGPUByID(gpuID int) (gpu.GPUInfo, bool)
+	// SelectGPUs 从候选GPU中按拓扑、温度与历史利用率选出count块GPU并返回选择依据
+	SelectGPUs(candidates []int, count int) gpu.Selection
+	// IndexOfUUID 返回指定UUID的GPU的当前索引，GPU已被移除时返回false
+	IndexOfUUID(uuid string) (int, bool)
+	Available() bool
+	// ProcessUtilization 统计since之后各进程在GPU上的平均SM利用率
+	ProcessUtilization(gpuID int, since time.Time) ([]gpu.ProcessUtilization, error)
 }
 
 // NewManager 创建新的容器管理器
-func NewManager(gpuMonitor GPUMonitor) (*Manager, error) {
+func NewManager(gpuMonitor GPUMonitor, options Options) (*Manager, error) {
 	// 检查Docker是否可用
 	if err := exec.Command("docker", "version").Run(); err != nil {
 		return nil, fmt.Errorf("docker is not available: %w", err)
 	}
 
+	// 检测可写层配额支持
+	storageQuota := detectStorageQuotaSupport(context.Background())
+	if options.DefaultStorageSizeGB > 0 && !storageQuota.Supported {
+		fmt.Printf("Warning: default storage size limit disabled: %s\n", storageQuota.Reason)
+	}
+
+	// 确定磁盘IO限速的工作区设备
+	ioLimits := detectIODevice(context.Background(), options.IODevice)
+	if !options.IOLimits.IsZero() && !ioLimits.Supported {
+		fmt.Printf("Warning: default disk IO limits disabled: %s\n", ioLimits.Reason)
+	}
+
 	return &Manager{
-		containers: make(map[string]ContainerInfo),
-		gpuMonitor: gpuMonitor,
+		containers:   make(map[string]ContainerInfo),
+		restarts:     make(map[string]*restartTracker),
+		gpuMonitor:   gpuMonitor,
+		options:      options,
+		storageQuota: storageQuota,
+		ioLimits:     ioLimits,
+		runtimes:     detectRuntimes(context.Background()),
 	}, nil
 }
 
+// UpdateOptions 更新容器管理器选项，对之后创建的容器生效
+func (m *Manager) UpdateOptions(options Options) {
+	m.mu.Lock()
+	m.options = options
+	m.mu.Unlock()
+}
+
+// getOptions 获取当前选项
+func (m *Manager) getOptions() Options {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.options
+}
+
+// StorageSizeGB 返回请求实际使用的可写层大小限制（GB），0表示不限制
+func (m *Manager) StorageSizeGB(req *CreateRequest) int {
+	if req.StorageSizeGB == 0 && m.storageQuota.Supported {
+		return m.getOptions().DefaultStorageSizeGB
+	}
+	return req.StorageSizeGB
+}
+
+// Tenant 返回容器所属的子租户，平台令牌创建的容器为空
+func (info ContainerInfo) Tenant() string {
+	return info.Labels[TenantLabel]
+}
+
+// StorageSizeGB 返回容器的可写层大小限制（GB），0表示不限制
+func (info ContainerInfo) StorageSizeGB() int {
+	size, _ := strconv.Atoi(info.Labels[storageSizeLabel])
+	return size
+}
+
+// GetStorageQuotaSupport 获取可写层配额支持情况
+func (m *Manager) GetStorageQuotaSupport() StorageQuotaSupport {
+	return m.storageQuota
+}
+
 // Close 关闭管理器
 func (m *Manager) Close() error {
 	return nil
 }
 
 // CreateContainer 创建并启动容器
-func (m *Manager) CreateContainer(ctx context.Context, req *CreateRequest) (string, error) {
-	// 1. 自动分配可用的GPU
+func (m *Manager) CreateContainer(ctx context.Context, req *CreateRequest) (containerID string, err error) {
+	ctx, span := tracing.Start(ctx, "container.Create",
+		attribute.String("utopia.claim_id", req.ClaimID),
+		attribute.String("container.image", req.Image),
+		attribute.Int("utopia.gpu_count", req.GPUCount),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if err := m.ValidateCreateRequest(req); err != nil {
+		return "", err
+	}
+	if err := m.checkPortConflicts(req); err != nil {
+		return "", err
+	}
+	if err := m.checkCommandPolicy(ctx, req); err != nil {
+		return "", err
+	}
+
+	// 记录操作意图，创建过程中崩溃时下次启动回滚
+	defer m.beginIntent(OpCreate, []string{req.ClaimID}, "")()
+
+	// 限制整个创建流程的时间，超时后清理未完成的容器
+	if timeout := m.getOptions().CreateTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		defer func() {
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err = m.handleCreateTimeout(req, timeout, err)
+			}
+		}()
+	}
+
+	// 1. 按超分策略准入，自动分配可用的GPU，共享计算与时间片共享请求优先复用已共享且余量足够的GPU
 	availableGPUs := m.gpuMonitor.GetAvailableGPUs()
-	if len(availableGPUs) < req.GPUCount {
-		return "", fmt.Errorf("insufficient available GPUs: need %d, only %d available",
-			req.GPUCount, len(availableGPUs))
+	demand, release, err := m.admit(ctx, req, availableGPUs)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	var allocatedGPUs []int
+	var selection gpu.Selection
+	if req.SharedCompute != nil {
+		allocatedGPUs = []int{demand.sharedGPU}
+	} else if req.GPUFraction > 0 {
+		allocatedGPUs = []int{demand.slicedGPU}
+	} else {
+		// 为其他claim定时启动预留的GPU不可分配
+		availableGPUs = m.withoutSharedGPUs(availableGPUs, m.timeSlicedGPUs())
+		if free := len(availableGPUs) - m.ReservedGPUs(req.ClaimID); free < req.GPUCount {
+			return "", fmt.Errorf("insufficient available GPUs: need %d, only %d available",
+				req.GPUCount, max(free, 0))
+		}
+
+		// 按互联拓扑、温度余量与历史利用率选择，选择依据记录在容器标签中
+		selection = m.gpuMonitor.SelectGPUs(availableGPUs, req.GPUCount)
+		allocatedGPUs = selection.GPUIDs
+	}
+
+	options := m.getOptions()
+
+	// 确定可写层大小限制
+	storageSizeGB := m.StorageSizeGB(req)
+	if storageSizeGB > 0 && !m.storageQuota.Supported {
+		return "", fmt.Errorf("%w: %s", ErrStorageQuotaUnsupported, m.storageQuota.Reason)
+	}
+
+	// 确定工作区设备的读写限速
+	ioLimits := m.IOLimits(req)
+	if !ioLimits.IsZero() && !m.ioLimits.Supported {
+		return "", fmt.Errorf("%w: %s", ErrIOLimitsUnsupported, m.ioLimits.Reason)
 	}
 
-	// 选择前N个可用GPU
-	allocatedGPUs := availableGPUs[:req.GPUCount]
+	// 确定安全配置
+	securityOpts, err := securityArgs(req.SecurityRelaxations, options.Security)
+	if err != nil {
+		return "", err
+	}
+
+	// 确定运行时
+	runtimeOpts, err := m.runtimeArgs(req.RuntimeClass)
+	if err != nil {
+		return "", err
+	}
+
+	// 执行创建前钩子
+	if err := m.runHooks(ctx, hooks.Context{
+		Stage:   hooks.PreCreate,
+		ClaimID: req.ClaimID,
+		Image:   req.Image,
+		GPUIDs:  allocatedGPUs,
+	}); err != nil {
+		return "", err
+	}
+
+	// 准备共享数据集
+	datasetArgs, datasetKeys, err := m.prepareDatasets(ctx, req.Datasets)
+	if err != nil {
+		return "", err
+	}
+
+	// 子租户只能挂载自己的主机目录，命名卷只能由所属claim挂载
+	if err := m.checkTenantHostPaths(req); err != nil {
+		return "", err
+	}
+	if err := m.prepareNamedVolumes(ctx, req); err != nil {
+		return "", err
+	}
+
+	// 解密密钥
+	containerName := containerNameFor(req.ClaimID)
+	secretArgs, secretEnv, err := m.prepareSecrets(containerName, req.Secrets)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			m.cleanupSecrets(containerName)
+		}
+	}()
+
+	// 挂载外部存储卷，凭据与文件密钥一样经节点加密公钥下发
+	externalVolumeArgs, err := m.prepareExternalVolumes(ctx, containerName, req.ExternalVolumes)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			m.releaseExternalVolumes(containerName)
+		}
+	}()
+
+	// 启动共享GPU的MPS守护进程
+	var mpsArgs []string
+	if req.SharedCompute != nil {
+		defer func() {
+			if err != nil {
+				m.ReleaseMPS(ctx)
+			}
+		}()
+		if mpsArgs, err = m.prepareMPS(ctx, allocatedGPUs[0], req.SharedCompute); err != nil {
+			return "", err
+		}
+	}
+
+	// 挂载核心转储目录
+	coreDumpArgs, err := m.prepareCoreDumps(containerName)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			m.cleanupCoreDumps(containerName)
+		}
+	}()
+
+	// 为未指定主机端口的端口映射分配主机端口
+	releasePorts, err := m.allocateHostPorts(req, allocatedGPUs)
+	if err != nil {
+		return "", err
+	}
+	defer releasePorts()
+
+	// 为需要对外发布的端口分配远端端口
+	publishArgs, unpublish, err := m.publishPorts(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			unpublish()
+		}
+	}()
 
 	// 2. 构建Docker运行命令
 	args := []string{"run", "-d"}
 
 	// 添加GPU设备（如果需要GPU）
 	if req.GPUCount > 0 {
-		gpuList := make([]string, len(allocatedGPUs))
-		for i, id := range allocatedGPUs {
-			gpuList[i] = strconv.Itoa(id)
-		}
-		args = append(args, "--gpus", fmt.Sprintf("\"device=%s\"", strings.Join(gpuList, ",")))
+		args = append(args, m.gpuDeviceArgs(allocatedGPUs)...)
 	}
 
 	// 添加端口映射
@@ -130,31 +574,69 @@ func (m *Manager) CreateContainer(ctx context.Context, req *CreateRequest) (stri
 		args = append(args, "-p", portMapping)
 	}
 
-	// 添加环境变量
-	for _, env := range req.EnvVars {
-		args = append(args, "-e", env)
+	// 添加CPU与内存限制
+	args = append(args, resourceLimitArgs(req)...)
+	args = append(args, ulimitArgs(req, options, m.maxCoreBytes())...)
+	args = append(args, ioLimitArgs(ioLimits, m.ioLimits.Device)...)
+
+	// 添加可写层大小限制
+	if storageSizeGB > 0 {
+		args = append(args, "--storage-opt", fmt.Sprintf("size=%dG", storageSizeGB))
 	}
 
+	// 添加环境变量，敏感变量的值通过docker客户端进程环境传递
+	envArgs, sensitiveEnv := m.envArgs(req.EnvVars)
+	args = append(args, envArgs...)
+
 	// 添加卷挂载
 	for hostPath, containerPath := range req.Volumes {
 		args = append(args, "-v", fmt.Sprintf("%s:%s", hostPath, containerPath))
 	}
+	args = append(args, datasetArgs...)
+	args = append(args, externalVolumeArgs...)
+	args = append(args, secretArgs...)
+	args = append(args, mpsArgs...)
+	if req.GPUFraction > 0 {
+		args = append(args, timeSliceArgs(req.GPUFraction)...)
+	}
+	args = append(args, coreDumpArgs...)
+
+	// 添加DNS与主机名
+	args = append(args, networkArgs(req, options)...)
+
+	// 添加安全配置与运行时
+	args = append(args, securityOpts...)
+	args = append(args, runtimeOpts...)
+	args = append(args, publishArgs...)
+	args = append(args, gpuSelectionArgs(selection)...)
+	args = append(args, metadataArgs(req.Metadata)...)
+	args = append(args, expiresAtArgs(req.MaxRuntimeSeconds, time.Now())...)
 
 	// 添加标签（记录实际分配的GPU）
 	args = append(args,
 		"--label", fmt.Sprintf("utopia.claim_id=%s", req.ClaimID),
 		"--label", fmt.Sprintf("utopia.gpu_ids=%s", strings.Join(convertIntSliceToStringSlice(allocatedGPUs), ",")),
+		"--label", fmt.Sprintf("%s=%s", gpuUUIDsLabel, m.gpuUUIDs(allocatedGPUs)),
 		"--label", fmt.Sprintf("utopia.gpu_count=%d", req.GPUCount),
+		"--label", fmt.Sprintf("%s=%d", storageSizeLabel, storageSizeGB),
+		"--label", fmt.Sprintf("%s=%s", datasetsLabel, strings.Join(datasetKeys, ",")),
+		"--label", fmt.Sprintf("%s=%s", securityRelaxationsLabel, strings.Join(req.SecurityRelaxations, ",")),
+		"--label", fmt.Sprintf("%s=%t", mpsLabel, req.SharedCompute != nil),
+		"--label", fmt.Sprintf("%s=%s", runtimeClassLabel, runtimeClassOrDefault(req.RuntimeClass)),
 		"--label", "utopia.managed=true",
 		"--label", "utopia.node_type=gpu",
 	)
+	args = append(args, demandLabelArgs(demand)...)
+	if req.Tenant != "" {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", TenantLabel, req.Tenant))
+	}
+	args = append(args, nodeLabelArgs(m.getOptions().Labels)...)
 
 	// 添加容器名称
-	containerName := fmt.Sprintf("utopia-claim-%s", req.ClaimID)
 	args = append(args, "--name", containerName)
 
 	// 添加重启策略
-	args = append(args, "--restart", "unless-stopped")
+	args = append(args, "--restart", req.RestartPolicy.dockerArg())
 
 	// 添加工作目录
 	if req.WorkingDir != "" {
@@ -169,53 +651,153 @@ func (m *Manager) CreateContainer(ctx context.Context, req *CreateRequest) (stri
 		args = append(args, req.Command...)
 	}
 
+	// 本地没有镜像时先拉取并记录耗时（启用懒加载时只拉取清单与索引）
+	if dockerCommand(ctx, "image", "inspect", "--format", "{{.Id}}", req.Image).Run() != nil {
+		pullStart := time.Now()
+		if err := m.PullImage(ctx, req.Image, req.RegistryAuth); err != nil {
+			return "", err
+		}
+		m.recordImagePull(req.Image, time.Since(pullStart), m.lazyPullActive())
+	}
+
+	// 拉取后镜像仍可能被清理，docker run按凭据重新拉取
+	pullEnv, cleanupPullEnv, err := registryEnv(m.registryAuthFor(ctx, req.Image, req.RegistryAuth))
+	if err != nil {
+		return "", err
+	}
+	defer cleanupPullEnv()
+
 	// 执行Docker命令
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := dockerCommand(ctx, args...)
+	cmd.Env = append(cmd.Env, sensitiveEnv...)
+	cmd.Env = append(cmd.Env, secretEnv...)
+	cmd.Env = append(cmd.Env, pullEnv...)
+	runStart := time.Now()
 	output, err := cmd.Output()
 	if err != nil {
+		if conflict := dockerPortConflict(err); conflict != nil {
+			return "", conflict
+		}
 		return "", fmt.Errorf("failed to create container: %w", err)
 	}
 
-	containerID := strings.TrimSpace(string(output))
+	m.recordImageStart(req.Image, time.Since(runStart))
+	containerID = strings.TrimSpace(string(output))
+	span.SetAttributes(attribute.String("container.id", containerID))
+	tracing.Logger(ctx).Infof("Created container %.12s for claim %s", containerID, req.ClaimID)
 
 	// 获取容器详细信息
 	if err := m.RefreshContainer(ctx, containerID); err != nil {
 		return "", fmt.Errorf("failed to refresh container info: %w", err)
 	}
 
+	// 执行启动后钩子
+	if info, ok := m.GetContainer(containerID); ok {
+		m.runHooks(ctx, hookContextFor(hooks.PostStart, info))
+	}
+
 	return containerID, nil
 }
 
 // RemoveContainer 停止并删除容器
-func (m *Manager) RemoveContainer(ctx context.Context, containerID string) error {
+func (m *Manager) RemoveContainer(ctx context.Context, containerID string) (err error) {
+	ctx, span := tracing.Start(ctx, "container.Remove", attribute.String("container.id", containerID))
+	defer func() { tracing.End(span, err) }()
+
+	// 执行删除前钩子，设置fail_on_error的钩子失败时保留容器
+	cached, found := m.GetContainer(containerID)
+	if !found {
+		cached = ContainerInfo{ID: containerID}
+	}
+	if err := m.runHooks(ctx, hookContextFor(hooks.PreRemove, cached)); err != nil {
+		return err
+	}
+
+	// 记录操作意图，删除过程中崩溃时下次启动补全
+	var claimIDs []string
+	if cached.ClaimID != "" {
+		claimIDs = []string{cached.ClaimID}
+	}
+	defer m.beginIntent(OpRemove, claimIDs, containerID)()
+
 	// 停止容器
-	stopCmd := exec.CommandContext(ctx, "docker", "stop", "-t", "30", containerID)
+	stopCmd := dockerCommand(ctx, "stop", "-t", "30", containerID)
 	if err := stopCmd.Run(); err != nil {
 		// 如果停止失败，记录但继续删除
-		fmt.Printf("Warning: failed to stop container %s: %v\n", containerID, err)
+		tracing.Logger(ctx).Warnf("Failed to stop container %s: %v", containerID, err)
 	}
 
 	// 删除容器
-	removeCmd := exec.CommandContext(ctx, "docker", "rm", "-f", "-v", containerID)
+	removeCmd := dockerCommand(ctx, "rm", "-f", "-v", containerID)
 	if err := removeCmd.Run(); err != nil {
 		return fmt.Errorf("failed to remove container: %w", err)
 	}
 
 	// 从本地缓存中移除
 	m.mu.Lock()
-	delete(m.containers, containerID)
+	info, exists := m.lookupLocked(containerID)
+	if exists {
+		m.deleteContainerLocked(info.ID)
+	}
 	m.mu.Unlock()
+	m.syncClaimDNS()
+	tracing.Logger(ctx).Infof("Removed container %.12s of claim %s", containerID, cached.ClaimID)
+
+	if exists {
+		if info.Hibernation != nil {
+			m.deleteHibernation(info.ClaimID)
+		}
+		m.cleanupSecrets(containerNameFor(info.ClaimID))
+		m.releaseExternalVolumes(containerNameFor(info.ClaimID))
+		m.cleanupCoreDumps(containerNameFor(info.ClaimID))
+		if info.Labels[mpsLabel] == "true" {
+			m.ReleaseMPS(ctx)
+		}
+		m.unpublishPorts(ctx, info)
+		m.cleanupGPUs(info)
+	}
+
+	// 执行删除后钩子
+	m.runHooks(ctx, hookContextFor(hooks.PostRemove, cached))
 
 	return nil
 }
 
+// ExecContainer 在运行中的容器内执行命令，返回标准输出；命令失败时错误中包含标准错误输出
+func (m *Manager) ExecContainer(ctx context.Context, containerID string, command ...string) ([]byte, error) {
+	output, err := dockerCommand(ctx, append([]string{"exec", containerID}, command...)...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return output, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return output, err
+	}
+	return output, nil
+}
+
 // GetContainer 获取容器信息
 func (m *Manager) GetContainer(containerID string) (ContainerInfo, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	info, exists := m.containers[containerID]
-	return info, exists
+	return m.lookupLocked(containerID)
+}
+
+// lookupLocked 按完整ID或ID前缀查找容器（调用方需持有锁）
+func (m *Manager) lookupLocked(containerID string) (ContainerInfo, bool) {
+	if info, exists := m.containers[containerID]; exists {
+		return info, true
+	}
+	if containerID == "" {
+		return ContainerInfo{}, false
+	}
+	for id, info := range m.containers {
+		if strings.HasPrefix(id, containerID) {
+			return info, true
+		}
+	}
+	return ContainerInfo{}, false
 }
 
 // ListContainers 列出所有容器
@@ -232,39 +814,54 @@ func (m *Manager) ListContainers() []ContainerInfo {
 
 // RefreshContainer 刷新单个容器信息
 func (m *Manager) RefreshContainer(ctx context.Context, containerID string) error {
-	cmd := exec.CommandContext(ctx, "docker", "inspect", containerID)
+	info, managed, err := m.inspectContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	if !managed {
+		return nil
+	}
+
+	m.mu.Lock()
+	m.setContainerLocked(info)
+	m.mu.Unlock()
+
+	m.addMetadata(info)
+	m.trackRestarts(info)
+	m.syncClaimDNS()
+
+	return nil
+}
+
+// inspectContainer 查询容器详情，非Utopia管理的容器返回 managed=false
+func (m *Manager) inspectContainer(ctx context.Context, containerID string) (info ContainerInfo, managed bool, err error) {
+	ctx, span := tracing.Start(ctx, "container.Inspect", attribute.String("container.id", containerID))
+	defer func() { tracing.End(span, err) }()
+
+	cmd := dockerCommand(ctx, "inspect", containerID)
 	output, err := cmd.Output()
 	if err != nil {
-		return fmt.Errorf("failed to inspect container: %w", err)
+		return ContainerInfo{}, false, fmt.Errorf("failed to inspect container: %w", err)
 	}
 
 	var containers []DockerContainer
 	if err := json.Unmarshal(output, &containers); err != nil {
-		return fmt.Errorf("failed to parse container info: %w", err)
+		return ContainerInfo{}, false, fmt.Errorf("failed to parse container info: %w", err)
 	}
 
 	if len(containers) == 0 {
-		return fmt.Errorf("container not found")
+		return ContainerInfo{}, false, fmt.Errorf("container not found")
 	}
 
 	container := containers[0]
 
 	// 只处理Utopia管理的容器
 	if container.Config.Labels["utopia.managed"] != "true" {
-		return nil
+		return ContainerInfo{}, false, nil
 	}
 
 	claimID := container.Config.Labels["utopia.claim_id"]
-	gpuIDsStr := container.Config.Labels["utopia.gpu_ids"]
-
-	var gpuIDs []int
-	if gpuIDsStr != "" {
-		for _, idStr := range strings.Split(gpuIDsStr, ",") {
-			if id, err := strconv.Atoi(strings.TrimSpace(idStr)); err == nil {
-				gpuIDs = append(gpuIDs, id)
-			}
-		}
-	}
+	gpuIDs := m.containerGPUIDs(container.Config.Labels)
 
 	// 构建端口映射
 	ports := make(map[string]string)
@@ -278,7 +875,7 @@ func (m *Manager) RefreshContainer(ctx context.Context, containerID string) erro
 	created, _ := time.Parse(time.RFC3339Nano, container.Created)
 	started, _ := time.Parse(time.RFC3339Nano, container.State.StartedAt)
 
-	info := ContainerInfo{
+	info = ContainerInfo{
 		ID:      container.ID,
 		ClaimID: claimID,
 		Image:   container.Config.Image,
@@ -287,20 +884,62 @@ func (m *Manager) RefreshContainer(ctx context.Context, containerID string) erro
 		Ports:   ports,
 		Created: created.Unix(),
 		Started: started.Unix(),
-		Labels:  container.Config.Labels,
+		Labels:  m.redactLabels(container.Config.Labels),
+		// 早于运行时选择创建的容器没有该标签，均为runc
+		RuntimeClass:   runtimeClassOrDefault(container.Config.Labels[runtimeClassLabel]),
+		PublishedPorts: parsePublishedPorts(container.Config.Labels),
+		IPAddress:      containerIPAddress(container),
+		DNSName:        m.dnsName(claimID),
+		GPUSelection:   parseGPUSelection(container.Config.Labels),
+		Metadata:       m.getRedactor().Map(parseMetadata(container.Config.Labels)),
+		ExpiresAt:      parseExpiresAt(container.Config.Labels),
+		GPUFraction:    parseGPUFraction(container.Config.Labels),
+		RestartPolicy: RestartPolicy{
+			Name:       container.HostConfig.RestartPolicy.Name,
+			MaxRetries: container.HostConfig.RestartPolicy.MaximumRetryCount,
+		},
+		RestartCount: container.RestartCount,
 	}
+	m.mu.RLock()
+	info.Hibernation = m.hibernationFor(claimID, container.ID)
+	info.Activity = m.activityFor(container.ID)
+	m.mu.RUnlock()
 
-	m.mu.Lock()
-	m.containers[containerID] = info
-	m.mu.Unlock()
+	return info, true, nil
+}
 
-	return nil
+// setContainerLocked 更新单个容器的缓存并记录修改代数（调用方需持有锁）
+func (m *Manager) setContainerLocked(info ContainerInfo) {
+	m.containers[info.ID] = info
+	m.markChangedLocked(info.ID)
+}
+
+// deleteContainerLocked 从缓存中删除单个容器并记录修改代数（调用方需持有锁）
+func (m *Manager) deleteContainerLocked(containerID string) {
+	delete(m.containers, containerID)
+	m.markChangedLocked(containerID)
+}
+
+// markChangedLocked 记录容器缓存的单独修改，进行中的全量刷新不覆盖此后的修改（调用方需持有锁）
+func (m *Manager) markChangedLocked(containerID string) {
+	if m.cacheChanges == nil {
+		m.cacheChanges = make(map[string]uint64)
+	}
+	m.cacheGen++
+	m.cacheChanges[containerID] = m.cacheGen
 }
 
 // RefreshContainers 刷新容器列表
+// 以有限并发查询全部容器，写入新的缓存后与刷新期间的单独修改合并，刷新期间缓存保持可见
 func (m *Manager) RefreshContainers(ctx context.Context) error {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+	m.mu.RLock()
+	startGen := m.cacheGen
+	m.mu.RUnlock()
+
 	// 列出所有容器
-	cmd := exec.CommandContext(ctx, "docker", "ps", "-a", "--filter", "label=utopia.managed=true", "--format", "{{.ID}}")
+	cmd := dockerCommand(ctx, "ps", "-a", "--no-trunc", "--filter", "label=utopia.managed=true", "--format", "{{.ID}}")
 	output, err := cmd.Output()
 	if err != nil {
 		return fmt.Errorf("failed to list containers: %w", err)
@@ -308,17 +947,77 @@ func (m *Manager) RefreshContainers(ctx context.Context) error {
 
 	containerIDs := strings.Fields(string(output))
 
-	m.mu.Lock()
-	// 清空当前缓存
-	m.containers = make(map[string]ContainerInfo)
-	m.mu.Unlock()
+	concurrency := m.getOptions().RefreshConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultRefreshConcurrency
+	}
 
-	// 刷新每个容器的信息
+	var (
+		wg      sync.WaitGroup
+		resultM sync.Mutex
+		ids     = make(chan string)
+		fresh   = make(map[string]ContainerInfo, len(containerIDs))
+		failed  = make(map[string]bool)
+	)
+	for i := 0; i < concurrency && i < len(containerIDs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				info, managed, err := m.inspectContainer(ctx, id)
+				resultM.Lock()
+				if err != nil {
+					fmt.Printf("Warning: failed to refresh container %s: %v\n", id, err)
+					failed[id] = true
+				} else if managed {
+					fresh[info.ID] = info
+				}
+				resultM.Unlock()
+			}
+		}()
+	}
 	for _, id := range containerIDs {
-		if err := m.RefreshContainer(ctx, id); err != nil {
-			fmt.Printf("Warning: failed to refresh container %s: %v\n", id, err)
+		ids <- id
+	}
+	close(ids)
+	wg.Wait()
+
+	m.mu.Lock()
+	// 查询失败的容器保留旧信息，避免短暂的docker错误导致容器从列表中消失
+	for id := range failed {
+		if old, exists := m.containers[id]; exists {
+			fresh[id] = old
+		}
+	}
+	// 列出容器之后单独创建、刷新或删除的容器以当前缓存为准：
+	// 刷新开始前的快照中没有刚创建的容器，也可能包含已收到 destroy 事件的容器
+	for id, gen := range m.cacheChanges {
+		if gen <= startGen {
+			delete(m.cacheChanges, id)
+			continue
+		}
+		if info, exists := m.containers[id]; exists {
+			fresh[id] = info
+		} else {
+			delete(fresh, id)
 		}
 	}
+	m.containers = fresh
+	snapshot := make(map[string]ContainerInfo, len(fresh))
+	for id, info := range fresh {
+		snapshot[id] = info
+	}
+	m.mu.Unlock()
+
+	m.indexMetadata(snapshot)
+
+	existing := make(map[string]bool, len(snapshot))
+	for id, info := range snapshot {
+		existing[id] = true
+		m.trackRestarts(info)
+	}
+	m.pruneRestartTrackers(existing)
+	m.syncClaimDNS()
 
 	return nil
 }
@@ -342,6 +1041,14 @@ func (m *Manager) GetContainersByGPU(gpuID int) []ContainerInfo {
 
 // IsGPUInUse 检查GPU是否被容器使用
 func (m *Manager) IsGPUInUse(gpuID int) bool {
+	// 休眠中保留GPU的claim虽已停止仍占用GPU
+	m.mu.RLock()
+	reserved := m.hibernatedGPUInUse(gpuID)
+	m.mu.RUnlock()
+	if reserved {
+		return true
+	}
+
 	containers := m.GetContainersByGPU(gpuID)
 	for _, container := range containers {
 		// 只要有运行中的容器使用该GPU，就认为被占用
@@ -353,6 +1060,35 @@ func (m *Manager) IsGPUInUse(gpuID int) bool {
 	return false
 }
 
+// dockerCommand 创建携带链路上下文的docker命令
+func dockerCommand(ctx context.Context, args ...string) *exec.Cmd {
+	if len(args) > 0 {
+		tracing.Logger(ctx).Debugf("Running docker %s", args[0])
+	}
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Env = tracing.CommandEnv(ctx)
+	return cmd
+}
+
+// containerNameFor 返回claim对应的容器名称
+func containerNameFor(claimID string) string {
+	return fmt.Sprintf("utopia-claim-%s", claimID)
+}
+
+// parseGPUIDs 解析 utopia.gpu_ids 标签
+func parseGPUIDs(value string) []int {
+	var gpuIDs []int
+	if value == "" {
+		return gpuIDs
+	}
+	for _, idStr := range strings.Split(value, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(idStr)); err == nil {
+			gpuIDs = append(gpuIDs, id)
+		}
+	}
+	return gpuIDs
+}
+
 // 辅助函数
 func convertIntSliceToStringSlice(ints []int) []string {
 	strs := make([]string, len(ints))
@@ -361,3 +1097,18 @@ func convertIntSliceToStringSlice(ints []int) []string {
 	}
 	return strs
 }
+
+// nodeLabelArgs 按名称顺序生成节点标签的docker参数
+func nodeLabelArgs(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", key, labels[key]))
+	}
+	return args
+}
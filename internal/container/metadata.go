@@ -0,0 +1,103 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// metadataLabel 记录平台附加到claim的元数据（JSON）
+const metadataLabel = "utopia.metadata"
+
+// maxMetadataBytes claim元数据编码后的大小上限
+const maxMetadataBytes = 16 * 1024
+
+// claimMetadata 按claim与容器索引的元数据，供事件附加使用
+// 使用独立的锁，发布事件的调用方持有管理器锁时也可查询
+type claimMetadata struct {
+	mu          sync.RWMutex
+	byClaim     map[string]map[string]interface{}
+	byContainer map[string]map[string]interface{}
+}
+
+// validateMetadata 检查元数据能否编码为不超过上限的JSON
+func validateMetadata(metadata map[string]interface{}) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("must be encodable as JSON: %v", err)
+	}
+	if len(data) > maxMetadataBytes {
+		return fmt.Errorf("must be at most %d bytes when encoded as JSON", maxMetadataBytes)
+	}
+	return nil
+}
+
+// metadataArgs 返回记录claim元数据的容器标签参数，没有元数据时为空
+func metadataArgs(metadata map[string]interface{}) []string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		fmt.Printf("Warning: failed to encode claim metadata: %v\n", err)
+		return nil
+	}
+	return []string{"--label", fmt.Sprintf("%s=%s", metadataLabel, data)}
+}
+
+// parseMetadata 解析容器标签中的claim元数据
+func parseMetadata(labels map[string]string) map[string]interface{} {
+	value := labels[metadataLabel]
+	if value == "" {
+		return nil
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &metadata); err != nil {
+		fmt.Printf("Warning: invalid %s label: %v\n", metadataLabel, err)
+		return nil
+	}
+	return metadata
+}
+
+// ClaimMetadata 返回claim（或容器所属claim）的元数据，未知或没有元数据时为nil
+func (m *Manager) ClaimMetadata(claimID, containerID string) map[string]interface{} {
+	m.metadata.mu.RLock()
+	defer m.metadata.mu.RUnlock()
+	if metadata, exists := m.metadata.byClaim[claimID]; exists && claimID != "" {
+		return metadata
+	}
+	return m.metadata.byContainer[containerID]
+}
+
+// indexMetadata 按容器信息重建元数据索引
+func (m *Manager) indexMetadata(containers map[string]ContainerInfo) {
+	byClaim := make(map[string]map[string]interface{})
+	byContainer := make(map[string]map[string]interface{})
+	for id, info := range containers {
+		if info.Metadata == nil {
+			continue
+		}
+		byClaim[info.ClaimID] = info.Metadata
+		byContainer[id] = info.Metadata
+	}
+
+	m.metadata.mu.Lock()
+	m.metadata.byClaim = byClaim
+	m.metadata.byContainer = byContainer
+	m.metadata.mu.Unlock()
+}
+
+// addMetadata 将单个容器的元数据加入索引
+func (m *Manager) addMetadata(info ContainerInfo) {
+	if info.Metadata == nil {
+		return
+	}
+	m.metadata.mu.Lock()
+	defer m.metadata.mu.Unlock()
+	if m.metadata.byClaim == nil {
+		m.metadata.byClaim = make(map[string]map[string]interface{})
+		m.metadata.byContainer = make(map[string]map[string]interface{})
+	}
+	m.metadata.byClaim[info.ClaimID] = info.Metadata
+	m.metadata.byContainer[info.ID] = info.Metadata
+}
@@ -0,0 +1,136 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"utopia-node-agent/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// migrationLabel 迁移导入的卷所属的迁移ID
+const migrationLabel = "utopia.migration_id"
+
+// Mount 容器挂载
+type Mount struct {
+	Type        string `json:"Type"`
+	Name        string `json:"Name,omitempty"`
+	Source      string `json:"Source"`
+	Destination string `json:"Destination"`
+}
+
+// ContainerMounts 返回受管容器的挂载列表
+func (m *Manager) ContainerMounts(ctx context.Context, containerID string) ([]Mount, error) {
+	if _, exists := m.GetContainer(containerID); !exists {
+		return nil, fmt.Errorf("container %s is not managed by this agent", containerID)
+	}
+
+	output, err := dockerCommand(ctx, "inspect", "--format", "{{json .Mounts}}", containerID).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container mounts: %w", err)
+	}
+
+	var mounts []Mount
+	if err := json.Unmarshal(output, &mounts); err != nil {
+		return nil, fmt.Errorf("failed to parse container mounts: %w", err)
+	}
+	return mounts, nil
+}
+
+// StopContainer 停止受管容器但不删除
+func (m *Manager) StopContainer(ctx context.Context, containerID string) (err error) {
+	ctx, span := tracing.Start(ctx, "container.Stop", attribute.String("container.id", containerID))
+	defer func() { tracing.End(span, err) }()
+
+	if _, exists := m.GetContainer(containerID); !exists {
+		return fmt.Errorf("container %s is not managed by this agent", containerID)
+	}
+
+	if output, err := dockerCommand(ctx, "stop", "-t", "30", containerID).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to stop container: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return m.RefreshContainer(ctx, containerID)
+}
+
+// SaveImage 将镜像以 docker save 归档格式写入w
+func (m *Manager) SaveImage(ctx context.Context, image string, w io.Writer) (err error) {
+	ctx, span := tracing.Start(ctx, "container.SaveImage", attribute.String("container.image", image))
+	defer func() { tracing.End(span, err) }()
+
+	return streamCommand(dockerCommand(ctx, "save", image), nil, w)
+}
+
+// LoadImage 从 docker save 归档加载镜像，返回加载的镜像引用
+func (m *Manager) LoadImage(ctx context.Context, r io.Reader) (image string, err error) {
+	ctx, span := tracing.Start(ctx, "container.LoadImage")
+	defer func() { tracing.End(span, err) }()
+
+	var output strings.Builder
+	if err := streamCommand(dockerCommand(ctx, "load"), r, &output); err != nil {
+		return "", err
+	}
+
+	// 输出形如 "Loaded image: repo:tag" 或 "Loaded image ID: sha256:..."
+	for _, line := range strings.Split(output.String(), "\n") {
+		if _, ref, found := strings.Cut(line, "Loaded image: "); found {
+			image = strings.TrimSpace(ref)
+		} else if _, ref, found := strings.Cut(line, "Loaded image ID: "); found && image == "" {
+			image = strings.TrimSpace(ref)
+		}
+	}
+	if image == "" {
+		return "", fmt.Errorf("docker load did not report an image: %s", strings.TrimSpace(output.String()))
+	}
+	span.SetAttributes(attribute.String("container.image", image))
+	return image, nil
+}
+
+// ArchiveMount 将挂载的主机目录以tar格式写入w，保留属主与权限
+func ArchiveMount(ctx context.Context, mount Mount, w io.Writer) error {
+	if mount.Source == "" {
+		return fmt.Errorf("mount %s has no host source", mount.Destination)
+	}
+	return streamCommand(exec.CommandContext(ctx, "tar", "--numeric-owner", "-C", mount.Source, "-cf", "-", "."), nil, w)
+}
+
+// RestoreVolume 创建迁移卷并将tar归档解压到其中，返回docker卷名
+// 创建容器时以 volumes: {"<卷名>": "<容器路径>"} 挂载
+func (m *Manager) RestoreVolume(ctx context.Context, migrationID, name string, r io.Reader) (volume string, err error) {
+	volume = fmt.Sprintf("utopia-migration-%s-%s", migrationID, name)
+	ctx, span := tracing.Start(ctx, "container.RestoreVolume", attribute.String("container.volume", volume))
+	defer func() { tracing.End(span, err) }()
+
+	if output, err := dockerCommand(ctx, "volume", "create", "--label", migrationLabel+"="+migrationID, volume).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to create volume: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	output, err := dockerCommand(ctx, "volume", "inspect", "--format", "{{.Mountpoint}}", volume).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect volume: %w", err)
+	}
+	mountpoint := strings.TrimSpace(string(output))
+
+	if err := streamCommand(exec.CommandContext(ctx, "tar", "--numeric-owner", "-C", mountpoint, "-xf", "-"), r, io.Discard); err != nil {
+		dockerCommand(context.Background(), "volume", "rm", "-f", volume).Run()
+		return "", fmt.Errorf("failed to restore volume %s: %w", volume, err)
+	}
+	return volume, nil
+}
+
+// streamCommand 以r为标准输入、w为标准输出执行命令，失败时附带标准错误输出
+func streamCommand(cmd *exec.Cmd, r io.Reader, w io.Writer) error {
+	var stderr strings.Builder
+	cmd.Stdin = r
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", cmd.Args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
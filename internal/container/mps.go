@@ -0,0 +1,172 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"utopia-node-agent/internal/gpu"
+)
+
+var (
+	// ErrMPSDisabled 节点未启用MPS共享计算
+	ErrMPSDisabled = errors.New("shared compute (MPS) is disabled on this node")
+	// ErrNoSharedGPU 没有可供共享计算的GPU
+	ErrNoSharedGPU = errors.New("no GPU available for shared compute")
+)
+
+const (
+	// mpsLabel 标记通过MPS共享GPU的容器
+	mpsLabel = "utopia.mps"
+	// mpsContainerPipeDir 容器内MPS管道目录
+	mpsContainerPipeDir = "/tmp/nvidia-mps"
+	// mpsMemLimitEnvVar MPS客户端的显存上限
+	mpsMemLimitEnvVar = "CUDA_MPS_PINNED_DEVICE_MEM_LIMIT"
+)
+
+// SharedComputeOptions 通过NVIDIA MPS与其他容器共享GPU的选项
+type SharedComputeOptions struct {
+	// 容器可使用的SM比例（1-100），0表示不限制
+	ActiveThreadPercentage int `json:"active_thread_percentage,omitempty"`
+	// 容器可使用的显存（MB），按超分策略装箱到共享GPU上，0表示不限制
+	MemoryMB int `json:"memory_mb,omitempty"`
+}
+
+// Validate 验证共享计算选项
+func (o *SharedComputeOptions) Validate(gpuCount int) error {
+	if gpuCount != 1 {
+		return fmt.Errorf("shared compute requires gpu_count of 1")
+	}
+	if o.ActiveThreadPercentage < 0 || o.ActiveThreadPercentage > 100 {
+		return fmt.Errorf("active_thread_percentage must be between 0 and 100")
+	}
+	if o.MemoryMB < 0 {
+		return fmt.Errorf("memory_mb must be non-negative")
+	}
+	return nil
+}
+
+// SetMPS 启用MPS共享计算
+func (m *Manager) SetMPS(mps *gpu.MPSManager) {
+	m.mu.Lock()
+	m.mps = mps
+	m.mu.Unlock()
+}
+
+// mpsManager 获取MPS管理器，未启用时返回nil
+func (m *Manager) mpsManager() *gpu.MPSManager {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mps
+}
+
+// allocateSharedGPU 为共享计算选择GPU，fits 判断GPU的显存余量是否足够
+// 优先选择已运行MPS且客户端未满的GPU中客户端最多的一个以集中放置，否则启用一个空闲GPU
+func (m *Manager) allocateSharedGPU(available []int, fits func(gpuID int) bool) (int, error) {
+	mps := m.mpsManager()
+	if mps == nil {
+		return 0, ErrMPSDisabled
+	}
+
+	maxClients := m.getOptions().MPSMaxClientsPerGPU
+	clients := m.mpsClients()
+	best, bestClients := -1, -1
+	for _, gpuID := range mps.Running() {
+		count := clients[gpuID]
+		if (maxClients > 0 && count >= maxClients) || !fits(gpuID) {
+			continue
+		}
+		if count > bestClients {
+			best, bestClients = gpuID, count
+		}
+	}
+	if best >= 0 {
+		return best, nil
+	}
+
+	for _, gpuID := range available {
+		if !mps.IsRunning(gpuID) && fits(gpuID) {
+			return gpuID, nil
+		}
+	}
+	return 0, ErrNoSharedGPU
+}
+
+// withoutSharedGPUs 从可用GPU中排除运行MPS或有时间片共享容器的GPU，独占请求不能分配到共享GPU上
+func (m *Manager) withoutSharedGPUs(available []int, timeSliced map[int]*TimeSlicedGPU) []int {
+	mps := m.mpsManager()
+	var result []int
+	for _, gpuID := range available {
+		if (mps == nil || !mps.IsRunning(gpuID)) && timeSliced[gpuID] == nil {
+			result = append(result, gpuID)
+		}
+	}
+	return result
+}
+
+// prepareMPS 启动GPU的MPS守护进程，返回连接守护进程所需的docker run参数
+// MPS客户端与守护进程通过共享内存通信，因此容器需要使用主机IPC命名空间
+func (m *Manager) prepareMPS(ctx context.Context, gpuID int, opts *SharedComputeOptions) ([]string, error) {
+	mps := m.mpsManager()
+	if mps == nil {
+		return nil, ErrMPSDisabled
+	}
+	if err := mps.Start(ctx, gpuID); err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		"--ipc", "host",
+		"-v", fmt.Sprintf("%s:%s", mps.PipeDir(gpuID), mpsContainerPipeDir),
+		"-e", "CUDA_MPS_PIPE_DIRECTORY=" + mpsContainerPipeDir,
+	}
+	if opts.ActiveThreadPercentage > 0 {
+		args = append(args, "-e", "CUDA_MPS_ACTIVE_THREAD_PERCENTAGE="+strconv.Itoa(opts.ActiveThreadPercentage))
+	}
+	if opts.MemoryMB > 0 {
+		// 容器内只可见分配的一块GPU，设备序号为0
+		args = append(args, "-e", fmt.Sprintf("%s=0=%dM", mpsMemLimitEnvVar, opts.MemoryMB))
+	}
+	return args, nil
+}
+
+// ReleaseMPS 停止已没有MPS容器使用的GPU上的守护进程
+func (m *Manager) ReleaseMPS(ctx context.Context) {
+	mps := m.mpsManager()
+	if mps == nil {
+		return
+	}
+	clients := m.mpsClients()
+	for _, gpuID := range mps.Running() {
+		if clients[gpuID] > 0 {
+			continue
+		}
+		if err := mps.Stop(ctx, gpuID); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+}
+
+// MPSGPUs 返回正在运行MPS守护进程的GPU
+func (m *Manager) MPSGPUs() []int {
+	mps := m.mpsManager()
+	if mps == nil {
+		return nil
+	}
+	return mps.Running()
+}
+
+// mpsClients 统计每个GPU上通过MPS共享的受管容器数
+func (m *Manager) mpsClients() map[int]int {
+	clients := make(map[int]int)
+	for _, info := range m.ListContainers() {
+		if info.Labels[mpsLabel] != "true" {
+			continue
+		}
+		for _, gpuID := range info.GPUIDs {
+			clients[gpuID]++
+		}
+	}
+	return clients
+}
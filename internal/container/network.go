@@ -0,0 +1,117 @@
+package container
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"utopia-node-agent/internal/config"
+)
+
+// hostnameInvalidChars 主机名标签中不允许的字符
+var hostnameInvalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// NetworkOptions 容器DNS与主机名选项
+type NetworkOptions struct {
+	// 自定义DNS服务器
+	DNSServers []string `json:"dns_servers,omitempty"`
+	// DNS搜索域
+	DNSSearch []string `json:"dns_search,omitempty"`
+	// 额外的 /etc/hosts 条目，格式为 "host:ip"
+	ExtraHosts []string `json:"extra_hosts,omitempty"`
+	// 容器主机名，为空时按节点配置的模式生成
+	Hostname string `json:"hostname,omitempty"`
+}
+
+// Validate 验证DNS与主机名选项
+func (o *NetworkOptions) Validate() error {
+	for _, server := range o.DNSServers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("invalid dns server %q", server)
+		}
+	}
+	for _, domain := range o.DNSSearch {
+		if err := config.ValidateHost(domain); err != nil || net.ParseIP(domain) != nil {
+			return fmt.Errorf("invalid dns search domain %q", domain)
+		}
+	}
+	for _, entry := range o.ExtraHosts {
+		// IPv6地址本身包含冒号，按第一个冒号分割
+		host, ip, found := strings.Cut(entry, ":")
+		if !found || config.ValidateHost(host) != nil {
+			return fmt.Errorf("invalid extra host %q, expected host:ip", entry)
+		}
+		if ip != "host-gateway" && net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid extra host %q, expected host:ip", entry)
+		}
+	}
+	if o.Hostname != "" {
+		if err := config.ValidateHost(o.Hostname); err != nil || net.ParseIP(o.Hostname) != nil {
+			return fmt.Errorf("invalid hostname %q", o.Hostname)
+		}
+	}
+	return nil
+}
+
+// networkArgs 合并节点默认值与请求选项，生成 docker run 参数
+func networkArgs(req *CreateRequest, options Options) []string {
+	var args []string
+
+	dnsServers := req.DNSServers
+	if len(dnsServers) == 0 {
+		dnsServers = options.Network.DNSServers
+	}
+	for _, server := range dnsServers {
+		args = append(args, "--dns", server)
+	}
+
+	dnsSearch := req.DNSSearch
+	if len(dnsSearch) == 0 {
+		dnsSearch = options.Network.DNSSearch
+	}
+	for _, domain := range dnsSearch {
+		args = append(args, "--dns-search", domain)
+	}
+
+	// 额外主机条目在节点默认值基础上追加
+	for _, entry := range append(append([]string{}, options.Network.ExtraHosts...), req.ExtraHosts...) {
+		args = append(args, "--add-host", entry)
+	}
+
+	hostname := req.Hostname
+	if hostname == "" {
+		hostname = renderHostname(options.HostnamePattern, req.ClaimID, options.NodeID)
+	}
+	if hostname != "" {
+		args = append(args, "--hostname", hostname)
+	}
+
+	return args
+}
+
+// renderHostname 按模式生成主机名，支持 {{claim_id}} 与 {{node_id}} 占位符
+// 占位符的值会被规范化为合法的主机名标签
+func renderHostname(pattern, claimID, nodeID string) string {
+	if pattern == "" {
+		return ""
+	}
+	hostname := strings.NewReplacer(
+		"{{claim_id}}", hostnameLabel(claimID),
+		"{{node_id}}", hostnameLabel(nodeID),
+	).Replace(pattern)
+	return strings.ToLower(hostname)
+}
+
+// hostnameLabel 将任意字符串转换为合法的主机名标签
+func hostnameLabel(value string) string {
+	label := hostnameInvalidChars.ReplaceAllString(strings.ToLower(value), "-")
+	label = strings.Trim(label, "-")
+	if len(label) > 63 {
+		label = strings.TrimRight(label[:63], "-")
+	}
+	if label == "" {
+		label = "x"
+	}
+	return label
+}
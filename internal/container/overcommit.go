@@ -0,0 +1,333 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrOvercommit 创建请求会使已承诺资源超过超分上限
+var ErrOvercommit = errors.New("resource commitment would exceed the overcommit limit")
+
+// 记录容器计入的资源承诺量的标签
+const (
+	cpusLabel      = "utopia.cpus"
+	memoryLabel    = "utopia.memory_mb"
+	gpuMemoryLabel = "utopia.gpu_memory_mb"
+)
+
+// OvercommitPolicy 创建容器时的资源准入策略
+// 所有受管容器承诺的资源之和不得超过物理容量乘以超分比例，比例为0表示不限制
+type OvercommitPolicy struct {
+	CPURatio    float64
+	MemoryRatio float64
+	// 共享计算容器在单个GPU上承诺的显存之和相对于显存总量的比例
+	GPUMemoryRatio float64
+	// 请求未指定时计入的CPU核数、内存与共享计算显存（MB），只用于准入计算，不限制容器
+	DefaultCPUs        float64
+	DefaultMemoryMB    int
+	DefaultGPUMemoryMB int
+}
+
+// SystemReservation 为宿主机系统进程（dockerd、frpc等）与Agent自身保留的资源
+type SystemReservation struct {
+	CPUs     float64 `json:"cpus"`
+	MemoryMB int     `json:"memory_mb"`
+	// Docker数据目录在创建容器后至少保留的空闲空间
+	DiskBytes uint64 `json:"disk_bytes"`
+}
+
+// Commitment 一类资源的承诺量与物理容量
+type Commitment struct {
+	Committed float64 `json:"committed"`
+	Physical  float64 `json:"physical"`
+	// 为系统保留、不参与调度的容量
+	Reserved float64 `json:"reserved,omitempty"`
+	// 允许的超分比例与由此得出的上限（物理容量扣除保留量后乘以比例），比例为0或物理容量未知时不限制
+	Ratio float64 `json:"ratio"`
+	Limit float64 `json:"limit,omitempty"`
+}
+
+// DiskHeadroom Docker数据目录的空闲空间与为系统保留的空间（字节）
+type DiskHeadroom struct {
+	Free     uint64 `json:"free"`
+	Reserved uint64 `json:"reserved"`
+}
+
+// GPUMemoryCommitment 单个共享GPU的显存承诺量（MB）
+type GPUMemoryCommitment struct {
+	GPUID int `json:"gpu_id"`
+	Commitment
+	Clients int `json:"clients"`
+}
+
+// OvercommitStatus 节点的资源承诺状态，CPU以核计，内存以MB计
+type OvercommitStatus struct {
+	CPU       Commitment            `json:"cpu"`
+	Memory    Commitment            `json:"memory"`
+	GPUMemory []GPUMemoryCommitment `json:"gpu_memory,omitempty"`
+	// 配置了磁盘保留时的空闲空间
+	Disk *DiskHeadroom `json:"disk,omitempty"`
+}
+
+// resourceDemand 一个容器计入的资源承诺量
+type resourceDemand struct {
+	cpus        float64
+	memoryMB    int
+	gpuMemoryMB int
+	// 共享计算容器所在的GPU，-1表示未共享GPU
+	sharedGPU int
+	// 时间片共享容器所在的GPU与占用的份额，-1表示未以时间片共享GPU
+	slicedGPU   int
+	gpuFraction float64
+}
+
+// newCommitment 根据承诺量、物理容量、系统保留量与超分比例生成承诺状态
+func newCommitment(committed, physical, reserved, ratio float64) Commitment {
+	c := Commitment{Committed: committed, Physical: physical, Reserved: reserved, Ratio: ratio}
+	if c.limited() {
+		c.Limit = max(physical-reserved, 0) * ratio
+	}
+	return c
+}
+
+// limited 是否按上限准入
+func (c Commitment) limited() bool {
+	return c.Ratio > 0 && c.Physical > 0
+}
+
+// admits 增加amount后是否仍在上限内
+func (c Commitment) admits(amount float64) bool {
+	return !c.limited() || amount <= 0 || c.Committed+amount <= c.Limit
+}
+
+// demandFor 计算创建请求计入的资源承诺量
+func demandFor(req *CreateRequest, policy OvercommitPolicy) resourceDemand {
+	d := resourceDemand{cpus: req.CPUs, memoryMB: req.MemoryMB, sharedGPU: -1, slicedGPU: -1}
+	if d.cpus == 0 {
+		d.cpus = policy.DefaultCPUs
+	}
+	if d.memoryMB == 0 {
+		d.memoryMB = policy.DefaultMemoryMB
+	}
+	if req.SharedCompute != nil {
+		d.gpuMemoryMB = req.SharedCompute.MemoryMB
+		if d.gpuMemoryMB == 0 {
+			d.gpuMemoryMB = policy.DefaultGPUMemoryMB
+		}
+	}
+	return d
+}
+
+// demandOf 读取已有容器计入的资源承诺量，升级前创建的容器没有标签，按默认值计入
+func demandOf(info ContainerInfo, policy OvercommitPolicy) resourceDemand {
+	d := resourceDemand{cpus: policy.DefaultCPUs, memoryMB: policy.DefaultMemoryMB, sharedGPU: -1, slicedGPU: -1}
+	if v, ok := info.Labels[cpusLabel]; ok {
+		d.cpus, _ = strconv.ParseFloat(v, 64)
+	}
+	if v, ok := info.Labels[memoryLabel]; ok {
+		d.memoryMB, _ = strconv.Atoi(v)
+	}
+	if info.Labels[mpsLabel] == "true" && len(info.GPUIDs) == 1 {
+		d.sharedGPU = info.GPUIDs[0]
+		d.gpuMemoryMB = policy.DefaultGPUMemoryMB
+		if v, ok := info.Labels[gpuMemoryLabel]; ok {
+			d.gpuMemoryMB, _ = strconv.Atoi(v)
+		}
+	}
+	if fraction := parseGPUFraction(info.Labels); fraction > 0 && len(info.GPUIDs) == 1 {
+		d.slicedGPU, d.gpuFraction = info.GPUIDs[0], fraction
+	}
+	return d
+}
+
+// demandLabelArgs 在容器标签中记录计入的资源承诺量
+func demandLabelArgs(d resourceDemand) []string {
+	args := []string{
+		"--label", fmt.Sprintf("%s=%s", cpusLabel, strconv.FormatFloat(d.cpus, 'f', -1, 64)),
+		"--label", fmt.Sprintf("%s=%d", memoryLabel, d.memoryMB),
+	}
+	if d.sharedGPU >= 0 {
+		args = append(args, "--label", fmt.Sprintf("%s=%d", gpuMemoryLabel, d.gpuMemoryMB))
+	}
+	if d.slicedGPU >= 0 {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", gpuFractionLabel, strconv.FormatFloat(d.gpuFraction, 'f', -1, 64)))
+	}
+	return args
+}
+
+// resourceLimitArgs 将请求中显式指定的CPU与内存转换为docker资源限制
+func resourceLimitArgs(req *CreateRequest) []string {
+	var args []string
+	if req.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(req.CPUs, 'f', -1, 64))
+	}
+	if req.MemoryMB > 0 {
+		args = append(args, "--memory", fmt.Sprintf("%dm", req.MemoryMB))
+	}
+	return args
+}
+
+// commitments 汇总受管容器与创建中请求的资源承诺量
+// 调用方需持有admitMu
+func (m *Manager) commitments(policy OvercommitPolicy) (cpus float64, memoryMB int, gpuMemoryMB, clients map[int]int) {
+	gpuMemoryMB = make(map[int]int)
+	clients = make(map[int]int)
+	add := func(d resourceDemand) {
+		cpus += d.cpus
+		memoryMB += d.memoryMB
+		if d.sharedGPU >= 0 {
+			gpuMemoryMB[d.sharedGPU] += d.gpuMemoryMB
+			clients[d.sharedGPU]++
+		}
+	}
+	for _, info := range m.ListContainers() {
+		add(demandOf(info, policy))
+	}
+	for _, d := range m.admitting {
+		add(d)
+	}
+	return cpus, memoryMB, gpuMemoryMB, clients
+}
+
+// gpuMemoryCommitment 单个GPU的显存承诺状态
+func (m *Manager) gpuMemoryCommitment(gpuID, committedMB int, policy OvercommitPolicy) Commitment {
+	var physical float64
+	if info, ok := m.gpuMonitor.GetGPUByID(gpuID); ok {
+		physical = float64(info.MemoryTotalMB)
+	}
+	return newCommitment(float64(committedMB), physical, 0, policy.GPUMemoryRatio)
+}
+
+// admit 按超分策略与系统保留量准入创建请求，为共享计算请求选择显存余量足够的GPU
+// 准入后的请求在创建完成前计入承诺量，返回的release在创建结束后调用
+func (m *Manager) admit(ctx context.Context, req *CreateRequest, available []int) (d resourceDemand, release func(), err error) {
+	m.admitMu.Lock()
+	defer m.admitMu.Unlock()
+
+	options := m.getOptions()
+	policy, reserved := options.Overcommit, options.SystemReserved
+	d = demandFor(req, policy)
+	cpus, memoryMB, gpuMemoryMB, _ := m.commitments(policy)
+
+	if c := newCommitment(cpus, float64(runtime.NumCPU()), reserved.CPUs, policy.CPURatio); !c.admits(d.cpus) {
+		return d, nil, fmt.Errorf("%w: cpu committed %.2f + requested %.2f > limit %.2f cores (%.2f reserved for the system)",
+			ErrOvercommit, c.Committed, d.cpus, c.Limit, c.Reserved)
+	}
+	if c := newCommitment(float64(memoryMB), float64(physicalMemoryMB()), float64(reserved.MemoryMB), policy.MemoryRatio); !c.admits(float64(d.memoryMB)) {
+		return d, nil, fmt.Errorf("%w: memory committed %.0f MB + requested %d MB > limit %.0f MB (%.0f MB reserved for the system)",
+			ErrOvercommit, c.Committed, d.memoryMB, c.Limit, c.Reserved)
+	}
+	if reserved.DiskBytes > 0 {
+		if err := m.checkDiskHeadroom(ctx, req, reserved.DiskBytes); err != nil {
+			return d, nil, err
+		}
+	}
+
+	if req.SharedCompute != nil {
+		gpuID, err := m.allocateSharedGPU(m.withoutSharedGPUs(available, m.timeSliceUsage()), func(gpuID int) bool {
+			return m.gpuMemoryCommitment(gpuID, gpuMemoryMB[gpuID], policy).admits(float64(d.gpuMemoryMB))
+		})
+		if err != nil {
+			return d, nil, err
+		}
+		d.sharedGPU = gpuID
+	}
+	if req.GPUFraction > 0 {
+		gpuID, err := m.allocateFractionalGPU(req, available)
+		if err != nil {
+			return d, nil, err
+		}
+		d.slicedGPU, d.gpuFraction = gpuID, req.GPUFraction
+	}
+
+	if m.admitting == nil {
+		m.admitting = make(map[*CreateRequest]resourceDemand)
+	}
+	m.admitting[req] = d
+	return d, func() {
+		m.admitMu.Lock()
+		delete(m.admitting, req)
+		m.admitMu.Unlock()
+	}, nil
+}
+
+// checkDiskHeadroom 检查创建容器（按其可写层上限计）后Docker数据目录仍保留系统所需的空闲空间
+func (m *Manager) checkDiskHeadroom(ctx context.Context, req *CreateRequest, reservedBytes uint64) error {
+	free, err := m.dockerRootFreeBytes(ctx)
+	if err != nil {
+		// 无法查询时不阻止创建
+		fmt.Printf("Warning: failed to check disk headroom: %v\n", err)
+		return nil
+	}
+	storageGB := req.StorageSizeGB
+	if storageGB == 0 {
+		storageGB = m.getOptions().DefaultStorageSizeGB
+	}
+	requested := uint64(storageGB) << 30
+	if free < requested+reservedBytes {
+		return fmt.Errorf("%w: disk %d GB free, %d GB requested and %d GB reserved for the system",
+			ErrOvercommit, free>>30, storageGB, reservedBytes>>30)
+	}
+	return nil
+}
+
+// OvercommitStatus 返回已承诺资源、物理容量与系统保留量，GPU显存只列出共享计算的GPU
+func (m *Manager) OvercommitStatus(ctx context.Context) OvercommitStatus {
+	m.admitMu.Lock()
+	options := m.getOptions()
+	policy, reserved := options.Overcommit, options.SystemReserved
+	cpus, memoryMB, gpuMemoryMB, clients := m.commitments(policy)
+	m.admitMu.Unlock()
+
+	status := OvercommitStatus{
+		CPU:    newCommitment(cpus, float64(runtime.NumCPU()), reserved.CPUs, policy.CPURatio),
+		Memory: newCommitment(float64(memoryMB), float64(physicalMemoryMB()), float64(reserved.MemoryMB), policy.MemoryRatio),
+	}
+	if reserved.DiskBytes > 0 {
+		if free, err := m.dockerRootFreeBytes(ctx); err == nil {
+			status.Disk = &DiskHeadroom{Free: free, Reserved: reserved.DiskBytes}
+		}
+	}
+	for _, gpuID := range m.MPSGPUs() {
+		if _, ok := clients[gpuID]; !ok {
+			clients[gpuID] = 0
+		}
+	}
+	for gpuID, count := range clients {
+		status.GPUMemory = append(status.GPUMemory, GPUMemoryCommitment{
+			GPUID:      gpuID,
+			Commitment: m.gpuMemoryCommitment(gpuID, gpuMemoryMB[gpuID], policy),
+			Clients:    count,
+		})
+	}
+	sort.Slice(status.GPUMemory, func(i, j int) bool {
+		return status.GPUMemory[i].GPUID < status.GPUMemory[j].GPUID
+	})
+	return status
+}
+
+// physicalMemoryMB 读取主机物理内存总量（MB），读取失败时返回0
+func physicalMemoryMB() int64 {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, _ := strconv.ParseInt(fields[1], 10, 64)
+			return kb / 1024
+		}
+	}
+	return 0
+}
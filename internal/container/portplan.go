@@ -0,0 +1,209 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrNoHostPorts 动态端口段中没有可分配的主机端口
+var ErrNoHostPorts = errors.New("no host ports left for allocation")
+
+// GPU端口段内的固定偏移，GPU隧道转发这两个端口
+const (
+	PortOffsetWeb = 0
+	PortOffsetSSH = 1
+)
+
+// 按名称分配到GPU端口段固定偏移的端口
+const (
+	PortNameWeb = "web"
+	PortNameSSH = "ssh"
+)
+
+// PortPlan 主机端口划分：GPU i 占用从 GPUBase+i*GPUStride 起的 GPUStride 个端口（web、ssh 及claim按需分配的端口），
+// 未绑定GPU或GPU端口段已用完时从动态端口段分配。容器端口分配与FRP隧道生成使用同一划分
+type PortPlan struct {
+	GPUBase   int `json:"gpu_base"`
+	GPUStride int `json:"gpu_stride"`
+	// 动态端口段 [DynamicStart, DynamicEnd]，DynamicStart为0表示不启用
+	DynamicStart int `json:"dynamic_start"`
+	DynamicEnd   int `json:"dynamic_end"`
+}
+
+// PortPlan 返回节点的主机端口划分
+func (m *Manager) PortPlan() PortPlan {
+	return m.getOptions().Ports
+}
+
+// GPURange 返回GPU端口段 [start, end]
+func (p PortPlan) GPURange(gpuID int) (start, end int) {
+	start = p.GPUBase + gpuID*p.GPUStride
+	return start, start + p.GPUStride - 1
+}
+
+// WebPort 返回GPU的web端口
+func (p PortPlan) WebPort(gpuID int) int {
+	start, _ := p.GPURange(gpuID)
+	return start + PortOffsetWeb
+}
+
+// SSHPort 返回GPU的ssh端口
+func (p PortPlan) SSHPort(gpuID int) int {
+	start, _ := p.GPURange(gpuID)
+	return start + PortOffsetSSH
+}
+
+// Validate 检查各端口段在 1-65535 以内且互不重叠，并且不包含节点自身使用的端口（端口 -> 用途）
+func (p PortPlan) Validate(gpuCount int, reserved map[int]string) error {
+	if p.GPUBase < 1 || p.GPUStride < 2 {
+		return fmt.Errorf("gpu_base must be positive and gpu_stride at least 2")
+	}
+	type portRange struct {
+		name       string
+		start, end int
+	}
+	var ranges []portRange
+	for i := 0; i < gpuCount; i++ {
+		start, end := p.GPURange(i)
+		ranges = append(ranges, portRange{fmt.Sprintf("gpu %d", i), start, end})
+	}
+	if p.DynamicStart > 0 {
+		ranges = append(ranges, portRange{"dynamic", p.DynamicStart, p.DynamicEnd})
+	}
+	for _, r := range ranges {
+		if r.end > 65535 || r.start > r.end {
+			return fmt.Errorf("%s range %d-%d is outside 1-65535", r.name, r.start, r.end)
+		}
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	for i := 1; i < len(ranges); i++ {
+		if ranges[i].start <= ranges[i-1].end {
+			return fmt.Errorf("%s range %d-%d overlaps %s range %d-%d",
+				ranges[i].name, ranges[i].start, ranges[i].end, ranges[i-1].name, ranges[i-1].start, ranges[i-1].end)
+		}
+	}
+
+	var conflicts []string
+	for port, purpose := range reserved {
+		for _, r := range ranges {
+			if port >= r.start && port <= r.end {
+				conflicts = append(conflicts, fmt.Sprintf("%s port %d is in the %s range %d-%d", purpose, port, r.name, r.start, r.end))
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("%s", strings.Join(conflicts, "; "))
+	}
+	return nil
+}
+
+// allocateHostPorts 为主机端口为0的端口映射分配主机端口：
+// 名称为 web、ssh 的使用claim第一块GPU端口段的固定偏移，其余依次使用该GPU端口段的剩余端口，再从动态端口段分配。
+// 分配结果写回请求并预留到返回的释放函数被调用，避免并发创建分配到相同端口
+func (m *Manager) allocateHostPorts(req *CreateRequest, gpuIDs []int) (func(), error) {
+	var pending []int
+	for i, pm := range req.PortMappings {
+		if pm.HostPort == 0 {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return func() {}, nil
+	}
+
+	plan := m.getOptions().Ports
+	used := make(map[string]bool)
+	for key := range m.containerHostPorts() {
+		used[key] = true
+	}
+	for key := range boundHostPorts() {
+		used[key] = true
+	}
+	for _, pm := range req.PortMappings {
+		if pm.HostPort != 0 {
+			used[hostPortKey(pm)] = true
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.portReservations == nil {
+		m.portReservations = make(map[string]string)
+	}
+	free := func(port int, protocol string) bool {
+		key := fmt.Sprintf("%d/%s", port, protocol)
+		_, reserved := m.portReservations[key]
+		return !used[key] && !reserved
+	}
+
+	var blockStart, blockEnd int
+	hasBlock := len(gpuIDs) > 0 && plan.GPUStride > 0
+	if hasBlock {
+		blockStart, blockEnd = plan.GPURange(gpuIDs[0])
+	}
+
+	var keys []string
+	fail := func(err error) (func(), error) {
+		for _, key := range keys {
+			delete(m.portReservations, key)
+		}
+		for _, i := range pending {
+			req.PortMappings[i].HostPort = 0
+		}
+		return nil, err
+	}
+	for _, i := range pending {
+		pm := &req.PortMappings[i]
+		protocol := pm.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+
+		port := 0
+		switch {
+		case hasBlock && pm.Name == PortNameWeb:
+			port = blockStart + PortOffsetWeb
+		case hasBlock && pm.Name == PortNameSSH:
+			port = blockStart + PortOffsetSSH
+		}
+		if port != 0 && !free(port, protocol) {
+			return fail(fmt.Errorf("%w: %s port %d/%s of GPU %d", ErrPortConflict, pm.Name, port, protocol, gpuIDs[0]))
+		}
+		if port == 0 && hasBlock {
+			for p := blockStart + PortOffsetSSH + 1; p <= blockEnd; p++ {
+				if free(p, protocol) {
+					port = p
+					break
+				}
+			}
+		}
+		if port == 0 && plan.DynamicStart > 0 {
+			for p := plan.DynamicStart; p <= plan.DynamicEnd; p++ {
+				if free(p, protocol) {
+					port = p
+					break
+				}
+			}
+		}
+		if port == 0 {
+			return fail(fmt.Errorf("%w: container port %d/%s", ErrNoHostPorts, pm.ContainerPort, protocol))
+		}
+
+		pm.HostPort = port
+		key := fmt.Sprintf("%d/%s", port, protocol)
+		m.portReservations[key] = req.ClaimID
+		keys = append(keys, key)
+	}
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, key := range keys {
+			delete(m.portReservations, key)
+		}
+	}, nil
+}
@@ -0,0 +1,152 @@
+package container
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ErrPortConflict 请求的主机端口已被占用
+var ErrPortConflict = errors.New("host port already in use")
+
+// procNetFiles 主机上已绑定端口的来源，按协议区分
+var procNetFiles = map[string][]string{
+	"tcp": {"/proc/net/tcp", "/proc/net/tcp6"},
+	"udp": {"/proc/net/udp", "/proc/net/udp6"},
+}
+
+// 套接字状态：TCP监听、UDP未连接
+const (
+	tcpListenState = "0A"
+	udpUnconnState = "07"
+)
+
+// PortConflictError 主机端口冲突，占用者为托管容器时包含其claim
+type PortConflictError struct {
+	Port        string
+	ClaimID     string
+	ContainerID string
+}
+
+// Error 实现error接口
+func (e *PortConflictError) Error() string {
+	if e.ClaimID != "" {
+		containerID := e.ContainerID
+		if len(containerID) > 12 {
+			containerID = containerID[:12]
+		}
+		return fmt.Sprintf("host port %s is already used by claim %s (container %s)", e.Port, e.ClaimID, containerID)
+	}
+	return fmt.Sprintf("host port %s is already bound on the host", e.Port)
+}
+
+// Unwrap 使 errors.Is(err, ErrPortConflict) 成立
+func (e *PortConflictError) Unwrap() error {
+	return ErrPortConflict
+}
+
+// checkPortConflicts 在执行docker run前检查请求的主机端口是否已被托管容器或主机上的进程占用
+func (m *Manager) checkPortConflicts(req *CreateRequest) error {
+	if len(req.PortMappings) == 0 {
+		return nil
+	}
+
+	used := m.containerHostPorts()
+	var bound map[string]bool
+	for _, pm := range req.PortMappings {
+		// 主机端口为0的端口映射在分配时检查
+		if pm.HostPort == 0 {
+			continue
+		}
+		key := hostPortKey(pm)
+		if info, exists := used[key]; exists {
+			return &PortConflictError{Port: key, ClaimID: info.ClaimID, ContainerID: info.ID}
+		}
+		if bound == nil {
+			bound = boundHostPorts()
+		}
+		if bound[key] {
+			return &PortConflictError{Port: key}
+		}
+	}
+	return nil
+}
+
+// containerHostPorts 返回托管容器占用的主机端口（"端口/协议"）
+func (m *Manager) containerHostPorts() map[string]ContainerInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	used := make(map[string]ContainerInfo)
+	for _, info := range m.containers {
+		for containerPort, binding := range info.Ports {
+			protocol := "tcp"
+			if i := strings.LastIndex(containerPort, "/"); i >= 0 {
+				protocol = containerPort[i+1:]
+			}
+			hostPort := binding[strings.LastIndex(binding, ":")+1:]
+			used[hostPort+"/"+protocol] = info
+		}
+	}
+	return used
+}
+
+// boundHostPorts 从/proc/net读取主机上已监听的TCP端口与已绑定的UDP端口
+// 读取失败时返回已读取的部分，由docker run报告剩余的冲突
+func boundHostPorts() map[string]bool {
+	bound := make(map[string]bool)
+	for protocol, files := range procNetFiles {
+		state := tcpListenState
+		if protocol == "udp" {
+			state = udpUnconnState
+		}
+		for _, path := range files {
+			f, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			scanner := bufio.NewScanner(f)
+			scanner.Scan() // 表头
+			for scanner.Scan() {
+				fields := strings.Fields(scanner.Text())
+				if len(fields) < 4 || fields[3] != state {
+					continue
+				}
+				local := fields[1]
+				port, err := strconv.ParseUint(local[strings.LastIndex(local, ":")+1:], 16, 16)
+				if err != nil || port == 0 {
+					continue
+				}
+				bound[fmt.Sprintf("%d/%s", port, protocol)] = true
+			}
+			f.Close()
+		}
+	}
+	return bound
+}
+
+// hostPortKey 返回端口映射的 "主机端口/协议"
+func hostPortKey(pm PortMapping) string {
+	protocol := pm.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	return fmt.Sprintf("%d/%s", pm.HostPort, protocol)
+}
+
+// dockerPortConflict 识别docker run因端口被占用失败的输出，检查后仍发生竞争时使用
+func dockerPortConflict(err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return nil
+	}
+	stderr := strings.TrimSpace(string(exitErr.Stderr))
+	if strings.Contains(stderr, "port is already allocated") || strings.Contains(stderr, "address already in use") {
+		return fmt.Errorf("%w: %s", ErrPortConflict, stderr)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"utopia-node-agent/internal/tracing"
+)
+
+// 端口发布方式
+const (
+	PublishHTTP = "http"
+	PublishTCP  = "tcp"
+)
+
+var (
+	// ErrPortPublishingDisabled 节点未启用claim端口发布
+	ErrPortPublishingDisabled = errors.New("port publishing is disabled on this node")
+	// ErrNoPublishPorts 节点的远端端口已全部分配
+	ErrNoPublishPorts = errors.New("no remote ports left for publishing")
+)
+
+// publishedPortsLabel 记录容器已发布端口的分配结果（JSON）
+const publishedPortsLabel = "utopia.published_ports"
+
+// PublishedPort 通过隧道对外发布的容器端口
+type PublishedPort struct {
+	Name          string `json:"name"`
+	ContainerPort int    `json:"container_port"`
+	HostPort      int    `json:"host_port"`
+	RemotePort    int    `json:"remote_port"`
+	// 外部访问地址 host:port
+	Address string `json:"address"`
+	// 发布方式为http时的访问URL
+	URL string `json:"url,omitempty"`
+}
+
+// PortPublisher 为claim的端口分配远端端口并更新隧道（由agent实现）
+type PortPublisher interface {
+	// PublishPorts 为claim分配远端端口并使隧道生效，远端端口用尽时返回 ErrNoPublishPorts
+	PublishPorts(ctx context.Context, claimID string, ports []PortMapping) ([]PublishedPort, error)
+	// UnpublishPorts 撤销claim的全部发布端口
+	UnpublishPorts(ctx context.Context, claimID string) error
+}
+
+// SetPortPublisher 启用claim端口发布
+func (m *Manager) SetPortPublisher(publisher PortPublisher) {
+	m.mu.Lock()
+	m.publisher = publisher
+	m.mu.Unlock()
+}
+
+// portPublisher 返回端口发布器，未启用时为nil
+func (m *Manager) portPublisher() PortPublisher {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.publisher
+}
+
+// portsToPublish 返回请求中需要对外发布的端口映射
+func portsToPublish(req *CreateRequest) []PortMapping {
+	var result []PortMapping
+	for _, pm := range req.PortMappings {
+		if pm.Publish != "" {
+			result = append(result, pm)
+		}
+	}
+	return result
+}
+
+// publishPorts 为创建请求发布端口，返回容器标签参数与撤销函数
+func (m *Manager) publishPorts(ctx context.Context, req *CreateRequest) ([]string, func(), error) {
+	ports := portsToPublish(req)
+	if len(ports) == 0 {
+		return nil, func() {}, nil
+	}
+
+	publisher := m.portPublisher()
+	if publisher == nil {
+		return nil, nil, ErrPortPublishingDisabled
+	}
+	published, err := publisher.PublishPorts(ctx, req.ClaimID, ports)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to publish ports: %w", err)
+	}
+	undo := func() {
+		if err := publisher.UnpublishPorts(context.Background(), req.ClaimID); err != nil {
+			fmt.Printf("Warning: failed to unpublish ports of claim %s: %v\n", req.ClaimID, err)
+		}
+	}
+
+	data, err := json.Marshal(published)
+	if err != nil {
+		undo()
+		return nil, nil, fmt.Errorf("failed to encode published ports: %w", err)
+	}
+	return []string{"--label", fmt.Sprintf("%s=%s", publishedPortsLabel, data)}, undo, nil
+}
+
+// unpublishPorts 撤销已删除容器的发布端口
+func (m *Manager) unpublishPorts(ctx context.Context, info ContainerInfo) {
+	if len(info.PublishedPorts) == 0 {
+		return
+	}
+	publisher := m.portPublisher()
+	if publisher == nil {
+		return
+	}
+	if err := publisher.UnpublishPorts(ctx, info.ClaimID); err != nil {
+		tracing.Logger(ctx).Warnf("Failed to unpublish ports of claim %s: %v", info.ClaimID, err)
+	}
+}
+
+// parsePublishedPorts 解析容器标签中的发布端口
+func parsePublishedPorts(labels map[string]string) []PublishedPort {
+	value := labels[publishedPortsLabel]
+	if value == "" {
+		return nil
+	}
+	var ports []PublishedPort
+	if err := json.Unmarshal([]byte(value), &ports); err != nil {
+		fmt.Printf("Warning: invalid %s label: %v\n", publishedPortsLabel, err)
+		return nil
+	}
+	return ports
+}
@@ -0,0 +1,124 @@
+package container
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 排序字段
+const (
+	SortByCreated = "created"
+	SortByClaimID = "claim_id"
+	SortByID      = "id"
+)
+
+// ListQuery 容器列表查询条件
+type ListQuery struct {
+	Status  string
+	ClaimID string
+	// GPUID 为nil表示不按GPU过滤
+	GPUID *int
+	// 标签选择器："key=value" 要求标签值相等，"key" 要求存在该标签
+	LabelSelectors []string
+	// 只返回在该时间（Unix秒）之后创建的容器
+	CreatedSince int64
+	// 排序字段，前缀 "-" 表示倒序
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// Validate 验证查询条件
+func (q *ListQuery) Validate() error {
+	switch strings.TrimPrefix(q.Sort, "-") {
+	case "", SortByCreated, SortByClaimID, SortByID:
+	default:
+		return fmt.Errorf("sort must be one of %s, %s, %s", SortByCreated, SortByClaimID, SortByID)
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return fmt.Errorf("limit and offset must be non-negative")
+	}
+	for _, selector := range q.LabelSelectors {
+		if key, _, _ := strings.Cut(selector, "="); key == "" {
+			return fmt.Errorf("invalid label selector %q", selector)
+		}
+	}
+	return nil
+}
+
+// QueryContainers 按条件过滤、排序并分页，返回当前页与过滤后的总数
+// 排序相同的容器按ID排序，保证分页结果稳定
+func (m *Manager) QueryContainers(query ListQuery) ([]ContainerInfo, int) {
+	matched := []ContainerInfo{}
+	for _, info := range m.ListContainers() {
+		if query.matches(info) {
+			matched = append(matched, info)
+		}
+	}
+
+	field := strings.TrimPrefix(query.Sort, "-")
+	descending := strings.HasPrefix(query.Sort, "-")
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		var less, equal bool
+		switch field {
+		case SortByClaimID:
+			less, equal = a.ClaimID < b.ClaimID, a.ClaimID == b.ClaimID
+		case SortByID:
+			less, equal = a.ID < b.ID, a.ID == b.ID
+		default:
+			less, equal = a.Created < b.Created, a.Created == b.Created
+		}
+		if equal {
+			return a.ID < b.ID
+		}
+		if descending {
+			return !less
+		}
+		return less
+	})
+
+	total := len(matched)
+	if query.Offset >= total {
+		return []ContainerInfo{}, total
+	}
+	matched = matched[query.Offset:]
+	if query.Limit > 0 && query.Limit < len(matched) {
+		matched = matched[:query.Limit]
+	}
+	return matched, total
+}
+
+// matches 检查容器是否满足查询条件
+func (q *ListQuery) matches(info ContainerInfo) bool {
+	if q.Status != "" && !strings.EqualFold(info.Status, q.Status) {
+		return false
+	}
+	if q.ClaimID != "" && info.ClaimID != q.ClaimID {
+		return false
+	}
+	if q.CreatedSince > 0 && info.Created < q.CreatedSince {
+		return false
+	}
+	if q.GPUID != nil {
+		found := false
+		for _, id := range info.GPUIDs {
+			if id == *q.GPUID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, selector := range q.LabelSelectors {
+		key, value, hasValue := strings.Cut(selector, "=")
+		actual, exists := info.Labels[key]
+		if !exists || (hasValue && actual != value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package container
+
+import (
+	"encoding/json"
+	"strings"
+
+	"utopia-node-agent/internal/redact"
+)
+
+// SetRedactor 设置敏感值遮盖：名称敏感的环境变量不出现在docker命令行中，
+// 容器信息中名称敏感的标签与元数据的值被遮盖；nil表示不遮盖
+func (m *Manager) SetRedactor(r *redact.Redactor) {
+	m.mu.Lock()
+	m.redactor = r
+	m.mu.Unlock()
+}
+
+// getRedactor 返回当前的遮盖器
+func (m *Manager) getRedactor() *redact.Redactor {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.redactor
+}
+
+// envArgs 返回环境变量的docker run参数：敏感变量只传变量名，值由docker客户端从自身环境读取，
+// 与其他变量的值一样进入容器，但不会出现在进程列表与审计日志的命令行中
+func (m *Manager) envArgs(envVars []string) (args []string, env []string) {
+	r := m.getRedactor()
+	for _, entry := range envVars {
+		name, _, _ := strings.Cut(entry, "=")
+		if r.Sensitive(name) {
+			args = append(args, "-e", name)
+			env = append(env, entry)
+			continue
+		}
+		args = append(args, "-e", entry)
+	}
+	return args, env
+}
+
+// redactLabels 返回遮盖后的容器标签，元数据标签中名称敏感的字段同样遮盖
+func (m *Manager) redactLabels(labels map[string]string) map[string]string {
+	r := m.getRedactor()
+	if r == nil {
+		return labels
+	}
+	redacted := r.Labels(labels)
+	metadata := parseMetadata(labels)
+	if metadata == nil {
+		return redacted
+	}
+	data, err := json.Marshal(r.Map(metadata))
+	if err != nil || string(data) == labels[metadataLabel] {
+		return redacted
+	}
+	copied := make(map[string]string, len(redacted))
+	for key, value := range redacted {
+		copied[key] = value
+	}
+	copied[metadataLabel] = string(data)
+	return copied
+}
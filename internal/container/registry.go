@@ -0,0 +1,149 @@
+package container
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"utopia-node-agent/internal/tracing"
+)
+
+// dockerHubServer Docker Hub 在 docker 客户端配置中使用的仓库地址
+const dockerHubServer = "https://index.docker.io/v1/"
+
+// RegistryAuth 镜像仓库认证信息
+// 请求中携带的凭据只保存在内存中，使用时写入仅root可读的临时 DOCKER_CONFIG 目录，用完即删除
+type RegistryAuth struct {
+	// 仓库地址，如 registry.example.com:5000；为空表示镜像所在的仓库
+	Server   string `json:"server,omitempty"`
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	// 凭据过期时间（Unix秒），过期后改用节点配置的凭据
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+// String 隐藏密码，避免凭据出现在日志中
+func (a RegistryAuth) String() string {
+	return fmt.Sprintf("%s@%s", a.Username, a.Server)
+}
+
+// Validate 验证请求携带的凭据可用于拉取image
+func (a *RegistryAuth) Validate(image string) error {
+	if a.Username == "" || a.Password == "" {
+		return fmt.Errorf("username and password are required")
+	}
+	if a.Server != "" && normalizeRegistryServer(a.Server) != registryHost(image) {
+		return fmt.Errorf("server %s does not match the image registry %s", a.Server, registryHost(image))
+	}
+	if a.expired() {
+		return fmt.Errorf("credentials have already expired")
+	}
+	return nil
+}
+
+// expired 凭据是否已过期
+func (a *RegistryAuth) expired() bool {
+	return a.ExpiresAt > 0 && time.Now().Unix() >= a.ExpiresAt
+}
+
+// registryHost 返回镜像引用所在的仓库，未指定仓库的镜像来自 Docker Hub
+func registryHost(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	if !found || (!strings.ContainsAny(first, ".:") && first != "localhost") {
+		return "docker.io"
+	}
+	return strings.ToLower(first)
+}
+
+// normalizeRegistryServer 去掉仓库地址的协议与路径，Docker Hub 的各种写法统一为 docker.io
+func normalizeRegistryServer(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	server, _, _ = strings.Cut(server, "/")
+	server = strings.ToLower(server)
+	switch server {
+	case "", "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return "docker.io"
+	}
+	return server
+}
+
+// registryAuthFor 选择访问镜像所在仓库的凭据：优先使用请求携带的未过期凭据，其次是节点配置的凭据
+// 没有可用凭据时返回nil
+func (m *Manager) registryAuthFor(ctx context.Context, image string, override *RegistryAuth) *RegistryAuth {
+	host := registryHost(image)
+	if override != nil && override.Username != "" &&
+		(override.Server == "" || normalizeRegistryServer(override.Server) == host) {
+		if !override.expired() {
+			auth := *override
+			auth.Server = host
+			return &auth
+		}
+		tracing.Logger(ctx).Warnf("Registry credentials for %s expired, falling back to node credentials", host)
+	}
+
+	options := m.getOptions()
+	candidates := append([]RegistryAuth{options.Registry}, options.RegistryCredentials...)
+	for _, auth := range candidates {
+		if auth.Username != "" && normalizeRegistryServer(auth.Server) == host {
+			auth.Server = host
+			return &auth
+		}
+	}
+	return nil
+}
+
+// registryEnv 把凭据写入临时的 DOCKER_CONFIG 目录，返回docker命令需追加的环境变量与清理函数
+// auth为nil时不设置，docker使用主机上的配置
+func registryEnv(auth *RegistryAuth) ([]string, func(), error) {
+	if auth == nil {
+		return nil, func() {}, nil
+	}
+
+	// 临时目录权限为0700
+	configDir, err := os.MkdirTemp("", "utopia-docker-config-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create docker config dir: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(configDir) }
+
+	server := auth.Server
+	if normalizeRegistryServer(server) == "docker.io" {
+		server = dockerHubServer
+	}
+	encoded := base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password))
+	data, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			server: map[string]string{"auth": encoded},
+		},
+	})
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "config.json"), data, 0600); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to write docker config: %w", err)
+	}
+	return []string{"DOCKER_CONFIG=" + configDir}, cleanup, nil
+}
+
+// PullImage 拉取镜像，auth为请求携带的凭据，为nil时使用节点为该仓库配置的凭据
+func (m *Manager) PullImage(ctx context.Context, image string, auth *RegistryAuth) error {
+	env, cleanup, err := registryEnv(m.registryAuthFor(ctx, image, auth))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	pull := dockerCommand(ctx, "pull", image)
+	pull.Env = append(pull.Env, env...)
+	if output, err := pull.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to pull image %s: %w: %s", image, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package container
+
+import (
+	"fmt"
+	"time"
+
+	"utopia-node-agent/internal/events"
+)
+
+// 重启策略
+const (
+	RestartNo            = "no"
+	RestartOnFailure     = "on-failure"
+	RestartUnlessStopped = "unless-stopped"
+)
+
+// EventCrashLoop 容器短时间内反复重启
+const EventCrashLoop = "container.crash_loop"
+
+// RestartPolicy 容器重启策略
+type RestartPolicy struct {
+	Name string `json:"name"`
+	// 最大重试次数，仅对 on-failure 有效，0表示不限制
+	MaxRetries int `json:"max_retries,omitempty"`
+}
+
+// Validate 验证重启策略
+func (p *RestartPolicy) Validate() error {
+	switch p.Name {
+	case RestartNo, RestartUnlessStopped:
+		if p.MaxRetries != 0 {
+			return fmt.Errorf("max_retries is only supported with the %s policy", RestartOnFailure)
+		}
+	case RestartOnFailure:
+		if p.MaxRetries < 0 {
+			return fmt.Errorf("max_retries must be non-negative")
+		}
+	default:
+		return fmt.Errorf("restart policy must be one of %s, %s, %s", RestartNo, RestartOnFailure, RestartUnlessStopped)
+	}
+	return nil
+}
+
+// dockerArg 返回 docker run --restart 参数值
+func (p *RestartPolicy) dockerArg() string {
+	if p == nil {
+		return RestartUnlessStopped
+	}
+	if p.Name == RestartOnFailure && p.MaxRetries > 0 {
+		return fmt.Sprintf("%s:%d", RestartOnFailure, p.MaxRetries)
+	}
+	return p.Name
+}
+
+// CrashLoopPolicy 崩溃循环判定：Window 内重启超过 MaxRestarts 次
+type CrashLoopPolicy struct {
+	MaxRestarts int
+	Window      time.Duration
+}
+
+// restartTracker 记录单个容器的重启历史
+type restartTracker struct {
+	lastCount int
+	restarts  []time.Time
+	// 本轮崩溃循环已上报，重启频率回落后复位
+	reported bool
+}
+
+// SetEventBus 设置事件总线，用于上报崩溃循环等事件
+func (m *Manager) SetEventBus(bus *events.Bus) {
+	m.mu.Lock()
+	m.events = bus
+	m.mu.Unlock()
+}
+
+// trackRestarts 根据docker报告的重启次数更新历史，达到阈值时发布崩溃循环事件
+func (m *Manager) trackRestarts(info ContainerInfo) {
+	m.mu.Lock()
+	policy := m.options.CrashLoop
+	bus := m.events
+
+	tracker, exists := m.restarts[info.ID]
+	if !exists {
+		// 首次观察到的容器以当前计数为基线
+		m.restarts[info.ID] = &restartTracker{lastCount: info.RestartCount}
+		m.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	for i := tracker.lastCount; i < info.RestartCount; i++ {
+		tracker.restarts = append(tracker.restarts, now)
+	}
+	tracker.lastCount = info.RestartCount
+
+	// 丢弃窗口外的重启记录
+	cutoff := now.Add(-policy.Window)
+	recent := tracker.restarts[:0]
+	for _, t := range tracker.restarts {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	tracker.restarts = recent
+
+	crashLooping := policy.MaxRestarts > 0 && len(recent) > policy.MaxRestarts
+	shouldReport := crashLooping && !tracker.reported
+	tracker.reported = crashLooping
+	m.mu.Unlock()
+
+	if shouldReport && bus != nil {
+		bus.Publish(events.Event{
+			Type:        EventCrashLoop,
+			Severity:    events.SeverityError,
+			ContainerID: info.ID,
+			ClaimID:     info.ClaimID,
+			Message: fmt.Sprintf("container restarted %d times in the last %s",
+				len(recent), policy.Window),
+			Data: map[string]interface{}{
+				"restart_count":   info.RestartCount,
+				"recent_restarts": len(recent),
+				"window_seconds":  int(policy.Window.Seconds()),
+			},
+		})
+	}
+}
+
+// pruneRestartTrackers 删除已不存在容器的重启历史
+func (m *Manager) pruneRestartTrackers(existing map[string]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id := range m.restarts {
+		if !existing[id] {
+			delete(m.restarts, id)
+		}
+	}
+}
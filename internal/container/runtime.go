@@ -0,0 +1,97 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+)
+
+// 容器运行时类别
+const (
+	RuntimeRunc   = "runc"
+	RuntimeGVisor = "gvisor"
+	RuntimeKata   = "kata"
+)
+
+// ErrRuntimeUnavailable 节点未安装请求的运行时
+var ErrRuntimeUnavailable = errors.New("container runtime is not installed on this node")
+
+// runtimeClassLabel 记录容器使用的运行时类别
+const runtimeClassLabel = "utopia.runtime_class"
+
+// sandboxRuntimeNames 沙箱运行时类别对应的docker运行时名称，按优先级排列
+var sandboxRuntimeNames = map[string][]string{
+	RuntimeGVisor: {"runsc"},
+	RuntimeKata:   {"kata", "kata-runtime", "io.containerd.kata.v2", "kata-qemu"},
+}
+
+// ValidateRuntimeClass 验证运行时类别名称
+func ValidateRuntimeClass(class string) error {
+	switch class {
+	case "", RuntimeRunc, RuntimeGVisor, RuntimeKata:
+		return nil
+	default:
+		return fmt.Errorf("unknown runtime class %q, must be one of runc, gvisor, kata", class)
+	}
+}
+
+// detectRuntimes 查询docker已注册的运行时，返回 运行时类别 -> docker运行时名称
+// runc 总是可用，使用节点默认运行时（GPU容器依赖其中的NVIDIA钩子）
+func detectRuntimes(ctx context.Context) map[string]string {
+	runtimes := map[string]string{RuntimeRunc: ""}
+
+	output, err := exec.CommandContext(ctx, "docker", "info", "--format", "{{json .Runtimes}}").Output()
+	if err != nil {
+		fmt.Printf("Warning: failed to query docker runtimes: %v\n", err)
+		return runtimes
+	}
+	var registered map[string]json.RawMessage
+	if err := json.Unmarshal(output, &registered); err != nil {
+		fmt.Printf("Warning: failed to parse docker runtimes: %v\n", err)
+		return runtimes
+	}
+
+	for class, names := range sandboxRuntimeNames {
+		for _, name := range names {
+			if _, ok := registered[name]; ok {
+				runtimes[class] = name
+				break
+			}
+		}
+	}
+	return runtimes
+}
+
+// AvailableRuntimes 返回节点上可用的运行时类别
+func (m *Manager) AvailableRuntimes() []string {
+	classes := make([]string, 0, len(m.runtimes))
+	for class := range m.runtimes {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+// runtimeClassOrDefault 未指定运行时类别时为runc
+func runtimeClassOrDefault(class string) string {
+	if class == "" {
+		return RuntimeRunc
+	}
+	return class
+}
+
+// runtimeArgs 生成运行时类别对应的 docker run 参数
+func (m *Manager) runtimeArgs(class string) ([]string, error) {
+	class = runtimeClassOrDefault(class)
+	name, ok := m.runtimes[class]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRuntimeUnavailable, class)
+	}
+	if name == "" {
+		return nil, nil
+	}
+	return []string{"--runtime", name}, nil
+}
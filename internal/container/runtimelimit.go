@@ -0,0 +1,202 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"utopia-node-agent/internal/events"
+)
+
+// expiresAtLabel 记录容器到期时间（Unix秒）的标签，Agent重启后按标签继续执行
+const expiresAtLabel = "utopia.expires_at"
+
+// claim运行时长上限事件类型
+const (
+	EventClaimRuntimeExpiring     = "claim.runtime_expiring"
+	EventClaimRuntimeExpired      = "claim.runtime_expired"
+	EventClaimRuntimeExpiryFailed = "claim.runtime_expiry_failed"
+)
+
+// RuntimeLimitPolicy 运行时长上限的警告与到期处理策略
+type RuntimeLimitPolicy struct {
+	// 到期前的警告时间点，如30分钟、5分钟
+	Warnings []time.Duration
+	// 到期时停止容器的宽限时间，超时后强制结束进程
+	GracePeriod time.Duration
+}
+
+// runtimeLimitState 单个容器已发布的到期警告
+type runtimeLimitState struct {
+	// 已警告的最近时间点
+	warned time.Duration
+	// 到期处理失败后不再重复发布失败事件
+	failed bool
+}
+
+// expiresAtArgs 按创建请求的运行时长上限生成到期时间标签参数
+func expiresAtArgs(maxRuntimeSeconds int, now time.Time) []string {
+	if maxRuntimeSeconds <= 0 {
+		return nil
+	}
+	expiresAt := now.Add(time.Duration(maxRuntimeSeconds) * time.Second).Unix()
+	return []string{"--label", fmt.Sprintf("%s=%d", expiresAtLabel, expiresAt)}
+}
+
+// parseExpiresAt 解析容器的到期时间，没有运行时长上限时为0
+func parseExpiresAt(labels map[string]string) int64 {
+	expiresAt, _ := strconv.ParseInt(labels[expiresAtLabel], 10, 64)
+	return expiresAt
+}
+
+// CheckRuntimeLimits 对接近到期的claim发布警告，停止并删除已到期的claim容器
+// 不依赖平台：到期时间记录在容器标签中，休眠与停止的时间同样计入
+func (m *Manager) CheckRuntimeLimits(ctx context.Context, policy RuntimeLimitPolicy) error {
+	warnings := append([]time.Duration(nil), policy.Warnings...)
+	sort.Slice(warnings, func(i, j int) bool { return warnings[i] < warnings[j] })
+
+	now := time.Now()
+	var expired []ContainerInfo
+	var pending []events.Event
+	m.mu.Lock()
+	if m.runtimeLimits == nil {
+		m.runtimeLimits = make(map[string]*runtimeLimitState)
+	}
+	current := make(map[string]bool)
+	for _, info := range m.containers {
+		if info.ExpiresAt == 0 {
+			continue
+		}
+		current[info.ID] = true
+		state, exists := m.runtimeLimits[info.ID]
+		if !exists {
+			state = &runtimeLimitState{}
+			m.runtimeLimits[info.ID] = state
+		}
+
+		remaining := time.Unix(info.ExpiresAt, 0).Sub(now)
+		if remaining <= 0 {
+			expired = append(expired, info)
+			continue
+		}
+		// 只发布已经过的最近一个时间点的警告，Agent重启后不补发更早的警告
+		for _, warning := range warnings {
+			if remaining > warning {
+				continue
+			}
+			if state.warned == 0 || warning < state.warned {
+				state.warned = warning
+				pending = append(pending, runtimeExpiringEvent(info, remaining))
+			}
+			break
+		}
+	}
+	for id := range m.runtimeLimits {
+		if !current[id] {
+			delete(m.runtimeLimits, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, event := range pending {
+		m.publishRuntimeLimitEvent(event)
+	}
+
+	// 各容器的宽限时间并行等待
+	var wg sync.WaitGroup
+	for _, info := range expired {
+		wg.Add(1)
+		go func(info ContainerInfo) {
+			defer wg.Done()
+			m.expireContainer(ctx, info, policy.GracePeriod)
+		}(info)
+	}
+	wg.Wait()
+	return nil
+}
+
+// runtimeExpiringEvent 生成claim即将到期的警告事件
+func runtimeExpiringEvent(info ContainerInfo, remaining time.Duration) events.Event {
+	message := fmt.Sprintf("claim %s reaches its runtime limit in %s and will be removed at %s",
+		info.ClaimID, remaining.Round(time.Second), time.Unix(info.ExpiresAt, 0).UTC().Format(time.RFC3339))
+	fmt.Printf("Warning: %s\n", message)
+	return events.Event{
+		Type:        EventClaimRuntimeExpiring,
+		Severity:    events.SeverityWarning,
+		ContainerID: info.ID,
+		ClaimID:     info.ClaimID,
+		Message:     message,
+		Data: map[string]interface{}{
+			"expires_at":        info.ExpiresAt,
+			"remaining_seconds": int64(remaining.Seconds()),
+			"gpu_ids":           info.GPUIDs,
+		},
+	}
+}
+
+// expireContainer 停止已到期的claim容器（先SIGTERM，宽限时间后SIGKILL）并删除
+func (m *Manager) expireContainer(ctx context.Context, info ContainerInfo, grace time.Duration) {
+	fmt.Printf("Claim %s reached its runtime limit, removing container %.12s\n", info.ClaimID, info.ID)
+	if info.Status == "running" {
+		seconds := strconv.Itoa(int(grace.Seconds()))
+		if err := dockerCommand(ctx, "stop", "-t", seconds, info.ID).Run(); err != nil {
+			fmt.Printf("Warning: failed to stop expired claim %s: %v\n", info.ClaimID, err)
+		}
+	}
+
+	err := m.RemoveContainer(ctx, info.ID)
+	if err != nil {
+		fmt.Printf("Warning: failed to remove expired claim %s: %v\n", info.ClaimID, err)
+		m.mu.Lock()
+		state := m.runtimeLimits[info.ID]
+		report := state != nil && !state.failed
+		if report {
+			state.failed = true
+		}
+		m.mu.Unlock()
+		if report {
+			m.publishRuntimeLimitEvent(events.Event{
+				Type:        EventClaimRuntimeExpiryFailed,
+				Severity:    events.SeverityError,
+				ContainerID: info.ID,
+				ClaimID:     info.ClaimID,
+				Message:     fmt.Sprintf("failed to remove claim %s after its runtime limit: %v", info.ClaimID, err),
+				Data: map[string]interface{}{
+					"expires_at": info.ExpiresAt,
+					"error":      err.Error(),
+				},
+			})
+		}
+		return
+	}
+
+	m.mu.Lock()
+	delete(m.runtimeLimits, info.ID)
+	m.mu.Unlock()
+
+	m.publishRuntimeLimitEvent(events.Event{
+		Type:        EventClaimRuntimeExpired,
+		Severity:    events.SeverityWarning,
+		ContainerID: info.ID,
+		ClaimID:     info.ClaimID,
+		Message:     fmt.Sprintf("removed claim %s after reaching its runtime limit", info.ClaimID),
+		Data: map[string]interface{}{
+			"expires_at":           info.ExpiresAt,
+			"grace_period_seconds": int64(grace.Seconds()),
+			"gpu_ids":              info.GPUIDs,
+		},
+	})
+}
+
+// publishRuntimeLimitEvent 发布运行时长上限事件
+func (m *Manager) publishRuntimeLimitEvent(event events.Event) {
+	m.mu.RLock()
+	bus := m.events
+	m.mu.RUnlock()
+	if bus != nil {
+		bus.Publish(event)
+	}
+}
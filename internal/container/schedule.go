@@ -0,0 +1,499 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/store"
+	"utopia-node-agent/internal/tracing"
+)
+
+var (
+	// ErrSchedulesDisabled 节点未启用定时启动
+	ErrSchedulesDisabled = errors.New("scheduled claims are disabled on this node")
+	// ErrScheduleExists claim已有未完成的定时启动
+	ErrScheduleExists = errors.New("claim is already scheduled")
+	// ErrScheduleNotFound 定时启动不存在
+	ErrScheduleNotFound = errors.New("schedule not found")
+	// ErrScheduleTooFar 启动时间超出允许的预约范围
+	ErrScheduleTooFar = errors.New("start time is too far in the future")
+	// ErrScheduleFinished 定时启动已开始或已结束，不能取消
+	ErrScheduleFinished = errors.New("schedule has already started or finished")
+)
+
+// 定时启动相关事件
+const (
+	// EventSchedulePrerequisiteFailed 镜像或磁盘空间等前置条件未满足
+	EventSchedulePrerequisiteFailed = "schedule.prerequisite_failed"
+	// EventScheduleStarted 到达启动时间，容器已创建
+	EventScheduleStarted = "schedule.started"
+	// EventScheduleStartFailed 到达启动时间，容器创建失败
+	EventScheduleStartFailed = "schedule.start_failed"
+)
+
+// ScheduleStatus 定时启动状态
+type ScheduleStatus string
+
+const (
+	ScheduleStatusPending   ScheduleStatus = "pending"
+	ScheduleStatusReady     ScheduleStatus = "ready"
+	ScheduleStatusStarting  ScheduleStatus = "starting"
+	ScheduleStatusStarted   ScheduleStatus = "started"
+	ScheduleStatusFailed    ScheduleStatus = "failed"
+	ScheduleStatusCancelled ScheduleStatus = "cancelled"
+)
+
+// SchedulePolicy 定时启动策略
+type SchedulePolicy struct {
+	// 最远可预约的时间
+	MaxAdvance time.Duration
+	// Docker数据目录至少保留的空闲空间（字节）
+	MinFreeDiskBytes uint64
+	// 在启动时间前多久开始预留GPU
+	ReserveLead time.Duration
+}
+
+// Schedule 定时启动的claim
+type Schedule struct {
+	ClaimID  string         `json:"claim_id"`
+	Image    string         `json:"image"`
+	GPUCount int            `json:"gpu_count"`
+	StartAt  int64          `json:"start_at"`
+	Status   ScheduleStatus `json:"status"`
+	// 镜像是否已预拉取
+	ImageReady bool `json:"image_ready"`
+	// 最近一次前置条件检查未通过的原因
+	Problem     string `json:"problem,omitempty"`
+	ContainerID string `json:"container_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+	CreatedAt   int64  `json:"created_at"`
+	FinishedAt  int64  `json:"finished_at,omitempty"`
+	// 创建请求中平台附加的元数据
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// scheduleRecord 持久化的定时启动记录，包含完整的创建请求
+type scheduleRecord struct {
+	Schedule
+	Request CreateRequest `json:"request"`
+}
+
+// active 是否尚未开始创建容器
+func (s Schedule) active() bool {
+	return s.Status == ScheduleStatusPending || s.Status == ScheduleStatusReady
+}
+
+// 定时启动的检查间隔与记录保留时间
+const (
+	scheduleTickInterval  = 5 * time.Second
+	scheduleRetryInterval = time.Minute
+	scheduleRetention     = 24 * time.Hour
+)
+
+// scheduler 定时启动的状态
+type scheduler struct {
+	mu      sync.Mutex
+	store   *store.Store
+	records map[string]*scheduleRecord // claimID -> 记录
+	// 正在准备的claim及下一次准备时间
+	preparing map[string]bool
+	nextCheck map[string]time.Time
+	// 请求携带的仓库凭据不持久化，Agent重启后改用节点凭据
+	registryAuth map[string]*RegistryAuth
+	wake         chan struct{}
+}
+
+// EnableSchedules 启用定时启动，记录持久化在dir中，重启后继续等待
+func (m *Manager) EnableSchedules(dir string) error {
+	st, err := store.Open(dir)
+	if err != nil {
+		return err
+	}
+	keys, err := st.Keys()
+	if err != nil {
+		return err
+	}
+
+	s := &scheduler{
+		store:        st,
+		records:      make(map[string]*scheduleRecord),
+		preparing:    make(map[string]bool),
+		nextCheck:    make(map[string]time.Time),
+		registryAuth: make(map[string]*RegistryAuth),
+		wake:         make(chan struct{}, 1),
+	}
+	for _, key := range keys {
+		var record scheduleRecord
+		if ok, err := st.Get(key, &record); err != nil || !ok {
+			fmt.Printf("Warning: failed to load schedule %s: %v\n", key, err)
+			continue
+		}
+		// 上次运行在创建容器的过程中退出，结果未知，按失败处理
+		if record.Status == ScheduleStatusStarting {
+			record.Status = ScheduleStatusFailed
+			record.Error = "agent restarted while the container was being created"
+			record.FinishedAt = time.Now().Unix()
+			st.Put(key, &record)
+		}
+		s.records[record.ClaimID] = &record
+	}
+
+	m.mu.Lock()
+	m.schedules = s
+	m.mu.Unlock()
+	return nil
+}
+
+// scheduleState 获取定时启动状态，未启用时返回nil
+func (m *Manager) scheduleState() *scheduler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.schedules
+}
+
+// ScheduleContainer 登记在req.StartAt启动的claim，之前预拉取镜像并检查磁盘空间
+func (m *Manager) ScheduleContainer(req *CreateRequest) (Schedule, error) {
+	s := m.scheduleState()
+	if s == nil {
+		return Schedule{}, ErrSchedulesDisabled
+	}
+
+	startAt := time.Unix(req.StartAt, 0)
+	if limit := m.getOptions().Schedule.MaxAdvance; limit > 0 && time.Until(startAt) > limit {
+		return Schedule{}, fmt.Errorf("%w: limit is %s", ErrScheduleTooFar, limit)
+	}
+
+	redactor := m.getRedactor()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, exists := s.records[req.ClaimID]; exists && (existing.active() || existing.Status == ScheduleStatusStarting) {
+		return Schedule{}, fmt.Errorf("%w: %s", ErrScheduleExists, req.ClaimID)
+	}
+
+	record := &scheduleRecord{
+		Schedule: Schedule{
+			ClaimID:   req.ClaimID,
+			Image:     req.Image,
+			GPUCount:  req.GPUCount,
+			StartAt:   req.StartAt,
+			Status:    ScheduleStatusPending,
+			CreatedAt: time.Now().Unix(),
+			Metadata:  redactor.Map(req.Metadata),
+		},
+		Request: *req,
+	}
+	record.Request.RegistryAuth = nil
+	if err := s.store.Put(req.ClaimID, record); err != nil {
+		return Schedule{}, fmt.Errorf("failed to save schedule: %w", err)
+	}
+	s.records[req.ClaimID] = record
+	delete(s.nextCheck, req.ClaimID)
+	if req.RegistryAuth != nil {
+		s.registryAuth[req.ClaimID] = req.RegistryAuth
+	} else {
+		delete(s.registryAuth, req.ClaimID)
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return record.Schedule, nil
+}
+
+// ListSchedules 列出定时启动，按启动时间排序
+func (m *Manager) ListSchedules() ([]Schedule, error) {
+	s := m.scheduleState()
+	if s == nil {
+		return nil, ErrSchedulesDisabled
+	}
+
+	s.mu.Lock()
+	result := make([]Schedule, 0, len(s.records))
+	for _, record := range s.records {
+		result = append(result, record.Schedule)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].StartAt < result[j].StartAt })
+	return result, nil
+}
+
+// GetSchedule 获取claim的定时启动
+func (m *Manager) GetSchedule(claimID string) (Schedule, error) {
+	s := m.scheduleState()
+	if s == nil {
+		return Schedule{}, ErrSchedulesDisabled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, exists := s.records[claimID]
+	if !exists {
+		return Schedule{}, ErrScheduleNotFound
+	}
+	return record.Schedule, nil
+}
+
+// CancelSchedule 取消尚未开始的定时启动并释放预留的GPU
+func (m *Manager) CancelSchedule(claimID string) (Schedule, error) {
+	s := m.scheduleState()
+	if s == nil {
+		return Schedule{}, ErrSchedulesDisabled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, exists := s.records[claimID]
+	if !exists {
+		return Schedule{}, ErrScheduleNotFound
+	}
+	if !record.active() {
+		return Schedule{}, fmt.Errorf("%w: status is %s", ErrScheduleFinished, record.Status)
+	}
+	record.Status = ScheduleStatusCancelled
+	record.FinishedAt = time.Now().Unix()
+	s.saveLocked(record)
+	delete(s.registryAuth, claimID)
+	return record.Schedule, nil
+}
+
+// ReservedGPUs 返回为其他claim的定时启动预留的GPU数量
+// 启动时间前 ReserveLead 内的定时启动占用预留，立即创建的容器不能使用这些GPU
+func (m *Manager) ReservedGPUs(claimID string) int {
+	return m.reservedGPUsExcept(map[string]bool{claimID: true})
+}
+
+// reservedGPUsExcept 返回为给定claim之外的定时启动预留的GPU数量
+func (m *Manager) reservedGPUsExcept(claims map[string]bool) int {
+	s := m.scheduleState()
+	if s == nil {
+		return 0
+	}
+	lead := m.getOptions().Schedule.ReserveLead
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reserved := 0
+	for id, record := range s.records {
+		if claims[id] || record.Request.SharesGPU() {
+			continue
+		}
+		if (record.active() || record.Status == ScheduleStatusStarting) &&
+			!now.Before(time.Unix(record.StartAt, 0).Add(-lead)) {
+			reserved += record.GPUCount
+		}
+	}
+	return reserved
+}
+
+// RunSchedules 运行定时启动循环，直到ctx取消
+func (m *Manager) RunSchedules(ctx context.Context) {
+	s := m.scheduleState()
+	if s == nil {
+		return
+	}
+
+	ticker := time.NewTicker(scheduleTickInterval)
+	defer ticker.Stop()
+
+	for {
+		m.processSchedules(ctx, s)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// processSchedules 准备未到期的定时启动，并依次启动已到期的
+// 到期的claim在循环中串行创建，避免同时到期的claim分配到同一GPU
+func (m *Manager) processSchedules(ctx context.Context, s *scheduler) {
+	now := time.Now()
+	var due []scheduleRecord
+
+	s.mu.Lock()
+	for id, record := range s.records {
+		switch {
+		case !record.active():
+			if record.FinishedAt > 0 && now.Sub(time.Unix(record.FinishedAt, 0)) > scheduleRetention {
+				delete(s.records, id)
+				delete(s.nextCheck, id)
+				delete(s.registryAuth, id)
+				s.store.Delete(id)
+			}
+		case !now.Before(time.Unix(record.StartAt, 0)):
+			if !s.preparing[id] {
+				record.Status = ScheduleStatusStarting
+				s.saveLocked(record)
+				due = append(due, *record)
+			}
+		case !s.preparing[id] && !now.Before(s.nextCheck[id]):
+			s.preparing[id] = true
+			go m.prepareSchedule(ctx, s, id, record.Request.Image)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].StartAt < due[j].StartAt })
+	for _, record := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		m.startSchedule(ctx, s, record)
+	}
+}
+
+// prepareSchedule 检查磁盘空间并预拉取镜像，未满足时发布事件并稍后重试
+func (m *Manager) prepareSchedule(ctx context.Context, s *scheduler, claimID, image string) {
+	var problems []string
+	if err := m.checkScheduleDiskSpace(ctx); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	s.mu.Lock()
+	record, exists := s.records[claimID]
+	imageReady := exists && record.ImageReady
+	auth := s.registryAuth[claimID]
+	s.mu.Unlock()
+
+	if !imageReady && len(problems) == 0 {
+		if err := m.PullImage(ctx, image, auth); err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			imageReady = true
+		}
+	}
+
+	problem := strings.Join(problems, "; ")
+
+	s.mu.Lock()
+	delete(s.preparing, claimID)
+	record, exists = s.records[claimID]
+	if !exists || !record.active() {
+		s.mu.Unlock()
+		return
+	}
+	changed := problem != "" && problem != record.Problem
+	record.ImageReady = imageReady
+	record.Problem = problem
+	if problem == "" {
+		record.Status = ScheduleStatusReady
+		// 就绪后仍定期检查磁盘空间
+		s.nextCheck[claimID] = time.Now().Add(scheduleRetryInterval * 5)
+	} else {
+		record.Status = ScheduleStatusPending
+		s.nextCheck[claimID] = time.Now().Add(scheduleRetryInterval)
+	}
+	s.saveLocked(record)
+	snapshot := record.Schedule
+	s.mu.Unlock()
+
+	if changed {
+		fmt.Printf("Warning: scheduled claim %s is not ready: %s\n", claimID, problem)
+		m.publishScheduleEvent(EventSchedulePrerequisiteFailed, events.SeverityWarning, snapshot,
+			fmt.Sprintf("prerequisites not met: %s", problem))
+	}
+}
+
+// startSchedule 到达启动时间后创建容器，前置条件未满足时仍尝试创建并上报
+func (m *Manager) startSchedule(ctx context.Context, s *scheduler, record scheduleRecord) {
+	if !record.ImageReady || record.Problem != "" {
+		problem := record.Problem
+		if problem == "" {
+			problem = "image was not pulled before the start time"
+		}
+		m.publishScheduleEvent(EventSchedulePrerequisiteFailed, events.SeverityError, record.Schedule,
+			fmt.Sprintf("prerequisites not met at start time: %s", problem))
+	}
+
+	req := record.Request
+	s.mu.Lock()
+	req.RegistryAuth = s.registryAuth[record.ClaimID]
+	delete(s.registryAuth, record.ClaimID)
+	s.mu.Unlock()
+	createCtx, span := tracing.Start(ctx, "container.StartScheduled")
+	containerID, err := m.CreateContainer(createCtx, &req)
+	tracing.End(span, err)
+
+	s.mu.Lock()
+	if current, exists := s.records[record.ClaimID]; exists {
+		current.FinishedAt = time.Now().Unix()
+		if err != nil {
+			current.Status = ScheduleStatusFailed
+			current.Error = err.Error()
+		} else {
+			current.Status = ScheduleStatusStarted
+			current.ContainerID = containerID
+		}
+		s.saveLocked(current)
+		record.Schedule = current.Schedule
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		fmt.Printf("Warning: failed to start scheduled claim %s: %v\n", record.ClaimID, err)
+		m.publishScheduleEvent(EventScheduleStartFailed, events.SeverityError, record.Schedule,
+			fmt.Sprintf("failed to start scheduled claim: %v", err))
+		return
+	}
+	fmt.Printf("Scheduled claim %s started (container: %s)\n", record.ClaimID, containerID)
+	m.publishScheduleEvent(EventScheduleStarted, events.SeverityInfo, record.Schedule, "scheduled claim started")
+}
+
+// checkScheduleDiskSpace 检查Docker数据目录的空闲空间是否满足策略
+func (m *Manager) checkScheduleDiskSpace(ctx context.Context) error {
+	minFree := m.getOptions().Schedule.MinFreeDiskBytes
+	if minFree == 0 {
+		return nil
+	}
+
+	free, err := m.dockerRootFreeBytes(ctx)
+	if err != nil {
+		return err
+	}
+	if free < minFree {
+		return fmt.Errorf("insufficient disk space: %d GB free, %d GB required", free>>30, minFree>>30)
+	}
+	return nil
+}
+
+// saveLocked 持久化记录，调用方需持有s.mu
+func (s *scheduler) saveLocked(record *scheduleRecord) {
+	if err := s.store.Put(record.ClaimID, record); err != nil {
+		fmt.Printf("Warning: failed to save schedule %s: %v\n", record.ClaimID, err)
+	}
+}
+
+// publishScheduleEvent 发布定时启动事件
+func (m *Manager) publishScheduleEvent(eventType, severity string, schedule Schedule, message string) {
+	m.mu.RLock()
+	bus := m.events
+	m.mu.RUnlock()
+	if bus == nil {
+		return
+	}
+	bus.Publish(events.Event{
+		Type:        eventType,
+		Severity:    severity,
+		ContainerID: schedule.ContainerID,
+		ClaimID:     schedule.ClaimID,
+		Message:     message,
+		Data: map[string]interface{}{
+			"image":       schedule.Image,
+			"start_at":    schedule.StartAt,
+			"image_ready": schedule.ImageReady,
+		},
+	})
+}
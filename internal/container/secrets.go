@@ -0,0 +1,82 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"utopia-node-agent/internal/secrets"
+)
+
+// ErrSecretsDisabled 节点未启用密钥注入
+var ErrSecretsDisabled = errors.New("secrets delivery is disabled on this node")
+
+// SetSecrets 启用密钥注入：keypair用于解密，文件密钥写入位于tmpfs的runtimeDir
+func (m *Manager) SetSecrets(keypair *secrets.Keypair, runtimeDir string) {
+	m.mu.Lock()
+	m.secretsKeypair = keypair
+	m.secretsDir = runtimeDir
+	m.mu.Unlock()
+}
+
+// prepareSecrets 解密密钥：文件密钥写入tmpfs并只读绑定挂载，环境变量密钥通过docker客户端进程环境传递
+// 返回docker run参数与需要加入docker客户端进程环境的变量
+func (m *Manager) prepareSecrets(containerName string, list []secrets.Secret) (args []string, env []string, err error) {
+	if len(list) == 0 {
+		return nil, nil, nil
+	}
+
+	m.mu.RLock()
+	keypair, dir := m.secretsKeypair, m.secretsDir
+	m.mu.RUnlock()
+	if keypair == nil {
+		return nil, nil, ErrSecretsDisabled
+	}
+
+	secretDir := filepath.Join(dir, containerName)
+	if err := os.MkdirAll(secretDir, 0700); err != nil {
+		return nil, nil, fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(secretDir)
+		}
+	}()
+
+	for i, secret := range list {
+		plaintext, err := keypair.Open(secret.Ciphertext)
+		if err != nil {
+			return nil, nil, fmt.Errorf("secret %s: %w", secret.Name, err)
+		}
+
+		if secret.Env != "" {
+			// 只传变量名，值由docker客户端从自身环境读取，不出现在命令行中
+			args = append(args, "-e", secret.Env)
+			env = append(env, secret.Env+"="+string(plaintext))
+			continue
+		}
+
+		hostPath := filepath.Join(secretDir, strconv.Itoa(i)+"-"+secret.Name)
+		if err := os.WriteFile(hostPath, plaintext, 0444); err != nil {
+			return nil, nil, fmt.Errorf("failed to write secret %s: %w", secret.Name, err)
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", hostPath, secret.Path))
+	}
+
+	return args, env, nil
+}
+
+// cleanupSecrets 删除容器的密钥文件
+func (m *Manager) cleanupSecrets(containerName string) {
+	m.mu.RLock()
+	dir := m.secretsDir
+	m.mu.RUnlock()
+	if dir == "" {
+		return
+	}
+	if err := os.RemoveAll(filepath.Join(dir, containerName)); err != nil {
+		fmt.Printf("Warning: failed to remove secrets for %s: %v\n", containerName, err)
+	}
+}
@@ -0,0 +1,80 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+
+	"utopia-node-agent/internal/security"
+)
+
+// ErrRelaxationNotAllowed 节点策略不允许请求的安全限制放宽
+var ErrRelaxationNotAllowed = errors.New("security relaxation is not allowed by node policy")
+
+// securityRelaxationsLabel 记录容器放宽的安全限制
+const securityRelaxationsLabel = "utopia.security_relaxations"
+
+// SecurityPolicy 容器强制访问控制策略
+type SecurityPolicy struct {
+	// 主机上生效的强制访问控制情况
+	Status security.Status
+	// 允许创建请求放宽的限制
+	AllowedRelaxations []string
+}
+
+// SecurityStatus 安全配置的生效情况与允许的放宽项
+type SecurityStatus struct {
+	security.Status
+	AllowedRelaxations []string `json:"allowed_relaxations"`
+}
+
+// GetSecurityStatus 获取容器安全配置的生效情况
+func (m *Manager) GetSecurityStatus() SecurityStatus {
+	policy := m.getOptions().Security
+	allowed := policy.AllowedRelaxations
+	if allowed == nil {
+		allowed = []string{}
+	}
+	return SecurityStatus{Status: policy.Status, AllowedRelaxations: allowed}
+}
+
+// securityArgs 按节点策略与请求的放宽项生成 docker run --security-opt 参数
+func securityArgs(relaxations []string, policy SecurityPolicy) ([]string, error) {
+	relaxed := make(map[string]bool, len(relaxations))
+	for _, name := range relaxations {
+		if !containsString(policy.AllowedRelaxations, name) {
+			return nil, fmt.Errorf("%w: %s", ErrRelaxationNotAllowed, name)
+		}
+		relaxed[name] = true
+	}
+
+	var args []string
+	switch policy.Status.Mechanism {
+	case security.MechanismAppArmor:
+		if relaxed[security.RelaxUnconfined] {
+			args = append(args, "--security-opt", "apparmor=unconfined")
+		} else if policy.Status.Enforced {
+			args = append(args, "--security-opt", "apparmor="+policy.Status.Profile)
+		}
+	case security.MechanismSELinux:
+		if relaxed[security.RelaxUnconfined] {
+			args = append(args, "--security-opt", "label=disable")
+		} else if policy.Status.Enforced {
+			args = append(args, "--security-opt", "label=type:"+policy.Status.Profile)
+		}
+	}
+	if relaxed[security.RelaxSeccompUnconfined] {
+		args = append(args, "--security-opt", "seccomp=unconfined")
+	}
+
+	return args, nil
+}
+
+// containsString 判断切片是否包含指定字符串
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrStorageQuotaUnsupported 存储驱动不支持可写层大小限制
+var ErrStorageQuotaUnsupported = errors.New("storage quota is not supported by the docker storage driver")
+
+// StorageQuotaSupport 可写层配额支持情况
+type StorageQuotaSupport struct {
+	Supported bool   `json:"supported"`
+	Driver    string `json:"driver"`
+	BackingFS string `json:"backing_fs,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// dockerInfo docker info输出中与存储相关的字段
+type dockerInfo struct {
+	Driver        string      `json:"Driver"`
+	DriverStatus  [][2]string `json:"DriverStatus"`
+	DockerRootDir string      `json:"DockerRootDir"`
+}
+
+// detectStorageQuotaSupport 检测Docker存储驱动是否支持 --storage-opt size
+func detectStorageQuotaSupport(ctx context.Context) StorageQuotaSupport {
+	output, err := exec.CommandContext(ctx, "docker", "info", "--format", "{{json .}}").Output()
+	if err != nil {
+		return StorageQuotaSupport{Reason: fmt.Sprintf("failed to query docker info: %v", err)}
+	}
+
+	var info dockerInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		return StorageQuotaSupport{Reason: fmt.Sprintf("failed to parse docker info: %v", err)}
+	}
+
+	support := StorageQuotaSupport{Driver: info.Driver}
+	for _, status := range info.DriverStatus {
+		if status[0] == "Backing Filesystem" {
+			support.BackingFS = status[1]
+		}
+	}
+
+	switch info.Driver {
+	case "devicemapper", "btrfs", "zfs":
+		support.Supported = true
+	case "overlay2":
+		// overlay2 仅在 xfs 且以 pquota 挂载时支持大小限制
+		if support.BackingFS != "xfs" {
+			support.Reason = fmt.Sprintf("overlay2 requires xfs backing filesystem, got %q", support.BackingFS)
+			break
+		}
+		options, err := mountOptionsFor(info.DockerRootDir)
+		if err != nil {
+			support.Reason = fmt.Sprintf("failed to read mount options: %v", err)
+			break
+		}
+		if !hasProjectQuota(options) {
+			support.Reason = "xfs backing filesystem is not mounted with pquota"
+			break
+		}
+		support.Supported = true
+	default:
+		support.Reason = fmt.Sprintf("storage driver %q does not support size limits", info.Driver)
+	}
+
+	return support
+}
+
+// mountOptionsFor 返回包含指定路径的挂载点的挂载选项
+func mountOptionsFor(path string) ([]string, error) {
+	if path == "" {
+		path = "/var/lib/docker"
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	file, err := os.Open("/proc/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// 选择最长匹配的挂载点
+	var bestMount string
+	var bestOptions []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		mountPoint := fields[1]
+		if !isSubPath(path, mountPoint) || len(mountPoint) < len(bestMount) {
+			continue
+		}
+		bestMount = mountPoint
+		bestOptions = strings.Split(fields[3], ",")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if bestMount == "" {
+		return nil, fmt.Errorf("no mount point found for %s", path)
+	}
+	return bestOptions, nil
+}
+
+// isSubPath 判断path是否位于mountPoint之下
+func isSubPath(path, mountPoint string) bool {
+	if mountPoint == "/" {
+		return true
+	}
+	return path == mountPoint || strings.HasPrefix(path, mountPoint+"/")
+}
+
+// hasProjectQuota 判断挂载选项是否启用了项目配额
+func hasProjectQuota(options []string) bool {
+	for _, opt := range options {
+		switch opt {
+		case "pquota", "prjquota":
+			return true
+		}
+	}
+	return false
+}
+
+// dockerRootFreeBytes 返回Docker数据目录所在文件系统的可用空间（字节）
+func (m *Manager) dockerRootFreeBytes(ctx context.Context) (uint64, error) {
+	root, err := m.dockerRootDir(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var stat unix.Statfs_t
+	if err := unix.Statfs(root, &stat); err != nil {
+		return 0, fmt.Errorf("failed to stat docker root dir: %v", err)
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
+
+// dockerRootDir 返回Docker数据目录，首次查询后缓存
+func (m *Manager) dockerRootDir(ctx context.Context) (string, error) {
+	m.mu.RLock()
+	root := m.dockerRoot
+	m.mu.RUnlock()
+	if root == "" {
+		output, err := dockerCommand(ctx, "info", "--format", "{{.DockerRootDir}}").Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to query docker root dir: %v", err)
+		}
+		root = strings.TrimSpace(string(output))
+		m.mu.Lock()
+		m.dockerRoot = root
+		m.mu.Unlock()
+	}
+	return root, nil
+}
@@ -0,0 +1,61 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"utopia-node-agent/internal/events"
+)
+
+// ErrCreateTimeout 容器创建超过时限
+var ErrCreateTimeout = errors.New("container create timed out")
+
+// EventCreateTimeout 容器创建超时，未完成的容器已被清理
+const EventCreateTimeout = "container.create_timeout"
+
+// cleanupTimeout 清理超时创建的容器的时限
+const cleanupTimeout = 30 * time.Second
+
+// handleCreateTimeout 删除超时创建遗留的容器并释放其占用的名称与GPU，返回超时错误
+// docker run 被取消时容器可能已经创建，不删除会占用容器名并使GPU保持被占用
+func (m *Manager) handleCreateTimeout(req *CreateRequest, timeout time.Duration, cause error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	containerName := containerNameFor(req.ClaimID)
+	if output, err := dockerCommand(ctx, "rm", "-f", "-v", containerName).CombinedOutput(); err != nil {
+		fmt.Printf("Warning: failed to remove timed out container %s: %v: %s\n", containerName, err, output)
+	}
+
+	m.mu.Lock()
+	for id, info := range m.containers {
+		if info.ClaimID == req.ClaimID {
+			m.deleteContainerLocked(id)
+		}
+	}
+	bus := m.events
+	m.mu.Unlock()
+
+	m.cleanupSecrets(containerName)
+	m.releaseExternalVolumes(containerName)
+	if req.SharedCompute != nil {
+		m.ReleaseMPS(ctx)
+	}
+
+	if bus != nil {
+		bus.Publish(events.Event{
+			Type:     EventCreateTimeout,
+			Severity: events.SeverityError,
+			ClaimID:  req.ClaimID,
+			Message:  fmt.Sprintf("container create did not finish within %s and was cleaned up", timeout),
+			Data: map[string]interface{}{
+				"image":           req.Image,
+				"timeout_seconds": int(timeout.Seconds()),
+			},
+		})
+	}
+
+	return fmt.Errorf("%w after %s: %v", ErrCreateTimeout, timeout, cause)
+}
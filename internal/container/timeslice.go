@@ -0,0 +1,217 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+var (
+	// ErrTimeSlicingDisabled 节点未启用GPU时间片共享
+	ErrTimeSlicingDisabled = errors.New("GPU time-slicing is disabled on this node")
+	// ErrNoFractionalGPU 没有剩余份额足够的GPU
+	ErrNoFractionalGPU = errors.New("no GPU with enough time-slice capacity")
+)
+
+const (
+	// gpuFractionLabel 记录时间片共享容器占用的GPU份额
+	gpuFractionLabel = "utopia.gpu_fraction"
+	// gpuFractionEnvVar 告知容器内进程分到的GPU份额
+	gpuFractionEnvVar = "UTOPIA_GPU_FRACTION"
+	// fractionEpsilon 比较份额时的容差
+	fractionEpsilon = 1e-6
+)
+
+// TimeSlicingPolicy GPU时间片共享策略
+type TimeSlicingPolicy struct {
+	// 每块GPU划分的份数，最小份额为 1/ReplicasPerGPU，0表示不启用
+	ReplicasPerGPU int
+	// 最多用于时间片共享的GPU数，0表示不限制
+	MaxGPUs int
+}
+
+// Enabled 是否启用时间片共享
+func (p TimeSlicingPolicy) Enabled() bool {
+	return p.ReplicasPerGPU > 1
+}
+
+// Unit 最小份额
+func (p TimeSlicingPolicy) Unit() float64 {
+	return 1 / float64(p.ReplicasPerGPU)
+}
+
+// validFraction 份额是否为最小份额的整数倍且小于一块GPU
+func (p TimeSlicingPolicy) validFraction(fraction float64) bool {
+	units := fraction * float64(p.ReplicasPerGPU)
+	rounded := math.Round(units)
+	return rounded >= 1 && rounded < float64(p.ReplicasPerGPU) && math.Abs(units-rounded) < fractionEpsilon
+}
+
+// TimeSlicedGPU 时间片共享GPU的占用情况
+type TimeSlicedGPU struct {
+	GPUID int `json:"gpu_id"`
+	// 已分配的份额之和
+	Allocated float64  `json:"allocated"`
+	Claims    []string `json:"claims"`
+}
+
+// TimeSlicingStatus 时间片共享的份额与余量，随心跳上报平台
+type TimeSlicingStatus struct {
+	ReplicasPerGPU int     `json:"replicas_per_gpu"`
+	Unit           float64 `json:"unit"`
+	MaxGPUs        int     `json:"max_gpus,omitempty"`
+	// 已有时间片共享容器的GPU
+	GPUs []TimeSlicedGPU `json:"gpus"`
+	// 还可分配的最小份额数：已共享GPU的剩余份额加上可启用的空闲GPU的全部份额
+	AvailableUnits int `json:"available_units"`
+}
+
+// SharesGPU 请求是否与其他容器共享GPU（MPS共享计算或时间片共享）
+func (r *CreateRequest) SharesGPU() bool {
+	return r.SharedCompute != nil || r.GPUFraction > 0
+}
+
+// validateGPUFraction 验证请求的GPU份额，份额粒度由节点策略在准入时检查
+func (r *CreateRequest) validateGPUFraction() error {
+	switch {
+	case r.GPUFraction < 0 || r.GPUFraction >= 1:
+		return fmt.Errorf("must be greater than 0 and less than 1")
+	case r.GPUCount != 1:
+		return fmt.Errorf("requires gpu_count of 1")
+	case r.SharedCompute != nil:
+		return fmt.Errorf("cannot be combined with shared_compute")
+	}
+	return nil
+}
+
+// parseGPUFraction 解析容器占用的GPU份额，独占或MPS共享的容器为0
+func parseGPUFraction(labels map[string]string) float64 {
+	fraction, _ := strconv.ParseFloat(labels[gpuFractionLabel], 64)
+	return fraction
+}
+
+// timeSliceArgs 告知容器分到的GPU份额；时间片共享时容器看到的是整块GPU，由驱动在容器间轮转
+func timeSliceArgs(fraction float64) []string {
+	return []string{"-e", gpuFractionEnvVar + "=" + strconv.FormatFloat(fraction, 'f', -1, 64)}
+}
+
+// timeSliceUsage 统计各GPU上时间片共享容器与创建中请求占用的份额（调用方需持有admitMu）
+// 未保留GPU休眠的claim不占用份额
+func (m *Manager) timeSliceUsage() map[int]*TimeSlicedGPU {
+	usage := make(map[int]*TimeSlicedGPU)
+	add := func(d resourceDemand, claimID string) {
+		if d.slicedGPU < 0 {
+			return
+		}
+		g, ok := usage[d.slicedGPU]
+		if !ok {
+			g = &TimeSlicedGPU{GPUID: d.slicedGPU, Claims: []string{}}
+			usage[d.slicedGPU] = g
+		}
+		g.Allocated += d.gpuFraction
+		g.Claims = append(g.Claims, claimID)
+	}
+	policy := m.getOptions().Overcommit
+	for _, info := range m.ListContainers() {
+		if info.Hibernation == nil {
+			add(demandOf(info, policy), info.ClaimID)
+		}
+	}
+	for req, d := range m.admitting {
+		add(d, req.ClaimID)
+	}
+	return usage
+}
+
+// allocateFractionalGPU 为时间片共享请求选择GPU（调用方需持有admitMu）
+// 优先放到剩余份额足够的已共享GPU中占用最多的一个以集中放置，否则按评分启用一个空闲GPU
+func (m *Manager) allocateFractionalGPU(req *CreateRequest, available []int) (int, error) {
+	policy := m.getOptions().TimeSlicing
+	if !policy.Enabled() {
+		return 0, ErrTimeSlicingDisabled
+	}
+	if !policy.validFraction(req.GPUFraction) {
+		return 0, &ValidationError{Fields: []FieldError{{
+			Field:   "gpu_fraction",
+			Message: fmt.Sprintf("must be a multiple of %g below 1 on this node", policy.Unit()),
+		}}}
+	}
+
+	usage := m.timeSliceUsage()
+	best := -1
+	for gpuID, g := range usage {
+		if g.Allocated+req.GPUFraction > 1+fractionEpsilon {
+			continue
+		}
+		if best < 0 || g.Allocated > usage[best].Allocated || (g.Allocated == usage[best].Allocated && gpuID < best) {
+			best = gpuID
+		}
+	}
+	if best >= 0 {
+		return best, nil
+	}
+
+	if policy.MaxGPUs > 0 && len(usage) >= policy.MaxGPUs {
+		return 0, fmt.Errorf("%w: all %d time-sliced GPUs are full", ErrNoFractionalGPU, policy.MaxGPUs)
+	}
+	// 为其他claim定时启动预留的GPU不启用
+	candidates := m.withoutSharedGPUs(available, usage)
+	if len(candidates) <= m.ReservedGPUs(req.ClaimID) {
+		return 0, fmt.Errorf("%w: no idle GPU to start time-slicing on", ErrNoFractionalGPU)
+	}
+	selection := m.gpuMonitor.SelectGPUs(candidates, 1)
+	if len(selection.GPUIDs) != 1 {
+		return 0, fmt.Errorf("%w: no idle GPU to start time-slicing on", ErrNoFractionalGPU)
+	}
+	return selection.GPUIDs[0], nil
+}
+
+// timeSlicedGPUs 返回有时间片共享容器的GPU
+func (m *Manager) timeSlicedGPUs() map[int]*TimeSlicedGPU {
+	m.admitMu.Lock()
+	defer m.admitMu.Unlock()
+	return m.timeSliceUsage()
+}
+
+// timeSliceFits GPU是否还能容纳给定份额：已有时间片共享容器时看剩余份额，否则GPU须空闲
+func (m *Manager) timeSliceFits(gpuID int, fraction float64) bool {
+	usage := m.timeSlicedGPUs()
+	if g, ok := usage[gpuID]; ok {
+		return g.Allocated+fraction <= 1+fractionEpsilon
+	}
+	for _, id := range m.withoutSharedGPUs(m.gpuMonitor.GetAvailableGPUs(), usage) {
+		if id == gpuID {
+			return true
+		}
+	}
+	return false
+}
+
+// TimeSlicingStatus 返回时间片共享的份额与余量，未启用时返回nil
+func (m *Manager) TimeSlicingStatus() *TimeSlicingStatus {
+	policy := m.getOptions().TimeSlicing
+	if !policy.Enabled() {
+		return nil
+	}
+	usage := m.timeSlicedGPUs()
+	status := &TimeSlicingStatus{
+		ReplicasPerGPU: policy.ReplicasPerGPU,
+		Unit:           policy.Unit(),
+		MaxGPUs:        policy.MaxGPUs,
+		GPUs:           []TimeSlicedGPU{},
+	}
+	for _, g := range usage {
+		status.GPUs = append(status.GPUs, *g)
+		status.AvailableUnits += int(math.Floor((1-g.Allocated)*float64(policy.ReplicasPerGPU) + fractionEpsilon))
+	}
+	sort.Slice(status.GPUs, func(i, j int) bool { return status.GPUs[i].GPUID < status.GPUs[j].GPUID })
+
+	idle := len(m.withoutSharedGPUs(m.gpuMonitor.GetAvailableGPUs(), usage)) - m.ReservedGPUs("")
+	if policy.MaxGPUs > 0 {
+		idle = min(idle, policy.MaxGPUs-len(usage))
+	}
+	status.AvailableUnits += max(idle, 0) * policy.ReplicasPerGPU
+	return status
+}
@@ -0,0 +1,85 @@
+package container
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// UlimitUnlimited 不限制
+const UlimitUnlimited = -1
+
+// ulimitNames 允许设置的ulimit
+var ulimitNames = map[string]bool{"nofile": true, "nproc": true, "core": true, "memlock": true, "stack": true}
+
+// Ulimit 容器进程的资源限制，-1表示不限制；core、memlock、stack 的单位为字节
+type Ulimit struct {
+	Soft int64 `json:"soft"`
+	Hard int64 `json:"hard"`
+}
+
+// ValidateUlimits 验证ulimit名称与软硬限制
+func ValidateUlimits(ulimits map[string]Ulimit) error {
+	for _, name := range sortedUlimitNames(ulimits) {
+		limit := ulimits[name]
+		if !ulimitNames[name] {
+			return fmt.Errorf("unsupported ulimit %q, expected nofile, nproc, core, memlock or stack", name)
+		}
+		if limit.Soft < UlimitUnlimited || limit.Hard < UlimitUnlimited {
+			return fmt.Errorf("ulimit %s must be non-negative or -1 (unlimited)", name)
+		}
+		if limit.Hard != UlimitUnlimited && (limit.Soft == UlimitUnlimited || limit.Soft > limit.Hard) {
+			return fmt.Errorf("ulimit %s soft limit exceeds the hard limit", name)
+		}
+		if name == "nofile" && (limit.Soft == UlimitUnlimited || limit.Hard == UlimitUnlimited) {
+			return fmt.Errorf("ulimit nofile cannot be unlimited")
+		}
+	}
+	return nil
+}
+
+// ulimitArgs 合并节点默认值与请求的ulimit，生成 docker run --ulimit 参数
+// 启用核心转储收集时 core 不超过单个转储的大小上限
+func ulimitArgs(req *CreateRequest, options Options, maxCoreBytes int64) []string {
+	merged := make(map[string]Ulimit, len(options.Ulimits)+len(req.Ulimits)+1)
+	for name, limit := range options.Ulimits {
+		merged[name] = limit
+	}
+	for name, limit := range req.Ulimits {
+		merged[name] = limit
+	}
+	if maxCoreBytes > 0 {
+		core, ok := merged["core"]
+		if !ok {
+			core = Ulimit{Soft: maxCoreBytes, Hard: maxCoreBytes}
+		}
+		core.Soft = capUlimit(core.Soft, maxCoreBytes)
+		core.Hard = capUlimit(core.Hard, maxCoreBytes)
+		merged["core"] = core
+	}
+
+	var args []string
+	for _, name := range sortedUlimitNames(merged) {
+		limit := merged[name]
+		args = append(args, "--ulimit", name+"="+strconv.FormatInt(limit.Soft, 10)+":"+strconv.FormatInt(limit.Hard, 10))
+	}
+	return args
+}
+
+// capUlimit 将限制值限制在上限以内
+func capUlimit(value, limit int64) int64 {
+	if value == UlimitUnlimited || value > limit {
+		return limit
+	}
+	return value
+}
+
+// sortedUlimitNames 按名称排序，使参数与错误信息稳定
+func sortedUlimitNames(ulimits map[string]Ulimit) []string {
+	names := make([]string, 0, len(ulimits))
+	for name := range ulimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
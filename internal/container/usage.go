@@ -0,0 +1,209 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ContainerUsage 容器资源使用的累计计数（自容器启动起）
+type ContainerUsage struct {
+	ID      string `json:"id"`
+	ClaimID string `json:"claim_id"`
+	GPUIDs  []int  `json:"gpu_ids"`
+	Running bool   `json:"running"`
+	// 时间片共享时占用的GPU份额，独占GPU时为0
+	GPUFraction float64 `json:"gpu_fraction,omitempty"`
+	// 最近一次按进程统计的claim GPU平均利用率（百分比），未统计时为nil
+	GPUUtilizationPercent *float64 `json:"gpu_utilization_percent,omitempty"`
+	// 累计CPU时间（纳秒）
+	CPUUsageNanos  uint64 `json:"cpu_usage_nanos"`
+	NetworkRxBytes uint64 `json:"network_rx_bytes"`
+	NetworkTxBytes uint64 `json:"network_tx_bytes"`
+	// 可写层占用
+	DiskUsageBytes int64 `json:"disk_usage_bytes"`
+	// claim休眠中，休眠时保留GPU的为ReservedGPUs
+	Hibernated   bool `json:"hibernated"`
+	ReservedGPUs int  `json:"reserved_gpus"`
+	// 创建请求中平台附加的元数据
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// dockerUsageInspect docker inspect --size 输出中与资源统计相关的字段
+type dockerUsageInspect struct {
+	ID     string `json:"Id"`
+	SizeRw int64  `json:"SizeRw"`
+	State  struct {
+		Running bool `json:"Running"`
+		Pid     int  `json:"Pid"`
+	} `json:"State"`
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	HostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+}
+
+// CollectUsage 采集所有受管容器的资源使用
+// CPU时间取自容器cgroup，网络流量取自容器网络命名空间，磁盘占用为可写层大小
+func (m *Manager) CollectUsage(ctx context.Context) ([]ContainerUsage, error) {
+	// 直接查询docker而非使用缓存，避免缓存刷新期间误判容器已停止
+	output, err := dockerCommand(ctx, "ps", "-a", "--filter", "label=utopia.managed=true", "--format", "{{.ID}}").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	containerIDs := strings.Fields(string(output))
+	if len(containerIDs) == 0 {
+		return nil, nil
+	}
+
+	output, err = dockerCommand(ctx, append([]string{"inspect", "--size"}, containerIDs...)...).Output()
+	if err != nil && len(output) == 0 {
+		return nil, fmt.Errorf("failed to inspect containers: %w", err)
+	}
+
+	var inspected []dockerUsageInspect
+	if err := json.Unmarshal(output, &inspected); err != nil {
+		return nil, fmt.Errorf("failed to parse container info: %w", err)
+	}
+
+	result := make([]ContainerUsage, 0, len(inspected))
+	for _, item := range inspected {
+		usage := ContainerUsage{
+			ID:             item.ID,
+			ClaimID:        item.Config.Labels["utopia.claim_id"],
+			GPUIDs:         m.containerGPUIDs(item.Config.Labels),
+			Running:        item.State.Running,
+			DiskUsageBytes: item.SizeRw,
+			Metadata:       parseMetadata(item.Config.Labels),
+			GPUFraction:    parseGPUFraction(item.Config.Labels),
+		}
+		if util, ok := m.latestGPUUtilization(usage.ClaimID); ok && item.State.Running {
+			usage.GPUUtilizationPercent = &util
+		}
+		if item.State.Running && item.State.Pid > 0 {
+			if nanos, err := readCgroupCPUNanos(item.State.Pid); err == nil {
+				usage.CPUUsageNanos = nanos
+			}
+			// host网络模式下无法区分容器流量
+			if item.HostConfig.NetworkMode != "host" {
+				if rx, tx, err := readNetDevBytes(item.State.Pid); err == nil {
+					usage.NetworkRxBytes = rx
+					usage.NetworkTxBytes = tx
+				}
+			}
+		}
+		m.mu.RLock()
+		if record := m.hibernationFor(usage.ClaimID, item.ID); record != nil && !item.State.Running {
+			usage.Hibernated = true
+			if record.KeepGPUs {
+				usage.ReservedGPUs = len(record.GPUIDs)
+			}
+		}
+		m.mu.RUnlock()
+		result = append(result, usage)
+	}
+
+	return result, nil
+}
+
+// readCgroupCPUNanos 读取进程所在cgroup的累计CPU时间，支持cgroup v1与v2
+func readCgroupCPUNanos(pid int) (uint64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		// cgroup v2: "0::/system.slice/docker-<id>.scope"
+		if parts[0] == "0" && parts[1] == "" {
+			return readCPUStatUsage("/sys/fs/cgroup" + parts[2] + "/cpu.stat")
+		}
+
+		// cgroup v1: "4:cpu,cpuacct:/docker/<id>"
+		for _, controller := range strings.Split(parts[1], ",") {
+			if controller != "cpuacct" {
+				continue
+			}
+			for _, mount := range []string{"/sys/fs/cgroup/cpuacct", "/sys/fs/cgroup/cpu,cpuacct"} {
+				content, err := os.ReadFile(mount + parts[2] + "/cpuacct.usage")
+				if err == nil {
+					return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+				}
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("cpu cgroup not found for pid %d", pid)
+}
+
+// readCPUStatUsage 读取cgroup v2 cpu.stat 中的 usage_usec 并换算为纳秒
+func readCPUStatUsage(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			usec, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return usec * 1000, nil
+		}
+	}
+	return 0, fmt.Errorf("usage_usec not found in %s", path)
+}
+
+// readNetDevBytes 汇总进程网络命名空间内除回环外所有接口的收发字节数
+func readNetDevBytes(pid int) (rx, tx uint64, err error) {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/net/dev", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, stats, found := strings.Cut(scanner.Text(), ":")
+		if !found || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(stats)
+		if len(fields) < 9 {
+			continue
+		}
+		received, _ := strconv.ParseUint(fields[0], 10, 64)
+		transmitted, _ := strconv.ParseUint(fields[8], 10, 64)
+		rx += received
+		tx += transmitted
+	}
+	return rx, tx, scanner.Err()
+}
+
+// dockerCgroupPattern cgroup路径中的docker容器ID
+var dockerCgroupPattern = regexp.MustCompile(`(?:/docker/|docker-)([0-9a-f]{64})`)
+
+// ContainerForPID 根据进程所在的cgroup查找其所属的受管容器
+func (m *Manager) ContainerForPID(pid int) (ContainerInfo, bool) {
+	containerID := DockerContainerIDForPID(pid)
+	if containerID == "" {
+		return ContainerInfo{}, false
+	}
+	return m.GetContainer(containerID)
+}
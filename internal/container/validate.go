@@ -0,0 +1,381 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"utopia-node-agent/internal/dataset"
+	"utopia-node-agent/internal/mounts"
+	"utopia-node-agent/internal/security"
+)
+
+// ErrInvalidRequest 创建请求未通过验证
+var ErrInvalidRequest = errors.New("invalid create request")
+
+// FieldError 单个字段的验证错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError 创建请求的全部字段错误
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error 实现error接口
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Unwrap 使 errors.Is(err, ErrInvalidRequest) 成立
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidRequest
+}
+
+// 创建请求的长度限制
+const (
+	maxImageLength      = 512
+	maxEnvVars          = 256
+	maxEnvVarLength     = 32 * 1024
+	maxVolumes          = 64
+	maxExternalVolumes  = 16
+	maxPortMappings     = 128
+	maxCommandArgs      = 256
+	maxCommandLength    = 128 * 1024
+	maxPathLength       = 4096
+	maxWorkingDirLength = 1024
+	// docker允许的最小内存限制
+	minMemoryMB = 6
+)
+
+var (
+	// claimIDPattern claim ID用于容器名称与本地存储键
+	claimIDPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,127}$`)
+	// envNamePattern 环境变量名
+	envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// portNamePattern 端口名称，用于隧道名称
+	portNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+	// volumeNamePattern Docker命名卷
+	volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+	// imagePattern 镜像引用：[registry[:port]/]path[:tag][@digest]
+	imagePattern = regexp.MustCompile(`^` + imageNameExpr +
+		`(?::[\w][\w.-]{0,127})?` +
+		`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?$`)
+	// repositoryPattern 不带标签与摘要的镜像仓库名
+	repositoryPattern = regexp.MustCompile(`^` + imageNameExpr + `$`)
+)
+
+// imageNameExpr 镜像名称：[registry[:port]/]path
+const imageNameExpr = `(?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*`
+
+// reservedEnvVars 由Agent控制、不允许请求覆盖的环境变量
+// NVIDIA_VISIBLE_DEVICES 会让容器运行时暴露未分配的GPU，MPS显存上限由共享计算选项决定，GPU份额由时间片共享决定
+var reservedEnvVars = map[string]bool{
+	"NVIDIA_VISIBLE_DEVICES": true,
+	mpsMemLimitEnvVar:        true,
+	gpuFractionEnvVar:        true,
+}
+
+// blockedHostPaths 不允许挂载到租户容器的主机路径
+// 这些路径本身、其子路径以及包含它们的上级目录（包括 /）都会被拒绝
+var blockedHostPaths = []string{
+	"/bin", "/boot", "/dev", "/etc", "/lib", "/lib64", "/proc", "/root",
+	"/run", "/sbin", "/sys", "/usr", "/var/run", "/var/lib/docker",
+	"/var/lib/utopia", "/var/lib/containerd",
+}
+
+// ValidateCreateRequest 验证创建请求的全部字段，返回 *ValidationError
+func (m *Manager) ValidateCreateRequest(req *CreateRequest) error {
+	return req.validate(m.getOptions().AllowedVolumeRoots)
+}
+
+// validate 收集所有字段错误而不是在第一个错误处返回
+func (r *CreateRequest) validate(allowedVolumeRoots []string) error {
+	var fields []FieldError
+	add := func(field, format string, args ...interface{}) {
+		fields = append(fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if !claimIDPattern.MatchString(r.ClaimID) {
+		add("claim_id", "must be 1-128 characters of letters, digits, '_', '.' or '-', starting with a letter or digit")
+	}
+
+	switch {
+	case len(r.Image) > maxImageLength:
+		add("image", "must be at most %d characters", maxImageLength)
+	case !imagePattern.MatchString(r.Image):
+		add("image", "invalid image reference %q", r.Image)
+	}
+
+	if r.GPUCount < 0 {
+		add("gpu_count", "must be non-negative")
+	}
+	if r.GPUs != nil {
+		if *r.GPUs < 0 {
+			add("gpus", "must be non-negative")
+		} else if *r.GPUs != r.GPUCount {
+			add("gpus", "conflicts with gpu_count")
+		}
+	}
+	if r.StorageSizeGB < 0 {
+		add("storage_size_gb", "must be non-negative")
+	}
+	if r.CPUs < 0 {
+		add("cpus", "must be non-negative")
+	}
+	if r.MemoryMB < 0 {
+		add("memory_mb", "must be non-negative")
+	} else if r.MemoryMB > 0 && r.MemoryMB < minMemoryMB {
+		add("memory_mb", "must be at least %d", minMemoryMB)
+	}
+	if r.StartAt < 0 {
+		add("start_at", "must be non-negative")
+	}
+	if r.MaxRuntimeSeconds < 0 {
+		add("max_runtime_seconds", "must be non-negative")
+	}
+
+	if len(r.PortMappings) > maxPortMappings {
+		add("port_mappings", "at most %d port mappings are allowed", maxPortMappings)
+	}
+	seenPorts := make(map[string]bool)
+	seenPortNames := make(map[string]bool)
+	for i, pm := range r.PortMappings {
+		field := fmt.Sprintf("port_mappings[%d]", i)
+		if pm.HostPort < 0 || pm.HostPort > 65535 {
+			add(field+".host_port", "must be between 1 and 65535, or 0 to allocate one")
+		}
+		if pm.ContainerPort < 1 || pm.ContainerPort > 65535 {
+			add(field+".container_port", "must be between 1 and 65535")
+		}
+		protocol := pm.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		if protocol != "tcp" && protocol != "udp" {
+			add(field+".protocol", "must be tcp or udp")
+		}
+		key := fmt.Sprintf("%d/%s", pm.HostPort, protocol)
+		if pm.HostPort != 0 && seenPorts[key] {
+			add(field+".host_port", "duplicate host port %s", key)
+		}
+		seenPorts[key] = true
+
+		if pm.Name != "" {
+			if !portNamePattern.MatchString(pm.Name) {
+				add(field+".name", "must be 1-32 lowercase letters, digits or '-'")
+			} else if seenPortNames[pm.Name] {
+				add(field+".name", "duplicate port name %q", pm.Name)
+			}
+			seenPortNames[pm.Name] = true
+		}
+		switch pm.Publish {
+		case "":
+		case PublishHTTP, PublishTCP:
+			if pm.Name == "" {
+				add(field+".name", "is required when the port is published")
+			}
+			if protocol != "tcp" {
+				add(field+".publish", "only tcp ports can be published")
+			}
+		default:
+			add(field+".publish", "must be http or tcp")
+		}
+	}
+
+	if len(r.EnvVars) > maxEnvVars {
+		add("env_vars", "at most %d environment variables are allowed", maxEnvVars)
+	}
+	for i, env := range r.EnvVars {
+		field := fmt.Sprintf("env_vars[%d]", i)
+		// 没有 "=" 时 docker 会从Agent进程环境中取值，可能泄露Agent的凭据
+		name, _, found := strings.Cut(env, "=")
+		switch {
+		case !found:
+			add(field, "must be in NAME=value form")
+		case !envNamePattern.MatchString(name):
+			add(field, "invalid variable name %q", name)
+		case reservedEnvVars[name]:
+			add(field, "%s is managed by the agent", name)
+		case len(env) > maxEnvVarLength:
+			add(field, "must be at most %d bytes", maxEnvVarLength)
+		}
+	}
+
+	if len(r.Command) > maxCommandArgs {
+		add("command", "at most %d arguments are allowed", maxCommandArgs)
+	}
+	commandLength := 0
+	for _, arg := range r.Command {
+		commandLength += len(arg)
+	}
+	if commandLength > maxCommandLength {
+		add("command", "must be at most %d bytes in total", maxCommandLength)
+	}
+
+	if r.WorkingDir != "" {
+		if len(r.WorkingDir) > maxWorkingDirLength || !path.IsAbs(r.WorkingDir) {
+			add("working_dir", "must be an absolute path of at most %d characters", maxWorkingDirLength)
+		}
+	}
+
+	if len(r.Volumes) > maxVolumes {
+		add("volumes", "at most %d volumes are allowed", maxVolumes)
+	}
+	for _, source := range sortedVolumeSources(r.Volumes) {
+		field := fmt.Sprintf("volumes[%s]", source)
+		if err := validateVolumeSource(source, allowedVolumeRoots); err != nil {
+			add(field, "%v", err)
+		}
+		if err := validateContainerPath(r.Volumes[source]); err != nil {
+			add(field, "%v", err)
+		}
+	}
+
+	if r.RestartPolicy != nil {
+		if err := r.RestartPolicy.Validate(); err != nil {
+			add("restart_policy", "%v", err)
+		}
+	}
+	if err := r.NetworkOptions.Validate(); err != nil {
+		add("network", "%v", err)
+	}
+	for i, secret := range r.Secrets {
+		if err := secret.Validate(); err != nil {
+			add(fmt.Sprintf("secrets[%d]", i), "%v", err)
+		}
+	}
+	if r.SharedCompute != nil {
+		if err := r.SharedCompute.Validate(r.GPUCount); err != nil {
+			add("shared_compute", "%v", err)
+		}
+	}
+	if r.GPUFraction != 0 {
+		if err := r.validateGPUFraction(); err != nil {
+			add("gpu_fraction", "%v", err)
+		}
+	}
+	for i, name := range r.SecurityRelaxations {
+		if err := security.ValidateRelaxation(name); err != nil {
+			add(fmt.Sprintf("security_relaxations[%d]", i), "%v", err)
+		}
+	}
+	if err := ValidateRuntimeClass(r.RuntimeClass); err != nil {
+		add("runtime_class", "%v", err)
+	} else if r.SharedCompute != nil && runtimeClassOrDefault(r.RuntimeClass) != RuntimeRunc {
+		// MPS客户端需要与主机共享IPC命名空间，沙箱运行时无法满足
+		add("runtime_class", "shared_compute requires the runc runtime")
+	}
+	for i, ref := range r.Datasets {
+		if err := dataset.Validate(ref); err != nil {
+			add(fmt.Sprintf("datasets[%d]", i), "%v", err)
+		}
+	}
+	if len(r.ExternalVolumes) > maxExternalVolumes {
+		add("external_volumes", "at most %d external volumes are allowed", maxExternalVolumes)
+	}
+	for i, v := range r.ExternalVolumes {
+		if err := mounts.Validate(v); err != nil {
+			add(fmt.Sprintf("external_volumes[%d]", i), "%v", err)
+		}
+	}
+	if err := ValidateUlimits(r.Ulimits); err != nil {
+		add("ulimits", "%v", err)
+	}
+	if r.IOLimits != nil {
+		if err := r.IOLimits.Validate(); err != nil {
+			add("io_limits", "%v", err)
+		}
+	}
+	if err := validateMetadata(r.Metadata); err != nil {
+		add("metadata", "%v", err)
+	}
+	if r.RegistryAuth != nil {
+		if err := r.RegistryAuth.Validate(r.Image); err != nil {
+			add("registry_auth", "%v", err)
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// validateVolumeSource 验证卷来源：命名卷，或位于允许目录下且不涉及敏感路径的主机路径
+// 命名卷的归属在创建容器时检查（见 prepareNamedVolumes）
+func validateVolumeSource(source string, allowedRoots []string) error {
+	if !strings.HasPrefix(source, "/") {
+		if !volumeNamePattern.MatchString(source) {
+			return fmt.Errorf("invalid volume name %q, host paths must be absolute", source)
+		}
+		return nil
+	}
+	if len(source) > maxPathLength || strings.ContainsAny(source, ":,") {
+		return fmt.Errorf("invalid host path %q", source)
+	}
+
+	// 同时检查符号链接解析后的实际路径
+	candidates := []string{filepath.Clean(source)}
+	if resolved, err := filepath.EvalSymlinks(source); err == nil && resolved != candidates[0] {
+		candidates = append(candidates, resolved)
+	}
+	for _, p := range candidates {
+		for _, blocked := range blockedHostPaths {
+			if pathWithin(p, blocked) || pathWithin(blocked, p) {
+				return fmt.Errorf("host path %q is not allowed", source)
+			}
+		}
+		if !withinAny(p, allowedRoots) {
+			return fmt.Errorf("host path %q is outside the allowed volume roots", source)
+		}
+	}
+	return nil
+}
+
+// validateContainerPath 验证容器内挂载路径
+func validateContainerPath(p string) error {
+	if !path.IsAbs(p) || path.Clean(p) == "/" || len(p) > maxPathLength || strings.ContainsAny(p, ":,") {
+		return fmt.Errorf("invalid container path %q", p)
+	}
+	return nil
+}
+
+// pathWithin 判断p是否为root本身或其子路径
+func pathWithin(p, root string) bool {
+	if root == "/" {
+		return true
+	}
+	return p == root || strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/")
+}
+
+// withinAny 判断p是否位于任一root之下
+func withinAny(p string, roots []string) bool {
+	for _, root := range roots {
+		if pathWithin(p, filepath.Clean(root)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedVolumeSources 返回排序后的卷来源，保证错误顺序稳定
+func sortedVolumeSources(volumes map[string]string) []string {
+	sources := make([]string, 0, len(volumes))
+	for source := range volumes {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
@@ -0,0 +1,138 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// volumeClaimLabel 命名卷所属的claim，由agent创建卷时添加
+const volumeClaimLabel = "utopia.claim_id"
+
+// tenantVolumeDir 子租户可挂载的主机路径位于允许目录下的 tenants/<租户> 中
+const tenantVolumeDir = "tenants"
+
+// checkTenantHostPaths 子租户只能挂载允许目录下属于自己的 tenants/<租户> 子目录，
+// 不能挂载其他租户或平台claim的主机目录
+func (m *Manager) checkTenantHostPaths(req *CreateRequest) error {
+	if req.Tenant == "" {
+		return nil
+	}
+	var roots []string
+	for _, root := range m.getOptions().AllowedVolumeRoots {
+		roots = append(roots, filepath.Join(root, tenantVolumeDir, req.Tenant))
+	}
+
+	var fields []FieldError
+	for _, source := range sortedVolumeSources(req.Volumes) {
+		if !strings.HasPrefix(source, "/") {
+			continue
+		}
+		candidates := []string{filepath.Clean(source)}
+		if resolved, err := filepath.EvalSymlinks(source); err == nil {
+			candidates = append(candidates, resolved)
+		}
+		for _, p := range candidates {
+			if !withinAny(p, roots) {
+				fields = append(fields, FieldError{
+					Field:   fmt.Sprintf("volumes[%s]", source),
+					Message: fmt.Sprintf("tenant host paths must be under <allowed volume root>/%s/%s", tenantVolumeDir, req.Tenant),
+				})
+				break
+			}
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// prepareNamedVolumes 检查并创建请求挂载的命名卷
+// 不存在的卷以claim与子租户标签创建；已存在的卷只能由所属claim挂载，
+// 迁移导入的卷只能由平台令牌的请求挂载，其他卷（其他claim的卷或非agent创建的卷）被拒绝
+func (m *Manager) prepareNamedVolumes(ctx context.Context, req *CreateRequest) error {
+	var fields []FieldError
+	for _, name := range sortedVolumeSources(req.Volumes) {
+		if strings.HasPrefix(name, "/") {
+			continue
+		}
+		labels, exists, err := inspectVolumeLabels(ctx, name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			args := []string{"volume", "create",
+				"--label", "utopia.managed=true",
+				"--label", fmt.Sprintf("%s=%s", volumeClaimLabel, req.ClaimID)}
+			if req.Tenant != "" {
+				args = append(args, "--label", fmt.Sprintf("%s=%s", TenantLabel, req.Tenant))
+			}
+			if output, err := dockerCommand(ctx, append(args, name)...).CombinedOutput(); err != nil {
+				return fmt.Errorf("failed to create volume %s: %w: %s", name, err, strings.TrimSpace(string(output)))
+			}
+			continue
+		}
+
+		switch {
+		case labels[volumeClaimLabel] == req.ClaimID && labels[TenantLabel] == req.Tenant:
+		case labels[migrationLabel] != "" && labels[volumeClaimLabel] == "" && req.Tenant == "":
+		default:
+			fields = append(fields, FieldError{
+				Field:   fmt.Sprintf("volumes[%s]", name),
+				Message: "volume already exists and does not belong to this claim",
+			})
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// inspectVolumeLabels 返回命名卷的标签，卷不存在时exists为false
+func inspectVolumeLabels(ctx context.Context, name string) (labels map[string]string, exists bool, err error) {
+	output, err := dockerCommand(ctx, "volume", "inspect", "--format", "{{json .Labels}}", name).CombinedOutput()
+	if err != nil {
+		if strings.Contains(strings.ToLower(string(output)), "no such volume") {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to inspect volume %s: %w: %s", name, err, strings.TrimSpace(string(output)))
+	}
+	labels = make(map[string]string)
+	if trimmed := strings.TrimSpace(string(output)); trimmed != "" && trimmed != "null" {
+		if err := json.Unmarshal([]byte(trimmed), &labels); err != nil {
+			return nil, false, fmt.Errorf("failed to parse labels of volume %s: %w", name, err)
+		}
+	}
+	return labels, true, nil
+}
+
+// ManagedVolumes 返回agent创建的命名卷（claim的卷与迁移导入的卷）
+func ManagedVolumes(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	var volumes []string
+	for _, filter := range []string{"label=utopia.managed=true", "label=" + migrationLabel} {
+		output, err := dockerCommand(ctx, "volume", "ls", "-q", "--filter", filter).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list volumes: %w", err)
+		}
+		for _, name := range strings.Fields(string(output)) {
+			if !seen[name] {
+				seen[name] = true
+				volumes = append(volumes, name)
+			}
+		}
+	}
+	return volumes, nil
+}
+
+// RemoveVolume 删除命名卷
+func RemoveVolume(ctx context.Context, name string) error {
+	if output, err := dockerCommand(ctx, "volume", "rm", "-f", name).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove volume %s: %w: %s", name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
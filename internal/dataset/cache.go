@@ -0,0 +1,482 @@
+package dataset
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"utopia-node-agent/internal/store"
+)
+
+var (
+	// sha256Pattern 数据集内容摘要
+	sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	// rsyncHostPattern rsync源的主机名或IP地址，不能以 - 开头
+	rsyncHostPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9.-]*|[0-9a-fA-F:]+)$`)
+)
+
+// Ref 容器请求的数据集
+type Ref struct {
+	// 数据源：http(s):// 文件（.tar/.tar.gz/.tgz 会自动解压）或 rsync:// 源
+	URL string `json:"url" binding:"required"`
+	// 下载文件的SHA-256，提供时用于校验并作为缓存键
+	SHA256 string `json:"sha256,omitempty"`
+	// 容器内挂载路径
+	MountPath string `json:"mount_path" binding:"required"`
+}
+
+// Entry 缓存中的数据集
+type Entry struct {
+	Key       string `json:"key"`
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256,omitempty"`
+	SizeBytes int64  `json:"size_bytes"`
+	CreatedAt int64  `json:"created_at"`
+	LastUsed  int64  `json:"last_used"`
+}
+
+// Cache 节点本地共享数据集缓存，超出磁盘预算时按最近使用时间淘汰
+type Cache struct {
+	mu          sync.Mutex
+	dir         string
+	budgetBytes int64
+	index       *store.Store
+	entries     map[string]*Entry
+	fetching    map[string]chan struct{} // 正在下载的键，避免重复下载
+	httpClient  *http.Client
+
+	// 允许的rsync主机，为空时不限制
+	rsyncHosts map[string]bool
+}
+
+// NewCache 创建位于dir、磁盘预算为budgetBytes的数据集缓存
+func NewCache(dir string, budgetBytes int64) (*Cache, error) {
+	index, err := store.Open(filepath.Join(dir, ".index"))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		dir:         dir,
+		budgetBytes: budgetBytes,
+		index:       index,
+		entries:     make(map[string]*Entry),
+		fetching:    make(map[string]chan struct{}),
+		httpClient:  newHTTPClient(),
+	}
+
+	keys, err := index.Keys()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		var entry Entry
+		if found, err := index.Get(key, &entry); err != nil || !found {
+			continue
+		}
+		// 数据目录丢失的索引项直接丢弃
+		if _, err := os.Stat(c.entryDir(key)); err != nil {
+			index.Delete(key)
+			continue
+		}
+		c.entries[key] = &entry
+	}
+
+	return c, nil
+}
+
+// Key 返回数据集的缓存键：提供摘要时为摘要，否则由URL派生
+func Key(ref Ref) string {
+	if ref.SHA256 != "" {
+		return strings.ToLower(ref.SHA256)
+	}
+	sum := sha256.Sum256([]byte(ref.URL))
+	return "url-" + hex.EncodeToString(sum[:16])
+}
+
+// Validate 验证数据集引用
+func Validate(ref Ref) error {
+	if ref.SHA256 != "" && !sha256Pattern.MatchString(strings.ToLower(ref.SHA256)) {
+		return fmt.Errorf("invalid sha256 %q", ref.SHA256)
+	}
+	// 挂载路径拼入 -v 参数，不能包含 : 与 ,
+	if !path.IsAbs(ref.MountPath) || path.Clean(ref.MountPath) == "/" || strings.ContainsAny(ref.MountPath, ":,") {
+		return fmt.Errorf("mount path %q must be an absolute path other than / without ':' or ','", ref.MountPath)
+	}
+	if isHTTP(ref.URL) {
+		return nil
+	}
+	if strings.HasPrefix(ref.URL, "rsync://") {
+		if _, err := rsyncHost(ref.URL); err != nil {
+			return err
+		}
+		if ref.SHA256 != "" {
+			return fmt.Errorf("sha256 verification is only supported for http(s) datasets")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported dataset url %q, must be http(s):// or rsync://", ref.URL)
+}
+
+// rsyncHost 返回rsync://地址的主机名
+// 只接受rsync守护进程地址，host:path形式会让rsync以root的ssh密钥连接远端
+func rsyncHost(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme != "rsync" || parsed.User != nil || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", fmt.Errorf("invalid rsync url %q", rawURL)
+	}
+	host := parsed.Hostname()
+	if !rsyncHostPattern.MatchString(host) || strings.ContainsAny(parsed.Path, " ") {
+		return "", fmt.Errorf("invalid rsync url %q", rawURL)
+	}
+	return strings.ToLower(host), nil
+}
+
+// SetRsyncHosts 限制rsync数据源的主机，为空时不限制
+func (c *Cache) SetRsyncHosts(hosts []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rsyncHosts = make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		c.rsyncHosts[strings.ToLower(host)] = true
+	}
+}
+
+// checkSource 检查数据源是否在允许的rsync主机中
+func (c *Cache) checkSource(ref Ref) error {
+	if isHTTP(ref.URL) {
+		return nil
+	}
+	host, err := rsyncHost(ref.URL)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.rsyncHosts) > 0 && !c.rsyncHosts[host] {
+		return fmt.Errorf("rsync host %s is not allowed on this node", host)
+	}
+	return nil
+}
+
+// Ensure 确保数据集已缓存并返回其本地目录
+// pinned 为正在被容器使用的缓存键，淘汰时跳过
+func (c *Cache) Ensure(ctx context.Context, ref Ref, pinned map[string]bool) (string, error) {
+	if err := Validate(ref); err != nil {
+		return "", err
+	}
+	if err := c.checkSource(ref); err != nil {
+		return "", err
+	}
+	key := Key(ref)
+
+	for {
+		c.mu.Lock()
+		if entry, exists := c.entries[key]; exists {
+			entry.LastUsed = time.Now().Unix()
+			c.index.Put(key, entry)
+			c.mu.Unlock()
+			return c.entryDir(key), nil
+		}
+		wait, busy := c.fetching[key]
+		if !busy {
+			c.fetching[key] = make(chan struct{})
+			c.mu.Unlock()
+			break
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	err := c.fetch(ctx, key, ref, pinned)
+
+	c.mu.Lock()
+	close(c.fetching[key])
+	delete(c.fetching, key)
+	c.mu.Unlock()
+
+	if err != nil {
+		return "", err
+	}
+	return c.entryDir(key), nil
+}
+
+// List 按最近使用时间倒序列出缓存的数据集
+func (c *Cache) List() []Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make([]Entry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		result = append(result, *entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].LastUsed > result[j].LastUsed })
+	return result
+}
+
+// Remove 删除缓存的数据集
+func (c *Cache) Remove(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.entries[key]; !exists {
+		return fmt.Errorf("dataset %s not cached", key)
+	}
+	return c.removeLocked(key)
+}
+
+// fetch 下载数据集到临时目录，校验后腾出空间并移入缓存
+func (c *Cache) fetch(ctx context.Context, key string, ref Ref, pinned map[string]bool) error {
+	tmpDir, err := os.MkdirTemp(c.dir, ".fetch-"+key+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create download dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if isHTTP(ref.URL) {
+		err = c.download(ctx, ref, tmpDir)
+	} else {
+		err = rsync(ctx, ref.URL, tmpDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	size, err := dirSize(tmpDir)
+	if err != nil {
+		return fmt.Errorf("failed to measure dataset: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.evictLocked(size, pinned); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpDir, c.entryDir(key)); err != nil {
+		return fmt.Errorf("failed to move dataset into cache: %w", err)
+	}
+
+	now := time.Now().Unix()
+	entry := &Entry{
+		Key:       key,
+		URL:       ref.URL,
+		SHA256:    strings.ToLower(ref.SHA256),
+		SizeBytes: size,
+		CreatedAt: now,
+		LastUsed:  now,
+	}
+	c.entries[key] = entry
+	return c.index.Put(key, entry)
+}
+
+// download 下载http(s)数据集，校验摘要，归档文件解压到dest
+func (c *Cache) download(ctx context.Context, ref Ref, dest string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref.URL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid dataset url: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download dataset: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download dataset: unexpected status %d", resp.StatusCode)
+	}
+	// 下载过程中限制大小，避免单个数据集写满磁盘
+	body := io.Reader(resp.Body)
+	if c.budgetBytes > 0 {
+		if resp.ContentLength > c.budgetBytes {
+			return fmt.Errorf("dataset size %d bytes exceeds cache budget %d bytes", resp.ContentLength, c.budgetBytes)
+		}
+		body = io.LimitReader(resp.Body, c.budgetBytes+1)
+	}
+
+	name := fileName(ref.URL)
+	archive := isArchive(name)
+	target := filepath.Join(dest, name)
+	if archive {
+		target = filepath.Join(filepath.Dir(dest), filepath.Base(dest)+"-"+name)
+		defer os.Remove(target)
+	}
+
+	file, err := os.Create(target)
+	if err != nil {
+		return fmt.Errorf("failed to create dataset file: %w", err)
+	}
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(file, hash), body)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to download dataset: %w", err)
+	}
+	if c.budgetBytes > 0 && written > c.budgetBytes {
+		return fmt.Errorf("dataset exceeds cache budget %d bytes", c.budgetBytes)
+	}
+
+	if ref.SHA256 != "" {
+		if actual := hex.EncodeToString(hash.Sum(nil)); actual != strings.ToLower(ref.SHA256) {
+			return fmt.Errorf("dataset checksum mismatch: expected %s, got %s", ref.SHA256, actual)
+		}
+	}
+
+	if archive {
+		if output, err := exec.CommandContext(ctx, "tar", "-xf", target, "-C", dest).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to extract dataset: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// evictLocked 按LRU淘汰未被使用的数据集直到能容纳size字节（调用方需持有锁）
+func (c *Cache) evictLocked(size int64, pinned map[string]bool) error {
+	if c.budgetBytes <= 0 {
+		return nil
+	}
+	if size > c.budgetBytes {
+		return fmt.Errorf("dataset size %d bytes exceeds cache budget %d bytes", size, c.budgetBytes)
+	}
+
+	var used int64
+	candidates := make([]*Entry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		used += entry.SizeBytes
+		if !pinned[entry.Key] {
+			candidates = append(candidates, entry)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].LastUsed < candidates[j].LastUsed })
+
+	for _, entry := range candidates {
+		if used+size <= c.budgetBytes {
+			break
+		}
+		fmt.Printf("Evicting dataset %s (%d bytes) from cache\n", entry.Key, entry.SizeBytes)
+		if err := c.removeLocked(entry.Key); err != nil {
+			return err
+		}
+		used -= entry.SizeBytes
+	}
+
+	if used+size > c.budgetBytes {
+		return fmt.Errorf("dataset cache budget exhausted by datasets in use")
+	}
+	return nil
+}
+
+// removeLocked 删除数据集目录与索引（调用方需持有锁）
+func (c *Cache) removeLocked(key string) error {
+	if err := os.RemoveAll(c.entryDir(key)); err != nil {
+		return fmt.Errorf("failed to remove dataset %s: %w", key, err)
+	}
+	delete(c.entries, key)
+	return c.index.Delete(key)
+}
+
+// entryDir 返回数据集的本地目录
+func (c *Cache) entryDir(key string) string {
+	return filepath.Join(c.dir, key)
+}
+
+// rsync 同步rsync源到dest
+func rsync(ctx context.Context, source, dest string) error {
+	output, err := exec.CommandContext(ctx, "rsync", "-a", "--delete", "--", strings.TrimSuffix(source, "/")+"/", dest+"/").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("rsync failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// dirSize 计算目录内文件总大小
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// blockedAddresses 云平台元数据服务的地址（链路本地地址之外）
+var blockedAddresses = []net.IP{
+	net.ParseIP("100.100.100.200"), // 阿里云
+	net.ParseIP("fd00:ec2::254"),   // AWS IPv6
+}
+
+// newHTTPClient 返回下载数据集的HTTP客户端
+// 连接（包括重定向）不能指向本机、链路本地地址与元数据服务，数据集地址由请求方提供
+func newHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+				return fmt.Errorf("dataset download from %s is not allowed", host)
+			}
+			for _, blocked := range blockedAddresses {
+				if ip.Equal(blocked) {
+					return fmt.Errorf("dataset download from %s is not allowed", host)
+				}
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: transport}
+}
+
+// isHTTP 判断是否为http(s)地址
+func isHTTP(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "http://") || strings.HasPrefix(rawURL, "https://")
+}
+
+// fileName 从URL提取文件名
+func fileName(rawURL string) string {
+	if parsed, err := url.Parse(rawURL); err == nil {
+		if name := path.Base(parsed.Path); name != "" && name != "/" && name != "." {
+			return name
+		}
+	}
+	return "dataset"
+}
+
+// isArchive 判断文件名是否为可解压的归档
+func isArchive(name string) bool {
+	for _, suffix := range []string{".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// 事件级别
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// Event 节点事件
+type Event struct {
+	// 单调递增序号，用于增量拉取
+	Seq         int64                  `json:"seq"`
+	Type        string                 `json:"type"`
+	Severity    string                 `json:"severity"`
+	Time        int64                  `json:"time"`
+	ContainerID string                 `json:"container_id,omitempty"`
+	ClaimID     string                 `json:"claim_id,omitempty"`
+	Message     string                 `json:"message"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+	// 事件相关claim的平台元数据
+	ClaimMetadata map[string]interface{} `json:"claim_metadata,omitempty"`
+}
+
+// Bus 事件总线，在内存中保留最近的事件
+type Bus struct {
+	mu     sync.RWMutex
+	seq    int64
+	events []Event
+	size   int
+	// 发布前补充事件字段
+	enrich func(*Event)
+}
+
+// NewBus 创建最多保留size条事件的事件总线
+func NewBus(size int) *Bus {
+	return &Bus{size: size}
+}
+
+// SetEnricher 设置发布前补充事件字段的函数（如附加claim元数据），函数内不得发布事件
+func (b *Bus) SetEnricher(enrich func(*Event)) {
+	b.mu.Lock()
+	b.enrich = enrich
+	b.mu.Unlock()
+}
+
+// Publish 发布事件，填充序号与时间并返回
+func (b *Bus) Publish(event Event) Event {
+	b.mu.RLock()
+	enrich := b.enrich
+	b.mu.RUnlock()
+	if enrich != nil {
+		enrich(&event)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event.Seq = b.seq
+	if event.Time == 0 {
+		event.Time = time.Now().Unix()
+	}
+	if event.Severity == "" {
+		event.Severity = SeverityInfo
+	}
+
+	b.events = append(b.events, event)
+	if len(b.events) > b.size {
+		b.events = b.events[len(b.events)-b.size:]
+	}
+	return event
+}
+
+// Since 返回序号大于seq的事件（按序号升序），limit<=0表示不限制
+func (b *Bus) Since(seq int64, limit int) []Event {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := make([]Event, 0)
+	for _, event := range b.events {
+		if event.Seq <= seq {
+			continue
+		}
+		result = append(result, event)
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,197 @@
+package features
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"utopia-node-agent/internal/store"
+)
+
+// 已知的功能开关
+const (
+	// AsyncCreate 允许以 ?async=true 异步创建容器
+	AsyncCreate = "async_create"
+	// EventStream 启用节点事件的SSE推送端点
+	EventStream = "event_stream"
+	// CDIMode 通过CDI设备名（nvidia.com/gpu=N）而非 --gpus 为容器分配GPU
+	CDIMode = "cdi_mode"
+)
+
+// 开关取值来源
+const (
+	SourceDefault  = "default"
+	SourceConfig   = "config"
+	SourceOverride = "override"
+)
+
+// ErrUnknownFlag 功能开关不存在
+var ErrUnknownFlag = errors.New("unknown feature flag")
+
+// Definition 功能开关定义
+type Definition struct {
+	Name        string
+	Description string
+	Default     bool
+}
+
+// definitions 全部已知的功能开关
+var definitions = []Definition{
+	{Name: AsyncCreate, Description: "Accept ?async=true on container creation and run it as a job"},
+	{Name: EventStream, Description: "Stream node events over Server-Sent Events at /events/stream"},
+	{Name: CDIMode, Description: "Attach GPUs to containers as CDI devices instead of --gpus"},
+}
+
+// Flag 功能开关的当前状态
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Default     bool   `json:"default"`
+	// 当前取值来源：default、config（配置文件或平台配置补丁）、override（管理端点设置）
+	Source    string `json:"source"`
+	UpdatedAt int64  `json:"updated_at,omitempty"`
+}
+
+// override 通过管理端点设置的开关值
+type override struct {
+	Enabled   bool  `json:"enabled"`
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// Flags 运行时功能开关，优先级：管理端点覆盖 > 配置 > 默认值
+// 覆盖持久化在本地，Agent重启后仍然有效
+type Flags struct {
+	mu        sync.RWMutex
+	store     *store.Store
+	config    map[string]bool
+	overrides map[string]override
+}
+
+// Open 加载持久化在dir中的开关覆盖
+func Open(dir string) (*Flags, error) {
+	st, err := store.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := st.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Flags{store: st, overrides: make(map[string]override)}
+	for _, name := range keys {
+		var o override
+		if ok, err := st.Get(name, &o); err != nil || !ok {
+			fmt.Printf("Warning: failed to load feature flag override %s: %v\n", name, err)
+			continue
+		}
+		if _, known := lookup(name); !known {
+			fmt.Printf("Warning: dropping override of unknown feature flag %s\n", name)
+			st.Delete(name)
+			continue
+		}
+		f.overrides[name] = o
+	}
+	return f, nil
+}
+
+// SetConfig 设置配置中的开关值（配置文件与平台补丁合并后的结果）
+func (f *Flags) SetConfig(values map[string]bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config = values
+}
+
+// Enabled 返回开关是否开启，f为nil时返回默认值
+func (f *Flags) Enabled(name string) bool {
+	if f == nil {
+		def, _ := lookup(name)
+		return def.Default
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.flagLocked(name).Enabled
+}
+
+// Get 返回开关的当前状态
+func (f *Flags) Get(name string) (Flag, error) {
+	if _, known := lookup(name); !known {
+		return Flag{}, fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.flagLocked(name), nil
+}
+
+// List 返回全部已知开关的当前状态
+func (f *Flags) List() []Flag {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	result := make([]Flag, 0, len(definitions))
+	for _, def := range definitions {
+		result = append(result, f.flagLocked(def.Name))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Set 覆盖开关值并持久化，立即生效
+func (f *Flags) Set(name string, enabled bool) (Flag, error) {
+	if _, known := lookup(name); !known {
+		return Flag{}, fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o := override{Enabled: enabled, UpdatedAt: time.Now().Unix()}
+	if err := f.store.Put(name, &o); err != nil {
+		return Flag{}, fmt.Errorf("failed to persist feature flag: %w", err)
+	}
+	f.overrides[name] = o
+	return f.flagLocked(name), nil
+}
+
+// Reset 删除开关的覆盖，恢复为配置值或默认值
+func (f *Flags) Reset(name string) (Flag, error) {
+	if _, known := lookup(name); !known {
+		return Flag{}, fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.store.Delete(name); err != nil {
+		return Flag{}, fmt.Errorf("failed to delete feature flag override: %w", err)
+	}
+	delete(f.overrides, name)
+	return f.flagLocked(name), nil
+}
+
+// flagLocked 按优先级计算开关状态（调用方需持有锁）
+func (f *Flags) flagLocked(name string) Flag {
+	def, _ := lookup(name)
+	flag := Flag{
+		Name:        name,
+		Description: def.Description,
+		Enabled:     def.Default,
+		Default:     def.Default,
+		Source:      SourceDefault,
+	}
+	if value, exists := f.config[name]; exists {
+		flag.Enabled, flag.Source = value, SourceConfig
+	}
+	if o, exists := f.overrides[name]; exists {
+		flag.Enabled, flag.Source, flag.UpdatedAt = o.Enabled, SourceOverride, o.UpdatedAt
+	}
+	return flag
+}
+
+// lookup 查找功能开关定义
+func lookup(name string) (Definition, bool) {
+	for _, def := range definitions {
+		if def.Name == name {
+			return def, true
+		}
+	}
+	return Definition{}, false
+}
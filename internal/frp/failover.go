@@ -0,0 +1,188 @@
+package frp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// probeTimeout 探测frps端口的连接超时
+const probeTimeout = 3 * time.Second
+
+// Server frps服务器地址
+type Server struct {
+	Addr string `json:"addr"`
+	Port int    `json:"port"`
+}
+
+// String 返回 host:port
+func (s Server) String() string {
+	return net.JoinHostPort(s.Addr, strconv.Itoa(s.Port))
+}
+
+// ServerStatus frps服务器的探测状态
+type ServerStatus struct {
+	Server
+	Active    bool   `json:"active"`
+	Healthy   bool   `json:"healthy"`
+	LastCheck int64  `json:"last_check,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// serverList 返回按优先级排列的服务器：主服务器在前，备用服务器在后
+func serverList(config *Config) []Server {
+	servers := []Server{{Addr: config.ServerAddr, Port: config.ServerPort}}
+	return append(servers, config.FallbackServers...)
+}
+
+// probeServer 探测frps端口是否可以建立TCP连接
+func probeServer(ctx context.Context, server Server) error {
+	dialer := net.Dialer{Timeout: probeTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", server.String())
+	if err != nil {
+		return err
+	}
+	conn.Close()
+	return nil
+}
+
+// ActiveServer 返回frpc当前连接的服务器
+func (m *Manager) ActiveServer() Server {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.activeServerLocked(m.config)
+}
+
+// Servers 返回全部服务器的探测状态
+func (m *Manager) Servers() []ServerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	servers := serverList(m.config)
+	result := make([]ServerStatus, len(servers))
+	for i, server := range servers {
+		status := m.health[server]
+		status.Server = server
+		status.Active = i == m.active
+		result[i] = status
+	}
+	return result
+}
+
+// CheckServer 探测当前服务器，不可达且有可用的其他服务器时切换并重启frpc
+// frpc登录成功后会一直重连原服务器，服务器故障只能由探测发现
+func (m *Manager) CheckServer(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.detached {
+		return nil
+	}
+
+	servers := serverList(m.config)
+	current := m.activeServerLocked(m.config)
+	err := probeServer(ctx, current)
+	m.recordLocked(current, err)
+	if err == nil || len(servers) == 1 {
+		return err
+	}
+
+	log.Warnf("FRP server %s is unreachable, trying other servers: %v", current, err)
+	if err := m.stopLocked(); err != nil {
+		log.Warnf("Error stopping frpc: %v", err)
+	}
+	if err := m.startWithFailoverLocked(ctx, m.active+1); err != nil {
+		return err
+	}
+	if !m.health[m.activeServerLocked(m.config)].Healthy {
+		return fmt.Errorf("no FRP server is reachable")
+	}
+	return nil
+}
+
+// startWithFailoverLocked 从第first个服务器起按顺序选择可达的服务器启动frpc（调用方需持有锁）
+// 全部服务器探测失败时仍尝试使用原服务器启动，交由frpc自身重连
+func (m *Manager) startWithFailoverLocked(ctx context.Context, first int) error {
+	servers := serverList(m.config)
+	previous := m.active
+
+	var errs []string
+	for i := 0; i < len(servers); i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		index := (first + i) % len(servers)
+		server := servers[index]
+		if err := probeServer(ctx, server); err != nil {
+			m.recordLocked(server, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", server, err))
+			continue
+		}
+		err := m.startOnLocked(ctx, index)
+		m.recordLocked(server, err)
+		if err == nil {
+			if index != previous {
+				log.Warnf("FRP failed over from %s to %s", servers[previous%len(servers)], server)
+			}
+			return nil
+		}
+		if errors.Is(err, ErrTokenRejected) {
+			// 各服务器共享同一令牌，切换服务器无济于事
+			return err
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", server, err))
+	}
+
+	log.Warnf("No FRP server is reachable, starting frpc with %s anyway", servers[previous%len(servers)])
+	if err := m.startOnLocked(ctx, previous%len(servers)); err != nil {
+		return fmt.Errorf("no FRP server available: %s: %w", strings.Join(errs, "; "), err)
+	}
+	return nil
+}
+
+// startOnLocked 以第index个服务器生成配置并启动frpc（调用方需持有锁）
+func (m *Manager) startOnLocked(ctx context.Context, index int) error {
+	m.active = index
+	path, version, err := m.writeConfigLocked(m.config)
+	if err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+	m.configPath, m.version = path, version
+	if err := m.startLocked(ctx); err != nil {
+		return err
+	}
+	m.markGoodLocked()
+	return nil
+}
+
+// activeServerLocked 返回配置中当前使用的服务器（调用方需持有锁）
+func (m *Manager) activeServerLocked(config *Config) Server {
+	servers := serverList(config)
+	return servers[m.active%len(servers)]
+}
+
+// retargetLocked 切换到新配置时保持使用原服务器，新配置中没有该服务器时从主服务器开始（调用方需持有锁）
+func (m *Manager) retargetLocked(config *Config) {
+	current := m.activeServerLocked(m.config)
+	m.active = 0
+	for i, server := range serverList(config) {
+		if server == current {
+			m.active = i
+			return
+		}
+	}
+}
+
+// recordLocked 记录服务器的探测或启动结果（调用方需持有锁）
+func (m *Manager) recordLocked(server Server, err error) {
+	status := ServerStatus{Server: server, Healthy: err == nil, LastCheck: time.Now().Unix()}
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	m.health[server] = status
+}
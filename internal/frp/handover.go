@@ -0,0 +1,223 @@
+package frp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"utopia-node-agent/internal/handover"
+)
+
+var (
+	// ErrDetached frpc已交给新的agent进程，本进程不再管理
+	ErrDetached = errors.New("frpc has been handed over to a new agent process")
+	// ErrNotAdoptable 交接的frpc进程已不存在，需重新启动
+	ErrNotAdoptable = errors.New("handed over frpc process is not running")
+)
+
+// pidFile 可交接方式启动的frpc的PID文件名（位于配置目录下）
+const pidFile = "frpc.pid"
+
+// adoptedPollInterval 检查接管的frpc进程是否退出的间隔
+const adoptedPollInterval = time.Second
+
+// SetHandover 设置是否以可交接方式启动frpc，下次启动时生效
+// 可交接的frpc不随agent进程退出，agent无中断重启时由新进程接管
+func (m *Manager) SetHandover(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handover = enabled
+}
+
+// Detach 把运行中的frpc交给新的agent进程，返回其状态与输出管道的副本
+// 之后本进程不再停止、重启frpc，也不再读取其输出；frpc未运行时返回nil，由新进程自行启动
+func (m *Manager) Detach() (*handover.FRP, *os.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.handover {
+		return nil, nil, fmt.Errorf("frpc was not started for handover")
+	}
+	if m.detached {
+		return nil, nil, ErrDetached
+	}
+	m.detached = true
+	proc := m.proc
+	if proc == nil || proc.exited() {
+		return nil, nil, nil
+	}
+
+	state := &handover.FRP{
+		PID:           proc.pid,
+		ConfigPath:    m.configPath,
+		Version:       m.version,
+		AdminPassword: m.adminPassword,
+		ActiveServer:  m.active,
+	}
+	if proc.output == nil {
+		return state, nil, nil
+	}
+	output, err := dupFile(proc.output)
+	if err != nil {
+		m.detached = false
+		return nil, nil, fmt.Errorf("failed to duplicate frpc output: %w", err)
+	}
+	// 中断本进程的输出转发，由新进程读取
+	proc.output.SetReadDeadline(time.Now())
+	return state, output, nil
+}
+
+// Reattach 交接失败后恢复管理frpc
+func (m *Manager) Reattach() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.detached {
+		return
+	}
+	m.detached = false
+	if proc := m.proc; proc != nil && proc.output != nil && !proc.exited() {
+		proc.output.SetReadDeadline(time.Time{})
+		go proc.copyOutput()
+	}
+}
+
+// Adopt 接管旧agent进程交出的frpc，代替Start
+// 进程已不存在时返回 ErrNotAdoptable；本进程生成的配置与frpc正在使用的不同时按新配置重启frpc
+func (m *Manager) Adopt(ctx context.Context, state *handover.FRP, output *os.File) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !processMatches(state.PID, state.ConfigPath) {
+		if output != nil {
+			output.Close()
+		}
+		return fmt.Errorf("%w (PID %d)", ErrNotAdoptable, state.PID)
+	}
+	if err := m.resolveBinaryLocked(ctx); err != nil {
+		return err
+	}
+
+	m.adminPassword = state.AdminPassword
+	m.active = state.ActiveServer % len(serverList(m.config))
+	m.configPath, m.version = state.ConfigPath, state.Version
+
+	watcher := newStartupWatcher(log.StandardLogger().Writer())
+	m.proxies = newProxyLog()
+	watcher.proxies = m.proxies
+	proc := &process{pid: state.PID, output: output, forward: watcher, done: make(chan struct{})}
+	if output != nil {
+		go proc.copyOutput()
+	}
+	go watchAdopted(proc)
+	m.proc = proc
+	m.writePIDLocked()
+
+	rendered, err := m.renderLocked(m.config)
+	current, readErr := os.ReadFile(state.ConfigPath)
+	if err == nil && readErr == nil && bytes.Equal(rendered, current) {
+		m.markGoodLocked()
+		log.Infof("Adopted frpc process (PID: %d, config version %d)", state.PID, state.Version)
+		return nil
+	}
+
+	log.Info("frpc config changed across agent restart, restarting frpc")
+	if err := m.stopLocked(); err != nil {
+		log.Warnf("Error stopping frpc: %v", err)
+	}
+	return m.startWithFailoverLocked(ctx, m.active)
+}
+
+// watchAdopted 等待接管的frpc退出；该进程不是本进程的子进程，只能轮询
+func watchAdopted(proc *process) {
+	ticker := time.NewTicker(adoptedPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := syscall.Kill(proc.pid, 0); errors.Is(err, syscall.ESRCH) {
+			close(proc.done)
+			return
+		}
+	}
+}
+
+// processMatches 进程是否仍在运行且是使用指定配置文件的frpc，避免PID被复用后误操作其他进程
+func processMatches(pid int, configPath string) bool {
+	if pid <= 0 {
+		return false
+	}
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return false
+	}
+	return configPath == "" || strings.Contains(string(cmdline), configPath)
+}
+
+// writePIDLocked 记录可交接的frpc的PID（调用方需持有锁）
+func (m *Manager) writePIDLocked() {
+	if !m.handover || m.proc == nil {
+		return
+	}
+	path := filepath.Join(m.configDir, pidFile)
+	if err := os.WriteFile(path, []byte(strconv.Itoa(m.proc.pid)), 0600); err != nil {
+		log.Warnf("Failed to write frpc PID file: %v", err)
+	}
+}
+
+// removePIDLocked 删除frpc的PID文件（调用方需持有锁）
+func (m *Manager) removePIDLocked() {
+	if m.handover {
+		os.Remove(filepath.Join(m.configDir, pidFile))
+	}
+}
+
+// killStaleLocked 结束agent异常退出后遗留的可交接frpc，避免重复的代理（调用方需持有锁）
+func (m *Manager) killStaleLocked() {
+	path := filepath.Join(m.configDir, pidFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	os.Remove(path)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || !processMatches(pid, m.configDir) {
+		return
+	}
+	log.Warnf("Stopping stale frpc process (PID: %d)", pid)
+	signalGroup(pid, syscall.SIGTERM)
+	deadline := time.Now().Add(frpcStopTimeout)
+	for time.Now().Before(deadline) {
+		if processMatches(pid, m.configDir) {
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		return
+	}
+	signalGroup(pid, syscall.SIGKILL)
+}
+
+// dupFile 复制文件描述符（设置close-on-exec），不改变原文件的非阻塞模式
+func dupFile(file *os.File) (*os.File, error) {
+	raw, err := file.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	fd := -1
+	var dupErr error
+	if err := raw.Control(func(original uintptr) {
+		fd, dupErr = unix.FcntlInt(original, unix.F_DUPFD_CLOEXEC, 0)
+	}); err != nil {
+		return nil, err
+	}
+	if dupErr != nil {
+		return nil, dupErr
+	}
+	return os.NewFile(uintptr(fd), file.Name()), nil
+}
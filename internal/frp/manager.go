@@ -1,11 +1,18 @@
 package frp
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 	"text/template"
 	"time"
@@ -15,13 +22,22 @@ import (
 
 // Config FRP配置
 type Config struct {
-	ServerAddr        string      `json:"server_addr"`
-	ServerPort        int         `json:"server_port"`
+	ServerAddr string `json:"server_addr"`
+	ServerPort int    `json:"server_port"`
+	// 备用服务器，主服务器不可达时按顺序切换
+	FallbackServers   []Server    `json:"fallback_servers,omitempty"`
 	FrpToken          string      `json:"frp_token"`
 	NodeID            string      `json:"node_id"`
+	AgentApiLocalIP   string      `json:"agent_api_local_ip"`
 	AgentApiPort      int         `json:"agent_api_port"`
 	ControlRemotePort int         `json:"control_remote_port"`
 	Gpus              []GPUTunnel `json:"gpus"`
+	// claim发布的端口
+	Claims []ClaimTunnel `json:"claims,omitempty"`
+	// frpc管理接口在127.0.0.1上的端口，0表示不启用（隧道状态只从日志判断）
+	AdminPort int `json:"admin_port,omitempty"`
+	// 隧道访问地址使用的主机名，为空时使用当前frps服务器地址
+	PublicHost string `json:"public_host,omitempty"`
 }
 
 // GPUTunnel GPU隧道配置
@@ -33,13 +49,87 @@ type GPUTunnel struct {
 	SshRemotePort int `json:"ssh_remote_port"`
 }
 
+// ClaimTunnel claim发布端口的隧道配置
+type ClaimTunnel struct {
+	ClaimID    string `json:"claim_id"`
+	PortName   string `json:"port_name"`
+	LocalPort  int    `json:"local_port"`
+	RemotePort int    `json:"remote_port"`
+}
+
 // Manager FRP管理器
+// 每次生成的配置写入带版本号的文件，保留最近一次成功启动的配置用于回滚
 type Manager struct {
+	mu        sync.Mutex
+	configDir string
+	proc      *process
+	config    *Config
+	// 当前配置文件及版本
 	configPath string
-	cmd        *exec.Cmd
-	config     *Config
+	version    int64
+	// 最近一次成功启动frpc的配置
+	goodConfig  *Config
+	goodPath    string
+	goodVersion int64
+	goodActive  int
+	// 当前使用的服务器在服务器列表中的下标，及各服务器的探测状态
+	active int
+	health map[Server]ServerStatus
+	// 等待frpc登录frps的时间
+	startTimeout time.Duration
+	// 当前frpc进程日志中的代理启动结果
+	proxies *proxyLog
+	// frpc管理接口的密码，每次启动agent时随机生成
+	adminPassword string
+	// frpc可执行文件，及PATH中没有frpc时的自动下载配置
+	binary   string
+	download DownloadConfig
+	// 是否以可交接方式启动frpc，及是否已交给新的agent进程
+	handover bool
+	detached bool
+}
+
+// adminUser frpc管理接口的用户名
+const adminUser = "utopia"
+
+// templateData 渲染frpc配置模板的数据
+type templateData struct {
+	Config
+	AdminUser     string
+	AdminPassword string
+}
+
+// process 运行中的frpc进程
+type process struct {
+	// 从旧agent进程接管的frpc没有cmd
+	cmd *exec.Cmd
+	pid int
+	// frpc输出管道的读端，输出转发到forward
+	output  *os.File
+	forward io.Writer
+	// 进程退出后关闭
+	done chan struct{}
+	err  error
+}
+
+// copyOutput 转发frpc输出，直到frpc退出或交接时设置读超时
+func (p *process) copyOutput() {
+	io.Copy(p.forward, p.output)
+}
+
+// exited 进程是否已退出
+func (p *process) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
 }
 
+// frpcStopTimeout 停止frpc时等待其优雅退出的时间，超时后强制结束
+const frpcStopTimeout = 10 * time.Second
+
 // frpc.toml模板
 const frpcTemplate = `
 serverAddr = "{{.ServerAddr}}"
@@ -47,12 +137,18 @@ serverPort = {{.ServerPort}}
 auth.method = "token"
 auth.token = "{{.FrpToken}}"
 user = "{{.NodeID}}"
-
+{{if .AdminPort}}
+# 管理接口，仅供agent查询隧道状态
+webServer.addr = "127.0.0.1"
+webServer.port = {{.AdminPort}}
+webServer.user = "{{.AdminUser}}"
+webServer.password = "{{.AdminPassword}}"
+{{end}}
 # 控制隧道
 [[proxies]]
 name = "control_{{.NodeID}}"
 type = "tcp"
-localIP = "127.0.0.1"
+localIP = "{{.AgentApiLocalIP}}"
 localPort = {{.AgentApiPort}}
 remotePort = {{.ControlRemotePort}}
 [proxies.metadatas]
@@ -85,166 +181,389 @@ tunnel_type = "container-data"
 gpu_id = "{{.ID}}"
 port_name = "ssh"
 {{end}}
+# claim发布的端口
+{{range .Claims}}
+[[proxies]]
+name = "claim_{{$.NodeID}}_{{.ClaimID}}_{{.PortName}}"
+type = "tcp"
+localIP = "127.0.0.1"
+localPort = {{.LocalPort}}
+remotePort = {{.RemotePort}}
+[proxies.metadatas]
+node_id = "{{$.NodeID}}"
+tunnel_type = "claim-port"
+claim_id = "{{.ClaimID}}"
+port_name = "{{.PortName}}"
+{{end}}
 `
 
 // NewManager 创建新的FRP管理器
 func NewManager(config *Config) (*Manager, error) {
 	// 创建临时配置目录
-	tmpDir := "/tmp/utopia"
-	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+	configDir := "/tmp/utopia"
+	if err := os.MkdirAll(configDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
 
-	configPath := filepath.Join(tmpDir, "frpc.toml")
+	password := make([]byte, 16)
+	if _, err := rand.Read(password); err != nil {
+		return nil, fmt.Errorf("failed to generate frpc admin password: %w", err)
+	}
 
 	return &Manager{
-		configPath: configPath,
-		config:     config,
+		configDir:     configDir,
+		config:        config,
+		health:        make(map[Server]ServerStatus),
+		startTimeout:  DefaultStartTimeout,
+		adminPassword: hex.EncodeToString(password),
+		binary:        "frpc",
 	}, nil
 }
 
-// GenerateConfig 生成frpc配置文件
-func (m *Manager) GenerateConfig() error {
-	tmpl, err := template.New("frpc").Parse(frpcTemplate)
+// SetStartTimeout 设置等待frpc登录frps的时间，下次启动时生效
+func (m *Manager) SetStartTimeout(timeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if timeout > 0 {
+		m.startTimeout = timeout
+	}
+}
+
+// writeConfigLocked 将配置写入新版本的配置文件（调用方需持有锁）
+// 先写入临时文件再原子重命名，frpc不会读到写了一半的配置
+func (m *Manager) writeConfigLocked(config *Config) (string, int64, error) {
+	content, err := m.renderLocked(config)
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
+		return "", 0, err
+	}
+
+	version := m.version + 1
+	path := filepath.Join(m.configDir, fmt.Sprintf("frpc.%d.toml", version))
+	tmpPath := path + ".tmp"
+
+	// 配置包含认证令牌，仅所有者可读
+	if err := os.WriteFile(tmpPath, content, 0600); err != nil {
+		os.Remove(tmpPath)
+		return "", 0, fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return "", 0, fmt.Errorf("failed to move config file: %w", err)
 	}
 
-	file, err := os.Create(m.configPath)
+	log.Infof("Generated frpc config version %d at %s", version, path)
+	return path, version, nil
+}
+
+// renderLocked 使用当前选中的服务器渲染frpc配置（调用方需持有锁）
+func (m *Manager) renderLocked(config *Config) ([]byte, error) {
+	tmpl, err := template.New("frpc").Parse(frpcTemplate)
 	if err != nil {
-		return fmt.Errorf("failed to create config file: %w", err)
+		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
-	defer file.Close()
+	rendered := templateData{Config: *config, AdminUser: adminUser, AdminPassword: m.adminPassword}
+	server := m.activeServerLocked(config)
+	rendered.ServerAddr, rendered.ServerPort = server.Addr, server.Port
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, &rendered); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
 
-	if err := tmpl.Execute(file, m.config); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+// pruneConfigsLocked 删除当前配置与最近成功配置之外的配置文件（调用方需持有锁）
+func (m *Manager) pruneConfigsLocked() {
+	paths, err := filepath.Glob(filepath.Join(m.configDir, "frpc.*.toml"))
+	if err != nil {
+		return
 	}
+	for _, path := range paths {
+		if path == m.configPath || path == m.goodPath {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Warnf("Failed to remove old frpc config %s: %v", path, err)
+		}
+	}
+}
 
-	log.Infof("Generated frpc config at %s", m.configPath)
+// verifyConfig 使用 frpc verify 检查配置文件
+func verifyConfig(ctx context.Context, binary, path string) error {
+	output, err := exec.CommandContext(ctx, binary, "verify", "-c", path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("frpc rejected config: %w: %s", err, strings.TrimSpace(string(output)))
+	}
 	return nil
 }
 
 // Start 启动frpc进程
 func (m *Manager) Start(ctx context.Context) error {
-	// 首先生成配置文件
-	if err := m.GenerateConfig(); err != nil {
-		return fmt.Errorf("failed to generate config: %w", err)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.detached {
+		return ErrDetached
 	}
 
-	// 检查frpc是否可用
-	if _, err := exec.LookPath("frpc"); err != nil {
-		return fmt.Errorf("frpc not found in PATH: %w", err)
+	// 检查frpc是否可用，PATH中没有时下载固定版本
+	if err := m.resolveBinaryLocked(ctx); err != nil {
+		return err
 	}
+	m.killStaleLocked()
+
+	// 生成配置文件并启动，主服务器不可达时切换到备用服务器
+	return m.startWithFailoverLocked(ctx, 0)
+}
 
-	// 启动frpc进程
-	m.cmd = exec.CommandContext(ctx, "frpc", "-c", m.configPath)
-	m.cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true, // 创建新的进程组
+// startLocked 使用当前配置文件启动frpc，等待其登录frps（调用方需持有锁）
+// 令牌被拒绝、服务器不可达与超时分别返回 ErrTokenRejected、ErrServerUnreachable 与 ErrStartTimeout，
+// 失败或ctx取消时停止frpc
+func (m *Manager) startLocked(ctx context.Context) error {
+	// 独立的进程组，发给agent进程组的信号（如终端的Ctrl+C）不会在agent停止前终止frpc
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	var cmd *exec.Cmd
+	if m.handover {
+		// 可交接的frpc不随agent进程与ctx结束，由新的agent进程接管；异常遗留的进程在下次启动时结束
+		cmd = exec.Command(m.binary, "-c", m.configPath)
+	} else {
+		cmd = exec.CommandContext(ctx, m.binary, "-c", m.configPath)
+		// agent异常退出时frpc随之退出，不会遗留占用隧道的孤儿进程
+		attr.Pdeathsig = syscall.SIGTERM
+		// ctx取消时与Stop一样向整个进程组发送SIGTERM，超时后强制结束
+		cmd.Cancel = func() error { return signalGroup(cmd.Process.Pid, syscall.SIGTERM) }
+		cmd.WaitDelay = frpcStopTimeout
 	}
+	cmd.SysProcAttr = attr
 
-	// 设置输出日志
-	m.cmd.Stdout = log.StandardLogger().Writer()
-	m.cmd.Stderr = log.StandardLogger().Writer()
+	// 输出经管道写入日志，同时从中识别登录结果；管道的读端可交给新的agent进程
+	watcher := newStartupWatcher(log.StandardLogger().Writer())
+	m.proxies = newProxyLog()
+	watcher.proxies = m.proxies
+	output, writer, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create frpc output pipe: %w", err)
+	}
+	cmd.Stdout = writer
+	cmd.Stderr = writer
 
-	if err := m.cmd.Start(); err != nil {
+	if err := cmd.Start(); err != nil {
+		output.Close()
+		writer.Close()
 		return fmt.Errorf("failed to start frpc: %w", err)
 	}
+	writer.Close()
+
+	proc := &process{cmd: cmd, pid: cmd.Process.Pid, output: output, forward: watcher, done: make(chan struct{})}
+	go proc.copyOutput()
+	go func() {
+		proc.err = cmd.Wait()
+		close(proc.done)
+	}()
+	m.proc = proc
+	m.writePIDLocked()
 
-	log.Infof("Started frpc process (PID: %d)", m.cmd.Process.Pid)
+	log.Infof("Started frpc process (PID: %d, config version %d)", cmd.Process.Pid, m.version)
 
-	// 等待一小段时间确保frpc启动成功
-	time.Sleep(2 * time.Second)
+	timer := time.NewTimer(m.startTimeout)
+	defer timer.Stop()
 
-	// 检查进程是否还在运行
-	if m.cmd.Process != nil {
-		if err := m.cmd.Process.Signal(syscall.Signal(0)); err != nil {
-			return fmt.Errorf("frpc process failed to start properly: %w", err)
+	select {
+	case err = <-watcher.result:
+	case <-proc.done:
+		// 登录失败时frpc输出原因后退出，优先返回识别出的原因
+		select {
+		case err = <-watcher.result:
+			if err == nil {
+				err = fmt.Errorf("frpc exited after logging in: %v", proc.err)
+			}
+		default:
+			err = fmt.Errorf("frpc process failed to start properly: %v", proc.err)
 		}
+	case <-timer.C:
+		err = fmt.Errorf("%w (%s)", ErrStartTimeout, m.startTimeout)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		if stopErr := m.stopLocked(); stopErr != nil {
+			log.Debugf("frpc exited: %v", stopErr)
+		}
+		return err
 	}
 
+	log.Infof("frpc logged in to %s", m.activeServerLocked(m.config))
 	return nil
 }
 
+// markGoodLocked 将当前配置记为最近一次成功启动的配置（调用方需持有锁）
+func (m *Manager) markGoodLocked() {
+	m.goodConfig, m.goodPath, m.goodVersion = m.config, m.configPath, m.version
+	m.goodActive = m.active
+	m.pruneConfigsLocked()
+}
+
 // Stop 停止frpc进程
 func (m *Manager) Stop() error {
-	if m.cmd == nil || m.cmd.Process == nil {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stopLocked()
+}
+
+// stopLocked 停止frpc进程（调用方需持有锁）
+func (m *Manager) stopLocked() error {
+	proc := m.proc
+	if proc == nil || m.detached {
+		// 已交给新agent进程的frpc继续运行
+		return nil
+	}
+	// 进程退出后清空，保证重复调用Stop是安全的
+	defer func() {
+		m.proc = nil
+		m.removePIDLocked()
+	}()
+
+	if proc.exited() {
 		return nil
 	}
 
 	log.Info("Stopping frpc process...")
 
-	// 发送SIGTERM信号
-	if err := m.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+	// 向frpc的进程组发送SIGTERM信号
+	if err := signalGroup(proc.pid, syscall.SIGTERM); err != nil {
 		log.Warnf("Failed to send SIGTERM to frpc: %v", err)
 	}
 
-	// 等待进程退出
-	done := make(chan error, 1)
-	go func() {
-		done <- m.cmd.Wait()
-	}()
-
 	select {
-	case err := <-done:
+	case <-proc.done:
 		log.Info("frpc process stopped gracefully")
-		return err
-	case <-time.After(10 * time.Second):
-		// 超时后强制杀死进程
+		return proc.err
+	case <-time.After(frpcStopTimeout):
+		// 超时后强制杀死整个进程组
 		log.Warn("frpc process did not stop gracefully, force killing...")
-		if err := m.cmd.Process.Kill(); err != nil {
+		if err := signalGroup(proc.pid, syscall.SIGKILL); err != nil {
 			return fmt.Errorf("failed to kill frpc process: %w", err)
 		}
-		<-done // 等待Wait()返回
+		<-proc.done // 等待Wait()返回
 		log.Info("frpc process killed")
 		return nil
 	}
 }
 
-// IsRunning 检查frpc是否在运行
-func (m *Manager) IsRunning() bool {
-	if m.cmd == nil || m.cmd.Process == nil {
-		return false
+// signalGroup 向进程所在的进程组发送信号，进程组已不存在时退回只向进程发送
+func signalGroup(pid int, sig syscall.Signal) error {
+	if err := syscall.Kill(-pid, sig); err == nil || !errors.Is(err, syscall.ESRCH) {
+		return err
 	}
+	return syscall.Kill(pid, sig)
+}
 
-	// 发送信号0检查进程是否存在
-	err := m.cmd.Process.Signal(syscall.Signal(0))
-	return err == nil
+// IsRunning 检查frpc是否在运行
+func (m *Manager) IsRunning() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.proc != nil && !m.proc.exited()
 }
 
-// Restart 重启frpc进程
+// Restart 使用当前配置重启frpc进程，当前服务器不可达时切换到其他服务器
 func (m *Manager) Restart(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.detached {
+		return ErrDetached
+	}
+
 	log.Info("Restarting frpc process...")
 
-	if err := m.Stop(); err != nil {
+	if err := m.stopLocked(); err != nil {
 		log.Warnf("Error stopping frpc: %v", err)
 	}
 
 	// 等待一下再启动
 	time.Sleep(1 * time.Second)
 
-	return m.Start(ctx)
+	return m.startWithFailoverLocked(ctx, m.active)
 }
 
 // GetPID 获取frpc进程ID
 func (m *Manager) GetPID() int {
-	if m.cmd == nil || m.cmd.Process == nil {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.proc == nil || m.proc.exited() {
 		return 0
 	}
-	return m.cmd.Process.Pid
+	return m.proc.pid
+}
+
+// Version 返回当前配置版本及最近一次成功启动的配置版本
+func (m *Manager) Version() (current, good int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.version, m.goodVersion
 }
 
 // UpdateConfig 更新配置并重启
+// 新配置未通过 frpc verify 时保持原进程运行；frpc使用新配置启动失败时自动回滚到最近一次成功的配置
 func (m *Manager) UpdateConfig(ctx context.Context, config *Config) error {
-	m.config = config
-	return m.Restart(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.detached {
+		return ErrDetached
+	}
+
+	previousActive := m.active
+	m.retargetLocked(config)
+	path, version, err := m.writeConfigLocked(config)
+	if err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+	if err := verifyConfig(ctx, m.binary, path); err != nil {
+		os.Remove(path)
+		m.active = previousActive
+		return err
+	}
+
+	if err := m.stopLocked(); err != nil {
+		log.Warnf("Error stopping frpc: %v", err)
+	}
+
+	m.config, m.configPath, m.version = config, path, version
+	startErr := m.startLocked(ctx)
+	if startErr == nil {
+		m.markGoodLocked()
+		return nil
+	}
+
+	if m.goodConfig == nil {
+		return startErr
+	}
+
+	log.Warnf("frpc failed with config version %d, rolling back to version %d: %v", version, m.goodVersion, startErr)
+	m.config, m.configPath, m.version = m.goodConfig, m.goodPath, m.goodVersion
+	m.active = m.goodActive
+	if err := m.startLocked(ctx); err != nil {
+		return fmt.Errorf("%v; rollback to config version %d also failed: %w", startErr, m.goodVersion, err)
+	}
+	os.Remove(path)
+	return fmt.Errorf("rolled back to config version %d: %w", m.goodVersion, startErr)
 }
 
 // CleanupConfig 清理配置文件
 func (m *Manager) CleanupConfig() error {
-	if m.configPath != "" {
-		if err := os.Remove(m.configPath); err != nil && !os.IsNotExist(err) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.detached {
+		// 配置文件仍由交接后的frpc使用
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(m.configDir, "frpc.*.toml*"))
+	if err != nil {
+		return fmt.Errorf("failed to list config files: %w", err)
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
 			return fmt.Errorf("failed to remove config file: %w", err)
 		}
 	}
+	m.configPath, m.goodPath = "", ""
 	return nil
 }
@@ -0,0 +1,107 @@
+package frp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTokenRejected frps拒绝了认证令牌
+	ErrTokenRejected = errors.New("frps rejected the auth token")
+	// ErrServerUnreachable frpc无法连接frps
+	ErrServerUnreachable = errors.New("frps is unreachable")
+	// ErrStartTimeout frpc未在启动超时内登录frps
+	ErrStartTimeout = errors.New("frpc did not log in to frps before the startup timeout")
+)
+
+// DefaultStartTimeout 等待frpc登录frps的默认时间
+const DefaultStartTimeout = 30 * time.Second
+
+// maxWatcherLine 启动监视器缓存的最长未结束行
+const maxWatcherLine = 64 * 1024
+
+// frpc日志中的登录结果
+var (
+	loginSuccessMarkers = []string{"login to server success"}
+	authFailureMarkers  = []string{"authorization failed", "token in login doesn't match", "invalid token"}
+	dialFailureMarkers  = []string{
+		"connection refused", "i/o timeout", "no such host", "network is unreachable",
+		"no route to host", "connection reset", "login to the server failed",
+	}
+)
+
+// startupWatcher 将frpc输出转发到日志，并从中识别首次登录frps的结果
+type startupWatcher struct {
+	out io.Writer
+
+	mu     sync.Mutex
+	buf    []byte
+	result chan error
+	// 记录各代理的启动结果（可选）
+	proxies *proxyLog
+}
+
+// newStartupWatcher 创建转发到out的启动监视器
+func newStartupWatcher(out io.Writer) *startupWatcher {
+	return &startupWatcher{out: out, result: make(chan error, 1)}
+}
+
+// Write 转发输出并逐行检查登录结果
+func (w *startupWatcher) Write(p []byte) (int, error) {
+	w.out.Write(p)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.inspect(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) > maxWatcherLine {
+		w.buf = w.buf[:0]
+	}
+	return len(p), nil
+}
+
+// inspect 识别一行输出中的登录结果，只保留第一个结果
+func (w *startupWatcher) inspect(line string) {
+	if w.proxies != nil {
+		w.proxies.inspect(line)
+	}
+
+	var result error
+	switch {
+	case containsAny(line, loginSuccessMarkers):
+		result = nil
+	case containsAny(line, authFailureMarkers):
+		result = fmt.Errorf("%w: %s", ErrTokenRejected, strings.TrimSpace(line))
+	case containsAny(line, dialFailureMarkers):
+		result = fmt.Errorf("%w: %s", ErrServerUnreachable, strings.TrimSpace(line))
+	default:
+		return
+	}
+	select {
+	case w.result <- result:
+	default:
+	}
+}
+
+// containsAny 字符串是否包含任一标记（不区分大小写）
+func containsAny(s string, markers []string) bool {
+	s = strings.ToLower(s)
+	for _, marker := range markers {
+		if strings.Contains(s, marker) {
+			return true
+		}
+	}
+	return false
+}
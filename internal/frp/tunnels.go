@@ -0,0 +1,273 @@
+package frp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 隧道状态
+const (
+	// frpc已启动，尚未报告该代理的启动结果
+	TunnelPending = "pending"
+	TunnelRunning = "running"
+	TunnelError   = "error"
+	// frpc未运行
+	TunnelStopped = "stopped"
+)
+
+// 隧道类别，与代理元数据中的 tunnel_type 一致
+const (
+	TunnelKindControl = "agent-control"
+	TunnelKindData    = "container-data"
+	TunnelKindClaim   = "claim-port"
+)
+
+// 隧道状态的来源
+const (
+	TunnelSourceAdminAPI = "admin_api"
+	TunnelSourceLog      = "log"
+)
+
+// adminStatusTimeout 查询frpc管理接口的超时时间
+const adminStatusTimeout = 2 * time.Second
+
+// proxyResultPattern frpc日志中代理的启动结果，如
+// "[claim_1_c-1_web] start proxy success" 或 "[data_1_gpu0_ssh] start error: port already used"
+var proxyResultPattern = regexp.MustCompile(`\[([^\[\]\s]+)\] (start proxy success|start error: .*)$`)
+
+// TunnelStatus 隧道（frpc代理）的配置与连接状态
+type TunnelStatus struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Kind     string `json:"kind"`
+	ClaimID  string `json:"claim_id,omitempty"`
+	GPUID    *int   `json:"gpu_id,omitempty"`
+	PortName string `json:"port_name,omitempty"`
+	// 本地地址
+	LocalIP   string `json:"local_ip"`
+	LocalPort int    `json:"local_port"`
+	// 配置中请求的远端端口与frps实际分配的远端端口（隧道运行时）
+	RequestedRemotePort int `json:"requested_remote_port"`
+	RemotePort          int `json:"remote_port,omitempty"`
+	// 外部访问地址 host:port（隧道运行时）
+	Address string `json:"address,omitempty"`
+	State   string `json:"state"`
+	Error   string `json:"error,omitempty"`
+	// 状态来源：admin_api（frpc管理接口）或 log（frpc日志）
+	Source string `json:"source,omitempty"`
+}
+
+// proxyResult frpc日志中记录的代理启动结果
+type proxyResult struct {
+	state string
+	err   string
+}
+
+// proxyLog 记录一个frpc进程日志中各代理最近一次的启动结果
+type proxyLog struct {
+	mu      sync.Mutex
+	results map[string]proxyResult
+}
+
+// newProxyLog 创建代理启动结果记录
+func newProxyLog() *proxyLog {
+	return &proxyLog{results: make(map[string]proxyResult)}
+}
+
+// inspect 识别一行frpc输出中的代理启动结果
+func (l *proxyLog) inspect(line string) {
+	match := proxyResultPattern.FindStringSubmatch(line)
+	if match == nil {
+		return
+	}
+	result := proxyResult{state: TunnelRunning}
+	if match[2] != "start proxy success" {
+		result = proxyResult{state: TunnelError, err: match[2][len("start error: "):]}
+	}
+	l.mu.Lock()
+	l.results[match[1]] = result
+	l.mu.Unlock()
+}
+
+// snapshot 返回各代理的启动结果
+func (l *proxyLog) snapshot() map[string]proxyResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	results := make(map[string]proxyResult, len(l.results))
+	for name, result := range l.results {
+		results[name] = result
+	}
+	return results
+}
+
+// adminProxyStatus frpc管理接口 /api/status 返回的代理状态
+type adminProxyStatus struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Status     string `json:"status"`
+	Err        string `json:"err"`
+	RemoteAddr string `json:"remote_addr"`
+}
+
+// requestedTunnels 返回配置中请求的全部隧道，名称与frpc配置模板一致
+func requestedTunnels(config *Config) []TunnelStatus {
+	tunnels := []TunnelStatus{{
+		Name:                "control_" + config.NodeID,
+		Kind:                TunnelKindControl,
+		LocalIP:             config.AgentApiLocalIP,
+		LocalPort:           config.AgentApiPort,
+		RequestedRemotePort: config.ControlRemotePort,
+	}}
+	for _, gpu := range config.Gpus {
+		id := gpu.ID
+		tunnels = append(tunnels,
+			TunnelStatus{
+				Name:                fmt.Sprintf("data_%s_gpu%d_web", config.NodeID, id),
+				Kind:                TunnelKindData,
+				GPUID:               &id,
+				PortName:            "web",
+				LocalIP:             "127.0.0.1",
+				LocalPort:           gpu.WebLocalPort,
+				RequestedRemotePort: gpu.WebRemotePort,
+			},
+			TunnelStatus{
+				Name:                fmt.Sprintf("data_%s_gpu%d_ssh", config.NodeID, id),
+				Kind:                TunnelKindData,
+				GPUID:               &id,
+				PortName:            "ssh",
+				LocalIP:             "127.0.0.1",
+				LocalPort:           gpu.SshLocalPort,
+				RequestedRemotePort: gpu.SshRemotePort,
+			},
+		)
+	}
+	for _, claim := range config.Claims {
+		tunnels = append(tunnels, TunnelStatus{
+			Name:                fmt.Sprintf("claim_%s_%s_%s", config.NodeID, claim.ClaimID, claim.PortName),
+			Kind:                TunnelKindClaim,
+			ClaimID:             claim.ClaimID,
+			PortName:            claim.PortName,
+			LocalIP:             "127.0.0.1",
+			LocalPort:           claim.LocalPort,
+			RequestedRemotePort: claim.RemotePort,
+		})
+	}
+	for i := range tunnels {
+		tunnels[i].Type = "tcp"
+	}
+	return tunnels
+}
+
+// Tunnels 返回agent请求的全部隧道及其状态
+// 配置了管理端口时从frpc管理接口读取状态，查询失败或未配置时根据frpc日志判断
+func (m *Manager) Tunnels(ctx context.Context) []TunnelStatus {
+	m.mu.Lock()
+	config := m.config
+	running := m.proc != nil && !m.proc.exited()
+	var logged map[string]proxyResult
+	if running && m.proxies != nil {
+		logged = m.proxies.snapshot()
+	}
+	server := m.activeServerLocked(config)
+	password := m.adminPassword
+	m.mu.Unlock()
+
+	tunnels := requestedTunnels(config)
+	if !running {
+		for i := range tunnels {
+			tunnels[i].State = TunnelStopped
+		}
+		return tunnels
+	}
+
+	var admin map[string]adminProxyStatus
+	if config.AdminPort > 0 {
+		var err error
+		if admin, err = fetchAdminStatus(ctx, config.AdminPort, password); err != nil {
+			log.Debugf("Failed to query frpc admin API, using log-derived tunnel status: %v", err)
+		}
+	}
+
+	host := config.PublicHost
+	if host == "" {
+		host = server.Addr
+	}
+	for i := range tunnels {
+		t := &tunnels[i]
+		t.State = TunnelPending
+		if status, ok := admin[t.Name]; ok {
+			t.Source = TunnelSourceAdminAPI
+			switch status.Status {
+			case "running":
+				t.State = TunnelRunning
+				t.RemotePort = t.RequestedRemotePort
+				if _, port, err := net.SplitHostPort(status.RemoteAddr); err == nil {
+					if p, err := strconv.Atoi(port); err == nil && p > 0 {
+						t.RemotePort = p
+					}
+				}
+			case "start error", "check failed", "closed":
+				t.State = TunnelError
+				t.Error = status.Err
+				if t.Error == "" {
+					t.Error = status.Status
+				}
+			}
+		} else if result, ok := logged[t.Name]; ok {
+			t.Source = TunnelSourceLog
+			t.State = result.state
+			t.Error = result.err
+			// 指定了远端端口的tcp代理启动成功即获得该端口
+			if result.state == TunnelRunning {
+				t.RemotePort = t.RequestedRemotePort
+			}
+		}
+		if t.RemotePort > 0 {
+			t.Address = net.JoinHostPort(host, strconv.Itoa(t.RemotePort))
+		}
+	}
+	return tunnels
+}
+
+// fetchAdminStatus 查询frpc管理接口中各代理的状态
+func fetchAdminStatus(ctx context.Context, port int, password string) (map[string]adminProxyStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, adminStatusTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/api/status", port)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(adminUser, password)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("frpc admin API returned %s", resp.Status)
+	}
+
+	// 按代理类型分组：{"tcp": [...], "http": [...]}
+	var groups map[string][]adminProxyStatus
+	if err := json.NewDecoder(resp.Body).Decode(&groups); err != nil {
+		return nil, fmt.Errorf("failed to parse frpc admin API response: %w", err)
+	}
+	result := make(map[string]adminProxyStatus)
+	for _, statuses := range groups {
+		for _, status := range statuses {
+			result[status.Name] = status
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,179 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// cleanupPollInterval 等待显存释放时的检查间隔
+const cleanupPollInterval = 2 * time.Second
+
+// killGracePeriod 结束残留进程后等待显存释放的时间
+const killGracePeriod = 5 * time.Second
+
+// CleanupPolicy claim之间的GPU清理策略
+type CleanupPolicy struct {
+	// 显存占用不超过该值（MB）且没有计算进程时视为已清理
+	MaxMemoryMB int
+	// 等待显存自然释放的时间，超时后执行补救
+	Timeout time.Duration
+	// 补救时结束残留的计算进程
+	KillProcesses bool
+	// 仍未清理且没有进程时重置GPU
+	ResetGPU bool
+}
+
+// CleanupResult 单个GPU的清理结果
+type CleanupResult struct {
+	GPUID        int      `json:"gpu_id"`
+	Clean        bool     `json:"clean"`
+	MemoryUsedMB int      `json:"memory_used_mb"`
+	Processes    []uint32 `json:"processes,omitempty"`
+	// 执行的补救措施
+	KilledProcesses []uint32 `json:"killed_processes,omitempty"`
+	Reset           bool     `json:"reset,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// Remediated 是否执行过补救措施
+func (r CleanupResult) Remediated() bool {
+	return len(r.KilledProcesses) > 0 || r.Reset
+}
+
+// MarkPendingCleanup 将GPU标记为待清理，清理确认前不再分配
+func (m *Monitor) MarkPendingCleanup(gpuIDs []int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range gpuIDs {
+		m.pendingCleanup[id] = true
+		if id >= 0 && id < len(m.gpus) && !m.gpus[id].Busy {
+			m.gpus[id].Busy = true
+			m.gpus[id].BusyBy = BusyByPendingCleanup
+		}
+	}
+}
+
+// ClearPendingCleanup 清除GPU的待清理标记，使其可以重新分配
+func (m *Monitor) ClearPendingCleanup(gpuID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pendingCleanup, gpuID)
+	if gpuID >= 0 && gpuID < len(m.gpus) && m.gpus[gpuID].BusyBy == BusyByPendingCleanup {
+		m.gpus[gpuID].Busy = false
+		m.gpus[gpuID].BusyBy = ""
+	}
+}
+
+// PendingCleanup 返回待清理的GPU
+func (m *Monitor) PendingCleanup() []int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var ids []int
+	for id := range m.pendingCleanup {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// CleanupGPU 确认GPU的显存与计算进程已释放，超时后按策略补救
+// 确认清理后清除待清理标记；仍未清理时GPU保持不可分配，需人工处理
+func (m *Monitor) CleanupGPU(ctx context.Context, gpuID int, policy CleanupPolicy) CleanupResult {
+	result := CleanupResult{GPUID: gpuID}
+
+	deadline := time.Now().Add(policy.Timeout)
+	for {
+		if m.checkClean(gpuID, policy, &result) || !time.Now().Before(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			result.Error = ctx.Err().Error()
+			return result
+		case <-time.After(cleanupPollInterval):
+		}
+	}
+
+	if !result.Clean && policy.KillProcesses && len(result.Processes) > 0 {
+		for _, pid := range result.Processes {
+			if err := syscall.Kill(int(pid), syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+				fmt.Printf("Warning: failed to kill process %d on GPU %d: %v\n", pid, gpuID, err)
+				continue
+			}
+			result.KilledProcesses = append(result.KilledProcesses, pid)
+		}
+		select {
+		case <-ctx.Done():
+			result.Error = ctx.Err().Error()
+			return result
+		case <-time.After(killGracePeriod):
+		}
+		m.checkClean(gpuID, policy, &result)
+	}
+
+	if !result.Clean && policy.ResetGPU && result.Error == "" && len(result.Processes) == 0 {
+		if err := resetGPU(ctx, gpuID); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Reset = true
+			m.checkClean(gpuID, policy, &result)
+		}
+	}
+
+	if result.Clean {
+		m.ClearPendingCleanup(gpuID)
+	}
+	return result
+}
+
+// checkClean 查询GPU的显存占用与计算进程，更新结果并返回是否已清理
+func (m *Monitor) checkClean(gpuID int, policy CleanupPolicy, result *CleanupResult) bool {
+	usedMB, pids, err := m.computeUsage(gpuID)
+	if err != nil {
+		result.Error = err.Error()
+		result.Clean = false
+		return false
+	}
+	result.Error = ""
+	result.MemoryUsedMB = usedMB
+	result.Processes = pids
+	result.Clean = usedMB <= policy.MaxMemoryMB && len(pids) == 0
+	return result.Clean
+}
+
+// computeUsage 通过NVML查询GPU当前的显存占用（MB）与计算进程
+func (m *Monitor) computeUsage(gpuID int) (int, []uint32, error) {
+	processes, err := m.deviceProcesses(gpuID)
+	if err != nil {
+		return 0, nil, err
+	}
+	device, ret := nvml.DeviceGetHandleByIndex(gpuID)
+	if ret != nvml.SUCCESS {
+		return 0, nil, fmt.Errorf("failed to get device handle for GPU %d: %v", gpuID, nvml.ErrorString(ret))
+	}
+	memInfo, ret := device.GetMemoryInfo()
+	if ret != nvml.SUCCESS {
+		return 0, nil, fmt.Errorf("failed to get memory info of GPU %d: %v", gpuID, nvml.ErrorString(ret))
+	}
+
+	pids := make([]uint32, 0, len(processes))
+	for _, p := range processes {
+		pids = append(pids, p.Pid)
+	}
+	return int(memInfo.Used / 1024 / 1024), pids, nil
+}
+
+// resetGPU 通过nvidia-smi重置GPU，GPU上不能有任何进程
+func resetGPU(ctx context.Context, gpuID int) error {
+	output, err := exec.CommandContext(ctx, "nvidia-smi", "--gpu-reset", "-i", strconv.Itoa(gpuID)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to reset GPU %d: %w: %s", gpuID, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package gpu
+
+import "fmt"
+
+// 占用者类型
+const (
+	// IntruderContainer 可访问GPU或在GPU上运行进程的非受管容器
+	IntruderContainer = "container"
+	// IntruderProcess 不属于任何容器的宿主机进程
+	IntruderProcess = "process"
+	// IntruderClaim 其他claim的受管容器
+	IntruderClaim = "claim"
+)
+
+// Intruder 使用已预留GPU的非预留方
+type Intruder struct {
+	Kind string `json:"kind"`
+	// GPU上的计算进程，仅配置了GPU访问但未运行进程的容器没有
+	PID          uint32 `json:"pid,omitempty"`
+	ProcessName  string `json:"process_name,omitempty"`
+	UsedMemoryMB int    `json:"used_memory_mb,omitempty"`
+	// 进程所属或配置了GPU访问的容器
+	ContainerID   string `json:"container_id,omitempty"`
+	ContainerName string `json:"container_name,omitempty"`
+	Image         string `json:"image,omitempty"`
+	// 占用者为其他claim的受管容器时的claim
+	ClaimID string `json:"claim_id,omitempty"`
+}
+
+// Key 占用者的标识，同一容器的多个进程视为同一占用者
+func (i Intruder) Key() string {
+	if i.ContainerID != "" {
+		return "container:" + i.ContainerID
+	}
+	return fmt.Sprintf("pid:%d", i.PID)
+}
+
+// SetContention 设置各GPU的占用者，不在intruders中的GPU视为没有争用
+func (m *Monitor) SetContention(intruders map[int][]Intruder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.contention = intruders
+	for i := range m.gpus {
+		m.gpus[i].Intruders = intruders[i]
+		m.gpus[i].Contended = len(intruders[i]) > 0
+	}
+}
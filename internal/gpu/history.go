@@ -0,0 +1,98 @@
+package gpu
+
+import "time"
+
+const (
+	// historyBucket 历史利用率的聚合粒度
+	historyBucket = time.Minute
+	// historyBuckets 保留的聚合桶数（24小时）
+	historyBuckets = 24 * 60
+)
+
+// UsageWindow 时间窗口内的GPU利用率与显存统计
+// 平均值按分钟桶求平均，不受采样频率变化影响
+type UsageWindow struct {
+	AvgUtilizationPercent  float64 `json:"avg_utilization_percent"`
+	PeakUtilizationPercent float64 `json:"peak_utilization_percent"`
+	AvgMemoryUsedMB        float64 `json:"avg_memory_used_mb"`
+	PeakMemoryUsedMB       int     `json:"peak_memory_used_mb"`
+	// 有采样的分钟数，小于窗口长度表示Agent运行时间不足或采样中断
+	SampledMinutes int `json:"sampled_minutes"`
+}
+
+// UsageHistory GPU最近5分钟、1小时与24小时的负载统计
+type UsageHistory struct {
+	Last5m  UsageWindow `json:"5m"`
+	Last1h  UsageWindow `json:"1h"`
+	Last24h UsageWindow `json:"24h"`
+}
+
+// usageBucket 一分钟内的采样聚合
+type usageBucket struct {
+	minute   int64 // Unix分钟数，用于识别过期的桶
+	count    int
+	utilSum  float64
+	utilPeak float64
+	memSum   float64
+	memPeak  int
+}
+
+// usageRing 单个GPU的分钟桶环形缓冲
+type usageRing struct {
+	buckets [historyBuckets]usageBucket
+}
+
+// record 记录一次采样
+func (r *usageRing) record(now time.Time, utilization float64, memoryUsedMB int) {
+	minute := now.Unix() / int64(historyBucket/time.Second)
+	b := &r.buckets[minute%historyBuckets]
+	if b.minute != minute {
+		*b = usageBucket{minute: minute}
+	}
+	b.count++
+	b.utilSum += utilization
+	b.memSum += float64(memoryUsedMB)
+	if utilization > b.utilPeak {
+		b.utilPeak = utilization
+	}
+	if memoryUsedMB > b.memPeak {
+		b.memPeak = memoryUsedMB
+	}
+}
+
+// summary 计算各时间窗口的统计
+func (r *usageRing) summary(now time.Time) *UsageHistory {
+	return &UsageHistory{
+		Last5m:  r.window(now, 5),
+		Last1h:  r.window(now, 60),
+		Last24h: r.window(now, historyBuckets),
+	}
+}
+
+// window 统计包含当前分钟在内的最近minutes个分钟桶
+func (r *usageRing) window(now time.Time, minutes int) UsageWindow {
+	current := now.Unix() / int64(historyBucket/time.Second)
+	var w UsageWindow
+	var utilSum, memSum float64
+	for i := 0; i < minutes; i++ {
+		minute := current - int64(i)
+		b := &r.buckets[minute%historyBuckets]
+		if b.minute != minute || b.count == 0 {
+			continue
+		}
+		w.SampledMinutes++
+		utilSum += b.utilSum / float64(b.count)
+		memSum += b.memSum / float64(b.count)
+		if b.utilPeak > w.PeakUtilizationPercent {
+			w.PeakUtilizationPercent = b.utilPeak
+		}
+		if b.memPeak > w.PeakMemoryUsedMB {
+			w.PeakMemoryUsedMB = b.memPeak
+		}
+	}
+	if w.SampledMinutes > 0 {
+		w.AvgUtilizationPercent = utilSum / float64(w.SampledMinutes)
+		w.AvgMemoryUsedMB = memSum / float64(w.SampledMinutes)
+	}
+	return w
+}
@@ -0,0 +1,171 @@
+package gpu
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// driverGPUsDir 驱动为每个已绑定的GPU创建一个以PCI地址命名的目录
+const driverGPUsDir = "/proc/driver/nvidia/gpus"
+
+// DeviceMove 其他GPU被插入或移除后索引发生变化的GPU
+type DeviceMove struct {
+	UUID string `json:"uuid"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+// DeviceChange 一次刷新中检测到的GPU增减
+type DeviceChange struct {
+	Added         []DeviceIdentity `json:"added,omitempty"`
+	Removed       []DeviceIdentity `json:"removed,omitempty"`
+	Moved         []DeviceMove     `json:"moved,omitempty"`
+	PreviousCount int              `json:"previous_count"`
+	Count         int              `json:"count"`
+}
+
+// SetDeviceChangeHandler 设置GPU增减时的回调
+// 回调在刷新完成、释放锁后同步调用，不能在其中调用RefreshGPUInfo
+func (m *Monitor) SetDeviceChangeHandler(handler func(DeviceChange)) {
+	m.mu.Lock()
+	m.onDeviceChange = handler
+	m.mu.Unlock()
+}
+
+// IndexOfUUID 返回指定UUID的GPU的当前索引
+func (m *Monitor) IndexOfUUID(uuid string) (int, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, device := range m.devices {
+		if device.UUID == uuid {
+			return device.Index, true
+		}
+	}
+	return 0, false
+}
+
+// driverGPUCount 返回驱动当前绑定的GPU数量，无法读取时返回-1
+func driverGPUCount() int {
+	entries, err := os.ReadDir(driverGPUsDir)
+	if err != nil {
+		return -1
+	}
+	return len(entries)
+}
+
+// reenumerate 驱动绑定的GPU数量变化且与NVML看到的数量不一致时重新初始化NVML
+// NVML只在初始化时枚举设备，热插拔的GPU需重新初始化后才可见；只在驱动报告的数量变化时重试，
+// 避免驱动可见但NVML无法访问的故障GPU导致每次刷新都重新初始化
+func (m *Monitor) reenumerate(count int) (int, error) {
+	driverCount := driverGPUCount()
+	if driverCount < 0 || driverCount == m.driverCount {
+		return count, nil
+	}
+	m.driverCount = driverCount
+	if driverCount == count {
+		return count, nil
+	}
+
+	fmt.Printf("Driver reports %d GPU(s) but NVML sees %d, reinitializing NVML\n", driverCount, count)
+	if ret := nvml.Shutdown(); ret != nvml.SUCCESS {
+		fmt.Printf("Warning: failed to shutdown NVML for re-enumeration: %v\n", nvml.ErrorString(ret))
+	}
+	if ret := nvml.Init(); ret != nvml.SUCCESS {
+		return 0, fmt.Errorf("failed to reinitialize NVML: %v", nvml.ErrorString(ret))
+	}
+	return m.GetGPUCount()
+}
+
+// reindexed 索引为index的GPU是否为新出现或索引发生变化的GPU
+func (c DeviceChange) reindexed(index int) bool {
+	for _, device := range c.Added {
+		if device.Index == index {
+			return true
+		}
+	}
+	for _, move := range c.Moved {
+		if move.To == index {
+			return true
+		}
+	}
+	return false
+}
+
+// recheckManaged GPU增减后重新判定受管容器占用
+// 回调应已按GPU的新索引刷新容器信息，此前按旧索引判定的结果被替换
+func (m *Monitor) recheckManaged(managed func(gpuID int) bool) {
+	m.mu.RLock()
+	count := len(m.gpus)
+	m.mu.RUnlock()
+
+	// 在加锁前查询，避免与容器管理器的锁交叉
+	inUse := make([]bool, count)
+	for i := range inUse {
+		inUse[i] = managed != nil && managed(i)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.gpus {
+		g := &m.gpus[i]
+		switch {
+		case inUse[i]:
+			g.BusyBy = BusyByManagedContainer
+		case g.BusyBy == BusyByManagedContainer:
+			g.BusyBy = ""
+			if m.pendingCleanup[i] {
+				g.BusyBy = BusyByPendingCleanup
+			} else if state := m.usage[i]; state != nil && state.busy {
+				g.BusyBy = BusyByUnknownProcess
+			}
+		}
+		g.Busy = g.BusyBy != ""
+	}
+}
+
+// diffDevices 比较前后两次刷新的GPU身份，没有变化时返回false
+func diffDevices(previous, current []DeviceIdentity) (DeviceChange, bool) {
+	change := DeviceChange{PreviousCount: len(previous), Count: len(current)}
+	before := make(map[string]DeviceIdentity, len(previous))
+	for _, device := range previous {
+		before[device.UUID] = device
+	}
+	for _, device := range current {
+		old, exists := before[device.UUID]
+		switch {
+		case !exists:
+			change.Added = append(change.Added, device)
+		case old.Index != device.Index:
+			change.Moved = append(change.Moved, DeviceMove{UUID: device.UUID, From: old.Index, To: device.Index})
+		}
+		delete(before, device.UUID)
+	}
+	for _, device := range previous {
+		if _, gone := before[device.UUID]; gone {
+			change.Removed = append(change.Removed, device)
+		}
+	}
+	changed := len(change.Added) > 0 || len(change.Removed) > 0 || len(change.Moved) > 0
+	return change, changed
+}
+
+// remapIndices 把按GPU索引记录的状态迁移到GPU的新索引，已移除GPU的状态被丢弃
+func remapIndices[V any](state map[int]V, previous, current []DeviceIdentity) map[int]V {
+	index := make(map[string]int, len(current))
+	for _, device := range current {
+		index[device.UUID] = device.Index
+	}
+	remapped := make(map[int]V, len(state))
+	for _, device := range previous {
+		value, exists := state[device.Index]
+		if !exists {
+			continue
+		}
+		if to, ok := index[device.UUID]; ok {
+			remapped[to] = value
+		}
+	}
+	return remapped
+}
@@ -3,26 +3,103 @@ package gpu
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
 )
 
 // GPUInfo GPU信息
 type GPUInfo struct {
-	ID            int     `json:"id"`
-	TemperatureC  int     `json:"temperature_c"`
-	MemoryTotalMB int     `json:"memory_total_mb"`
-	MemoryUsedMB  int     `json:"memory_used_mb"`
-	Name          string  `json:"name"`
-	UUID          string  `json:"uuid"`
-	Busy          bool    `json:"busy"`
-	UsagePercent  float64 `json:"usage_percent"`
+	ID            int    `json:"id"`
+	TemperatureC  int    `json:"temperature_c"`
+	MemoryTotalMB int    `json:"memory_total_mb"`
+	MemoryUsedMB  int    `json:"memory_used_mb"`
+	Name          string `json:"name"`
+	UUID          string `json:"uuid"`
+	Busy          bool   `json:"busy"`
+	// 忙碌原因：managed_container（受管容器占用）、unknown_process（其他进程持续使用）
+	// 或 pending_cleanup（上一个claim的显存与进程尚未确认释放）
+	BusyBy       string  `json:"busy_by,omitempty"`
+	UsagePercent float64 `json:"usage_percent"`
+	// 各风扇转速（最大转速的百分比），被动散热的GPU为空
+	FanSpeedsPercent []int `json:"fan_speeds_percent,omitempty"`
+	// 当前功耗与生效的功率上限（瓦），驱动不支持时为0
+	PowerDrawW  int `json:"power_draw_w,omitempty"`
+	PowerLimitW int `json:"power_limit_w,omitempty"`
+	// 温控策略因持续高温降低了功率上限
+	ThermalThrottled bool `json:"thermal_throttled,omitempty"`
+	// 最近5分钟、1小时与24小时的负载统计
+	History *UsageHistory `json:"history,omitempty"`
+	// 已预留的GPU被非预留方使用（见争用检测）及其占用者
+	Contended bool       `json:"contended,omitempty"`
+	Intruders []Intruder `json:"intruders,omitempty"`
+}
+
+// 忙碌原因
+const (
+	BusyByManagedContainer = "managed_container"
+	BusyByUnknownProcess   = "unknown_process"
+	BusyByPendingCleanup   = "pending_cleanup"
+)
+
+// BusyPolicy GPU忙碌判定策略
+type BusyPolicy struct {
+	// 显存使用率或GPU利用率超过阈值（百分比）视为有负载
+	MemoryPercent      float64
+	UtilizationPercent float64
+	// 负载状态需持续该时间才切换忙碌判定，过滤短暂尖峰，0表示立即切换
+	Window time.Duration
+}
+
+// DefaultBusyPolicy 默认忙碌判定策略
+var DefaultBusyPolicy = BusyPolicy{MemoryPercent: 10, UtilizationPercent: 10}
+
+// usageState 单个GPU的负载判定状态
+type usageState struct {
+	busy bool
+	// 与当前判定相反的负载状态开始的时间，零值表示没有
+	pendingSince time.Time
 }
 
 // Monitor GPU监控器
 type Monitor struct {
 	mu   sync.RWMutex
 	gpus []GPUInfo
+	// NVML不可用的原因，非空表示处于仅CPU的降级模式
+	unavailableReason string
+
+	policy BusyPolicy
+	usage  map[int]*usageState
+	// 判断GPU是否被受管容器占用
+	managed func(gpuID int) bool
+	// 每个GPU的历史负载
+	history map[int]*usageRing
+	// 驱动维护期间NVML已关闭，不刷新也不分配GPU
+	maintenance bool
+	// 等待清理确认的GPU
+	pendingCleanup map[int]bool
+	// 最近一次争用检测发现的各GPU占用者
+	contention map[int][]Intruder
+	// 被温控策略降低功率上限的GPU
+	throttled map[int]bool
+	// 最近一次刷新时各GPU的身份，用于检测热插拔
+	devices []DeviceIdentity
+	// 最近一次检查时驱动绑定的GPU数量，仅在刷新中访问
+	driverCount int
+	// GPU增减时的回调
+	onDeviceChange func(DeviceChange)
+	// 最近一次成功刷新的时间
+	collectedAt time.Time
+
+	// 进行中的刷新，并发调用方共享其结果，避免同时访问NVML
+	refreshMu  sync.Mutex
+	refreshing *refreshCall
+}
+
+// refreshCall 一次进行中的GPU信息刷新
+type refreshCall struct {
+	done chan struct{}
+	err  error
 }
 
 // NewMonitor 创建新的GPU监控器
@@ -32,11 +109,56 @@ func NewMonitor() (*Monitor, error) {
 		return nil, fmt.Errorf("failed to initialize NVML: %v", nvml.ErrorString(ret))
 	}
 
-	return &Monitor{}, nil
+	return &Monitor{
+		policy:         DefaultBusyPolicy,
+		usage:          make(map[int]*usageState),
+		history:        make(map[int]*usageRing),
+		pendingCleanup: make(map[int]bool),
+		driverCount:    driverGPUCount(),
+	}, nil
+}
+
+// NewUnavailableMonitor 创建降级模式的GPU监控器：不报告任何GPU
+func NewUnavailableMonitor(reason string) *Monitor {
+	return &Monitor{
+		unavailableReason: reason,
+		policy:            DefaultBusyPolicy,
+		usage:             make(map[int]*usageState),
+		history:           make(map[int]*usageRing),
+		pendingCleanup:    make(map[int]bool),
+	}
+}
+
+// SetBusyPolicy 设置忙碌判定策略，下次刷新时生效
+func (m *Monitor) SetBusyPolicy(policy BusyPolicy) {
+	m.mu.Lock()
+	m.policy = policy
+	m.mu.Unlock()
+}
+
+// SetManagedChecker 设置判断GPU是否被受管容器占用的函数
+// 被占用的GPU即使空闲也视为忙碌，不会再分配给其他容器
+func (m *Monitor) SetManagedChecker(managed func(gpuID int) bool) {
+	m.mu.Lock()
+	m.managed = managed
+	m.mu.Unlock()
+}
+
+// Available GPU功能是否可用
+func (m *Monitor) Available() bool {
+	return m.unavailableReason == ""
+}
+
+// UnavailableReason 返回GPU功能不可用的原因，可用时为空
+func (m *Monitor) UnavailableReason() string {
+	return m.unavailableReason
 }
 
 // Close 关闭监控器
 func (m *Monitor) Close() error {
+	if !m.Available() {
+		return nil
+	}
 	ret := nvml.Shutdown()
 	if ret != nvml.SUCCESS {
 		return fmt.Errorf("failed to shutdown NVML: %v", nvml.ErrorString(ret))
@@ -46,6 +168,9 @@ func (m *Monitor) Close() error {
 
 // GetGPUCount 获取GPU数量
 func (m *Monitor) GetGPUCount() (int, error) {
+	if !m.Available() {
+		return 0, nil
+	}
 	count, ret := nvml.DeviceGetCount()
 	if ret != nvml.SUCCESS {
 		return 0, fmt.Errorf("failed to get device count: %v", nvml.ErrorString(ret))
@@ -54,13 +179,48 @@ func (m *Monitor) GetGPUCount() (int, error) {
 }
 
 // RefreshGPUInfo 刷新GPU信息
+// 已有刷新在进行时等待其完成并返回相同结果，不会重复访问NVML
 func (m *Monitor) RefreshGPUInfo() error {
+	m.refreshMu.Lock()
+	if call := m.refreshing; call != nil {
+		m.refreshMu.Unlock()
+		<-call.done
+		return call.err
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	m.refreshing = call
+	m.refreshMu.Unlock()
+
+	call.err = m.refreshGPUInfo()
+
+	m.refreshMu.Lock()
+	m.refreshing = nil
+	m.refreshMu.Unlock()
+	close(call.done)
+	return call.err
+}
+
+// refreshGPUInfo 从NVML读取GPU信息
+func (m *Monitor) refreshGPUInfo() error {
+	if m.InMaintenance() {
+		return nil
+	}
+
 	count, err := m.GetGPUCount()
 	if err != nil {
 		return err
 	}
+	if count, err = m.reenumerate(count); err != nil {
+		return err
+	}
 
 	gpus := make([]GPUInfo, count)
+	devices := make([]DeviceIdentity, count)
+	overThreshold := make([]bool, count)
+
+	m.mu.RLock()
+	policy, managed := m.policy, m.managed
+	m.mu.RUnlock()
 
 	for i := 0; i < count; i++ {
 		device, ret := nvml.DeviceGetHandleByIndex(i)
@@ -102,32 +262,131 @@ func (m *Monitor) RefreshGPUInfo() error {
 			usagePercent = float64(utilization.Gpu)
 		}
 
-		// 判断GPU是否忙碌（基于内存使用率和利用率）
-		busy := false
+		// 判断GPU是否有负载（基于内存使用率和利用率）
 		if totalMB > 0 {
 			memUsagePercent := float64(usedMB) / float64(totalMB) * 100
-			busy = memUsagePercent > 10.0 || usagePercent > 10.0
+			overThreshold[i] = memUsagePercent > policy.MemoryPercent || usagePercent > policy.UtilizationPercent
 		}
 
 		gpus[i] = GPUInfo{
-			ID:            i,
-			TemperatureC:  int(temp),
-			MemoryTotalMB: totalMB,
-			MemoryUsedMB:  usedMB,
-			Name:          name,
-			UUID:          uuid,
-			Busy:          busy,
-			UsagePercent:  usagePercent,
+			ID:               i,
+			TemperatureC:     int(temp),
+			MemoryTotalMB:    totalMB,
+			MemoryUsedMB:     usedMB,
+			Name:             name,
+			UUID:             uuid,
+			UsagePercent:     usagePercent,
+			FanSpeedsPercent: fanSpeeds(device),
+		}
+		if power, ret := device.GetPowerUsage(); ret == nvml.SUCCESS {
+			gpus[i].PowerDrawW = int(power / 1000)
+		}
+		if limit, ret := device.GetEnforcedPowerLimit(); ret == nvml.SUCCESS {
+			gpus[i].PowerLimitW = int(limit / 1000)
+		}
+
+		devices[i] = DeviceIdentity{Index: i, UUID: uuid}
+		if minor, ret := device.GetMinorNumber(); ret == nvml.SUCCESS {
+			devices[i].Minor = minor
+		}
+
+		// 在加锁前查询，避免与容器管理器的锁交叉
+		if managed != nil && managed(i) {
+			gpus[i].BusyBy = BusyByManagedContainer
 		}
 	}
 
+	now := time.Now()
 	m.mu.Lock()
+	// 首次刷新只记录基准；GPU增减后把按索引记录的状态迁移到新索引
+	change, changed := diffDevices(m.devices, devices)
+	changed = changed && !m.collectedAt.IsZero()
+	if changed {
+		m.usage = remapIndices(m.usage, m.devices, devices)
+		m.history = remapIndices(m.history, m.devices, devices)
+		m.pendingCleanup = remapIndices(m.pendingCleanup, m.devices, devices)
+		m.contention = remapIndices(m.contention, m.devices, devices)
+		m.throttled = remapIndices(m.throttled, m.devices, devices)
+	}
+	onDeviceChange := m.onDeviceChange
+	for i := range gpus {
+		sustained := m.sustainedBusy(i, overThreshold[i], policy.Window, now)
+		// 受管容器的占用仍按旧索引判定，新出现或索引变化的GPU在重新判定前不分配
+		if changed && gpus[i].BusyBy == "" && change.reindexed(i) {
+			gpus[i].BusyBy = BusyByManagedContainer
+		}
+		if gpus[i].BusyBy == "" && m.pendingCleanup[i] {
+			gpus[i].BusyBy = BusyByPendingCleanup
+		}
+		if gpus[i].BusyBy == "" && sustained {
+			gpus[i].BusyBy = BusyByUnknownProcess
+		}
+		gpus[i].Busy = gpus[i].BusyBy != ""
+		gpus[i].Intruders = m.contention[i]
+		gpus[i].Contended = len(gpus[i].Intruders) > 0
+		gpus[i].ThermalThrottled = m.throttled[i]
+
+		ring, exists := m.history[i]
+		if !exists {
+			ring = &usageRing{}
+			m.history[i] = ring
+		}
+		ring.record(now, gpus[i].UsagePercent, gpus[i].MemoryUsedMB)
+		gpus[i].History = ring.summary(now)
+	}
 	m.gpus = gpus
+	m.devices = devices
+	m.collectedAt = now
 	m.mu.Unlock()
 
+	if changed {
+		if onDeviceChange != nil {
+			onDeviceChange(change)
+		}
+		m.recheckManaged(managed)
+	}
 	return nil
 }
 
+// fanSpeeds 读取各风扇的转速，不支持风扇查询（如被动散热）时返回nil
+func fanSpeeds(device nvml.Device) []int {
+	count, ret := device.GetNumFans()
+	if ret != nvml.SUCCESS || count == 0 {
+		return nil
+	}
+	speeds := make([]int, 0, count)
+	for fan := 0; fan < count; fan++ {
+		speed, ret := device.GetFanSpeed_v2(fan)
+		if ret != nvml.SUCCESS {
+			return nil
+		}
+		speeds = append(speeds, int(speed))
+	}
+	return speeds
+}
+
+// sustainedBusy 按滑动窗口更新GPU的负载判定：相反的负载状态持续window后才切换（调用方需持有锁）
+func (m *Monitor) sustainedBusy(gpuID int, over bool, window time.Duration, now time.Time) bool {
+	state, exists := m.usage[gpuID]
+	if !exists {
+		state = &usageState{busy: over}
+		m.usage[gpuID] = state
+	}
+
+	if over == state.busy {
+		state.pendingSince = time.Time{}
+		return state.busy
+	}
+	if state.pendingSince.IsZero() {
+		state.pendingSince = now
+	}
+	if now.Sub(state.pendingSince) >= window {
+		state.busy = over
+		state.pendingSince = time.Time{}
+	}
+	return state.busy
+}
+
 // GetGPUInfo 获取所有GPU信息
 func (m *Monitor) GetGPUInfo() []GPUInfo {
 	m.mu.RLock()
@@ -139,6 +398,16 @@ func (m *Monitor) GetGPUInfo() []GPUInfo {
 	return result
 }
 
+// Snapshot 返回缓存的GPU信息及其采集时间，尚未成功刷新过时时间为零值
+func (m *Monitor) Snapshot() ([]GPUInfo, time.Time) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]GPUInfo, len(m.gpus))
+	copy(result, m.gpus)
+	return result, m.collectedAt
+}
+
 // GetGPUByID 根据ID获取GPU信息
 func (m *Monitor) GetGPUByID(id int) (GPUInfo, bool) {
 	m.mu.RLock()
@@ -174,6 +443,9 @@ func (m *Monitor) GetAvailableGPUs() []int {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	if m.maintenance {
+		return nil
+	}
 	var available []int
 	for _, gpu := range m.gpus {
 		if !gpu.Busy {
@@ -0,0 +1,178 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MPSManager 按GPU管理NVIDIA MPS控制守护进程
+// 每个GPU的守护进程使用独立的管道与日志目录：<baseDir>/<gpu_id>/{pipe,log}
+type MPSManager struct {
+	mu      sync.Mutex
+	baseDir string
+	running map[int]bool
+}
+
+// NewMPSManager 创建MPS管理器，并接管上次运行遗留的守护进程
+func NewMPSManager(baseDir string) (*MPSManager, error) {
+	if _, err := exec.LookPath("nvidia-cuda-mps-control"); err != nil {
+		return nil, fmt.Errorf("nvidia-cuda-mps-control not found: %w", err)
+	}
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create MPS directory: %w", err)
+	}
+
+	m := &MPSManager{baseDir: baseDir, running: make(map[int]bool)}
+
+	// Agent重启后守护进程仍在运行，以控制管道是否存在判断
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MPS directory: %w", err)
+	}
+	for _, entry := range entries {
+		gpuID, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(m.PipeDir(gpuID), "control")); err == nil {
+			m.running[gpuID] = true
+		}
+	}
+
+	return m, nil
+}
+
+// PipeDir 返回GPU的MPS管道目录，容器通过挂载该目录连接守护进程
+func (m *MPSManager) PipeDir(gpuID int) string {
+	return filepath.Join(m.baseDir, strconv.Itoa(gpuID), "pipe")
+}
+
+// logDir 返回GPU的MPS日志目录
+func (m *MPSManager) logDir(gpuID int) string {
+	return filepath.Join(m.baseDir, strconv.Itoa(gpuID), "log")
+}
+
+// IsRunning GPU的MPS守护进程是否在运行
+func (m *MPSManager) IsRunning(gpuID int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.running[gpuID]
+}
+
+// Running 返回正在运行MPS守护进程的GPU
+func (m *MPSManager) Running() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	gpuIDs := make([]int, 0, len(m.running))
+	for gpuID := range m.running {
+		gpuIDs = append(gpuIDs, gpuID)
+	}
+	sort.Ints(gpuIDs)
+	return gpuIDs
+}
+
+// Start 将GPU切换为独占进程模式并启动MPS守护进程，已运行时直接返回
+func (m *MPSManager) Start(ctx context.Context, gpuID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.running[gpuID] {
+		return nil
+	}
+
+	for _, dir := range []string{m.PipeDir(gpuID), m.logDir(gpuID)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create MPS directory: %w", err)
+		}
+	}
+
+	// 独占进程模式保证GPU上只有MPS服务进程，客户端都经由MPS共享
+	if err := setComputeMode(ctx, gpuID, "EXCLUSIVE_PROCESS"); err != nil {
+		return err
+	}
+
+	cmd := m.controlCommand(ctx, gpuID, "-d")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		setComputeMode(ctx, gpuID, "DEFAULT")
+		return fmt.Errorf("failed to start MPS daemon on GPU %d: %w: %s", gpuID, err, strings.TrimSpace(string(output)))
+	}
+
+	// 等待控制管道就绪
+	control := filepath.Join(m.PipeDir(gpuID), "control")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(control); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("MPS daemon on GPU %d did not create its control pipe", gpuID)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	m.running[gpuID] = true
+	return nil
+}
+
+// Stop 停止GPU的MPS守护进程，恢复默认计算模式并清理管道目录
+func (m *MPSManager) Stop(ctx context.Context, gpuID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.running[gpuID] {
+		return nil
+	}
+
+	cmd := m.controlCommand(ctx, gpuID)
+	cmd.Stdin = strings.NewReader("quit\n")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to stop MPS daemon on GPU %d: %w: %s", gpuID, err, strings.TrimSpace(string(output)))
+	}
+	delete(m.running, gpuID)
+
+	if err := setComputeMode(ctx, gpuID, "DEFAULT"); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if err := os.RemoveAll(m.PipeDir(gpuID)); err != nil {
+		fmt.Printf("Warning: failed to remove MPS pipe directory for GPU %d: %v\n", gpuID, err)
+	}
+	return nil
+}
+
+// StopAll 停止所有MPS守护进程
+func (m *MPSManager) StopAll(ctx context.Context) {
+	for _, gpuID := range m.Running() {
+		if err := m.Stop(ctx, gpuID); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+}
+
+// controlCommand 创建指向GPU专属守护进程的 nvidia-cuda-mps-control 命令
+func (m *MPSManager) controlCommand(ctx context.Context, gpuID int, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "nvidia-cuda-mps-control", args...)
+	cmd.Env = append(os.Environ(),
+		"CUDA_VISIBLE_DEVICES="+strconv.Itoa(gpuID),
+		"CUDA_MPS_PIPE_DIRECTORY="+m.PipeDir(gpuID),
+		"CUDA_MPS_LOG_DIRECTORY="+m.logDir(gpuID),
+	)
+	return cmd
+}
+
+// setComputeMode 设置GPU计算模式
+func setComputeMode(ctx context.Context, gpuID int, mode string) error {
+	output, err := exec.CommandContext(ctx, "nvidia-smi", "-i", strconv.Itoa(gpuID), "-c", mode).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to set compute mode %s on GPU %d: %w: %s", mode, gpuID, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
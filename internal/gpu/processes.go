@@ -0,0 +1,83 @@
+package gpu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ErrProcessNotFound 指定的进程不是该GPU上的计算进程
+var ErrProcessNotFound = errors.New("process is not running on the GPU")
+
+// ProcessInfo GPU上的计算进程
+type ProcessInfo struct {
+	PID  uint32 `json:"pid"`
+	Name string `json:"name"`
+	// 进程占用的显存（MB），驱动无法统计时为0
+	UsedMemoryMB int `json:"used_memory_mb"`
+}
+
+// Processes 通过NVML列出GPU上的计算进程
+func (m *Monitor) Processes(gpuID int) ([]ProcessInfo, error) {
+	processes, err := m.deviceProcesses(gpuID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ProcessInfo, 0, len(processes))
+	for _, p := range processes {
+		result = append(result, ProcessInfo{
+			PID:          p.Pid,
+			Name:         processName(p.Pid),
+			UsedMemoryMB: int(p.UsedGpuMemory / 1024 / 1024),
+		})
+	}
+	return result, nil
+}
+
+// KillProcess 结束GPU上的计算进程，进程不在该GPU上时返回 ErrProcessNotFound
+func (m *Monitor) KillProcess(gpuID int, pid uint32) error {
+	processes, err := m.deviceProcesses(gpuID)
+	if err != nil {
+		return err
+	}
+	for _, p := range processes {
+		if p.Pid != pid {
+			continue
+		}
+		if err := syscall.Kill(int(pid), syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("failed to kill process %d: %w", pid, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: pid %d on GPU %d", ErrProcessNotFound, pid, gpuID)
+}
+
+// deviceProcesses 查询GPU当前的计算进程
+func (m *Monitor) deviceProcesses(gpuID int) ([]nvml.ProcessInfo, error) {
+	if m.InMaintenance() {
+		return nil, fmt.Errorf("GPU maintenance is in progress")
+	}
+	device, ret := nvml.DeviceGetHandleByIndex(gpuID)
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to get device handle for GPU %d: %v", gpuID, nvml.ErrorString(ret))
+	}
+	processes, ret := device.GetComputeRunningProcesses()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to get compute processes of GPU %d: %v", gpuID, nvml.ErrorString(ret))
+	}
+	return processes, nil
+}
+
+// processName 读取进程名称，进程已退出时为空
+func processName(pid uint32) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
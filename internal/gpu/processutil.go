@@ -0,0 +1,57 @@
+package gpu
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ErrProcessUtilizationUnsupported GPU或驱动不支持按进程统计利用率
+var ErrProcessUtilizationUnsupported = errors.New("per-process GPU utilization is not supported")
+
+// ProcessUtilization 进程在统计区间内的平均SM利用率（百分比）
+type ProcessUtilization struct {
+	PID                uint32
+	UtilizationPercent float64
+}
+
+// ProcessUtilization 通过NVML统计since之后各进程在GPU上的平均SM利用率
+// 驱动只保留最近若干秒的采样，since早于此时只统计仍保留的部分；区间内没有计算进程时返回空列表
+func (m *Monitor) ProcessUtilization(gpuID int, since time.Time) ([]ProcessUtilization, error) {
+	if m.InMaintenance() {
+		return nil, fmt.Errorf("GPU maintenance is in progress")
+	}
+	device, ret := nvml.DeviceGetHandleByIndex(gpuID)
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to get device handle for GPU %d: %v", gpuID, nvml.ErrorString(ret))
+	}
+	samples, ret := device.GetProcessUtilization(uint64(since.UnixMicro()))
+	switch ret {
+	case nvml.SUCCESS:
+	case nvml.ERROR_NOT_FOUND:
+		return []ProcessUtilization{}, nil
+	case nvml.ERROR_NOT_SUPPORTED:
+		return nil, ErrProcessUtilizationUnsupported
+	default:
+		return nil, fmt.Errorf("failed to get process utilization of GPU %d: %v", gpuID, nvml.ErrorString(ret))
+	}
+
+	// 同一进程在区间内有多个采样，取平均
+	sums := make(map[uint32]float64)
+	counts := make(map[uint32]int)
+	var order []uint32
+	for _, s := range samples {
+		if counts[s.Pid] == 0 {
+			order = append(order, s.Pid)
+		}
+		sums[s.Pid] += float64(s.SmUtil)
+		counts[s.Pid]++
+	}
+	result := make([]ProcessUtilization, 0, len(order))
+	for _, pid := range order {
+		result = append(result, ProcessUtilization{PID: pid, UtilizationPercent: sums[pid] / float64(counts[pid])})
+	}
+	return result, nil
+}
@@ -0,0 +1,139 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// driverVersionFile 内核模块加载后存在，内容包含驱动版本
+const driverVersionFile = "/proc/driver/nvidia/version"
+
+// driverPollInterval 等待驱动重新加载时的检查间隔
+const driverPollInterval = 2 * time.Second
+
+// DeviceIdentity GPU的索引、UUID与设备次设备号（/dev/nvidia<minor>）
+type DeviceIdentity struct {
+	Index int    `json:"index"`
+	UUID  string `json:"uuid"`
+	Minor int    `json:"minor"`
+}
+
+// Devices 通过NVML查询当前所有GPU的身份
+func (m *Monitor) Devices() ([]DeviceIdentity, error) {
+	count, err := m.GetGPUCount()
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]DeviceIdentity, 0, count)
+	for i := 0; i < count; i++ {
+		device, ret := nvml.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			return nil, fmt.Errorf("failed to get device handle for GPU %d: %v", i, nvml.ErrorString(ret))
+		}
+		uuid, ret := device.GetUUID()
+		if ret != nvml.SUCCESS {
+			return nil, fmt.Errorf("failed to get UUID of GPU %d: %v", i, nvml.ErrorString(ret))
+		}
+		minor, ret := device.GetMinorNumber()
+		if ret != nvml.SUCCESS {
+			return nil, fmt.Errorf("failed to get minor number of GPU %d: %v", i, nvml.ErrorString(ret))
+		}
+		devices = append(devices, DeviceIdentity{Index: i, UUID: uuid, Minor: minor})
+	}
+	return devices, nil
+}
+
+// DriverVersion 读取已加载的内核驱动版本，驱动未加载时返回空字符串
+func DriverVersion() string {
+	data, err := os.ReadFile(driverVersionFile)
+	if err != nil {
+		return ""
+	}
+	// 首行形如 "NVRM version: NVIDIA UNIX x86_64 Kernel Module  535.104.05  ..."
+	line, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimSpace(line)
+}
+
+// BeginMaintenance 进入驱动维护：停止刷新与分配GPU，并关闭NVML以免占用驱动
+func (m *Monitor) BeginMaintenance() error {
+	if !m.Available() {
+		return fmt.Errorf("GPU support is unavailable: %s", m.unavailableReason)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.maintenance {
+		return fmt.Errorf("GPU maintenance is already in progress")
+	}
+	m.maintenance = true
+	if ret := nvml.Shutdown(); ret != nvml.SUCCESS {
+		fmt.Printf("Warning: failed to shutdown NVML for maintenance: %v\n", nvml.ErrorString(ret))
+	}
+	return nil
+}
+
+// WaitForDriver 等待驱动重新加载并可通过NVML访问至少expectedCount个GPU
+// expectReload为true时先等待驱动被卸载或版本变化，避免在重新加载前就继续
+func (m *Monitor) WaitForDriver(ctx context.Context, previousVersion string, expectReload bool, expectedCount int) error {
+	ticker := time.NewTicker(driverPollInterval)
+	defer ticker.Stop()
+
+	reloaded := !expectReload
+	for {
+		version := DriverVersion()
+		if !reloaded && (version == "" || version != previousVersion) {
+			reloaded = true
+		}
+
+		// 驱动被卸载前不初始化NVML，否则会阻止模块卸载
+		if reloaded && version != "" {
+			if ret := nvml.Init(); ret == nvml.SUCCESS {
+				count, ret := nvml.DeviceGetCount()
+				if ret == nvml.SUCCESS && count >= expectedCount {
+					return nil
+				}
+				nvml.Shutdown()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if !reloaded {
+				return fmt.Errorf("driver was not reloaded: %w", ctx.Err())
+			}
+			return fmt.Errorf("NVML did not come back with %d GPUs: %w", expectedCount, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// EndMaintenance 结束驱动维护并恢复刷新与分配
+// WaitForDriver 未成功时尽力重新初始化NVML
+func (m *Monitor) EndMaintenance(initialized bool) {
+	if !initialized {
+		if ret := nvml.Init(); ret != nvml.SUCCESS {
+			fmt.Printf("Warning: failed to reinitialize NVML after maintenance: %v\n", nvml.ErrorString(ret))
+		}
+	}
+
+	m.mu.Lock()
+	m.maintenance = false
+	m.mu.Unlock()
+
+	if err := m.RefreshGPUInfo(); err != nil {
+		fmt.Printf("Warning: failed to refresh GPU info after maintenance: %v\n", err)
+	}
+}
+
+// InMaintenance 是否正在进行驱动维护
+func (m *Monitor) InMaintenance() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.maintenance
+}
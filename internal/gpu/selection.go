@@ -0,0 +1,162 @@
+package gpu
+
+import "sort"
+
+// 温度得分区间：不高于thermalIdleC得满分，达到thermalLimitC得0分
+const (
+	thermalIdleC  = 40
+	thermalLimitC = 85
+)
+
+// 选择策略
+const (
+	// SelectionScored 按互联拓扑、温度余量与历史利用率评分选择
+	SelectionScored = "scored"
+	// SelectionAll 候选GPU数量不超过请求数量，全部选中
+	SelectionAll = "all_candidates"
+)
+
+// CandidateScore 候选GPU的评分
+type CandidateScore struct {
+	GPUID        int `json:"gpu_id"`
+	TemperatureC int `json:"temperature_c"`
+	// 最近1小时的平均利用率，无采样时为最近5分钟
+	AvgUtilizationPercent float64 `json:"avg_utilization_percent"`
+	// 温度余量得分与历史利用率得分（0~100），越高越适合分配
+	ThermalScore     float64 `json:"thermal_score"`
+	UtilizationScore float64 `json:"utilization_score"`
+	Score            float64 `json:"score"`
+	Selected         bool    `json:"selected"`
+}
+
+// Selection GPU选择结果及依据，便于排查分配问题
+type Selection struct {
+	GPUIDs   []int  `json:"gpu_ids"`
+	Strategy string `json:"strategy"`
+	// 所选GPU两两之间的互联得分之和（NVLink远高于PCIe，见linkScore）
+	TopologyScore int `json:"topology_score"`
+	// 拓扑查询失败时的错误，此时只按单卡得分选择
+	TopologyError string           `json:"topology_error,omitempty"`
+	Candidates    []CandidateScore `json:"candidates"`
+}
+
+// SelectGPUs 从候选GPU中选出count块GPU并返回选择依据
+// 单卡得分为温度余量与历史利用率得分的平均值；多卡时再加上所选GPU之间的互联得分，
+// NVLink互联的组合总是优先，其次是单卡得分更高、PCIe路径更近的组合
+func (m *Monitor) SelectGPUs(candidates []int, count int) Selection {
+	selection := Selection{Strategy: SelectionScored, GPUIDs: []int{}}
+	scores := make(map[int]float64, len(candidates))
+	for _, id := range candidates {
+		candidate := m.scoreCandidate(id)
+		scores[id] = candidate.Score
+		selection.Candidates = append(selection.Candidates, candidate)
+	}
+
+	// 多卡选择需要拓扑，查询失败时只按单卡得分选择
+	var pairs map[[2]int]int
+	if count > 1 && len(candidates) > 1 {
+		if _, links, err := m.gpuTopology(); err != nil {
+			selection.TopologyError = err.Error()
+		} else {
+			pairs = linkScores(links)
+		}
+	}
+
+	switch {
+	case count <= 0:
+	case len(candidates) <= count:
+		selection.Strategy = SelectionAll
+		selection.GPUIDs = append(selection.GPUIDs, candidates...)
+	case count == 1:
+		best := candidates[0]
+		for _, id := range candidates[1:] {
+			if scores[id] > scores[best] {
+				best = id
+			}
+		}
+		selection.GPUIDs = []int{best}
+	default:
+		selection.GPUIDs = selectGroup(candidates, count, scores, pairs)
+	}
+	sort.Ints(selection.GPUIDs)
+
+	for i, a := range selection.GPUIDs {
+		for _, b := range selection.GPUIDs[i+1:] {
+			selection.TopologyScore += pairs[[2]int{a, b}]
+		}
+	}
+	for i := range selection.Candidates {
+		selection.Candidates[i].Selected = containsInt(selection.GPUIDs, selection.Candidates[i].GPUID)
+	}
+	return selection
+}
+
+// scoreCandidate 按当前温度与历史利用率为单块GPU评分
+func (m *Monitor) scoreCandidate(id int) CandidateScore {
+	candidate := CandidateScore{GPUID: id}
+	info, exists := m.GetGPUByID(id)
+	if !exists {
+		return candidate
+	}
+	candidate.TemperatureC = info.TemperatureC
+	candidate.AvgUtilizationPercent = info.UsagePercent
+	if info.History != nil {
+		if info.History.Last1h.SampledMinutes > 0 {
+			candidate.AvgUtilizationPercent = info.History.Last1h.AvgUtilizationPercent
+		} else if info.History.Last5m.SampledMinutes > 0 {
+			candidate.AvgUtilizationPercent = info.History.Last5m.AvgUtilizationPercent
+		}
+	}
+
+	candidate.ThermalScore = clampScore(100 * float64(thermalLimitC-info.TemperatureC) / (thermalLimitC - thermalIdleC))
+	candidate.UtilizationScore = clampScore(100 - candidate.AvgUtilizationPercent)
+	candidate.Score = (candidate.ThermalScore + candidate.UtilizationScore) / 2
+	return candidate
+}
+
+// selectGroup 从每块候选GPU出发，贪心加入与已选GPU互联得分之和加单卡得分最高的GPU，取总分最高的组合
+func selectGroup(candidates []int, count int, scores map[int]float64, pairs map[[2]int]int) []int {
+	var best []int
+	bestTotal := -1.0
+	for _, start := range candidates {
+		chosen := []int{start}
+		total := scores[start]
+		for len(chosen) < count {
+			next, nextScore := -1, -1.0
+			for _, c := range candidates {
+				if containsInt(chosen, c) {
+					continue
+				}
+				s := scores[c]
+				for _, g := range chosen {
+					s += float64(pairs[[2]int{g, c}])
+				}
+				if s > nextScore {
+					next, nextScore = c, s
+				}
+			}
+			chosen = append(chosen, next)
+			total += nextScore
+		}
+		if total > bestTotal {
+			best, bestTotal = chosen, total
+		}
+	}
+	return best
+}
+
+// linkScores 返回GPU对（双向）的互联得分
+func linkScores(links []GPULink) map[[2]int]int {
+	pairs := make(map[[2]int]int, 2*len(links))
+	for _, l := range links {
+		s := linkScore(l)
+		pairs[[2]int{l.GPUs[0], l.GPUs[1]}] = s
+		pairs[[2]int{l.GPUs[1], l.GPUs[0]}] = s
+	}
+	return pairs
+}
+
+// clampScore 将得分限制在0~100
+func clampScore(score float64) float64 {
+	return min(max(score, 0), 100)
+}
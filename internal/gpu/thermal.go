@@ -0,0 +1,114 @@
+package gpu
+
+import (
+	"fmt"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// PowerLimits GPU的功率上限（瓦）：当前设置、出厂默认值及驱动允许的范围
+type PowerLimits struct {
+	CurrentW int `json:"current_w"`
+	DefaultW int `json:"default_w"`
+	MinW     int `json:"min_w"`
+	MaxW     int `json:"max_w"`
+}
+
+// PowerLimits 查询GPU的功率上限
+func (m *Monitor) PowerLimits(gpuID int) (PowerLimits, error) {
+	device, err := m.device(gpuID)
+	if err != nil {
+		return PowerLimits{}, err
+	}
+	current, ret := device.GetPowerManagementLimit()
+	if ret != nvml.SUCCESS {
+		return PowerLimits{}, fmt.Errorf("failed to get power limit of GPU %d: %v", gpuID, nvml.ErrorString(ret))
+	}
+	def, ret := device.GetPowerManagementDefaultLimit()
+	if ret != nvml.SUCCESS {
+		return PowerLimits{}, fmt.Errorf("failed to get default power limit of GPU %d: %v", gpuID, nvml.ErrorString(ret))
+	}
+	min, max, ret := device.GetPowerManagementLimitConstraints()
+	if ret != nvml.SUCCESS {
+		return PowerLimits{}, fmt.Errorf("failed to get power limit constraints of GPU %d: %v", gpuID, nvml.ErrorString(ret))
+	}
+	return PowerLimits{
+		CurrentW: int(current / 1000),
+		DefaultW: int(def / 1000),
+		MinW:     int(min / 1000),
+		MaxW:     int(max / 1000),
+	}, nil
+}
+
+// SetPowerLimit 设置GPU的功率上限（瓦），需要root权限；驱动重新加载后恢复默认值
+func (m *Monitor) SetPowerLimit(gpuID int, watts int) error {
+	device, err := m.device(gpuID)
+	if err != nil {
+		return err
+	}
+	if ret := device.SetPowerManagementLimit(uint32(watts) * 1000); ret != nvml.SUCCESS {
+		return fmt.Errorf("failed to set power limit of GPU %d to %dW: %v", gpuID, watts, nvml.ErrorString(ret))
+	}
+	return nil
+}
+
+// SetFanSpeed 将GPU的全部风扇设为固定转速（百分比），需要root权限
+func (m *Monitor) SetFanSpeed(gpuID int, percent int) error {
+	device, err := m.device(gpuID)
+	if err != nil {
+		return err
+	}
+	count, ret := device.GetNumFans()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("failed to get fan count of GPU %d: %v", gpuID, nvml.ErrorString(ret))
+	}
+	for fan := 0; fan < count; fan++ {
+		if ret := device.SetFanSpeed_v2(fan, percent); ret != nvml.SUCCESS {
+			return fmt.Errorf("failed to set speed of fan %d on GPU %d: %v", fan, gpuID, nvml.ErrorString(ret))
+		}
+	}
+	return nil
+}
+
+// ResetFanSpeed 恢复GPU全部风扇的自动转速控制
+func (m *Monitor) ResetFanSpeed(gpuID int) error {
+	device, err := m.device(gpuID)
+	if err != nil {
+		return err
+	}
+	count, ret := device.GetNumFans()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("failed to get fan count of GPU %d: %v", gpuID, nvml.ErrorString(ret))
+	}
+	for fan := 0; fan < count; fan++ {
+		if ret := device.SetDefaultFanSpeed_v2(fan); ret != nvml.SUCCESS {
+			return fmt.Errorf("failed to reset speed of fan %d on GPU %d: %v", fan, gpuID, nvml.ErrorString(ret))
+		}
+	}
+	return nil
+}
+
+// SetThermalThrottled 设置被温控策略降低功率上限的GPU
+func (m *Monitor) SetThermalThrottled(throttled map[int]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.throttled = throttled
+	for i := range m.gpus {
+		m.gpus[i].ThermalThrottled = throttled[i]
+	}
+}
+
+// device 获取GPU的NVML句柄，驱动维护期间返回错误
+func (m *Monitor) device(gpuID int) (nvml.Device, error) {
+	if !m.Available() {
+		return nil, fmt.Errorf("GPU support is unavailable: %s", m.UnavailableReason())
+	}
+	if m.InMaintenance() {
+		return nil, fmt.Errorf("GPU maintenance is in progress")
+	}
+	device, ret := nvml.DeviceGetHandleByIndex(gpuID)
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to get device handle for GPU %d: %v", gpuID, nvml.ErrorString(ret))
+	}
+	return device, nil
+}
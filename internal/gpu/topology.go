@@ -0,0 +1,325 @@
+package gpu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// sysfs路径
+const (
+	sysPCIDevices = "/sys/bus/pci/devices"
+	sysNUMANodes  = "/sys/devices/system/node"
+	sysNet        = "/sys/class/net"
+)
+
+// GPU之间的连接方式
+const (
+	LinkNVLink = "nvlink"
+	LinkPCIe   = "pcie"
+)
+
+// Topology 节点的GPU、互联、NUMA节点与网卡拓扑
+type Topology struct {
+	GPUs      []TopologyGPU `json:"gpus"`
+	Links     []GPULink     `json:"links"`
+	NUMANodes []NUMANode    `json:"numa_nodes"`
+	NICs      []NIC         `json:"nics"`
+}
+
+// TopologyGPU GPU的PCI位置与亲和性
+type TopologyGPU struct {
+	ID       int    `json:"id"`
+	UUID     string `json:"uuid"`
+	PCIBusID string `json:"pci_bus_id"`
+	// 所属NUMA节点，-1表示未知
+	NUMANode int `json:"numa_node"`
+	// 与GPU亲和的CPU列表，如 "0-15,32-47"
+	CPUs string `json:"cpus"`
+	// 处于活动状态的NVLink数，以及其中连接到NVSwitch的链路数
+	NVLinks       int `json:"nvlinks"`
+	NVSwitchLinks int `json:"nvswitch_links,omitempty"`
+}
+
+// GPULink 两块GPU之间的连接
+type GPULink struct {
+	GPUs [2]int `json:"gpus"`
+	// nvlink（可通过NVLink点对点访问）或 pcie
+	Type string `json:"type"`
+	// 两块GPU之间直连的NVLink数，经NVSwitch互联时为0
+	NVLinks int `json:"nvlinks,omitempty"`
+	// PCIe路径上的最近公共节点：internal、single、multiple、hostbridge、node、system
+	Level string `json:"level"`
+}
+
+// NUMANode NUMA节点上的CPU、GPU与网卡
+type NUMANode struct {
+	ID   int      `json:"id"`
+	CPUs string   `json:"cpus"`
+	GPUs []int    `json:"gpus"`
+	NICs []string `json:"nics"`
+}
+
+// NIC 物理网卡
+type NIC struct {
+	Name     string `json:"name"`
+	PCIBusID string `json:"pci_bus_id,omitempty"`
+	NUMANode int    `json:"numa_node"`
+	// 链路速率（Mb/s），未连接或未知时为0
+	SpeedMbps int `json:"speed_mbps,omitempty"`
+}
+
+// topologyLevels NVML拓扑层级名称
+var topologyLevels = map[nvml.GpuTopologyLevel]string{
+	nvml.TOPOLOGY_INTERNAL:   "internal",
+	nvml.TOPOLOGY_SINGLE:     "single",
+	nvml.TOPOLOGY_MULTIPLE:   "multiple",
+	nvml.TOPOLOGY_HOSTBRIDGE: "hostbridge",
+	nvml.TOPOLOGY_NODE:       "node",
+	nvml.TOPOLOGY_SYSTEM:     "system",
+}
+
+// Topology 通过NVML拓扑接口与sysfs查询节点拓扑
+// GPU不可用（降级模式或驱动维护中）时只返回NUMA节点与网卡
+func (m *Monitor) Topology() (*Topology, error) {
+	topology := &Topology{
+		GPUs:  []TopologyGPU{},
+		Links: []GPULink{},
+		NICs:  readNICs(),
+	}
+
+	if m.Available() && !m.InMaintenance() {
+		gpus, links, err := m.gpuTopology()
+		if err != nil {
+			return nil, err
+		}
+		topology.GPUs, topology.Links = gpus, links
+	}
+
+	topology.NUMANodes = readNUMANodes(topology.GPUs, topology.NICs)
+	return topology, nil
+}
+
+// gpuTopology 查询各GPU的PCI位置、NVLink与两两之间的连接
+func (m *Monitor) gpuTopology() ([]TopologyGPU, []GPULink, error) {
+	count, err := m.GetGPUCount()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	devices := make([]nvml.Device, count)
+	gpus := make([]TopologyGPU, count)
+	busIndex := make(map[string]int, count)
+	for i := 0; i < count; i++ {
+		device, ret := nvml.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			return nil, nil, fmt.Errorf("failed to get device handle for GPU %d: %v", i, nvml.ErrorString(ret))
+		}
+		devices[i] = device
+
+		uuid, _ := device.GetUUID()
+		pci, ret := device.GetPciInfo()
+		if ret != nvml.SUCCESS {
+			return nil, nil, fmt.Errorf("failed to get PCI info of GPU %d: %v", i, nvml.ErrorString(ret))
+		}
+		busID := sysfsBusID(pci)
+		busIndex[busID] = i
+		gpus[i] = TopologyGPU{
+			ID:       i,
+			UUID:     uuid,
+			PCIBusID: busID,
+			NUMANode: readNUMANode(filepath.Join(sysPCIDevices, busID)),
+			CPUs:     readTrimmed(filepath.Join(sysPCIDevices, busID, "local_cpulist")),
+		}
+	}
+
+	// 统计每块GPU的活动NVLink及其对端
+	direct := make(map[[2]int]int)
+	for i, device := range devices {
+		for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
+			state, ret := device.GetNvLinkState(link)
+			if ret == nvml.ERROR_NOT_SUPPORTED {
+				// GPU不支持NVLink
+				break
+			}
+			if ret != nvml.SUCCESS || state != nvml.FEATURE_ENABLED {
+				continue
+			}
+			gpus[i].NVLinks++
+
+			remote, ret := device.GetNvLinkRemotePciInfo(link)
+			if ret != nvml.SUCCESS {
+				continue
+			}
+			if j, ok := busIndex[sysfsBusID(remote)]; ok {
+				if i < j {
+					direct[[2]int{i, j}]++
+				}
+			} else {
+				gpus[i].NVSwitchLinks++
+			}
+		}
+	}
+
+	var links []GPULink
+	for i := 0; i < count; i++ {
+		for j := i + 1; j < count; j++ {
+			link := GPULink{GPUs: [2]int{i, j}, Type: LinkPCIe, NVLinks: direct[[2]int{i, j}]}
+			if level, ret := devices[i].GetTopologyCommonAncestor(devices[j]); ret == nvml.SUCCESS {
+				link.Level = topologyLevels[level]
+			}
+			if status, ret := devices[i].GetP2PStatus(devices[j], nvml.P2P_CAPS_INDEX_NVLINK); ret == nvml.SUCCESS && status == nvml.P2P_STATUS_OK {
+				link.Type = LinkNVLink
+			} else if link.NVLinks > 0 {
+				link.Type = LinkNVLink
+			}
+			links = append(links, link)
+		}
+	}
+	if links == nil {
+		links = []GPULink{}
+	}
+	return gpus, links, nil
+}
+
+// linkScore 连接得分：NVLink远高于PCIe，PCIe按公共节点由近到远递减
+func linkScore(l GPULink) int {
+	s := 0
+	if l.Type == LinkNVLink {
+		s += 1000 + 100*l.NVLinks
+	}
+	switch l.Level {
+	case "internal", "single":
+		s += 50
+	case "multiple":
+		s += 40
+	case "hostbridge":
+		s += 30
+	case "node":
+		s += 20
+	case "system":
+		s += 10
+	}
+	return s
+}
+
+// containsInt 判断列表是否包含指定值
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// sysfsBusID 将NVML的PCI总线ID（如 "00000000:3B:00.0"）转换为sysfs格式（"0000:3b:00.0"）
+func sysfsBusID(pci nvml.PciInfo) string {
+	buf := make([]byte, 0, len(pci.BusId))
+	for _, c := range pci.BusId {
+		if c == 0 {
+			break
+		}
+		buf = append(buf, byte(c))
+	}
+	id := strings.ToLower(string(buf))
+	if domain, rest, ok := strings.Cut(id, ":"); ok && len(domain) > 4 {
+		id = domain[len(domain)-4:] + ":" + rest
+	}
+	return id
+}
+
+// readNUMANodes 读取NUMA节点的CPU列表，并归集各节点上的GPU与网卡
+func readNUMANodes(gpus []TopologyGPU, nics []NIC) []NUMANode {
+	paths, _ := filepath.Glob(filepath.Join(sysNUMANodes, "node[0-9]*"))
+	nodes := make([]NUMANode, 0, len(paths))
+	for _, path := range paths {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(path), "node"))
+		if err != nil {
+			continue
+		}
+		node := NUMANode{
+			ID:   id,
+			CPUs: readTrimmed(filepath.Join(path, "cpulist")),
+			GPUs: []int{},
+			NICs: []string{},
+		}
+		for _, g := range gpus {
+			if g.NUMANode == id {
+				node.GPUs = append(node.GPUs, g.ID)
+			}
+		}
+		for _, n := range nics {
+			if n.NUMANode == id {
+				node.NICs = append(node.NICs, n.Name)
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// readNICs 读取物理网卡（有对应设备的网络接口）
+func readNICs() []NIC {
+	entries, err := os.ReadDir(sysNet)
+	if err != nil {
+		return []NIC{}
+	}
+	nics := []NIC{}
+	for _, entry := range entries {
+		devicePath := filepath.Join(sysNet, entry.Name(), "device")
+		target, err := filepath.EvalSymlinks(devicePath)
+		if err != nil {
+			// 虚拟接口（lo、网桥、veth等）没有device
+			continue
+		}
+		nic := NIC{
+			Name:     entry.Name(),
+			NUMANode: readNUMANode(devicePath),
+		}
+		nic.PCIBusID = pciAddressOf(target)
+		if speed, err := strconv.Atoi(readTrimmed(filepath.Join(sysNet, entry.Name(), "speed"))); err == nil && speed > 0 {
+			nic.SpeedMbps = speed
+		}
+		nics = append(nics, nic)
+	}
+	return nics
+}
+
+// pciAddressPattern sysfs中的PCI设备地址
+var pciAddressPattern = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]$`)
+
+// pciAddressOf 返回设备路径上最近的PCI设备地址（virtio等设备挂在PCI设备之下）
+func pciAddressOf(devicePath string) string {
+	for path := devicePath; path != "/" && path != "."; path = filepath.Dir(path) {
+		if base := filepath.Base(path); pciAddressPattern.MatchString(base) {
+			return base
+		}
+	}
+	return ""
+}
+
+// readNUMANode 读取设备所属的NUMA节点，未知时返回-1
+func readNUMANode(devicePath string) int {
+	node, err := strconv.Atoi(readTrimmed(filepath.Join(devicePath, "numa_node")))
+	if err != nil {
+		return -1
+	}
+	return node
+}
+
+// readTrimmed 读取sysfs文件内容，失败时返回空字符串
+func readTrimmed(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
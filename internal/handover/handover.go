@@ -0,0 +1,325 @@
+// Package handover 实现Agent的无中断重启：旧进程把监听套接字与frpc进程交给以相同参数启动的新进程，
+// 新进程就绪后旧进程再退出，期间API与隧道不中断
+package handover
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// 交接相关的环境变量
+const (
+	// stateEnv 指向交接状态文件，由旧进程为新进程设置
+	stateEnv = "UTOPIA_HANDOVER_STATE"
+	// SupervisorFDEnv PID 1模式的init进程接收新Agent PID的管道描述符
+	SupervisorFDEnv = "UTOPIA_SUPERVISOR_FD"
+)
+
+// stateFile 交接状态文件名（位于数据目录下，仅属主可读）
+const stateFile = "handover.json"
+
+// ErrNotReady 新进程在时限内未就绪
+var ErrNotReady = errors.New("new agent process did not become ready")
+
+// Listener 交接的监听套接字
+type Listener struct {
+	Network string `json:"network"`
+	Address string `json:"address"`
+	FD      int    `json:"fd"`
+}
+
+// FRP 交接的frpc进程
+type FRP struct {
+	PID           int    `json:"pid"`
+	ConfigPath    string `json:"config_path"`
+	Version       int64  `json:"version"`
+	AdminPassword string `json:"admin_password"`
+	ActiveServer  int    `json:"active_server"`
+	// frpc输出管道的读端，为0表示未交接
+	OutputFD int `json:"output_fd,omitempty"`
+}
+
+// State 旧进程交给新进程的状态
+type State struct {
+	// 旧进程的PID，用于日志
+	PreviousPID int        `json:"previous_pid"`
+	Listeners   []Listener `json:"listeners,omitempty"`
+	FRP         *FRP       `json:"frp,omitempty"`
+	// 新进程就绪后写入一个字节的管道
+	ReadyFD int `json:"ready_fd"`
+}
+
+// Builder 收集交给新进程的文件描述符
+type Builder struct {
+	State State
+	files []*os.File
+}
+
+// NewBuilder 创建交接状态
+func NewBuilder() *Builder {
+	return &Builder{State: State{PreviousPID: os.Getpid()}}
+}
+
+// Add 登记交给新进程的文件，返回其在新进程中的描述符编号
+// 文件在Spawn返回后关闭
+func (b *Builder) Add(file *os.File) int {
+	b.files = append(b.files, file)
+	// ExtraFiles 从描述符3开始
+	return 2 + len(b.files)
+}
+
+// AddListener 复制监听套接字的描述符并登记，新进程按配置的network与address取出
+func (b *Builder) AddListener(network, address string, listener net.Listener) error {
+	filer, ok := listener.(interface{ File() (*os.File, error) })
+	if !ok {
+		return fmt.Errorf("listener %s cannot be handed over", listener.Addr())
+	}
+	file, err := filer.File()
+	if err != nil {
+		return fmt.Errorf("failed to duplicate listener %s: %w", listener.Addr(), err)
+	}
+	b.State.Listeners = append(b.State.Listeners, Listener{
+		Network: network,
+		Address: address,
+		FD:      b.Add(file),
+	})
+	return nil
+}
+
+// Close 关闭已登记的文件（新进程持有各自的副本），未调用Spawn而放弃交接时由调用方关闭
+func (b *Builder) Close() {
+	for _, file := range b.files {
+		file.Close()
+	}
+	b.files = nil
+}
+
+// Spawn 以当前进程的可执行文件与参数启动新进程，等待其报告就绪
+// 新进程在timeout内退出或未就绪时结束新进程并返回错误，调用方应继续运行
+func (b *Builder) Spawn(ctx context.Context, dataDir string, timeout time.Duration) (pid int, err error) {
+	defer b.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("failed to locate agent executable: %w", err)
+	}
+
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create ready pipe: %w", err)
+	}
+	defer ready.Close()
+	b.State.ReadyFD = b.Add(readyWriter)
+
+	// exec.Cmd对重复的环境变量使用最后一个值
+	env := os.Environ()
+	if supervisor := supervisorFile(); supervisor != nil {
+		if fd, err := unix.FcntlInt(supervisor.Fd(), unix.F_DUPFD_CLOEXEC, 0); err == nil {
+			env = append(env, fmt.Sprintf("%s=%d", SupervisorFDEnv, b.Add(os.NewFile(uintptr(fd), "supervisor"))))
+		}
+	}
+
+	path := filepath.Join(dataDir, stateFile)
+	data, err := json.Marshal(&b.State)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return 0, fmt.Errorf("failed to write handover state: %w", err)
+	}
+	env = append(env, stateEnv+"="+path)
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = b.files
+	if err := cmd.Start(); err != nil {
+		os.Remove(path)
+		return 0, fmt.Errorf("failed to start new agent process: %w", err)
+	}
+	// 只保留新进程持有的写端，新进程退出时读端收到EOF
+	readyWriter.Close()
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	result := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		if _, err := ready.Read(buf); err != nil {
+			result <- fmt.Errorf("%w: %v", ErrNotReady, err)
+			return
+		}
+		result <- nil
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err = <-result:
+	case err = <-exited:
+		err = fmt.Errorf("%w: new process exited: %v", ErrNotReady, err)
+	case <-timer.C:
+		err = fmt.Errorf("%w within %s", ErrNotReady, timeout)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		os.Remove(path)
+		return 0, err
+	}
+	return cmd.Process.Pid, nil
+}
+
+// Inherited 新进程从旧进程接收的状态
+type Inherited struct {
+	mu        sync.Mutex
+	state     State
+	listeners map[string]*os.File
+	ready     *os.File
+}
+
+// Inherit 读取旧进程交接的状态，不是由交接启动时返回nil
+// 状态文件读取后立即删除，避免之后普通启动的进程误用
+func Inherit() (*Inherited, error) {
+	path := os.Getenv(stateEnv)
+	if path == "" {
+		return nil, nil
+	}
+	os.Unsetenv(stateEnv)
+
+	data, err := os.ReadFile(path)
+	os.Remove(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read handover state: %w", err)
+	}
+	inherited := &Inherited{listeners: make(map[string]*os.File)}
+	if err := json.Unmarshal(data, &inherited.state); err != nil {
+		return nil, fmt.Errorf("failed to parse handover state: %w", err)
+	}
+	// 继承的描述符不应再传给本进程启动的其他命令
+	for _, l := range inherited.state.Listeners {
+		syscall.CloseOnExec(l.FD)
+		inherited.listeners[l.Network+" "+l.Address] = os.NewFile(uintptr(l.FD), l.Address)
+	}
+	if inherited.state.FRP != nil && inherited.state.FRP.OutputFD > 0 {
+		syscall.CloseOnExec(inherited.state.FRP.OutputFD)
+	}
+	syscall.CloseOnExec(inherited.state.ReadyFD)
+	inherited.ready = os.NewFile(uintptr(inherited.state.ReadyFD), "handover-ready")
+	return inherited, nil
+}
+
+// PreviousPID 返回交出状态的旧进程PID
+func (h *Inherited) PreviousPID() int {
+	return h.state.PreviousPID
+}
+
+// Listener 取出交接的监听套接字，没有该地址时返回false；h为nil时总是返回false
+func (h *Inherited) Listener(network, address string) (net.Listener, bool, error) {
+	if h == nil {
+		return nil, false, nil
+	}
+	h.mu.Lock()
+	file, ok := h.listeners[network+" "+address]
+	delete(h.listeners, network+" "+address)
+	h.mu.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to adopt listener %s: %w", address, err)
+	}
+	return listener, true, nil
+}
+
+// FRP 取出交接的frpc进程及其输出管道，没有时返回nil
+func (h *Inherited) FRP() (*FRP, *os.File) {
+	if h == nil || h.state.FRP == nil {
+		return nil, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	frp := h.state.FRP
+	h.state.FRP = nil
+	var output *os.File
+	if frp.OutputFD > 0 {
+		output = os.NewFile(uintptr(frp.OutputFD), "frpc-output")
+	}
+	return frp, output
+}
+
+// Ready 通知旧进程新进程已就绪，关闭未使用的交接监听套接字
+func (h *Inherited) Ready() error {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key, file := range h.listeners {
+		file.Close()
+		delete(h.listeners, key)
+	}
+	if h.ready == nil {
+		return nil
+	}
+	_, err := h.ready.Write([]byte{1})
+	h.ready.Close()
+	h.ready = nil
+	return err
+}
+
+// NotifySupervisor 把新进程登记为服务的主进程：systemd（需 NotifyAccess=main 或 all）
+// 与PID 1模式的init进程据此继续监管新进程，而不是在旧进程退出时结束服务
+func NotifySupervisor(pid int) error {
+	var errs []error
+	if socket := os.Getenv("NOTIFY_SOCKET"); socket != "" {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+		if err == nil {
+			_, err = fmt.Fprintf(conn, "MAINPID=%d\n", pid)
+			conn.Close()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("systemd notification failed: %w", err))
+		}
+	}
+	if supervisor := supervisorFile(); supervisor != nil {
+		if _, err := fmt.Fprintf(supervisor, "%d\n", pid); err != nil {
+			errs = append(errs, fmt.Errorf("init notification failed: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var (
+	supervisorOnce sync.Once
+	supervisor     *os.File
+)
+
+// supervisorFile 返回PID 1模式的init进程传入的管道，不是由init进程启动时返回nil
+func supervisorFile() *os.File {
+	supervisorOnce.Do(func() {
+		fd, err := strconv.Atoi(os.Getenv(SupervisorFDEnv))
+		if err != nil || fd < 3 {
+			return
+		}
+		syscall.CloseOnExec(fd)
+		supervisor = os.NewFile(uintptr(fd), "supervisor")
+	})
+	return supervisor
+}
@@ -0,0 +1,171 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"utopia-node-agent/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 容器生命周期阶段
+const (
+	PreCreate  = "pre_create"
+	PostStart  = "post_start"
+	PreRemove  = "pre_remove"
+	PostRemove = "post_remove"
+)
+
+// ErrHookFailed 设置了FailOnError的钩子执行失败，容器操作被中止
+var ErrHookFailed = errors.New("lifecycle hook failed")
+
+// defaultTimeout 未配置超时时的钩子执行时限
+const defaultTimeout = 30 * time.Second
+
+// Hook 生命周期钩子：执行本地命令或调用webhook
+type Hook struct {
+	Stage string
+	// 本地命令及参数，上下文通过标准输入（JSON）与 UTOPIA_* 环境变量传入
+	Command []string
+	// webhook地址，上下文以JSON请求体POST
+	URL     string
+	Timeout time.Duration
+	// pre_* 阶段的钩子失败时中止容器操作
+	FailOnError bool
+}
+
+// Context 钩子收到的容器上下文
+type Context struct {
+	Stage       string            `json:"stage"`
+	NodeID      string            `json:"node_id"`
+	ClaimID     string            `json:"claim_id"`
+	ContainerID string            `json:"container_id,omitempty"`
+	Image       string            `json:"image,omitempty"`
+	GPUIDs      []int             `json:"gpu_ids"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// Validate 验证钩子配置
+func (h *Hook) Validate() error {
+	switch h.Stage {
+	case PreCreate, PostStart, PreRemove, PostRemove:
+	default:
+		return fmt.Errorf("stage must be one of %s, %s, %s, %s", PreCreate, PostStart, PreRemove, PostRemove)
+	}
+	if (len(h.Command) == 0) == (h.URL == "") {
+		return fmt.Errorf("exactly one of command and url is required")
+	}
+	if h.URL != "" && !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+		return fmt.Errorf("url must be http(s)")
+	}
+	return nil
+}
+
+// Runner 按阶段执行钩子
+type Runner struct {
+	hooks      []Hook
+	httpClient *http.Client
+}
+
+// NewRunner 创建钩子执行器
+func NewRunner(hooks []Hook) *Runner {
+	return &Runner{hooks: hooks, httpClient: &http.Client{}}
+}
+
+// Run 依次执行阶段的全部钩子
+// 设置了FailOnError的钩子失败时立即返回错误，其余失败通过onError报告后继续
+func (r *Runner) Run(ctx context.Context, hookCtx Context, onError func(Hook, error)) error {
+	for _, hook := range r.hooks {
+		if hook.Stage != hookCtx.Stage {
+			continue
+		}
+		if err := r.runHook(ctx, hook, hookCtx); err != nil {
+			if hook.FailOnError {
+				return fmt.Errorf("%w: %s: %v", ErrHookFailed, hook.Stage, err)
+			}
+			if onError != nil {
+				onError(hook, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runHook 执行单个钩子
+func (r *Runner) runHook(ctx context.Context, hook Hook, hookCtx Context) (err error) {
+	ctx, span := tracing.Start(ctx, "hooks.Run", attribute.String("utopia.hook_stage", hook.Stage))
+	defer func() { tracing.End(span, err) }()
+
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	payload, err := json.Marshal(hookCtx)
+	if err != nil {
+		return err
+	}
+
+	if hook.URL != "" {
+		return r.callWebhook(ctx, hook.URL, payload)
+	}
+	return runCommand(ctx, hook.Command, hookCtx, payload)
+}
+
+// runCommand 执行本地钩子命令
+func runCommand(ctx context.Context, command []string, hookCtx Context, payload []byte) error {
+	gpuIDs := make([]string, len(hookCtx.GPUIDs))
+	for i, id := range hookCtx.GPUIDs {
+		gpuIDs[i] = strconv.Itoa(id)
+	}
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(tracing.CommandEnv(ctx),
+		"UTOPIA_HOOK_STAGE="+hookCtx.Stage,
+		"UTOPIA_NODE_ID="+hookCtx.NodeID,
+		"UTOPIA_CLAIM_ID="+hookCtx.ClaimID,
+		"UTOPIA_CONTAINER_ID="+hookCtx.ContainerID,
+		"UTOPIA_IMAGE="+hookCtx.Image,
+		"UTOPIA_GPU_IDS="+strings.Join(gpuIDs, ","),
+	)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", command[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// callWebhook 调用webhook，非2xx状态码视为失败
+func (r *Runner) callWebhook(ctx context.Context, url string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	tracing.InjectHTTP(ctx, req)
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook %s returned %d: %s", url, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"utopia-node-agent/internal/store"
+)
+
+var (
+	// ErrInProgress 相同幂等键的请求正在处理
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+	// ErrMismatch 幂等键已用于不同的请求
+	ErrMismatch = errors.New("idempotency key was used for a different request")
+)
+
+// pruneInterval 清理过期记录的最小间隔
+const pruneInterval = 10 * time.Minute
+
+// Record 已完成请求的响应
+type Record struct {
+	// 请求指纹（方法、路径与请求体的摘要）
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// Cache 持久化的幂等键缓存，Agent重启后重试的请求仍返回原响应
+type Cache struct {
+	mu        sync.Mutex
+	records   *store.Store
+	ttl       time.Duration
+	inflight  map[string]string // 存储键 -> 请求指纹
+	lastPrune time.Time
+}
+
+// NewCache 打开位于dir的幂等键缓存，记录保留ttl
+func NewCache(dir string, ttl time.Duration) (*Cache, error) {
+	records, err := store.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{
+		records:  records,
+		ttl:      ttl,
+		inflight: make(map[string]string),
+	}, nil
+}
+
+// Begin 开始处理带幂等键的请求
+// 已有未过期的记录时返回该记录；请求仍在处理或键已用于其他请求时返回错误；
+// 否则将键标记为处理中，调用方随后必须调用 Complete 或 Abort
+func (c *Cache) Begin(key, fingerprint string) (*Record, error) {
+	storeKey := storeKeyFor(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if existing, exists := c.inflight[storeKey]; exists {
+		if existing != fingerprint {
+			return nil, ErrMismatch
+		}
+		return nil, ErrInProgress
+	}
+
+	var record Record
+	found, err := c.records.Get(storeKey, &record)
+	if err != nil {
+		return nil, err
+	}
+	if found && time.Since(time.Unix(record.CreatedAt, 0)) < c.ttl {
+		if record.Fingerprint != fingerprint {
+			return nil, ErrMismatch
+		}
+		return &record, nil
+	}
+
+	c.inflight[storeKey] = fingerprint
+	return nil, nil
+}
+
+// Complete 保存请求的响应并结束处理
+func (c *Cache) Complete(key string, record Record) error {
+	storeKey := storeKeyFor(key)
+	record.CreatedAt = time.Now().Unix()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.inflight, storeKey)
+	if err := c.records.Put(storeKey, record); err != nil {
+		return fmt.Errorf("failed to save idempotency record: %w", err)
+	}
+
+	if time.Since(c.lastPrune) > pruneInterval {
+		c.lastPrune = time.Now()
+		c.pruneLocked()
+	}
+	return nil
+}
+
+// Abort 放弃处理，不保存响应，之后的重试会重新执行请求
+func (c *Cache) Abort(key string) {
+	c.mu.Lock()
+	delete(c.inflight, storeKeyFor(key))
+	c.mu.Unlock()
+}
+
+// pruneLocked 删除过期记录（调用方需持有锁）
+func (c *Cache) pruneLocked() {
+	keys, err := c.records.Keys()
+	if err != nil {
+		fmt.Printf("Warning: failed to list idempotency records: %v\n", err)
+		return
+	}
+	for _, key := range keys {
+		var record Record
+		found, err := c.records.Get(key, &record)
+		if err == nil && found && time.Since(time.Unix(record.CreatedAt, 0)) < c.ttl {
+			continue
+		}
+		if err := c.records.Delete(key); err != nil {
+			fmt.Printf("Warning: failed to delete idempotency record: %v\n", err)
+		}
+	}
+}
+
+// storeKeyFor 将客户端提供的幂等键映射为安全的存储键
+func storeKeyFor(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Fingerprint 计算请求指纹
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
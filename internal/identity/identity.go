@@ -0,0 +1,312 @@
+package identity
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bundleVersion 身份文件格式版本
+const bundleVersion = 1
+
+// 请求签名头
+const (
+	HeaderNodeID    = "X-Utopia-Node-ID"
+	HeaderTimestamp = "X-Utopia-Timestamp"
+	HeaderSignature = "X-Utopia-Signature"
+)
+
+// signaturePrefix 签名内容的域分隔前缀，避免签名被用于其他用途
+const signaturePrefix = "utopia-node-request-v1"
+
+// machineKeyContext 由机器ID派生加密密钥时的上下文
+const machineKeyContext = "utopia-node-identity:"
+
+// ErrEncrypted 身份文件的私钥已加密，但未配置解密密钥
+var ErrEncrypted = errors.New("identity private key is encrypted but no key is configured")
+
+// Identity 节点身份：平台分配的节点ID与节点生成的Ed25519密钥对
+// 旧版身份文件只有节点ID，加载后没有密钥对
+type Identity struct {
+	NodeID string
+	// 平台注册时签发的身份证明（不透明字符串），平台未签发时为空
+	Certificate  string
+	RegisteredAt int64
+
+	publicKey  ed25519.PublicKey
+	privateKey ed25519.PrivateKey
+	// 加载时私钥是否为加密存储
+	encrypted bool
+}
+
+// bundle 身份文件内容
+type bundle struct {
+	Version   int    `json:"version"`
+	NodeID    string `json:"node_id"`
+	PublicKey string `json:"public_key"`
+	// 未加密时为base64编码的私钥种子
+	PrivateKey string `json:"private_key,omitempty"`
+	// AES-256-GCM加密的私钥种子（base64编码的 nonce||密文）
+	EncryptedPrivateKey string `json:"encrypted_private_key,omitempty"`
+	Certificate         string `json:"certificate,omitempty"`
+	RegisteredAt        int64  `json:"registered_at,omitempty"`
+}
+
+// Generate 生成新的密钥对，节点ID在注册后设置
+func Generate() (*Identity, error) {
+	id := &Identity{}
+	if err := id.GenerateKey(); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// GenerateKey 为身份生成新的密钥对（旧版身份文件升级时使用）
+func (id *Identity) GenerateKey() error {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate identity key: %w", err)
+	}
+	id.publicKey, id.privateKey = publicKey, privateKey
+	return nil
+}
+
+// AdoptKey 使用other的密钥对
+func (id *Identity) AdoptKey(other *Identity) {
+	id.publicKey, id.privateKey = other.publicKey, other.privateKey
+}
+
+// HasKey 是否有密钥对
+func (id *Identity) HasKey() bool {
+	return id != nil && id.privateKey != nil
+}
+
+// Encrypted 加载的身份文件中私钥是否为加密存储
+func (id *Identity) Encrypted() bool {
+	return id.encrypted
+}
+
+// PublicKey 返回base64编码的公钥，没有密钥对时为空
+func (id *Identity) PublicKey() string {
+	if !id.HasKey() {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(id.publicKey)
+}
+
+// Load 加载身份文件，文件不存在时返回nil
+// key为私钥的加密密钥，私钥未加密时忽略；只包含节点ID的旧版文件返回没有密钥对的身份
+func Load(path string, key []byte) (*Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read identity file: %w", err)
+	}
+
+	content := strings.TrimSpace(string(data))
+	if content == "" {
+		return nil, nil
+	}
+	if _, err := strconv.ParseInt(content, 10, 64); err == nil {
+		return &Identity{NodeID: content}, nil
+	}
+
+	var b bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("invalid identity file %s: %w", path, err)
+	}
+	if b.Version != bundleVersion {
+		return nil, fmt.Errorf("unsupported identity file version %d", b.Version)
+	}
+
+	var seed []byte
+	switch {
+	case b.EncryptedPrivateKey != "":
+		if key == nil {
+			return nil, ErrEncrypted
+		}
+		seed, err = decrypt(key, b.EncryptedPrivateKey)
+	case b.PrivateKey != "":
+		seed, err = base64.StdEncoding.DecodeString(b.PrivateKey)
+	default:
+		err = fmt.Errorf("missing private key")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity private key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid identity private key length %d", len(seed))
+	}
+
+	privateKey := ed25519.NewKeyFromSeed(seed)
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	if b.PublicKey != base64.StdEncoding.EncodeToString(publicKey) {
+		return nil, fmt.Errorf("identity public key does not match private key")
+	}
+	return &Identity{
+		NodeID:       b.NodeID,
+		Certificate:  b.Certificate,
+		RegisteredAt: b.RegisteredAt,
+		publicKey:    publicKey,
+		privateKey:   privateKey,
+		encrypted:    b.EncryptedPrivateKey != "",
+	}, nil
+}
+
+// Save 以0600权限原子写入身份文件，key不为nil时加密私钥
+func (id *Identity) Save(path string, key []byte) error {
+	if !id.HasKey() {
+		return fmt.Errorf("identity has no key")
+	}
+	b := bundle{
+		Version:      bundleVersion,
+		NodeID:       id.NodeID,
+		PublicKey:    id.PublicKey(),
+		Certificate:  id.Certificate,
+		RegisteredAt: id.RegisteredAt,
+	}
+	seed := id.privateKey.Seed()
+	if key != nil {
+		encrypted, err := encrypt(key, seed)
+		if err != nil {
+			return err
+		}
+		b.EncryptedPrivateKey = encrypted
+	} else {
+		b.PrivateKey = base64.StdEncoding.EncodeToString(seed)
+	}
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode identity: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to move temp file: %w", err)
+	}
+	return nil
+}
+
+// Remove 删除身份文件
+func Remove(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove identity file: %w", err)
+	}
+	return nil
+}
+
+// SignRequest 为发往平台的请求添加签名头
+// 签名内容为 "utopia-node-request-v1\n<方法>\n<路径与查询>\n<时间戳>\n<请求体SHA-256十六进制>"
+func (id *Identity) SignRequest(req *http.Request, body []byte) {
+	if !id.HasKey() {
+		return
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	digest := sha256.Sum256(body)
+	message := strings.Join([]string{signaturePrefix, req.Method, req.URL.RequestURI(), timestamp, hex.EncodeToString(digest[:])}, "\n")
+
+	if id.NodeID != "" {
+		req.Header.Set(HeaderNodeID, id.NodeID)
+	}
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, base64.StdEncoding.EncodeToString(ed25519.Sign(id.privateKey, []byte(message))))
+}
+
+// EncryptionKey 返回加密私钥的密钥：keyFile内容的SHA-256，keyFile为空时由机器ID派生
+// 由机器ID派生时，复制到其他机器的身份文件无法解密
+func EncryptionKey(keyFile string) ([]byte, error) {
+	var material []byte
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read identity key file: %w", err)
+		}
+		if len(strings.TrimSpace(string(data))) == 0 {
+			return nil, fmt.Errorf("identity key file %s is empty", keyFile)
+		}
+		material = data
+	} else {
+		machineID, err := machineID()
+		if err != nil {
+			return nil, err
+		}
+		material = []byte(machineKeyContext + machineID)
+	}
+	key := sha256.Sum256(material)
+	return key[:], nil
+}
+
+// machineID 读取机器ID
+func machineID() (string, error) {
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		data, err := os.ReadFile(path)
+		if err == nil && strings.TrimSpace(string(data)) != "" {
+			return strings.TrimSpace(string(data)), nil
+		}
+	}
+	return "", fmt.Errorf("failed to read machine ID for identity encryption")
+}
+
+// encrypt 以AES-256-GCM加密
+func encrypt(key, plaintext []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// decrypt 解密encrypt的输出，密钥不匹配（如身份文件来自其他机器）时返回错误
+func decrypt(key []byte, ciphertext string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt (wrong key or identity file copied from another machine)")
+	}
+	return plaintext, nil
+}
+
+// newGCM 创建AES-GCM
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,240 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status 任务状态
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// maxLogLines 每个任务保留的日志行数
+const maxLogLines = 200
+
+// Job 长时间运行的任务
+type Job struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Status     Status                 `json:"status"`
+	Progress   float64                `json:"progress"`
+	Message    string                 `json:"message,omitempty"`
+	Result     map[string]interface{} `json:"result,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Logs       []string               `json:"logs,omitempty"`
+	CreatedAt  int64                  `json:"created_at"`
+	StartedAt  int64                  `json:"started_at,omitempty"`
+	FinishedAt int64                  `json:"finished_at,omitempty"`
+
+	// 提交任务的子租户，平台提交的任务为空
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// RunFunc 任务执行函数，返回的结果保存在 Job.Result 中
+type RunFunc func(ctx context.Context, h *Handle) (map[string]interface{}, error)
+
+// Manager 任务管理器
+type Manager struct {
+	mu      sync.RWMutex
+	jobs    map[string]*Job
+	maxJobs int
+	ctx     context.Context
+	cancel  context.CancelFunc
+	// 执行中的任务
+	running sync.WaitGroup
+}
+
+// Handle 任务执行过程中用于上报进度的句柄
+type Handle struct {
+	manager *Manager
+	id      string
+}
+
+// NewManager 创建新的任务管理器，最多保留maxJobs个任务记录
+func NewManager(maxJobs int) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		jobs:    make(map[string]*Job),
+		maxJobs: maxJobs,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Shutdown 取消所有运行中的任务并等待其返回，ctx到期时不再等待
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs did not stop: %w", ctx.Err())
+	}
+}
+
+// Submit 提交任务并在后台执行，返回任务快照
+func (m *Manager) Submit(jobType string, run RunFunc) Job {
+	return m.SubmitForTenant("", jobType, run)
+}
+
+// SubmitForTenant 代子租户提交任务，任务记录所属子租户
+func (m *Manager) SubmitForTenant(tenant, jobType string, run RunFunc) Job {
+	job := &Job{
+		ID:        newJobID(),
+		Type:      jobType,
+		Status:    StatusPending,
+		CreatedAt: time.Now().Unix(),
+		Tenant:    tenant,
+	}
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	m.evictLocked()
+	snapshot := job.snapshot()
+	m.mu.Unlock()
+
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+		m.execute(job.ID, run)
+	}()
+
+	return snapshot
+}
+
+// execute 执行任务并记录结果
+func (m *Manager) execute(id string, run RunFunc) {
+	m.update(id, func(j *Job) {
+		j.Status = StatusRunning
+		j.StartedAt = time.Now().Unix()
+	})
+
+	handle := &Handle{manager: m, id: id}
+	result, err := func() (result map[string]interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		return run(m.ctx, handle)
+	}()
+
+	m.update(id, func(j *Job) {
+		j.FinishedAt = time.Now().Unix()
+		j.Result = result
+		if err != nil {
+			j.Status = StatusFailed
+			j.Error = err.Error()
+			return
+		}
+		j.Status = StatusSucceeded
+		j.Progress = 100
+	})
+}
+
+// Get 获取任务
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, exists := m.jobs[id]
+	if !exists {
+		return Job{}, false
+	}
+	return job.snapshot(), true
+}
+
+// List 按创建时间倒序列出任务
+func (m *Manager) List() []Job {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		result = append(result, job.snapshot())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt != result[j].CreatedAt {
+			return result[i].CreatedAt > result[j].CreatedAt
+		}
+		return result[i].ID > result[j].ID
+	})
+	return result
+}
+
+// SetProgress 更新任务进度（0-100）与当前说明
+func (h *Handle) SetProgress(progress float64, message string) {
+	h.manager.update(h.id, func(j *Job) {
+		j.Progress = progress
+		j.Message = message
+	})
+}
+
+// Log 追加一行任务日志
+func (h *Handle) Log(line string) {
+	h.manager.update(h.id, func(j *Job) {
+		j.Logs = append(j.Logs, line)
+		if len(j.Logs) > maxLogLines {
+			j.Logs = j.Logs[len(j.Logs)-maxLogLines:]
+		}
+	})
+}
+
+// update 在锁内修改任务
+func (m *Manager) update(id string, fn func(j *Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job, exists := m.jobs[id]; exists {
+		fn(job)
+	}
+}
+
+// evictLocked 超出上限时淘汰最早结束的任务（调用方需持有锁）
+func (m *Manager) evictLocked() {
+	for len(m.jobs) > m.maxJobs {
+		var oldest *Job
+		for _, job := range m.jobs {
+			if job.FinishedAt == 0 {
+				continue
+			}
+			if oldest == nil || job.FinishedAt < oldest.FinishedAt {
+				oldest = job
+			}
+		}
+		if oldest == nil {
+			return // 全部在运行中，不淘汰
+		}
+		delete(m.jobs, oldest.ID)
+	}
+}
+
+// snapshot 返回任务副本
+func (j *Job) snapshot() Job {
+	copied := *j
+	copied.Logs = append([]string(nil), j.Logs...)
+	return copied
+}
+
+// newJobID 生成随机任务ID
+func newJobID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return "job-" + hex.EncodeToString(buf)
+}
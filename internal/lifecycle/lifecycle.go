@@ -0,0 +1,198 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrStopping 管理器已开始停止，不再启动子系统
+var ErrStopping = errors.New("lifecycle is stopping")
+
+// defaultStopTimeout 未设置StopTimeout时停止单个子系统的时限
+const defaultStopTimeout = 10 * time.Second
+
+// Stage 一个子系统的启动与停止
+type Stage struct {
+	Name string
+	// 依赖的子系统：先于本子系统启动，晚于本子系统停止
+	DependsOn []string
+	// 启动子系统，ctx在Stop时取消；为nil表示无需启动操作
+	Start func(ctx context.Context) error
+	// 停止子系统，ctx在StopTimeout后到期；启动失败的子系统同样会被停止，需容忍未完成的初始化
+	Stop        func(ctx context.Context) error
+	StopTimeout time.Duration
+}
+
+// Manager 按依赖顺序启动子系统，并按相反顺序逐个停止
+type Manager struct {
+	// runMu 串行化Start与Stop
+	runMu sync.Mutex
+
+	mu          sync.Mutex
+	stages      []Stage
+	started     []string
+	stopping    bool
+	cancelStart context.CancelFunc
+}
+
+// New 创建生命周期管理器
+func New() *Manager {
+	return &Manager{}
+}
+
+// Add 注册子系统，需在Start前调用
+func (m *Manager) Add(stage Stage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stages = append(m.stages, stage)
+}
+
+// order 按依赖关系排序子系统，无依赖关系的子系统保持注册顺序
+// 存在未注册的依赖或循环依赖时返回错误
+func (m *Manager) order() ([]Stage, error) {
+	byName := make(map[string]Stage, len(m.stages))
+	for _, stage := range m.stages {
+		if _, exists := byName[stage.Name]; exists {
+			return nil, fmt.Errorf("duplicate stage %q", stage.Name)
+		}
+		byName[stage.Name] = stage
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	marks := make(map[string]int, len(m.stages))
+	ordered := make([]Stage, 0, len(m.stages))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch marks[name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %v", append(path, name))
+		}
+		stage, exists := byName[name]
+		if !exists {
+			return fmt.Errorf("stage %q depends on unknown stage %q", path[len(path)-1], name)
+		}
+		marks[name] = visiting
+		for _, dep := range stage.DependsOn {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		marks[name] = done
+		ordered = append(ordered, stage)
+		return nil
+	}
+	for _, stage := range m.stages {
+		if err := visit(stage.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// Start 按依赖顺序启动全部子系统，任一子系统启动失败时立即返回错误
+// 已启动（包括启动失败）的子系统由Stop停止
+func (m *Manager) Start(ctx context.Context) error {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	m.mu.Lock()
+	if m.stopping {
+		m.mu.Unlock()
+		return ErrStopping
+	}
+	ordered, err := m.order()
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m.cancelStart = cancel
+	m.mu.Unlock()
+
+	for _, stage := range ordered {
+		if ctx.Err() != nil {
+			return ErrStopping
+		}
+		m.mu.Lock()
+		m.started = append(m.started, stage.Name)
+		m.mu.Unlock()
+
+		if stage.Start == nil {
+			continue
+		}
+		if err := stage.Start(ctx); err != nil {
+			return fmt.Errorf("%s: %w", stage.Name, err)
+		}
+	}
+	return nil
+}
+
+// Stop 取消进行中的启动，按启动的相反顺序逐个停止子系统
+// 每个子系统的停止不超过其时限，超时后继续停止下一个；返回全部停止错误
+func (m *Manager) Stop() error {
+	m.mu.Lock()
+	m.stopping = true
+	if m.cancelStart != nil {
+		m.cancelStart()
+	}
+	m.mu.Unlock()
+
+	// 等待进行中的Start返回
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	m.mu.Lock()
+	started := m.started
+	m.started = nil
+	byName := make(map[string]Stage, len(m.stages))
+	for _, stage := range m.stages {
+		byName[stage.Name] = stage
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		stage := byName[started[i]]
+		if err := m.stopStage(stage); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", stage.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stopStage 在时限内停止单个子系统，超时后不再等待
+func (m *Manager) stopStage(stage Stage) error {
+	if stage.Stop == nil {
+		return nil
+	}
+
+	timeout := stage.StopTimeout
+	if timeout <= 0 {
+		timeout = defaultStopTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	begin := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- stage.Stop(ctx) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			fmt.Printf("Stopped %s in %s\n", stage.Name, time.Since(begin).Round(time.Millisecond))
+		}
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
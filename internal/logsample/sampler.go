@@ -0,0 +1,267 @@
+// Package logsample 对重复的日志限流：同一条消息在一个时间窗口内只输出前几次，
+// 其余的计数后在窗口结束时汇总为一行，避免持续故障（如驱动异常期间每个监控周期的错误）占满日志
+package logsample
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// flushInterval 检查时间窗口是否结束、输出汇总的间隔
+const flushInterval = 10 * time.Second
+
+// Policy 限流策略
+type Policy struct {
+	Enabled bool
+	// 统计重复消息的时间窗口
+	Window time.Duration
+	// 每个窗口内原样输出的次数，超出的只计数
+	Burst int
+}
+
+// DefaultPolicy 默认策略：每5分钟内相同的消息最多输出3次
+var DefaultPolicy = Policy{Enabled: true, Window: 5 * time.Minute, Burst: 3}
+
+// Stats 限流统计
+type Stats struct {
+	// 启动以来被汇总的日志行数
+	Suppressed int64 `json:"suppressed"`
+	// 当前窗口内已达到输出次数上限的消息数
+	Active int `json:"active"`
+}
+
+// entry 一条消息在当前窗口内的输出情况
+type entry struct {
+	start time.Time
+	// 本窗口内出现的次数，及其中未输出的次数
+	count      int
+	suppressed int
+	// 汇总的输出方式：Printf写入out，logrus消息经原logger输出
+	message string
+	logger  *logrus.Logger
+	level   logrus.Level
+}
+
+// Sampler 重复日志限流器
+type Sampler struct {
+	mu      sync.Mutex
+	out     io.Writer
+	policy  Policy
+	entries map[string]*entry
+	total   int64
+	now     func() time.Time
+}
+
+// New 创建限流器，Printf的输出写入out
+func New(out io.Writer) *Sampler {
+	return &Sampler{out: out, policy: DefaultPolicy, entries: make(map[string]*entry), now: time.Now}
+}
+
+// SetPolicy 更新限流策略，关闭时先输出已汇总的计数
+func (s *Sampler) SetPolicy(policy Policy) {
+	if !policy.Enabled {
+		s.flush(true)
+	}
+	s.mu.Lock()
+	s.policy = policy
+	s.mu.Unlock()
+}
+
+// Stats 返回限流统计
+func (s *Sampler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active := 0
+	for _, e := range s.entries {
+		if e.count >= s.policy.Burst {
+			active++
+		}
+	}
+	return Stats{Suppressed: s.total, Active: active}
+}
+
+// allow 记录一次消息，返回是否原样输出；窗口已结束时同时返回上一个窗口的汇总，由调用方先输出
+func (s *Sampler) allow(key string, create func() *entry) (bool, *entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policy := s.policy
+	if !policy.Enabled || policy.Burst <= 0 || policy.Window <= 0 {
+		return true, nil
+	}
+	now := s.now()
+	e, exists := s.entries[key]
+	if !exists {
+		e = create()
+		e.start = now
+		s.entries[key] = e
+	}
+	var summary *entry
+	if now.Sub(e.start) >= policy.Window {
+		summary = s.rollLocked(e, now)
+	}
+	e.count++
+	allowed := e.count <= policy.Burst
+	if !allowed {
+		e.suppressed++
+		s.total++
+	}
+	return allowed, summary
+}
+
+// rollLocked 结束消息的当前窗口，返回需输出的汇总（调用方需持有锁）
+// 窗口内有被汇总的消息说明故障仍在持续，新窗口内不再原样输出，只在结束时汇总
+func (s *Sampler) rollLocked(e *entry, now time.Time) *entry {
+	var summary *entry
+	if e.suppressed > 0 {
+		copied := *e
+		summary = &copied
+	}
+	e.start = now
+	if e.suppressed > 0 {
+		e.count = s.policy.Burst
+	} else {
+		e.count = 0
+	}
+	e.suppressed = 0
+	return summary
+}
+
+// Flush 输出窗口已结束的汇总，并清理不再出现的消息
+func (s *Sampler) Flush() {
+	s.flush(false)
+}
+
+// flush 输出汇总，all为true时不等待窗口结束
+func (s *Sampler) flush(all bool) {
+	s.mu.Lock()
+	now := s.now()
+	window := s.policy.Window
+	var summaries []*entry
+	for key, e := range s.entries {
+		if !all && now.Sub(e.start) < window {
+			continue
+		}
+		if summary := s.rollLocked(e, now); summary != nil {
+			summaries = append(summaries, summary)
+		}
+		if all || e.count == 0 {
+			delete(s.entries, key)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, summary := range summaries {
+		if summary.logger != nil {
+			summaryEntry(summary, now).Log(summary.level, summary.summary(now))
+		} else {
+			fmt.Fprintln(s.out, summary.summary(now))
+		}
+	}
+}
+
+// Run 定期输出汇总，ctx取消时输出剩余的汇总后返回
+func (s *Sampler) Run(ctx context.Context) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.flush(true)
+			return
+		case <-ticker.C:
+			s.Flush()
+		}
+	}
+}
+
+// summaryKey 标记汇总日志，限流格式化器不再对其限流
+type summaryKey struct{}
+
+// summary 返回汇总的日志内容
+func (e *entry) summary(now time.Time) string {
+	return fmt.Sprintf("%s [repeated %d more times in the last %s]", e.message, e.suppressed, now.Sub(e.start).Round(time.Second))
+}
+
+// summaryEntry 创建汇总的logrus日志，带有重复次数字段
+func summaryEntry(e *entry, now time.Time) *logrus.Entry {
+	return e.logger.WithContext(context.WithValue(context.Background(), summaryKey{}, true)).
+		WithFields(logrus.Fields{
+			"repeated":       e.suppressed,
+			"window_seconds": int64(now.Sub(e.start).Round(time.Second).Seconds()),
+		})
+}
+
+// Printf 与 fmt.Printf 相同，但对重复的消息限流
+func (s *Sampler) Printf(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	trimmed := strings.TrimRight(message, "\n")
+	allowed, summary := s.allow("printf:"+trimmed, func() *entry { return &entry{message: trimmed} })
+	if summary != nil {
+		fmt.Fprintln(s.out, summary.summary(s.now()))
+	}
+	if allowed {
+		io.WriteString(s.out, message)
+	}
+}
+
+// Formatter 包装logrus的格式化器，对级别与消息都相同的日志限流
+func (s *Sampler) Formatter(inner logrus.Formatter) logrus.Formatter {
+	return &formatter{sampler: s, inner: inner}
+}
+
+// formatter 限流的logrus格式化器，被限流的日志格式化为空
+type formatter struct {
+	sampler *Sampler
+	inner   logrus.Formatter
+}
+
+// Format 实现 logrus.Formatter
+func (f *formatter) Format(e *logrus.Entry) ([]byte, error) {
+	if e.Context != nil && e.Context.Value(summaryKey{}) != nil {
+		return f.inner.Format(e)
+	}
+	key := fmt.Sprintf("logrus:%s:%s", e.Level, e.Message)
+	create := func() *entry { return &entry{message: e.Message, logger: e.Logger, level: e.Level} }
+	allowed, summary := f.sampler.allow(key, create)
+
+	// 此时logger已加锁，汇总不能再经logger输出，与本条日志一起格式化
+	var out []byte
+	if summary != nil {
+		now := f.sampler.now()
+		se := summaryEntry(summary, now)
+		se.Time, se.Level, se.Message = e.Time, summary.level, summary.summary(now)
+		formatted, err := f.inner.Format(se)
+		if err != nil {
+			return nil, err
+		}
+		out = formatted
+	}
+	if allowed {
+		formatted, err := f.inner.Format(e)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, formatted...)
+	}
+	return out, nil
+}
+
+// std 全局限流器，Printf 写入标准输出
+var std = New(os.Stdout)
+
+// Default 返回全局限流器
+func Default() *Sampler {
+	return std
+}
+
+// Printf 使用全局限流器输出
+func Printf(format string, args ...interface{}) {
+	std.Printf(format, args...)
+}
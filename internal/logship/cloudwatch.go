@@ -0,0 +1,272 @@
+package logship
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PutLogEvents 的单次请求限制
+const (
+	cloudWatchMaxEvents = 10000
+	cloudWatchMaxBytes  = 1024 * 1024
+	// 每条事件在大小计算中额外占用的字节数
+	cloudWatchEventOverhead = 26
+)
+
+// CloudWatchCredentials AWS访问密钥
+type CloudWatchCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// CloudWatchSink 通过 CloudWatch Logs PutLogEvents 接口输出日志
+// 每个节点写入日志组中以节点ID命名的日志流，事件内容为包含 claim_id 等字段的JSON
+type CloudWatchSink struct {
+	region      string
+	logGroup    string
+	endpoint    string
+	credentials CloudWatchCredentials
+	httpClient  *http.Client
+
+	mu      sync.Mutex
+	streams map[string]bool // 已确认存在的日志流
+}
+
+// NewCloudWatchSink 创建CloudWatch输出
+// 未提供访问密钥时读取 AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY 与 AWS_SESSION_TOKEN 环境变量；
+// endpoint为空时使用区域的默认地址
+func NewCloudWatchSink(region, logGroup, endpoint string, credentials CloudWatchCredentials) (*CloudWatchSink, error) {
+	if credentials.AccessKeyID == "" {
+		credentials = CloudWatchCredentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("AWS credentials are not configured")
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://logs.%s.amazonaws.com", region)
+	}
+	return &CloudWatchSink{
+		region:      region,
+		logGroup:    logGroup,
+		endpoint:    strings.TrimRight(endpoint, "/") + "/",
+		credentials: credentials,
+		httpClient:  &http.Client{},
+		streams:     make(map[string]bool),
+	}, nil
+}
+
+// Type 输出类型
+func (s *CloudWatchSink) Type() string { return "cloudwatch" }
+
+// CloudWatch Logs 请求结构
+type (
+	cloudWatchEvent struct {
+		Timestamp int64  `json:"timestamp"`
+		Message   string `json:"message"`
+	}
+	cloudWatchMessage struct {
+		NodeID      string `json:"node_id"`
+		ClaimID     string `json:"claim_id"`
+		ContainerID string `json:"container_id"`
+		Stream      string `json:"stream"`
+		Line        string `json:"line"`
+		Truncated   bool   `json:"truncated,omitempty"`
+	}
+	cloudWatchError struct {
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+	}
+)
+
+// Write 按时间排序后分批写入，日志流不存在时创建
+func (s *CloudWatchSink) Write(ctx context.Context, nodeID string, entries []Entry) error {
+	stream := cloudWatchStreamName(nodeID)
+	if err := s.ensureStream(ctx, stream); err != nil {
+		return err
+	}
+
+	events := make([]cloudWatchEvent, 0, len(entries))
+	for _, e := range entries {
+		message, err := json.Marshal(cloudWatchMessage{
+			NodeID:      nodeID,
+			ClaimID:     e.ClaimID,
+			ContainerID: e.ContainerID,
+			Stream:      e.Stream,
+			Line:        e.Line,
+			Truncated:   e.Truncated,
+		})
+		if err != nil {
+			return err
+		}
+		events = append(events, cloudWatchEvent{Timestamp: e.Time.UnixMilli(), Message: string(message)})
+	}
+	// PutLogEvents 要求事件按时间升序
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp < events[j].Timestamp })
+
+	for len(events) > 0 {
+		n, size := 0, 0
+		for n < len(events) && n < cloudWatchMaxEvents {
+			eventSize := len(events[n].Message) + cloudWatchEventOverhead
+			if n > 0 && size+eventSize > cloudWatchMaxBytes {
+				break
+			}
+			size += eventSize
+			n++
+		}
+		err := s.call(ctx, "PutLogEvents", map[string]interface{}{
+			"logGroupName":  s.logGroup,
+			"logStreamName": stream,
+			"logEvents":     events[:n],
+		})
+		if err != nil {
+			if strings.Contains(err.Error(), "ResourceNotFoundException") {
+				s.mu.Lock()
+				delete(s.streams, stream)
+				s.mu.Unlock()
+			}
+			return err
+		}
+		events = events[n:]
+	}
+	return nil
+}
+
+// ensureStream 创建日志流，已存在时忽略
+func (s *CloudWatchSink) ensureStream(ctx context.Context, stream string) error {
+	s.mu.Lock()
+	exists := s.streams[stream]
+	s.mu.Unlock()
+	if exists {
+		return nil
+	}
+
+	err := s.call(ctx, "CreateLogStream", map[string]string{
+		"logGroupName":  s.logGroup,
+		"logStreamName": stream,
+	})
+	if err != nil && !strings.Contains(err.Error(), "ResourceAlreadyExistsException") {
+		return fmt.Errorf("failed to create log stream %s: %w", stream, err)
+	}
+	s.mu.Lock()
+	s.streams[stream] = true
+	s.mu.Unlock()
+	return nil
+}
+
+// call 调用CloudWatch Logs接口
+func (s *CloudWatchSink) call(ctx context.Context, action string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Logs_20140328."+action)
+	signV4(req, body, s.credentials, s.region, "logs", time.Now())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var apiErr cloudWatchError
+		if json.Unmarshal(msg, &apiErr) == nil && apiErr.Type != "" {
+			return fmt.Errorf("cloudwatch %s returned %d: %s: %s", action, resp.StatusCode, apiErr.Type, apiErr.Message)
+		}
+		return fmt.Errorf("cloudwatch %s returned %d: %s", action, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// cloudWatchStreamName 日志流名称不能包含 ':' 与 '*'
+func cloudWatchStreamName(nodeID string) string {
+	if nodeID == "" {
+		nodeID = "unregistered"
+	}
+	return strings.NewReplacer(":", "_", "*", "_").Replace(nodeID)
+}
+
+// signV4 按 AWS Signature Version 4 为请求签名
+func signV4(req *http.Request, body []byte, credentials CloudWatchCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// sha256Hex 返回数据的SHA-256十六进制摘要
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 计算HMAC-SHA256
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
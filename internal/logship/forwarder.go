@@ -0,0 +1,236 @@
+package logship
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"utopia-node-agent/internal/store"
+)
+
+// 日志流
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+	// StreamAgent 转发器自身插入的提示行（如限流丢弃的行数）
+	StreamAgent = "agent"
+)
+
+// Entry 一行容器日志
+type Entry struct {
+	Time        time.Time `json:"time"`
+	ClaimID     string    `json:"claim_id"`
+	ContainerID string    `json:"container_id"`
+	Stream      string    `json:"stream"`
+	Line        string    `json:"line"`
+	// 超过单行长度上限被截断
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Target 受管容器，运行中的容器会被跟踪日志
+type Target struct {
+	ContainerID string
+	ClaimID     string
+	Running     bool
+	// 容器创建时间（Unix秒）
+	Created int64
+}
+
+// Sink 日志输出
+type Sink interface {
+	// Type 输出类型：platform、loki、cloudwatch
+	Type() string
+	// Write 写出一批日志，返回错误时整批重试
+	Write(ctx context.Context, nodeID string, entries []Entry) error
+}
+
+// Options 日志转发选项
+type Options struct {
+	// 单行最大字节数，超出部分截断
+	MaxLineBytes int
+	// 每个容器每秒最多转发的行数，超出的行丢弃，0表示不限制
+	RateLimitLinesPerSecond int
+	// 每次写出的最大行数
+	BatchSize int
+	// 每个输出最多缓存的行数，超出时丢弃最旧的行
+	QueueSize int
+	// 定期写出的间隔
+	FlushInterval time.Duration
+}
+
+// Status 日志转发状态
+type Status struct {
+	// 正在跟踪日志的容器数
+	Containers int `json:"containers"`
+	// 因限流丢弃与被截断的行数
+	RateLimitedLines int64        `json:"rate_limited_lines"`
+	TruncatedLines   int64        `json:"truncated_lines"`
+	Sinks            []SinkStatus `json:"sinks"`
+}
+
+// position 容器日志的转发进度，Agent重启后从该时间之后继续
+type position struct {
+	ClaimID string `json:"claim_id"`
+	// 最后一行已读取日志的时间（Unix纳秒）
+	Time int64 `json:"time"`
+}
+
+// Forwarder 跟踪受管容器的stdout/stderr并转发到各输出
+// 通过 docker logs --follow 读取日志，与容器的日志驱动无关；进度在读取时记录，
+// Agent崩溃时尚未写出的日志会丢失，不会重复转发
+type Forwarder struct {
+	nodeID    func() string
+	opts      Options
+	positions *store.Store
+	workers   []*worker
+	started   time.Time
+
+	mu      sync.Mutex
+	tailers map[string]*tailer // containerID -> 日志跟踪
+
+	rateLimited atomic.Int64
+	truncated   atomic.Int64
+}
+
+// NewForwarder 创建日志转发器，names与sinks一一对应，转发进度持久化在positionsDir中
+func NewForwarder(nodeID func() string, names []string, sinks []Sink, opts Options, positionsDir string) (*Forwarder, error) {
+	positions, err := store.Open(positionsDir)
+	if err != nil {
+		return nil, err
+	}
+	f := &Forwarder{
+		nodeID:    nodeID,
+		opts:      opts,
+		positions: positions,
+		started:   time.Now(),
+		tailers:   make(map[string]*tailer),
+	}
+	for i, sink := range sinks {
+		f.workers = append(f.workers, newWorker(names[i], sink, opts))
+	}
+	return f, nil
+}
+
+// Run 运行所有输出的写出协程，直到ctx取消后停止跟踪并尽力写出剩余日志
+func (f *Forwarder) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, w := range f.workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			w.run(ctx, f.nodeID)
+		}(w)
+	}
+	<-ctx.Done()
+	f.stopAll()
+	wg.Wait()
+}
+
+// Sync 为运行中的容器启动日志跟踪，停止已不在运行的容器的跟踪并保存转发进度
+// 跟踪进程异常退出的容器在下次同步时重新跟踪
+func (f *Forwarder) Sync(ctx context.Context, targets []Target) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	present := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		present[target.ContainerID] = true
+		t, exists := f.tailers[target.ContainerID]
+		if exists && t.exited() {
+			f.savePosition(t)
+			delete(f.tailers, target.ContainerID)
+			exists = false
+		}
+		if !target.Running || exists {
+			continue
+		}
+		t = newTailer(target, f.since(target), f)
+		var tailCtx context.Context
+		tailCtx, t.cancel = context.WithCancel(ctx)
+		f.tailers[target.ContainerID] = t
+		go t.run(tailCtx)
+	}
+
+	for id, t := range f.tailers {
+		if !present[id] {
+			t.stop()
+			delete(f.tailers, id)
+			continue
+		}
+		f.savePosition(t)
+	}
+
+	// 清理已删除容器的转发进度
+	keys, err := f.positions.Keys()
+	if err != nil {
+		return
+	}
+	for _, id := range keys {
+		if !present[id] {
+			f.positions.Delete(id)
+		}
+	}
+}
+
+// Status 返回日志转发状态
+func (f *Forwarder) Status() Status {
+	f.mu.Lock()
+	containers := len(f.tailers)
+	f.mu.Unlock()
+
+	status := Status{
+		Containers:       containers,
+		RateLimitedLines: f.rateLimited.Load(),
+		TruncatedLines:   f.truncated.Load(),
+		Sinks:            make([]SinkStatus, 0, len(f.workers)),
+	}
+	for _, w := range f.workers {
+		status.Sinks = append(status.Sinks, w.snapshot())
+	}
+	return status
+}
+
+// publish 将日志加入每个输出的队列，不会阻塞
+func (f *Forwarder) publish(entries ...Entry) {
+	for _, w := range f.workers {
+		w.enqueue(entries)
+	}
+}
+
+// since 返回容器日志的起始时间：有转发进度时从进度继续，
+// 转发器启动前已存在的容器从启动时间开始，避免首次启用时补发全部历史日志
+func (f *Forwarder) since(target Target) time.Time {
+	var pos position
+	if ok, err := f.positions.Get(target.ContainerID, &pos); err == nil && ok && pos.Time > 0 {
+		return time.Unix(0, pos.Time)
+	}
+	if target.Created > 0 && target.Created < f.started.Unix() {
+		return f.started
+	}
+	return time.Time{}
+}
+
+// savePosition 保存跟踪的转发进度，进度未变化时跳过（调用方需持有锁）
+func (f *Forwarder) savePosition(t *tailer) {
+	last, changed := t.checkpoint()
+	if !changed {
+		return
+	}
+	pos := position{ClaimID: t.target.ClaimID, Time: last.UnixNano()}
+	if err := f.positions.Put(t.target.ContainerID, &pos); err != nil {
+		fmt.Printf("Warning: failed to save log position of container %s: %v\n", t.target.ContainerID, err)
+	}
+}
+
+// stopAll 停止全部跟踪并保存进度
+func (f *Forwarder) stopAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, t := range f.tailers {
+		t.stop()
+		f.savePosition(t)
+		delete(f.tailers, id)
+	}
+}
@@ -0,0 +1,93 @@
+package logship
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// LokiSink 通过Loki推送接口（JSON编码）输出日志
+// 每个 node_id、claim_id、container_id、stream 组合为一个Loki流
+type LokiSink struct {
+	url        string
+	tenantID   string
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+// NewLokiSink 创建Loki输出，url为Loki地址（如 http://loki:3100），tenantID非空时作为 X-Scope-OrgID
+func NewLokiSink(url, tenantID string, headers map[string]string) *LokiSink {
+	return &LokiSink{
+		url:        strings.TrimRight(url, "/") + "/loki/api/v1/push",
+		tenantID:   tenantID,
+		headers:    headers,
+		httpClient: &http.Client{},
+	}
+}
+
+// Type 输出类型
+func (s *LokiSink) Type() string { return "loki" }
+
+// Loki 推送请求结构
+type (
+	lokiPush struct {
+		Streams []lokiStream `json:"streams"`
+	}
+	lokiStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+)
+
+// Write 按流聚合日志后推送，同一流内保持读取顺序
+func (s *LokiSink) Write(ctx context.Context, nodeID string, entries []Entry) error {
+	var streams []lokiStream
+	index := make(map[[3]string]int)
+	for _, e := range entries {
+		key := [3]string{e.ClaimID, e.ContainerID, e.Stream}
+		i, exists := index[key]
+		if !exists {
+			i = len(streams)
+			index[key] = i
+			streams = append(streams, lokiStream{Stream: map[string]string{
+				"node_id":      nodeID,
+				"claim_id":     e.ClaimID,
+				"container_id": e.ContainerID,
+				"stream":       e.Stream,
+			}})
+		}
+		streams[i].Values = append(streams[i].Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), e.Line})
+	}
+
+	payload, err := json.Marshal(lokiPush{Streams: streams})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.tenantID != "" {
+		req.Header.Set("X-Scope-OrgID", s.tenantID)
+	}
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("loki returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
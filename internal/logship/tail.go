@@ -0,0 +1,203 @@
+package logship
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timestampPrefixLen docker logs --timestamps 在每行前加的时间戳长度（RFC3339Nano加空格）
+const timestampPrefixLen = len("2006-01-02T15:04:05.000000000Z ")
+
+// tailer 单个容器的日志跟踪
+type tailer struct {
+	target Target
+	since  time.Time
+	f      *Forwarder
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu sync.Mutex
+	// 最后一行已读取日志的时间及其是否已保存
+	last  time.Time
+	dirty bool
+	// 限流令牌桶
+	tokens  float64
+	refill  time.Time
+	dropped int
+}
+
+// newTailer 创建从since之后开始的容器日志跟踪
+func newTailer(target Target, since time.Time, f *Forwarder) *tailer {
+	return &tailer{
+		target: target,
+		since:  since,
+		f:      f,
+		done:   make(chan struct{}),
+		last:   since,
+		tokens: float64(f.opts.RateLimitLinesPerSecond),
+		refill: time.Now(),
+	}
+}
+
+// run 运行 docker logs --follow 并转发输出，容器停止或ctx取消时返回
+func (t *tailer) run(ctx context.Context) {
+	defer close(t.done)
+
+	args := []string{"logs", "--follow", "--timestamps"}
+	if !t.since.IsZero() {
+		args = append(args, "--since", t.since.UTC().Format(time.RFC3339Nano))
+	}
+	cmd := exec.CommandContext(ctx, "docker", append(args, t.target.ContainerID)...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		fmt.Printf("Warning: failed to follow logs of container %s: %v\n", t.target.ContainerID, err)
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		fmt.Printf("Warning: failed to follow logs of container %s: %v\n", t.target.ContainerID, err)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Printf("Warning: failed to follow logs of container %s: %v\n", t.target.ContainerID, err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for stream, r := range map[string]io.Reader{StreamStdout: stdout, StreamStderr: stderr} {
+		wg.Add(1)
+		go func(stream string, r io.Reader) {
+			defer wg.Done()
+			t.read(stream, r)
+		}(stream, r)
+	}
+	wg.Wait()
+	if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		fmt.Printf("Warning: log stream of container %s ended: %v\n", t.target.ContainerID, err)
+	}
+}
+
+// read 逐行读取日志流，超过单行上限的部分丢弃
+func (t *tailer) read(stream string, r io.Reader) {
+	maxBytes := t.f.opts.MaxLineBytes + timestampPrefixLen
+	br := bufio.NewReaderSize(r, 64*1024)
+	var line []byte
+	truncated := false
+	for {
+		chunk, isPrefix, err := br.ReadLine()
+		if err != nil {
+			return
+		}
+		if room := maxBytes - len(line); len(chunk) > room {
+			chunk = chunk[:max(room, 0)]
+			truncated = true
+		}
+		line = append(line, chunk...)
+		if isPrefix {
+			continue
+		}
+		t.handle(stream, string(line), truncated)
+		line, truncated = line[:0], false
+	}
+}
+
+// handle 解析时间戳并在限流允许时转发一行日志
+func (t *tailer) handle(stream, raw string, truncated bool) {
+	ts := time.Now()
+	line := raw
+	if prefix, rest, ok := strings.Cut(raw, " "); ok {
+		if parsed, err := time.Parse(time.RFC3339Nano, prefix); err == nil {
+			ts, line = parsed, rest
+		}
+	}
+
+	t.mu.Lock()
+	// --since 包含起始时间本身，跳过已转发过的行
+	if !t.since.IsZero() && !ts.After(t.since) {
+		t.mu.Unlock()
+		return
+	}
+	if ts.After(t.last) {
+		t.last, t.dirty = ts, true
+	}
+	if !t.allowLocked() {
+		t.dropped++
+		t.mu.Unlock()
+		t.f.rateLimited.Add(1)
+		return
+	}
+	dropped := t.dropped
+	t.dropped = 0
+	t.mu.Unlock()
+
+	var entries []Entry
+	if dropped > 0 {
+		entries = append(entries, Entry{
+			Time:        ts,
+			ClaimID:     t.target.ClaimID,
+			ContainerID: t.target.ContainerID,
+			Stream:      StreamAgent,
+			Line:        fmt.Sprintf("%d log line(s) dropped by rate limit", dropped),
+		})
+	}
+	if truncated {
+		t.f.truncated.Add(1)
+	}
+	entries = append(entries, Entry{
+		Time:        ts,
+		ClaimID:     t.target.ClaimID,
+		ContainerID: t.target.ContainerID,
+		Stream:      stream,
+		Line:        strings.ToValidUTF8(line, "�"),
+		Truncated:   truncated,
+	})
+	t.f.publish(entries...)
+}
+
+// allowLocked 按令牌桶判断是否允许转发一行（调用方需持有锁）
+func (t *tailer) allowLocked() bool {
+	rate := float64(t.f.opts.RateLimitLinesPerSecond)
+	if rate <= 0 {
+		return true
+	}
+	now := time.Now()
+	t.tokens = min(t.tokens+now.Sub(t.refill).Seconds()*rate, rate)
+	t.refill = now
+	if t.tokens < 1 {
+		return false
+	}
+	t.tokens--
+	return true
+}
+
+// checkpoint 返回最后读取的日志时间及自上次调用以来是否有变化
+func (t *tailer) checkpoint() (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	changed := t.dirty
+	t.dirty = false
+	return t.last, changed
+}
+
+// exited 跟踪进程是否已退出
+func (t *tailer) exited() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop 停止跟踪并等待退出
+func (t *tailer) stop() {
+	t.cancel()
+	<-t.done
+}
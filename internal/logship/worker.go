@@ -0,0 +1,140 @@
+package logship
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 写出失败后的重试退避
+const (
+	minBackoff   = 5 * time.Second
+	maxBackoff   = 5 * time.Minute
+	writeTimeout = 30 * time.Second
+)
+
+// SinkStatus 日志输出的运行状态
+type SinkStatus struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Pending     int    `json:"pending"`
+	Dropped     int64  `json:"dropped"`
+	LastSuccess int64  `json:"last_success,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+}
+
+// worker 单个输出的队列与写出协程，一个输出变慢或失败不影响其他输出
+type worker struct {
+	name string
+	sink Sink
+	opts Options
+
+	mu      sync.Mutex
+	queue   []Entry
+	status  SinkStatus
+	backoff time.Duration
+	retryAt time.Time
+	notify  chan struct{}
+}
+
+// newWorker 创建输出的写出协程
+func newWorker(name string, sink Sink, opts Options) *worker {
+	return &worker{
+		name:   name,
+		sink:   sink,
+		opts:   opts,
+		status: SinkStatus{Name: name, Type: sink.Type()},
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// snapshot 返回输出的运行状态
+func (w *worker) snapshot() SinkStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := w.status
+	status.Pending = len(w.queue)
+	return status
+}
+
+// enqueue 加入队列，超出容量时丢弃最旧的日志
+func (w *worker) enqueue(entries []Entry) {
+	w.mu.Lock()
+	w.queue = append(w.queue, entries...)
+	if over := len(w.queue) - w.opts.QueueSize; over > 0 {
+		w.queue = append(w.queue[:0:0], w.queue[over:]...)
+		w.status.Dropped += int64(over)
+	}
+	ready := len(w.queue) >= w.opts.BatchSize
+	w.mu.Unlock()
+
+	if ready {
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run 定期或队列达到批量大小时写出，ctx取消后尽力写出一次
+func (w *worker) run(ctx context.Context, nodeID func() string) {
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+			w.flush(flushCtx, nodeID(), true)
+			cancel()
+			return
+		case <-ticker.C:
+		case <-w.notify:
+		}
+		w.flush(ctx, nodeID(), false)
+	}
+}
+
+// flush 分批写出队列中的日志，失败时保留日志并退避
+func (w *worker) flush(ctx context.Context, nodeID string, force bool) {
+	for {
+		w.mu.Lock()
+		if len(w.queue) == 0 || (!force && time.Now().Before(w.retryAt)) {
+			w.mu.Unlock()
+			return
+		}
+		n := min(len(w.queue), w.opts.BatchSize)
+		batch := append([]Entry(nil), w.queue[:n]...)
+		droppedBefore := w.status.Dropped
+		w.mu.Unlock()
+
+		writeCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+		err := w.sink.Write(writeCtx, nodeID, batch)
+		cancel()
+
+		w.mu.Lock()
+		if err != nil {
+			w.backoff = min(max(w.backoff*2, minBackoff), maxBackoff)
+			w.retryAt = time.Now().Add(w.backoff)
+			if w.status.LastError == "" {
+				fmt.Printf("Warning: log sink %s failed, retrying in %s: %v\n", w.name, w.backoff, err)
+			}
+			w.status.LastError = err.Error()
+			w.mu.Unlock()
+			return
+		}
+
+		// 写出期间队列溢出时队首的本批日志已被部分丢弃，只移除剩余部分
+		if remaining := n - int(w.status.Dropped-droppedBefore); remaining > 0 {
+			w.queue = w.queue[remaining:]
+		}
+		if w.status.LastError != "" {
+			fmt.Printf("Log sink %s recovered\n", w.name)
+		}
+		w.backoff, w.retryAt = 0, time.Time{}
+		w.status.LastError = ""
+		w.status.LastSuccess = time.Now().Unix()
+		w.mu.Unlock()
+	}
+}
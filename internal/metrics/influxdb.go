@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InfluxDBSink 通过 InfluxDB v2 写入API（行协议）输出
+type InfluxDBSink struct {
+	writeURL   string
+	token      string
+	httpClient *http.Client
+}
+
+// NewInfluxDBSink 创建InfluxDB输出
+func NewInfluxDBSink(baseURL, org, bucket, token string) (*InfluxDBSink, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/") + "/api/v2/write")
+	if err != nil {
+		return nil, fmt.Errorf("invalid influxdb url: %w", err)
+	}
+	q := u.Query()
+	q.Set("org", org)
+	q.Set("bucket", bucket)
+	q.Set("precision", "ns")
+	u.RawQuery = q.Encode()
+
+	return &InfluxDBSink{writeURL: u.String(), token: token, httpClient: &http.Client{}}, nil
+}
+
+// Type 输出类型
+func (s *InfluxDBSink) Type() string { return "influxdb" }
+
+// Write 以行协议写出一批采样
+func (s *InfluxDBSink) Write(ctx context.Context, points []Point) error {
+	var body bytes.Buffer
+	for _, p := range points {
+		writeLine(&body, p)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.writeURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("influxdb returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// lineEscaper 转义行协议中测量名、标签键值与字段键的特殊字符
+var lineEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// writeLine 写出一行行协议：measurement,tag=v field=v timestamp
+func writeLine(buf *bytes.Buffer, p Point) {
+	buf.WriteString(lineEscaper.Replace(p.Name))
+	for _, key := range sortedKeys(p.Tags) {
+		if p.Tags[key] == "" {
+			continue
+		}
+		buf.WriteByte(',')
+		buf.WriteString(lineEscaper.Replace(key))
+		buf.WriteByte('=')
+		buf.WriteString(lineEscaper.Replace(p.Tags[key]))
+	}
+	for i, key := range sortedKeys(p.Fields) {
+		if i == 0 {
+			buf.WriteByte(' ')
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(lineEscaper.Replace(key))
+		buf.WriteByte('=')
+		buf.WriteString(strconv.FormatFloat(p.Fields[key], 'f', -1, 64))
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(p.Time.UnixNano(), 10))
+	buf.WriteByte('\n')
+}
+
+// sortedKeys 返回排序后的键，保证输出稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
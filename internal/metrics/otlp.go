@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// OTLPSink 通过 OTLP/HTTP（JSON编码）向收集器输出gauge指标
+type OTLPSink struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	httpClient  *http.Client
+}
+
+// NewOTLPSink 创建OTLP输出，endpoint为收集器地址（如 http://collector:4318）
+func NewOTLPSink(endpoint string, headers map[string]string, serviceName string) *OTLPSink {
+	return &OTLPSink{
+		endpoint:    strings.TrimRight(endpoint, "/") + "/v1/metrics",
+		headers:     headers,
+		serviceName: serviceName,
+		httpClient:  &http.Client{},
+	}
+}
+
+// Type 输出类型
+func (s *OTLPSink) Type() string { return "otlp" }
+
+// OTLP JSON 编码结构（opentelemetry-proto metrics/v1 的子集）
+type (
+	otlpRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpMetric struct {
+		Name  string    `json:"name"`
+		Gauge otlpGauge `json:"gauge"`
+	}
+	otlpGauge struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	}
+	otlpDataPoint struct {
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+		TimeUnixNano string         `json:"timeUnixNano"`
+		AsDouble     float64        `json:"asDouble"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue string `json:"stringValue"`
+	}
+)
+
+// Write 按指标名聚合数据点后写出
+func (s *OTLPSink) Write(ctx context.Context, points []Point) error {
+	var order []string
+	byName := make(map[string]*otlpMetric)
+	for _, p := range points {
+		attrs := otlpAttributes(p.Tags)
+		ts := strconv.FormatInt(p.Time.UnixNano(), 10)
+		for _, field := range sortedKeys(p.Fields) {
+			name := p.Name + "." + field
+			metric, exists := byName[name]
+			if !exists {
+				metric = &otlpMetric{Name: name}
+				byName[name] = metric
+				order = append(order, name)
+			}
+			metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, otlpDataPoint{
+				Attributes:   attrs,
+				TimeUnixNano: ts,
+				AsDouble:     p.Fields[field],
+			})
+		}
+	}
+
+	scope := otlpScopeMetrics{Scope: otlpScope{Name: "utopia-node-agent"}}
+	for _, name := range order {
+		scope.Metrics = append(scope.Metrics, *byName[name])
+	}
+	payload, err := json.Marshal(otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: otlpAttributes(map[string]string{
+			"service.name": s.serviceName,
+		})},
+		ScopeMetrics: []otlpScopeMetrics{scope},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("otlp collector returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// otlpAttributes 将标签转换为OTLP属性
+func otlpAttributes(tags map[string]string) []otlpKeyValue {
+	var attrs []otlpKeyValue
+	for _, key := range sortedKeys(tags) {
+		if tags[key] != "" {
+			attrs = append(attrs, otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: tags[key]}})
+		}
+	}
+	return attrs
+}
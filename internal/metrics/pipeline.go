@@ -0,0 +1,213 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Point 一次采样：测量名称、标签与数值字段
+// 各输出按自身格式展开，例如statsd/OTLP中字段对应指标 "<name>.<field>"
+type Point struct {
+	Name   string
+	Tags   map[string]string
+	Fields map[string]float64
+	Time   time.Time
+}
+
+// Sink 指标输出
+type Sink interface {
+	// Type 输出类型：influxdb、otlp、statsd
+	Type() string
+	// Write 写出一批采样，返回错误时整批重试
+	Write(ctx context.Context, points []Point) error
+}
+
+// Options 指标管道选项
+type Options struct {
+	// 每次写出的最大采样数
+	BatchSize int
+	// 每个输出最多缓存的采样数，超出时丢弃最旧的采样
+	QueueSize int
+	// 定期写出的间隔
+	FlushInterval time.Duration
+}
+
+// 写出失败后的重试退避
+const (
+	minBackoff   = 5 * time.Second
+	maxBackoff   = 5 * time.Minute
+	writeTimeout = 10 * time.Second
+)
+
+// SinkStatus 指标输出的运行状态
+type SinkStatus struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Pending     int    `json:"pending"`
+	Dropped     int64  `json:"dropped"`
+	LastSuccess int64  `json:"last_success,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+}
+
+// Pipeline 将采样分发到多个输出
+// 每个输出有独立的队列与写出协程，一个输出变慢或失败不影响其他输出
+type Pipeline struct {
+	opts    Options
+	workers []*worker
+}
+
+// worker 单个输出的队列与状态
+type worker struct {
+	name string
+	sink Sink
+	opts Options
+
+	mu      sync.Mutex
+	queue   []Point
+	status  SinkStatus
+	backoff time.Duration
+	retryAt time.Time
+	notify  chan struct{}
+}
+
+// NewPipeline 创建指标管道，names与sinks一一对应
+func NewPipeline(names []string, sinks []Sink, opts Options) *Pipeline {
+	p := &Pipeline{opts: opts}
+	for i, sink := range sinks {
+		p.workers = append(p.workers, &worker{
+			name:   names[i],
+			sink:   sink,
+			opts:   opts,
+			status: SinkStatus{Name: names[i], Type: sink.Type()},
+			notify: make(chan struct{}, 1),
+		})
+	}
+	return p
+}
+
+// Publish 将采样加入每个输出的队列，不会阻塞
+func (p *Pipeline) Publish(points []Point) {
+	for _, w := range p.workers {
+		w.enqueue(points)
+	}
+}
+
+// Run 运行所有输出的写出协程，直到ctx取消后尽力写出剩余采样
+func (p *Pipeline) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, w := range p.workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			w.run(ctx)
+		}(w)
+	}
+	wg.Wait()
+}
+
+// Status 返回各输出的运行状态
+func (p *Pipeline) Status() []SinkStatus {
+	result := make([]SinkStatus, 0, len(p.workers))
+	for _, w := range p.workers {
+		w.mu.Lock()
+		status := w.status
+		status.Pending = len(w.queue)
+		w.mu.Unlock()
+		result = append(result, status)
+	}
+	return result
+}
+
+// enqueue 加入队列，超出容量时丢弃最旧的采样
+func (w *worker) enqueue(points []Point) {
+	w.mu.Lock()
+	w.queue = append(w.queue, points...)
+	if over := len(w.queue) - w.opts.QueueSize; over > 0 {
+		w.queue = append(w.queue[:0:0], w.queue[over:]...)
+		w.status.Dropped += int64(over)
+	}
+	ready := len(w.queue) >= w.opts.BatchSize
+	w.mu.Unlock()
+
+	if ready {
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run 定期或队列达到批量大小时写出
+func (w *worker) run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// 退出前尽力写出一次，忽略退避
+			flushCtx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+			w.flush(flushCtx, true)
+			cancel()
+			return
+		case <-ticker.C:
+		case <-w.notify:
+		}
+		w.flush(ctx, false)
+	}
+}
+
+// flush 分批写出队列中的采样，失败时保留采样并退避
+func (w *worker) flush(ctx context.Context, force bool) {
+	for {
+		w.mu.Lock()
+		if len(w.queue) == 0 || (!force && time.Now().Before(w.retryAt)) {
+			w.mu.Unlock()
+			return
+		}
+		n := len(w.queue)
+		if n > w.opts.BatchSize {
+			n = w.opts.BatchSize
+		}
+		batch := append([]Point(nil), w.queue[:n]...)
+		droppedBefore := w.status.Dropped
+		w.mu.Unlock()
+
+		writeCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+		err := w.sink.Write(writeCtx, batch)
+		cancel()
+
+		w.mu.Lock()
+		if err != nil {
+			if w.backoff == 0 {
+				w.backoff = minBackoff
+			} else if w.backoff < maxBackoff {
+				w.backoff *= 2
+				if w.backoff > maxBackoff {
+					w.backoff = maxBackoff
+				}
+			}
+			w.retryAt = time.Now().Add(w.backoff)
+			if w.status.LastError == "" {
+				fmt.Printf("Warning: metrics sink %s failed, retrying in %s: %v\n", w.name, w.backoff, err)
+			}
+			w.status.LastError = err.Error()
+			w.mu.Unlock()
+			return
+		}
+
+		// 写出期间队列溢出时队首的本批采样已被部分丢弃，只移除剩余部分
+		if remaining := n - int(w.status.Dropped-droppedBefore); remaining > 0 {
+			w.queue = w.queue[remaining:]
+		}
+		if w.status.LastError != "" {
+			fmt.Printf("Metrics sink %s recovered\n", w.name)
+		}
+		w.backoff, w.retryAt = 0, time.Time{}
+		w.status.LastError = ""
+		w.status.LastSuccess = time.Now().Unix()
+		w.mu.Unlock()
+	}
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// statsdMaxPacket 单个UDP包的最大字节数，避免在常见MTU下分片
+const statsdMaxPacket = 1432
+
+// StatsdSink 以statsd gauge（DogStatsD标签扩展）通过UDP输出
+type StatsdSink struct {
+	address string
+	prefix  string
+}
+
+// NewStatsdSink 创建statsd输出，指标名为 prefix + "<name>.<field>"
+func NewStatsdSink(address, prefix string) *StatsdSink {
+	return &StatsdSink{address: address, prefix: prefix}
+}
+
+// Type 输出类型
+func (s *StatsdSink) Type() string { return "statsd" }
+
+// Write 将采样打包为多个UDP包发送
+func (s *StatsdSink) Write(ctx context.Context, points []Point) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", s.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var packet strings.Builder
+	send := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := conn.Write([]byte(packet.String()))
+		packet.Reset()
+		return err
+	}
+
+	for _, p := range points {
+		tags := statsdTags(p.Tags)
+		for _, field := range sortedKeys(p.Fields) {
+			line := s.prefix + p.Name + "." + field + ":" +
+				strconv.FormatFloat(p.Fields[field], 'f', -1, 64) + "|g" + tags
+			if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacket {
+				if err := send(); err != nil {
+					return err
+				}
+			}
+			if packet.Len() > 0 {
+				packet.WriteByte('\n')
+			}
+			packet.WriteString(line)
+		}
+	}
+	return send()
+}
+
+// statsdTags 生成 "|#key:value,..." 标签后缀
+func statsdTags(tags map[string]string) string {
+	var parts []string
+	for _, key := range sortedKeys(tags) {
+		if tags[key] != "" {
+			parts = append(parts, key+":"+tags[key])
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "|#" + strings.Join(parts, ",")
+}
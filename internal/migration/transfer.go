@@ -0,0 +1,167 @@
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ChecksumHeader 上传数据时携带的SHA-256摘要请求头，接收方据此校验
+const ChecksumHeader = "X-Content-SHA256"
+
+// namePattern 迁移ID与卷名
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// Endpoint 迁移数据的传输端点
+// 可以是目标节点Agent的导入地址（经隧道直连，Headers中携带目标Agent的认证头），
+// 也可以是对象存储的预签名URL
+type Endpoint struct {
+	URL     string            `json:"url" binding:"required"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// 下载时用于校验的SHA-256（可选）
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Artifact 已传输的数据
+type Artifact struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	SHA256    string `json:"sha256"`
+}
+
+// Transfer 迁移数据的暂存与传输
+// 数据先完整写入本地暂存目录再发送或使用：上传时可以提供Content-Length（对象存储预签名URL要求），
+// 接收时校验通过后才会加载，避免导入不完整的数据
+type Transfer struct {
+	spoolDir   string
+	httpClient *http.Client
+}
+
+// ValidateName 验证迁移ID或卷名
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	return nil
+}
+
+// NewTransfer 创建使用spoolDir暂存数据的传输器
+func NewTransfer(spoolDir string) (*Transfer, error) {
+	if err := os.MkdirAll(spoolDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create migration spool dir: %w", err)
+	}
+	return &Transfer{
+		spoolDir:   spoolDir,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// Upload 将produce写出的数据暂存后以PUT上传到端点
+func (t *Transfer) Upload(ctx context.Context, name string, endpoint Endpoint, produce func(io.Writer) error) (artifact Artifact, err error) {
+	file, err := os.CreateTemp(t.spoolDir, "upload-*")
+	if err != nil {
+		return artifact, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(file, hash)}
+	if err := produce(counter); err != nil {
+		return artifact, err
+	}
+	artifact = Artifact{Name: name, SizeBytes: counter.n, SHA256: hex.EncodeToString(hash.Sum(nil))}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return artifact, fmt.Errorf("failed to rewind spool file: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint.URL, file)
+	if err != nil {
+		return artifact, fmt.Errorf("invalid upload url: %w", err)
+	}
+	req.ContentLength = artifact.SizeBytes
+	req.Header.Set("Content-Type", "application/x-tar")
+	req.Header.Set(ChecksumHeader, artifact.SHA256)
+	for key, value := range endpoint.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return artifact, fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return artifact, fmt.Errorf("failed to upload %s: unexpected status %d: %s", name, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return artifact, nil
+}
+
+// Download 从端点下载数据，校验后交给consume
+func (t *Transfer) Download(ctx context.Context, name string, endpoint Endpoint, consume func(io.Reader) error) (Artifact, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.URL, nil)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("invalid download url: %w", err)
+	}
+	for key, value := range endpoint.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Artifact{}, fmt.Errorf("failed to download %s: unexpected status %d", name, resp.StatusCode)
+	}
+
+	return t.Receive(name, resp.Body, endpoint.SHA256, consume)
+}
+
+// Receive 暂存r中的数据并校验摘要（expectedSHA256为空时不校验），校验通过后交给consume
+func (t *Transfer) Receive(name string, r io.Reader, expectedSHA256 string, consume func(io.Reader) error) (artifact Artifact, err error) {
+	file, err := os.CreateTemp(t.spoolDir, "receive-*")
+	if err != nil {
+		return artifact, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), r)
+	if err != nil {
+		return artifact, fmt.Errorf("failed to receive %s: %w", name, err)
+	}
+	artifact = Artifact{Name: name, SizeBytes: size, SHA256: hex.EncodeToString(hash.Sum(nil))}
+
+	if expectedSHA256 != "" && !strings.EqualFold(expectedSHA256, artifact.SHA256) {
+		return artifact, fmt.Errorf("%s checksum mismatch: expected %s, got %s", name, expectedSHA256, artifact.SHA256)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return artifact, fmt.Errorf("failed to rewind spool file: %w", err)
+	}
+	return artifact, consume(file)
+}
+
+// countingWriter 统计写入字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,397 @@
+// Package mounts 在主机上挂载claim请求的外部存储（NFS导出、S3存储桶、SMB共享），
+// 再由容器管理器绑定挂载到容器中；claim释放时卸载并删除挂载点
+package mounts
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 外部存储类型
+const (
+	TypeNFS = "nfs"
+	TypeS3  = "s3"
+	TypeSMB = "smb"
+)
+
+// S3挂载工具
+const (
+	S3DriverS3FS   = "s3fs"
+	S3DriverRclone = "rclone"
+)
+
+// Types 支持的外部存储类型
+var Types = []string{TypeNFS, TypeS3, TypeSMB}
+
+// ErrTypeUnavailable 节点未允许该类型或未安装其挂载工具
+var ErrTypeUnavailable = errors.New("external volume type is not available on this node")
+
+var (
+	// nfsSourcePattern host:/export，IPv6地址需加方括号
+	nfsSourcePattern = regexp.MustCompile(`^(\[[0-9a-fA-F:]+\]|[A-Za-z0-9._-]+):/[^\s,]*$`)
+	// smbSourcePattern //server/share[/path]
+	smbSourcePattern = regexp.MustCompile(`^//[A-Za-z0-9._-]+/[^\s,/][^\s,]*$`)
+	// s3SourcePattern bucket[/prefix]
+	s3SourcePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9](/[^\s,]*)?$`)
+	// 挂载选项的取值
+	numberValue = regexp.MustCompile(`^[0-9]{1,10}$`)
+	modeValue   = regexp.MustCompile(`^0?[0-7]{3,4}$`)
+)
+
+// allowedOptions 各存储类型允许请求设置的挂载选项 -> 取值格式，nil表示不带值的选项
+// 只允许不涉及主机路径、凭据与访问权限的选项，其余选项由Agent设置
+var allowedOptions = map[string]map[string]*regexp.Regexp{
+	TypeNFS: {
+		"vers":    regexp.MustCompile(`^(3|4|4\.[0-2])$`),
+		"proto":   regexp.MustCompile(`^(tcp|udp|tcp6|udp6|rdma)$`),
+		"port":    numberValue,
+		"timeo":   numberValue,
+		"retrans": numberValue,
+		"rsize":   numberValue,
+		"wsize":   numberValue,
+		"hard":    nil,
+		"soft":    nil,
+	},
+	TypeSMB: {
+		"vers":      regexp.MustCompile(`^(1\.0|2\.0|2\.1|3|3\.0|3\.02|3\.1\.1|default)$`),
+		"sec":       regexp.MustCompile(`^(none|krb5i?|ntlmi?|ntlmv2i?|ntlmsspi?)$`),
+		"uid":       numberValue,
+		"gid":       numberValue,
+		"file_mode": modeValue,
+		"dir_mode":  modeValue,
+	},
+	TypeS3: {
+		"region": regexp.MustCompile(`^[a-z0-9-]{1,32}$`),
+		"uid":    numberValue,
+		"gid":    numberValue,
+		"umask":  modeValue,
+	},
+}
+
+// hardeningOptions 所有外部存储挂载都带有的选项，容器无法借助远端文件获得主机权限
+var hardeningOptions = []string{"nosuid", "nodev", "noexec"}
+
+// Volume 容器请求的外部存储卷
+type Volume struct {
+	// 存储类型：nfs、s3 或 smb
+	Type string `json:"type" binding:"required"`
+	// nfs为 host:/export，s3为 bucket 或 bucket/prefix，smb为 //server/share
+	Source string `json:"source" binding:"required"`
+	// 容器内挂载路径
+	MountPath string `json:"mount_path" binding:"required"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+	// S3兼容存储的服务地址，为空时使用AWS S3
+	Endpoint string `json:"endpoint,omitempty"`
+	// 附加的挂载选项（name 或 name=value），只能使用 allowedOptions 中该类型的选项
+	Options []string `json:"options,omitempty"`
+	// 使用节点加密公钥加密的凭据（与密钥下发相同的密封盒）：s3为 ACCESS_KEY_ID:SECRET_ACCESS_KEY，
+	// smb为 username:password（可带 DOMAIN\ 前缀）；为空时匿名访问
+	Credentials string `json:"credentials,omitempty"`
+}
+
+// Validate 验证外部存储卷声明
+func Validate(v Volume) error {
+	switch v.Type {
+	case TypeNFS:
+		if !nfsSourcePattern.MatchString(v.Source) {
+			return fmt.Errorf("invalid nfs source %q, must be host:/export", v.Source)
+		}
+		if v.Credentials != "" {
+			return fmt.Errorf("nfs volumes do not take credentials")
+		}
+	case TypeS3:
+		if !s3SourcePattern.MatchString(v.Source) {
+			return fmt.Errorf("invalid s3 source %q, must be bucket or bucket/prefix", v.Source)
+		}
+	case TypeSMB:
+		if !smbSourcePattern.MatchString(v.Source) {
+			return fmt.Errorf("invalid smb source %q, must be //server/share", v.Source)
+		}
+	default:
+		return fmt.Errorf("unknown volume type %q, must be one of nfs, s3, smb", v.Type)
+	}
+	if !path.IsAbs(v.MountPath) || path.Clean(v.MountPath) == "/" || strings.ContainsAny(v.MountPath, ":,") {
+		return fmt.Errorf("mount_path %q must be an absolute path other than /", v.MountPath)
+	}
+	if v.Endpoint != "" {
+		if v.Type != TypeS3 {
+			return fmt.Errorf("endpoint is only valid for s3 volumes")
+		}
+		u, err := url.Parse(v.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.ContainsAny(v.Endpoint, ", ") {
+			return fmt.Errorf("invalid endpoint %q, must be an http(s) URL", v.Endpoint)
+		}
+	}
+	for _, option := range v.Options {
+		name, value, hasValue := strings.Cut(option, "=")
+		pattern, allowed := allowedOptions[v.Type][name]
+		if !allowed {
+			return fmt.Errorf("mount option %q is not allowed for %s volumes", name, v.Type)
+		}
+		if (pattern == nil && hasValue) || (pattern != nil && (!hasValue || !pattern.MatchString(value))) {
+			return fmt.Errorf("invalid value for mount option %q", option)
+		}
+	}
+	return nil
+}
+
+// Provider 一种外部存储的挂载方式
+type Provider interface {
+	// Tool 挂载依赖的主机命令，未安装时该类型不可用
+	Tool() string
+	// Mount 将卷挂载到主机目录target；credentials为解密后的凭据（可能为空），需要凭据文件时写入tmpfs目录credDir
+	Mount(ctx context.Context, v Volume, target string, credentials []byte, credDir string) error
+}
+
+// Options 外部存储卷选项
+type Options struct {
+	// 允许的存储类型
+	Types []string
+	// S3挂载工具：s3fs 或 rclone
+	S3Driver string
+	// 单个卷的挂载时限
+	Timeout time.Duration
+}
+
+// Manager 管理主机上的外部存储挂载，挂载点为 dir/<owner>/<序号>
+type Manager struct {
+	mu        sync.Mutex
+	dir       string
+	timeout   time.Duration
+	providers map[string]Provider
+}
+
+// NewManager 创建挂载管理器，未安装挂载工具的类型不可用
+func NewManager(dir string, options Options) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create mount directory: %w", err)
+	}
+	m := &Manager{dir: dir, timeout: options.Timeout, providers: make(map[string]Provider)}
+	for _, t := range options.Types {
+		provider := newProvider(t, options.S3Driver)
+		if provider == nil {
+			return nil, fmt.Errorf("unknown external volume type %q", t)
+		}
+		if _, err := exec.LookPath(provider.Tool()); err != nil {
+			fmt.Printf("Warning: %s volumes disabled: %s is not installed\n", t, provider.Tool())
+			continue
+		}
+		m.providers[t] = provider
+	}
+	return m, nil
+}
+
+// newProvider 返回存储类型对应的挂载方式
+func newProvider(t, s3Driver string) Provider {
+	switch t {
+	case TypeNFS:
+		return nfsProvider{}
+	case TypeSMB:
+		return smbProvider{}
+	case TypeS3:
+		if s3Driver == S3DriverRclone {
+			return rcloneProvider{}
+		}
+		return s3fsProvider{}
+	default:
+		return nil
+	}
+}
+
+// Types 返回节点可挂载的存储类型
+func (m *Manager) Types() []string {
+	types := make([]string, 0, len(m.providers))
+	for t := range m.providers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Mount 将卷挂载到owner的第index个挂载点，返回主机路径
+func (m *Manager) Mount(ctx context.Context, owner string, index int, v Volume, credentials []byte, credDir string) (string, error) {
+	provider, ok := m.providers[v.Type]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrTypeUnavailable, v.Type)
+	}
+	if len(credentials) > 0 && credDir == "" {
+		return "", fmt.Errorf("credentials require secrets delivery")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	target := filepath.Join(m.dir, owner, strconv.Itoa(index))
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return "", fmt.Errorf("failed to create mount point: %w", err)
+	}
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+	if err := provider.Mount(ctx, v, target, credentials, credDir); err != nil {
+		// 挂载超时时可能已经挂载
+		unmount(target)
+		os.Remove(target)
+		return "", err
+	}
+	return target, nil
+}
+
+// Release 卸载owner的全部挂载并删除挂载点
+// 只删除空目录，卸载失败时不会删除远端存储中的数据
+func (m *Manager) Release(owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	root := filepath.Join(m.dir, owner)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+
+	mounted, err := mountsUnder(root)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, target := range mounted {
+		if err := unmount(target); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", target, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to unmount %s", strings.Join(failed, "; "))
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.Remove(filepath.Join(root, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return os.Remove(root)
+}
+
+// Owners 返回有挂载点的owner
+func (m *Manager) Owners() []string {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil
+	}
+	var owners []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			owners = append(owners, entry.Name())
+		}
+	}
+	return owners
+}
+
+// unmount 卸载target，失败时延迟卸载（仍有进程使用时在其退出后完成）
+func unmount(target string) error {
+	if err := exec.Command("umount", target).Run(); err == nil {
+		return nil
+	}
+	if output, err := exec.Command("umount", "-l", target).CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// mountsUnder 返回root之下的挂载点，按深度从深到浅排列
+func mountsUnder(root string) ([]string, error) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mount table: %w", err)
+	}
+	defer file.Close()
+
+	var targets []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		target := unescapeMountPath(fields[4])
+		if strings.HasPrefix(target, root+"/") && !slices.Contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mount table: %w", err)
+	}
+	sort.Slice(targets, func(i, j int) bool { return len(targets[i]) > len(targets[j]) })
+	return targets, nil
+}
+
+// unescapeMountPath 还原mountinfo中八进制转义的字符（如 \040 表示空格）
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// writeCredentialFile 将凭据写入credDir下仅root可读的临时文件，返回路径与删除函数
+func writeCredentialFile(credDir string, content string) (string, func(), error) {
+	file, err := os.CreateTemp(credDir, ".mount-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to write credentials: %w", err)
+	}
+	remove := func() { os.Remove(file.Name()) }
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		remove()
+		return "", nil, fmt.Errorf("failed to write credentials: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		remove()
+		return "", nil, fmt.Errorf("failed to write credentials: %w", err)
+	}
+	return file.Name(), remove, nil
+}
+
+// splitCredentials 拆分 name:secret 形式的凭据
+func splitCredentials(credentials []byte) (string, string, error) {
+	name, secret, found := strings.Cut(strings.TrimRight(string(credentials), "\r\n"), ":")
+	if !found || name == "" {
+		return "", "", fmt.Errorf("credentials must be in name:secret form")
+	}
+	return name, secret, nil
+}
+
+// run 执行挂载命令，失败时错误中包含命令输出
+func run(cmd *exec.Cmd) error {
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", filepath.Base(cmd.Path), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
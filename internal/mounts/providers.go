@@ -0,0 +1,149 @@
+package mounts
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// nfsProvider 通过内核NFS客户端挂载
+type nfsProvider struct{}
+
+// Tool 实现 Provider
+func (nfsProvider) Tool() string { return "mount.nfs" }
+
+// Mount 实现 Provider
+func (nfsProvider) Mount(ctx context.Context, v Volume, target string, _ []byte, _ string) error {
+	options := append([]string{}, hardeningOptions...)
+	if v.ReadOnly {
+		options = append(options, "ro")
+	}
+	options = append(options, v.Options...)
+	return run(exec.CommandContext(ctx, "mount", "-t", "nfs", "-o", strings.Join(options, ","), "--", v.Source, target))
+}
+
+// smbProvider 通过内核CIFS客户端挂载，凭据经临时凭据文件传递
+type smbProvider struct{}
+
+// Tool 实现 Provider
+func (smbProvider) Tool() string { return "mount.cifs" }
+
+// Mount 实现 Provider
+func (smbProvider) Mount(ctx context.Context, v Volume, target string, credentials []byte, credDir string) error {
+	options := append([]string{}, hardeningOptions...)
+	if v.ReadOnly {
+		options = append(options, "ro")
+	}
+	if len(credentials) > 0 {
+		user, password, err := splitCredentials(credentials)
+		if err != nil {
+			return err
+		}
+		content := ""
+		if domain, name, found := strings.Cut(user, `\`); found {
+			content += "domain=" + domain + "\n"
+			user = name
+		}
+		content += "username=" + user + "\npassword=" + password + "\n"
+		file, remove, err := writeCredentialFile(credDir, content)
+		if err != nil {
+			return err
+		}
+		// mount.cifs只在挂载时读取凭据文件
+		defer remove()
+		options = append(options, "credentials="+file)
+	} else {
+		options = append(options, "guest")
+	}
+	options = append(options, v.Options...)
+	return run(exec.CommandContext(ctx, "mount", "-t", "cifs", "-o", strings.Join(options, ","), "--", v.Source, target))
+}
+
+// s3fsProvider 通过s3fs（FUSE）挂载S3存储桶，凭据经临时密码文件传递
+type s3fsProvider struct{}
+
+// Tool 实现 Provider
+func (s3fsProvider) Tool() string { return "s3fs" }
+
+// Mount 实现 Provider
+func (s3fsProvider) Mount(ctx context.Context, v Volume, target string, credentials []byte, credDir string) error {
+	// 容器内的用户与docker守护进程都需要访问FUSE挂载
+	options := append([]string{"allow_other"}, hardeningOptions...)
+	if v.ReadOnly {
+		options = append(options, "ro")
+	}
+	if v.Endpoint != "" {
+		options = append(options, "url="+v.Endpoint, "use_path_request_style")
+	}
+	if len(credentials) > 0 {
+		if _, _, err := splitCredentials(credentials); err != nil {
+			return err
+		}
+		file, remove, err := writeCredentialFile(credDir, strings.TrimRight(string(credentials), "\r\n")+"\n")
+		if err != nil {
+			return err
+		}
+		// s3fs在启动时读取密码文件
+		defer remove()
+		options = append(options, "passwd_file="+file)
+	} else {
+		options = append(options, "public_bucket=1")
+	}
+	for _, option := range v.Options {
+		// s3fs的 endpoint 选项为区域
+		if region, found := strings.CutPrefix(option, "region="); found {
+			option = "endpoint=" + region
+		}
+		options = append(options, option)
+	}
+
+	bucket := v.Source
+	if name, prefix, found := strings.Cut(v.Source, "/"); found {
+		bucket = name + ":/" + prefix
+	}
+	return run(exec.CommandContext(ctx, "s3fs", bucket, target, "-o", strings.Join(options, ",")))
+}
+
+// rcloneFlags 请求的S3挂载选项对应的rclone参数，选项已由 Validate 限定在 allowedOptions 中
+var rcloneFlags = map[string]string{
+	"region": "--s3-region",
+	"uid":    "--uid",
+	"gid":    "--gid",
+	"umask":  "--umask",
+}
+
+// rcloneProvider 通过 rclone mount（FUSE）挂载S3存储桶，凭据经进程环境传递
+type rcloneProvider struct{}
+
+// Tool 实现 Provider
+func (rcloneProvider) Tool() string { return "rclone" }
+
+// Mount 实现 Provider
+func (rcloneProvider) Mount(ctx context.Context, v Volume, target string, credentials []byte, _ string) error {
+	args := []string{"mount", ":s3:" + v.Source, target, "--daemon", "--allow-other"}
+	if v.ReadOnly {
+		args = append(args, "--read-only")
+	}
+	if v.Endpoint != "" {
+		args = append(args, "--s3-provider=Other", "--s3-endpoint="+v.Endpoint)
+	}
+	for _, option := range hardeningOptions {
+		args = append(args, "--option", option)
+	}
+	for _, option := range v.Options {
+		name, value, _ := strings.Cut(option, "=")
+		args = append(args, rcloneFlags[name]+"="+value)
+	}
+
+	cmd := exec.CommandContext(ctx, "rclone", args...)
+	cmd.Env = os.Environ()
+	if len(credentials) > 0 {
+		accessKey, secretKey, err := splitCredentials(credentials)
+		if err != nil {
+			return err
+		}
+		cmd.Env = append(cmd.Env, "RCLONE_S3_ACCESS_KEY_ID="+accessKey, "RCLONE_S3_SECRET_ACCESS_KEY="+secretKey)
+	}
+	return run(cmd)
+}
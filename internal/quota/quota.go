@@ -0,0 +1,195 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 超出的资源
+const (
+	ResourceContainers = "containers"
+	ResourceGPUs       = "gpus"
+	ResourceDisk       = "disk_gb"
+	ResourceRate       = "creates_per_minute"
+)
+
+// rateWindow 创建速率的统计窗口
+const rateWindow = time.Minute
+
+// ErrExceeded 租户配额或创建速率超限
+var ErrExceeded = errors.New("tenant quota exceeded")
+
+// Limits 租户配额，0表示不限制
+type Limits struct {
+	MaxContainers       int `json:"max_containers,omitempty"`
+	MaxGPUs             int `json:"max_gpus,omitempty"`
+	MaxDiskGB           int `json:"max_disk_gb,omitempty"`
+	MaxCreatesPerMinute int `json:"max_creates_per_minute,omitempty"`
+}
+
+// Tenant 使用独立令牌访问API的子租户
+type Tenant struct {
+	Name   string
+	Token  string
+	Limits Limits
+}
+
+// Usage 租户的资源占用
+type Usage struct {
+	Containers int `json:"containers"`
+	GPUs       int `json:"gpus"`
+	DiskGB     int `json:"disk_gb"`
+	// 最近一分钟内的创建次数
+	CreatesLastMinute int `json:"creates_last_minute"`
+}
+
+// add 累加资源占用
+func (u Usage) add(other Usage) Usage {
+	return Usage{
+		Containers:        u.Containers + other.Containers,
+		GPUs:              u.GPUs + other.GPUs,
+		DiskGB:            u.DiskGB + other.DiskGB,
+		CreatesLastMinute: u.CreatesLastMinute + other.CreatesLastMinute,
+	}
+}
+
+// sub 扣减资源占用
+func (u Usage) sub(other Usage) Usage {
+	return Usage{
+		Containers: u.Containers - other.Containers,
+		GPUs:       u.GPUs - other.GPUs,
+		DiskGB:     u.DiskGB - other.DiskGB,
+	}
+}
+
+// ExceededError 配额超限的详细信息
+type ExceededError struct {
+	Tenant   string
+	Resource string
+	Limit    int
+	// 已占用（含正在创建的容器）与本次申请的数量
+	Used      int
+	Requested int
+	Usage     Usage
+	Limits    Limits
+	// 创建速率超限时距离可以再次创建的时间
+	RetryAfter time.Duration
+}
+
+// Error 实现error接口
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("tenant %s exceeded %s quota: used %d, requested %d, limit %d",
+		e.Tenant, e.Resource, e.Used, e.Requested, e.Limit)
+}
+
+// Unwrap 支持 errors.Is(err, ErrExceeded)
+func (e *ExceededError) Unwrap() error { return ErrExceeded }
+
+// Tracker 跟踪各租户正在创建的容器与创建速率
+// 已创建容器的占用由调用方根据容器标签统计，正在创建的容器在完成前计入预留，避免并发请求同时通过检查
+type Tracker struct {
+	mu      sync.Mutex
+	pending map[string]Usage
+	creates map[string][]time.Time
+}
+
+// NewTracker 创建配额跟踪器
+func NewTracker() *Tracker {
+	return &Tracker{
+		pending: make(map[string]Usage),
+		creates: make(map[string][]time.Time),
+	}
+}
+
+// Reserve 检查配额并预留本次申请的资源，current为租户已创建容器的占用
+// 成功时返回释放函数，调用方在创建完成（无论成败）后调用；超限时返回 *ExceededError
+func (t *Tracker) Reserve(tenant Tenant, current, requested Usage, count int) (release func(), err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	creates := t.recentLocked(tenant.Name, now)
+	usage := current.add(t.pending[tenant.Name])
+	usage.CreatesLastMinute = len(creates)
+
+	limits := tenant.Limits
+	exceeded := func(resource string, limit, used, rq int) *ExceededError {
+		return &ExceededError{
+			Tenant:    tenant.Name,
+			Resource:  resource,
+			Limit:     limit,
+			Used:      used,
+			Requested: rq,
+			Usage:     usage,
+			Limits:    limits,
+		}
+	}
+	if limits.MaxCreatesPerMinute > 0 && len(creates)+count > limits.MaxCreatesPerMinute {
+		e := exceeded(ResourceRate, limits.MaxCreatesPerMinute, len(creates), count)
+		if len(creates) > 0 {
+			e.RetryAfter = creates[0].Add(rateWindow).Sub(now)
+		} else {
+			e.RetryAfter = rateWindow
+		}
+		return nil, e
+	}
+	checks := []struct {
+		resource        string
+		limit, used, rq int
+	}{
+		{ResourceContainers, limits.MaxContainers, usage.Containers, requested.Containers},
+		{ResourceGPUs, limits.MaxGPUs, usage.GPUs, requested.GPUs},
+		{ResourceDisk, limits.MaxDiskGB, usage.DiskGB, requested.DiskGB},
+	}
+	for _, check := range checks {
+		if check.limit > 0 && check.used+check.rq > check.limit {
+			return nil, exceeded(check.resource, check.limit, check.used, check.rq)
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		creates = append(creates, now)
+	}
+	t.creates[tenant.Name] = creates
+	t.pending[tenant.Name] = t.pending[tenant.Name].add(requested)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if remaining := t.pending[tenant.Name].sub(requested); remaining != (Usage{}) {
+				t.pending[tenant.Name] = remaining
+			} else {
+				delete(t.pending, tenant.Name)
+			}
+		})
+	}, nil
+}
+
+// Usage 返回租户的资源占用，current为已创建容器的占用
+func (t *Tracker) Usage(tenant string, current Usage) Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	usage := current.add(t.pending[tenant])
+	usage.CreatesLastMinute = len(t.recentLocked(tenant, time.Now()))
+	return usage
+}
+
+// recentLocked 返回统计窗口内的创建时间并清理过期记录（调用方需持有锁）
+func (t *Tracker) recentLocked(tenant string, now time.Time) []time.Time {
+	creates := t.creates[tenant]
+	i := 0
+	for i < len(creates) && now.Sub(creates[i]) >= rateWindow {
+		i++
+	}
+	creates = creates[i:]
+	if len(creates) == 0 {
+		delete(t.creates, tenant)
+		return nil
+	}
+	t.creates[tenant] = creates
+	return creates
+}
@@ -0,0 +1,211 @@
+// Package redact 遮盖名称匹配敏感模式的环境变量、标签与元数据的值，
+// 避免令牌、密钥等凭据出现在API响应、事件与日志中
+package redact
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Mask 替换敏感值的文本
+const Mask = "[REDACTED]"
+
+// DefaultPatterns 默认的敏感名称模式
+var DefaultPatterns = []string{"*TOKEN*", "*SECRET*", "*KEY*", "*PASSWORD*"}
+
+// Redactor 按名称模式遮盖敏感值，nil表示不遮盖
+type Redactor struct {
+	patterns []string
+}
+
+// New 创建遮盖器，模式为不区分大小写的通配符（如 *TOKEN*）
+func New(patterns []string) (*Redactor, error) {
+	r := &Redactor{patterns: make([]string, 0, len(patterns))}
+	for _, pattern := range patterns {
+		upper := strings.ToUpper(pattern)
+		if _, err := path.Match(upper, ""); err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, upper)
+	}
+	return r, nil
+}
+
+// Sensitive 名称是否匹配任一敏感模式
+func (r *Redactor) Sensitive(name string) bool {
+	if r == nil || name == "" {
+		return false
+	}
+	upper := strings.ToUpper(name)
+	for _, pattern := range r.patterns {
+		if matched, _ := path.Match(pattern, upper); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Env 返回遮盖后的 NAME=value 形式的环境变量副本
+func (r *Redactor) Env(env []string) []string {
+	if r == nil || env == nil {
+		return env
+	}
+	result := make([]string, len(env))
+	for i, entry := range env {
+		if name, _, found := strings.Cut(entry, "="); found && r.Sensitive(name) {
+			entry = name + "=" + Mask
+		}
+		result[i] = entry
+	}
+	return result
+}
+
+// Labels 返回遮盖后的标签副本，没有需遮盖的值时返回原map
+func (r *Redactor) Labels(labels map[string]string) map[string]string {
+	if r == nil {
+		return labels
+	}
+	var result map[string]string
+	for key := range labels {
+		if !r.Sensitive(key) {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]string, len(labels))
+			for k, v := range labels {
+				result[k] = v
+			}
+		}
+		result[key] = Mask
+	}
+	if result == nil {
+		return labels
+	}
+	return result
+}
+
+// Map 返回遮盖后的副本：键匹配敏感模式的值整体遮盖，嵌套的map与数组递归处理，字符串按 Text 处理
+func (r *Redactor) Map(values map[string]interface{}) map[string]interface{} {
+	if r == nil || values == nil {
+		return values
+	}
+	result := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if r.Sensitive(key) {
+			result[key] = Mask
+			continue
+		}
+		result[key] = r.value(value)
+	}
+	return result
+}
+
+// value 遮盖任意JSON值
+func (r *Redactor) value(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return r.Map(v)
+	case map[string]string:
+		return r.Labels(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = r.value(item)
+		}
+		return result
+	case []string:
+		return r.Env(v)
+	case string:
+		return r.Text(v)
+	default:
+		return value
+	}
+}
+
+var (
+	// assignmentPattern 文本中的 NAME=value
+	assignmentPattern = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_.-]*)=("[^"]*"|'[^']*'|[^\s"',;&]+)`)
+	// jsonFieldPattern 文本中的 "name": "value"
+	jsonFieldPattern = regexp.MustCompile(`"([^"\\]+)"(\s*:\s*)"(?:[^"\\]|\\.)*"`)
+)
+
+// Text 遮盖文本（日志、错误信息）中名称敏感的 NAME=value 与 "name": "value"
+func (r *Redactor) Text(text string) string {
+	if r == nil || !strings.ContainsAny(text, "=:") {
+		return text
+	}
+	text = assignmentPattern.ReplaceAllStringFunc(text, func(match string) string {
+		name, _, _ := strings.Cut(match, "=")
+		if !r.Sensitive(name) {
+			return match
+		}
+		return name + "=" + Mask
+	})
+	return jsonFieldPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := jsonFieldPattern.FindStringSubmatch(match)
+		if !r.Sensitive(groups[1]) {
+			return match
+		}
+		return `"` + groups[1] + `"` + groups[2] + `"` + Mask + `"`
+	})
+}
+
+// std 全局遮盖器，用于日志
+var std atomic.Pointer[Redactor]
+
+func init() {
+	r, _ := New(DefaultPatterns)
+	std.Store(r)
+}
+
+// Default 返回全局遮盖器，关闭遮盖时为nil
+func Default() *Redactor {
+	return std.Load()
+}
+
+// SetDefault 设置全局遮盖器，nil表示关闭遮盖
+func SetDefault(r *Redactor) {
+	std.Store(r)
+}
+
+// Formatter 包装logrus的格式化器，按全局遮盖器遮盖消息与字段
+func Formatter(inner logrus.Formatter) logrus.Formatter {
+	return &formatter{inner: inner}
+}
+
+// formatter 遮盖敏感值的logrus格式化器
+type formatter struct {
+	inner logrus.Formatter
+}
+
+// Format 实现 logrus.Formatter
+func (f *formatter) Format(e *logrus.Entry) ([]byte, error) {
+	r := Default()
+	if r == nil {
+		return f.inner.Format(e)
+	}
+	redacted := *e
+	redacted.Message = r.Text(e.Message)
+	if len(e.Data) > 0 {
+		redacted.Data = make(logrus.Fields, len(e.Data))
+		for key, value := range e.Data {
+			switch {
+			case r.Sensitive(key):
+				redacted.Data[key] = Mask
+			case key == logrus.ErrorKey:
+				if err, ok := value.(error); ok {
+					value = r.Text(err.Error())
+				}
+				redacted.Data[key] = value
+			default:
+				redacted.Data[key] = r.value(value)
+			}
+		}
+	}
+	return f.inner.Format(&redacted)
+}
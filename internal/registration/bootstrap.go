@@ -0,0 +1,147 @@
+package registration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"utopia-node-agent/internal/config"
+)
+
+// 引导令牌来源
+const (
+	TokenSourceNone     = "none"
+	TokenSourceFile     = "file"
+	TokenSourceMetadata = "metadata"
+	TokenSourceConfig   = "config"
+)
+
+// metadataTimeout 访问实例元数据服务的超时
+const metadataTimeout = 5 * time.Second
+
+// BootstrapToken 注册使用的引导令牌
+// 令牌内容不会出现在String、错误信息或日志中
+type BootstrapToken struct {
+	value  string
+	Source string
+	path   string
+}
+
+// String 只输出令牌来源，防止令牌被写入日志
+func (t *BootstrapToken) String() string {
+	if t.path != "" {
+		return fmt.Sprintf("bootstrap token from %s %s", t.Source, t.path)
+	}
+	return "bootstrap token from " + t.Source
+}
+
+// Redact 将文本中出现的令牌替换为占位符
+func (t *BootstrapToken) Redact(text string) string {
+	if t.value == "" {
+		return text
+	}
+	return strings.ReplaceAll(text, t.value, "[REDACTED]")
+}
+
+// Consume 注册成功后作废令牌：删除一次性令牌文件，无法删除时清空其内容
+func (t *BootstrapToken) Consume() error {
+	if t.Source != TokenSourceFile {
+		return nil
+	}
+	if err := os.Remove(t.path); err == nil || os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.WriteFile(t.path, nil, 0600); err != nil {
+		return fmt.Errorf("failed to invalidate bootstrap token file %s: %w", t.path, err)
+	}
+	return nil
+}
+
+// ResolveBootstrapToken 按令牌文件、实例元数据服务、配置文件的顺序获取引导令牌
+// 令牌文件不存在或为空时视为已被消费，继续尝试其他来源；都未配置时返回空令牌
+func ResolveBootstrapToken(ctx context.Context, cfg config.CentralPlatformConfig) (*BootstrapToken, error) {
+	if path := cfg.BootstrapTokenFile; path != "" {
+		info, err := os.Stat(path)
+		switch {
+		case err == nil && info.Size() > 0:
+			value, err := config.ReadSecretFile(path)
+			if err != nil {
+				return nil, err
+			}
+			return &BootstrapToken{value: value, Source: TokenSourceFile, path: path}, nil
+		case err != nil && !errors.Is(err, os.ErrNotExist):
+			return nil, fmt.Errorf("failed to stat bootstrap token file: %w", err)
+		}
+	}
+
+	if cfg.BootstrapTokenURL != "" {
+		value, err := fetchMetadataToken(ctx, cfg.BootstrapTokenURL, cfg.BootstrapTokenHeaders)
+		if err != nil {
+			return nil, err
+		}
+		return &BootstrapToken{value: value, Source: TokenSourceMetadata}, nil
+	}
+
+	if cfg.BootstrapToken != "" {
+		return &BootstrapToken{value: cfg.BootstrapToken, Source: TokenSourceConfig}, nil
+	}
+	return &BootstrapToken{Source: TokenSourceNone}, nil
+}
+
+// fetchMetadataToken 从实例元数据服务读取引导令牌
+func fetchMetadataToken(ctx context.Context, url string, headers map[string]string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create metadata request: %w", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query instance metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 响应体可能就是令牌，错误信息中不包含响应内容
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("instance metadata returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", fmt.Errorf("failed to read instance metadata: %w", err)
+	}
+	value := strings.TrimSpace(string(body))
+	if value == "" {
+		return "", fmt.Errorf("instance metadata returned an empty bootstrap token")
+	}
+	return value, nil
+}
+
+// 节点注册状态
+const (
+	StateRegistered = "registered"
+	// 启动时平台不可达，以独立模式运行并在后台重试注册
+	StatePending = "pending"
+)
+
+// Status 节点注册状态
+type Status struct {
+	State string `json:"state"`
+	// 注册尝试次数（含启动时的首次尝试）
+	Attempts      int    `json:"attempts,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	LastAttemptAt int64  `json:"last_attempt_at,omitempty"`
+	NextAttemptAt int64  `json:"next_attempt_at,omitempty"`
+	// 以独立模式启动后注册成功的时间
+	RegisteredAt int64 `json:"registered_at,omitempty"`
+}
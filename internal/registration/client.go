@@ -2,14 +2,28 @@ package registration
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"utopia-node-agent/internal/accounting"
+	"utopia-node-agent/internal/config"
+	"utopia-node-agent/internal/container"
+	"utopia-node-agent/internal/events"
+	"utopia-node-agent/internal/gpu"
+	"utopia-node-agent/internal/identity"
+	"utopia-node-agent/internal/logship"
+	"utopia-node-agent/internal/system"
+	"utopia-node-agent/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // RegisterRequest 注册请求
@@ -17,6 +31,10 @@ type RegisterRequest struct {
 	MachineID      string `json:"machine_id"`
 	Hostname       string `json:"hostname"`
 	BootstrapToken string `json:"bootstrap_token,omitempty"`
+	// 节点加密公钥（X25519，base64），平台用其加密下发的密钥
+	EncryptionPublicKey string `json:"encryption_public_key,omitempty"`
+	// 节点身份公钥（Ed25519，base64），平台用其校验节点请求的签名
+	IdentityPublicKey string `json:"identity_public_key,omitempty"`
 }
 
 // RegisterResponse 注册响应
@@ -24,12 +42,57 @@ type RegisterResponse struct {
 	NodeID    int64  `json:"node_id"`
 	Message   string `json:"message"`
 	Timestamp int64  `json:"timestamp"`
+	// 平台为节点ID与身份公钥签发的身份证明，保存在身份文件中
+	IdentityCertificate string `json:"identity_certificate,omitempty"`
+}
+
+// HeartbeatRequest 心跳请求
+type HeartbeatRequest struct {
+	NodeID         string                `json:"node_id"`
+	Timestamp      int64                 `json:"timestamp"`
+	GPUs           []gpu.GPUInfo         `json:"gpus"`
+	System         *system.SystemMetrics `json:"system,omitempty"`
+	ContainerCount int                   `json:"container_count"`
+	Versions       *system.VersionInfo   `json:"versions,omitempty"`
+	// GPU功能不可用（仅CPU降级模式）的原因
+	GPUUnavailableReason string `json:"gpu_unavailable_reason,omitempty"`
+	// 可用的容器运行时类别（runc、gvisor、kata）
+	Runtimes []string `json:"runtimes,omitempty"`
+	// 可挂载的外部存储类型（nfs、s3、smb）
+	ExternalVolumeTypes []string `json:"external_volume_types,omitempty"`
+	// GPU时间片共享的份额与余量，平台据此售卖份额GPU
+	TimeSlicing *container.TimeSlicingStatus `json:"time_slicing,omitempty"`
+	// 节点加密公钥
+	EncryptionPublicKey string `json:"encryption_public_key,omitempty"`
+	// 节点身份公钥，从旧版身份文件升级的节点由此向平台登记公钥
+	IdentityPublicKey string `json:"identity_public_key,omitempty"`
+	// 已应用的配置补丁版本
+	ConfigVersion int64 `json:"config_version"`
+	// 最近一次被拒绝的配置补丁版本及原因
+	RejectedConfigVersion int64  `json:"rejected_config_version,omitempty"`
+	ConfigError           string `json:"config_error,omitempty"`
+	// 自上次心跳以来的节点事件
+	Events []events.Event `json:"events,omitempty"`
+	// 运维配置的节点标签
+	Labels map[string]string `json:"labels,omitempty"`
+	// 为宿主机系统与Agent保留、不参与调度的资源
+	SystemReserved container.SystemReservation `json:"system_reserved"`
+}
+
+// HeartbeatResponse 心跳响应
+type HeartbeatResponse struct {
+	Message     string              `json:"message,omitempty"`
+	ConfigPatch *config.ConfigPatch `json:"config_patch,omitempty"`
+	// 平台推送的签名密钥包（签名密钥轮换时下发）
+	SigningKeys string `json:"signing_keys,omitempty"`
 }
 
 // Client 注册客户端
 type Client struct {
 	apiURL     string
 	httpClient *http.Client
+	// 对请求签名的节点身份
+	identity atomic.Pointer[identity.Identity]
 }
 
 // NewClient 创建新的注册客户端
@@ -42,6 +105,11 @@ func NewClient(apiURL string) *Client {
 	}
 }
 
+// SetIdentity 设置对发往平台的请求签名的节点身份
+func (c *Client) SetIdentity(id *identity.Identity) {
+	c.identity.Store(id)
+}
+
 // GetMachineID 获取机器ID
 func GetMachineID() (string, error) {
 	// 尝试从 /etc/machine-id 读取
@@ -74,62 +142,164 @@ func readMachineIDFromFile(path string) (string, error) {
 	return machineID, nil
 }
 
-// LoadNodeID 从文件加载节点ID
-func LoadNodeID(filePath string) (string, error) {
-	data, err := os.ReadFile(filePath)
+// Register 向中央平台注册节点
+// 错误信息中的令牌会被替换，避免平台回显的令牌进入日志
+func (c *Client) Register(ctx context.Context, token *BootstrapToken, hostname, encryptionPublicKey string) (*RegisterResponse, error) {
+	req := RegisterRequest{
+		Hostname:            hostname,
+		BootstrapToken:      token.value,
+		EncryptionPublicKey: encryptionPublicKey,
+		IdentityPublicKey:   c.identity.Load().PublicKey(),
+	}
+
+	body, err := c.do(ctx, http.MethodPost, "/api/nodes/register", req)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return "", nil // 文件不存在，返回空字符串
+		return nil, fmt.Errorf("registration failed: %s", token.Redact(err.Error()))
+	}
+
+	var registerResp RegisterResponse
+	if err := json.Unmarshal(body, &registerResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return &registerResp, nil
+}
+
+// Heartbeat 向中央平台上报心跳并获取待应用的配置补丁
+func (c *Client) Heartbeat(ctx context.Context, req *HeartbeatRequest) (*HeartbeatResponse, error) {
+	body, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/nodes/%s/heartbeat", req.NodeID), req)
+	if err != nil {
+		return nil, fmt.Errorf("heartbeat failed: %w", err)
+	}
+
+	var heartbeatResp HeartbeatResponse
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &heartbeatResp); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 		}
-		return "", fmt.Errorf("failed to read node ID file: %w", err)
 	}
 
-	nodeID := strings.TrimSpace(string(data))
-	return nodeID, nil
+	return &heartbeatResp, nil
 }
 
-// SaveNodeID 保存节点ID到文件
-func SaveNodeID(filePath string, nodeID int64) error {
-	// 确保目录存在
-	dir := filepath.Dir(filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+// UpdateAddress 更新平台记录的节点主机名与IP地址
+func (c *Client) UpdateAddress(ctx context.Context, nodeID string, addr system.NodeAddress) error {
+	if _, err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/api/nodes/%s", nodeID), addr); err != nil {
+		return fmt.Errorf("address update failed: %w", err)
 	}
+	return nil
+}
 
-	// 原子写入
-	tmpFile := filePath + ".tmp"
-	nodeIDStr := fmt.Sprintf("%d", nodeID)
-	if err := os.WriteFile(tmpFile, []byte(nodeIDStr), 0644); err != nil {
-		return fmt.Errorf("failed to write temp file: %w", err)
+// SigningKeysResponse 签名密钥包响应
+type SigningKeysResponse struct {
+	Bundle string `json:"bundle"`
+}
+
+// SigningKeys 获取平台当前的签名密钥包
+func (c *Client) SigningKeys(ctx context.Context, nodeID string) (string, error) {
+	body, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/nodes/%s/signing-keys", nodeID), nil)
+	if err != nil {
+		return "", fmt.Errorf("signing keys request failed: %w", err)
 	}
 
-	if err := os.Rename(tmpFile, filePath); err != nil {
-		os.Remove(tmpFile) // 清理临时文件
-		return fmt.Errorf("failed to move temp file: %w", err)
+	var resp SigningKeysResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
 	}
+	return resp.Bundle, nil
+}
 
+// Deregister 通知中央平台节点已退役
+func (c *Client) Deregister(ctx context.Context, nodeID string) error {
+	if _, err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/nodes/%s", nodeID), nil); err != nil {
+		return fmt.Errorf("deregistration failed: %w", err)
+	}
 	return nil
 }
 
-// Register 向中央平台注册节点
-func (c *Client) Register(bootstrapToken, hostname string) (*RegisterResponse, error) {
-	req := RegisterRequest{
-		Hostname:       hostname,
-		BootstrapToken: bootstrapToken,
+// OfflineReasonShutdown agent停止导致的下线
+const OfflineReasonShutdown = "shutdown"
+
+// OfflineNotice 节点下线通知
+type OfflineNotice struct {
+	NodeID    string `json:"node_id"`
+	Timestamp int64  `json:"timestamp"`
+	Reason    string `json:"reason"`
+	// 预计停机时长（秒），0表示未知
+	ExpectedDurationSeconds int64  `json:"expected_duration_seconds,omitempty"`
+	Message                 string `json:"message,omitempty"`
+}
+
+// NotifyOffline 通知平台节点即将下线，平台据此标记节点并停止调度claim，而不必等待心跳超时
+func (c *Client) NotifyOffline(ctx context.Context, notice *OfflineNotice) error {
+	if _, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/nodes/%s/offline", notice.NodeID), notice); err != nil {
+		return fmt.Errorf("offline notice failed: %w", err)
 	}
+	return nil
+}
 
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+// UsageReport 计费记录上报请求
+type UsageReport struct {
+	NodeID  string              `json:"node_id"`
+	Records []accounting.Record `json:"records"`
+}
+
+// ReportUsage 上报计费记录，平台按记录ID去重
+func (c *Client) ReportUsage(ctx context.Context, nodeID string, records []accounting.Record) error {
+	report := UsageReport{NodeID: nodeID, Records: records}
+	if _, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/nodes/%s/usage", nodeID), report); err != nil {
+		return fmt.Errorf("usage report failed: %w", err)
+	}
+	return nil
+}
+
+// LogReport 容器日志上报请求
+type LogReport struct {
+	NodeID  string          `json:"node_id"`
+	Entries []logship.Entry `json:"entries"`
+}
+
+// ShipLogs 上报一批容器日志
+func (c *Client) ShipLogs(ctx context.Context, nodeID string, entries []logship.Entry) error {
+	report := LogReport{NodeID: nodeID, Entries: entries}
+	if _, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/nodes/%s/logs", nodeID), report); err != nil {
+		return fmt.Errorf("log report failed: %w", err)
 	}
+	return nil
+}
 
-	resp, err := c.httpClient.Post(
-		c.apiURL+"/api/nodes/register",
-		"application/json",
-		bytes.NewBuffer(jsonData),
+// do 发送JSON请求到平台并返回响应体，非2xx状态码视为错误
+func (c *Client) do(ctx context.Context, method, path string, payload interface{}) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, method+" "+path,
+		attribute.String("http.method", method),
+		attribute.String("http.url", c.apiURL+path),
 	)
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	var reqBody io.Reader
+	var jsonData []byte
+	if payload != nil {
+		jsonData, err = json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send registration request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	tracing.InjectHTTP(ctx, req)
+	c.identity.Load().SignRequest(req, jsonData)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -138,16 +308,33 @@ func (c *Client) Register(bootstrapToken, hostname string) (*RegisterResponse, e
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("registration failed with status %d: %s", resp.StatusCode, string(body))
+		err = &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+		return nil, err
 	}
 
-	var registerResp RegisterResponse
-	if err := json.Unmarshal(body, &registerResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
+	return body, nil
+}
 
-	return &registerResp, nil
+// StatusError 平台返回的非2xx响应
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Body)
+}
+
+// IsRejected 平台是否明确拒绝了请求（4xx，超时与限流除外），重试相同的请求不会成功
+func IsRejected(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	code := statusErr.StatusCode
+	return code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
 }
 
 func GetHostname() (string, error) {
@@ -0,0 +1,130 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// namePattern 密钥名称
+	namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,127}$`)
+	// envPattern 环境变量名
+	envPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Secret 平台下发的加密密钥
+// 密文为使用节点加密公钥生成的 NaCl 匿名密封盒（crypto_box_seal），base64编码
+type Secret struct {
+	Name       string `json:"name" binding:"required"`
+	Ciphertext string `json:"ciphertext" binding:"required"`
+	// 以环境变量注入时的变量名
+	Env string `json:"env,omitempty"`
+	// 以文件注入时容器内的绝对路径
+	Path string `json:"path,omitempty"`
+}
+
+// Validate 验证密钥声明，Env与Path必须且只能设置一个
+func (s *Secret) Validate() error {
+	if !namePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid secret name %q", s.Name)
+	}
+	if (s.Env == "") == (s.Path == "") {
+		return fmt.Errorf("secret %s must set exactly one of env or path", s.Name)
+	}
+	if s.Env != "" && !envPattern.MatchString(s.Env) {
+		return fmt.Errorf("secret %s: invalid env name %q", s.Name, s.Env)
+	}
+	if s.Path != "" && (!path.IsAbs(s.Path) || path.Clean(s.Path) == "/") {
+		return fmt.Errorf("secret %s: path %q must be an absolute file path", s.Name, s.Path)
+	}
+	return nil
+}
+
+// Keypair 节点加密密钥对（X25519）
+type Keypair struct {
+	publicKey  *[32]byte
+	privateKey *[32]byte
+}
+
+// LoadOrCreateKeypair 从文件加载密钥对，文件不存在时生成并以0600权限保存
+func LoadOrCreateKeypair(keyPath string) (*Keypair, error) {
+	data, err := os.ReadFile(keyPath)
+	if err == nil {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(raw) != 32 {
+			return nil, fmt.Errorf("invalid encryption key file %s", keyPath)
+		}
+		var privateKey, publicKey [32]byte
+		copy(privateKey[:], raw)
+		pub, err := curve25519.X25519(privateKey[:], curve25519.Basepoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key: %w", err)
+		}
+		copy(publicKey[:], pub)
+		return &Keypair{publicKey: &publicKey, privateKey: &privateKey}, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read encryption key: %w", err)
+	}
+
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	tmpFile := keyPath + ".tmp"
+	if err := os.WriteFile(tmpFile, []byte(base64.StdEncoding.EncodeToString(privateKey[:])), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write encryption key: %w", err)
+	}
+	if err := os.Rename(tmpFile, keyPath); err != nil {
+		os.Remove(tmpFile)
+		return nil, fmt.Errorf("failed to write encryption key: %w", err)
+	}
+
+	return &Keypair{publicKey: publicKey, privateKey: privateKey}, nil
+}
+
+// PublicKey 返回base64编码的公钥，随注册与心跳上报平台
+func (k *Keypair) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(k.publicKey[:])
+}
+
+// Open 解密平台下发的密文
+func (k *Keypair) Open(ciphertext string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext encoding: %w", err)
+	}
+	plaintext, ok := box.OpenAnonymous(nil, sealed, k.publicKey, k.privateKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to decrypt secret: not sealed for this node")
+	}
+	return plaintext, nil
+}
+
+// EnsureMemoryBacked 确保dir位于tmpfs上，避免明文落盘
+func EnsureMemoryBacked(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	var stat unix.Statfs_t
+	if err := unix.Statfs(dir, &stat); err != nil {
+		return fmt.Errorf("failed to stat secrets directory: %w", err)
+	}
+	if stat.Type != unix.TMPFS_MAGIC && stat.Type != unix.RAMFS_MAGIC {
+		return fmt.Errorf("secrets directory %s is not on tmpfs", dir)
+	}
+	return nil
+}
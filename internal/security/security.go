@@ -0,0 +1,169 @@
+package security
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ProfileName 租户容器AppArmor配置名称
+const ProfileName = "utopia-container"
+
+//go:embed utopia-container.apparmor
+var apparmorProfile []byte
+
+// 强制访问控制机制
+const (
+	MechanismAppArmor = "apparmor"
+	MechanismSELinux  = "selinux"
+	MechanismNone     = "none"
+)
+
+// 创建请求可申请的限制放宽项
+const (
+	// RelaxUnconfined 不应用AppArmor/SELinux配置
+	RelaxUnconfined = "unconfined"
+	// RelaxSeccompUnconfined 禁用docker默认的seccomp过滤
+	RelaxSeccompUnconfined = "seccomp-unconfined"
+)
+
+// Status 容器强制访问控制的生效情况
+type Status struct {
+	// 生效的机制：apparmor、selinux 或 none
+	Mechanism string `json:"mechanism"`
+	// 新建容器是否默认受加固配置约束
+	Enforced bool `json:"enforced"`
+	// AppArmor配置名称或SELinux进程类型
+	Profile string `json:"profile,omitempty"`
+	// SELinux模式：enforcing 或 permissive
+	SELinuxMode string `json:"selinux_mode,omitempty"`
+	// 未强制的原因
+	Reason string `json:"reason,omitempty"`
+}
+
+// ValidateRelaxation 检查放宽项名称是否有效
+func ValidateRelaxation(name string) error {
+	switch name {
+	case RelaxUnconfined, RelaxSeccompUnconfined:
+		return nil
+	}
+	return fmt.Errorf("unknown security relaxation %q, must be one of %s, %s", name, RelaxUnconfined, RelaxSeccompUnconfined)
+}
+
+// Setup 检测主机的强制访问控制机制：AppArmor主机上写入并加载内置配置，
+// SELinux主机上使用selinuxType（为空时为docker默认的 container_t）
+func Setup(ctx context.Context, profileDir, selinuxType string) Status {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	dockerOptions, err := dockerSecurityOptions(ctx)
+	if err != nil {
+		return Status{Mechanism: MechanismNone, Reason: err.Error()}
+	}
+
+	switch {
+	case appArmorEnabled():
+		status := Status{Mechanism: MechanismAppArmor, Profile: ProfileName}
+		if !dockerOptions[MechanismAppArmor] {
+			status.Reason = "docker daemon does not report apparmor support"
+			return status
+		}
+		if err := loadAppArmorProfile(ctx, profileDir); err != nil {
+			status.Reason = err.Error()
+			return status
+		}
+		status.Enforced = true
+		return status
+
+	case selinuxMode() != "":
+		status := Status{Mechanism: MechanismSELinux, Profile: selinuxType, SELinuxMode: selinuxMode()}
+		if status.Profile == "" {
+			status.Profile = "container_t"
+		}
+		if !dockerOptions[MechanismSELinux] {
+			status.Reason = "docker daemon is not running with --selinux-enabled"
+			return status
+		}
+		if status.SELinuxMode != "enforcing" {
+			status.Reason = "selinux is in permissive mode"
+			return status
+		}
+		status.Enforced = true
+		return status
+	}
+
+	return Status{Mechanism: MechanismNone, Reason: "neither apparmor nor selinux is enabled on this host"}
+}
+
+// appArmorEnabled 判断内核是否启用了AppArmor
+func appArmorEnabled() bool {
+	data, err := os.ReadFile("/sys/module/apparmor/parameters/enabled")
+	return err == nil && strings.TrimSpace(string(data)) == "Y"
+}
+
+// selinuxMode 返回SELinux模式，未启用时返回空字符串
+func selinuxMode() string {
+	data, err := os.ReadFile("/sys/fs/selinux/enforce")
+	if err != nil {
+		return ""
+	}
+	if strings.TrimSpace(string(data)) == "1" {
+		return "enforcing"
+	}
+	return "permissive"
+}
+
+// dockerSecurityOptions 返回docker守护进程启用的安全机制（name=apparmor 等）
+func dockerSecurityOptions(ctx context.Context) (map[string]bool, error) {
+	output, err := exec.CommandContext(ctx, "docker", "info", "--format", "{{json .SecurityOptions}}").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query docker info: %w", err)
+	}
+
+	var options []string
+	if err := json.Unmarshal(output, &options); err != nil {
+		return nil, fmt.Errorf("failed to parse docker security options: %w", err)
+	}
+
+	result := make(map[string]bool)
+	for _, option := range options {
+		for _, field := range strings.Split(option, ",") {
+			if name, found := strings.CutPrefix(field, "name="); found {
+				result[name] = true
+			}
+		}
+	}
+	return result, nil
+}
+
+// loadAppArmorProfile 写入内置配置并加载（已加载时替换），随后确认其处于enforce模式
+func loadAppArmorProfile(ctx context.Context, profileDir string) error {
+	if err := os.MkdirAll(profileDir, 0755); err != nil {
+		return fmt.Errorf("failed to create apparmor profile directory: %w", err)
+	}
+	path := filepath.Join(profileDir, ProfileName)
+	if err := os.WriteFile(path, apparmorProfile, 0644); err != nil {
+		return fmt.Errorf("failed to write apparmor profile: %w", err)
+	}
+
+	if output, err := exec.CommandContext(ctx, "apparmor_parser", "-r", "-W", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to load apparmor profile: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	data, err := os.ReadFile("/sys/kernel/security/apparmor/profiles")
+	if err != nil {
+		return fmt.Errorf("failed to read loaded apparmor profiles: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == ProfileName+" (enforce)" {
+			return nil
+		}
+	}
+	return fmt.Errorf("apparmor profile %s is not loaded in enforce mode", ProfileName)
+}
@@ -0,0 +1,311 @@
+package signing
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrInvalidBundle 密钥包格式或签名无效
+	ErrInvalidBundle = errors.New("invalid signed key bundle")
+	// ErrStaleBundle 密钥包版本不高于已缓存的版本
+	ErrStaleBundle = errors.New("key bundle is not newer than the cached bundle")
+)
+
+// missRefreshInterval 因未知签名密钥触发的拉取之间的最小间隔，避免伪造令牌放大到平台请求
+const missRefreshInterval = time.Minute
+
+// fetchTimeout 单次拉取密钥包的时限
+const fetchTimeout = 10 * time.Second
+
+// bundleSigningContext 密钥包签名的上下文前缀，与令牌签名区分，
+// 根公钥对令牌等其他内容的签名不能被当作密钥轮换
+const bundleSigningContext = "utopia-key-bundle\x00"
+
+// KeyBundle 平台签发的验证公钥集合
+//
+// 格式为 base64url(bundle JSON) + "." + base64url(ed25519签名)，签名覆盖 bundleSigningContext 与第一段，
+// 新的密钥包必须由当前受信任的公钥（配置的根公钥或已缓存密钥包中的公钥）签名，且版本递增。
+type KeyBundle struct {
+	Version  int64 `json:"version"`
+	IssuedAt int64 `json:"iat"`
+	// base64编码的Ed25519公钥
+	Keys []string `json:"keys"`
+}
+
+// KeyCacheStatus 密钥缓存状态
+type KeyCacheStatus struct {
+	// 已缓存密钥包的版本，0表示只有配置的根公钥
+	Version   int64 `json:"version"`
+	KeyCount  int   `json:"key_count"`
+	UpdatedAt int64 `json:"updated_at,omitempty"`
+	// 最近一次从平台拉取的时间与错误
+	LastFetch int64  `json:"last_fetch,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// cachedBundle 密钥包缓存文件内容
+type cachedBundle struct {
+	Bundle    string `json:"bundle"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// FetchFunc 从平台获取最新的签名密钥包
+type FetchFunc func(ctx context.Context) (string, error)
+
+// KeyCache 平台验证公钥的本地缓存
+// 密钥包持久化到本地，平台不可达时仍可校验签名；遇到未知签名密钥时回源拉取一次
+type KeyCache struct {
+	mu        sync.Mutex
+	path      string
+	roots     []ed25519.PublicKey
+	fetch     FetchFunc
+	bundle    *KeyBundle
+	keys      []ed25519.PublicKey
+	updatedAt int64
+	lastFetch time.Time
+	lastError string
+	verifiers []*Verifier
+}
+
+// NewKeyCache 创建密钥缓存并加载本地缓存的密钥包，fetch为nil时只使用本地缓存与推送的密钥包
+func NewKeyCache(path string, roots []ed25519.PublicKey, fetch FetchFunc) (*KeyCache, error) {
+	c := &KeyCache{
+		path:  path,
+		roots: roots,
+		fetch: fetch,
+	}
+	c.keys = withRoots(roots, nil)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key cache: %w", err)
+	}
+
+	var cached cachedBundle
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, fmt.Errorf("failed to parse key cache: %w", err)
+	}
+	bundle, keys, err := parseBundle(cached.Bundle)
+	if err != nil {
+		return nil, fmt.Errorf("cached key bundle: %w", err)
+	}
+	// 密钥包在写入缓存前已校验签名，轮换后签名公钥可能已不在包内，加载时不再校验签名；
+	// 缓存文件仅所有者可读写，与节点身份文件同等保护
+	c.bundle, c.updatedAt = bundle, cached.UpdatedAt
+	c.keys = withRoots(roots, keys)
+	return c, nil
+}
+
+// Attach 让校验器使用缓存中的公钥，并在遇到未知签名密钥时回源拉取
+func (c *KeyCache) Attach(v *Verifier) {
+	c.mu.Lock()
+	c.verifiers = append(c.verifiers, v)
+	keys := c.keys
+	c.mu.Unlock()
+
+	v.SetKeys(keys)
+	v.setMissHandler(c.refreshOnMiss)
+}
+
+// Keys 返回当前受信任的公钥
+func (c *KeyCache) Keys() []ed25519.PublicKey {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.keys
+}
+
+// Update 校验并应用新的签名密钥包，版本不高于当前缓存时返回 ErrStaleBundle
+func (c *KeyCache) Update(signed string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.updateLocked(signed)
+}
+
+// Refresh 从平台拉取最新的密钥包，平台返回的版本与缓存相同时不视为错误
+func (c *KeyCache) Refresh(ctx context.Context) error {
+	if c.fetch == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	signed, err := c.fetch(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastFetch = time.Now()
+	if err == nil {
+		err = c.updateLocked(signed)
+		if errors.Is(err, ErrStaleBundle) {
+			err = nil
+		}
+	}
+	if err != nil {
+		c.lastError = err.Error()
+		return err
+	}
+	c.lastError = ""
+	return nil
+}
+
+// Status 返回缓存状态
+func (c *KeyCache) Status() KeyCacheStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := KeyCacheStatus{
+		KeyCount:  len(c.keys),
+		UpdatedAt: c.updatedAt,
+		LastError: c.lastError,
+	}
+	if c.bundle != nil {
+		status.Version = c.bundle.Version
+	}
+	if !c.lastFetch.IsZero() {
+		status.LastFetch = c.lastFetch.Unix()
+	}
+	return status
+}
+
+// refreshOnMiss 签名无法用已知公钥校验时回源拉取，返回公钥是否有更新
+func (c *KeyCache) refreshOnMiss() bool {
+	c.mu.Lock()
+	if c.fetch == nil || time.Since(c.lastFetch) < missRefreshInterval {
+		c.mu.Unlock()
+		return false
+	}
+	version := c.versionLocked()
+	c.mu.Unlock()
+
+	if err := c.Refresh(context.Background()); err != nil {
+		log.Warnf("Failed to refresh signing keys: %v", err)
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.versionLocked() != version
+}
+
+// updateLocked 校验、持久化并应用密钥包（调用方需持有锁）
+func (c *KeyCache) updateLocked(signed string) error {
+	bundle, keys, err := parseBundle(signed)
+	if err != nil {
+		return err
+	}
+	if !verifyBundle(signed, c.keys) {
+		return fmt.Errorf("%w: not signed by a trusted key", ErrInvalidBundle)
+	}
+	if bundle.Version <= c.versionLocked() {
+		return ErrStaleBundle
+	}
+
+	now := time.Now().Unix()
+	if err := c.persistLocked(cachedBundle{Bundle: signed, UpdatedAt: now}); err != nil {
+		return err
+	}
+
+	c.bundle, c.updatedAt = bundle, now
+	c.keys = withRoots(c.roots, keys)
+	for _, v := range c.verifiers {
+		v.SetKeys(c.keys)
+	}
+	log.Infof("Signing key bundle updated to version %d (%d key(s))", bundle.Version, len(bundle.Keys))
+	return nil
+}
+
+// persistLocked 原子写入缓存文件（调用方需持有锁）
+func (c *KeyCache) persistLocked(cached cachedBundle) error {
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return fmt.Errorf("failed to create key cache directory: %w", err)
+	}
+	tmpPath := c.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write key cache: %w", err)
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write key cache: %w", err)
+	}
+	return nil
+}
+
+// versionLocked 当前密钥包版本（调用方需持有锁）
+func (c *KeyCache) versionLocked() int64 {
+	if c.bundle == nil {
+		return 0
+	}
+	return c.bundle.Version
+}
+
+// withRoots 根公钥始终受信任，密钥包中的公钥追加在其后
+func withRoots(roots, keys []ed25519.PublicKey) []ed25519.PublicKey {
+	return append(append([]ed25519.PublicKey{}, roots...), keys...)
+}
+
+// parseBundle 解析签名密钥包的内容，不校验签名
+func parseBundle(signed string) (*KeyBundle, []ed25519.PublicKey, error) {
+	parts := strings.Split(strings.TrimSpace(signed), ".")
+	if len(parts) != 2 {
+		return nil, nil, ErrInvalidBundle
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, ErrInvalidBundle
+	}
+
+	var bundle KeyBundle
+	if err := json.Unmarshal(payload, &bundle); err != nil {
+		return nil, nil, ErrInvalidBundle
+	}
+	if bundle.Version <= 0 || len(bundle.Keys) == 0 {
+		return nil, nil, fmt.Errorf("%w: version and keys are required", ErrInvalidBundle)
+	}
+	keys := make([]ed25519.PublicKey, 0, len(bundle.Keys))
+	for _, encoded := range bundle.Keys {
+		key, err := ParsePublicKey(encoded)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		keys = append(keys, key)
+	}
+	return &bundle, keys, nil
+}
+
+// verifyBundle 使用任一公钥校验密钥包签名，签名须覆盖密钥包上下文前缀
+func verifyBundle(signed string, keys []ed25519.PublicKey) bool {
+	parts := strings.Split(strings.TrimSpace(signed), ".")
+	if len(parts) != 2 {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	message := []byte(bundleSigningContext + parts[0])
+	for _, key := range keys {
+		if ed25519.Verify(key, message, signature) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func signBundle(t *testing.T, key ed25519.PrivateKey, bundle KeyBundle, context string) string {
+	t.Helper()
+	payload, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	signature := ed25519.Sign(key, []byte(context+encoded))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newTestBundle(t *testing.T) KeyBundle {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return KeyBundle{
+		Version:  1,
+		IssuedAt: time.Now().Unix(),
+		Keys:     []string{base64.StdEncoding.EncodeToString(pub)},
+	}
+}
+
+func newTestKeyCache(t *testing.T) (*KeyCache, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	cache, err := NewKeyCache(filepath.Join(t.TempDir(), "signing-keys.json"), []ed25519.PublicKey{pub}, nil)
+	if err != nil {
+		t.Fatalf("NewKeyCache: %v", err)
+	}
+	return cache, priv
+}
+
+func TestKeyCacheAcceptsBundleSignedWithContext(t *testing.T) {
+	cache, root := newTestKeyCache(t)
+	if err := cache.Update(signBundle(t, root, newTestBundle(t), bundleSigningContext)); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := len(cache.Keys()); got != 2 {
+		t.Fatalf("expected root and bundle key, got %d keys", got)
+	}
+}
+
+func TestKeyCacheRejectsBundleSignedAsToken(t *testing.T) {
+	cache, root := newTestKeyCache(t)
+	// 与令牌相同方式（不带上下文前缀）签名的内容不能作为密钥包
+	if err := cache.Update(signBundle(t, root, newTestBundle(t), "")); !errors.Is(err, ErrInvalidBundle) {
+		t.Fatalf("expected ErrInvalidBundle, got %v", err)
+	}
+	if got := len(cache.Keys()); got != 1 {
+		t.Fatalf("expected only the root key, got %d keys", got)
+	}
+}
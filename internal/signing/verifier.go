@@ -0,0 +1,184 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidToken 令牌格式或签名无效
+	ErrInvalidToken = errors.New("invalid signed token")
+	// ErrTokenExpired 令牌已过期
+	ErrTokenExpired = errors.New("signed token expired")
+	// ErrTokenReplayed 一次性令牌已被使用
+	ErrTokenReplayed = errors.New("signed token already used")
+)
+
+// maxClockSkew 容许的平台与节点之间的时钟偏差
+const maxClockSkew = time.Minute
+
+// Claims 平台签发令牌中的声明
+type Claims struct {
+	ID        string `json:"jti"`
+	Purpose   string `json:"purpose"`
+	NodeID    string `json:"node_id"`
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	// 命令签名令牌授权的请求（"DELETE /containers/abc"）与请求体的SHA-256（十六进制，无请求体时为空）
+	Target     string `json:"target,omitempty"`
+	BodySHA256 string `json:"body_sha256,omitempty"`
+}
+
+// Verifier 平台签名令牌校验器
+//
+// 令牌格式为 base64url(claims JSON) + "." + base64url(ed25519签名)，
+// 签名覆盖第一段的原始字节。
+type Verifier struct {
+	mu     sync.Mutex
+	keys   []ed25519.PublicKey
+	maxTTL time.Duration
+	used   map[string]time.Time // jti -> 过期时间
+	// 签名无法用已知公钥校验时调用，返回true表示公钥已更新需重试
+	onMiss func() bool
+}
+
+// NewVerifier 创建新的令牌校验器
+func NewVerifier(keys []ed25519.PublicKey, maxTTL time.Duration) *Verifier {
+	return &Verifier{
+		keys:   keys,
+		maxTTL: maxTTL,
+		used:   make(map[string]time.Time),
+	}
+}
+
+// ParsePublicKey 解析base64编码的Ed25519公钥
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key size: %d", len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// Verify 校验令牌签名、用途、目标节点与有效期，但不消费令牌
+func (v *Verifier) Verify(token, purpose, nodeID string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if !v.verifySignature([]byte(parts[0]), signature) {
+		// 平台可能已轮换签名密钥，更新公钥后重试一次
+		onMiss := v.missHandler()
+		if onMiss == nil || !onMiss() || !v.verifySignature([]byte(parts[0]), signature) {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.ID == "" || claims.Purpose != purpose {
+		return nil, fmt.Errorf("%w: unexpected purpose %q", ErrInvalidToken, claims.Purpose)
+	}
+	// 一次性记录只保存在本进程内存中，未绑定节点的令牌可在每个节点各用一次，因此必须绑定本节点
+	if nodeID == "" || claims.NodeID != nodeID {
+		return nil, fmt.Errorf("%w: token is not bound to this node", ErrInvalidToken)
+	}
+
+	now := time.Now().Unix()
+	if claims.ExpiresAt <= now {
+		return nil, ErrTokenExpired
+	}
+	// 签发时间晚于当前时间的令牌可以绕过有效期上限，只容许时钟偏差
+	if claims.IssuedAt > now+int64(maxClockSkew/time.Second) {
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+	if v.maxTTL > 0 && time.Duration(claims.ExpiresAt-max(claims.IssuedAt, now))*time.Second > v.maxTTL {
+		return nil, fmt.Errorf("%w: lifetime exceeds %s", ErrInvalidToken, v.maxTTL)
+	}
+
+	return &claims, nil
+}
+
+// Consume 校验并消费一次性令牌，同一令牌第二次使用会返回 ErrTokenReplayed
+func (v *Verifier) Consume(token, purpose, nodeID string) (*Claims, error) {
+	claims, err := v.Verify(token, purpose, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// 清理已过期的记录
+	now := time.Now()
+	for id, expiry := range v.used {
+		if now.After(expiry) {
+			delete(v.used, id)
+		}
+	}
+
+	if _, seen := v.used[claims.ID]; seen {
+		return nil, ErrTokenReplayed
+	}
+	v.used[claims.ID] = time.Unix(claims.ExpiresAt, 0)
+
+	return claims, nil
+}
+
+// SetKeys 替换校验使用的公钥
+func (v *Verifier) SetKeys(keys []ed25519.PublicKey) {
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+}
+
+// setMissHandler 设置未知签名密钥的回调
+func (v *Verifier) setMissHandler(onMiss func() bool) {
+	v.mu.Lock()
+	v.onMiss = onMiss
+	v.mu.Unlock()
+}
+
+// missHandler 返回未知签名密钥的回调
+func (v *Verifier) missHandler() func() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.onMiss
+}
+
+// verifySignature 使用任一已配置公钥校验签名
+func (v *Verifier) verifySignature(message, signature []byte) bool {
+	v.mu.Lock()
+	keys := v.keys
+	v.mu.Unlock()
+
+	for _, key := range keys {
+		if ed25519.Verify(key, message, signature) {
+			return true
+		}
+	}
+	return false
+}
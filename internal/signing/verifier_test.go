@@ -0,0 +1,146 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+const (
+	testPurpose = "break-glass-shell"
+	testNodeID  = "node-1"
+)
+
+func newTestVerifier(t *testing.T) (*Verifier, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return NewVerifier([]ed25519.PublicKey{pub}, time.Hour), priv
+}
+
+func signToken(t *testing.T, key ed25519.PrivateKey, claims Claims) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	signature := ed25519.Sign(key, []byte(encoded))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() Claims {
+	now := time.Now().Unix()
+	return Claims{
+		ID:        "jti-1",
+		Purpose:   testPurpose,
+		NodeID:    testNodeID,
+		Subject:   "operator",
+		IssuedAt:  now,
+		ExpiresAt: now + 300,
+	}
+}
+
+func TestVerifierAcceptsValidToken(t *testing.T) {
+	v, key := newTestVerifier(t)
+	claims, err := v.Consume(signToken(t, key, validClaims()), testPurpose, testNodeID)
+	if err != nil {
+		t.Fatalf("Consume: %v", err)
+	}
+	if claims.ID != "jti-1" || claims.Subject != "operator" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestVerifierRejectsExpiredToken(t *testing.T) {
+	v, key := newTestVerifier(t)
+	claims := validClaims()
+	claims.IssuedAt -= 600
+	claims.ExpiresAt = time.Now().Unix() - 1
+	if _, err := v.Consume(signToken(t, key, claims), testPurpose, testNodeID); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("expected ErrTokenExpired, got %v", err)
+	}
+}
+
+func TestVerifierRejectsFutureIssuedAt(t *testing.T) {
+	v, key := newTestVerifier(t)
+	claims := validClaims()
+	claims.IssuedAt = time.Now().Add(24 * time.Hour).Unix()
+	claims.ExpiresAt = claims.IssuedAt + 300
+	if _, err := v.Consume(signToken(t, key, claims), testPurpose, testNodeID); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken for a token issued in the future, got %v", err)
+	}
+}
+
+func TestVerifierRejectsExcessiveLifetime(t *testing.T) {
+	v, key := newTestVerifier(t)
+	claims := validClaims()
+	claims.ExpiresAt = time.Now().Add(2 * time.Hour).Unix()
+	if _, err := v.Consume(signToken(t, key, claims), testPurpose, testNodeID); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken for a lifetime above the maximum, got %v", err)
+	}
+}
+
+func TestVerifierRejectsReplayedToken(t *testing.T) {
+	v, key := newTestVerifier(t)
+	token := signToken(t, key, validClaims())
+	if _, err := v.Consume(token, testPurpose, testNodeID); err != nil {
+		t.Fatalf("first Consume: %v", err)
+	}
+	if _, err := v.Consume(token, testPurpose, testNodeID); !errors.Is(err, ErrTokenReplayed) {
+		t.Fatalf("expected ErrTokenReplayed, got %v", err)
+	}
+}
+
+func TestVerifierRejectsWrongPurpose(t *testing.T) {
+	v, key := newTestVerifier(t)
+	claims := validClaims()
+	claims.Purpose = "signed-command"
+	if _, err := v.Consume(signToken(t, key, claims), testPurpose, testNodeID); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestVerifierRejectsUnboundToken(t *testing.T) {
+	v, key := newTestVerifier(t)
+	claims := validClaims()
+	claims.NodeID = ""
+	if _, err := v.Consume(signToken(t, key, claims), testPurpose, testNodeID); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken for token without node_id, got %v", err)
+	}
+}
+
+func TestVerifierRejectsOtherNode(t *testing.T) {
+	v, key := newTestVerifier(t)
+	claims := validClaims()
+	claims.NodeID = "node-2"
+	if _, err := v.Consume(signToken(t, key, claims), testPurpose, testNodeID); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken for another node's token, got %v", err)
+	}
+}
+
+func TestVerifierRejectsWithoutNodeID(t *testing.T) {
+	v, key := newTestVerifier(t)
+	claims := validClaims()
+	claims.NodeID = ""
+	if _, err := v.Consume(signToken(t, key, claims), testPurpose, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken before the node is registered, got %v", err)
+	}
+}
+
+func TestVerifierRejectsUnknownKey(t *testing.T) {
+	v, _ := newTestVerifier(t)
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if _, err := v.Consume(signToken(t, other, validClaims()), testPurpose, testNodeID); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+}
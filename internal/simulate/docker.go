@@ -0,0 +1,1025 @@
+package simulate
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// 模拟的docker组件版本
+const (
+	simDockerVersion     = "24.0.7"
+	simContainerdVersion = "1.7.6"
+	simRuncVersion       = "1.1.9"
+)
+
+// dockerCommands 支持的docker子命令
+var dockerCommands = map[string]func(opts Options, args []string) int{
+	"version": dockerVersion,
+	"info":    dockerInfo,
+	"image":   dockerImage,
+	"pull":    dockerPull,
+	"push":    dockerPush,
+	"commit":  dockerCommit,
+	"run":     dockerRun,
+	"inspect": dockerInspect,
+	"ps":      dockerPs,
+	"start":   dockerStart,
+	"stop":    dockerStop,
+	"rm":      dockerRm,
+	"exec":    dockerExec,
+	"logs":    dockerLogs,
+	"events":  dockerEvents,
+	"volume":  dockerVolume,
+}
+
+// runDocker docker替身的入口
+func runDocker(opts Options, args []string) int {
+	if len(args) == 0 {
+		return dockerError("Usage: docker COMMAND")
+	}
+	run, ok := dockerCommands[args[0]]
+	if !ok {
+		return dockerError("docker %s is not supported in simulation", args[0])
+	}
+	return run(opts, args[1:])
+}
+
+// dockerError 输出错误并返回docker的通用失败退出码
+func dockerError(format string, args ...interface{}) int {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	return 1
+}
+
+// dockerFlags 解析后的命令行参数
+type dockerFlags struct {
+	values     map[string][]string
+	positional []string
+}
+
+// get 返回参数的最后一个值
+func (f dockerFlags) get(name string) string {
+	values := f.values[name]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// has 参数是否出现
+func (f dockerFlags) has(name string) bool {
+	_, ok := f.values[name]
+	return ok
+}
+
+// parseDockerFlags 解析参数：boolean 中的参数不带值，其余参数带值；
+// stopAtPositional 为true时第一个非参数之后的内容都作为位置参数（docker run 的镜像与命令）
+func parseDockerFlags(args []string, boolean map[string]bool, stopAtPositional bool) dockerFlags {
+	flags := dockerFlags{values: make(map[string][]string)}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if stopAtPositional {
+				flags.positional = append(flags.positional, args[i:]...)
+				break
+			}
+			flags.positional = append(flags.positional, arg)
+			continue
+		}
+		if name, value, ok := strings.Cut(arg, "="); ok {
+			flags.values[name] = append(flags.values[name], value)
+			continue
+		}
+		if boolean[arg] || i+1 >= len(args) {
+			flags.values[arg] = append(flags.values[arg], "true")
+			continue
+		}
+		flags.values[arg] = append(flags.values[arg], args[i+1])
+		i++
+	}
+	return flags
+}
+
+// renderFormat 按 --format 模板输出数据，模板中的字段名与docker的JSON字段一致
+func renderFormat(format string, data interface{}) (string, error) {
+	tmpl, err := template.New("format").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			out, err := json.Marshal(v)
+			return string(out), err
+		},
+	}).Parse(format)
+	if err != nil {
+		return "", fmt.Errorf("template parsing error: %w", err)
+	}
+	// 经JSON转换为通用结构，模板按JSON字段名访问
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, generic); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// printFormatted 输出数据：指定 --format 时按模板输出，否则输出缩进的JSON
+func printFormatted(format string, data interface{}) int {
+	if format == "" {
+		out, _ := json.MarshalIndent(data, "", "    ")
+		fmt.Println(string(out))
+		return 0
+	}
+	out, err := renderFormat(format, data)
+	if err != nil {
+		return dockerError("%v", err)
+	}
+	fmt.Println(out)
+	return 0
+}
+
+// dockerVersion docker version
+func dockerVersion(opts Options, args []string) int {
+	type component struct {
+		Name    string
+		Version string
+	}
+	data := map[string]interface{}{
+		"Client": map[string]string{"Version": simDockerVersion, "ApiVersion": "1.43"},
+		"Server": map[string]interface{}{
+			"Version": simDockerVersion,
+			"Components": []component{
+				{Name: "Engine", Version: simDockerVersion},
+				{Name: "containerd", Version: simContainerdVersion},
+				{Name: "runc", Version: simRuncVersion},
+			},
+		},
+	}
+	flags := parseDockerFlags(args, nil, false)
+	if !flags.has("--format") && !flags.has("-f") {
+		fmt.Printf("Client:\n Version: %s (simulated)\n\nServer:\n Engine:\n  Version: %s (simulated)\n", simDockerVersion, simDockerVersion)
+		return 0
+	}
+	return printFormatted(flags.get("--format")+flags.get("-f"), data)
+}
+
+// dockerInfo docker info，模拟的存储驱动不支持可写层大小限制
+func dockerInfo(opts Options, args []string) int {
+	data := map[string]interface{}{
+		"Driver":          "overlay2",
+		"DriverStatus":    [][2]string{{"Backing Filesystem", "extfs"}, {"Supports d_type", "true"}},
+		"DockerRootDir":   filepath.Join(opts.Dir, "docker"),
+		"Runtimes":        map[string]interface{}{"runc": map[string]string{"path": "runc"}},
+		"DefaultRuntime":  "runc",
+		"SecurityOptions": []string{"name=seccomp,profile=builtin", "name=cgroupns"},
+		"ServerVersion":   simDockerVersion,
+	}
+	flags := parseDockerFlags(args, nil, false)
+	return printFormatted(flags.get("--format")+flags.get("-f"), data)
+}
+
+// normalizeImage 补全镜像的默认标签
+func normalizeImage(image string) string {
+	if strings.Contains(image, "@") {
+		return image
+	}
+	if strings.LastIndex(image, ":") <= strings.LastIndex(image, "/") {
+		return image + ":latest"
+	}
+	return image
+}
+
+// imageID 镜像的模拟ID
+func imageID(image string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(image)))
+}
+
+// imageConfig 模拟镜像的配置
+func imageConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"User":       "",
+		"Env":        []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+		"Cmd":        []string{"/bin/bash"},
+		"Entrypoint": nil,
+		"WorkingDir": "",
+	}
+}
+
+// dockerImage docker image inspect
+func dockerImage(opts Options, args []string) int {
+	if len(args) == 0 || args[0] != "inspect" {
+		return dockerError("docker image %s is not supported in simulation", strings.Join(args, " "))
+	}
+	flags := parseDockerFlags(args[1:], nil, false)
+	st, err := loadState(opts.Dir)
+	if err != nil {
+		return dockerError("%v", err)
+	}
+
+	code := 0
+	for _, ref := range flags.positional {
+		id, ok := st.Images[normalizeImage(ref)]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: No such image: %s\n", ref)
+			code = 1
+			continue
+		}
+		data := map[string]interface{}{"Id": id, "RepoTags": []string{normalizeImage(ref)}, "Config": imageConfig()}
+		if printFormatted(flags.get("--format")+flags.get("-f"), data) != 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// pullImage 模拟拉取耗时后记录本地镜像
+func pullImage(opts Options, image string) error {
+	time.Sleep(opts.PullDelay)
+	return withState(opts.Dir, func(st *state) error {
+		st.Images[normalizeImage(image)] = imageID(image)
+		return nil
+	})
+}
+
+// dockerPull docker pull
+func dockerPull(opts Options, args []string) int {
+	flags := parseDockerFlags(args, map[string]bool{"-q": true, "--quiet": true, "-a": true, "--all-tags": true}, false)
+	if len(flags.positional) != 1 {
+		return dockerError("\"docker pull\" requires exactly 1 argument.")
+	}
+	image := flags.positional[0]
+	if err := pullImage(opts, image); err != nil {
+		return dockerError("%v", err)
+	}
+	fmt.Printf("Status: Downloaded newer image for %s\n%s\n", image, normalizeImage(image))
+	return 0
+}
+
+// dockerPush docker push，只检查镜像在本地存在
+func dockerPush(opts Options, args []string) int {
+	flags := parseDockerFlags(args, map[string]bool{"-q": true, "--quiet": true, "-a": true, "--all-tags": true}, false)
+	if len(flags.positional) != 1 {
+		return dockerError("\"docker push\" requires exactly 1 argument.")
+	}
+	image := flags.positional[0]
+	st, err := loadState(opts.Dir)
+	if err != nil {
+		return dockerError("%v", err)
+	}
+	id, ok := st.Images[normalizeImage(image)]
+	if !ok {
+		return dockerError("An image does not exist locally with the tag: %s", image)
+	}
+	time.Sleep(opts.PullDelay)
+	fmt.Printf("latest: digest: %s size: 1024\n", id)
+	return 0
+}
+
+// dockerCommit docker commit，记录新镜像
+func dockerCommit(opts Options, args []string) int {
+	flags := parseDockerFlags(args, nil, false)
+	if len(flags.positional) != 2 {
+		return dockerError("\"docker commit\" requires exactly 2 arguments.")
+	}
+	ref, image := flags.positional[0], flags.positional[1]
+	err := withState(opts.Dir, func(st *state) error {
+		c := st.find(ref)
+		if c == nil {
+			return fmt.Errorf("Error response from daemon: No such container: %s", ref)
+		}
+		st.Images[normalizeImage(image)] = imageID(c.ID + image)
+		return nil
+	})
+	if err != nil {
+		return dockerError("%v", err)
+	}
+	fmt.Println(imageID(image))
+	return 0
+}
+
+// dockerRun docker run -d
+func dockerRun(opts Options, args []string) int {
+	flags := parseDockerFlags(args, map[string]bool{
+		"-d": true, "--detach": true, "--rm": true, "--init": true, "--privileged": true,
+		"--read-only": true, "-i": true, "-t": true, "-it": true, "--interactive": true, "--tty": true,
+	}, true)
+	if len(flags.positional) == 0 {
+		return dockerError("\"docker run\" requires at least 1 argument.")
+	}
+	image, command := flags.positional[0], flags.positional[1:]
+
+	// 本地没有镜像时与docker一样先拉取
+	st, err := loadState(opts.Dir)
+	if err != nil {
+		return dockerError("%v", err)
+	}
+	if _, ok := st.Images[normalizeImage(image)]; !ok {
+		if err := pullImage(opts, image); err != nil {
+			return dockerError("%v", err)
+		}
+	}
+	time.Sleep(opts.StartDelay)
+
+	c := &simContainer{
+		ID:          randomID(),
+		Name:        flags.get("--name"),
+		Image:       image,
+		Created:     time.Now().UTC(),
+		Labels:      make(map[string]string),
+		Cmd:         command,
+		WorkingDir:  flags.get("--workdir") + flags.get("-w"),
+		User:        flags.get("--user") + flags.get("-u"),
+		Ports:       make(map[string][]portBinding),
+		NetworkMode: flags.get("--network"),
+	}
+	if c.Name == "" {
+		c.Name = "sim_" + c.ID[:8]
+	}
+	if len(c.Cmd) == 0 {
+		c.Cmd = []string{"/bin/bash"}
+	}
+	if c.NetworkMode == "" {
+		c.NetworkMode = "bridge"
+	}
+	for _, label := range flags.values["--label"] {
+		key, value, _ := strings.Cut(label, "=")
+		c.Labels[key] = value
+	}
+	// 只有名称的环境变量从docker客户端进程环境读取
+	for _, entry := range append(flags.values["-e"], flags.values["--env"]...) {
+		if strings.Contains(entry, "=") {
+			c.Env = append(c.Env, entry)
+		} else if value, ok := os.LookupEnv(entry); ok {
+			c.Env = append(c.Env, entry+"="+value)
+		}
+	}
+	for _, volume := range append(flags.values["-v"], flags.values["--volume"]...) {
+		parts := strings.Split(volume, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		m := mount{Type: "volume", Source: parts[0], Destination: parts[1], RW: len(parts) < 3 || parts[2] != "ro"}
+		if filepath.IsAbs(parts[0]) {
+			m.Type = "bind"
+		}
+		c.Mounts = append(c.Mounts, m)
+	}
+	c.RestartPolicy, _, _ = strings.Cut(flags.get("--restart"), ":")
+	if _, retries, ok := strings.Cut(flags.get("--restart"), ":"); ok {
+		c.MaxRetries, _ = strconv.Atoi(retries)
+	}
+	if c.RestartPolicy == "" {
+		c.RestartPolicy = "no"
+	}
+	for _, publish := range append(flags.values["-p"], flags.values["--publish"]...) {
+		key, binding, err := parsePublish(publish)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "docker: %v.\n", err)
+			return 125
+		}
+		c.Ports[key] = append(c.Ports[key], binding)
+	}
+
+	err = withState(opts.Dir, func(st *state) error {
+		for _, other := range st.Containers {
+			if other.Name == c.Name {
+				return fmt.Errorf("Conflict. The container name \"/%s\" is already in use by container \"%s\". You have to remove (or rename) that container to be able to reuse that name", c.Name, other.ID)
+			}
+		}
+		if conflict := portConflict(st, c); conflict != "" {
+			return fmt.Errorf("driver failed programming external connectivity on endpoint %s (%s): Bind for 0.0.0.0:%s failed: port is already allocated", c.Name, c.ID, conflict)
+		}
+		if c.NetworkMode == "bridge" {
+			c.IPAddress = fmt.Sprintf("172.17.%d.%d", st.NextIP/254, st.NextIP%254+1)
+			st.NextIP++
+		}
+		// 与docker一样自动创建不存在的命名卷
+		for _, m := range c.Mounts {
+			if _, exists := st.Volumes[m.Source]; m.Type == "volume" && !exists {
+				st.Volumes[m.Source] = map[string]string{}
+			}
+		}
+		st.Containers[c.ID] = c
+		if err := appendEvent(opts.Dir, "create", c, nil); err != nil {
+			return err
+		}
+		return startContainer(opts.Dir, c)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "docker: Error response from daemon: %v.\n", err)
+		return 125
+	}
+	fmt.Println(c.ID)
+	return 0
+}
+
+// parsePublish 解析 -p [ip:]host:container[/proto]
+func parsePublish(publish string) (string, portBinding, error) {
+	spec, protocol, _ := strings.Cut(publish, "/")
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	parts := strings.Split(spec, ":")
+	var binding portBinding
+	switch len(parts) {
+	case 2:
+		binding.HostPort = parts[0]
+	case 3:
+		binding.HostIP, binding.HostPort = parts[0], parts[1]
+	default:
+		return "", binding, fmt.Errorf("invalid publish spec %q", publish)
+	}
+	if binding.HostIP == "" {
+		binding.HostIP = "0.0.0.0"
+	}
+	containerPort := parts[len(parts)-1]
+	if _, err := strconv.Atoi(containerPort); err != nil {
+		return "", binding, fmt.Errorf("invalid containerPort: %s", containerPort)
+	}
+	return containerPort + "/" + protocol, binding, nil
+}
+
+// portConflict 返回与其他运行中容器冲突的主机端口，没有冲突时返回空
+func portConflict(st *state, c *simContainer) string {
+	used := make(map[string]bool)
+	for _, other := range st.Containers {
+		if !other.running() || other.ID == c.ID {
+			continue
+		}
+		for key, bindings := range other.Ports {
+			_, protocol, _ := strings.Cut(key, "/")
+			for _, b := range bindings {
+				used[b.HostPort+"/"+protocol] = true
+			}
+		}
+	}
+	for key, bindings := range c.Ports {
+		_, protocol, _ := strings.Cut(key, "/")
+		for _, b := range bindings {
+			if b.HostPort != "" && b.HostPort != "0" && used[b.HostPort+"/"+protocol] {
+				return b.HostPort
+			}
+		}
+	}
+	return ""
+}
+
+// containerJSON docker inspect 输出的容器详情
+type containerJSON struct {
+	ID      string    `json:"Id"`
+	Name    string    `json:"Name"`
+	Created time.Time `json:"Created"`
+	Path    string    `json:"Path"`
+	Args    []string  `json:"Args"`
+	State   struct {
+		Status     string    `json:"Status"`
+		Running    bool      `json:"Running"`
+		Paused     bool      `json:"Paused"`
+		Restarting bool      `json:"Restarting"`
+		OOMKilled  bool      `json:"OOMKilled"`
+		Dead       bool      `json:"Dead"`
+		Pid        int       `json:"Pid"`
+		ExitCode   int       `json:"ExitCode"`
+		Error      string    `json:"Error"`
+		StartedAt  time.Time `json:"StartedAt"`
+		FinishedAt time.Time `json:"FinishedAt"`
+	} `json:"State"`
+	Image        string `json:"Image"`
+	RestartCount int    `json:"RestartCount"`
+	Config       struct {
+		Hostname   string            `json:"Hostname"`
+		User       string            `json:"User"`
+		Env        []string          `json:"Env"`
+		Cmd        []string          `json:"Cmd"`
+		Image      string            `json:"Image"`
+		WorkingDir string            `json:"WorkingDir"`
+		Entrypoint []string          `json:"Entrypoint"`
+		Labels     map[string]string `json:"Labels"`
+	} `json:"Config"`
+	HostConfig struct {
+		RestartPolicy struct {
+			Name              string `json:"Name"`
+			MaximumRetryCount int    `json:"MaximumRetryCount"`
+		} `json:"RestartPolicy"`
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Ports     map[string][]portBinding `json:"Ports"`
+		IPAddress string                   `json:"IPAddress"`
+		Networks  map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+	Mounts []mount `json:"Mounts"`
+	SizeRw *int64  `json:"SizeRw,omitempty"`
+}
+
+// toJSON 转换为 docker inspect 的格式，withSize 时包含可写层大小（随运行时长增长）
+func (c *simContainer) toJSON(withSize bool) containerJSON {
+	var out containerJSON
+	out.ID = c.ID
+	out.Name = "/" + c.Name
+	out.Created = c.Created
+	if len(c.Cmd) > 0 {
+		out.Path, out.Args = c.Cmd[0], c.Cmd[1:]
+	}
+	out.State.Status = c.Status
+	out.State.Running = c.running()
+	out.State.OOMKilled = c.OOMKilled
+	out.State.ExitCode = c.ExitCode
+	out.State.StartedAt = c.StartedAt
+	out.State.FinishedAt = c.FinishedAt
+	out.Image = imageID(c.Image)
+	out.RestartCount = c.RestartCount
+	out.Config.Hostname = c.ID[:12]
+	out.Config.User = c.User
+	out.Config.Env = append(c.Env, "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin")
+	out.Config.Cmd = c.Cmd
+	out.Config.Image = c.Image
+	out.Config.WorkingDir = c.WorkingDir
+	out.Config.Labels = c.Labels
+	out.HostConfig.RestartPolicy.Name = c.RestartPolicy
+	out.HostConfig.RestartPolicy.MaximumRetryCount = c.MaxRetries
+	out.HostConfig.NetworkMode = c.NetworkMode
+	out.NetworkSettings.Ports = c.Ports
+	out.NetworkSettings.Networks = make(map[string]struct {
+		IPAddress string `json:"IPAddress"`
+	})
+	if c.IPAddress != "" && c.running() {
+		out.NetworkSettings.IPAddress = c.IPAddress
+		out.NetworkSettings.Networks[c.NetworkMode] = struct {
+			IPAddress string `json:"IPAddress"`
+		}{c.IPAddress}
+	}
+	out.Mounts = c.Mounts
+	if out.Mounts == nil {
+		out.Mounts = []mount{}
+	}
+	if withSize {
+		size := int64(64<<20) + int64(time.Since(c.Created).Minutes())<<20
+		out.SizeRw = &size
+	}
+	return out
+}
+
+// dockerInspect docker inspect
+func dockerInspect(opts Options, args []string) int {
+	flags := parseDockerFlags(args, map[string]bool{"-s": true, "--size": true}, false)
+	st, err := loadState(opts.Dir)
+	if err != nil {
+		return dockerError("%v", err)
+	}
+
+	format := flags.get("--format") + flags.get("-f")
+	withSize := flags.has("--size") || flags.has("-s")
+	code := 0
+	items := []containerJSON{}
+	for _, ref := range flags.positional {
+		c := st.find(ref)
+		if c == nil {
+			fmt.Fprintf(os.Stderr, "Error: No such object: %s\n", ref)
+			code = 1
+			continue
+		}
+		items = append(items, c.toJSON(withSize))
+	}
+	if format == "" {
+		printFormatted("", items)
+		return code
+	}
+	for _, item := range items {
+		if printFormatted(format, item) != 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// dockerPs docker ps
+func dockerPs(opts Options, args []string) int {
+	flags := parseDockerFlags(args, map[string]bool{
+		"-a": true, "--all": true, "-q": true, "--quiet": true, "--no-trunc": true, "-s": true, "--size": true,
+	}, false)
+	st, err := loadState(opts.Dir)
+	if err != nil {
+		return dockerError("%v", err)
+	}
+
+	all := flags.has("-a") || flags.has("--all")
+	var matched []*simContainer
+	for _, c := range st.Containers {
+		if (all || c.running()) && matchLabelFilters(c.Labels, flags.values["--filter"]) {
+			matched = append(matched, c)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Created.After(matched[j].Created) })
+
+	format := flags.get("--format")
+	if format == "" {
+		format = "{{.ID}}"
+	}
+	for _, c := range matched {
+		id := c.ID
+		if !flags.has("--no-trunc") {
+			id = id[:12]
+		}
+		row := map[string]string{"ID": id, "Names": c.Name, "Image": c.Image, "State": c.Status, "Status": c.Status}
+		if printFormatted(format, row) != 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// matchLabelFilters 容器或卷的标签是否满足所有 label= 过滤条件，其他过滤条件忽略
+func matchLabelFilters(labels map[string]string, filters []string) bool {
+	for _, filter := range filters {
+		label, ok := strings.CutPrefix(filter, "label=")
+		if !ok {
+			continue
+		}
+		key, value, hasValue := strings.Cut(label, "=")
+		actual, exists := labels[key]
+		if !exists || (hasValue && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// eachContainer 在锁内对每个引用的容器执行fn，输出成功的引用
+func eachContainer(opts Options, refs []string, fn func(c *simContainer, st *state) error) int {
+	code := 0
+	for _, ref := range refs {
+		err := withState(opts.Dir, func(st *state) error {
+			c := st.find(ref)
+			if c == nil {
+				return fmt.Errorf("No such container: %s", ref)
+			}
+			return fn(c, st)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error response from daemon: %v\n", err)
+			code = 1
+			continue
+		}
+		fmt.Println(ref)
+	}
+	return code
+}
+
+// dockerStart docker start
+func dockerStart(opts Options, args []string) int {
+	flags := parseDockerFlags(args, map[string]bool{"-a": true, "-i": true}, false)
+	return eachContainer(opts, flags.positional, func(c *simContainer, st *state) error {
+		if c.running() {
+			return nil
+		}
+		if conflict := portConflict(st, c); conflict != "" {
+			return fmt.Errorf("driver failed programming external connectivity on endpoint %s (%s): Bind for 0.0.0.0:%s failed: port is already allocated", c.Name, c.ID, conflict)
+		}
+		return startContainer(opts.Dir, c)
+	})
+}
+
+// dockerStop docker stop，模拟的容器立即正常退出
+func dockerStop(opts Options, args []string) int {
+	flags := parseDockerFlags(args, nil, false)
+	return eachContainer(opts, flags.positional, func(c *simContainer, st *state) error {
+		return stopContainer(opts.Dir, c, 0, false)
+	})
+}
+
+// dockerRm docker rm
+func dockerRm(opts Options, args []string) int {
+	flags := parseDockerFlags(args, map[string]bool{"-f": true, "--force": true, "-v": true, "--volumes": true}, false)
+	force := flags.has("-f") || flags.has("--force")
+	return eachContainer(opts, flags.positional, func(c *simContainer, st *state) error {
+		if c.running() {
+			if !force {
+				return fmt.Errorf("You cannot remove a running container %s. Stop the container before attempting removal or force remove", c.ID)
+			}
+			if err := appendEvent(opts.Dir, "kill", c, map[string]string{"signal": "9"}); err != nil {
+				return err
+			}
+			if err := stopContainer(opts.Dir, c, 137, false); err != nil {
+				return err
+			}
+		}
+		delete(st.Containers, c.ID)
+		return appendEvent(opts.Dir, "destroy", c, nil)
+	})
+}
+
+// dockerExec docker exec，容器内的 nvidia-smi 查询返回分配给容器的GPU，其余命令不做任何事
+func dockerExec(opts Options, args []string) int {
+	flags := parseDockerFlags(args, map[string]bool{"-i": true, "-t": true, "-it": true, "-d": true, "--privileged": true}, true)
+	if len(flags.positional) < 2 {
+		return dockerError("\"docker exec\" requires at least 2 arguments.")
+	}
+	st, err := loadState(opts.Dir)
+	if err != nil {
+		return dockerError("%v", err)
+	}
+	c := st.find(flags.positional[0])
+	if c == nil {
+		return dockerError("Error response from daemon: No such container: %s", flags.positional[0])
+	}
+	if !c.running() {
+		return dockerError("Error response from daemon: Container %s is not running", c.ID)
+	}
+
+	command := flags.positional[1:]
+	if filepath.Base(command[0]) == "nvidia-smi" {
+		for _, arg := range command[1:] {
+			if strings.HasPrefix(arg, "--query-gpu=") {
+				for _, uuid := range strings.Split(c.Labels["utopia.gpu_uuids"], ",") {
+					if uuid = strings.TrimSpace(uuid); uuid != "" {
+						fmt.Printf("%s, %s\n", uuid, opts.GPUModel)
+					}
+				}
+				break
+			}
+		}
+	}
+	return 0
+}
+
+// logInterval 模拟容器输出日志的间隔
+const logInterval = 10 * time.Second
+
+// maxLogLines 未指定 --tail 时最多输出的历史日志行数
+const maxLogLines = 1000
+
+// logLine 容器在t时刻输出的模拟日志
+func logLine(c *simContainer, t time.Time, timestamps bool) string {
+	step := int(t.Sub(c.StartedAt) / logInterval)
+	line := fmt.Sprintf("step %d: loss=%.4f throughput=%d samples/s", step, 2.5/float64(step+1)+0.1, 900+step%200)
+	if timestamps {
+		return t.UTC().Format(time.RFC3339Nano) + " " + line
+	}
+	return line
+}
+
+// dockerLogs docker logs，按容器的运行时间生成训练日志
+func dockerLogs(opts Options, args []string) int {
+	flags := parseDockerFlags(args, map[string]bool{"-f": true, "--follow": true, "-t": true, "--timestamps": true}, false)
+	if len(flags.positional) != 1 {
+		return dockerError("\"docker logs\" requires exactly 1 argument.")
+	}
+	ref := flags.positional[0]
+	st, err := loadState(opts.Dir)
+	if err != nil {
+		return dockerError("%v", err)
+	}
+	c := st.find(ref)
+	if c == nil {
+		return dockerError("Error response from daemon: No such container: %s", ref)
+	}
+
+	timestamps := flags.has("--timestamps") || flags.has("-t")
+	since := c.StartedAt
+	if value := flags.get("--since"); value != "" {
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil && t.After(since) {
+			since = t
+		}
+	}
+	end := time.Now()
+	if !c.running() {
+		end = c.FinishedAt
+	}
+
+	// 历史日志
+	var times []time.Time
+	for t := c.StartedAt; !t.After(end); t = t.Add(logInterval) {
+		if !t.Before(since) {
+			times = append(times, t)
+		}
+	}
+	limit := maxLogLines
+	if tail, err := strconv.Atoi(flags.get("--tail")); err == nil && tail >= 0 && tail < limit {
+		limit = tail
+	}
+	if len(times) > limit {
+		times = times[len(times)-limit:]
+	}
+	out := bufio.NewWriter(os.Stdout)
+	for _, t := range times {
+		fmt.Fprintln(out, logLine(c, t, timestamps))
+	}
+	out.Flush()
+
+	if !flags.has("--follow") && !flags.has("-f") || !c.running() {
+		return 0
+	}
+	// 跟随输出，容器停止后返回
+	next := end.Truncate(logInterval).Add(logInterval)
+	for {
+		time.Sleep(time.Until(next))
+		st, err := loadState(opts.Dir)
+		if err != nil {
+			return dockerError("%v", err)
+		}
+		if current := st.find(c.ID); current == nil || !current.running() || !current.StartedAt.Equal(c.StartedAt) {
+			return 0
+		}
+		fmt.Println(logLine(c, next, timestamps))
+		next = next.Add(logInterval)
+	}
+}
+
+// eventsPollInterval docker events 检查新事件的间隔
+const eventsPollInterval = 200 * time.Millisecond
+
+// dockerEvents docker events，输出事件文件中满足条件的事件并持续跟随
+func dockerEvents(opts Options, args []string) int {
+	flags := parseDockerFlags(args, nil, false)
+	var since int64
+	if value := flags.get("--since"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			since = int64(seconds * float64(time.Second))
+		} else if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			since = t.UnixNano()
+		}
+	}
+
+	// 按类别汇总过滤条件，同一类别内为或，类别之间为且
+	filters := make(map[string][]string)
+	for _, filter := range flags.values["--filter"] {
+		key, value, _ := strings.Cut(filter, "=")
+		filters[key] = append(filters[key], value)
+	}
+	match := func(event dockerEvent) bool {
+		if event.TimeNano < since {
+			return false
+		}
+		if types := filters["type"]; len(types) > 0 && !contains(types, event.Type) {
+			return false
+		}
+		if actions := filters["event"]; len(actions) > 0 && !contains(actions, event.Action) {
+			return false
+		}
+		for _, label := range filters["label"] {
+			key, value, hasValue := strings.Cut(label, "=")
+			actual, exists := event.Actor.Attributes[key]
+			if !exists || (hasValue && actual != value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	format := flags.get("--format")
+	if format == "" {
+		format = "{{json .}}"
+	}
+	f, err := os.OpenFile(filepath.Join(opts.Dir, eventsFile), os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return dockerError("%v", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var partial string
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			// 保留未写完的行，等待新事件
+			partial += line
+			time.Sleep(eventsPollInterval)
+			continue
+		}
+		if err != nil {
+			return dockerError("%v", err)
+		}
+		line, partial = partial+line, ""
+		var event dockerEvent
+		if json.Unmarshal([]byte(line), &event) != nil || !match(event) {
+			continue
+		}
+		if printFormatted(format, event) != 0 {
+			return 1
+		}
+	}
+}
+
+// contains 列表中是否包含值
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// dockerVolume docker volume create/inspect/ls/rm
+func dockerVolume(opts Options, args []string) int {
+	if len(args) == 0 {
+		return dockerError("Usage: docker volume COMMAND")
+	}
+	flags := parseDockerFlags(args[1:], map[string]bool{"-q": true, "--quiet": true, "-f": true, "--force": true}, false)
+	switch args[0] {
+	case "create":
+		if len(flags.positional) != 1 {
+			return dockerError("\"docker volume create\" requires exactly 1 argument.")
+		}
+		name := flags.positional[0]
+		err := withState(opts.Dir, func(st *state) error {
+			if _, exists := st.Volumes[name]; exists {
+				return nil
+			}
+			labels := make(map[string]string)
+			for _, label := range flags.values["--label"] {
+				key, value, _ := strings.Cut(label, "=")
+				labels[key] = value
+			}
+			st.Volumes[name] = labels
+			return nil
+		})
+		if err != nil {
+			return dockerError("%v", err)
+		}
+		fmt.Println(name)
+		return 0
+	case "inspect":
+		st, err := loadState(opts.Dir)
+		if err != nil {
+			return dockerError("%v", err)
+		}
+		code := 0
+		for _, name := range flags.positional {
+			labels, exists := st.Volumes[name]
+			if !exists {
+				fmt.Fprintf(os.Stderr, "Error response from daemon: get %s: no such volume\n", name)
+				code = 1
+				continue
+			}
+			data := map[string]interface{}{
+				"Name":       name,
+				"Driver":     "local",
+				"Labels":     labels,
+				"Mountpoint": filepath.Join(opts.Dir, "volumes", name, "_data"),
+			}
+			if printFormatted(flags.get("--format")+flags.get("-f"), data) != 0 {
+				code = 1
+			}
+		}
+		return code
+	case "ls":
+		st, err := loadState(opts.Dir)
+		if err != nil {
+			return dockerError("%v", err)
+		}
+		names := make([]string, 0, len(st.Volumes))
+		for name, labels := range st.Volumes {
+			if matchLabelFilters(labels, flags.values["--filter"]) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return 0
+	case "rm":
+		code := 0
+		for _, name := range flags.positional {
+			err := withState(opts.Dir, func(st *state) error {
+				if _, exists := st.Volumes[name]; !exists {
+					return fmt.Errorf("get %s: no such volume", name)
+				}
+				for _, c := range st.Containers {
+					for _, m := range c.Mounts {
+						if m.Type == "volume" && m.Source == name {
+							return fmt.Errorf("remove %s: volume is in use - [%s]", name, c.ID)
+						}
+					}
+				}
+				delete(st.Volumes, name)
+				return nil
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error response from daemon: %v\n", err)
+				code = 1
+				continue
+			}
+			fmt.Println(name)
+		}
+		return code
+	}
+	return dockerError("docker volume %s is not supported in simulation", args[0])
+}
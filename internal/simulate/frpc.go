@@ -0,0 +1,62 @@
+package simulate
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"time"
+)
+
+// simFrpcVersion 模拟的frpc版本
+const simFrpcVersion = "0.52.3"
+
+// proxyNamePattern frpc.toml 中的代理名称
+var proxyNamePattern = regexp.MustCompile(`(?m)^name = "([^"]+)"`)
+
+// runFRPC frpc替身：不连接服务器，按配置输出登录与各代理启动成功的日志后一直运行到收到信号
+func runFRPC(opts Options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: frpc -c CONFIG")
+		return 1
+	}
+	switch args[0] {
+	case "-v", "--version":
+		fmt.Println(simFrpcVersion)
+		return 0
+	case "verify":
+		fmt.Println("frpc: the configuration file is syntax ok")
+		return 0
+	}
+
+	var path string
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == "-c" || args[i] == "--config" {
+			path = args[i+1]
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "frpc: %v\n", err)
+		return 1
+	}
+
+	logf := func(format string, args ...interface{}) {
+		fmt.Printf("%s [I] [simulate] "+format+"\n", append([]interface{}{time.Now().Format("2006-01-02 15:04:05.000")}, args...)...)
+	}
+	logf("login to server success, get run id [sim%d]", os.Getpid())
+	for _, match := range proxyNamePattern.FindAllStringSubmatch(string(data), -1) {
+		logf("[%s] start proxy success", match[1])
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	return 0
+}
+
+// runNvidiaSMI nvidia-smi替身：GPU重置与计算模式设置直接成功
+func runNvidiaSMI(opts Options, args []string) int {
+	return 0
+}
@@ -0,0 +1,273 @@
+package simulate
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// 虚拟GPU的功率参数（毫瓦）
+const (
+	simIdlePowerMW    = 60000
+	simDefaultLimitMW = 400000
+	simMinLimitMW     = 100000
+)
+
+// attachedCacheTTL 读取容器分配的GPU的缓存时间，避免每次查询都读取状态文件
+const attachedCacheTTL = 2 * time.Second
+
+// simNVML 模拟的NVML：GPU负载随分配给运行中容器的GPU变化
+type simNVML struct {
+	mu   sync.Mutex
+	opts Options
+	gpus []*simDevice
+	// 分配给运行中容器的GPU及读取时间
+	attached   map[int]bool
+	attachedAt time.Time
+}
+
+// simDevice 虚拟GPU，未实现的 nvml.Device 方法不会被Agent调用
+type simDevice struct {
+	nvml.Device
+	sim   *simNVML
+	index int
+	uuid  string
+
+	// 以下字段由 sim.mu 保护
+	util       float64
+	memUsedMB  float64
+	tempC      float64
+	powerLimit uint32
+	updated    time.Time
+}
+
+// installNVML 以模拟的GPU替换NVML的包级函数
+func installNVML(opts Options) {
+	sim := &simNVML{opts: opts}
+	for i := 0; i < opts.GPUs; i++ {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s/gpu%d", opts.Dir, i)))
+		sim.gpus = append(sim.gpus, &simDevice{
+			sim:        sim,
+			index:      i,
+			uuid:       fmt.Sprintf("GPU-%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16]),
+			tempC:      30 + rand.Float64()*5,
+			powerLimit: simDefaultLimitMW,
+		})
+	}
+
+	nvml.Init = func() nvml.Return { return nvml.SUCCESS }
+	nvml.Shutdown = func() nvml.Return { return nvml.SUCCESS }
+	nvml.DeviceGetCount = func() (int, nvml.Return) { return len(sim.gpus), nvml.SUCCESS }
+	nvml.DeviceGetHandleByIndex = func(index int) (nvml.Device, nvml.Return) {
+		if index < 0 || index >= len(sim.gpus) {
+			return nil, nvml.ERROR_INVALID_ARGUMENT
+		}
+		return sim.gpus[index], nvml.SUCCESS
+	}
+}
+
+// isAttached GPU是否分配给了运行中的容器（调用方需持有锁）
+func (s *simNVML) isAttached(index int) bool {
+	if time.Since(s.attachedAt) > attachedCacheTTL {
+		attached := make(map[int]bool)
+		if st, err := loadState(s.opts.Dir); err == nil {
+			for _, c := range st.Containers {
+				if c.running() {
+					for _, id := range c.gpuIDs() {
+						attached[id] = true
+					}
+				}
+			}
+		}
+		s.attached, s.attachedAt = attached, time.Now()
+	}
+	return s.attached[index]
+}
+
+// sample 按经过的时间推进GPU负载的随机游走并返回当前值
+// 分配给运行中容器的GPU利用率在30%~100%、显存在20%~95%之间波动，空闲GPU回落到接近0
+func (d *simDevice) sample() (util, memUsedMB, tempC float64) {
+	d.sim.mu.Lock()
+	defer d.sim.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(d.updated) >= time.Second {
+		total := float64(d.sim.opts.GPUMemoryMB)
+		if d.sim.isAttached(d.index) {
+			if d.util < 30 {
+				d.util, d.memUsedMB = 40+rand.Float64()*20, total*(0.3+rand.Float64()*0.2)
+			}
+			d.util = clamp(d.util+rand.NormFloat64()*8, 30, 100)
+			d.memUsedMB = clamp(d.memUsedMB+rand.NormFloat64()*total*0.02, total*0.2, total*0.95)
+		} else {
+			d.util = 0
+			d.memUsedMB = 4
+		}
+		// 温度向负载对应的稳态温度靠近
+		target := 32 + d.util*0.5
+		d.tempC = clamp(d.tempC+(target-d.tempC)*0.2+rand.NormFloat64()*0.5, 25, 90)
+		d.updated = now
+	}
+	return d.util, d.memUsedMB, d.tempC
+}
+
+// clamp 将值限制在 [low, high] 内
+func clamp(v, low, high float64) float64 {
+	return math.Max(low, math.Min(high, v))
+}
+
+// GetName 实现 nvml.Device
+func (d *simDevice) GetName() (string, nvml.Return) {
+	return d.sim.opts.GPUModel, nvml.SUCCESS
+}
+
+// GetUUID 实现 nvml.Device
+func (d *simDevice) GetUUID() (string, nvml.Return) {
+	return d.uuid, nvml.SUCCESS
+}
+
+// GetMinorNumber 实现 nvml.Device
+func (d *simDevice) GetMinorNumber() (int, nvml.Return) {
+	return d.index, nvml.SUCCESS
+}
+
+// GetTemperature 实现 nvml.Device
+func (d *simDevice) GetTemperature(nvml.TemperatureSensors) (uint32, nvml.Return) {
+	_, _, temp := d.sample()
+	return uint32(temp), nvml.SUCCESS
+}
+
+// GetMemoryInfo 实现 nvml.Device
+func (d *simDevice) GetMemoryInfo() (nvml.Memory, nvml.Return) {
+	_, used, _ := d.sample()
+	total := uint64(d.sim.opts.GPUMemoryMB) << 20
+	usedBytes := uint64(used) << 20
+	return nvml.Memory{Total: total, Used: usedBytes, Free: total - usedBytes}, nvml.SUCCESS
+}
+
+// GetUtilizationRates 实现 nvml.Device
+func (d *simDevice) GetUtilizationRates() (nvml.Utilization, nvml.Return) {
+	util, used, _ := d.sample()
+	return nvml.Utilization{Gpu: uint32(util), Memory: uint32(used * 100 / float64(d.sim.opts.GPUMemoryMB))}, nvml.SUCCESS
+}
+
+// GetPowerUsage 实现 nvml.Device，功耗随利用率在空闲功耗与功率上限之间变化
+func (d *simDevice) GetPowerUsage() (uint32, nvml.Return) {
+	util, _, _ := d.sample()
+	limit := d.limit()
+	return simIdlePowerMW + uint32(float64(limit-simIdlePowerMW)*util/100*0.9), nvml.SUCCESS
+}
+
+// GetEnforcedPowerLimit 实现 nvml.Device
+func (d *simDevice) GetEnforcedPowerLimit() (uint32, nvml.Return) {
+	return d.limit(), nvml.SUCCESS
+}
+
+// GetPowerManagementLimit 实现 nvml.Device
+func (d *simDevice) GetPowerManagementLimit() (uint32, nvml.Return) {
+	return d.limit(), nvml.SUCCESS
+}
+
+// GetPowerManagementDefaultLimit 实现 nvml.Device
+func (d *simDevice) GetPowerManagementDefaultLimit() (uint32, nvml.Return) {
+	return simDefaultLimitMW, nvml.SUCCESS
+}
+
+// GetPowerManagementLimitConstraints 实现 nvml.Device
+func (d *simDevice) GetPowerManagementLimitConstraints() (uint32, uint32, nvml.Return) {
+	return simMinLimitMW, simDefaultLimitMW, nvml.SUCCESS
+}
+
+// SetPowerManagementLimit 实现 nvml.Device
+func (d *simDevice) SetPowerManagementLimit(limit uint32) nvml.Return {
+	if limit < simMinLimitMW || limit > simDefaultLimitMW {
+		return nvml.ERROR_INVALID_ARGUMENT
+	}
+	d.sim.mu.Lock()
+	d.powerLimit = limit
+	d.sim.mu.Unlock()
+	return nvml.SUCCESS
+}
+
+// limit 当前的功率上限
+func (d *simDevice) limit() uint32 {
+	d.sim.mu.Lock()
+	defer d.sim.mu.Unlock()
+	return d.powerLimit
+}
+
+// GetNumFans 实现 nvml.Device，模拟被动散热的SXM模块
+func (d *simDevice) GetNumFans() (int, nvml.Return) {
+	return 0, nvml.SUCCESS
+}
+
+// GetFanSpeed_v2 实现 nvml.Device
+func (d *simDevice) GetFanSpeed_v2(int) (uint32, nvml.Return) {
+	return 0, nvml.ERROR_NOT_SUPPORTED
+}
+
+// SetFanSpeed_v2 实现 nvml.Device
+func (d *simDevice) SetFanSpeed_v2(int, int) nvml.Return {
+	return nvml.ERROR_NOT_SUPPORTED
+}
+
+// SetDefaultFanSpeed_v2 实现 nvml.Device
+func (d *simDevice) SetDefaultFanSpeed_v2(int) nvml.Return {
+	return nvml.ERROR_NOT_SUPPORTED
+}
+
+// GetComputeRunningProcesses 实现 nvml.Device，模拟的容器没有主机进程
+func (d *simDevice) GetComputeRunningProcesses() ([]nvml.ProcessInfo, nvml.Return) {
+	return []nvml.ProcessInfo{}, nvml.SUCCESS
+}
+
+// GetProcessUtilization 实现 nvml.Device，模拟的容器没有主机进程，按不支持处理
+func (d *simDevice) GetProcessUtilization(uint64) ([]nvml.ProcessUtilizationSample, nvml.Return) {
+	return nil, nvml.ERROR_NOT_SUPPORTED
+}
+
+// GetPciInfo 实现 nvml.Device
+func (d *simDevice) GetPciInfo() (nvml.PciInfo, nvml.Return) {
+	var info nvml.PciInfo
+	info.Bus = uint32(0x10 + d.index*0x10)
+	busID := fmt.Sprintf("00000000:%02X:00.0", info.Bus)
+	for i := 0; i < len(busID) && i < len(info.BusId)-1; i++ {
+		info.BusId[i] = int8(busID[i])
+	}
+	return info, nvml.SUCCESS
+}
+
+// GetNvLinkState 实现 nvml.Device，GPU间经NVSwitch互联，不逐条报告链路
+func (d *simDevice) GetNvLinkState(int) (nvml.EnableState, nvml.Return) {
+	return nvml.FEATURE_DISABLED, nvml.ERROR_NOT_SUPPORTED
+}
+
+// GetNvLinkRemotePciInfo 实现 nvml.Device
+func (d *simDevice) GetNvLinkRemotePciInfo(int) (nvml.PciInfo, nvml.Return) {
+	return nvml.PciInfo{}, nvml.ERROR_NOT_SUPPORTED
+}
+
+// GetTopologyCommonAncestor 实现 nvml.Device，每4块GPU共享一个NUMA节点
+func (d *simDevice) GetTopologyCommonAncestor(other nvml.Device) (nvml.GpuTopologyLevel, nvml.Return) {
+	peer, ok := other.(*simDevice)
+	if !ok {
+		return 0, nvml.ERROR_INVALID_ARGUMENT
+	}
+	if peer.index/4 == d.index/4 {
+		return nvml.TOPOLOGY_NODE, nvml.SUCCESS
+	}
+	return nvml.TOPOLOGY_SYSTEM, nvml.SUCCESS
+}
+
+// GetP2PStatus 实现 nvml.Device，所有GPU两两之间可经NVLink通信
+func (d *simDevice) GetP2PStatus(other nvml.Device, index nvml.GpuP2PCapsIndex) (nvml.GpuP2PStatus, nvml.Return) {
+	if _, ok := other.(*simDevice); !ok {
+		return 0, nvml.ERROR_INVALID_ARGUMENT
+	}
+	return nvml.P2P_STATUS_OK, nvml.SUCCESS
+}
@@ -0,0 +1,159 @@
+// Package simulate 在没有docker与NVML的主机上模拟GPU节点，用于对平台控制面做负载测试。
+// 模拟的NVML提供虚拟GPU，本进程的可执行文件作为 docker、frpc 与 nvidia-smi 的替身，
+// Agent的其余部分（注册、心跳、API、事件）照常运行
+package simulate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// envDir 替身命令读取的模拟状态目录
+const envDir = "UTOPIA_SIMULATE_DIR"
+
+// Options 模拟选项
+type Options struct {
+	// 模拟状态目录：容器、镜像、事件与替身命令
+	Dir string `json:"dir"`
+	// 虚拟GPU的数量、型号与显存
+	GPUs        int    `json:"gpus"`
+	GPUModel    string `json:"gpu_model"`
+	GPUMemoryMB int    `json:"gpu_memory_mb"`
+	// 拉取镜像与启动容器的模拟耗时
+	PullDelay  time.Duration `json:"pull_delay"`
+	StartDelay time.Duration `json:"start_delay"`
+	// 每个运行中容器每小时因OOM退出的平均次数，0表示不注入故障
+	FailuresPerHour float64 `json:"failures_per_hour"`
+}
+
+// DefaultOptions 默认模拟选项：8块80GB的A100
+func DefaultOptions() Options {
+	return Options{
+		GPUs:        8,
+		GPUModel:    "NVIDIA A100-SXM4-80GB",
+		GPUMemoryMB: 81920,
+		PullDelay:   5 * time.Second,
+		StartDelay:  500 * time.Millisecond,
+	}
+}
+
+// shims 由替身处理的命令
+var shims = map[string]func(opts Options, args []string) int{
+	"docker":     runDocker,
+	"frpc":       runFRPC,
+	"nvidia-smi": runNvidiaSMI,
+}
+
+// RunShim 本进程作为替身命令（argv[0] 为 docker 等）启动时处理该命令，返回退出码与是否已处理
+func RunShim() (int, bool) {
+	run, ok := shims[filepath.Base(os.Args[0])]
+	dir := os.Getenv(envDir)
+	if !ok || dir == "" {
+		return 0, false
+	}
+	opts, err := loadOptions(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "simulate: %v\n", err)
+		return 1, true
+	}
+	return run(opts, os.Args[1:]), true
+}
+
+// Simulator 运行中的节点模拟
+type Simulator struct {
+	opts Options
+}
+
+// Setup 准备模拟环境：保存选项、创建替身命令并加入PATH、安装模拟的NVML
+// 须在创建Agent之前调用
+func Setup(opts Options) (*Simulator, error) {
+	if opts.GPUs < 0 || opts.GPUMemoryMB <= 0 {
+		return nil, fmt.Errorf("invalid simulated GPUs: count must be non-negative and memory positive")
+	}
+	binDir := filepath.Join(opts.Dir, "bin")
+	if err := os.MkdirAll(binDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create simulation directory: %w", err)
+	}
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(opts.Dir, optionsFile), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save simulation options: %w", err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate agent executable: %w", err)
+	}
+	for name := range shims {
+		link := filepath.Join(binDir, name)
+		os.Remove(link)
+		if err := os.Symlink(executable, link); err != nil {
+			return nil, fmt.Errorf("failed to create %s shim: %w", name, err)
+		}
+	}
+	os.Setenv(envDir, opts.Dir)
+	os.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	installNVML(opts)
+	return &Simulator{opts: opts}, nil
+}
+
+// Run 按设定的故障率随机使运行中的容器因OOM退出，直到ctx取消
+func (s *Simulator) Run(ctx context.Context) {
+	if s.opts.FailuresPerHour <= 0 {
+		return
+	}
+	const tick = 10 * time.Second
+	probability := s.opts.FailuresPerHour * tick.Hours()
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := withState(s.opts.Dir, func(st *state) error {
+			for _, c := range st.Containers {
+				if !c.running() || rand.Float64() >= probability {
+					continue
+				}
+				if err := stopContainer(s.opts.Dir, c, 137, true); err != nil {
+					return err
+				}
+				// 按重启策略由模拟的docker守护进程重启
+				if c.RestartPolicy == "always" || c.RestartPolicy == "unless-stopped" ||
+					(c.RestartPolicy == "on-failure" && (c.MaxRetries == 0 || c.RestartCount < c.MaxRetries)) {
+					c.RestartCount++
+					if err := startContainer(s.opts.Dir, c); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("Warning: simulated failure injection failed: %v\n", err)
+		}
+	}
+}
+
+// loadOptions 读取 Setup 保存的模拟选项
+func loadOptions(dir string) (Options, error) {
+	var opts Options
+	data, err := os.ReadFile(filepath.Join(dir, optionsFile))
+	if err != nil {
+		return opts, fmt.Errorf("failed to read simulation options: %w", err)
+	}
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return opts, fmt.Errorf("failed to parse simulation options: %w", err)
+	}
+	return opts, nil
+}
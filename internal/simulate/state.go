@@ -0,0 +1,256 @@
+package simulate
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 状态目录中的文件
+const (
+	stateFile   = "state.json"
+	lockFile    = "state.lock"
+	eventsFile  = "events.jsonl"
+	optionsFile = "simulation.json"
+)
+
+// portBinding 容器端口绑定（与 docker inspect 的格式一致）
+type portBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+// mount 容器挂载（与 docker inspect 的格式一致）
+type mount struct {
+	Type        string `json:"Type"`
+	Source      string `json:"Source"`
+	Destination string `json:"Destination"`
+	RW          bool   `json:"RW"`
+}
+
+// simContainer 模拟的容器
+type simContainer struct {
+	ID            string                   `json:"id"`
+	Name          string                   `json:"name"`
+	Image         string                   `json:"image"`
+	Created       time.Time                `json:"created"`
+	StartedAt     time.Time                `json:"started_at"`
+	FinishedAt    time.Time                `json:"finished_at"`
+	Status        string                   `json:"status"`
+	ExitCode      int                      `json:"exit_code"`
+	OOMKilled     bool                     `json:"oom_killed"`
+	RestartCount  int                      `json:"restart_count"`
+	Labels        map[string]string        `json:"labels"`
+	Env           []string                 `json:"env"`
+	Cmd           []string                 `json:"cmd"`
+	WorkingDir    string                   `json:"working_dir"`
+	User          string                   `json:"user"`
+	Ports         map[string][]portBinding `json:"ports"`
+	Mounts        []mount                  `json:"mounts"`
+	RestartPolicy string                   `json:"restart_policy"`
+	MaxRetries    int                      `json:"max_retries"`
+	NetworkMode   string                   `json:"network_mode"`
+	IPAddress     string                   `json:"ip_address"`
+	SizeRw        int64                    `json:"size_rw"`
+}
+
+// running 容器是否在运行
+func (c *simContainer) running() bool {
+	return c.Status == "running"
+}
+
+// gpuIDs 容器分配的GPU（utopia.gpu_ids 标签）
+func (c *simContainer) gpuIDs() []int {
+	var ids []int
+	for _, s := range strings.Split(c.Labels["utopia.gpu_ids"], ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// state 模拟的docker守护进程状态
+type state struct {
+	Containers map[string]*simContainer `json:"containers"`
+	// 本地镜像引用 -> 镜像ID
+	Images map[string]string `json:"images"`
+	// 命名卷 -> 标签
+	Volumes map[string]map[string]string `json:"volumes"`
+	// 下一个分配的容器IP后缀
+	NextIP int `json:"next_ip"`
+}
+
+// find 按完整ID、ID前缀或名称查找容器
+func (s *state) find(ref string) *simContainer {
+	ref = strings.TrimPrefix(ref, "/")
+	if c, ok := s.Containers[ref]; ok {
+		return c
+	}
+	for _, c := range s.Containers {
+		if c.Name == ref || (len(ref) >= 12 && strings.HasPrefix(c.ID, ref)) {
+			return c
+		}
+	}
+	return nil
+}
+
+// withState 在文件锁内读取状态、执行fn并写回，fn返回错误时不写回
+func withState(dir string, fn func(*state) error) error {
+	unlock, err := lockState(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := readState(dir)
+	if err != nil {
+		return err
+	}
+	if err := fn(s); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, stateFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, stateFile))
+}
+
+// loadState 只读地读取状态
+func loadState(dir string) (*state, error) {
+	unlock, err := lockState(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return readState(dir)
+}
+
+// lockState 获取状态目录的排他锁，替身命令以独立进程并发运行
+func lockState(dir string) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(dir, lockFile), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open simulation state lock: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock simulation state: %w", err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// readState 读取状态文件，不存在时返回空状态（调用方需持有锁）
+func readState(dir string) (*state, error) {
+	s := &state{}
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("failed to parse simulation state: %w", err)
+		}
+	}
+	if s.Containers == nil {
+		s.Containers = make(map[string]*simContainer)
+	}
+	if s.Images == nil {
+		s.Images = make(map[string]string)
+	}
+	if s.Volumes == nil {
+		s.Volumes = make(map[string]map[string]string)
+	}
+	if s.NextIP < 2 {
+		s.NextIP = 2
+	}
+	return s, nil
+}
+
+// dockerEvent 模拟的docker事件（与 docker events --format '{{json .}}' 的格式一致）
+type dockerEvent struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	Scope    string `json:"scope"`
+	Time     int64  `json:"time"`
+	TimeNano int64  `json:"timeNano"`
+}
+
+// appendEvent 追加容器事件（调用方需持有锁）
+func appendEvent(dir, action string, c *simContainer, extra map[string]string) error {
+	event := dockerEvent{Type: "container", Action: action, Scope: "local"}
+	event.Actor.ID = c.ID
+	event.Actor.Attributes = map[string]string{"image": c.Image, "name": c.Name}
+	for k, v := range c.Labels {
+		event.Actor.Attributes[k] = v
+	}
+	for k, v := range extra {
+		event.Actor.Attributes[k] = v
+	}
+	now := time.Now()
+	event.Time, event.TimeNano = now.Unix(), now.UnixNano()
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, eventsFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// stopContainer 将运行中的容器标记为已退出并追加 die 事件（调用方需持有锁）
+func stopContainer(dir string, c *simContainer, exitCode int, oom bool) error {
+	if !c.running() {
+		return nil
+	}
+	c.Status = "exited"
+	c.ExitCode = exitCode
+	c.OOMKilled = oom
+	c.FinishedAt = time.Now().UTC()
+	if oom {
+		if err := appendEvent(dir, "oom", c, nil); err != nil {
+			return err
+		}
+	}
+	return appendEvent(dir, "die", c, map[string]string{"exitCode": strconv.Itoa(exitCode)})
+}
+
+// startContainer 启动容器并追加 start 事件（调用方需持有锁）
+func startContainer(dir string, c *simContainer) error {
+	c.Status = "running"
+	c.ExitCode = 0
+	c.OOMKilled = false
+	c.StartedAt = time.Now().UTC()
+	return appendEvent(dir, "start", c, nil)
+}
+
+// randomID 生成64位十六进制的ID
+func randomID() string {
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
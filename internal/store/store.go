@@ -0,0 +1,123 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fileSuffix 记录文件后缀
+const fileSuffix = ".json"
+
+// Store 基于目录的本地持久化存储，每个键对应一个JSON文件
+// 写入采用临时文件+重命名，进程崩溃时不会留下半写的记录
+type Store struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// Open 打开（必要时创建）位于dir的存储
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Put 保存键对应的值
+func (s *Store) Put(key string, value interface{}) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to move temp file: %w", err)
+	}
+	return nil
+}
+
+// Get 读取键对应的值，键不存在时返回false
+func (s *Store) Get(key string, value interface{}) (bool, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	data, err := os.ReadFile(path)
+	s.mu.Unlock()
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	if err := json.Unmarshal(data, value); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// Delete 删除键，键不存在时不报错
+func (s *Store) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// Keys 按字典序列出所有键
+func (s *Store) Keys() ([]string, error) {
+	s.mu.Lock()
+	entries, err := os.ReadDir(s.dir)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list store: %w", err)
+	}
+
+	var keys []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		keys = append(keys, strings.TrimSuffix(name, fileSuffix))
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// path 返回键对应的文件路径，拒绝包含路径分隔符的键
+func (s *Store) path(key string) (string, error) {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return "", fmt.Errorf("invalid store key %q", key)
+	}
+	return filepath.Join(s.dir, key+fileSuffix), nil
+}
@@ -0,0 +1,232 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 任务状态
+const (
+	StateRunning    = "running"
+	StateRestarting = "restarting"
+	StateStopped    = "stopped"
+)
+
+// 崩溃后重启的退避：从minBackoff开始翻倍，稳定运行stableAfter后重置
+const (
+	minBackoff  = time.Second
+	maxBackoff  = time.Minute
+	stableAfter = 5 * time.Minute
+)
+
+// unhealthyAfterFailures 周期任务连续失败达到该次数视为不健康
+const unhealthyAfterFailures = 3
+
+// maxStackLength 保留的panic堆栈长度
+const maxStackLength = 4096
+
+// TaskStatus 后台任务的运行状态
+type TaskStatus struct {
+	Name     string `json:"name"`
+	State    string `json:"state"`
+	Healthy  bool   `json:"healthy"`
+	Restarts int    `json:"restarts"`
+	// 周期任务最近一次执行与成功的时间，以及连续失败次数
+	LastRun             int64  `json:"last_run,omitempty"`
+	LastSuccess         int64  `json:"last_success,omitempty"`
+	LastError           string `json:"last_error,omitempty"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	// 最近一次panic的信息与时间
+	LastPanic     string `json:"last_panic,omitempty"`
+	LastPanicTime int64  `json:"last_panic_time,omitempty"`
+	// 周期任务的间隔（秒），超过三个周期未执行视为停滞
+	IntervalSeconds int `json:"interval_seconds,omitempty"`
+}
+
+// task 单个任务的状态
+type task struct {
+	status    TaskStatus
+	startedAt time.Time
+}
+
+// Supervisor 运行后台任务，恢复panic并按退避重启，记录每个任务的运行状态
+type Supervisor struct {
+	ctx context.Context
+	wg  *sync.WaitGroup
+
+	mu    sync.Mutex
+	tasks map[string]*task
+}
+
+// New 创建任务监管器，任务goroutine计入wg，ctx取消后任务不再重启
+func New(ctx context.Context, wg *sync.WaitGroup) *Supervisor {
+	return &Supervisor{
+		ctx:   ctx,
+		wg:    wg,
+		tasks: make(map[string]*task),
+	}
+}
+
+// Go 在后台运行任务，panic时记录并在退避后重启；任务正常返回时不再重启
+func (s *Supervisor) Go(name string, run func(ctx context.Context)) {
+	s.mu.Lock()
+	t := &task{status: TaskStatus{Name: name, State: StateRunning}, startedAt: time.Now()}
+	s.tasks[name] = t
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		backoff := minBackoff
+		for {
+			panicked := s.runOnce(name, run)
+			if !panicked || s.ctx.Err() != nil {
+				s.setState(name, StateStopped)
+				return
+			}
+
+			// 稳定运行一段时间后的崩溃从最小退避开始
+			s.mu.Lock()
+			if time.Since(t.startedAt) > stableAfter {
+				backoff = minBackoff
+			}
+			t.status.State = StateRestarting
+			s.mu.Unlock()
+
+			fmt.Printf("Warning: background task %s crashed, restarting in %s\n", name, backoff)
+			select {
+			case <-s.ctx.Done():
+				s.setState(name, StateStopped)
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+
+			s.mu.Lock()
+			t.status.Restarts++
+			t.status.State = StateRunning
+			t.startedAt = time.Now()
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// runOnce 运行一次任务，返回是否发生panic
+func (s *Supervisor) runOnce(name string, run func(ctx context.Context)) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			stack := debug.Stack()
+			if len(stack) > maxStackLength {
+				stack = stack[:maxStackLength]
+			}
+			fmt.Printf("Error: background task %s panicked: %v\n%s\n", name, r, stack)
+
+			s.mu.Lock()
+			if t, exists := s.tasks[name]; exists {
+				t.status.LastPanic = fmt.Sprintf("%v\n%s", r, stack)
+				t.status.LastPanicTime = time.Now().Unix()
+			}
+			s.mu.Unlock()
+		}
+	}()
+	run(s.ctx)
+	return false
+}
+
+// SetInterval 设置周期任务当前的执行间隔，用于判断任务是否停滞
+func (s *Supervisor) SetInterval(name string, interval time.Duration) {
+	s.mu.Lock()
+	if t, exists := s.tasks[name]; exists {
+		t.status.IntervalSeconds = int(interval.Seconds())
+	}
+	s.mu.Unlock()
+}
+
+// Report 记录周期任务一次执行的结果
+func (s *Supervisor) Report(name string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, exists := s.tasks[name]
+	if !exists {
+		return
+	}
+
+	now := time.Now().Unix()
+	t.status.LastRun = now
+	if err != nil {
+		t.status.LastError = err.Error()
+		t.status.ConsecutiveFailures++
+		return
+	}
+	t.status.LastSuccess = now
+	t.status.LastError = ""
+	t.status.ConsecutiveFailures = 0
+}
+
+// Status 返回所有任务的状态，按名称排序
+func (s *Supervisor) Status() []TaskStatus {
+	now := time.Now()
+
+	s.mu.Lock()
+	result := make([]TaskStatus, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		status := t.status
+		status.Healthy = healthy(status, t.startedAt, now)
+		result = append(result, status)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Unhealthy 返回不健康的任务名称
+func (s *Supervisor) Unhealthy() []string {
+	var names []string
+	for _, status := range s.Status() {
+		if !status.Healthy {
+			names = append(names, status.Name)
+		}
+	}
+	return names
+}
+
+// setState 设置任务状态
+func (s *Supervisor) setState(name, state string) {
+	s.mu.Lock()
+	if t, exists := s.tasks[name]; exists {
+		t.status.State = state
+	}
+	s.mu.Unlock()
+}
+
+// healthy 判断任务是否健康：未在重启中、周期任务未连续失败且未停滞
+// 正常结束（stopped）的任务视为健康
+func healthy(status TaskStatus, startedAt, now time.Time) bool {
+	if status.State == StateRestarting {
+		return false
+	}
+	if status.State == StateStopped {
+		return true
+	}
+	if status.ConsecutiveFailures >= unhealthyAfterFailures {
+		return false
+	}
+	if status.IntervalSeconds > 0 {
+		last := startedAt
+		if status.LastRun > 0 && time.Unix(status.LastRun, 0).After(last) {
+			last = time.Unix(status.LastRun, 0)
+		}
+		if now.Sub(last) > 3*time.Duration(status.IntervalSeconds)*time.Second+time.Minute {
+			return false
+		}
+	}
+	return true
+}
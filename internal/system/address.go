@@ -0,0 +1,103 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// addressProbeTimeout 查询公网IP的超时
+const addressProbeTimeout = 10 * time.Second
+
+// NodeAddress 节点的主机名与IP地址
+type NodeAddress struct {
+	Hostname string `json:"hostname"`
+	// 访问平台时使用的本机地址
+	PrimaryIP string `json:"primary_ip"`
+	PublicIP  string `json:"public_ip,omitempty"`
+}
+
+// DetectAddress 检测节点当前的主机名、主IP与公网IP
+// 主IP取连接平台时内核选择的源地址（UDP连接不发送数据）；publicIPURL为空时不检测公网IP
+func DetectAddress(ctx context.Context, platformURL, publicIPURL string) (NodeAddress, error) {
+	var addr NodeAddress
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return addr, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	addr.Hostname = hostname
+
+	primaryIP, err := primaryIPFor(platformURL)
+	if err != nil {
+		return addr, err
+	}
+	addr.PrimaryIP = primaryIP
+
+	if publicIPURL != "" {
+		publicIP, err := fetchPublicIP(ctx, publicIPURL)
+		if err != nil {
+			return addr, err
+		}
+		addr.PublicIP = publicIP
+	}
+	return addr, nil
+}
+
+// primaryIPFor 返回访问目标地址时使用的本机源地址
+func primaryIPFor(target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid platform URL %q", target)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	conn, err := net.Dial("udp", host)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine primary IP: %w", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
+// fetchPublicIP 查询公网IP
+func fetchPublicIP(ctx context.Context, publicIPURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, addressProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, publicIPURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create public IP request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query public IP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("public IP service returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", fmt.Errorf("failed to read public IP: %w", err)
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return "", fmt.Errorf("public IP service returned an invalid address")
+	}
+	return ip.String(), nil
+}
@@ -0,0 +1,435 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskSectorBytes /proc/diskstats 中扇区数的单位，与设备实际扇区大小无关
+const diskSectorBytes = 512
+
+// hostMinInterval 两次采集的最小间隔，间隔内重复调用返回上次结果，避免速率因间隔过短失真
+const hostMinInterval = time.Second
+
+// HostMetricsOptions 主机指标采集范围
+type HostMetricsOptions struct {
+	// 采集的块设备与网卡（支持通配符），为空时采集全部物理块设备与除lo、veth外的网卡
+	Devices    []string
+	Interfaces []string
+}
+
+// HostMetrics 主机的IO、网络、大页内存与资源压力指标，速率按两次采集之间的增量计算
+type HostMetrics struct {
+	// 内核未启用PSI时为nil
+	Pressure  *Pressure      `json:"pressure,omitempty"`
+	Disks     []DiskIOStats  `json:"disks,omitempty"`
+	Network   []NetworkStats `json:"network,omitempty"`
+	HugePages *HugePages     `json:"hugepages,omitempty"`
+}
+
+// Pressure /proc/pressure 中CPU、内存与IO的压力停顿信息（PSI）
+type Pressure struct {
+	CPU    *PressureStats `json:"cpu,omitempty"`
+	Memory *PressureStats `json:"memory,omitempty"`
+	IO     *PressureStats `json:"io,omitempty"`
+}
+
+// PressureStats 一类资源的压力停顿信息
+// some为至少一个任务因该资源停顿的时间占比，full为全部非空闲任务同时停顿的时间占比（百分比，10/60/300秒平均）
+type PressureStats struct {
+	SomeAvg10  float64 `json:"some_avg10"`
+	SomeAvg60  float64 `json:"some_avg60"`
+	SomeAvg300 float64 `json:"some_avg300"`
+	FullAvg10  float64 `json:"full_avg10"`
+	FullAvg60  float64 `json:"full_avg60"`
+	FullAvg300 float64 `json:"full_avg300"`
+	// 累计停顿时间（微秒）
+	SomeTotalUs uint64 `json:"some_total_us"`
+	FullTotalUs uint64 `json:"full_total_us"`
+}
+
+// DiskIOStats 块设备的IO统计，累计值自开机起计
+type DiskIOStats struct {
+	Device     string `json:"device"`
+	Reads      uint64 `json:"reads"`
+	Writes     uint64 `json:"writes"`
+	ReadBytes  uint64 `json:"read_bytes"`
+	WriteBytes uint64 `json:"write_bytes"`
+	// 当前正在进行的IO数
+	InFlight         uint64  `json:"in_flight"`
+	ReadIOPS         float64 `json:"read_iops"`
+	WriteIOPS        float64 `json:"write_iops"`
+	ReadBytesPerSec  float64 `json:"read_bytes_per_sec"`
+	WriteBytesPerSec float64 `json:"write_bytes_per_sec"`
+	// 设备有IO在处理的时间占比
+	UtilizationPercent float64 `json:"utilization_percent"`
+	// 完成的IO平均耗时（毫秒，含排队）
+	AwaitMs float64 `json:"await_ms"`
+}
+
+// NetworkStats 网卡的流量与错误统计，累计值自网卡创建起计
+type NetworkStats struct {
+	Interface     string  `json:"interface"`
+	RxBytes       uint64  `json:"rx_bytes"`
+	TxBytes       uint64  `json:"tx_bytes"`
+	RxErrors      uint64  `json:"rx_errors"`
+	TxErrors      uint64  `json:"tx_errors"`
+	RxDropped     uint64  `json:"rx_dropped"`
+	TxDropped     uint64  `json:"tx_dropped"`
+	RxBytesPerSec float64 `json:"rx_bytes_per_sec"`
+	TxBytesPerSec float64 `json:"tx_bytes_per_sec"`
+	// 两次采集之间新增的收发错误与丢包数
+	NewErrors  uint64 `json:"new_errors"`
+	NewDropped uint64 `json:"new_dropped"`
+}
+
+// HugePages /proc/meminfo 中的默认大小大页内存统计
+type HugePages struct {
+	Total      int64 `json:"total"`
+	Free       int64 `json:"free"`
+	Reserved   int64 `json:"reserved"`
+	Surplus    int64 `json:"surplus"`
+	PageSizeKB int64 `json:"page_size_kb"`
+}
+
+// diskCounters /proc/diskstats 中一个设备的原始计数
+type diskCounters struct {
+	reads, readSectors, readMs    uint64
+	writes, writeSectors, writeMs uint64
+	inFlight, ioMs                uint64
+}
+
+// hostCollector 主机指标采集器，保存上次的计数用于计算速率
+type hostCollector struct {
+	mu       sync.Mutex
+	options  HostMetricsOptions
+	last     *HostMetrics
+	lastTime time.Time
+	disks    map[string]diskCounters
+	network  map[string]NetworkStats
+}
+
+// EnableHostMetrics 启用主机IO、网络、大页内存与PSI指标采集
+func (m *Monitor) EnableHostMetrics(options HostMetricsOptions) {
+	m.mu.Lock()
+	m.host = &hostCollector{options: options}
+	m.mu.Unlock()
+}
+
+// hostMetrics 采集主机指标，未启用时返回nil
+func (m *Monitor) hostMetrics() *HostMetrics {
+	m.mu.Lock()
+	host := m.host
+	m.mu.Unlock()
+	if host == nil {
+		return nil
+	}
+	return host.collect()
+}
+
+// collect 采集一次主机指标，读取失败的部分留空
+func (h *hostCollector) collect() *HostMetrics {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	if h.last != nil && now.Sub(h.lastTime) < hostMinInterval {
+		return h.last
+	}
+	elapsed := now.Sub(h.lastTime).Seconds()
+	if h.lastTime.IsZero() {
+		elapsed = 0
+	}
+
+	metrics := &HostMetrics{Pressure: readPressure()}
+	if hugePages, err := readHugePages(); err == nil {
+		metrics.HugePages = hugePages
+	}
+
+	if disks, err := readDiskStats(); err == nil {
+		metrics.Disks = h.diskStats(disks, elapsed)
+	} else {
+		fmt.Printf("Warning: failed to read disk stats: %v\n", err)
+	}
+	if network, err := readNetworkStats(); err == nil {
+		metrics.Network = h.networkStats(network, elapsed)
+	} else {
+		fmt.Printf("Warning: failed to read network stats: %v\n", err)
+	}
+
+	h.last = metrics
+	h.lastTime = now
+	return metrics
+}
+
+// diskStats 按采集范围筛选设备并计算速率
+func (h *hostCollector) diskStats(disks map[string]diskCounters, elapsed float64) []DiskIOStats {
+	var stats []DiskIOStats
+	for _, device := range sortedKeys(disks) {
+		if !h.includeDevice(device) {
+			continue
+		}
+		cur := disks[device]
+		s := DiskIOStats{
+			Device:     device,
+			Reads:      cur.reads,
+			Writes:     cur.writes,
+			ReadBytes:  cur.readSectors * diskSectorBytes,
+			WriteBytes: cur.writeSectors * diskSectorBytes,
+			InFlight:   cur.inFlight,
+		}
+		if prev, ok := h.disks[device]; ok && elapsed > 0 {
+			reads := counterDelta(cur.reads, prev.reads)
+			writes := counterDelta(cur.writes, prev.writes)
+			s.ReadIOPS = float64(reads) / elapsed
+			s.WriteIOPS = float64(writes) / elapsed
+			s.ReadBytesPerSec = float64(counterDelta(cur.readSectors, prev.readSectors)*diskSectorBytes) / elapsed
+			s.WriteBytesPerSec = float64(counterDelta(cur.writeSectors, prev.writeSectors)*diskSectorBytes) / elapsed
+			s.UtilizationPercent = min(float64(counterDelta(cur.ioMs, prev.ioMs))/(elapsed*10), 100)
+			if ios := reads + writes; ios > 0 {
+				s.AwaitMs = float64(counterDelta(cur.readMs, prev.readMs)+counterDelta(cur.writeMs, prev.writeMs)) / float64(ios)
+			}
+		}
+		stats = append(stats, s)
+	}
+	h.disks = disks
+	return stats
+}
+
+// networkStats 按采集范围筛选网卡并计算速率
+func (h *hostCollector) networkStats(network map[string]NetworkStats, elapsed float64) []NetworkStats {
+	var stats []NetworkStats
+	for _, iface := range sortedKeys(network) {
+		if !h.includeInterface(iface) {
+			continue
+		}
+		s := network[iface]
+		if prev, ok := h.network[iface]; ok && elapsed > 0 {
+			s.RxBytesPerSec = float64(counterDelta(s.RxBytes, prev.RxBytes)) / elapsed
+			s.TxBytesPerSec = float64(counterDelta(s.TxBytes, prev.TxBytes)) / elapsed
+			s.NewErrors = counterDelta(s.RxErrors, prev.RxErrors) + counterDelta(s.TxErrors, prev.TxErrors)
+			s.NewDropped = counterDelta(s.RxDropped, prev.RxDropped) + counterDelta(s.TxDropped, prev.TxDropped)
+		}
+		stats = append(stats, s)
+	}
+	h.network = network
+	return stats
+}
+
+// includeDevice 判断是否采集该块设备，默认只采集 /sys/block 下的非loop、ram设备（不含分区）
+func (h *hostCollector) includeDevice(device string) bool {
+	if len(h.options.Devices) > 0 {
+		return matchAny(h.options.Devices, device)
+	}
+	if strings.HasPrefix(device, "loop") || strings.HasPrefix(device, "ram") {
+		return false
+	}
+	_, err := os.Stat(filepath.Join("/sys/block", device))
+	return err == nil
+}
+
+// includeInterface 判断是否采集该网卡，默认跳过lo与容器的veth
+func (h *hostCollector) includeInterface(iface string) bool {
+	if len(h.options.Interfaces) > 0 {
+		return matchAny(h.options.Interfaces, iface)
+	}
+	return iface != "lo" && !strings.HasPrefix(iface, "veth")
+}
+
+// readPressure 读取 /proc/pressure，内核不支持PSI时返回nil
+func readPressure() *Pressure {
+	pressure := &Pressure{
+		CPU:    readPressureFile("/proc/pressure/cpu"),
+		Memory: readPressureFile("/proc/pressure/memory"),
+		IO:     readPressureFile("/proc/pressure/io"),
+	}
+	if pressure.CPU == nil && pressure.Memory == nil && pressure.IO == nil {
+		return nil
+	}
+	return pressure
+}
+
+// readPressureFile 解析PSI文件，格式为 "some avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+func readPressureFile(path string) *PressureStats {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	stats := &PressureStats{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var avg10, avg60, avg300 *float64
+		var total *uint64
+		switch fields[0] {
+		case "some":
+			avg10, avg60, avg300, total = &stats.SomeAvg10, &stats.SomeAvg60, &stats.SomeAvg300, &stats.SomeTotalUs
+		case "full":
+			avg10, avg60, avg300, total = &stats.FullAvg10, &stats.FullAvg60, &stats.FullAvg300, &stats.FullTotalUs
+		default:
+			continue
+		}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "avg10":
+				*avg10, _ = strconv.ParseFloat(value, 64)
+			case "avg60":
+				*avg60, _ = strconv.ParseFloat(value, 64)
+			case "avg300":
+				*avg300, _ = strconv.ParseFloat(value, 64)
+			case "total":
+				*total, _ = strconv.ParseUint(value, 10, 64)
+			}
+		}
+	}
+	return stats
+}
+
+// readDiskStats 读取 /proc/diskstats
+func readDiskStats() (map[string]diskCounters, error) {
+	file, err := os.Open("/proc/diskstats")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	disks := make(map[string]diskCounters)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// major minor name reads merged sectors ms writes merged sectors ms in_flight io_ms weighted_ms ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 14 {
+			continue
+		}
+		values := make([]uint64, 11)
+		for i := range values {
+			values[i], _ = strconv.ParseUint(fields[i+3], 10, 64)
+		}
+		disks[fields[2]] = diskCounters{
+			reads:        values[0],
+			readSectors:  values[2],
+			readMs:       values[3],
+			writes:       values[4],
+			writeSectors: values[6],
+			writeMs:      values[7],
+			inFlight:     values[8],
+			ioMs:         values[9],
+		}
+	}
+	return disks, scanner.Err()
+}
+
+// readNetworkStats 读取 /proc/net/dev
+func readNetworkStats() (map[string]NetworkStats, error) {
+	file, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	network := make(map[string]NetworkStats)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 前两行为表头；每行为 "iface: rx_bytes packets errs drop fifo frame compressed multicast tx_bytes packets errs drop ..."
+		name, counters, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 16 {
+			continue
+		}
+		values := make([]uint64, 16)
+		for i := range values {
+			values[i], _ = strconv.ParseUint(fields[i], 10, 64)
+		}
+		iface := strings.TrimSpace(name)
+		network[iface] = NetworkStats{
+			Interface: iface,
+			RxBytes:   values[0],
+			RxErrors:  values[2],
+			RxDropped: values[3],
+			TxBytes:   values[8],
+			TxErrors:  values[10],
+			TxDropped: values[11],
+		}
+	}
+	return network, scanner.Err()
+}
+
+// readHugePages 读取 /proc/meminfo 中的大页内存统计
+func readHugePages() (*HugePages, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hugePages := &HugePages{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSuffix(fields[0], ":") {
+		case "HugePages_Total":
+			hugePages.Total = value
+		case "HugePages_Free":
+			hugePages.Free = value
+		case "HugePages_Rsvd":
+			hugePages.Reserved = value
+		case "HugePages_Surp":
+			hugePages.Surplus = value
+		case "Hugepagesize":
+			hugePages.PageSizeKB = value
+		}
+	}
+	return hugePages, scanner.Err()
+}
+
+// counterDelta 计算计数器增量，计数器回绕或重置时返回0
+func counterDelta(cur, prev uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
+
+// matchAny 判断名称是否匹配任一通配符模式
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys 返回按名称排序的键
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
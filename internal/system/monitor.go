@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // SystemMetrics 系统指标
@@ -17,10 +18,16 @@ type SystemMetrics struct {
 	DiskUsagePercent   float64 `json:"disk_usage_percent"`
 	LoadAverage        float64 `json:"load_average"`
 	Uptime             int64   `json:"uptime"`
+	// 磁盘IO、网卡错误、大页内存与PSI，未启用主机指标时为nil
+	Host *HostMetrics `json:"host,omitempty"`
 }
 
 // Monitor 系统监控器
-type Monitor struct{}
+type Monitor struct {
+	mu       sync.Mutex
+	versions *VersionInfo
+	host     *hostCollector
+}
 
 // NewMonitor 创建新的系统监控器
 func NewMonitor() *Monitor {
@@ -59,6 +66,8 @@ func (m *Monitor) GetSystemMetrics() (*SystemMetrics, error) {
 		metrics.Uptime = uptime
 	}
 
+	metrics.Host = m.hostMetrics()
+
 	return metrics, nil
 }
 
@@ -0,0 +1,144 @@
+package system
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 启动原因
+const (
+	BootReasonFirstBoot     = "first_boot"
+	BootReasonRequested     = "agent_requested"
+	BootReasonCleanShutdown = "clean_shutdown"
+	BootReasonUnexpected    = "unexpected"
+)
+
+const (
+	powerIntentFile   = "power_intent.json"
+	cleanShutdownFile = "clean_shutdown"
+	lastBootFile      = "last_boot.json"
+)
+
+// PowerIntent 由Agent发起的电源操作记录
+type PowerIntent struct {
+	Action      string `json:"action"`
+	Reason      string `json:"reason,omitempty"`
+	RequestedAt int64  `json:"requested_at"`
+}
+
+// BootInfo 本次启动信息
+type BootInfo struct {
+	BootTime int64        `json:"boot_time"`
+	Reason   string       `json:"reason"`
+	Intent   *PowerIntent `json:"intent,omitempty"`
+}
+
+// RecordPowerIntent 在执行电源操作前记录意图，用于下次启动时判断启动原因
+func RecordPowerIntent(dataDir string, intent PowerIntent) error {
+	return writeJSONFile(filepath.Join(dataDir, powerIntentFile), intent)
+}
+
+// ClearPowerIntent 删除未执行的电源意图
+func ClearPowerIntent(dataDir string) error {
+	if err := os.Remove(filepath.Join(dataDir, powerIntentFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// MarkCleanShutdown 记录Agent正常退出
+func MarkCleanShutdown(dataDir string) error {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dataDir, cleanShutdownFile), []byte(strconv.FormatInt(time.Now().Unix(), 10)), 0644)
+}
+
+// DetectBoot 判断本次启动的原因
+//
+// 同一次系统启动内Agent多次重启时沿用首次判断的结果；
+// 否则依据上次留下的电源意图和正常退出标记判断，然后清理这些标记。
+func DetectBoot(dataDir string) (*BootInfo, error) {
+	bootTime, err := readBootTime()
+	if err != nil {
+		return nil, err
+	}
+
+	lastBootPath := filepath.Join(dataDir, lastBootFile)
+	intentPath := filepath.Join(dataDir, powerIntentFile)
+	cleanPath := filepath.Join(dataDir, cleanShutdownFile)
+
+	var last BootInfo
+	if data, err := os.ReadFile(lastBootPath); err == nil && json.Unmarshal(data, &last) == nil && last.BootTime == bootTime {
+		// 仅Agent重启：本次退出标记不代表系统关机
+		os.Remove(cleanPath)
+		return &last, nil
+	}
+
+	info := &BootInfo{BootTime: bootTime, Reason: BootReasonFirstBoot}
+
+	var intent PowerIntent
+	if data, err := os.ReadFile(intentPath); err == nil && json.Unmarshal(data, &intent) == nil && intent.RequestedAt <= bootTime {
+		info.Reason = BootReasonRequested
+		info.Intent = &intent
+	} else if _, err := os.Stat(cleanPath); err == nil {
+		info.Reason = BootReasonCleanShutdown
+	} else if _, err := os.Stat(lastBootPath); err == nil {
+		// 曾经运行过但没有留下任何退出记录：崩溃、断电或硬件复位
+		info.Reason = BootReasonUnexpected
+	}
+
+	os.Remove(intentPath)
+	os.Remove(cleanPath)
+	if err := writeJSONFile(lastBootPath, info); err != nil {
+		return info, err
+	}
+
+	return info, nil
+}
+
+// readBootTime 从 /proc/stat 读取系统启动时间
+func readBootTime() (int64, error) {
+	file, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "btime" {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("btime not found in /proc/stat")
+}
+
+// writeJSONFile 原子写入JSON文件
+func writeJSONFile(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to move temp file: %w", err)
+	}
+	return nil
+}
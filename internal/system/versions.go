@@ -0,0 +1,120 @@
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// VersionInfo 节点运行时组件版本
+type VersionInfo struct {
+	Kernel                 string `json:"kernel"`
+	NvidiaDriver           string `json:"nvidia_driver"`
+	Docker                 string `json:"docker"`
+	Containerd             string `json:"containerd"`
+	Runc                   string `json:"runc"`
+	NvidiaContainerToolkit string `json:"nvidia_container_toolkit"`
+	CollectedAt            int64  `json:"collected_at"`
+}
+
+// dockerVersionOutput docker version --format '{{json .}}' 输出中需要的字段
+type dockerVersionOutput struct {
+	Server struct {
+		Version    string `json:"Version"`
+		Components []struct {
+			Name    string `json:"Name"`
+			Version string `json:"Version"`
+		} `json:"Components"`
+	} `json:"Server"`
+}
+
+var (
+	driverVersionPattern  = regexp.MustCompile(`Kernel Module\s+([0-9][0-9.]*)`)
+	toolkitVersionPattern = regexp.MustCompile(`version\s*:?\s*v?([0-9][0-9.]*)`)
+)
+
+// GetVersions 获取组件版本，refresh为true或尚未采集时重新采集
+func (m *Monitor) GetVersions(refresh bool) *VersionInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if refresh || m.versions == nil {
+		m.versions = collectVersions()
+	}
+
+	result := *m.versions
+	return &result
+}
+
+// collectVersions 采集各组件版本，无法获取的组件留空
+func collectVersions() *VersionInfo {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	info := &VersionInfo{
+		Kernel:                 readKernelVersion(),
+		NvidiaDriver:           readNvidiaDriverVersion(),
+		NvidiaContainerToolkit: readNvidiaToolkitVersion(ctx),
+		CollectedAt:            time.Now().Unix(),
+	}
+
+	output, err := exec.CommandContext(ctx, "docker", "version", "--format", "{{json .}}").Output()
+	if err == nil {
+		var dv dockerVersionOutput
+		if err := json.Unmarshal(output, &dv); err == nil {
+			info.Docker = dv.Server.Version
+			for _, component := range dv.Server.Components {
+				switch strings.ToLower(component.Name) {
+				case "containerd":
+					info.Containerd = component.Version
+				case "runc":
+					info.Runc = component.Version
+				}
+			}
+		}
+	}
+
+	return info
+}
+
+// readKernelVersion 读取内核版本
+func readKernelVersion() string {
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readNvidiaDriverVersion 从 /proc/driver/nvidia/version 读取驱动版本
+func readNvidiaDriverVersion() string {
+	data, err := os.ReadFile("/proc/driver/nvidia/version")
+	if err != nil {
+		return ""
+	}
+	if match := driverVersionPattern.FindSubmatch(data); match != nil {
+		return string(match[1])
+	}
+	return ""
+}
+
+// readNvidiaToolkitVersion 读取 nvidia-container-toolkit 版本
+func readNvidiaToolkitVersion(ctx context.Context) string {
+	for _, args := range [][]string{
+		{"nvidia-ctk", "--version"},
+		{"nvidia-container-cli", "--version"},
+	} {
+		output, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+		if err != nil {
+			continue
+		}
+		if match := toolkitVersionPattern.FindSubmatch(output); match != nil {
+			return string(match[1])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader 请求ID头，平台传入时沿用，否则由Agent生成
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern 可以沿用的请求ID
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestIDKey 上下文中保存请求ID的键
+type requestIDKey struct{}
+
+// WithRequestID 返回携带请求ID的上下文
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 返回上下文中的请求ID，不在请求中时为空
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ValidRequestID 判断传入的请求ID能否沿用
+func ValidRequestID(id string) bool {
+	return requestIDPattern.MatchString(id)
+}
+
+// NewRequestID 生成新的请求ID
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Logger 返回带有请求ID与trace ID字段的日志记录器，用于关联同一请求的日志
+func Logger(ctx context.Context) *log.Entry {
+	fields := log.Fields{}
+	if id := RequestID(ctx); id != "" {
+		fields["request_id"] = id
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		fields["trace_id"] = sc.TraceID().String()
+	}
+	return log.WithFields(fields)
+}
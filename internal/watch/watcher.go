@@ -0,0 +1,144 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// dirEvents 监视父目录时关心的事件，覆盖原地写入与原子替换（rename）两种更新方式
+const dirEvents = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM
+
+// FileStatus 被监视文件最近一次加载的结果
+type FileStatus struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	LoadedAt int64  `json:"loaded_at"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Watcher 基于inotify监视文件变化
+// 监视的是文件所在目录，因此文件被删除后重新创建或被替换时仍能收到通知
+type Watcher struct {
+	fd       int
+	debounce time.Duration
+
+	mu       sync.Mutex
+	dirs     map[int]string    // watch descriptor -> 目录
+	watched  map[string]int    // 目录 -> watch descriptor
+	handlers map[string]func() // 文件路径 -> 变化回调
+}
+
+// NewWatcher 创建文件监视器，同一文件在debounce内的连续事件合并为一次回调
+func NewWatcher(debounce time.Duration) (*Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize inotify: %w", err)
+	}
+	return &Watcher{
+		fd:       fd,
+		debounce: debounce,
+		dirs:     make(map[int]string),
+		watched:  make(map[string]int),
+		handlers: make(map[string]func()),
+	}, nil
+}
+
+// Add 监视文件，文件被写入、替换或删除时调用onChange
+func (w *Watcher) Add(path string, onChange func()) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, exists := w.watched[dir]; !exists {
+		wd, err := unix.InotifyAddWatch(w.fd, dir, dirEvents)
+		if err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+		w.dirs[wd] = dir
+		w.watched[dir] = wd
+	}
+	w.handlers[path] = onChange
+	return nil
+}
+
+// Run 处理文件事件直到ctx取消
+func (w *Watcher) Run(ctx context.Context) {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	pending := make(map[string]time.Time)
+	pollFds := []unix.PollFd{{Fd: int32(w.fd), Events: unix.POLLIN}}
+
+	for ctx.Err() == nil {
+		// 定期醒来以检查ctx并触发已稳定的回调
+		n, err := unix.Poll(pollFds, 200)
+		if err != nil && err != unix.EINTR {
+			fmt.Printf("Warning: file watcher poll failed: %v\n", err)
+			return
+		}
+
+		if n > 0 {
+			for _, path := range w.readEvents(buf) {
+				pending[path] = time.Now()
+			}
+		}
+
+		for path, last := range pending {
+			if time.Since(last) < w.debounce {
+				continue
+			}
+			delete(pending, path)
+			w.mu.Lock()
+			handler := w.handlers[path]
+			w.mu.Unlock()
+			if handler != nil {
+				handler()
+			}
+		}
+	}
+}
+
+// readEvents 读取所有就绪的inotify事件，返回其中被监视的文件路径
+func (w *Watcher) readEvents(buf []byte) []string {
+	var paths []string
+	for {
+		n, err := unix.Read(w.fd, buf)
+		if err != nil || n <= 0 {
+			return paths
+		}
+
+		w.mu.Lock()
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			offset = nameStart + int(event.Len)
+			if event.Len == 0 {
+				continue
+			}
+
+			name := string(buf[nameStart : nameStart+int(event.Len)])
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+			path := filepath.Join(w.dirs[int(event.Wd)], name)
+			if _, watched := w.handlers[path]; watched {
+				paths = append(paths, path)
+			}
+		}
+		w.mu.Unlock()
+	}
+}
+
+// Close 关闭监视器
+func (w *Watcher) Close() error {
+	return unix.Close(w.fd)
+}
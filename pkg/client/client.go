@@ -0,0 +1,250 @@
+// Package client 节点代理 API 的 Go 客户端，供中央平台与内部工具调用
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 默认选项
+const (
+	DefaultAPIVersion   = "v1"
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = 500 * time.Millisecond
+	// maxRetryBackoff 重试间隔上限
+	maxRetryBackoff = 10 * time.Second
+)
+
+// Options 客户端选项，零值字段使用默认值
+type Options struct {
+	// HTTP客户端，默认不设总超时（事件流与日志跟随为长连接），单次调用的超时由ctx控制
+	HTTPClient *http.Client
+	// API版本，默认 v1
+	APIVersion string
+	// 网络错误、429 与 502/503/504 响应的最大重试次数，默认3，负数表示不重试
+	// POST/DELETE 请求携带自动生成的 Idempotency-Key，重试不会重复执行操作
+	MaxRetries int
+	// 首次重试前的等待时间，之后按指数增长（响应带 Retry-After 时以其为准），默认500ms
+	RetryBackoff time.Duration
+}
+
+// Client 节点代理API客户端，可并发使用
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+}
+
+// New 创建客户端，baseURL为Agent地址（如 http://10.0.0.5:9200），token为API认证令牌
+func New(baseURL, token string, opts Options) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/") + "/api/" + DefaultAPIVersion,
+		token:      token,
+		httpClient: opts.HTTPClient,
+		maxRetries: opts.MaxRetries,
+		backoff:    opts.RetryBackoff,
+	}
+	if opts.APIVersion != "" {
+		c.baseURL = strings.TrimRight(baseURL, "/") + "/api/" + opts.APIVersion
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{}
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = DefaultMaxRetries
+	}
+	if c.backoff <= 0 {
+		c.backoff = DefaultRetryBackoff
+	}
+	return c
+}
+
+// APIError Agent返回的错误响应
+type APIError struct {
+	StatusCode int          `json:"-"`
+	RequestID  string       `json:"request_id,omitempty"`
+	Message    string       `json:"error"`
+	Code       int          `json:"code,omitempty"`
+	Details    string       `json:"details,omitempty"`
+	Fields     []FieldError `json:"fields,omitempty"`
+}
+
+// Error 实现error接口
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("agent API returned %d: %s", e.StatusCode, e.Message)
+	if e.Details != "" {
+		msg += ": " + e.Details
+	}
+	if e.RequestID != "" {
+		msg += " (request_id " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// IsNotFound 错误是否为 404 Not Found
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// do 发送请求并将JSON响应解码到out（out为nil时丢弃响应体）
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) (*http.Response, error) {
+	resp, err := c.send(ctx, method, path, query, body, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return resp, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp, fmt.Errorf("failed to decode %s %s response: %w", method, path, err)
+	}
+	return resp, nil
+}
+
+// send 发送请求，按需重试；返回2xx响应（调用方需关闭响应体），其他状态码返回 *APIError
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body interface{}, header http.Header) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	idempotencyKey := ""
+	if method == http.MethodPost || method == http.MethodDelete {
+		idempotencyKey = newIdempotencyKey()
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		if token, ok := ctx.Value(commandSignatureKey{}).(string); ok && token != "" {
+			req.Header.Set("X-Command-Signature", token)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		var retryAfter time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			err = fmt.Errorf("%s %s: %w", method, path, err)
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			return resp, nil
+		default:
+			err = readAPIError(resp)
+			if !retryableStatus(resp.StatusCode) {
+				return nil, err
+			}
+			if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil {
+				retryAfter = time.Duration(seconds) * time.Second
+			}
+		}
+
+		if attempt >= c.maxRetries {
+			return nil, err
+		}
+		if err := sleepContext(ctx, c.retryDelay(attempt, retryAfter)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryDelay 第attempt次重试前的等待时间
+func (c *Client) retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, maxRetryBackoff)
+	}
+	delay := c.backoff << attempt
+	if delay <= 0 || delay > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return delay
+}
+
+// readAPIError 读取并关闭错误响应
+func readAPIError(resp *http.Response) error {
+	defer resp.Body.Close()
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(data))
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+	}
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = resp.Header.Get("X-Request-ID")
+	}
+	return apiErr
+}
+
+// retryableStatus 状态码是否表示可重试的临时错误
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// sleepContext 等待d或ctx取消
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// commandSignatureKey ctx中命令签名令牌的键
+type commandSignatureKey struct{}
+
+// WithCommandSignature 返回携带平台命令签名令牌的ctx，节点启用命令签名时删除容器等破坏性操作需要
+// 令牌须为本次请求签发（方法、路径与请求体一致）且只能使用一次
+func WithCommandSignature(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, commandSignatureKey{}, token)
+}
+
+// newIdempotencyKey 生成随机幂等键
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
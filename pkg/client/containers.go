@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// CreateContainer 创建容器，返回容器ID、发布端口与GPU选择依据
+func (c *Client) CreateContainer(ctx context.Context, req *CreateContainerRequest) (*CreateContainerResponse, error) {
+	var resp CreateContainerResponse
+	if _, err := c.do(ctx, http.MethodPost, "/containers", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateContainerAsync 以异步任务创建容器，返回任务ID（需节点开启 async_create 功能开关），可用 WaitJob 等待结果
+func (c *Client) CreateContainerAsync(ctx context.Context, req *CreateContainerRequest) (string, error) {
+	var resp struct {
+		JobID string `json:"job_id"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/containers", url.Values{"async": {"true"}}, req, &resp); err != nil {
+		return "", err
+	}
+	return resp.JobID, nil
+}
+
+// DeleteContainer 删除容器
+func (c *Client) DeleteContainer(ctx context.Context, containerID string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/containers/"+url.PathEscape(containerID), nil, nil, nil)
+	return err
+}
+
+// GetContainer 获取容器信息，容器不存在时返回的错误满足 IsNotFound
+func (c *Client) GetContainer(ctx context.Context, containerID string) (*Container, error) {
+	var info Container
+	if _, err := c.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(containerID), nil, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// ListContainersOptions 容器列表的过滤、排序与分页条件，零值字段不过滤
+type ListContainersOptions struct {
+	Status  string
+	ClaimID string
+	GPUID   *int
+	// 标签选择器，如 "team=ml"、"team!=ml"、"team"
+	Labels       []string
+	CreatedSince int64
+	// 排序字段，如 "created"、"-created"
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// ListContainers 列出容器，total为分页前符合条件的容器总数
+func (c *Client) ListContainers(ctx context.Context, opts ListContainersOptions) (containers []Container, total int, err error) {
+	query := url.Values{}
+	if opts.Status != "" {
+		query.Set("status", opts.Status)
+	}
+	if opts.ClaimID != "" {
+		query.Set("claim_id", opts.ClaimID)
+	}
+	if opts.GPUID != nil {
+		query.Set("gpu_id", strconv.Itoa(*opts.GPUID))
+	}
+	for _, label := range opts.Labels {
+		query.Add("label", label)
+	}
+	if opts.CreatedSince > 0 {
+		query.Set("created_since", strconv.FormatInt(opts.CreatedSince, 10))
+	}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		query.Set("offset", strconv.Itoa(opts.Offset))
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "/containers", query, nil, &containers)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, convErr := strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	if convErr != nil {
+		total = len(containers)
+	}
+	return containers, total, nil
+}
+
+// LogOptions 容器日志选项
+type LogOptions struct {
+	// 只返回最后Tail行，0使用Agent默认值（100），负数表示全部
+	Tail int
+	// 只返回该时间之后的日志
+	Since time.Time
+	// 持续返回新日志，直到容器停止、ctx取消或关闭返回的流
+	Follow bool
+	// 每行前加RFC3339Nano时间戳
+	Timestamps bool
+}
+
+// Logs 返回容器stdout与stderr合并后的纯文本日志流，调用方需关闭
+func (c *Client) Logs(ctx context.Context, containerID string, opts LogOptions) (io.ReadCloser, error) {
+	query := url.Values{}
+	switch {
+	case opts.Tail < 0:
+		query.Set("tail", "all")
+	case opts.Tail > 0:
+		query.Set("tail", strconv.Itoa(opts.Tail))
+	}
+	if !opts.Since.IsZero() {
+		query.Set("since", strconv.FormatInt(opts.Since.Unix(), 10))
+	}
+	if opts.Follow {
+		query.Set("follow", "true")
+	}
+	if opts.Timestamps {
+		query.Set("timestamps", "true")
+	}
+
+	resp, err := c.send(ctx, http.MethodGet, "/containers/"+url.PathEscape(containerID)+"/logs", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Events 获取序号大于since的最近事件，limit<=0时使用Agent默认值
+func (c *Client) Events(ctx context.Context, since int64, limit int) ([]Event, error) {
+	query := url.Values{"since": {strconv.FormatInt(since, 10)}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var events []Event
+	if _, err := c.do(ctx, http.MethodGet, "/events", query, nil, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// StreamEvents 通过SSE接收序号大于since的事件并依次交给handler（需节点开启 event_stream 功能开关）
+// 连接断开时从最后收到的事件处自动重连；ctx取消、handler返回错误或Agent返回非临时错误时结束
+func (c *Client) StreamEvents(ctx context.Context, since int64, handler func(Event) error) error {
+	failures := 0
+	for {
+		received, err := c.streamEventsOnce(ctx, &since, handler)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var apiErr *APIError
+		var handlerErr *eventHandlerError
+		switch {
+		case errors.As(err, &handlerErr):
+			return handlerErr.err
+		case errors.As(err, &apiErr):
+			return err
+		}
+		if received {
+			failures = 0
+		}
+		if err := sleepContext(ctx, c.retryDelay(failures, 0)); err != nil {
+			return err
+		}
+		failures++
+	}
+}
+
+// eventHandlerError 事件处理函数返回的错误
+type eventHandlerError struct {
+	err error
+}
+
+func (e *eventHandlerError) Error() string { return e.err.Error() }
+
+// streamEventsOnce 建立一次SSE连接并读取到连接结束，返回期间是否收到过事件
+func (c *Client) streamEventsOnce(ctx context.Context, since *int64, handler func(Event) error) (bool, error) {
+	header := http.Header{
+		"Accept":        {"text/event-stream"},
+		"Last-Event-ID": {strconv.FormatInt(*since, 10)},
+	}
+	resp, err := c.send(ctx, http.MethodGet, "/events/stream", nil, nil, header)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	received := false
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var event Event
+			err := json.Unmarshal([]byte(data.String()), &event)
+			data.Reset()
+			if err != nil {
+				continue
+			}
+			received = true
+			*since = event.Seq
+			if err := handler(event); err != nil {
+				return received, &eventHandlerError{err: err}
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return received, scanner.Err()
+}
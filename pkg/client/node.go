@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Metrics 获取节点的GPU与系统指标，GPU指标为Agent后台刷新的缓存
+func (c *Client) Metrics(ctx context.Context) (*Metrics, error) {
+	return c.metrics(ctx, nil)
+}
+
+// RefreshMetrics 获取节点指标，返回前先刷新GPU指标
+func (c *Client) RefreshMetrics(ctx context.Context) (*Metrics, error) {
+	return c.metrics(ctx, url.Values{"refresh": {"true"}})
+}
+
+// metrics 获取节点指标
+func (c *Client) metrics(ctx context.Context, query url.Values) (*Metrics, error) {
+	var metrics Metrics
+	if _, err := c.do(ctx, http.MethodGet, "/metrics", query, nil, &metrics); err != nil {
+		return nil, err
+	}
+	return &metrics, nil
+}
+
+// Tunnels 列出节点的FRP隧道及其状态，claimID非空时只返回该claim发布的端口
+func (c *Client) Tunnels(ctx context.Context, claimID string) ([]Tunnel, error) {
+	query := url.Values{}
+	if claimID != "" {
+		query.Set("claim_id", claimID)
+	}
+	var result struct {
+		Tunnels []Tunnel `json:"tunnels"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/tunnels", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Tunnels, nil
+}
+
+// Job 获取异步任务状态
+func (c *Client) Job(ctx context.Context, jobID string) (*Job, error) {
+	var job Job
+	if _, err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(jobID), nil, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// WaitJob 每interval查询一次任务状态，直到任务成功或失败；任务失败时返回任务本身而不是错误
+func (c *Client) WaitJob(ctx context.Context, jobID string, interval time.Duration) (*Job, error) {
+	for {
+		job, err := c.Job(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if job.Status == JobSucceeded || job.Status == JobFailed {
+			return job, nil
+		}
+		if err := sleepContext(ctx, interval); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package client
+
+import "time"
+
+// 以下类型与 Agent API 的 JSON 结构一一对应，字段含义见 API.md
+
+// PortMapping 端口映射
+type PortMapping struct {
+	HostPort      int    `json:"host_port"`
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol,omitempty"`
+	Name          string `json:"name,omitempty"`
+	// 通过FRP隧道对外发布：http 或 tcp，为空表示不发布
+	Publish string `json:"publish,omitempty"`
+}
+
+// RestartPolicy 容器重启策略
+type RestartPolicy struct {
+	Name       string `json:"name"`
+	MaxRetries int    `json:"max_retries,omitempty"`
+}
+
+// DatasetRef 只读挂载的共享数据集
+type DatasetRef struct {
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256,omitempty"`
+	MountPath string `json:"mount_path"`
+}
+
+// ExternalVolume 由节点挂载后绑定挂载到容器的外部存储（nfs、s3、smb）
+type ExternalVolume struct {
+	Type      string   `json:"type"`
+	Source    string   `json:"source"`
+	MountPath string   `json:"mount_path"`
+	ReadOnly  bool     `json:"read_only,omitempty"`
+	Endpoint  string   `json:"endpoint,omitempty"`
+	Options   []string `json:"options,omitempty"`
+	// 使用节点加密公钥加密的凭据
+	Credentials string `json:"credentials,omitempty"`
+}
+
+// Secret 加密下发的密钥
+type Secret struct {
+	Name       string `json:"name"`
+	Ciphertext string `json:"ciphertext"`
+	Env        string `json:"env,omitempty"`
+	Path       string `json:"path,omitempty"`
+}
+
+// SharedCompute 通过MPS与其他容器共享GPU的选项
+type SharedCompute struct {
+	ActiveThreadPercentage int `json:"active_thread_percentage,omitempty"`
+	MemoryMB               int `json:"memory_mb,omitempty"`
+}
+
+// CreateContainerRequest 创建容器请求
+type CreateContainerRequest struct {
+	ClaimID string `json:"claim_id"`
+	Image   string `json:"image"`
+	// GPU数量，nil表示仅CPU
+	GPUs                *int              `json:"gpus,omitempty"`
+	PortMappings        []PortMapping     `json:"port_mappings,omitempty"`
+	EnvVars             []string          `json:"env_vars,omitempty"`
+	Command             []string          `json:"command,omitempty"`
+	WorkingDir          string            `json:"working_dir,omitempty"`
+	Volumes             map[string]string `json:"volumes,omitempty"`
+	StorageSizeGB       int               `json:"storage_size_gb,omitempty"`
+	CPUs                float64           `json:"cpus,omitempty"`
+	MemoryMB            int               `json:"memory_mb,omitempty"`
+	Datasets            []DatasetRef      `json:"datasets,omitempty"`
+	ExternalVolumes     []ExternalVolume  `json:"external_volumes,omitempty"`
+	RestartPolicy       *RestartPolicy    `json:"restart_policy,omitempty"`
+	DNSServers          []string          `json:"dns_servers,omitempty"`
+	DNSSearch           []string          `json:"dns_search,omitempty"`
+	ExtraHosts          []string          `json:"extra_hosts,omitempty"`
+	Hostname            string            `json:"hostname,omitempty"`
+	Secrets             []Secret          `json:"secrets,omitempty"`
+	SecurityRelaxations []string          `json:"security_relaxations,omitempty"`
+	SharedCompute       *SharedCompute    `json:"shared_compute,omitempty"`
+	GPUFraction         float64           `json:"gpu_fraction,omitempty"`
+	StartAt             int64             `json:"start_at,omitempty"`
+	RuntimeClass        string            `json:"runtime_class,omitempty"`
+}
+
+// PublishedPort 通过隧道对外发布的端口
+type PublishedPort struct {
+	Name          string `json:"name"`
+	ContainerPort int    `json:"container_port"`
+	HostPort      int    `json:"host_port"`
+	RemotePort    int    `json:"remote_port"`
+	Address       string `json:"address"`
+	URL           string `json:"url,omitempty"`
+}
+
+// GPUCandidate GPU选择时的候选评分
+type GPUCandidate struct {
+	GPUID                 int     `json:"gpu_id"`
+	TemperatureC          int     `json:"temperature_c"`
+	AvgUtilizationPercent float64 `json:"avg_utilization_percent"`
+	ThermalScore          float64 `json:"thermal_score"`
+	UtilizationScore      float64 `json:"utilization_score"`
+	Score                 float64 `json:"score"`
+	Selected              bool    `json:"selected"`
+}
+
+// GPUSelection GPU选择结果及依据
+type GPUSelection struct {
+	GPUIDs        []int          `json:"gpu_ids"`
+	Strategy      string         `json:"strategy"`
+	TopologyScore int            `json:"topology_score"`
+	TopologyError string         `json:"topology_error,omitempty"`
+	Candidates    []GPUCandidate `json:"candidates"`
+}
+
+// CreateContainerResponse 创建容器响应
+type CreateContainerResponse struct {
+	ContainerID    string          `json:"container_id"`
+	PublishedPorts []PublishedPort `json:"published_ports,omitempty"`
+	GPUSelection   *GPUSelection   `json:"gpu_selection,omitempty"`
+}
+
+// Hibernation claim的休眠记录
+type Hibernation struct {
+	ClaimID      string `json:"claim_id"`
+	ContainerID  string `json:"container_id"`
+	GPUIDs       []int  `json:"gpu_ids"`
+	KeepGPUs     bool   `json:"keep_gpus"`
+	HibernatedAt int64  `json:"hibernated_at"`
+}
+
+// Container 容器信息
+type Container struct {
+	ID             string            `json:"id"`
+	ClaimID        string            `json:"claim_id"`
+	Image          string            `json:"image"`
+	Status         string            `json:"status"`
+	GPUIDs         []int             `json:"gpu_ids"`
+	Ports          map[string]string `json:"ports"`
+	Created        int64             `json:"created"`
+	Started        int64             `json:"started"`
+	Labels         map[string]string `json:"labels"`
+	RuntimeClass   string            `json:"runtime_class"`
+	PublishedPorts []PublishedPort   `json:"published_ports,omitempty"`
+	IPAddress      string            `json:"ip_address,omitempty"`
+	DNSName        string            `json:"dns_name,omitempty"`
+	GPUSelection   *GPUSelection     `json:"gpu_selection,omitempty"`
+	RestartPolicy  RestartPolicy     `json:"restart_policy"`
+	RestartCount   int               `json:"restart_count"`
+	Hibernation    *Hibernation      `json:"hibernation,omitempty"`
+	Activity       *Activity         `json:"activity,omitempty"`
+	GPUFraction    float64           `json:"gpu_fraction,omitempty"`
+}
+
+// Activity 运行中claim的活动信号与空闲状态
+type Activity struct {
+	GPUUtilizationPercent   float64 `json:"gpu_utilization_percent"`
+	Connections             int     `json:"connections"`
+	NetworkRxBytesPerMinute float64 `json:"network_rx_bytes_per_minute"`
+	LastActiveAt            int64   `json:"last_active_at"`
+	LastActiveSignal        string  `json:"last_active_signal"`
+	IdleSeconds             int64   `json:"idle_seconds"`
+	Idle                    bool    `json:"idle"`
+	StopAt                  int64   `json:"stop_at,omitempty"`
+	CheckedAt               int64   `json:"checked_at"`
+}
+
+// UsageWindow GPU在一个时间窗口内的负载统计
+type UsageWindow struct {
+	AvgUtilizationPercent  float64 `json:"avg_utilization_percent"`
+	PeakUtilizationPercent float64 `json:"peak_utilization_percent"`
+	AvgMemoryUsedMB        float64 `json:"avg_memory_used_mb"`
+	PeakMemoryUsedMB       int     `json:"peak_memory_used_mb"`
+	SampledMinutes         int     `json:"sampled_minutes"`
+}
+
+// UsageHistory GPU最近5分钟、1小时与24小时的负载统计
+type UsageHistory struct {
+	Last5m  UsageWindow `json:"5m"`
+	Last1h  UsageWindow `json:"1h"`
+	Last24h UsageWindow `json:"24h"`
+}
+
+// GPUIntruder 使用已预留GPU的非预留方
+type GPUIntruder struct {
+	Kind          string `json:"kind"`
+	PID           uint32 `json:"pid,omitempty"`
+	ProcessName   string `json:"process_name,omitempty"`
+	UsedMemoryMB  int    `json:"used_memory_mb,omitempty"`
+	ContainerID   string `json:"container_id,omitempty"`
+	ContainerName string `json:"container_name,omitempty"`
+	Image         string `json:"image,omitempty"`
+	ClaimID       string `json:"claim_id,omitempty"`
+}
+
+// GPU GPU状态
+type GPU struct {
+	ID            int           `json:"id"`
+	TemperatureC  int           `json:"temperature_c"`
+	MemoryTotalMB int           `json:"memory_total_mb"`
+	MemoryUsedMB  int           `json:"memory_used_mb"`
+	Name          string        `json:"name"`
+	UUID          string        `json:"uuid"`
+	Busy          bool          `json:"busy"`
+	BusyBy        string        `json:"busy_by,omitempty"`
+	UsagePercent  float64       `json:"usage_percent"`
+	History       *UsageHistory `json:"history,omitempty"`
+	Contended     bool          `json:"contended,omitempty"`
+	Intruders     []GPUIntruder `json:"intruders,omitempty"`
+}
+
+// SystemMetrics 主机系统指标
+type SystemMetrics struct {
+	CPUUsagePercent    float64 `json:"cpu_usage_percent"`
+	MemoryUsagePercent float64 `json:"memory_usage_percent"`
+	MemoryTotalMB      int64   `json:"memory_total_mb"`
+	MemoryUsedMB       int64   `json:"memory_used_mb"`
+	DiskUsagePercent   float64 `json:"disk_usage_percent"`
+	LoadAverage        float64 `json:"load_average"`
+	Uptime             int64   `json:"uptime"`
+}
+
+// Metrics 节点指标
+type Metrics struct {
+	NodeID               string         `json:"node_id"`
+	CPUUsagePercent      float64        `json:"cpu_usage_percent"`
+	MemoryUsagePercent   float64        `json:"memory_usage_percent"`
+	GPUs                 []GPU          `json:"gpus"`
+	CollectedAt          time.Time      `json:"collected_at"`
+	Stale                bool           `json:"stale"`
+	GPUUnavailableReason string         `json:"gpu_unavailable_reason,omitempty"`
+	System               *SystemMetrics `json:"system,omitempty"`
+	Overcommit           *Overcommit    `json:"overcommit,omitempty"`
+}
+
+// Commitment 一类资源的承诺量与物理容量，超分比例为0时limit为0表示不限制
+type Commitment struct {
+	Committed float64 `json:"committed"`
+	Physical  float64 `json:"physical"`
+	Ratio     float64 `json:"ratio"`
+	Limit     float64 `json:"limit,omitempty"`
+}
+
+// GPUMemoryCommitment 单个共享GPU的显存承诺量（MB）
+type GPUMemoryCommitment struct {
+	GPUID int `json:"gpu_id"`
+	Commitment
+	Clients int `json:"clients"`
+}
+
+// Overcommit 节点的资源承诺状态，CPU以核计，内存以MB计
+type Overcommit struct {
+	CPU       Commitment            `json:"cpu"`
+	Memory    Commitment            `json:"memory"`
+	GPUMemory []GPUMemoryCommitment `json:"gpu_memory,omitempty"`
+}
+
+// Tunnel FRP隧道状态
+type Tunnel struct {
+	Name                string `json:"name"`
+	Type                string `json:"type"`
+	Kind                string `json:"kind"`
+	ClaimID             string `json:"claim_id,omitempty"`
+	GPUID               *int   `json:"gpu_id,omitempty"`
+	PortName            string `json:"port_name,omitempty"`
+	LocalIP             string `json:"local_ip"`
+	LocalPort           int    `json:"local_port"`
+	RequestedRemotePort int    `json:"requested_remote_port"`
+	RemotePort          int    `json:"remote_port,omitempty"`
+	Address             string `json:"address,omitempty"`
+	State               string `json:"state"`
+	Error               string `json:"error,omitempty"`
+	Source              string `json:"source,omitempty"`
+}
+
+// Event 节点事件
+type Event struct {
+	Seq         int64                  `json:"seq"`
+	Type        string                 `json:"type"`
+	Severity    string                 `json:"severity"`
+	Time        int64                  `json:"time"`
+	ContainerID string                 `json:"container_id,omitempty"`
+	ClaimID     string                 `json:"claim_id,omitempty"`
+	Message     string                 `json:"message"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+}
+
+// Job 异步任务
+type Job struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Status     string                 `json:"status"`
+	Progress   float64                `json:"progress"`
+	Message    string                 `json:"message,omitempty"`
+	Result     map[string]interface{} `json:"result,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Logs       []string               `json:"logs,omitempty"`
+	CreatedAt  int64                  `json:"created_at"`
+	StartedAt  int64                  `json:"started_at,omitempty"`
+	FinishedAt int64                  `json:"finished_at,omitempty"`
+}
+
+// 异步任务状态
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// FieldError 请求字段的验证错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}